@@ -0,0 +1,905 @@
+// Code generated by cmd/protocolgen from internal/message. DO NOT EDIT.
+
+export const PROTOCOL_VERSION = "1.0";
+
+/** Declare client capabilities */
+export interface ClientHello {
+  version: "1.0";
+  type: "hello";
+  data: {
+    batch?: boolean;
+    binary?: boolean;
+    compression?: boolean;
+    delivery_acks?: boolean;
+    locale?: string;
+  };
+}
+
+/** Opt out of or back into optional broadcast types */
+export interface ClientSubscribe {
+  version: "1.0";
+  type: "subscribe";
+  data: {
+    exclude?: string[];
+    include?: string[];
+  };
+}
+
+/** Submit a message for the active round */
+export interface ClientClientMessage {
+  version: "1.0";
+  type: "client_message";
+  attachment_id?: string;
+  choice?: number | null;
+  data: string | {
+    attachment_id?: string;
+    choice?: number | null;
+    ciphertext?: string;
+    lang?: string;
+    text?: string;
+  };
+  message_id?: string;
+  username?: string;
+}
+
+/** Replace the content of an own submission */
+export interface ClientEditMessage {
+  version: "1.0";
+  type: "edit_message";
+  data: string | {
+    attachment_id?: string;
+    choice?: number | null;
+    ciphertext?: string;
+    lang?: string;
+    text?: string;
+  };
+  message_id: string;
+}
+
+/** Withdraw an own submission */
+export interface ClientWithdrawMessage {
+  version: "1.0";
+  type: "withdraw_message";
+  message_id: string;
+}
+
+/** React to a round winner during the reveal phase */
+export interface ClientReaction {
+  version: "1.0";
+  type: "reaction";
+  data: string;
+  round_id: number;
+}
+
+/** Measure round trip time; answered with pong */
+export interface ClientPing {
+  version: "1.0";
+  type: "ping";
+  data: number;
+}
+
+/** Request a time_sync reply, optionally echoing a client timestamp */
+export interface ClientTimeSync {
+  version: "1.0";
+  type: "time_sync";
+  data?: number;
+}
+
+/** Request the usernames of every connected client */
+export interface ClientParticipants {
+  version: "1.0";
+  type: "participants";
+}
+
+/** Answer a server ping */
+export interface ClientPong {
+  version: "1.0";
+  type: "pong";
+  data: number;
+  server_time?: number;
+}
+
+/** Report whether the app is focused or backgrounded and its queue depth; backgrounded clients get fewer broadcasts */
+export interface ClientHeartbeat {
+  version: "1.0";
+  type: "heartbeat";
+  data: {
+    queue_depth?: number;
+    state: string;
+  };
+}
+
+/** Confirm a round_start or winner_announcement by its delivery_id (clients with delivery_acks) */
+export interface ClientDeliveryAck {
+  version: "1.0";
+  type: "delivery_ack";
+  data: string;
+}
+
+/** Replay game events from a sequence number on after detecting a gap */
+export interface ClientResyncFrom {
+  version: "1.0";
+  type: "resync_from";
+  data: number;
+}
+
+/** Sign in as a guest under a registered name */
+export interface ClientAuth {
+  version: "1.0";
+  type: "auth";
+  data: {
+    username: string;
+  };
+}
+
+/** Remove a submission in the room; owners and moderators only */
+export interface ClientRemoveMessage {
+  version: "1.0";
+  type: "remove_message";
+  message_id: string;
+  reason?: string;
+  round_id: number;
+}
+
+/** Stop a user from submitting in the room for duration_seconds; owners and moderators only */
+export interface ClientMute {
+  version: "1.0";
+  type: "mute";
+  duration_seconds: number;
+  reason?: string;
+  username: string;
+}
+
+/** Negotiated capabilities in reply to hello */
+export interface ServerWelcome {
+  version: "1.0";
+  type: "welcome";
+  data: {
+    batch?: boolean;
+    binary?: boolean;
+    compression?: boolean;
+    delivery_acks?: boolean;
+    locale?: string;
+  };
+}
+
+/** The client's generated guest name, or its registered name after auth */
+export interface ServerIdentity {
+  version: "1.0";
+  type: "identity";
+  data: {
+    guest: boolean;
+    preferences?: unknown;
+    username: string;
+  };
+}
+
+/** Current round, time remaining, last winner and presence count, sent on registration */
+export interface ServerStateSync {
+  version: "1.0";
+  type: "state_sync";
+  announcements?: {
+    expires_at?: string;
+    id: string;
+    sent_at: string;
+    severity: string;
+    text: string;
+    title?: string;
+  }[];
+  last_winner: {
+    round_id: number;
+    winner: {
+      attachment_id?: string;
+      bot?: boolean;
+      choice?: number | null;
+      encrypted?: boolean;
+      id: string;
+      lang?: string;
+      message: string;
+      timestamp: string;
+      username: string;
+    } | null;
+  } | null;
+  muted_until?: string;
+  preferences?: unknown;
+  presence: number;
+  role?: string;
+  round: {
+    active: boolean;
+    choices?: {
+      options: string[];
+      prompt: string;
+    } | null;
+    duration_seconds?: number;
+    ends_at?: string;
+    round_id: number;
+    started_at?: string;
+    submission_deadline?: string;
+    submissions_open: boolean;
+    time_remaining_ms?: number;
+  };
+  seq: number;
+  server_time: string;
+}
+
+/** Token for resuming the session after a disconnect, sent on registration and refreshed at half its lifetime */
+export interface ServerResumeToken {
+  version: "1.0";
+  type: "resume_token";
+  expires_at: string;
+  resumed: boolean;
+  session_id: string;
+  token: string;
+}
+
+/** End of the events replayed for resync_from */
+export interface ServerResyncComplete {
+  version: "1.0";
+  type: "resync_complete";
+  code?: string;
+  complete: boolean;
+  from: number;
+  replayed: number;
+  to: number;
+}
+
+/** Resulting exclusions in reply to subscribe */
+export interface ServerSubscribed {
+  version: "1.0";
+  type: "subscribed";
+  data: {
+    excluded: string[];
+  };
+}
+
+/** A round started */
+export interface ServerRoundStart {
+  version: "1.0";
+  type: "round_start";
+  choices?: {
+    options: string[];
+    prompt: string;
+  } | null;
+  data: number;
+  delivery_id?: string;
+  empty?: boolean;
+  ends_at?: string;
+  next_round_in_seconds?: number;
+  pacing_profile?: string;
+  seq?: number;
+  started_at?: string;
+  submission_deadline?: string;
+  void?: boolean;
+}
+
+/** The submission window of the round ended */
+export interface ServerSubmissionsClosed {
+  version: "1.0";
+  type: "submissions_closed";
+  choices?: {
+    options: string[];
+    prompt: string;
+  } | null;
+  data: number;
+  delivery_id?: string;
+  empty?: boolean;
+  ends_at?: string;
+  next_round_in_seconds?: number;
+  pacing_profile?: string;
+  seq?: number;
+  started_at?: string;
+  submission_deadline?: string;
+  void?: boolean;
+}
+
+/** A round ended */
+export interface ServerRoundEnd {
+  version: "1.0";
+  type: "round_end";
+  choices?: {
+    options: string[];
+    prompt: string;
+  } | null;
+  data: number;
+  delivery_id?: string;
+  empty?: boolean;
+  ends_at?: string;
+  next_round_in_seconds?: number;
+  pacing_profile?: string;
+  seq?: number;
+  started_at?: string;
+  submission_deadline?: string;
+  void?: boolean;
+}
+
+/** Too few users submitted; entries reopen once until the new deadlines */
+export interface ServerRoundExtended {
+  version: "1.0";
+  type: "round_extended";
+  data: number;
+  ends_at?: string;
+  message?: string;
+  min_participants: number;
+  participants: number;
+  seq?: number;
+  started_at?: string;
+  submission_deadline?: string;
+}
+
+/** The round ended with fewer than min_participants participants and has no winner */
+export interface ServerRoundVoid {
+  version: "1.0";
+  type: "round_void";
+  data: number;
+  ends_at?: string;
+  message?: string;
+  min_participants: number;
+  participants: number;
+  seq?: number;
+  started_at?: string;
+  submission_deadline?: string;
+}
+
+/** The winner of a round */
+export interface ServerWinnerAnnouncement {
+  version: "1.0";
+  type: "winner_announcement";
+  attachment_url?: string;
+  choices?: {
+    answer?: number | null;
+    counts: number[];
+    options: string[];
+    prompt: string;
+    winning_options: number[];
+  } | null;
+  delivery_id?: string;
+  message?: string;
+  round_id: number;
+  seq?: number;
+  total_messages: number;
+  winner: {
+    attachment_id?: string;
+    bot?: boolean;
+    choice?: number | null;
+    encrypted?: boolean;
+    id: string;
+    lang?: string;
+    message: string;
+    timestamp: string;
+    username: string;
+  } | null;
+}
+
+/** An admin invalidated a round's winner; winner is the entrant drawn in their place */
+export interface ServerWinnerUpdated {
+  version: "1.0";
+  type: "winner_updated";
+  attachment_url?: string;
+  previous: string;
+  reason: string;
+  round_id: number;
+  seq?: number;
+  supersedes: string;
+  winner: {
+    attachment_id?: string;
+    bot?: boolean;
+    choice?: number | null;
+    encrypted?: boolean;
+    id: string;
+    lang?: string;
+    message: string;
+    timestamp: string;
+    username: string;
+  } | null;
+}
+
+/** Sent to the winner only, held until they connect if they were away; acknowledged with delivery_acks */
+export interface ServerYouWon {
+  version: "1.0";
+  type: "you_won";
+  appeal?: boolean;
+  attachment_url?: string;
+  balance?: number | null;
+  delivery_id?: string;
+  message: string;
+  message_id: string;
+  points: number;
+  round_id: number;
+  streak?: number;
+  wins?: number;
+}
+
+/** An admin changed the game rules; data is the new rule set, as served by /api/rules */
+export interface ServerRulesUpdate {
+  version: "1.0";
+  type: "rules_update";
+  data: {
+    adaptive_rounds: boolean;
+    duplicate_content: string;
+    max_message_length: number;
+    max_submissions_per_round: number;
+    min_message_length: number;
+    pacing_profile?: string;
+    round_duration_seconds: number;
+    round_mode: string;
+    round_pause_seconds: number;
+    rounds_per_hour: number;
+    sanitize_mode: string;
+    scripted_rules: boolean;
+    submission_window_seconds: number;
+    winner_mode: string;
+  };
+  seq?: number;
+}
+
+/** A notice from the operators, not from a player; severity hints at how prominently to show it */
+export interface ServerAnnouncement {
+  version: "1.0";
+  type: "announcement";
+  data: {
+    expires_at?: string;
+    id: string;
+    sent_at: string;
+    severity: string;
+    text: string;
+    title?: string;
+  };
+  seq?: number;
+}
+
+/** The tournament bracket changed */
+export interface ServerBracketUpdate {
+  version: "1.0";
+  type: "bracket_update";
+  data: {
+    champion?: string;
+    finalists: string[];
+    id: string;
+    qualifying_rounds: number;
+    rounds: {
+      round_id: number;
+      stage: string;
+      winner?: string;
+    }[];
+    started_at: string;
+    status: string;
+    updated_at: string;
+  };
+  seq?: number;
+}
+
+/** The running series started a round or recorded its winner */
+export interface ServerSeriesUpdate {
+  version: "1.0";
+  type: "series_update";
+  data: {
+    champion?: string;
+    id: string;
+    length: number;
+    rounds: {
+      round_id: number;
+      winner?: string;
+    }[];
+    standings: {
+      points: number;
+      username: string;
+      wins: number;
+    }[];
+    started_at: string;
+    status: string;
+    updated_at: string;
+  };
+  seq?: number;
+}
+
+/** A series finished, with its champion and final standings */
+export interface ServerSeriesChampion {
+  version: "1.0";
+  type: "series_champion";
+  champion: string;
+  points: number;
+  seq?: number;
+  series_id: string;
+  standings: {
+    points: number;
+    username: string;
+    wins: number;
+  }[];
+}
+
+/** Usernames of every connected client in reply to participants */
+export interface ServerParticipants {
+  version: "1.0";
+  type: "participants";
+  count: number;
+  data: string[];
+}
+
+/** Usernames that connected since the previous roster update, at most every participants_interval_ms; opt out by excluding user_joined */
+export interface ServerUserJoined {
+  version: "1.0";
+  type: "user_joined";
+  count: number;
+  data: string[];
+}
+
+/** Usernames no longer connected since the previous roster update; opt out by excluding user_left */
+export interface ServerUserLeft {
+  version: "1.0";
+  type: "user_left";
+  count: number;
+  data: string[];
+}
+
+/** Batched reaction tally for the round in its reveal phase */
+export interface ServerReactionCounts {
+  version: "1.0";
+  type: "reaction_counts";
+  data: Record<string, number>;
+  round_id: number;
+}
+
+/** A submission, edit or withdrawal was accepted */
+export interface ServerAck {
+  version: "1.0";
+  type: "ack";
+  data: string;
+  duplicate?: boolean;
+  message_id: string;
+  round_id: number;
+  submission?: {
+    attachment_id?: string;
+    bot?: boolean;
+    choice?: number | null;
+    encrypted?: boolean;
+    id: string;
+    lang?: string;
+    message: string;
+    timestamp: string;
+    username: string;
+  } | null;
+}
+
+/** A client message was rejected */
+export interface ServerError {
+  version: "1.0";
+  type: "error";
+  data: string;
+  error_code?: string;
+  errors?: {
+    constraint: string;
+    field: string;
+    message: string;
+  }[];
+  max_bytes?: number;
+  message_id?: string;
+  retriable?: boolean;
+  round_id?: number;
+  username?: string;
+}
+
+/** Latency probe; answer with pong echoing data */
+export interface ServerPing {
+  version: "1.0";
+  type: "ping";
+  data: number;
+}
+
+/** Answer to a client ping */
+export interface ServerPong {
+  version: "1.0";
+  type: "pong";
+  data: number;
+  server_time?: number;
+}
+
+/** Server time and the current round's deadlines, sent every time_sync_seconds and on request */
+export interface ServerTimeSync {
+  version: "1.0";
+  type: "time_sync";
+  client_time?: number;
+  ends_at_ms?: number;
+  ends_in_ms?: number;
+  monotonic_ms: number;
+  round_active: boolean;
+  round_id: number;
+  server_time: number;
+  submission_deadline_ms?: number;
+  submissions_close_in_ms?: number;
+}
+
+/** Reminder at nudge_at_percent of the submission window for clients that have not submitted; opt out by excluding nudge */
+export interface ServerNudge {
+  version: "1.0";
+  type: "nudge";
+  message: string;
+  round_id: number;
+  submissions_close_in_ms: number;
+}
+
+/** The connection turned degraded (a WebSocket pong slower than slow_pong_ms) or good again */
+export interface ServerConnectionQuality {
+  version: "1.0";
+  type: "connection_quality";
+  ping_interval_seconds: number;
+  quality: string;
+  rtt_ms: number;
+}
+
+/** A moderator removed the client's submission; data is the reason */
+export interface ServerMessageRemoved {
+  version: "1.0";
+  type: "message_removed";
+  data: string;
+  duplicate?: boolean;
+  message_id: string;
+  round_id: number;
+  submission?: {
+    attachment_id?: string;
+    bot?: boolean;
+    choice?: number | null;
+    encrypted?: boolean;
+    id: string;
+    lang?: string;
+    message: string;
+    timestamp: string;
+    username: string;
+  } | null;
+}
+
+/** A remove_message or mute was carried out */
+export interface ServerModerationAck {
+  version: "1.0";
+  type: "moderation_ack";
+  action: string;
+  message_id?: string;
+  round_id?: number;
+  until?: string;
+  username: string;
+}
+
+/** The room's owner changed the client's role */
+export interface ServerRoleUpdate {
+  version: "1.0";
+  type: "role_update";
+  data: string;
+}
+
+/** The client's user was muted, or the mute expired or was lifted */
+export interface ServerMuteUpdate {
+  version: "1.0";
+  type: "mute_update";
+  muted: boolean;
+  reason?: string;
+  until?: string;
+}
+
+/** Position in the waiting room while the server is full */
+export interface ServerWaiting {
+  version: "1.0";
+  type: "waiting";
+  data: string;
+  position: number;
+}
+
+/** Left the waiting room and joined the game */
+export interface ServerAdmitted {
+  version: "1.0";
+  type: "admitted";
+  data: string;
+  error_code?: string;
+  errors?: {
+    constraint: string;
+    field: string;
+    message: string;
+  }[];
+  max_bytes?: number;
+  message_id?: string;
+  retriable?: boolean;
+  round_id?: number;
+  username?: string;
+}
+
+/** Every room, sent to /ws/lobby connections when they connect */
+export interface ServerLobbySnapshot {
+  version: "1.0";
+  type: "lobby_snapshot";
+  rooms: {
+    capacity: number;
+    connected: number;
+    created_at: string;
+    encrypted?: boolean;
+    max_submissions_per_round?: number;
+    name: string;
+    owner: string;
+    pacing_profile?: string;
+    public: boolean;
+    round_active: boolean;
+    round_duration_seconds?: number;
+    round_id?: number;
+    submission_window_seconds?: number;
+  }[];
+}
+
+/** A room was created (/ws/lobby) */
+export interface ServerRoomCreated {
+  version: "1.0";
+  type: "room_created";
+  reason?: string;
+  room: {
+    capacity: number;
+    connected: number;
+    created_at: string;
+    encrypted?: boolean;
+    max_submissions_per_round?: number;
+    name: string;
+    owner: string;
+    pacing_profile?: string;
+    public: boolean;
+    round_active: boolean;
+    round_duration_seconds?: number;
+    round_id?: number;
+    submission_window_seconds?: number;
+  };
+}
+
+/** A room's round started or ended, or its occupancy changed (/ws/lobby) */
+export interface ServerRoomUpdated {
+  version: "1.0";
+  type: "room_updated";
+  reason?: string;
+  room: {
+    capacity: number;
+    connected: number;
+    created_at: string;
+    encrypted?: boolean;
+    max_submissions_per_round?: number;
+    name: string;
+    owner: string;
+    pacing_profile?: string;
+    public: boolean;
+    round_active: boolean;
+    round_duration_seconds?: number;
+    round_id?: number;
+    submission_window_seconds?: number;
+  };
+}
+
+/** A room was deleted (/ws/lobby) */
+export interface ServerRoomDeleted {
+  version: "1.0";
+  type: "room_deleted";
+  name: string;
+}
+
+/** A replay of recorded events began (/ws/history) */
+export interface ServerHistoryStart {
+  version: "1.0";
+  type: "history_start";
+  events: number;
+  from: string;
+  source: string;
+  speed: number;
+}
+
+/** A recorded round, submission or winner event, paced as it happened (/ws/history) */
+export interface ServerHistoryEvent {
+  version: "1.0";
+  type: "history_event";
+  data: unknown;
+  subject: string;
+  timestamp: string;
+}
+
+/** Every event of the replay was sent; the server closes the connection (/ws/history) */
+export interface ServerHistoryComplete {
+  version: "1.0";
+  type: "history_complete";
+  events: number;
+}
+
+export type ClientMessage =
+  | ClientHello
+  | ClientSubscribe
+  | ClientClientMessage
+  | ClientEditMessage
+  | ClientWithdrawMessage
+  | ClientReaction
+  | ClientPing
+  | ClientTimeSync
+  | ClientParticipants
+  | ClientPong
+  | ClientHeartbeat
+  | ClientDeliveryAck
+  | ClientResyncFrom
+  | ClientAuth
+  | ClientRemoveMessage
+  | ClientMute;
+
+export type ServerMessage =
+  | ServerWelcome
+  | ServerIdentity
+  | ServerStateSync
+  | ServerResumeToken
+  | ServerResyncComplete
+  | ServerSubscribed
+  | ServerRoundStart
+  | ServerSubmissionsClosed
+  | ServerRoundEnd
+  | ServerRoundExtended
+  | ServerRoundVoid
+  | ServerWinnerAnnouncement
+  | ServerWinnerUpdated
+  | ServerYouWon
+  | ServerRulesUpdate
+  | ServerAnnouncement
+  | ServerBracketUpdate
+  | ServerSeriesUpdate
+  | ServerSeriesChampion
+  | ServerParticipants
+  | ServerUserJoined
+  | ServerUserLeft
+  | ServerReactionCounts
+  | ServerAck
+  | ServerError
+  | ServerPing
+  | ServerPong
+  | ServerTimeSync
+  | ServerNudge
+  | ServerConnectionQuality
+  | ServerMessageRemoved
+  | ServerModerationAck
+  | ServerRoleUpdate
+  | ServerMuteUpdate
+  | ServerWaiting
+  | ServerAdmitted
+  | ServerLobbySnapshot
+  | ServerRoomCreated
+  | ServerRoomUpdated
+  | ServerRoomDeleted
+  | ServerHistoryStart
+  | ServerHistoryEvent
+  | ServerHistoryComplete;
+
+export type ClientMessageType = ClientMessage["type"];
+export type ServerMessageType = ServerMessage["type"];
+export type ServerMessageOf<T extends ServerMessageType> = Extract<ServerMessage, { type: T }>;
+
+/** Sends typed messages over a WebSocket and dispatches received ones by type. */
+export class GameClient {
+  private readonly handlers = new Map<string, Set<(message: ServerMessage) => void>>();
+
+  /** Opens a JSON connection, e.g. to wss://example.com/ws?username=alice. */
+  static connect(url: string): GameClient {
+    return new GameClient(new WebSocket(url));
+  }
+
+  constructor(readonly socket: WebSocket) {
+    socket.addEventListener("message", (event: MessageEvent) => {
+      if (typeof event.data !== "string") {
+        return; // MessagePack frames are not handled by this client
+      }
+      const parsed: ServerMessage | ServerMessage[] = JSON.parse(event.data);
+      for (const message of Array.isArray(parsed) ? parsed : [parsed]) {
+        this.handlers.get(message.type)?.forEach((handler) => handler(message));
+      }
+    });
+  }
+
+  send(message: ClientMessage): void {
+    this.socket.send(JSON.stringify(message));
+  }
+
+  /** Calls handler for every received message of type and returns a function removing it. */
+  on<T extends ServerMessageType>(type: T, handler: (message: ServerMessageOf<T>) => void): () => void {
+    const handlers = this.handlers.get(type) ?? new Set<(message: ServerMessage) => void>();
+    const wrapped = handler as (message: ServerMessage) => void;
+    handlers.add(wrapped);
+    this.handlers.set(type, handlers);
+    return () => handlers.delete(wrapped);
+  }
+
+  close(code?: number, reason?: string): void {
+    this.socket.close(code, reason);
+  }
+}
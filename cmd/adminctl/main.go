@@ -0,0 +1,245 @@
+// cmd/adminctl/main.go
+// Operator CLI for the admin API: list and kick clients, manage bans, force a round end,
+// adjust runtime settings and tail audit events.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: adminctl [flags] <command> [args]
+
+Commands:
+  clients                      list connected clients
+  kick <username>              close all connections of a user
+  bans                         list banned users
+  ban <username> [reason]      ban a user and kick their connections
+  unban <username>             lift a ban
+  end-round                    end the active round immediately
+  remove <round_id> <message_id> [reason]
+                               remove a submission from its round and history
+  config [key=value ...]       show or change runtime settings
+  audit [-follow] [-username u] [-event e] [-limit n]
+                               show audit events, optionally polling for new ones
+
+Flags:
+`
+
+type client struct {
+	server string
+	token  string
+	actor  string
+	http   *http.Client
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "base URL of the server")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin token (defaults to $ADMIN_TOKEN)")
+	output := flag.String("o", "table", "output format: table or json")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *token == "" {
+		fatalf("an admin token is required, pass -token or set ADMIN_TOKEN")
+	}
+	if *output != "table" && *output != "json" {
+		fatalf("unknown output format %q", *output)
+	}
+
+	c := &client{
+		server: strings.TrimRight(*server, "/"),
+		token:  *token,
+		actor:  os.Getenv("USER"),
+		http:   &http.Client{Timeout: 10 * time.Second},
+	}
+	out := newPrinter(os.Stdout, *output == "json")
+
+	args := flag.Args()[1:]
+	switch cmd := flag.Arg(0); cmd {
+	case "clients":
+		var resp struct {
+			Clients []map[string]interface{} `json:"clients"`
+		}
+		c.do(http.MethodGet, "/api/admin/clients", nil, &resp)
+		out.table(resp.Clients, "username", "connected_at", "last_active", "rtt_ms", "remote_ip", "country", "capabilities")
+	case "kick":
+		username := requireArg(args, "kick <username>")
+		var resp map[string]interface{}
+		c.do(http.MethodPost, "/api/admin/clients/"+url.PathEscape(username)+"/kick", nil, &resp)
+		out.object(resp)
+	case "bans":
+		var resp struct {
+			Bans []map[string]interface{} `json:"bans"`
+		}
+		c.do(http.MethodGet, "/api/admin/bans", nil, &resp)
+		out.table(resp.Bans, "username", "reason", "banned_at")
+	case "ban":
+		username := requireArg(args, "ban <username> [reason]")
+		body := map[string]string{"username": username, "reason": strings.Join(args[1:], " ")}
+		var resp map[string]interface{}
+		c.do(http.MethodPost, "/api/admin/bans", body, &resp)
+		out.object(resp)
+	case "unban":
+		username := requireArg(args, "unban <username>")
+		c.do(http.MethodDelete, "/api/admin/bans/"+url.PathEscape(username), nil, nil)
+		out.message("unbanned " + username)
+	case "end-round":
+		var resp map[string]interface{}
+		c.do(http.MethodPost, "/api/admin/rounds/end", nil, &resp)
+		out.object(resp)
+	case "remove":
+		if len(args) < 2 {
+			fatalf("usage: adminctl remove <round_id> <message_id> [reason]")
+		}
+		body := map[string]string{"reason": strings.Join(args[2:], " ")}
+		var resp map[string]interface{}
+		c.do(http.MethodDelete, "/api/admin/rounds/"+url.PathEscape(args[0])+"/messages/"+url.PathEscape(args[1]), body, &resp)
+		out.object(resp)
+	case "config":
+		var resp map[string]interface{}
+		if len(args) == 0 {
+			c.do(http.MethodGet, "/api/admin/config", nil, &resp)
+		} else {
+			c.do(http.MethodPatch, "/api/admin/config", parseSettings(args), &resp)
+		}
+		out.object(resp)
+	case "audit":
+		runAudit(c, out, args)
+	default:
+		fatalf("unknown command %q, run adminctl -h for help", cmd)
+	}
+}
+
+// runAudit prints audit entries and, with -follow, polls for entries newer than the last one printed.
+func runAudit(c *client, out *printer, args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	follow := fs.Bool("follow", false, "keep polling for new events")
+	interval := fs.Duration("interval", 2*time.Second, "poll interval with -follow")
+	username := fs.String("username", "", "only events for this user")
+	event := fs.String("event", "", "only this event type")
+	limit := fs.Int("limit", 100, "number of recent events to show")
+	fs.Parse(args)
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(*limit))
+	if *username != "" {
+		query.Set("username", *username)
+	}
+	if *event != "" {
+		query.Set("event", *event)
+	}
+
+	columns := []string{"timestamp", "event", "username", "message", "detail"}
+	last := ""
+	for {
+		var resp struct {
+			Entries []map[string]interface{} `json:"entries"`
+		}
+		c.do(http.MethodGet, "/api/audit?"+query.Encode(), nil, &resp)
+
+		fresh := make([]map[string]interface{}, 0, len(resp.Entries))
+		for _, entry := range resp.Entries {
+			if ts, _ := entry["timestamp"].(string); ts > last {
+				fresh = append(fresh, entry)
+			}
+		}
+		if len(fresh) > 0 {
+			last, _ = fresh[len(fresh)-1]["timestamp"].(string)
+			if *follow {
+				out.rows(fresh, columns)
+			} else {
+				out.table(fresh, columns...)
+			}
+		}
+		if !*follow {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// parseSettings turns key=value arguments into a JSON patch, keeping numbers and booleans typed.
+func parseSettings(args []string) map[string]interface{} {
+	patch := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			fatalf("expected key=value, got %q", arg)
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			patch[key] = n
+		} else if b, err := strconv.ParseBool(value); err == nil {
+			patch[key] = b
+		} else {
+			patch[key] = value
+		}
+	}
+	return patch
+}
+
+// do sends an authenticated request and decodes the JSON response into result, exiting on failure.
+func (c *client) do(method, path string, body, result interface{}) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			fatalf("encoding request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		fatalf("building request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if c.actor != "" {
+		req.Header.Set("X-Admin-User", c.actor)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		fatalf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			fatalf("decoding response: %v", err)
+		}
+	}
+}
+
+func requireArg(args []string, form string) string {
+	if len(args) == 0 || args[0] == "" {
+		fatalf("usage: adminctl %s", form)
+	}
+	return args[0]
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "adminctl: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,109 @@
+// cmd/adminctl/output.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer renders responses either as JSON or as aligned tables.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, asJSON bool) *printer {
+	return &printer{w: w, json: asJSON}
+}
+
+// table prints one row per item with the given columns and a header.
+func (p *printer) table(items []map[string]interface{}, columns ...string) {
+	if p.json {
+		p.encode(items)
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	p.writeRows(tw, items, columns)
+	tw.Flush()
+}
+
+// rows prints items without a header, used when following a stream of entries.
+func (p *printer) rows(items []map[string]interface{}, columns []string) {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		for _, item := range items {
+			enc.Encode(item)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	p.writeRows(tw, items, columns)
+	tw.Flush()
+}
+
+func (p *printer) writeRows(w io.Writer, items []map[string]interface{}, columns []string) {
+	for _, item := range items {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = formatValue(item[column])
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+// object prints a single response as key/value lines.
+func (p *printer) object(obj map[string]interface{}) {
+	if p.json {
+		p.encode(obj)
+		return
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%s\t%s\n", key, formatValue(obj[key]))
+	}
+	tw.Flush()
+}
+
+func (p *printer) message(msg string) {
+	if p.json {
+		p.encode(map[string]string{"result": msg})
+		return
+	}
+	fmt.Fprintln(p.w, msg)
+}
+
+func (p *printer) encode(v interface{}) {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// formatValue renders a decoded JSON value for a table cell.
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if value == "" {
+			return "-"
+		}
+		return value
+	case float64:
+		return fmt.Sprintf("%g", value)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
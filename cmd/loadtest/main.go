@@ -0,0 +1,146 @@
+// cmd/loadtest/main.go
+// Load test tool: connects N simulated WebSocket clients to a running server,
+// submits one message per round from each client and reports connection success rate,
+// ack latency percentiles and dropped broadcasts.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// broadcastTypes are the message types every connected client is expected to receive.
+var broadcastTypes = []string{"round_start", "round_end", "winner_announcement"}
+
+type options struct {
+	target   string
+	clients  int
+	duration time.Duration
+	rampUp   time.Duration
+	prefix   string
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.target, "url", "ws://localhost:8080/ws", "WebSocket endpoint of the server under test")
+	flag.IntVar(&opts.clients, "clients", 100, "number of simulated clients")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "how long each client stays connected")
+	flag.DurationVar(&opts.rampUp, "ramp", 5*time.Second, "time over which clients are connected")
+	flag.StringVar(&opts.prefix, "prefix", "lt", "username prefix for simulated clients")
+	flag.Parse()
+
+	if opts.clients < 1 {
+		fmt.Fprintln(os.Stderr, "clients must be at least 1")
+		os.Exit(2)
+	}
+
+	results := run(opts)
+	results.print(os.Stdout)
+}
+
+// run starts all simulated clients and waits for them to finish.
+func run(opts options) *report {
+	rep := newReport()
+	var wg sync.WaitGroup
+
+	var interval time.Duration
+	if opts.clients > 1 {
+		interval = opts.rampUp / time.Duration(opts.clients-1)
+	}
+	for i := 0; i < opts.clients; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			simulateClient(opts, fmt.Sprintf("%s_%d", opts.prefix, id), rep)
+		}(i)
+		time.Sleep(interval)
+	}
+	wg.Wait()
+	return rep
+}
+
+// simulateClient connects a single user, submits a message at every round start
+// and records acks and received broadcasts until the duration elapses.
+func simulateClient(opts options, username string, rep *report) {
+	u, err := url.Parse(opts.target)
+	if err != nil {
+		rep.connectFailed(err)
+		return
+	}
+	q := u.Query()
+	q.Set("username", username)
+	u.RawQuery = q.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		rep.connectFailed(err)
+		return
+	}
+	defer conn.Close()
+	rep.connected()
+
+	seen := make(map[string]map[float64]bool)
+	for _, t := range broadcastTypes {
+		seen[t] = make(map[float64]bool)
+	}
+	var sentAt time.Time
+	deadline := time.Now().Add(opts.duration)
+	conn.SetReadDeadline(deadline)
+
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		// The server may batch several JSON documents into one frame separated by newlines.
+		for _, doc := range bytes.Split(frame, []byte{'\n'}) {
+			var msg map[string]interface{}
+			if err := json.Unmarshal(doc, &msg); err != nil {
+				rep.invalidFrame()
+				continue
+			}
+			msgType, _ := msg["type"].(string)
+			switch msgType {
+			case "round_start":
+				seen[msgType][roundOf(msg)] = true
+				sentAt = time.Now()
+				submit := map[string]interface{}{
+					"version":  "1.0",
+					"type":     "client_message",
+					"username": username,
+					"data":     fmt.Sprintf("load test message from %s", username),
+				}
+				if err := conn.WriteJSON(submit); err != nil {
+					rep.submitFailed()
+				}
+			case "ack":
+				if !sentAt.IsZero() {
+					rep.ackLatency(time.Since(sentAt))
+					sentAt = time.Time{}
+				}
+			case "error":
+				rep.serverError()
+			case "round_end", "winner_announcement":
+				seen[msgType][roundOf(msg)] = true
+			}
+		}
+	}
+	rep.finished(seen)
+}
+
+// roundOf extracts the round ID from a broadcast, which uses either "data" or "round_id".
+func roundOf(msg map[string]interface{}) float64 {
+	if id, ok := msg["round_id"].(float64); ok {
+		return id
+	}
+	id, _ := msg["data"].(float64)
+	return id
+}
@@ -0,0 +1,154 @@
+// cmd/loadtest/report.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// report aggregates results from all simulated clients.
+type report struct {
+	mu            sync.Mutex
+	attempts      int
+	connects      int
+	connectErrors map[string]int
+	submitErrors  int
+	serverErrors  int
+	invalidFrames int
+	latencies     []time.Duration
+	clientRounds  []map[string]map[float64]bool
+}
+
+func newReport() *report {
+	return &report{connectErrors: make(map[string]int)}
+}
+
+func (r *report) connected() {
+	r.mu.Lock()
+	r.attempts++
+	r.connects++
+	r.mu.Unlock()
+}
+
+func (r *report) connectFailed(err error) {
+	r.mu.Lock()
+	r.attempts++
+	r.connectErrors[err.Error()]++
+	r.mu.Unlock()
+}
+
+func (r *report) submitFailed() {
+	r.mu.Lock()
+	r.submitErrors++
+	r.mu.Unlock()
+}
+
+func (r *report) serverError() {
+	r.mu.Lock()
+	r.serverErrors++
+	r.mu.Unlock()
+}
+
+func (r *report) invalidFrame() {
+	r.mu.Lock()
+	r.invalidFrames++
+	r.mu.Unlock()
+}
+
+func (r *report) ackLatency(d time.Duration) {
+	r.mu.Lock()
+	r.latencies = append(r.latencies, d)
+	r.mu.Unlock()
+}
+
+func (r *report) finished(seen map[string]map[float64]bool) {
+	r.mu.Lock()
+	r.clientRounds = append(r.clientRounds, seen)
+	r.mu.Unlock()
+}
+
+// droppedBroadcasts counts, per message type, rounds that at least one client received
+// but another client missed while it was connected (between the first and last round it saw).
+func (r *report) droppedBroadcasts() map[string]int {
+	global := make(map[string]map[float64]bool)
+	for _, seen := range r.clientRounds {
+		for msgType, rounds := range seen {
+			if global[msgType] == nil {
+				global[msgType] = make(map[float64]bool)
+			}
+			for id := range rounds {
+				global[msgType][id] = true
+			}
+		}
+	}
+
+	dropped := make(map[string]int)
+	for _, seen := range r.clientRounds {
+		for msgType, rounds := range seen {
+			if len(rounds) == 0 {
+				continue
+			}
+			first, last := bounds(rounds)
+			for id := range global[msgType] {
+				if id >= first && id <= last && !rounds[id] {
+					dropped[msgType]++
+				}
+			}
+		}
+	}
+	return dropped
+}
+
+func bounds(rounds map[float64]bool) (float64, float64) {
+	first, last := -1.0, -1.0
+	for id := range rounds {
+		if first < 0 || id < first {
+			first = id
+		}
+		if id > last {
+			last = id
+		}
+	}
+	return first, last
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
+
+// print writes a human readable summary.
+func (r *report) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fmt.Fprintln(w, "== Connections ==")
+	rate := 0.0
+	if r.attempts > 0 {
+		rate = float64(r.connects) / float64(r.attempts) * 100
+	}
+	fmt.Fprintf(w, "attempted: %d  succeeded: %d  success rate: %.1f%%\n", r.attempts, r.connects, rate)
+	for msg, n := range r.connectErrors {
+		fmt.Fprintf(w, "  %4d x %s\n", n, msg)
+	}
+
+	fmt.Fprintln(w, "== Acks ==")
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	fmt.Fprintf(w, "acks: %d  submit errors: %d  server errors: %d\n", len(sorted), r.submitErrors, r.serverErrors)
+	fmt.Fprintf(w, "latency p50: %v  p90: %v  p99: %v  max: %v\n",
+		percentile(sorted, 0.50), percentile(sorted, 0.90), percentile(sorted, 0.99), percentile(sorted, 1))
+
+	fmt.Fprintln(w, "== Broadcasts ==")
+	dropped := r.droppedBroadcasts()
+	for _, msgType := range broadcastTypes {
+		fmt.Fprintf(w, "%-20s dropped: %d\n", msgType, dropped[msgType])
+	}
+	fmt.Fprintf(w, "invalid frames: %d\n", r.invalidFrames)
+}
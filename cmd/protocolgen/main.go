@@ -0,0 +1,27 @@
+// cmd/protocolgen/main.go
+// Writes the TypeScript types and client of the WebSocket protocol, generated from the
+// structs in internal/message. Run through go generate ./internal/message.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/erilali/internal/message"
+)
+
+func main() {
+	output := flag.String("o", "", "file to write, standard output when empty")
+	flag.Parse()
+
+	source := message.TypeScript()
+	if *output == "" {
+		os.Stdout.Write(source)
+		return
+	}
+	if err := os.WriteFile(*output, source, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "protocolgen: %v\n", err)
+		os.Exit(1)
+	}
+}
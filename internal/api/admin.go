@@ -0,0 +1,360 @@
+// internal/api/admin.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/sso"
+)
+
+// clientLister is implemented by hubs that can describe their connected clients.
+type clientLister interface {
+	ClientInfos() []hub.ClientInfo
+}
+
+// controlPlane is implemented by hubs that carry admin commands to every server instance.
+// Handlers fall back to the local adminController methods without one.
+type controlPlane interface {
+	Control(cmd hub.ControlCommand) hub.ControlResult
+}
+
+// adminClientsHandler serves GET /api/admin/clients with per-client connection details,
+// merged across all instances when a control plane is available.
+func adminClientsHandler(lister clientLister, cluster controlPlane) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		response := map[string]interface{}{}
+		var clients []hub.ClientInfo
+		if cluster != nil {
+			result := cluster.Control(hub.ControlCommand{Action: hub.ControlClients, Actor: adminActor(r)})
+			clients = result.Clients()
+			response["instances"] = len(result.Replies)
+		} else {
+			clients = lister.ClientInfos()
+		}
+		response["clients"] = clients
+		response["count"] = len(clients)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// writeControlResult encodes the outcome of a command fanned out to all instances.
+func writeControlResult(w http.ResponseWriter, status int, response map[string]interface{}, result hub.ControlResult) {
+	response["instances"] = result.Replies
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// adminController is implemented by hubs that support operator actions.
+type adminController interface {
+	KickClient(username, actor string) int
+	BanUser(username, reason, actor string) hub.Ban
+	UnbanUser(username, actor string) bool
+	Bans() []hub.Ban
+	ForceEndRound(actor string) (int64, error)
+	RuntimeSettings() hub.RuntimeSettings
+	ApplyRuntimeSettings(settings hub.RuntimeSettings, actor string) error
+	RemoveSubmission(roundID int64, messageID, reason, actor string) (hub.Redaction, error)
+	InvalidateWinner(roundID int64, reason, actor string) (hub.WinnerCorrection, error)
+}
+
+// adminActor names the operator behind a request for the audit log.
+// Service accounts are named after themselves and SSO sessions after the signed-in user;
+// with the shared admin token, which carries no identity, tools may set X-Admin-User.
+func adminActor(r *http.Request) string {
+	if account, ok := r.Context().Value(serviceAccountKey{}).(string); ok {
+		return account
+	}
+	if session, ok := r.Context().Value(adminSessionKey{}).(sso.Session); ok {
+		return session.Name
+	}
+	if actor := r.Header.Get("X-Admin-User"); actor != "" {
+		return actor
+	}
+	return "admin"
+}
+
+// adminClientActionHandler serves POST /api/admin/clients/{username}/kick. With a control
+// plane the kick reaches whichever instance holds the user's connections.
+func adminClientActionHandler(controller adminController, cluster controlPlane) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/admin/clients/")
+		username, action, _ := strings.Cut(rest, "/")
+		if username == "" || action != "kick" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if cluster != nil {
+			result := cluster.Control(hub.ControlCommand{Action: hub.ControlKick, Username: username, Actor: adminActor(r)})
+			status := http.StatusOK
+			if result.Kicked() == 0 {
+				status = http.StatusNotFound
+			}
+			writeControlResult(w, status, map[string]interface{}{
+				"username": username,
+				"kicked":   result.Kicked(),
+			}, result)
+			return
+		}
+		kicked := controller.KickClient(username, adminActor(r))
+		if kicked == 0 {
+			http.Error(w, "Client not connected", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"username": username,
+			"kicked":   kicked,
+		})
+	}
+}
+
+// adminBansHandler serves GET/POST /api/admin/bans and DELETE /api/admin/bans/{username}.
+// With a control plane bans and unbans apply to every instance; GET lists this instance's bans.
+func adminBansHandler(controller adminController, cluster controlPlane) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/bans"), "/")
+		switch {
+		case username == "" && r.Method == http.MethodGet:
+			bans := controller.Bans()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"bans":  bans,
+				"count": len(bans),
+			})
+		case username == "" && r.Method == http.MethodPost:
+			var req struct {
+				Username string `json:"username"`
+				Reason   string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+				http.Error(w, "Expected JSON body with username and optional reason", http.StatusBadRequest)
+				return
+			}
+			if cluster != nil {
+				result := cluster.Control(hub.ControlCommand{Action: hub.ControlBan, Username: req.Username, Reason: req.Reason, Actor: adminActor(r)})
+				writeControlResult(w, http.StatusCreated, map[string]interface{}{
+					"username": req.Username,
+					"reason":   req.Reason,
+					"kicked":   result.Kicked(),
+				}, result)
+				return
+			}
+			ban := controller.BanUser(req.Username, req.Reason, adminActor(r))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(ban)
+		case username != "" && r.Method == http.MethodDelete:
+			if cluster != nil {
+				result := cluster.Control(hub.ControlCommand{Action: hub.ControlUnban, Username: username, Actor: adminActor(r)})
+				if !result.Found() {
+					http.Error(w, "User is not banned", http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if !controller.UnbanUser(username, adminActor(r)) {
+				http.Error(w, "User is not banned", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// adminEndRoundHandler serves POST /api/admin/rounds/end. Every instance runs its own
+// rounds, so with a control plane the active round of each instance is ended: round_ids
+// maps every instance that ended a round to that round, round_id is this instance's
+// round if it had one, and the per-instance outcome is listed under instances.
+func adminEndRoundHandler(controller adminController, cluster controlPlane) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if cluster != nil {
+			result := cluster.Control(hub.ControlCommand{Action: hub.ControlEndRound, Actor: adminActor(r)})
+			roundIDs := make(map[string]int64, len(result.Replies))
+			for _, reply := range result.Replies {
+				if reply.Error == "" {
+					roundIDs[reply.Instance] = reply.RoundID
+				}
+			}
+			if len(roundIDs) == 0 {
+				http.Error(w, "No active round", http.StatusConflict)
+				return
+			}
+			response := map[string]interface{}{
+				"round_ids": roundIDs,
+				"ended":     len(roundIDs),
+			}
+			if local := result.Replies[0]; local.Error == "" {
+				response["round_id"] = local.RoundID
+			}
+			writeControlResult(w, http.StatusOK, response, result)
+			return
+		}
+		roundID, err := controller.ForceEndRound(adminActor(r))
+		if errors.Is(err, hub.ErrNoActiveRound) {
+			http.Error(w, "No active round", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"round_id": roundID})
+	}
+}
+
+// adminRoundsHandler routes DELETE /api/admin/rounds/{roundID}/messages/{messageID},
+// POST /api/admin/rounds/{roundID}/winner/invalidate and PUT /api/admin/rounds/{roundID}/tags.
+func adminRoundsHandler(controller adminController, tagger roundTagger) http.HandlerFunc {
+	removeMessage := adminRemoveMessageHandler(controller)
+	invalidateWinner := adminInvalidateWinnerHandler(controller)
+	setTags := adminRoundTagsHandler(tagger)
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/rounds/"), "/")
+		if len(parts) == 3 && parts[1] == "winner" && parts[2] == "invalidate" {
+			invalidateWinner(w, r)
+			return
+		}
+		if len(parts) == 2 && parts[1] == "tags" {
+			setTags(w, r)
+			return
+		}
+		removeMessage(w, r)
+	}
+}
+
+// adminInvalidateWinnerHandler serves POST /api/admin/rounds/{roundID}/winner/invalidate
+// with an optional {"reason": "..."} body. The winner is disqualified and a new one drawn
+// among the remaining entrants; the response is the correction. The hub drops the round
+// from the history cache of every instance.
+func adminInvalidateWinnerHandler(controller adminController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/rounds/"), "/")
+		roundID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid round ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		correction, err := controller.InvalidateWinner(roundID, req.Reason, adminActor(r))
+		switch {
+		case errors.Is(err, hub.ErrNoWinner):
+			http.Error(w, "Round has no winner to invalidate", http.StatusNotFound)
+			return
+		case errors.Is(err, hub.ErrAppealWindowClosed):
+			http.Error(w, "Appeal window has closed", http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(correction)
+	}
+}
+
+// adminRemoveMessageHandler serves DELETE /api/admin/rounds/{roundID}/messages/{messageID}
+// with an optional JSON body {"reason": "..."}. The hub drops the round from the history cache of every instance.
+func adminRemoveMessageHandler(controller adminController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/rounds/"), "/")
+		if len(parts) != 3 || parts[1] != "messages" || parts[2] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		roundID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid round ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		redaction, err := controller.RemoveSubmission(roundID, parts[2], req.Reason, adminActor(r))
+		if errors.Is(err, hub.ErrMessageNotFound) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(redaction)
+	}
+}
+
+// adminConfigHandler serves GET and PATCH /api/admin/config. A PATCH body only needs
+// the settings being changed; the rest keep their current values.
+func adminConfigHandler(controller adminController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPatch:
+			settings := controller.RuntimeSettings()
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&settings); err != nil {
+				http.Error(w, "Invalid settings: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := controller.ApplyRuntimeSettings(settings, adminActor(r)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(controller.RuntimeSettings())
+	}
+}
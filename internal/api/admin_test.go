@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erilali/internal/hub"
+)
+
+// fakeCluster answers every command with result.
+type fakeCluster struct {
+	result hub.ControlResult
+}
+
+func (c fakeCluster) Control(hub.ControlCommand) hub.ControlResult { return c.result }
+
+func TestAdminEndRoundHandlerInstances(t *testing.T) {
+	tests := []struct {
+		name     string
+		replies  []hub.ControlReply
+		status   int
+		roundID  *int64
+		roundIDs map[string]int64
+	}{
+		{"every instance", []hub.ControlReply{{Instance: "a", RoundID: 11}, {Instance: "b", RoundID: 22}}, http.StatusOK, ptr(int64(11)), map[string]int64{"a": 11, "b": 22}},
+		{"this instance idle", []hub.ControlReply{{Instance: "a", Error: hub.ErrNoActiveRound.Error()}, {Instance: "b", RoundID: 22}}, http.StatusOK, nil, map[string]int64{"b": 22}},
+		{"no active round", []hub.ControlReply{{Instance: "a", Error: hub.ErrNoActiveRound.Error()}}, http.StatusConflict, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			cluster := fakeCluster{hub.ControlResult{Replies: tt.replies}}
+			adminEndRoundHandler(nil, cluster)(rec, httptest.NewRequest(http.MethodPost, "/api/admin/rounds/end", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				RoundID  *int64           `json:"round_id"`
+				RoundIDs map[string]int64 `json:"round_ids"`
+				Ended    int              `json:"ended"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("response %s: %v", rec.Body, err)
+			}
+			if (body.RoundID == nil) != (tt.roundID == nil) || (body.RoundID != nil && *body.RoundID != *tt.roundID) {
+				t.Errorf("round_id = %v, want %v", body.RoundID, tt.roundID)
+			}
+			if len(body.RoundIDs) != len(tt.roundIDs) || body.Ended != len(tt.roundIDs) {
+				t.Errorf("round_ids = %v and ended = %d, want %v", body.RoundIDs, body.Ended, tt.roundIDs)
+			}
+			for instance, roundID := range tt.roundIDs {
+				if body.RoundIDs[instance] != roundID {
+					t.Errorf("round of instance %s = %d, want %d", instance, body.RoundIDs[instance], roundID)
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
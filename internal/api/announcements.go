@@ -0,0 +1,55 @@
+// internal/api/announcements.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/message"
+)
+
+// announcer is implemented by hubs that broadcast operator announcements.
+type announcer interface {
+	NewAnnouncement(severity, title, text string, ttl time.Duration) (message.Announcement, error)
+	Announce(announcement message.Announcement, actor string)
+}
+
+// adminAnnouncementsHandler serves POST /api/admin/announcements with a body of text and
+// optional severity, title and expires_in_seconds. With a control plane the announcement
+// reaches the clients of every instance.
+func adminAnnouncementsHandler(announcer announcer, cluster controlPlane) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Severity         string `json:"severity"`
+			Title            string `json:"title"`
+			Text             string `json:"text"`
+			ExpiresInSeconds int    `json:"expires_in_seconds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Expected JSON body with text and optional severity, title and expires_in_seconds", http.StatusBadRequest)
+			return
+		}
+		announcement, err := announcer.NewAnnouncement(req.Severity, req.Title, req.Text, time.Duration(req.ExpiresInSeconds)*time.Second)
+		if err != nil {
+			http.Error(w, "Invalid announcement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if cluster != nil {
+			result := cluster.Control(hub.ControlCommand{Action: hub.ControlAnnounce, Announcement: &announcement, Actor: adminActor(r)})
+			writeControlResult(w, http.StatusCreated, map[string]interface{}{
+				"announcement": announcement,
+			}, result)
+			return
+		}
+		announcer.Announce(announcement, adminActor(r))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(announcement)
+	}
+}
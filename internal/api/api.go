@@ -3,90 +3,117 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/eventbus"
+	hubpkg "github.com/erilali/internal/hub"
 	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/sso"
+	"github.com/erilali/internal/streams"
+	"github.com/erilali/internal/util"
+	"github.com/erilali/internal/web"
 	"github.com/nats-io/nats.go"
 )
 
 const (
-	defaultNatsURL          = nats.DefaultURL
-	jetstreamRetention      = 30 * time.Minute
-	apiConsumerMaxDeliver   = 1
+	historyRetention        = 30 * time.Minute
+	auditRetention          = 24 * time.Hour
+	summaryRetention        = 24 * time.Hour
+	roundEventLimit         = 10000           // message events read per round
+	roundFetchDeadline      = 5 * time.Second // deadline for reading all events of a round
 	apiConsumerFetchMaxWait = 2 * time.Second
 	winnerAPIFetchMaxWait   = 1 * time.Second
-	apiConsumerPrefix       = "API_CONSUMER_"
+	winnerHistoryLimit      = 16              // winner records read per round: the selection and any appeal corrections
+	historyQueueWait        = 2 * time.Second // longest an API request waits for a history read slot
+	shutdownTimeout         = 10 * time.Second
 )
 
-// StartServer starts the websocket and HTTP server.
-func StartServer(serverLogger *logger.Logger, hubFactory func(*nats.Conn, nats.JetStreamContext, *logger.Logger) interface{}) {
-	// Connect to NATS using environment variable or default URL
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = defaultNatsURL
-	}
+// StartServer starts the websocket and HTTP server. It first runs the startup
+// self-check, which includes the preflight results of checks done by the caller.
+func StartServer(cfg config.Config, serverLogger *logger.Logger, hubFactory func(config.Config, *nats.Conn, nats.JetStreamContext, eventbus.EventBus, *logger.Logger) interface{}, preflight ...CheckResult) {
+	var nc *nats.Conn
+	var js nats.JetStreamContext
+	var bus eventbus.EventBus
+	natsStatus := &connectionStatus{}
+	report := &StartupReport{Strict: cfg.StrictStartup, Checks: append([]CheckResult(nil), preflight...)}
+	report.checkConfig(cfg)
 
-	serverLogger.Infof("Connecting to NATS at %s", natsURL)
-	nc, err := nats.Connect(natsURL)
+	streamSpec, err := loadStreamSpec(cfg)
 	if err != nil {
-		serverLogger.Errorf("Error connecting to NATS: %v", err) // Wrapped error
-		nc = nil
-		serverLogger.Warn("Running without NATS connection. Message persistence will be disabled.")
-	} else {
-		serverLogger.Info("Successfully connected to NATS")
+		serverLogger.Errorf("Leaving JetStream streams unchanged, invalid stream spec: %v", err)
+	}
+	report.addError("streams_file", err, cfg.StreamsFile)
+	monitoredSpec := streamSpec
+	if err != nil {
+		monitoredSpec = defaultStreamSpec()
 	}
+	streamNames := monitoredSpec.Names()
 
-	// Set up JetStream if NATS is connected
-	var js nats.JetStreamContext
-	if nc != nil {
-		jsContext, err := nc.JetStream()
+	switch cfg.EventBus {
+	case config.EventBusRedis:
+		serverLogger.Infof("Connecting to Redis at %s", cfg.RedisURL)
+		redisBus, err := eventbus.NewRedisBus(cfg.RedisURL, historyRetention, serverLogger)
 		if err != nil {
-			serverLogger.Errorf("Error getting JetStream context: %v", err)
-			serverLogger.Warn("Running without JetStream. Message persistence will be disabled.")
+			serverLogger.Errorf("Error connecting to Redis: %v", err)
+			serverLogger.Warn("Running without Redis connection. Message persistence will be disabled.")
 		} else {
-			js = jsContext
-			serverLogger.Info("Successfully connected to JetStream")
-
-			// Set up JetStream streams with configurable retention
-			streams := []struct {
-				Name     string
-				Subjects []string
-			}{
-				{Name: "ROUNDS", Subjects: []string{"rounds.started.*", "rounds.ended.*"}},
-				{Name: "MESSAGES", Subjects: []string{"messages.*"}},
-				{Name: "WINNERS", Subjects: []string{"winners.*"}},
-			}
-			for _, s := range streams {
-				streamConfig := &nats.StreamConfig{
-					Name:     s.Name,
-					Subjects: s.Subjects,
-					Storage:  nats.FileStorage,
-					MaxAge:   jetstreamRetention,
-				}
-				_, err := js.StreamInfo(streamConfig.Name)
-				if err != nil {
-					_, err = js.AddStream(streamConfig)
-					if err != nil {
-						serverLogger.Errorf("Error creating stream %s: %v", s.Name, err) // Wrapped error
-					} else {
-						serverLogger.Infof("Created stream: %s", s.Name)
-					}
-				} else {
-					_, err = js.UpdateStream(streamConfig)
-					if err != nil {
-						serverLogger.Errorf("Error updating stream %s: %v", s.Name, err) // Wrapped error
-					} else {
-						serverLogger.Infof("Updated stream: %s", s.Name)
-					}
-				}
-			}
+			bus = eventbus.WithPrefix(redisBus, cfg.SubjectPrefix)
+			serverLogger.Info("Successfully connected to Redis")
+		}
+	default:
+		nc, js = connectNATS(cfg, streamSpec, natsStatus, serverLogger)
+		if js != nil {
+			bus = eventbus.WithPrefix(eventbus.NewJetStreamBus(nc, js, serverLogger), cfg.SubjectPrefix)
+		}
+	}
+
+	report.checkEventBus(cfg, nc, js, bus, natsStatus, streamNames)
+	ui, uiErr := web.New(cfg.StaticDir)
+	if cfg.StaticDir == "" {
+		report.addError("ui", uiErr, "embedded")
+	} else {
+		report.addError("ui", uiErr, cfg.StaticDir)
+	}
+	gameListener := report.listen(cfg, "listen_addr", cfg.ListenAddr)
+	var adminListener net.Listener
+	if cfg.AdminListenAddr != "" {
+		adminListener = report.listen(cfg, "admin_listen_addr", cfg.AdminListenAddr)
+	}
+	report.finish(serverLogger)
+	if gameListener == nil || (cfg.AdminListenAddr != "" && adminListener == nil) {
+		serverLogger.Fatal("Cannot listen on the configured addresses")
+	}
+
+	hub := hubFactory(cfg, nc, js, bus, serverLogger)
+
+	var domains streams.Domains
+	if js != nil {
+		domains = streams.ConnDomains(nc, js)
+	}
+	lagMonitor := streams.NewMonitor(domains, monitoredSpec, cfg, serverLogger)
+	if lagMonitor != nil {
+		// The monitor runs with the hub so it stops on shutdown.
+		if background, ok := hub.(backgroundRunner); ok {
+			background.RunInBackground(lagMonitor.Run)
+		} else {
+			serverLogger.Warn("Hub cannot run background work; the lag monitor is not started")
 		}
 	}
-	hub := hubFactory(nc, js, serverLogger)
+
+	// API handlers read history through a bus that bounds how many history consumers
+	// they create at once; the hub keeps the unrestricted bus.
+	historyBus := eventbus.WithHistoryLimit(bus, cfg.HistoryConcurrency, historyQueueWait)
 
 	// Validate that hub implements required interfaces
 	hubRunner, ok := hub.(interface{ Run() })
@@ -103,180 +130,374 @@ func StartServer(serverLogger *logger.Logger, hubFactory func(*nats.Conn, nats.J
 
 	go hubRunner.Run()
 
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	gameMux := http.NewServeMux()
+	adminMux := http.NewServeMux()
+
+	gameMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		hubServer.ServeWs(w, r)
 	})
+	if lobby, ok := hub.(interface {
+		ServeLobby(http.ResponseWriter, *http.Request)
+	}); ok {
+		gameMux.HandleFunc("/ws/lobby", lobby.ServeLobby)
+	}
+	if history, ok := hub.(interface {
+		ServeHistory(http.ResponseWriter, *http.Request)
+	}); ok {
+		gameMux.HandleFunc("/ws/history", history.ServeHistory)
+	}
 
-	// Serve the test UI
-	// Serve the UI at root and /ui for convenience
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
-			http.ServeFile(w, r, "test-ui.html")
-			return
-		}
-		http.NotFound(w, r)
-	})
-	http.HandleFunc("/ui", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "test-ui.html")
-	})
+	// Serve the UI embedded in the binary, or from static_dir. Unmatched paths without an
+	// extension, such as /ui, get index.html for the app's client-side routes.
+	if uiErr == nil {
+		gameMux.Handle("/", ui)
+	}
 
-	http.HandleFunc("/api/rounds/", func(w http.ResponseWriter, r *http.Request) {
-		if js == nil {
-			http.Error(w, "JetStream not available", http.StatusServiceUnavailable)
-			return
-		}
-		path := r.URL.Path
-		if len(path) <= len("/api/rounds/") {
-			http.Error(w, "Round ID required", http.StatusBadRequest)
-			return
-		}
-		roundID := path[len("/api/rounds/"):]
-		subject := fmt.Sprintf("messages.%s", roundID)
-
-		// Use a more descriptive and potentially durable consumer name if appropriate
-		// For now, keeping it dynamic but with a clear prefix and ensuring it's cleaned up.
-		consumerName := fmt.Sprintf("%s%s_%d", apiConsumerPrefix, roundID, time.Now().UnixNano())
-
-		_, err := js.AddConsumer("MESSAGES", &nats.ConsumerConfig{
-			Name:          consumerName,
-			DeliverPolicy: nats.DeliverAllPolicy,
-			AckPolicy:     nats.AckExplicitPolicy,
-			FilterSubject: subject,
-			MaxDeliver:    apiConsumerMaxDeliver,
-		})
-		if err != nil {
-			serverLogger.Errorf("Error creating consumer %s for subject %s: %v", consumerName, subject, err) // Wrapped error
-			http.Error(w, "Error retrieving messages", http.StatusInternalServerError)
-			return
-		}
-		sub, err := js.PullSubscribe(subject, consumerName) // Using the created consumer name
-		if err != nil {
-			serverLogger.Errorf("Error subscribing with consumer %s to subject %s: %v", consumerName, subject, err) // Wrapped error
-			js.DeleteConsumer("MESSAGES", consumerName)                                                             // Attempt cleanup
-			http.Error(w, "Error retrieving messages", http.StatusInternalServerError)
-			return
-		}
+	gameMux.HandleFunc("/api/protocol", protocolHandler())
+	gameMux.HandleFunc("/api/protocol/client.ts", protocolClientHandler())
+	cache := newRoundCache(roundCacheSize, historyRetention, roundFinalAge(cfg))
+	invalidateOnStreamChanges(nc, cache, serverLogger)
+	if notifier, ok := hub.(roundChangeNotifier); ok {
+		notifier.OnRoundsChanged(cache.deleteRounds)
+	}
+	recent, _ := hub.(recentRoundProvider)
+	odds, _ := hub.(roundOddsProvider)
+	gameMux.HandleFunc("/api/rounds/", roundsHandler(historyBus, cache, newRoundLoads(), recent, odds, serverLogger))
+	gameMux.HandleFunc("/api/winners", winnersHandler(historyBus, serverLogger))
+	gameMux.HandleFunc("/api/export", bulkExportHandler(historyBus, secondsOr(cfg.HTTPWriteTimeoutSeconds, defaultWriteTimeout), serverLogger))
+
+	if provider, ok := hub.(searchProvider); ok {
+		gameMux.HandleFunc("/api/search", searchHandler(provider))
+	}
 
-		// Ensure cleanup happens even if other operations fail
-		defer func() {
-			if unsubErr := sub.Unsubscribe(); unsubErr != nil {
-				serverLogger.Errorf("Error unsubscribing consumer %s: %v", consumerName, unsubErr) // Wrapped error
+	if statsProvider, ok := hub.(roundStatsProvider); ok {
+		gameMux.HandleFunc("/api/stats", statsHandler(statsProvider))
+	} else {
+		serverLogger.Warn("Hub does not provide round statistics, /api/stats disabled")
+	}
+
+	if occupancyProvider, ok := hub.(interface{ Occupancy() hubpkg.Occupancy }); ok {
+		// Load balancers can poll this endpoint and route elsewhere on 503.
+		gameMux.HandleFunc("/api/occupancy", func(w http.ResponseWriter, r *http.Request) {
+			occupancy := occupancyProvider.Occupancy()
+			w.Header().Set("Content-Type", "application/json")
+			if occupancy.Full {
+				w.Header().Set("Retry-After", strconv.Itoa(cfg.RetryAfterSeconds))
+				w.WriteHeader(http.StatusServiceUnavailable)
 			}
-			if delErr := js.DeleteConsumer("MESSAGES", consumerName); delErr != nil {
-				serverLogger.Errorf("Error deleting consumer %s: %v", consumerName, delErr) // Wrapped error
+			json.NewEncoder(w).Encode(occupancy)
+		})
+	}
+
+	gameMux.HandleFunc("/api/users/", usersHandler(hub, serverLogger))
+
+	if provider, ok := hub.(roomProvider); ok {
+		rooms := roomsHandler(provider)
+		gameMux.HandleFunc("/api/rooms", rooms)
+		gameMux.HandleFunc("/api/rooms/", rooms)
+	}
+
+	if provider, ok := hub.(tournamentProvider); ok {
+		gameMux.HandleFunc("/api/tournaments/", tournamentHandler(provider, serverLogger))
+	}
+	if provider, ok := hub.(seriesProvider); ok {
+		gameMux.HandleFunc("/api/series/", seriesHandler(provider, serverLogger))
+	}
+	if provider, ok := hub.(rulesProvider); ok {
+		gameMux.HandleFunc("/api/rules", rulesHandler(provider))
+	}
+
+	if provider, ok := hub.(attachmentStoreProvider); ok && provider.AttachmentStore() != nil {
+		uploads := uploadsHandler(cfg, provider, serverLogger)
+		gameMux.HandleFunc("/api/uploads", uploads)
+		gameMux.HandleFunc("/api/uploads/", uploads)
+	} else {
+		serverLogger.Warn("Attachment store unavailable, /api/uploads disabled")
+	}
+
+	// Admin commands reach every instance through the NATS control plane when connected.
+	var cluster controlPlane
+	if nc != nil {
+		cluster, _ = hub.(controlPlane)
+	}
+
+	if lister, ok := hub.(clientLister); ok {
+		adminMux.HandleFunc("/api/admin/clients", adminClientsHandler(lister, cluster))
+	}
+
+	if controller, ok := hub.(adminController); ok {
+		adminMux.HandleFunc("/api/admin/clients/", adminClientActionHandler(controller, cluster))
+		bans := adminBansHandler(controller, cluster)
+		adminMux.HandleFunc("/api/admin/bans", bans)
+		adminMux.HandleFunc("/api/admin/bans/", bans)
+		adminMux.HandleFunc("/api/admin/rounds/end", adminEndRoundHandler(controller, cluster))
+		tagger, _ := hub.(roundTagger)
+		adminMux.HandleFunc("/api/admin/rounds/", adminRoundsHandler(controller, tagger))
+		adminMux.HandleFunc("/api/admin/config", adminConfigHandler(controller))
+	}
+
+	if announcer, ok := hub.(announcer); ok {
+		adminMux.HandleFunc("/api/admin/announcements", adminAnnouncementsHandler(announcer, cluster))
+	}
+
+	if muter, ok := hub.(muter); ok {
+		mutes := adminMutesHandler(muter, cluster)
+		adminMux.HandleFunc("/api/admin/mutes", mutes)
+		adminMux.HandleFunc("/api/admin/mutes/", mutes)
+	}
+
+	if controller, ok := hub.(chaosController); ok && cfg.ChaosMode {
+		adminMux.HandleFunc("/api/admin/chaos", adminChaosHandler(controller))
+		adminMux.HandleFunc("/api/admin/chaos/nats-disconnect", adminChaosNATSHandler(controller))
+		adminMux.HandleFunc("/api/admin/chaos/kill-clients", adminChaosKillHandler(controller))
+	}
+
+	if js != nil {
+		adminMux.HandleFunc("/api/admin/streams", adminStreamsHandler(cfg, domains))
+	}
+
+	adminMux.HandleFunc("/api/admin/users/", adminUsersHandler(hub, serverLogger))
+	adminMux.HandleFunc("/api/audit", auditHandler(historyBus, serverLogger))
+
+	ssoProvider, err := sso.New(cfg, serverLogger)
+	if err != nil {
+		serverLogger.Errorf("Admin SSO disabled: %v", err)
+	}
+	if ssoProvider != nil {
+		auditor, _ := hub.(auditRecorder)
+		adminMux.HandleFunc(ssoPathPrefix+"login", ssoLoginHandler(ssoProvider, serverLogger))
+		adminMux.HandleFunc(sso.CallbackPath, ssoCallbackHandler(ssoProvider, auditor, serverLogger))
+		adminMux.HandleFunc(ssoPathPrefix+"logout", ssoLogoutHandler(ssoProvider))
+		adminMux.HandleFunc(ssoPathPrefix+"session", ssoSessionHandler(ssoProvider))
+	}
+
+	publishStats, _ := hub.(publishStatsProvider)
+	handshakeStats, _ := hub.(handshakeStatsProvider)
+	deliveryStats, _ := hub.(deliveryStatsProvider)
+	quality, _ := hub.(connectionQualityProvider)
+	archiveStats, _ := hub.(archiveStatsProvider)
+	hubStats, _ := hub.(hubStatsProvider)
+	gameMux.HandleFunc("/health", healthHandler(cfg, nc, js, streamNames, lagMonitor, hubStats, publishStats, handshakeStats, deliveryStats, quality, archiveStats))
+	gameMux.HandleFunc("/readyz", readyHandler(cfg, nc, bus, natsStatus))
+
+	trustedProxies, err := util.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		serverLogger.Errorf("Ignoring trusted proxies: %v", err)
+	}
+	gameHandler := chain(gameMux,
+		withRecovery(serverLogger),
+		withLogging(serverLogger),
+		withCORS(cfg.CORSAllowedOrigins),
+		withRateLimit(cfg.RateLimitPerSecond, cfg.RateLimitBurst, trustedProxies),
+		withRequestTimeout(secondsOr(cfg.HTTPRequestTimeoutSeconds, defaultRequestTimeout)),
+	)
+	adminHandler := chain(adminMux,
+		withRecovery(serverLogger),
+		withLogging(serverLogger),
+		requireAdmin(cfg, ssoProvider),
+		withRequestTimeout(secondsOr(cfg.HTTPRequestTimeoutSeconds, defaultRequestTimeout)),
+	)
+
+	if cfg.AdminListenAddr == "" {
+		// Without a dedicated admin port the admin routes share the game listener,
+		// still behind their own chain so game middleware like CORS does not apply.
+		shared := http.NewServeMux()
+		shared.Handle("/", gameHandler)
+		shared.Handle("/api/admin/", adminHandler)
+		shared.Handle("/api/audit", adminHandler)
+		gameHandler = shared
+	}
+
+	gameServer := newHTTPServer(cfg, cfg.ListenAddr, gameHandler)
+	servers := []*http.Server{gameServer}
+	if cfg.AdminListenAddr != "" {
+		adminServer := newHTTPServer(cfg, cfg.AdminListenAddr, adminHandler)
+		servers = append(servers, adminServer)
+		go func() {
+			serverLogger.Infof("Admin server started at %s", cfg.AdminListenAddr)
+			if err := serve(cfg, adminServer, adminListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverLogger.Fatalf("Admin ListenAndServe: %v", err)
 			}
 		}()
+	}
+	stopper, _ := hub.(hubStopper)
+	go stopOnSignal(stopper, servers, serverLogger)
 
-		msgs, err := sub.Fetch(100, nats.MaxWait(apiConsumerFetchMaxWait)) // Use constant
-		if err != nil && err != nats.ErrTimeout {
-			serverLogger.Errorf("Error fetching messages with consumer %s: %v", consumerName, err) // Wrapped error
-			http.Error(w, "Error retrieving messages", http.StatusInternalServerError)
-			return
+	serverLogger.Infof("Server started at %s (TLS: %t, HTTP/2: %t)", cfg.ListenAddr, cfg.TLSCertFile != "", cfg.TLSCertFile != "" && cfg.HTTP2)
+	if err := serve(cfg, gameServer, gameListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		serverLogger.Fatalf("ListenAndServe: %v", err)
+	}
+	// The server was shut down by stopOnSignal, which exits once the shutdown is done.
+	select {}
+}
+
+// backgroundRunner is implemented by hubs that run background work for as long as they
+// run.
+type backgroundRunner interface {
+	RunInBackground(fn func(ctx context.Context))
+}
+
+var _ backgroundRunner = (*hubpkg.Hub)(nil)
+
+// hubStopper is implemented by hubs that can drain and shut down.
+type hubStopper interface {
+	Stop(ctx context.Context) error
+}
+
+// stopOnSignal drains the hub on SIGINT or SIGTERM, so the active round's winner is
+// announced and clients are disconnected cleanly, then shuts the HTTP servers down,
+// letting requests in flight finish, and exits: with status 1 when either did not stop
+// cleanly within shutdownTimeout. hub may be nil.
+func stopOnSignal(hub hubStopper, servers []*http.Server, serverLogger *logger.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	serverLogger.Infof("Received %v, shutting down", sig)
+	os.Exit(shutdown(hub, servers, serverLogger))
+}
+
+// shutdown stops the hub and then the servers and returns the exit status.
+func shutdown(hub hubStopper, servers []*http.Server, serverLogger *logger.Logger) int {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	status := 0
+	if hub != nil {
+		if err := hub.Stop(ctx); err != nil {
+			serverLogger.Errorf("Hub did not stop cleanly: %v", err)
+			status = 1
 		}
-		var messages []map[string]interface{}
-		for _, msg := range msgs {
-			var message map[string]interface{}
-			if err := json.Unmarshal(msg.Data, &message); err != nil {
-				serverLogger.Errorf("Error unmarshaling message: %v", err) // Wrapped error
-				continue
-			}
-			messages = append(messages, message)
-			msg.Ack() // Ack individual messages as they are processed
+	}
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			serverLogger.Errorf("Server %s did not shut down cleanly: %v", server.Addr, err)
+			status = 1
 		}
+	}
+	return status
+}
 
-		var winner map[string]interface{}
-		// For fetching winner, using an ephemeral pull subscriber is generally fine if we only need the latest.
-		// If multiple API calls could happen concurrently for the same round before a winner is published,
-		// and each needs to see the winner, this approach is okay.
-		// If a durable view of the winner is needed across multiple API calls even if they are spaced out,
-		// a named consumer for winners might be considered, but for now, this is simpler.
-		winnerSubject := fmt.Sprintf("winners.%s", roundID)
-		winnerConsumerName := fmt.Sprintf("API_WINNER_CONSUMER_%s_%d", roundID, time.Now().UnixNano())
-
-		// Create a consumer for the winner message
-		_, err = js.AddConsumer("WINNERS", &nats.ConsumerConfig{
-			Name:          winnerConsumerName,
-			DeliverPolicy: nats.DeliverAllPolicy, // Or DeliverLastPolicy if only the most recent winner matters
-			AckPolicy:     nats.AckExplicitPolicy,
-			FilterSubject: winnerSubject,
-			MaxDeliver:    1, // Only attempt to deliver once to this ephemeral consumer
-		})
+// hubStatsProvider is implemented by hubs that report their uptime, clients and round progress.
+type hubStatsProvider interface {
+	Stats() hubpkg.HubStats
+}
 
-		if err != nil {
-			serverLogger.Warnf("Error creating winner consumer %s for subject %s: %v. Winner might not be retrieved.", winnerConsumerName, winnerSubject, err)
-		} else {
-			defer js.DeleteConsumer("WINNERS", winnerConsumerName) // Cleanup winner consumer
-
-			winnerSub, err := js.PullSubscribe(winnerSubject, winnerConsumerName)
-			if err != nil {
-				serverLogger.Warnf("Error subscribing for winner with consumer %s: %v. Winner might not be retrieved.", winnerConsumerName, err)
-			} else {
-				defer winnerSub.Unsubscribe()
-				winnerMsgs, fetchErr := winnerSub.Fetch(1, nats.MaxWait(winnerAPIFetchMaxWait)) // Use constant
-				if fetchErr != nil && fetchErr != nats.ErrTimeout {
-					serverLogger.Warnf("Error fetching winner message with consumer %s: %v", winnerConsumerName, fetchErr)
-				} else if len(winnerMsgs) > 0 {
-					var winnerMsg map[string]interface{}
-					if unmarshalErr := json.Unmarshal(winnerMsgs[0].Data, &winnerMsg); unmarshalErr == nil {
-						winner = winnerMsg
-						winnerMsgs[0].Ack() // Ack the winner message
-					} else {
-						serverLogger.Errorf("Error unmarshaling winner message: %v", unmarshalErr)
-					}
-				}
-			}
-		}
+// publishStatsProvider is implemented by hubs that publish events through a background queue.
+type publishStatsProvider interface {
+	PublishQueueStats() (hubpkg.PublishQueueStats, bool)
+}
 
-		response := map[string]interface{}{
-			"round_id":  roundID,
-			"messages":  messages,
-			"winner":    winner,
-			"count":     len(messages),
-			"timestamp": time.Now(),
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	})
+// handshakeStatsProvider is implemented by hubs that count rejected WebSocket upgrades.
+type handshakeStatsProvider interface {
+	HandshakeRejections() map[string]uint64
+}
+
+// deliveryStatsProvider is implemented by hubs that track client acks of broadcasts.
+type deliveryStatsProvider interface {
+	DeliveryStats() hubpkg.DeliveryStats
+}
+
+// connectionQualityProvider is implemented by hubs that measure WebSocket ping round trips.
+type connectionQualityProvider interface {
+	ConnectionQuality() hubpkg.ConnectionQualityStats
+}
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+// archiveStatsProvider is implemented by hubs that archive finished rounds to an object store.
+type archiveStatsProvider interface {
+	ArchiveStats() (hubpkg.ArchiveStats, bool)
+}
+
+// healthHandler reports the NATS connection, JetStream stream state, hub statistics, publish
+// queue metrics, rejected WebSocket handshakes, broadcast delivery rates, connection quality
+// and round archival.
+func healthHandler(cfg config.Config, nc *nats.Conn, js nats.JetStreamContext, streamNames []string, lagMonitor *streams.Monitor, hubStats hubStatsProvider, publishStats publishStatsProvider, handshakeStats handshakeStatsProvider, deliveryStats deliveryStatsProvider, quality connectionQualityProvider, archiveStats archiveStatsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		natsStatus := "disconnected"
 		if nc != nil && nc.Status() == nats.CONNECTED {
 			natsStatus = "connected"
 		}
 		health := map[string]interface{}{
-			"status":  "ok",
-			"nats":    natsStatus,
-			"version": "1.0.0",
+			"status":    "ok",
+			"nats":      natsStatus,
+			"version":   "1.0.0",
+			"event_bus": cfg.EventBus,
 		}
 		if js != nil {
 			jsInfo := make(map[string]interface{})
-			streams := []string{"ROUNDS", "MESSAGES", "WINNERS"}
 			streamInfo := make(map[string]interface{})
-			for _, streamName := range streams {
+			for _, stream := range streamNames {
+				streamName := cfg.ResourceName(stream)
 				info, err := js.StreamInfo(streamName)
 				if err == nil {
-					streamInfo[streamName] = map[string]interface{}{
+					streamInfo[stream] = map[string]interface{}{
 						"messages":  info.State.Msgs,
 						"bytes":     info.State.Bytes,
 						"subjects":  info.Config.Subjects,
 						"retention": fmt.Sprintf("%v", info.Config.MaxAge),
 					}
 				} else {
-					streamInfo[streamName] = map[string]interface{}{
+					streamInfo[stream] = map[string]interface{}{
 						"error": err.Error(),
 					}
 				}
 			}
 			jsInfo["streams"] = streamInfo
+			if lagMonitor != nil {
+				jsInfo["lag"] = lagMonitor.Report()
+			}
 			health["jetstream"] = jsInfo
 		}
+		if hubStats != nil {
+			stats := hubStats.Stats()
+			health["uptime"] = time.Duration(stats.UptimeSeconds * float64(time.Second)).Round(time.Second).String()
+			health["hub"] = stats
+		}
+		if publishStats != nil {
+			if stats, ok := publishStats.PublishQueueStats(); ok {
+				health["publish_queue"] = stats
+			}
+		}
+		if handshakeStats != nil {
+			health["handshake_rejections"] = handshakeStats.HandshakeRejections()
+		}
+		if deliveryStats != nil {
+			health["delivery"] = deliveryStats.DeliveryStats()
+		}
+		if quality != nil {
+			health["connection_quality"] = quality.ConnectionQuality()
+		}
+		if archiveStats != nil {
+			if stats, ok := archiveStats.ArchiveStats(); ok {
+				health["archive"] = stats
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(health)
-	})
+	}
+}
 
-	addr := ":8080"
-	serverLogger.Infof("Server started at %s", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		serverLogger.Fatalf("ListenAndServe: %v", err)
+// readyHandler reflects whether the persistence backend is usable, including auth failures.
+func readyHandler(cfg config.Config, nc *nats.Conn, bus eventbus.EventBus, natsStatus *connectionStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ready := bus != nil
+		readiness := map[string]interface{}{
+			"event_bus": cfg.EventBus,
+		}
+		if cfg.EventBus != config.EventBusRedis {
+			connected := nc != nil && nc.Status() == nats.CONNECTED
+			ready = ready && connected
+			natsInfo := map[string]interface{}{"connected": connected}
+			if err := natsStatus.err(); err != nil {
+				natsInfo["last_error"] = err.Error()
+				natsInfo["auth_error"] = isNATSAuthError(err)
+			}
+			readiness["nats"] = natsInfo
+		}
+		readiness["ready"] = ready
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(readiness)
 	}
 }
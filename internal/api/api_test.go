@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/erilali/internal/logger"
+)
+
+// stopFunc is a hubStopper answering with its own result.
+type stopFunc func(ctx context.Context) error
+
+func (f stopFunc) Stop(ctx context.Context) error { return f(ctx) }
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		hub    hubStopper
+		status int
+	}{
+		{"clean", stopFunc(func(context.Context) error { return nil }), 0},
+		{"hub failed to stop", stopFunc(func(context.Context) error { return errors.New("timed out") }), 1},
+		{"no hub", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			server := &http.Server{Handler: http.NotFoundHandler()}
+			served := make(chan error, 1)
+			go func() { served <- server.Serve(listener) }()
+
+			if got := shutdown(tt.hub, []*http.Server{server}, logger.NewLogger("test")); got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+			if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("Serve returned %v, want the server closed", err)
+			}
+		})
+	}
+}
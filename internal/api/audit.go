@@ -0,0 +1,146 @@
+// internal/api/audit.go
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+)
+
+const (
+	auditHistoryLimit = 1000 // most recent entries read per event type
+	auditDefaultLimit = 100
+	// historyTailWindow is how far back the first read for the most recent events
+	// reaches, and historyTailMaxWindow the window beyond which the whole stream is read.
+	historyTailWindow    = time.Hour
+	historyTailMaxWindow = 100 * 365 * 24 * time.Hour
+	historyTailReads     = 16 // reads spent narrowing the window before settling for what was read
+)
+
+// auditHandler serves GET /api/audit?username=&event=&limit= from the AUDIT stream,
+// returning the most recent matching entries oldest first.
+func auditHandler(bus eventbus.EventBus, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bus == nil {
+			http.Error(w, "Event bus not available", http.StatusServiceUnavailable)
+			return
+		}
+		query := r.URL.Query()
+		username := query.Get("username")
+		eventTypes := hub.AuditEventTypes
+		if event := query.Get("event"); event != "" {
+			eventTypes = []string{event}
+		}
+		limit := auditDefaultLimit
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		entries := []message.LogEntry{}
+		for _, eventType := range eventTypes {
+			events, err := historyTail(r.Context(), bus, "audit."+eventType, time.Time{}, time.Time{}, auditHistoryLimit, winnerAPIFetchMaxWait)
+			if err != nil && !errors.Is(err, eventbus.ErrHistoryIncomplete) {
+				serverLogger.Errorf("Error reading audit events %s: %v", eventType, err)
+				writeHistoryError(w, err, "Error retrieving audit events")
+				return
+			}
+			for _, event := range events {
+				var entry message.LogEntry
+				if err := json.Unmarshal(event.Data, &entry); err != nil {
+					serverLogger.Errorf("Error unmarshaling audit entry: %v", err)
+					continue
+				}
+				if username != "" && entry.Username != username {
+					continue
+				}
+				entries = append(entries, entry)
+			}
+		}
+
+		// RFC3339Nano timestamps in UTC sort lexically
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp < entries[j].Timestamp })
+		if len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		})
+	}
+}
+
+// historyTail returns the last limit events on subject published between from and until,
+// oldest first; zero bounds are open. The bus reads forwards from a start time and stops
+// at its limit, so the start is searched for: it moves back from until in growing steps
+// while a read holds fewer than limit events up to until, and forward again when a read
+// of twice limit events stops before until. The newest limit events of the last read are
+// returned.
+func historyTail(ctx context.Context, bus eventbus.EventBus, subject string, from, until time.Time, limit int, maxWait time.Duration) ([]eventbus.Event, error) {
+	end := until
+	if end.IsZero() {
+		end = time.Now()
+	}
+	reach := historyTailMaxWindow // windows this long read from from
+	if !from.IsZero() {
+		reach = max(min(reach, end.Sub(from)), 0)
+	}
+	var short, full time.Duration // windows known to hold fewer than limit and more than twice limit events
+	window := min(historyTailWindow, reach)
+	var events []eventbus.Event
+	for read := 0; read < historyTailReads; read++ {
+		since := end.Add(-window)
+		if window == reach {
+			since = from
+		}
+		got, err := bus.HistorySince(ctx, subject, since, 2*limit, maxWait)
+		events = got
+		reachedEnd := len(got) < 2*limit
+		if i := slices.IndexFunc(got, func(e eventbus.Event) bool { return e.Timestamp.After(end) }); i >= 0 {
+			events, reachedEnd = got[:i], true
+		}
+		if err != nil {
+			return tail(events, limit), err
+		}
+		switch {
+		case !reachedEnd:
+			full = window
+		case len(events) < limit && window == reach:
+			return events, nil
+		case len(events) < limit:
+			short = window
+		default:
+			return tail(events, limit), nil
+		}
+		if full == 0 {
+			window = min(window*8, reach)
+		} else {
+			window = short + (full-short)/2
+		}
+	}
+	return tail(events, limit), nil
+}
+
+// tail returns the last n events.
+func tail(events []eventbus.Event, n int) []eventbus.Event {
+	if len(events) > n {
+		return events[len(events)-n:]
+	}
+	return events
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+)
+
+// memoryBus serves History reads from a fixed list of events, oldest first, and counts
+// the reads.
+type memoryBus struct {
+	events []eventbus.Event
+	reads  int
+}
+
+func (b *memoryBus) Publish(string, []byte) error               { return nil }
+func (b *memoryBus) PublishWithID(string, string, []byte) error { return nil }
+func (b *memoryBus) Subscribe(string, eventbus.Handler) (eventbus.Subscription, error) {
+	return nil, nil
+}
+func (b *memoryBus) Close() error { return nil }
+
+func (b *memoryBus) History(subject string, limit int, maxWait time.Duration) ([]eventbus.Event, error) {
+	return b.HistorySince(context.Background(), subject, time.Time{}, limit, maxWait)
+}
+
+func (b *memoryBus) HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]eventbus.Event, error) {
+	return b.HistorySince(ctx, subject, time.Time{}, limit, maxWait)
+}
+
+func (b *memoryBus) HistorySince(_ context.Context, _ string, since time.Time, limit int, _ time.Duration) ([]eventbus.Event, error) {
+	b.reads++
+	var events []eventbus.Event
+	for _, event := range b.events {
+		if !event.Timestamp.Before(since) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// eventsEvery returns count events spaced by step and ending ago before now.
+func eventsEvery(count int, step, ago time.Duration) []eventbus.Event {
+	end := time.Now().Add(-ago)
+	events := make([]eventbus.Event, count)
+	for i := range events {
+		events[i] = eventbus.Event{Subject: "audit.test", Timestamp: end.Add(-time.Duration(count-1-i) * step)}
+	}
+	return events
+}
+
+// between returns the events published between from and until.
+func between(events []eventbus.Event, from, until time.Time) []eventbus.Event {
+	var in []eventbus.Event
+	for _, event := range events {
+		if !event.Timestamp.Before(from) && (until.IsZero() || !event.Timestamp.After(until)) {
+			in = append(in, event)
+		}
+	}
+	return in
+}
+
+func TestHistoryTail(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		events      []eventbus.Event
+		from, until time.Time
+		limit       int
+	}{
+		{"fewer events than the limit", eventsEvery(5, time.Hour, 0), time.Time{}, time.Time{}, 10},
+		{"dense recent events", eventsEvery(5000, time.Second, 0), time.Time{}, time.Time{}, 100},
+		{"sparse events", eventsEvery(300, 24*time.Hour, 0), time.Time{}, time.Time{}, 100},
+		{"monthly events", eventsEvery(50, 30*24*time.Hour, 0), time.Time{}, time.Time{}, 10},
+		{"burst before a quiet day", append(eventsEvery(5000, time.Second, 24*time.Hour), eventsEvery(20, time.Minute, 0)...), time.Time{}, time.Time{}, 100},
+		{"from", eventsEvery(300, 24*time.Hour, 0), now.Add(-50 * 24 * time.Hour), time.Time{}, 100},
+		{"until", eventsEvery(5000, time.Second, 0), time.Time{}, now.Add(-time.Hour), 100},
+		{"from and until", eventsEvery(5000, time.Minute, 0), now.Add(-48 * time.Hour), now.Add(-47 * time.Hour), 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &memoryBus{events: tt.events}
+			got, err := historyTail(context.Background(), bus, "audit.test", tt.from, tt.until, tt.limit, time.Second)
+			if err != nil {
+				t.Fatalf("historyTail: %v", err)
+			}
+			want := tail(between(tt.events, tt.from, tt.until), tt.limit)
+			if len(got) != len(want) {
+				t.Fatalf("read %d events, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !got[i].Timestamp.Equal(want[i].Timestamp) {
+					t.Fatalf("event %d at %s, want the newest events ending at %s", i, got[i].Timestamp, want[len(want)-1].Timestamp)
+				}
+			}
+			if bus.reads > historyTailReads {
+				t.Errorf("%d reads, want at most %d", bus.reads, historyTailReads)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// internal/api/cache.go
+package api
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/ids"
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	roundCacheSize = 256
+	// roundFinalGrace covers publish latency and clock skew between instances on top of
+	// the configured round timing.
+	roundFinalGrace = 30 * time.Second
+)
+
+// roundRecord is the immutable part of a finished round's history response.
+type roundRecord struct {
+	messages  []map[string]interface{}
+	winner    map[string]interface{}
+	reactions map[string]interface{}
+	complete  bool // false when the round had more than roundEventLimit events or a read stopped at its deadline
+	cachedAt  time.Time
+}
+
+// roundCache is an LRU cache of finished rounds so repeat queries skip the event bus.
+// Entries expire with the bus retention so the cache never outlives the stored data.
+type roundCache struct {
+	mu       sync.Mutex
+	size     int
+	ttl      time.Duration
+	finalAge time.Duration // see roundFinalAge
+	order    *list.List    // most recently used first, values are cache keys
+	entries  map[string]*list.Element
+	records  map[string]roundRecord
+}
+
+func newRoundCache(size int, ttl, finalAge time.Duration) *roundCache {
+	return &roundCache{
+		size:     size,
+		ttl:      ttl,
+		finalAge: finalAge,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		records:  make(map[string]roundRecord),
+	}
+}
+
+// get returns a cached round and marks it as recently used.
+func (c *roundCache) get(roundID string) (roundRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[roundID]
+	if !ok {
+		return roundRecord{}, false
+	}
+	record := c.records[roundID]
+	if time.Since(record.cachedAt) > c.ttl {
+		c.remove(elem)
+		return roundRecord{}, false
+	}
+	c.order.MoveToFront(elem)
+	return record, true
+}
+
+// put stores a round, evicting the least recently used one when full.
+func (c *roundCache) put(roundID string, record roundRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record.cachedAt = time.Now()
+	if elem, ok := c.entries[roundID]; ok {
+		c.records[roundID] = record
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[roundID] = c.order.PushFront(roundID)
+	c.records[roundID] = record
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// delete drops a single round.
+func (c *roundCache) delete(roundID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[roundID]; ok {
+		c.remove(elem)
+	}
+}
+
+// deleteRounds drops the given rounds. It is registered with hubs that report rewritten
+// rounds, on this instance or fanned out from another one.
+func (c *roundCache) deleteRounds(roundIDs []int64) {
+	for _, roundID := range roundIDs {
+		c.delete(strconv.FormatInt(roundID, 10))
+	}
+}
+
+// purge drops every entry.
+func (c *roundCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.records = make(map[string]roundRecord)
+}
+
+func (c *roundCache) remove(elem *list.Element) {
+	roundID := c.order.Remove(elem).(string)
+	delete(c.entries, roundID)
+	delete(c.records, roundID)
+}
+
+// final reports whether a round's history can no longer change and may be cached.
+// Later corrections, such as appeals and redactions, drop the round through deleteRounds.
+func (c *roundCache) final(roundID string) bool {
+	started, err := strconv.ParseInt(roundID, 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Since(ids.RoundTime(started)) > c.finalAge
+}
+
+// roundFinalAge returns how long after its start a round's history no longer changes.
+// Messages and the winner are written when the round ends, the reaction tally when the
+// next round's winner is announced, so this covers two rounds of the longest configured
+// length, grace period and pause. Every pacing profile counts, since the profile can be
+// switched at runtime.
+func roundFinalAge(cfg config.Config) time.Duration {
+	length, pause := cfg.RoundDurationSeconds, cfg.RoundPauseSeconds
+	if cfg.AdaptiveRounds {
+		length = max(length, cfg.MaxRoundDurationSeconds)
+	}
+	for _, profile := range cfg.PacingProfiles {
+		length = max(length, profile.RoundDurationSeconds)
+		pause = max(pause, profile.PauseSeconds)
+	}
+	length += max(cfg.ParticipantsGraceSeconds, 0)
+	return 2*time.Duration(length+max(pause, 0))*time.Second + roundFinalGrace
+}
+
+// roundChangeNotifier is implemented by hubs that report finished rounds whose stored
+// history was rewritten, such as by a user data erasure.
+type roundChangeNotifier interface {
+	OnRoundsChanged(fn func(roundIDs []int64))
+}
+
+// invalidateOnStreamChanges purges the cache whenever a JetStream stream is deleted or purged,
+// since cached rounds may then no longer exist.
+func invalidateOnStreamChanges(nc *nats.Conn, cache *roundCache, serverLogger *logger.Logger) {
+	if nc == nil {
+		return
+	}
+	for _, subject := range []string{
+		"$JS.EVENT.ADVISORY.STREAM.DELETED.*",
+		"$JS.EVENT.ADVISORY.STREAM.PURGED.*",
+	} {
+		if _, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+			serverLogger.Infof("Stream change advisory on %s, clearing round cache", msg.Subject)
+			cache.purge()
+		}); err != nil {
+			serverLogger.Warnf("Failed to subscribe to %s, round cache relies on expiry only: %v", subject, err)
+		}
+	}
+}
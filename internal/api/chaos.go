@@ -0,0 +1,93 @@
+// internal/api/chaos.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/erilali/internal/hub"
+)
+
+// chaosController is implemented by hubs that can inject failures for resilience drills.
+type chaosController interface {
+	ChaosSettings() hub.ChaosSettings
+	ApplyChaosSettings(settings hub.ChaosSettings, actor string) error
+	ForceNATSReconnect(actor string) error
+	KillRandomClients(n int, actor string) []string
+}
+
+// adminChaosHandler serves GET and PATCH /api/admin/chaos, which read and change the
+// injected failures. A PATCH body only needs the settings being changed.
+func adminChaosHandler(controller chaosController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPatch:
+			settings := controller.ChaosSettings()
+			decoder := json.NewDecoder(r.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&settings); err != nil {
+				http.Error(w, "Invalid chaos settings: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := controller.ApplyChaosSettings(settings, adminActor(r)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(controller.ChaosSettings())
+	}
+}
+
+// adminChaosNATSHandler serves POST /api/admin/chaos/nats-disconnect.
+func adminChaosNATSHandler(controller chaosController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		err := controller.ForceNATSReconnect(adminActor(r))
+		if errors.Is(err, hub.ErrNoNATSConnection) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"reconnecting": true})
+	}
+}
+
+// adminChaosKillHandler serves POST /api/admin/chaos/kill-clients?count=N, which drops the
+// connections of N random clients on this instance, one by default.
+func adminChaosKillHandler(controller chaosController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		count := 1
+		if v := r.URL.Query().Get("count"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid count parameter", http.StatusBadRequest)
+				return
+			}
+			count = n
+		}
+		killed := controller.KillRandomClients(count, adminActor(r))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"killed":    len(killed),
+			"usernames": killed,
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// internal/api/coalesce.go
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+// roundCall is a round load in progress that concurrent requests wait on.
+type roundCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // requests still waiting; the load is canceled when the last one leaves
+	record  roundRecord
+	err     error
+}
+
+// roundLoads coalesces concurrent loads of the same round into a single event bus fetch.
+type roundLoads struct {
+	mu    sync.Mutex
+	calls map[string]*roundCall
+}
+
+func newRoundLoads() *roundLoads {
+	return &roundLoads{calls: make(map[string]*roundCall)}
+}
+
+// do runs load for roundID unless a load for it is already running, in which case it
+// waits for that load and returns its result. The record is shared and must not be modified.
+// A request whose ctx is done stops waiting with its error; once no request waits any
+// more the load itself is canceled, so abandoned reads do not keep fetching.
+func (l *roundLoads) do(ctx context.Context, roundID string, load func(context.Context) (roundRecord, error)) (roundRecord, error) {
+	l.mu.Lock()
+	call, ok := l.calls[roundID]
+	if !ok {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &roundCall{done: make(chan struct{}), cancel: cancel}
+		l.calls[roundID] = call
+		go func() {
+			record, err := load(loadCtx)
+			l.mu.Lock()
+			call.record, call.err = record, err
+			if l.calls[roundID] == call {
+				delete(l.calls, roundID)
+			}
+			l.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.record, call.err
+	case <-ctx.Done():
+		l.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Later requests for the round start a fresh load rather than join a canceled one.
+			if l.calls[roundID] == call {
+				delete(l.calls, roundID)
+			}
+			call.cancel()
+		}
+		l.mu.Unlock()
+		return roundRecord{}, ctx.Err()
+	}
+}
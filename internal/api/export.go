@@ -0,0 +1,285 @@
+// internal/api/export.go
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/ids"
+	"github.com/erilali/internal/logger"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+
+	exportRoundLimit   = 10000 // rounds of one bulk export
+	exportFetchMaxWait = 5 * time.Second
+)
+
+var exportCSVHeader = []string{"round_id", "message_id", "username", "content", "timestamp", "winner"}
+
+// errExportRangeTooLarge is returned for bulk exports spanning more than exportRoundLimit rounds.
+var errExportRangeTooLarge = fmt.Errorf("more than %d rounds in range, narrow since and until", exportRoundLimit)
+
+// roundExporter writes round records in one of the export formats.
+type roundExporter interface {
+	begin() error
+	writeRound(roundID string, messages []map[string]interface{}, winner map[string]interface{}) error
+	// fail records that the export stopped at roundID, and reports false when the format
+	// has no room for such a record.
+	fail(roundID string, err error) bool
+	flush() error
+}
+
+// newRoundExporter validates the format and sets the download headers.
+func newRoundExporter(w http.ResponseWriter, format, filename string) (roundExporter, bool) {
+	switch format {
+	case "", exportFormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		return &csvExporter{w: csv.NewWriter(w)}, true
+	case exportFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ndjson"`, filename))
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, true
+	default:
+		return nil, false
+	}
+}
+
+// roundExportHandler serves GET /api/rounds/{id}/export?format=csv|ndjson.
+func roundExportHandler(bus eventbus.EventBus, roundID string, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, err := loadRound(r.Context(), bus, roundID, serverLogger)
+		if err != nil {
+			writeHistoryError(w, err, "Error retrieving messages")
+			return
+		}
+		exporter, ok := newRoundExporter(w, r.URL.Query().Get("format"), "round-"+roundID)
+		if !ok {
+			http.Error(w, "Unsupported format, use csv or ndjson", http.StatusBadRequest)
+			return
+		}
+		if err := exporter.begin(); err != nil {
+			serverLogger.Errorf("Error exporting round %s: %v", roundID, err)
+			return
+		}
+		if err := exporter.writeRound(roundID, record.messages, record.winner); err != nil {
+			serverLogger.Errorf("Error exporting round %s: %v", roundID, err)
+			return
+		}
+		exporter.flush()
+	}
+}
+
+// exportRoundIDs returns the rounds that started within [since, until] and whose end is
+// stored on rounds.ended.*, oldest first. These are the rounds whose messages the event
+// bus still holds. A zero since or until leaves that side of the range open.
+func exportRoundIDs(ctx context.Context, bus eventbus.EventBus, since, until time.Time) ([]int64, error) {
+	events, err := bus.HistorySince(ctx, "rounds.ended.*", since, exportRoundLimit+1, exportFetchMaxWait)
+	if err != nil {
+		return nil, err
+	}
+	var roundIDs []int64
+	seen := make(map[int64]bool, len(events))
+	for _, event := range events {
+		roundID, err := strconv.ParseInt(strings.TrimPrefix(event.Subject, "rounds.ended."), 10, 64)
+		if err != nil || seen[roundID] {
+			continue
+		}
+		started := ids.RoundTime(roundID)
+		if (!since.IsZero() && started.Before(since)) || (!until.IsZero() && started.After(until)) {
+			continue
+		}
+		seen[roundID] = true
+		roundIDs = append(roundIDs, roundID)
+	}
+	if len(events) > exportRoundLimit {
+		return nil, errExportRangeTooLarge
+	}
+	return roundIDs, nil
+}
+
+// bulkExportHandler serves GET /api/export?since=&until=&format= for every finished round
+// in the range still held by the event bus, streaming one round at a time. A round that
+// cannot be read ends the export: NDJSON with an "error" record, CSV by aborting the
+// response, so the download never looks complete. The server's write timeout applies to
+// each round rather than the whole download: the write deadline moves before every round
+// is read, unless writeTimeout is negative (disabled).
+func bulkExportHandler(bus eventbus.EventBus, writeTimeout time.Duration, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bus == nil {
+			http.Error(w, "Event bus not available", http.StatusServiceUnavailable)
+			return
+		}
+		since, err := parseTimeParam(r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		until, err := parseTimeParam(r.URL.Query().Get("until"))
+		if err != nil {
+			http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != exportFormatCSV && format != exportFormatNDJSON {
+			http.Error(w, "Unsupported format, use csv or ndjson", http.StatusBadRequest)
+			return
+		}
+		roundIDs, err := exportRoundIDs(r.Context(), bus, since, until)
+		if errors.Is(err, errExportRangeTooLarge) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeHistoryError(w, err, "Error listing rounds")
+			return
+		}
+
+		exporter, _ := newRoundExporter(w, format, "rounds-export")
+		flusher, _ := w.(http.Flusher)
+		flush := func() {
+			exporter.flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		controller := http.NewResponseController(w)
+		extendDeadline := func() {
+			if writeTimeout > 0 {
+				controller.SetWriteDeadline(time.Now().Add(writeTimeout))
+			}
+		}
+		if err := exporter.begin(); err != nil {
+			return
+		}
+		flush()
+		for _, id := range roundIDs {
+			extendDeadline()
+			roundID := strconv.FormatInt(id, 10)
+			record, err := loadRound(r.Context(), bus, roundID, serverLogger)
+			if err != nil {
+				if r.Context().Err() != nil {
+					return // the client went away
+				}
+				serverLogger.Errorf("Export stopped at round %s: %v", roundID, err)
+				if !exporter.fail(roundID, err) {
+					panic(http.ErrAbortHandler)
+				}
+				flush()
+				return
+			}
+			if err := exporter.writeRound(roundID, record.messages, record.winner); err != nil {
+				serverLogger.Errorf("Error exporting round %s: %v", roundID, err)
+				return
+			}
+			flush()
+		}
+	}
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+// begin writes the header row, so even an export without rounds has one.
+func (e *csvExporter) begin() error {
+	return e.w.Write(exportCSVHeader)
+}
+
+func (e *csvExporter) writeRound(roundID string, messages []map[string]interface{}, winner map[string]interface{}) error {
+	winnerID := ""
+	if winner != nil {
+		winnerID = fmt.Sprint(winner["message_id"])
+	}
+	for _, msg := range messages {
+		id := fmt.Sprint(msg["id"])
+		record := []string{
+			roundID,
+			id,
+			fmt.Sprint(msg["username"]),
+			fmt.Sprint(msg["content"]),
+			fmt.Sprint(msg["timestamp"]),
+			strconv.FormatBool(winnerID != "" && id == winnerID),
+		}
+		for i, cell := range record {
+			record[i] = csvCell(cell)
+		}
+		if err := e.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvCell prefixes a cell starting with a character spreadsheets read as the start of
+// a formula with a single quote, so an opened export never evaluates a submission.
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// fail reports false: a CSV row cannot be told apart from data.
+func (e *csvExporter) fail(roundID string, err error) bool {
+	return false
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) begin() error {
+	return nil
+}
+
+// writeRound emits one "message" line per submission followed by a "winner" line if known.
+func (e *ndjsonExporter) writeRound(roundID string, messages []map[string]interface{}, winner map[string]interface{}) error {
+	for _, msg := range messages {
+		record := map[string]interface{}{"type": "message", "round_id": roundID}
+		for k, v := range msg {
+			if _, reserved := record[k]; !reserved {
+				record[k] = v
+			}
+		}
+		if err := e.enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	if winner != nil {
+		record := map[string]interface{}{"type": "winner", "round_id": roundID}
+		for k, v := range winner {
+			if _, reserved := record[k]; !reserved {
+				record[k] = v
+			}
+		}
+		return e.enc.Encode(record)
+	}
+	return nil
+}
+
+// fail emits an "error" line naming the round the export stopped at.
+func (e *ndjsonExporter) fail(roundID string, err error) bool {
+	return e.enc.Encode(map[string]interface{}{"type": "error", "round_id": roundID, "error": err.Error()}) == nil
+}
+
+func (e *ndjsonExporter) flush() error {
+	return nil
+}
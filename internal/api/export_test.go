@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/logger"
+)
+
+// slowRoundsBus lists the given number of ended rounds and takes delay for every read
+// of their messages.
+type slowRoundsBus struct {
+	memoryBus
+	rounds int
+	delay  time.Duration
+}
+
+func (b *slowRoundsBus) HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]eventbus.Event, error) {
+	return b.HistorySince(ctx, subject, time.Time{}, limit, maxWait)
+}
+
+func (b *slowRoundsBus) HistorySince(_ context.Context, subject string, _ time.Time, _ int, _ time.Duration) ([]eventbus.Event, error) {
+	if subject != "rounds.ended.*" {
+		time.Sleep(b.delay)
+		return nil, nil
+	}
+	events := make([]eventbus.Event, b.rounds)
+	for i := range events {
+		events[i] = eventbus.Event{Subject: "rounds.ended." + strconv.Itoa(i+1), Timestamp: time.Now()}
+	}
+	return events, nil
+}
+
+func TestBulkExportOutlastsWriteTimeout(t *testing.T) {
+	const writeTimeout = 200 * time.Millisecond
+	bus := &slowRoundsBus{rounds: 8, delay: 20 * time.Millisecond}
+	serverLogger := logger.NewLogger("test")
+	server := httptest.NewUnstartedServer(chain(bulkExportHandler(bus, writeTimeout, serverLogger), withLogging(serverLogger)))
+	server.Config.WriteTimeout = writeTimeout
+	server.Start()
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "/api/export?format=ndjson")
+	if err != nil {
+		t.Fatalf("GET /api/export: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("export cut off after %v: %v", time.Since(start), err)
+	}
+	if strings.Contains(string(body), `"error"`) {
+		t.Errorf("export = %s, want every round", body)
+	}
+	if elapsed := time.Since(start); elapsed < writeTimeout {
+		t.Skipf("export took %v, within the write timeout", elapsed)
+	}
+}
+
+func TestCSVExportEscapesFormulas(t *testing.T) {
+	var out strings.Builder
+	exporter := &csvExporter{w: csv.NewWriter(&out)}
+	messages := []map[string]interface{}{
+		{"id": "m1", "username": "=cmd", "content": "+SUM(A1:A2)", "timestamp": "2026-01-01T00:00:00Z"},
+		{"id": "m2", "username": "@ada", "content": "-1", "timestamp": "2026-01-01T00:00:01Z"},
+		{"id": "m3", "username": "grace", "content": "\t=1", "timestamp": "2026-01-01T00:00:02Z"},
+		{"id": "m4", "username": "linus", "content": "1=1", "timestamp": "2026-01-01T00:00:03Z"},
+	}
+	if err := exporter.writeRound("7", messages, nil); err != nil {
+		t.Fatalf("writeRound: %v", err)
+	}
+	exporter.flush()
+
+	records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the export: %v", err)
+	}
+	want := [][2]string{{"'=cmd", "'+SUM(A1:A2)"}, {"'@ada", "'-1"}, {"grace", "'\t=1"}, {"linus", "1=1"}}
+	for i, record := range records {
+		if got := [2]string{record[2], record[3]}; got != want[i] {
+			t.Errorf("row %d username and content = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// internal/api/middleware.go
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/sso"
+	"github.com/erilali/internal/util"
+)
+
+// middleware wraps a handler with cross-cutting behaviour.
+type middleware func(http.Handler) http.Handler
+
+// chain applies middlewares so that the first one listed runs first.
+func chain(h http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// statusRecorder captures the response status for logging. It keeps Hijack working
+// so WebSocket upgrades pass through the chain.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, for handlers that move
+// their write deadline.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// withLogging logs method, path, status and duration of every request at debug level.
+func withLogging(serverLogger *logger.Logger) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			serverLogger.Debugf("%s %s %d %v", r.Method, r.URL.Path, rec.status, time.Since(start))
+		})
+	}
+}
+
+// withRecovery turns a panicking handler into a 500 response instead of a dropped connection.
+func withRecovery(serverLogger *logger.Logger) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
+					serverLogger.Errorf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withRequestTimeout gives every request a deadline on its context, so event bus reads
+// give up and answer 504 instead of holding the connection. WebSocket upgrades and the
+// streamed bulk export are left unbounded. A non-positive timeout disables it.
+func withRequestTimeout(timeout time.Duration) middleware {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/export" || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// writeHistoryError answers a failed event bus read: 504 when the request deadline
+// passed, 503 when the history limit is saturated and 500 with msg otherwise. Nothing
+// is written for a canceled request, whose client is gone.
+func writeHistoryError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, context.Canceled):
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, "Request timed out", http.StatusGatewayTimeout)
+	case errors.Is(err, eventbus.ErrHistoryBusy):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too many history requests, try again shortly", http.StatusServiceUnavailable)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// withCORS allows browser requests from the given origins; "*" allows any origin.
+// Without origins the middleware is a no-op.
+func withCORS(origins []string) middleware {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" && (allowed["*"] || allowed[origin]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withRateLimit applies a token bucket per client IP, honoring X-Forwarded-For from
+// trusted proxies. A non-positive rate disables it.
+func withRateLimit(perSecond float64, burst int, trusted []*net.IPNet) middleware {
+	return func(next http.Handler) http.Handler {
+		if perSecond <= 0 {
+			return next
+		}
+		limiter := newIPRateLimiter(perSecond, burst)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.allow(util.ClientIP(r, trusted)) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// serviceAccountKey carries the name of the service account that authorized an admin request.
+type serviceAccountKey struct{}
+
+// adminSessionKey carries the SSO session that authorized an admin request.
+type adminSessionKey struct{}
+
+// requireAdmin only lets requests through that carry the configured admin token or a
+// service account token as "Authorization: Bearer <token>", or, with SSO configured, an
+// admin session cookie. Service accounts and sessions with the read role may only read,
+// those with the admin role may do anything the admin token may. Service accounts are held
+// to the bot rate limit; cookie-authenticated writes and WebSocket upgrades must come from
+// the admin host itself, since browsers send the cookie along with cross-site requests.
+// Admin endpoints are disabled when neither an admin token, a service account nor SSO is
+// configured.
+func requireAdmin(cfg config.Config, provider *sso.Provider) middleware {
+	return func(next http.Handler) http.Handler {
+		botLimiter := newIPRateLimiter(cfg.BotRateLimitPerSecond, cfg.BotRateLimitBurst)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if provider != nil && strings.HasPrefix(r.URL.Path, ssoPathPrefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.AdminToken == "" && len(cfg.ServiceAccounts) == 0 && provider == nil {
+				http.Error(w, "Admin API disabled", http.StatusForbidden)
+				return
+			}
+			readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+			if provider != nil && !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+				session, ok := provider.SessionFromRequest(r)
+				switch {
+				case ok && session.Role != config.ScopeAdmin && !readOnly:
+					http.Error(w, "Your role does not allow this request", http.StatusForbidden)
+				case ok && (!readOnly || strings.EqualFold(r.Header.Get("Upgrade"), "websocket")) && !sameOrigin(r):
+					http.Error(w, "Cross-origin request refused", http.StatusForbidden)
+				case ok:
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminSessionKey{}, session)))
+				case readOnly && strings.Contains(r.Header.Get("Accept"), "text/html"):
+					// Browsers opening an admin page are sent to sign in first.
+					http.Redirect(w, r, ssoPathPrefix+"login?return="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				default:
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				}
+				return
+			}
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(cfg.AdminToken)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			account, ok := cfg.ServiceAccountByToken(provided)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !account.Allows(config.ScopeAdmin) && !(readOnly && account.Allows(config.ScopeRead)) {
+				http.Error(w, "Service account scope does not allow this request", http.StatusForbidden)
+				return
+			}
+			if cfg.BotRateLimitPerSecond > 0 && !botLimiter.allow(account.Name) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serviceAccountKey{}, account.Name)))
+		})
+	}
+}
+
+// sameOrigin reports whether a browser request comes from a page of the host it is sent
+// to. Requests without Origin, which browsers add to cross-origin requests, pass.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// ipRateLimiter keeps a token bucket per client IP. Idle buckets are dropped periodically.
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+const rateLimitSweepInterval = time.Minute
+
+func newIPRateLimiter(perSecond float64, burst int) *ipRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &ipRateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the IP's bucket and reports whether one was available.
+func (l *ipRateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		for key, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitSweepInterval {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.perSecond
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,81 @@
+// internal/api/mutes.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/hub"
+)
+
+// muter is implemented by hubs that can mute users.
+type muter interface {
+	NewMute(username, reason string, duration time.Duration, actor string) (hub.Mute, error)
+	ApplyMute(mute hub.Mute)
+	UnmuteUser(username, actor string) bool
+	Mutes() []hub.Mute
+}
+
+// adminMutesHandler serves GET /api/admin/mutes to list the active mutes, POST with a
+// body of username, duration_seconds and optional reason to mute a user, and
+// DELETE /api/admin/mutes/{username} to lift a mute early. With a control plane the mute
+// applies on every instance.
+func adminMutesHandler(muter muter, cluster controlPlane) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/mutes"), "/")
+		switch {
+		case username == "" && r.Method == http.MethodGet:
+			mutes := muter.Mutes()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"mutes": mutes,
+				"count": len(mutes),
+			})
+		case username == "" && r.Method == http.MethodPost:
+			var req struct {
+				Username        string `json:"username"`
+				DurationSeconds int    `json:"duration_seconds"`
+				Reason          string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+				http.Error(w, "Expected JSON body with username, duration_seconds and optional reason", http.StatusBadRequest)
+				return
+			}
+			mute, err := muter.NewMute(req.Username, req.Reason, time.Duration(req.DurationSeconds)*time.Second, adminActor(r))
+			if err != nil {
+				http.Error(w, "Invalid mute: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if cluster != nil {
+				result := cluster.Control(hub.ControlCommand{Action: hub.ControlMute, Mute: &mute, Actor: adminActor(r)})
+				writeControlResult(w, http.StatusCreated, map[string]interface{}{
+					"mute": mute,
+				}, result)
+				return
+			}
+			muter.ApplyMute(mute)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(mute)
+		case username != "" && r.Method == http.MethodDelete:
+			if cluster != nil {
+				result := cluster.Control(hub.ControlCommand{Action: hub.ControlUnmute, Username: username, Actor: adminActor(r)})
+				if !result.Found() {
+					http.Error(w, "User is not muted", http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if !muter.UnmuteUser(username, adminActor(r)) {
+				http.Error(w, "User is not muted", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// internal/api/nats.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/streams"
+	"github.com/nats-io/nats.go"
+)
+
+// connectionStatus remembers the most recent NATS error so /readyz can report it.
+type connectionStatus struct {
+	mu      sync.Mutex
+	lastErr error
+}
+
+func (s *connectionStatus) setError(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+func (s *connectionStatus) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// natsOptions builds the connection options for authentication, TLS and the connection name.
+func natsOptions(cfg config.Config, status *connectionStatus, serverLogger *logger.Logger) ([]nats.Option, error) {
+	opts := []nats.Option{
+		nats.Name(cfg.NatsConnectionName),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			if isNATSAuthError(err) {
+				serverLogger.Errorf("NATS authorization error: %v", err)
+			} else {
+				serverLogger.Errorf("NATS async error: %v", err)
+			}
+			status.setError(err)
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				serverLogger.Warnf("Disconnected from NATS: %v", err)
+				status.setError(err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			serverLogger.Infof("Reconnected to NATS at %s", nc.ConnectedUrl())
+			status.setError(nil)
+		}),
+	}
+
+	credentialSources := 0
+	if cfg.NatsUser != "" {
+		opts = append(opts, nats.UserInfo(cfg.NatsUser, cfg.NatsPassword))
+		credentialSources++
+	}
+	if cfg.NatsCredsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.NatsCredsFile))
+		credentialSources++
+	}
+	if cfg.NatsNKeyFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(cfg.NatsNKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading nkey seed %s: %w", cfg.NatsNKeyFile, err)
+		}
+		opts = append(opts, opt)
+		credentialSources++
+	}
+	if credentialSources > 1 {
+		return nil, errors.New("only one of nats_user, nats_creds_file and nats_nkey_file may be set")
+	}
+
+	if cfg.NatsTLSCert != "" || cfg.NatsTLSKey != "" {
+		if cfg.NatsTLSCert == "" || cfg.NatsTLSKey == "" {
+			return nil, errors.New("nats_tls_cert and nats_tls_key must be set together")
+		}
+		opts = append(opts, nats.ClientCert(cfg.NatsTLSCert, cfg.NatsTLSKey))
+	}
+	if cfg.NatsTLSCA != "" {
+		opts = append(opts, nats.RootCAs(cfg.NatsTLSCA))
+	}
+	return opts, nil
+}
+
+// isNATSAuthError reports whether err is caused by rejected credentials or permissions.
+func isNATSAuthError(err error) bool {
+	if errors.Is(err, nats.ErrAuthorization) || errors.Is(err, nats.ErrAuthExpired) ||
+		errors.Is(err, nats.ErrAuthRevoked) || errors.Is(err, nats.ErrPermissionViolation) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "authorization") || strings.Contains(msg, "authentication")
+}
+
+// connectNATS connects to NATS, sets up JetStream and reconciles the streams with spec.
+// Either return value may be nil when the corresponding service is unavailable.
+func connectNATS(cfg config.Config, spec streams.Spec, status *connectionStatus, serverLogger *logger.Logger) (*nats.Conn, nats.JetStreamContext) {
+	opts, err := natsOptions(cfg, status, serverLogger)
+	if err != nil {
+		serverLogger.Errorf("Invalid NATS security configuration: %v", err)
+		status.setError(err)
+		serverLogger.Warn("Running without NATS connection. Message persistence will be disabled.")
+		return nil, nil
+	}
+
+	serverLogger.Infof("Connecting to NATS at %s", cfg.NatsURL)
+	nc, err := nats.Connect(cfg.NatsURL, opts...)
+	if err != nil {
+		if isNATSAuthError(err) {
+			serverLogger.Errorf("NATS authentication failed, check nats_user/nats_password, nats_creds_file or nats_nkey_file: %v", err)
+		} else {
+			serverLogger.Errorf("Error connecting to NATS: %v", err) // Wrapped error
+		}
+		status.setError(err)
+		serverLogger.Warn("Running without NATS connection. Message persistence will be disabled.")
+		return nil, nil
+	}
+	serverLogger.Info("Successfully connected to NATS")
+
+	js, err := nc.JetStream()
+	if err != nil {
+		serverLogger.Errorf("Error getting JetStream context: %v", err)
+		serverLogger.Warn("Running without JetStream. Message persistence will be disabled.")
+		return nc, nil
+	}
+	serverLogger.Info("Successfully connected to JetStream")
+
+	reconcileStreams(cfg, streams.ConnDomains(nc, js), spec, serverLogger)
+	return nc, js
+}
@@ -0,0 +1,59 @@
+// internal/api/protocol.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	hubpkg "github.com/erilali/internal/hub"
+	"github.com/erilali/internal/message"
+)
+
+// protocolHandler serves GET /api/protocol: the JSON Schema of every WebSocket message type.
+// An optional ?direction=client_to_server|server_to_client narrows the list.
+func protocolHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		direction := r.URL.Query().Get("direction")
+
+		messages := make([]map[string]interface{}, 0, len(message.Protocol))
+		for _, spec := range message.Protocol {
+			if direction != "" && spec.Direction != direction {
+				continue
+			}
+			messages = append(messages, map[string]interface{}{
+				"type":      spec.Type,
+				"direction": spec.Direction,
+				"version":   spec.Version,
+				"schema":    spec.Schema(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"version":      message.ProtocolVersion,
+			"subprotocols": []string{hubpkg.SubprotocolJSON, hubpkg.SubprotocolMsgpack},
+			"framing":      message.Framing,
+			"messages":     messages,
+		})
+	}
+}
+
+// protocolClientHandler serves GET /api/protocol/client.ts: TypeScript types of every
+// WebSocket message type and a small client, generated from the same structs as
+// /api/protocol so they always match the running server.
+func protocolClientHandler() http.HandlerFunc {
+	source := message.TypeScript()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/typescript; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="protocol.ts"`)
+		w.Write(source)
+	}
+}
@@ -0,0 +1,310 @@
+// internal/api/rooms.go
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/hub"
+)
+
+// roomProvider is implemented by hubs that host private rooms.
+type roomProvider interface {
+	CreateRoom(settings hub.RoomSettings, resumeToken string) (hub.RoomCredentials, error)
+	Room(name string) (hub.RoomInfo, bool)
+	Rooms() []hub.RoomInfo
+	OwnedRoom(name, ownerToken string) (*hub.Hub, error)
+	CreateRoomInvite(name, ownerToken string) (string, error)
+	DeleteRoom(ctx context.Context, name, ownerToken string) error
+	ModeratedRoom(name, token string) (*hub.Hub, hub.RoomRole, error)
+	RoomRoles(name, ownerToken string) ([]hub.RoomRole, error)
+	SetRoomRole(name, ownerToken, username, role string) (hub.RoomRole, error)
+}
+
+// roomsHandler serves GET /api/rooms to list the rooms, POST /api/rooms to create a room
+// with the creator's resume token as a bearer token, and the routes of one room:
+//
+//	GET    /api/rooms/{name}                              room details
+//	DELETE /api/rooms/{name}                              delete the room (owner)
+//	POST   /api/rooms/{name}/invites                      issue a single-use invite token (owner)
+//	POST   /api/rooms/{name}/clients/{username}/kick      kick a client from the room (owner)
+//	POST   /api/rooms/{name}/bans                         ban a user from the room (owner)
+//	DELETE /api/rooms/{name}/bans/{username}              lift a room ban (owner)
+//	POST   /api/rooms/{name}/rounds/end                   end the room's active round (owner)
+//	GET    /api/rooms/{name}/roles                        list the moderators and spectators (owner)
+//	PUT    /api/rooms/{name}/roles/{username}             assign a role, {"role": "moderator"} (owner)
+//	DELETE /api/rooms/{name}/roles/{username}             make the user a player again (owner)
+//	DELETE /api/rooms/{name}/rounds/{id}/messages/{msgID} remove a submission (owner or moderator)
+//	POST   /api/rooms/{name}/mutes                        mute a user in the room (owner or moderator)
+//
+// Owner routes take the owner token returned on creation as a bearer token, moderator
+// routes the owner token or the moderator token returned when the role was assigned.
+func roomsHandler(provider roomProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/rooms"), "/")
+		if rest == "" {
+			switch r.Method {
+			case http.MethodGet:
+				listRooms(w, r, provider)
+			case http.MethodPost:
+				createRoom(w, r, provider)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		name, action, _ := strings.Cut(rest, "/")
+		if action == "" && r.Method == http.MethodGet {
+			info, ok := provider.Room(name)
+			if !ok {
+				http.Error(w, "Room not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(info)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if action == "mutes" || strings.HasPrefix(action, "rounds/") && strings.Contains(action, "/messages/") {
+			moderateRoom(w, r, provider, name, action, token)
+			return
+		}
+		roomHub, err := provider.OwnedRoom(name, token)
+		if errors.Is(err, hub.ErrNotRoomOwner) {
+			if _, access, err := provider.ModeratedRoom(name, token); err == nil && access.Role == hub.RoleModerator {
+				http.Error(w, "Only the room's owner can do this, moderators remove submissions and mute users", http.StatusForbidden)
+				return
+			}
+		}
+		if !writeRoomError(w, err) {
+			return
+		}
+		actor := "owner:" + name
+		if info, ok := provider.Room(name); ok {
+			actor = info.Owner
+		}
+
+		switch {
+		case action == "" && r.Method == http.MethodDelete:
+			if !writeRoomError(w, provider.DeleteRoom(r.Context(), name, token)) {
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case action == "invites" && r.Method == http.MethodPost:
+			invite, err := provider.CreateRoomInvite(name, token)
+			if !writeRoomError(w, err) {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"room": name, "invite_token": invite})
+		case strings.HasPrefix(action, "clients/") && strings.HasSuffix(action, "/kick") && r.Method == http.MethodPost:
+			username := strings.TrimSuffix(strings.TrimPrefix(action, "clients/"), "/kick")
+			kicked := roomHub.KickClient(username, actor)
+			if kicked == 0 {
+				http.Error(w, "Client not connected", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"room": name, "username": username, "kicked": kicked})
+		case action == "bans" || strings.HasPrefix(action, "bans/"):
+			// The admin handler reads the username from its own route.
+			r = r.Clone(r.Context())
+			r.URL.Path = "/api/admin/" + action
+			adminBansHandler(roomHub, nil)(w, r)
+		case action == "rounds/end":
+			adminEndRoundHandler(roomHub, nil)(w, r)
+		case action == "roles" && r.Method == http.MethodGet:
+			roles, err := provider.RoomRoles(name, token)
+			if !writeRoomError(w, err) {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"room": name, "roles": roles})
+		case strings.HasPrefix(action, "roles/"):
+			setRoomRole(w, r, provider, name, strings.TrimPrefix(action, "roles/"), token)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// listRooms handles GET /api/rooms: every room with its occupancy, round settings and
+// whether a round is live. ?public=true leaves out the rooms that need a join code.
+func listRooms(w http.ResponseWriter, r *http.Request, provider roomProvider) {
+	publicOnly := r.URL.Query().Get("public") == "true"
+	rooms := make([]hub.RoomInfo, 0)
+	for _, info := range provider.Rooms() {
+		if !publicOnly || info.Public {
+			rooms = append(rooms, info)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rooms": rooms,
+		"count": len(rooms),
+	})
+}
+
+// createRoom handles POST /api/rooms with a RoomSettings body and the creator's resume
+// token as a bearer token, and answers with the room's join code and owner token.
+func createRoom(w http.ResponseWriter, r *http.Request, provider roomProvider) {
+	var settings hub.RoomSettings
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		http.Error(w, "Invalid room settings: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	credentials, err := provider.CreateRoom(settings, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	switch {
+	case errors.Is(err, hub.ErrRoomAuth):
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, hub.ErrRoomExists):
+		http.Error(w, "Room already exists", http.StatusConflict)
+		return
+	case errors.Is(err, hub.ErrTooManyOwnedRooms):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, hub.ErrRoomCreateTooSoon):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, hub.ErrTooManyRooms):
+		http.Error(w, "Too many rooms, try again later", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(credentials)
+}
+
+// setRoomRole handles PUT /api/rooms/{name}/roles/{username} with a {"role": "..."} body
+// and DELETE, which makes the user a player again. The answer to making someone a
+// moderator carries the moderator token, shown only this once.
+func setRoomRole(w http.ResponseWriter, r *http.Request, provider roomProvider, name, username, token string) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		req.Role = hub.RolePlayer
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if username == "" || strings.Contains(username, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	role, err := provider.SetRoomRole(name, token, username, req.Role)
+	if !writeRoomError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(role)
+}
+
+// moderateRoom serves the routes moderators share with the owner: removing a submission
+// of the room's rounds and muting a user in the room.
+func moderateRoom(w http.ResponseWriter, r *http.Request, provider roomProvider, name, action, token string) {
+	roomHub, access, err := provider.ModeratedRoom(name, token)
+	if !writeRoomError(w, err) {
+		return
+	}
+	if action == "mutes" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Username        string `json:"username"`
+			DurationSeconds int    `json:"duration_seconds"`
+			Reason          string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+			http.Error(w, "Invalid JSON body, username and duration_seconds are required", http.StatusBadRequest)
+			return
+		}
+		mute, err := roomHub.MuteInRoom(access, req.Username, req.Reason, time.Duration(req.DurationSeconds)*time.Second)
+		if !writeRoomError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(mute)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(action, "rounds/"), "/")
+	if len(parts) != 3 || parts[1] != "messages" || parts[2] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	roundID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid round ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	redaction, err := roomHub.RemoveSubmission(roundID, parts[2], req.Reason, access.Username)
+	if errors.Is(err, hub.ErrMessageNotFound) {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if !writeRoomError(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redaction)
+}
+
+// writeRoomError answers a failed room operation and reports whether err was nil.
+func writeRoomError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, hub.ErrRoomNotFound):
+		http.Error(w, "Room not found", http.StatusNotFound)
+	case errors.Is(err, hub.ErrNotRoomOwner):
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Owner token required", http.StatusUnauthorized)
+	case errors.Is(err, hub.ErrTooManyInvites):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, hub.ErrRoleForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, hub.ErrInvalidRole), errors.Is(err, hub.ErrMuteDuration):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
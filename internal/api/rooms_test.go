@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erilali/internal/hub"
+)
+
+// The handlers find the room routes by asserting the hub to roomProvider, so a change of
+// signature would silently drop them.
+var _ roomProvider = (*hub.Hub)(nil)
+
+// fakeRooms answers room creations with err and records the token they carried.
+type fakeRooms struct {
+	roomProvider
+	token string
+	err   error
+}
+
+func (f *fakeRooms) CreateRoom(settings hub.RoomSettings, resumeToken string) (hub.RoomCredentials, error) {
+	f.token = resumeToken
+	return hub.RoomCredentials{}, f.err
+}
+
+func TestCreateRoomStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"no resume token", hub.ErrRoomAuth, http.StatusUnauthorized},
+		{"name taken", hub.ErrRoomExists, http.StatusConflict},
+		{"owns too many", hub.ErrTooManyOwnedRooms, http.StatusConflict},
+		{"too soon", hub.ErrRoomCreateTooSoon, http.StatusTooManyRequests},
+		{"server full", hub.ErrTooManyRooms, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeRooms{err: tt.err}
+			req := httptest.NewRequest(http.MethodPost, "/api/rooms", strings.NewReader(`{"name": "lobby"}`))
+			req.Header.Set("Authorization", "Bearer resume-token")
+			rec := httptest.NewRecorder()
+			roomsHandler(provider)(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if provider.token != "resume-token" {
+				t.Errorf("token = %q, want the bearer token", provider.token)
+			}
+		})
+	}
+}
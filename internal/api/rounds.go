@@ -0,0 +1,434 @@
+// internal/api/rounds.go
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/attachments"
+	"github.com/erilali/internal/eventbus"
+	hubpkg "github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+)
+
+// History sources reported in round history responses.
+const (
+	sourceEventBus = "event_bus"
+	sourceMemory   = "memory"
+)
+
+// Page sizes of round history responses.
+const (
+	roundPageDefaultLimit = 100
+	roundPageMaxLimit     = 1000
+)
+
+// errInvalidCursor is returned for continuation tokens that were not issued by this API.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// roundPage selects the messages of a round history response.
+type roundPage struct {
+	offset int
+	limit  int
+}
+
+// parseRoundPage reads ?limit= and the ?cursor= continuation token of a previous response.
+func parseRoundPage(query url.Values) (roundPage, error) {
+	page := roundPage{limit: roundPageDefaultLimit}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > roundPageMaxLimit {
+			return roundPage{}, errors.New("invalid limit parameter")
+		}
+		page.limit = n
+	}
+	if v := query.Get("cursor"); v != "" {
+		offset, err := decodeCursor(v)
+		if err != nil {
+			return roundPage{}, err
+		}
+		page.offset = offset
+	}
+	return page, nil
+}
+
+// encodeCursor returns the continuation token for the messages from offset on.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset a continuation token points to.
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+	rest, ok := strings.CutPrefix(string(data), "o:")
+	if !ok {
+		return 0, errInvalidCursor
+	}
+	offset, err := strconv.Atoi(rest)
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+// recentRoundProvider is implemented by hubs that keep recently finished rounds in memory.
+type recentRoundProvider interface {
+	RecentRound(roundID int64) (hubpkg.RecentRound, bool)
+}
+
+// roundOddsProvider is implemented by hubs that record the odds of their winner draws.
+type roundOddsProvider interface {
+	RoundOdds(ctx context.Context, roundID int64) (hubpkg.RoundOdds, error)
+}
+
+// roundsHandler routes /api/rounds/{id}?limit=&cursor=, /api/rounds/{id}/export and
+// /api/rounds/{id}/odds. Without an event bus, round history is served from the hub's
+// in-memory window.
+func roundsHandler(bus eventbus.EventBus, cache *roundCache, loads *roundLoads, recent recentRoundProvider, odds roundOddsProvider, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/rounds/")
+		roundID, resource, _ := strings.Cut(rest, "/")
+		if roundID == "" {
+			http.Error(w, "Round ID required", http.StatusBadRequest)
+			return
+		}
+		if resource == "odds" {
+			if odds == nil {
+				http.NotFound(w, r)
+				return
+			}
+			roundOddsHandler(odds, roundID)(w, r)
+			return
+		}
+		var page roundPage
+		if resource == "" {
+			var err error
+			if page, err = parseRoundPage(r.URL.Query()); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if bus == nil {
+			if resource == "" && recent != nil {
+				memoryRoundHandler(recent, roundID, page)(w, r)
+				return
+			}
+			http.Error(w, "Event bus not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		switch resource {
+		case "":
+			roundHistoryHandler(bus, cache, loads, recent, roundID, page, serverLogger)(w, r)
+		case "export":
+			roundExportHandler(bus, roundID, serverLogger)(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// roundHistoryHandler serves the messages and winner of a round as JSON, one page of
+// messages at a time. Finished rounds are served from the cache after the first request,
+// and concurrent requests for a round that is not cached share one fetch. When the event
+// bus cannot be read, rounds still held in memory are served from there.
+func roundHistoryHandler(bus eventbus.EventBus, cache *roundCache, loads *roundLoads, recent recentRoundProvider, roundID string, page roundPage, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, cached := cache.get(roundID)
+		if !cached {
+			var err error
+			record, err = loads.do(r.Context(), roundID, func(ctx context.Context) (roundRecord, error) {
+				record, err := loadRound(ctx, bus, roundID, serverLogger)
+				if err != nil {
+					return roundRecord{}, err
+				}
+				record.reactions = loadReactions(ctx, bus, roundID, serverLogger)
+				// A partial read is served but not cached, so the next request reads again.
+				if record.complete && cache.final(roundID) {
+					cache.put(roundID, record)
+				}
+				return record, nil
+			})
+			if err != nil {
+				if recent != nil {
+					if id, parseErr := strconv.ParseInt(roundID, 10, 64); parseErr == nil {
+						if round, ok := recent.RecentRound(id); ok {
+							writeRoundRecord(w, roundID, memoryRoundRecord(round), sourceMemory, page)
+							return
+						}
+					}
+				}
+				writeHistoryError(w, err, "Error retrieving messages")
+				return
+			}
+		}
+
+		writeRoundRecord(w, roundID, record, sourceEventBus, page)
+	}
+}
+
+// memoryRoundHandler serves a round from the hub's in-memory history.
+func memoryRoundHandler(recent recentRoundProvider, roundID string, page roundPage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(roundID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid round ID", http.StatusBadRequest)
+			return
+		}
+		round, ok := recent.RecentRound(id)
+		if !ok {
+			http.Error(w, "Round not available while the event bus is down", http.StatusNotFound)
+			return
+		}
+		writeRoundRecord(w, roundID, memoryRoundRecord(round), sourceMemory, page)
+	}
+}
+
+// writeRoundRecord encodes a round history response with one page of its messages.
+// total counts every message of the round, and next_cursor continues with the next page.
+// complete is false when the round had more events than could be read.
+func writeRoundRecord(w http.ResponseWriter, roundID string, record roundRecord, source string, page roundPage) {
+	total := len(record.messages)
+	start := min(page.offset, total)
+	end := min(start+page.limit, total)
+	response := map[string]interface{}{
+		"round_id":  roundID,
+		"messages":  record.messages[start:end],
+		"winner":    record.winner,
+		"reactions": record.reactions,
+		"count":     end - start,
+		"total":     total,
+		"limit":     page.limit,
+		"complete":  record.complete,
+		"source":    source,
+		"timestamp": time.Now(),
+	}
+	if end < total {
+		response["next_cursor"] = encodeCursor(end)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// memoryRoundRecord converts a round held in memory into the shape of the events
+// read from the event bus.
+func memoryRoundRecord(round hubpkg.RecentRound) roundRecord {
+	record := roundRecord{messages: make([]map[string]interface{}, 0, len(round.Messages)), complete: true}
+	for _, msg := range round.Messages {
+		event := map[string]interface{}{
+			"id":        msg.ID,
+			"username":  msg.Username,
+			"content":   msg.Message,
+			"payload":   message.Submission{Text: msg.Message, Lang: msg.Lang, AttachmentID: msg.AttachmentID, Choice: msg.Choice},
+			"timestamp": msg.Timestamp.Unix(),
+			"round_id":  round.RoundID,
+		}
+		if msg.Bot {
+			event["bot"] = true
+		}
+		record.messages = append(record.messages, event)
+	}
+	if round.Winner != nil {
+		record.winner = map[string]interface{}{
+			"round_id":   round.RoundID,
+			"message_id": round.Winner.ID,
+			"username":   round.Winner.Username,
+			"content":    round.Winner.Message,
+			"timestamp":  round.EndedAt.Unix(),
+		}
+		if round.Winner.AttachmentID != "" {
+			record.winner["attachment_url"] = attachments.URL(round.Winner.AttachmentID)
+		}
+		if round.Winner.Bot {
+			record.winner["bot"] = true
+		}
+	}
+	if round.Reactions != nil {
+		record.reactions = make(map[string]interface{}, len(round.Reactions))
+		for emoji, count := range round.Reactions {
+			record.reactions[emoji] = count
+		}
+	}
+	return record
+}
+
+// loadRound reads the folded messages and the winner record of a round from the event bus.
+// Events are read until none are pending, up to roundEventLimit within roundFetchDeadline.
+// The latest winner record wins, so appeal corrections replace the original selection.
+// A missing winner is not an error. Reads stop once ctx is done. The record is not
+// complete when the round has more events than the limit or either read stopped at its
+// deadline with events pending.
+func loadRound(ctx context.Context, bus eventbus.EventBus, roundID string, serverLogger *logger.Logger) (roundRecord, error) {
+	subject := fmt.Sprintf("messages.%s", roundID)
+	events, err := bus.HistoryContext(ctx, subject, roundEventLimit, roundFetchDeadline)
+	if ctx.Err() != nil {
+		return roundRecord{}, ctx.Err()
+	}
+	truncated := errors.Is(err, eventbus.ErrHistoryIncomplete)
+	if err != nil && !truncated {
+		serverLogger.Errorf("Error reading history for subject %s: %v", subject, err)
+		return roundRecord{}, err
+	}
+	record := roundRecord{
+		messages: foldMessageEvents(events, serverLogger),
+		complete: !truncated && len(events) < roundEventLimit,
+	}
+	if truncated {
+		serverLogger.Warnf("Round %s history is truncated: %v", roundID, err)
+	} else if !record.complete {
+		serverLogger.Warnf("Round %s has more than %d events, history is truncated", roundID, roundEventLimit)
+	}
+
+	winnerSubject := fmt.Sprintf("winners.%s", roundID)
+	winnerEvents, err := bus.HistoryContext(ctx, winnerSubject, winnerHistoryLimit, winnerAPIFetchMaxWait)
+	if ctx.Err() != nil {
+		return roundRecord{}, ctx.Err()
+	}
+	if errors.Is(err, eventbus.ErrHistoryIncomplete) {
+		// The latest correction may be among the events not read.
+		record.complete = false
+		serverLogger.Warnf("Winner history of round %s is truncated: %v", roundID, err)
+		winnerEvents = nil
+	} else if err != nil {
+		serverLogger.Warnf("Error reading winner for subject %s: %v. Winner might not be retrieved.", winnerSubject, err)
+	}
+	if len(winnerEvents) > 0 {
+		var winnerMsg map[string]interface{}
+		if unmarshalErr := json.Unmarshal(winnerEvents[len(winnerEvents)-1].Data, &winnerMsg); unmarshalErr == nil {
+			// A correction without a username invalidated the winner and found no other.
+			if username, _ := winnerMsg["username"].(string); username != "" {
+				record.winner = winnerMsg
+			}
+		} else {
+			serverLogger.Errorf("Error unmarshaling winner message: %v", unmarshalErr)
+		}
+	}
+	return record, nil
+}
+
+// loadReactions returns the archived reaction counts of a round, or nil if none were recorded.
+// Counts are archived once the round's reveal phase ends.
+func loadReactions(ctx context.Context, bus eventbus.EventBus, roundID string, serverLogger *logger.Logger) map[string]interface{} {
+	subject := fmt.Sprintf("reactions.%s", roundID)
+	events, err := bus.HistoryContext(ctx, subject, 1, winnerAPIFetchMaxWait)
+	if err != nil && !errors.Is(err, eventbus.ErrHistoryIncomplete) {
+		serverLogger.Warnf("Error reading reactions for subject %s: %v", subject, err)
+		return nil
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	var record struct {
+		Counts map[string]interface{} `json:"counts"`
+	}
+	if err := json.Unmarshal(events[0].Data, &record); err != nil {
+		serverLogger.Errorf("Error unmarshaling reactions: %v", err)
+		return nil
+	}
+	return record.Counts
+}
+
+// foldMessageEvents replays submission events in order and returns the resulting messages.
+// Edits replace the content of an earlier submission, withdrawals remove it, and repeated
+// submissions with the same ID are ignored. Events without an ID are kept as they are.
+func foldMessageEvents(events []eventbus.Event, serverLogger *logger.Logger) []map[string]interface{} {
+	var ordered []map[string]interface{}
+	byID := make(map[string]map[string]interface{})
+	removed := make(map[string]bool) // kept for removals read before their submission
+	for _, event := range events {
+		var message map[string]interface{}
+		if err := json.Unmarshal(event.Data, &message); err != nil {
+			serverLogger.Errorf("Error unmarshaling message: %v", err) // Wrapped error
+			continue
+		}
+		id, _ := message["id"].(string)
+		if id == "" {
+			ordered = append(ordered, message)
+			continue
+		}
+
+		action, _ := message["action"].(string)
+		existing, seen := byID[id]
+		switch action {
+		case "edit":
+			if seen {
+				existing["content"] = message["content"]
+				existing["payload"] = message["payload"]
+				existing["edited_at"] = message["timestamp"]
+			}
+		case "withdraw", "redact":
+			removed[id] = true
+			if seen {
+				existing["withdrawn"] = true
+			}
+		default:
+			if !seen {
+				if removed[id] {
+					message["withdrawn"] = true
+				}
+				byID[id] = message
+				ordered = append(ordered, message)
+			}
+		}
+	}
+
+	messages := make([]map[string]interface{}, 0, len(ordered))
+	for _, message := range ordered {
+		if withdrawn, _ := message["withdrawn"].(bool); withdrawn {
+			continue
+		}
+		delete(message, "action")
+		message["payload"] = submissionPayload(message)
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// submissionPayload decodes the structured submission of a message event. Events
+// published before structured submissions only carry their text in "content".
+func submissionPayload(event map[string]interface{}) message.Submission {
+	var payload message.Submission
+	if raw, ok := event["payload"]; ok {
+		if data, err := json.Marshal(raw); err == nil && json.Unmarshal(data, &payload) == nil {
+			return payload
+		}
+	}
+	payload.Text, _ = event["content"].(string)
+	payload.AttachmentID, _ = event["attachment_id"].(string)
+	return payload
+}
+
+// roundOddsHandler serves GET /api/rounds/{id}/odds: every submission of the round with
+// its chance of being drawn under the strategy the winner was selected with.
+func roundOddsHandler(provider roundOddsProvider, roundID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(roundID, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid round ID", http.StatusBadRequest)
+			return
+		}
+		odds, err := provider.RoundOdds(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(odds)
+	}
+}
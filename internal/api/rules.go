@@ -0,0 +1,27 @@
+// internal/api/rules.go
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/erilali/internal/hub"
+)
+
+// rulesProvider is implemented by hubs that report their game rules.
+type rulesProvider interface {
+	GameRules() hub.GameRules
+}
+
+// rulesHandler serves GET /api/rules, the active rule set. Clients receive changes as
+// rules_update over the WebSocket.
+func rulesHandler(provider rulesProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(provider.GameRules())
+	}
+}
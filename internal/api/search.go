@@ -0,0 +1,113 @@
+// internal/api/search.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/erilali/internal/hub"
+)
+
+// searchProvider is implemented by hubs that index finished rounds for search.
+type searchProvider interface {
+	Search(q hub.SearchQuery) (hub.SearchResult, error)
+}
+
+// roundTagger is implemented by hubs whose rounds can be tagged.
+type roundTagger interface {
+	SetRoundTags(room string, roundID int64, tags []string, actor string) ([]string, error)
+}
+
+// searchHandler serves GET /api/search?tag=&username=&text=&limit=: the most recent
+// finished rounds carrying every tag (repeat tag or separate tags with commas), with
+// their messages by username containing every word of text.
+func searchHandler(provider searchProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		q := hub.SearchQuery{
+			Username: query.Get("username"),
+			Text:     query.Get("text"),
+		}
+		for _, value := range query["tag"] {
+			for _, tag := range strings.Split(value, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					q.Tags = append(q.Tags, tag)
+				}
+			}
+		}
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+
+		result, err := provider.Search(q)
+		switch {
+		case errors.Is(err, hub.ErrSearchDisabled):
+			http.Error(w, "Search disabled", http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// adminRoundTagsHandler serves PUT /api/admin/rounds/{roundID}/tags with a body of
+// {"tags": ["theme:space", ...]}, replacing the tags of an active or indexed round of the
+// main room, or of the room named by ?room=. The response lists the round's tags.
+func adminRoundTagsHandler(tagger roundTagger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tagger == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/rounds/"), "/")
+		roundID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid round ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		tags, err := tagger.SetRoundTags(r.URL.Query().Get("room"), roundID, req.Tags, adminActor(r))
+		switch {
+		case errors.Is(err, hub.ErrInvalidTag):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, hub.ErrRoomNotFound), errors.Is(err, hub.ErrRoundNotTaggable), errors.Is(err, hub.ErrSearchDisabled):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"round_id": roundID,
+			"tags":     tags,
+		})
+	}
+}
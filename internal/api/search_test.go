@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/erilali/internal/hub"
+)
+
+// fakeSearch answers with err and records the last query.
+type fakeSearch struct {
+	query hub.SearchQuery
+	err   error
+}
+
+func (f *fakeSearch) Search(q hub.SearchQuery) (hub.SearchResult, error) {
+	f.query = q
+	return hub.SearchResult{}, f.err
+}
+
+func TestSearchHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		err    error
+		status int
+	}{
+		{"found", http.MethodGet, "/api/search?text=moon", nil, http.StatusOK},
+		{"wrong method", http.MethodPost, "/api/search?text=moon", nil, http.StatusMethodNotAllowed},
+		{"bad limit", http.MethodGet, "/api/search?text=moon&limit=x", nil, http.StatusBadRequest},
+		{"zero limit", http.MethodGet, "/api/search?text=moon&limit=0", nil, http.StatusBadRequest},
+		{"empty query", http.MethodGet, "/api/search", hub.ErrEmptySearch, http.StatusBadRequest},
+		{"invalid tag", http.MethodGet, "/api/search?tag=bad%20tag", hub.ErrInvalidTag, http.StatusBadRequest},
+		{"disabled", http.MethodGet, "/api/search?text=moon", hub.ErrSearchDisabled, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			searchHandler(&fakeSearch{err: tt.err})(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerQuery(t *testing.T) {
+	provider := &fakeSearch{}
+	target := "/api/search?tag=theme:space,%20event:launch&tag=room:lobby&username=ada&text=moon%20base&limit=5"
+	searchHandler(provider)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+
+	q := provider.query
+	if want := []string{"theme:space", "event:launch", "room:lobby"}; !slices.Equal(q.Tags, want) {
+		t.Errorf("tags = %q, want %q", q.Tags, want)
+	}
+	if q.Username != "ada" || q.Text != "moon base" || q.Limit != 5 {
+		t.Errorf("query = %+v, want username ada, text %q and limit 5", q, "moon base")
+	}
+}
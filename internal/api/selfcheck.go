@@ -0,0 +1,208 @@
+// internal/api/selfcheck.go
+package api
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/sso"
+	"github.com/nats-io/nats.go"
+)
+
+// Outcomes of a startup check.
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// CheckResult is the outcome of one startup check.
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// StartupReport collects the checks run before the server starts serving. With
+// strict_startup a failed check stops the server instead of letting it run degraded,
+// for example with persistence disabled.
+type StartupReport struct {
+	Strict bool          `json:"strict"`
+	Passed bool          `json:"passed"` // no check failed
+	Checks []CheckResult `json:"checks"`
+}
+
+func (r *StartupReport) add(name, status, detail string) {
+	r.Checks = append(r.Checks, CheckResult{Name: name, Status: status, Detail: detail})
+}
+
+// addError records a failed check for a non-nil err and a passed one otherwise.
+func (r *StartupReport) addError(name string, err error, ok string) {
+	if err != nil {
+		r.add(name, CheckFail, err.Error())
+		return
+	}
+	r.add(name, CheckOK, ok)
+}
+
+// finish logs every check that did not pass and a summary. In strict mode a failed
+// check prints the report as JSON on stdout and exits with status 1.
+func (r *StartupReport) finish(serverLogger *logger.Logger) {
+	failed, warned := 0, 0
+	for _, check := range r.Checks {
+		switch check.Status {
+		case CheckFail:
+			failed++
+			serverLogger.Errorf("Startup check %s failed: %s", check.Name, check.Detail)
+		case CheckWarn:
+			warned++
+			serverLogger.Warnf("Startup check %s: %s", check.Name, check.Detail)
+		}
+	}
+	r.Passed = failed == 0
+	serverLogger.Infof("Startup self-check: %d checks, %d failed, %d warnings", len(r.Checks), failed, warned)
+	if r.Passed || !r.Strict {
+		return
+	}
+	data, _ := json.MarshalIndent(r, "", "  ")
+	fmt.Fprintln(os.Stdout, string(data))
+	serverLogger.Errorf("strict_startup is set, exiting after %d failed startup checks", failed)
+	os.Exit(1)
+}
+
+// CheckLogFile checks that the log file can be created and appended to, as the logger
+// does not report that and would silently lose the file output.
+func CheckLogFile(path string) CheckResult {
+	result := CheckResult{Name: "log_file", Status: CheckOK, Detail: path}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		result.Status, result.Detail = CheckFail, err.Error()
+		return result
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		result.Status, result.Detail = CheckFail, err.Error()
+		return result
+	}
+	file.Close()
+	return result
+}
+
+// checkConfig adds the sanity checks of settings that would otherwise only fail, or be
+// ignored, once the server runs.
+func (r *StartupReport) checkConfig(cfg config.Config) {
+	var problems []string
+	if cfg.RoundDurationSeconds <= 0 {
+		problems = append(problems, "round_duration_seconds must be positive")
+	}
+	if cfg.MinRoundDurationSeconds > cfg.MaxRoundDurationSeconds {
+		problems = append(problems, "min_round_duration_seconds exceeds max_round_duration_seconds")
+	}
+	if _, _, err := net.SplitHostPort(cfg.ListenAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("listen_addr: %v", err))
+	}
+	if cfg.AdminListenAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.AdminListenAddr); err != nil {
+			problems = append(problems, fmt.Sprintf("admin_listen_addr: %v", err))
+		} else if cfg.AdminListenAddr == cfg.ListenAddr {
+			problems = append(problems, "admin_listen_addr must differ from listen_addr")
+		}
+	}
+	switch cfg.EventBus {
+	case config.EventBusJetStream, "":
+	case config.EventBusRedis:
+		if cfg.RedisURL == "" {
+			problems = append(problems, "event_bus redis needs redis_url")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown event_bus %q", cfg.EventBus))
+	}
+	switch cfg.SanitizeMode {
+	case config.SanitizeOff, config.SanitizeEscape, config.SanitizeStrict:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown sanitize_mode %q, every submission is refused", cfg.SanitizeMode))
+	}
+	if len(problems) > 0 {
+		r.add("config", CheckFail, strings.Join(problems, "; "))
+	} else {
+		r.add("config", CheckOK, "")
+	}
+
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		_, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		r.addError("tls", err, cfg.TLSCertFile)
+	}
+	if cfg.GeoIPDatabase != "" {
+		_, err := os.Stat(cfg.GeoIPDatabase)
+		r.addError("geoip_database", err, cfg.GeoIPDatabase)
+	}
+	if cfg.OIDCIssuer != "" {
+		r.addError("admin_sso", sso.Validate(cfg), cfg.OIDCIssuer)
+	}
+	if cfg.AdminToken == "" && len(cfg.ServiceAccounts) == 0 && cfg.OIDCIssuer == "" {
+		r.add("admin_auth", CheckWarn, "no admin_token, service accounts or oidc_issuer, admin endpoints are disabled")
+	}
+}
+
+// checkEventBus adds whether the event bus, and for JetStream the declared streams,
+// can be used; without them history and persistence are disabled.
+func (r *StartupReport) checkEventBus(cfg config.Config, nc *nats.Conn, js nats.JetStreamContext, bus eventbus.EventBus, status *connectionStatus, streamNames []string) {
+	if cfg.EventBus == config.EventBusRedis {
+		if bus == nil {
+			r.add("event_bus", CheckFail, "Redis unreachable at "+cfg.RedisURL)
+		} else {
+			r.add("event_bus", CheckOK, "redis")
+		}
+		return
+	}
+	switch {
+	case nc == nil:
+		detail := "unreachable at " + cfg.NatsURL
+		if err := status.err(); err != nil {
+			detail += ": " + err.Error()
+		}
+		r.add("nats", CheckFail, detail)
+		return
+	case js == nil:
+		r.add("nats", CheckOK, nc.ConnectedUrl())
+		r.add("jetstream", CheckFail, "JetStream is not enabled on the server")
+		return
+	}
+	r.add("nats", CheckOK, nc.ConnectedUrl())
+	r.add("jetstream", CheckOK, "")
+
+	var missing []string
+	for _, name := range streamNames {
+		if _, err := js.StreamInfo(cfg.ResourceName(name)); err != nil {
+			if !errors.Is(err, nats.ErrStreamNotFound) {
+				r.add("streams", CheckFail, fmt.Sprintf("%s: %v", cfg.ResourceName(name), err))
+				return
+			}
+			missing = append(missing, cfg.ResourceName(name))
+		}
+	}
+	switch {
+	case len(missing) == 0:
+		r.add("streams", CheckOK, strings.Join(streamNames, ", "))
+	case cfg.StreamsDryRun:
+		r.add("streams", CheckWarn, "not created with streams_dry_run: "+strings.Join(missing, ", "))
+	default:
+		r.add("streams", CheckFail, "could not be created: "+strings.Join(missing, ", "))
+	}
+}
+
+// listen opens a listener for the self-check, so a port in use is reported before the
+// server starts rather than when it begins serving.
+func (r *StartupReport) listen(cfg config.Config, name, addr string) net.Listener {
+	listener, err := listen(cfg, addr)
+	r.addError(name, err, addr)
+	return listener
+}
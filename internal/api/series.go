@@ -0,0 +1,44 @@
+// internal/api/series.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+)
+
+// seriesProvider is implemented by hubs that run best-of series.
+type seriesProvider interface {
+	Series(id string) (hub.Series, error)
+}
+
+// seriesHandler serves GET /api/series/{id}, where {id} may be "current".
+func seriesHandler(provider seriesProvider, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/series/")
+		if id == "" || strings.Contains(id, "/") {
+			http.Error(w, "Expected /api/series/{id}", http.StatusBadRequest)
+			return
+		}
+		series, err := provider.Series(id)
+		if errors.Is(err, hub.ErrSeriesNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serverLogger.Errorf("Error reading series %s: %v", id, err)
+			http.Error(w, "Error retrieving series", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(series)
+	}
+}
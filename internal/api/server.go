@@ -0,0 +1,82 @@
+// internal/api/server.go
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/erilali/internal/config"
+)
+
+// Tuning defaults, used when the corresponding setting is 0.
+const (
+	// defaultReadTimeout bounds reading a whole request including its body, long enough
+	// for uploads over slow links.
+	defaultReadTimeout = 30 * time.Second
+	// defaultReadHeaderTimeout bounds reading request headers, which is what stops
+	// slowloris style clients; bodies get the full read timeout.
+	defaultReadHeaderTimeout = 10 * time.Second
+	// defaultWriteTimeout bounds writing a response. The bulk export moves it forward for
+	// every round it streams. Upgraded WebSockets are not affected, their deadlines are
+	// reset on hijack.
+	defaultWriteTimeout = 60 * time.Second
+	// defaultRequestTimeout is the deadline of a request's context, which ends event bus
+	// reads that would outlast it with a 504. It stays below the write timeout.
+	defaultRequestTimeout = 15 * time.Second
+	// defaultIdleTimeout closes keep-alive connections without a request in flight.
+	defaultIdleTimeout = 120 * time.Second
+	// defaultMaxHeaderBytes matches net/http's own default.
+	defaultMaxHeaderBytes = 1 << 20
+	// defaultTCPKeepAlive is the TCP keep-alive probe interval on accepted connections,
+	// which detects vanished peers below the WebSocket ping.
+	defaultTCPKeepAlive = 30 * time.Second
+)
+
+// secondsOr converts a setting in seconds, using fallback for 0.
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds == 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newHTTPServer builds a server for handler with the configured timeouts and limits.
+// Negative timeouts disable them. HTTP/2 is negotiated over TLS unless http2 is off;
+// cleartext listeners always speak HTTP/1.1.
+func newHTTPServer(cfg config.Config, addr string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       secondsOr(cfg.HTTPReadTimeoutSeconds, defaultReadTimeout),
+		ReadHeaderTimeout: secondsOr(cfg.HTTPReadHeaderTimeoutSeconds, defaultReadHeaderTimeout),
+		WriteTimeout:      secondsOr(cfg.HTTPWriteTimeoutSeconds, defaultWriteTimeout),
+		IdleTimeout:       secondsOr(cfg.HTTPIdleTimeoutSeconds, defaultIdleTimeout),
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+	}
+	if server.MaxHeaderBytes <= 0 {
+		server.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+	if !cfg.HTTP2 {
+		// A non-nil empty map turns off the automatic HTTP/2 support.
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	return server
+}
+
+// listen opens a TCP listener on addr with the configured keep-alive.
+func listen(cfg config.Config, addr string) (net.Listener, error) {
+	listenConfig := net.ListenConfig{KeepAlive: secondsOr(cfg.TCPKeepAliveSeconds, defaultTCPKeepAlive)}
+	return listenConfig.Listen(context.Background(), "tcp", addr)
+}
+
+// serve serves HTTP, or HTTPS with tls_cert_file, on a listener opened by listen. It
+// only returns on error.
+func serve(cfg config.Config, server *http.Server, listener net.Listener) error {
+	if cfg.TLSCertFile != "" {
+		return server.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return server.Serve(listener)
+}
@@ -0,0 +1,106 @@
+// internal/api/sso.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	hubpkg "github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/sso"
+)
+
+// ssoPathPrefix holds the login routes, which requireAdmin lets through unauthenticated.
+const ssoPathPrefix = "/api/admin/sso/"
+
+// auditRecorder is implemented by hubs that write the audit log.
+type auditRecorder interface {
+	Audit(event, username, msg, detail string)
+}
+
+// ssoLoginHandler serves GET /api/admin/sso/login: it redirects to the identity provider,
+// which sends the operator back to /api/admin/sso/callback. ?return= names the admin path
+// to open once signed in.
+func ssoLoginHandler(provider *sso.Provider, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := provider.StartLogin(w, r, r.URL.Query().Get("return")); err != nil {
+			log.Errorf("Starting an SSO login: %v", err)
+			http.Error(w, "Identity provider unavailable", http.StatusBadGateway)
+		}
+	}
+}
+
+// ssoCallbackHandler serves GET /api/admin/sso/callback: it completes the login, sets the
+// session cookie and records the login in the audit log. Operators none of whose groups
+// maps to a role are refused with 403.
+func ssoCallbackHandler(provider *sso.Provider, auditor auditRecorder, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		session, returnTo, err := provider.FinishLogin(w, r)
+		switch {
+		case errors.Is(err, sso.ErrInvalidFlow):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, sso.ErrNoRole):
+			log.Warnf("SSO login refused for %s: %v", session.Name, err)
+			if auditor != nil {
+				auditor.Audit(hubpkg.AuditAdminLogin, session.Name, "Admin login refused", err.Error())
+			}
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			log.Errorf("Completing an SSO login: %v", err)
+			http.Error(w, "Login failed", http.StatusBadGateway)
+			return
+		}
+		if auditor != nil {
+			auditor.Audit(hubpkg.AuditAdminLogin, session.Name, "Admin signed in", fmt.Sprintf("role=%s subject=%s", session.Role, session.Subject))
+		}
+		http.Redirect(w, r, returnTo, http.StatusFound)
+	}
+}
+
+// ssoLogoutHandler serves POST /api/admin/sso/logout.
+func ssoLogoutHandler(provider *sso.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		provider.Logout(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ssoSessionHandler serves GET /api/admin/sso/session: the signed-in operator and role,
+// or 401 without a session.
+func ssoSessionHandler(provider *sso.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		session, ok := provider.SessionFromRequest(r)
+		if !ok {
+			http.Error(w, "Not signed in", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":    session.Name,
+			"subject": session.Subject,
+			"role":    session.Role,
+			"expires": session.Expires.UTC().Format(time.RFC3339),
+		})
+	}
+}
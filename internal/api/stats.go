@@ -0,0 +1,52 @@
+// internal/api/stats.go
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erilali/internal/hub"
+)
+
+// roundStatsProvider is implemented by hubs that keep round statistics.
+type roundStatsProvider interface {
+	RoundStatistics(ctx context.Context, since, until time.Time) hub.RoundStats
+}
+
+// statsHandler serves GET /api/stats with optional since/until filters
+// given as RFC3339 timestamps or unix seconds.
+func statsHandler(provider roundStatsProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		since, err := parseTimeParam(r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		until, err := parseTimeParam(r.URL.Query().Get("until"))
+		if err != nil {
+			http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(provider.RoundStatistics(r.Context(), since, until))
+	}
+}
+
+// parseTimeParam accepts an RFC3339 timestamp or unix seconds. An empty value yields the zero time.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
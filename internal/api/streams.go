@@ -0,0 +1,102 @@
+// internal/api/streams.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/streams"
+)
+
+// defaultStreamSpec declares the streams the hub publishes to. It is used when the
+// streams_file does not exist.
+func defaultStreamSpec() streams.Spec {
+	return streams.Spec{Streams: []streams.StreamSpec{
+		{Name: "ROUNDS", Subjects: []string{"rounds.started.*", "rounds.ended.*"}, MaxAge: historyRetention},
+		{Name: "MESSAGES", Subjects: []string{"messages.*"}, MaxAge: historyRetention},
+		{Name: "WINNERS", Subjects: []string{"winners.*"}, MaxAge: historyRetention},
+		{Name: "REACTIONS", Subjects: []string{"reactions.*"}, MaxAge: historyRetention},
+		{Name: "AUDIT", Subjects: []string{"audit.*"}, MaxAge: auditRetention},
+		{Name: "ROUND_SUMMARY", Subjects: []string{"round_summary.*"}, MaxAge: summaryRetention},
+	}}
+}
+
+// loadStreamSpec reads the streams_file, falling back to the built-in streams when it
+// is not set or does not exist.
+func loadStreamSpec(cfg config.Config) (streams.Spec, error) {
+	if cfg.StreamsFile == "" {
+		return defaultStreamSpec(), nil
+	}
+	spec, err := streams.Load(cfg.StreamsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultStreamSpec(), nil
+	}
+	return spec, err
+}
+
+// reconcileStreams brings the JetStream streams and consumers in line with spec. With
+// streams_dry_run the changes are only logged.
+func reconcileStreams(cfg config.Config, domains streams.Domains, spec streams.Spec, serverLogger *logger.Logger) {
+	changes := streams.Plan(domains, spec, cfg)
+	if cfg.StreamsDryRun {
+		for _, change := range changes {
+			if change.Action != streams.ActionNone {
+				serverLogger.Infof("Stream spec dry run: %s", change)
+			}
+		}
+		serverLogger.Infof("Stream spec dry run: %d of %d streams and consumers would change", pendingChanges(changes), len(changes))
+		return
+	}
+	for _, change := range streams.Apply(domains, changes) {
+		switch {
+		case change.Action == streams.ActionNone:
+		case change.Action == streams.ActionIncompatible:
+			serverLogger.Errorf("Stream spec: %s; recreate it by hand to apply the spec", change)
+		case change.Error != "":
+			serverLogger.Errorf("Stream spec: %s", change)
+		default:
+			serverLogger.Infof("Stream spec: %s", change)
+		}
+	}
+}
+
+// pendingChanges counts the changes of a plan that are not no-ops.
+func pendingChanges(changes []streams.Change) int {
+	pending := 0
+	for _, change := range changes {
+		if change.Action != streams.ActionNone {
+			pending++
+		}
+	}
+	return pending
+}
+
+// adminStreamsHandler serves GET /api/admin/streams, a dry run of the streams_file
+// against the server. The file is read again on every request, so an edit can be
+// checked before restarting.
+func adminStreamsHandler(cfg config.Config, domains streams.Domains) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		spec, err := loadStreamSpec(cfg)
+		if err != nil {
+			http.Error(w, "Invalid stream spec: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		changes := streams.Plan(domains, spec, cfg)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"file":      cfg.StreamsFile,
+			"changes":   changes,
+			"pending":   pendingChanges(changes),
+			"timestamp": time.Now(),
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// internal/api/tournaments.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+)
+
+// tournamentProvider is implemented by hubs that run tournaments.
+type tournamentProvider interface {
+	Tournament(id string) (hub.Tournament, error)
+}
+
+// tournamentHandler serves GET /api/tournaments/{id}, where {id} may be "current".
+func tournamentHandler(provider tournamentProvider, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/tournaments/")
+		if id == "" || strings.Contains(id, "/") {
+			http.Error(w, "Expected /api/tournaments/{id}", http.StatusBadRequest)
+			return
+		}
+		tournament, err := provider.Tournament(id)
+		if errors.Is(err, hub.ErrTournamentNotFound) {
+			http.Error(w, "Tournament not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serverLogger.Errorf("Error reading tournament %s: %v", id, err)
+			http.Error(w, "Error retrieving tournament", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tournament)
+	}
+}
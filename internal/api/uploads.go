@@ -0,0 +1,124 @@
+// internal/api/uploads.go
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/erilali/internal/attachments"
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+)
+
+// multipartOverhead is the room left for form boundaries and headers on top of the file size limit.
+const multipartOverhead = 64 << 10
+
+// attachmentStoreProvider is implemented by hubs that accept media attachments.
+type attachmentStoreProvider interface {
+	AttachmentStore() *attachments.Store
+	AttachmentUploader(resumeToken string) (string, error)
+}
+
+var _ attachmentStoreProvider = (*hub.Hub)(nil)
+
+// uploadsHandler serves POST /api/uploads and GET /api/uploads/{id}.
+func uploadsHandler(cfg config.Config, provider attachmentStoreProvider, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/uploads"), "/")
+		switch {
+		case id == "" && r.Method == http.MethodPost:
+			uploadAttachment(cfg, provider, serverLogger, w, r)
+		case id != "" && r.Method == http.MethodGet:
+			serveAttachment(provider.AttachmentStore(), id, serverLogger, w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// uploadAttachment stores the multipart "file" field and returns its attachment ID.
+// The uploader is the player whose resume token is sent as "Authorization: Bearer".
+// The content type is sniffed from the data rather than trusted from the client.
+func uploadAttachment(cfg config.Config, provider attachmentStoreProvider, serverLogger *logger.Logger, w http.ResponseWriter, r *http.Request) {
+	username, err := provider.AttachmentUploader(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.UploadMaxBytes+multipartOverhead)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Attachment too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Expected a multipart form with a file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > cfg.UploadMaxBytes {
+		http.Error(w, "Attachment too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		http.Error(w, "Empty attachment", http.StatusBadRequest)
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !slices.Contains(cfg.UploadContentTypes, contentType) {
+		http.Error(w, "Unsupported attachment type "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	id, err := provider.AttachmentStore().Put(contentType, username, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		serverLogger.Errorf("Error storing attachment from %s: %v", username, err)
+		http.Error(w, "Error storing attachment", http.StatusInternalServerError)
+		return
+	}
+	serverLogger.Infof("Stored attachment %s (%s, %d bytes) from %s", id, contentType, header.Size, username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":           id,
+		"url":          attachments.URL(id),
+		"content_type": contentType,
+		"size":         header.Size,
+	})
+}
+
+// serveAttachment streams a stored attachment back with its content type.
+func serveAttachment(store *attachments.Store, id string, serverLogger *logger.Logger, w http.ResponseWriter, r *http.Request) {
+	reader, contentType, err := store.Get(id)
+	if errors.Is(err, attachments.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		serverLogger.Errorf("Error reading attachment %s: %v", id, err)
+		http.Error(w, "Error retrieving attachment", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	if _, err := io.Copy(w, reader); err != nil {
+		serverLogger.Debugf("Error streaming attachment %s: %v", id, err)
+	}
+}
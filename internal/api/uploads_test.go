@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erilali/internal/attachments"
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+)
+
+// fakeUploads accepts only the resume token "valid", for ada.
+type fakeUploads struct{}
+
+func (fakeUploads) AttachmentStore() *attachments.Store { return nil }
+
+func (fakeUploads) AttachmentUploader(token string) (string, error) {
+	if token != "valid" {
+		return "", hub.ErrUploadAuth
+	}
+	return "ada", nil
+}
+
+func TestUploadRequiresResumeToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer forged", http.StatusUnauthorized},
+		// Past authentication the empty body is rejected as a malformed form.
+		{"valid token", "Bearer valid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/uploads?username=ada", strings.NewReader(""))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			uploadsHandler(config.DefaultConfig(), fakeUploads{}, logger.NewLogger("test"))(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
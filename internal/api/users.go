@@ -0,0 +1,217 @@
+// internal/api/users.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/erilali/internal/hub"
+	"github.com/erilali/internal/logger"
+)
+
+// pointsProvider is implemented by hubs that expose winner point balances.
+type pointsProvider interface {
+	UserPoints(username string) (int64, error)
+}
+
+// userStatsProvider is implemented by hubs that keep lifetime user statistics.
+type userStatsProvider interface {
+	UserStats(username string) (hub.UserStats, error)
+}
+
+// preferencesProvider is implemented by hubs that store client preferences per user.
+type preferencesProvider interface {
+	UserPreferences(username string) (hub.UserPreferences, error)
+	SetUserPreferences(username string, data []byte) (hub.UserPreferences, error)
+}
+
+// userDataEraser is implemented by hubs that can erase everything stored about a user.
+type userDataEraser interface {
+	EraseUserData(username, actor string) (hub.ErasureReport, error)
+}
+
+// preferencesBodyLimit bounds the request bodies read before the hub applies
+// preferences_max_bytes, which counts the compacted JSON.
+const preferencesBodyLimit = 64 << 10
+
+// usersHandler routes /api/users/{username}/{resource} requests.
+func usersHandler(h interface{}, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/users/")
+		username, resource, ok := strings.Cut(rest, "/")
+		if !ok || username == "" {
+			http.Error(w, "Expected /api/users/{username}/{resource}", http.StatusBadRequest)
+			return
+		}
+
+		switch resource {
+		case "points":
+			provider, ok := h.(pointsProvider)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			userPointsHandler(provider, username, serverLogger)(w, r)
+		case "stats":
+			provider, ok := h.(userStatsProvider)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			userStatsHandler(provider, username, serverLogger)(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// adminUsersHandler routes /api/admin/users/{username}/{resource} requests, which act on
+// a user's data on the operator's authority.
+func adminUsersHandler(h interface{}, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
+		username, resource, ok := strings.Cut(rest, "/")
+		if !ok || username == "" {
+			http.Error(w, "Expected /api/admin/users/{username}/{resource}", http.StatusBadRequest)
+			return
+		}
+
+		switch resource {
+		case "preferences":
+			provider, ok := h.(preferencesProvider)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			userPreferencesHandler(provider, username, serverLogger)(w, r)
+		case "data":
+			eraser, ok := h.(userDataEraser)
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			userDataHandler(eraser, username, serverLogger)(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// userPointsHandler serves GET /api/users/{username}/points.
+func userPointsHandler(provider pointsProvider, username string, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		points, err := provider.UserPoints(username)
+		if errors.Is(err, hub.ErrPointsUnavailable) {
+			http.Error(w, "Points are tracked by an external system", http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			serverLogger.Errorf("Error reading points for %s: %v", username, err)
+			http.Error(w, "Error retrieving points", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"username": username,
+			"points":   points,
+		})
+	}
+}
+
+// userStatsHandler serves GET /api/users/{username}/stats.
+func userStatsHandler(provider userStatsProvider, username string, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		stats, err := provider.UserStats(username)
+		if errors.Is(err, hub.ErrUserStatsNotFound) {
+			http.Error(w, "No statistics for this user", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serverLogger.Errorf("Error reading statistics for %s: %v", username, err)
+			http.Error(w, "Error retrieving statistics", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// userPreferencesHandler serves GET and PUT /api/admin/users/{username}/preferences. PUT
+// replaces the stored preferences with the JSON object in the body. Usernames are not
+// bound to an identity, so only operators may read or change them.
+func userPreferencesHandler(provider preferencesProvider, username string, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var prefs hub.UserPreferences
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			prefs, err = provider.UserPreferences(username)
+		case http.MethodPut:
+			data, readErr := io.ReadAll(http.MaxBytesReader(w, r.Body, preferencesBodyLimit))
+			if readErr != nil {
+				http.Error(w, "Preferences are too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			prefs, err = provider.SetUserPreferences(username, data)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch {
+		case errors.Is(err, hub.ErrPreferencesTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, hub.ErrInvalidPreferences), errors.Is(err, hub.ErrInvalidUsername):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, hub.ErrGuestPreferences):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			serverLogger.Errorf("Error accessing preferences of %s: %v", username, err)
+			http.Error(w, "Error accessing preferences", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(prefs)
+	}
+}
+
+// userDataHandler serves DELETE /api/admin/users/{username}/data, which erases the user's
+// submissions, wins, statistics, preferences and points. It answers 202 while archived
+// rounds are still being rewritten and 200 once everything is erased. Usernames are not
+// bound to an identity, so users cannot erase their own data; operators do it for them.
+func userDataHandler(eraser userDataEraser, username string, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		report, err := eraser.EraseUserData(username, adminActor(r))
+		if err != nil {
+			serverLogger.Errorf("Error erasing data of %s: %v", username, err)
+			http.Error(w, "Error erasing user data, retry to erase what remains", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.ArchivesPending {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
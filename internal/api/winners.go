@@ -0,0 +1,130 @@
+// internal/api/winners.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/logger"
+)
+
+const (
+	winnersHistoryLimit = 10000 // most recent winners read
+	winnersDefaultLimit = 50
+	winnersMaxLimit     = 500
+)
+
+// winnerRecord is a winner as published on the WINNERS stream.
+type winnerRecord struct {
+	RoundID       int64  `json:"round_id"`
+	MessageID     string `json:"message_id,omitempty"`
+	Username      string `json:"username"`
+	Content       string `json:"content"`
+	AttachmentURL string `json:"attachment_url,omitempty"`
+	Timestamp     int64  `json:"timestamp"`
+	Supersedes    string `json:"supersedes,omitempty"` // message ID of a winner invalidated on appeal
+}
+
+// winnersHandler serves GET /api/winners?since=&until=&username=&limit=&offset=: every
+// recorded winner, newest first. since and until filter on the selection time.
+// Appeal corrections replace the winner they supersede.
+func winnersHandler(bus eventbus.EventBus, serverLogger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if bus == nil {
+			http.Error(w, "Event bus not available", http.StatusServiceUnavailable)
+			return
+		}
+		query := r.URL.Query()
+		since, err := parseTimeParam(query.Get("since"))
+		if err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		until, err := parseTimeParam(query.Get("until"))
+		if err != nil {
+			http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+			return
+		}
+		limit := winnersDefaultLimit
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > winnersMaxLimit {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		offset := 0
+		if v := query.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+				return
+			}
+			offset = n
+		}
+		username := query.Get("username")
+
+		// Winners are published when selected, so the read is bounded by since and until
+		// and keeps the most recent winnersHistoryLimit of them.
+		events, err := historyTail(r.Context(), bus, "winners.*", since, until, winnersHistoryLimit, apiConsumerFetchMaxWait)
+		if err != nil && !errors.Is(err, eventbus.ErrHistoryIncomplete) {
+			serverLogger.Errorf("Error reading winners: %v", err)
+			writeHistoryError(w, err, "Error retrieving winners")
+			return
+		}
+		var current []winnerRecord
+		for _, event := range events {
+			var winner winnerRecord
+			if err := json.Unmarshal(event.Data, &winner); err != nil {
+				serverLogger.Errorf("Error unmarshaling winner: %v", err)
+				continue
+			}
+			if winner.Supersedes != "" {
+				current = slices.DeleteFunc(current, func(w winnerRecord) bool { return w.RoundID == winner.RoundID })
+				if winner.Username == "" {
+					continue
+				}
+			}
+			current = append(current, winner)
+		}
+		winners := []winnerRecord{}
+		for _, winner := range current {
+			if username != "" && winner.Username != username {
+				continue
+			}
+			if !since.IsZero() && winner.Timestamp < since.Unix() {
+				continue
+			}
+			if !until.IsZero() && winner.Timestamp > until.Unix() {
+				continue
+			}
+			winners = append(winners, winner)
+		}
+		sort.SliceStable(winners, func(i, j int) bool { return winners[i].Timestamp > winners[j].Timestamp })
+
+		total := len(winners)
+		page := winners[min(offset, total):min(offset+limit, total)]
+		response := map[string]interface{}{
+			"winners": page,
+			"count":   len(page),
+			"total":   total,
+			"offset":  offset,
+			"limit":   limit,
+		}
+		if offset+limit < total {
+			response["next_offset"] = offset + limit
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,267 @@
+// internal/archive/s3.go
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	requestTimeout  = 30 * time.Second
+	defaultRegion   = "us-east-1"
+	lifecycleRuleID = "round-archive-expiry"
+	amzDateFormat   = "20060102T150405Z"
+	amzDayFormat    = "20060102"
+)
+
+// ErrNoBucket is returned by NewS3Store when no bucket is configured.
+var ErrNoBucket = errors.New("archive bucket is not configured")
+
+// S3Store reads and writes objects in an S3-compatible object store such as AWS S3 or MinIO.
+// Requests use path-style addressing and are signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3Store creates a store for bucket at endpoint, for example https://s3.eu-west-1.amazonaws.com
+// or http://minio:9000. An empty region selects us-east-1, which MinIO accepts by default.
+func NewS3Store(endpoint, region, bucket, accessKey, secretKey string) (*S3Store, error) {
+	if bucket == "" {
+		return nil, ErrNoBucket
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing archive endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("archive endpoint %q must be an http or https URL", endpoint)
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+	return &S3Store{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Bucket returns the name of the bucket objects are written to.
+func (s *S3Store) Bucket() string {
+	return s.bucket
+}
+
+// Put stores body under key, replacing an existing object.
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	return s.do(ctx, http.MethodPut, "/"+key, "", header, body)
+}
+
+// Get returns the object stored under key.
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.request(ctx, http.MethodGet, "/"+key, "", http.Header{}, nil)
+}
+
+// listBucketResult is the body of a ListObjectsV2 response.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the keys of every object under prefix, following continuation tokens.
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		// Signature Version 4 expects spaces as %20, which Encode writes as +.
+		body, err := s.request(ctx, http.MethodGet, "/", strings.ReplaceAll(query.Encode(), "+", "%20"), http.Header{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("decoding object list: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// lifecycleConfiguration is the body of a PutBucketLifecycleConfiguration request.
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+type lifecycleRule struct {
+	ID         string `xml:"ID"`
+	Prefix     string `xml:"Filter>Prefix"`
+	Status     string `xml:"Status"`
+	Expiration struct {
+		Days int `xml:"Days"`
+	} `xml:"Expiration"`
+}
+
+// SetExpiration installs a lifecycle rule that deletes objects under prefix days after
+// they were written. The rule replaces the lifecycle configuration of the bucket, so
+// buckets shared with other lifecycle rules should be configured by their owner instead.
+func (s *S3Store) SetExpiration(ctx context.Context, prefix string, days int) error {
+	rule := lifecycleRule{ID: lifecycleRuleID, Prefix: prefix, Status: "Enabled"}
+	rule.Expiration.Days = days
+	body, err := xml.Marshal(lifecycleConfiguration{Rules: []lifecycleRule{rule}})
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(body)
+	header := http.Header{}
+	header.Set("Content-Type", "application/xml")
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	return s.do(ctx, http.MethodPut, "/", "lifecycle=", header, body)
+}
+
+// do sends a signed request for path within the bucket and expects a 2xx response.
+func (s *S3Store) do(ctx context.Context, method, path, rawQuery string, header http.Header, body []byte) error {
+	_, err := s.request(ctx, method, path, rawQuery, header, body)
+	return err
+}
+
+// request is do returning the response body.
+func (s *S3Store) request(ctx context.Context, method, path, rawQuery string, header http.Header, body []byte) ([]byte, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + path
+	u.RawPath = uriEncode(u.Path)
+	u.RawQuery = rawQuery
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling object store: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("object store returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading object store response: %w", err)
+	}
+	return data, nil
+}
+
+// sign adds the AWS Signature Version 4 headers for a request made at now. Without
+// credentials the request is sent unsigned, which buckets with a public write policy accept.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.accessKey == "" {
+		return
+	}
+
+	names := []string{"host"}
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.Host
+		if name != "host" {
+			value = strings.Join(req.Header.Values(name), ",")
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := now.Format(amzDayFormat) + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format(amzDayFormat))
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode escapes a path the way Signature Version 4 expects: every byte except
+// unreserved characters and slashes is percent-encoded.
+func uriEncode(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
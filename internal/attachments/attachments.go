@@ -0,0 +1,87 @@
+// internal/attachments/attachments.go
+// Stores media attachments for submissions in a JetStream Object Store bucket.
+package attachments
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/oklog/ulid/v2"
+)
+
+// ErrNotFound is returned when no attachment exists for an ID.
+var ErrNotFound = errors.New("attachment not found")
+
+// Store keeps uploaded blobs keyed by a server-assigned ULID.
+type Store struct {
+	obs nats.ObjectStore
+}
+
+// NewStore opens the bucket, creating it if it does not exist yet.
+// Attachments expire after ttl; zero keeps them until the bucket is purged.
+func NewStore(js nats.JetStreamContext, bucket string, ttl time.Duration) (*Store, error) {
+	obs, err := js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) || errors.Is(err, nats.ErrBucketNotFound) {
+		obs, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      bucket,
+			Description: "Submission attachments",
+			TTL:         ttl,
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening attachment bucket %s: %w", bucket, err)
+	}
+	return &Store{obs: obs}, nil
+}
+
+// Put stores a blob with its content type and returns the new attachment ID.
+func (s *Store) Put(contentType, uploader string, r io.Reader) (string, error) {
+	id := ulid.Make().String()
+	meta := &nats.ObjectMeta{
+		Name: id,
+		Headers: nats.Header{
+			"Content-Type": []string{contentType},
+			"Uploader":     []string{uploader},
+		},
+	}
+	if _, err := s.obs.Put(meta, r); err != nil {
+		return "", fmt.Errorf("storing attachment: %w", err)
+	}
+	return id, nil
+}
+
+// Get opens an attachment for reading together with its content type.
+// The caller must close the returned reader.
+func (s *Store) Get(id string) (io.ReadCloser, string, error) {
+	result, err := s.obs.Get(id)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("reading attachment %s: %w", id, err)
+	}
+	info, err := result.Info()
+	if err != nil {
+		result.Close()
+		return nil, "", fmt.Errorf("reading attachment %s: %w", id, err)
+	}
+	return result, info.Headers.Get("Content-Type"), nil
+}
+
+// Exists reports whether an attachment with the given ID is stored.
+func (s *Store) Exists(id string) bool {
+	if id == "" {
+		return false
+	}
+	_, err := s.obs.GetInfo(id)
+	return err == nil
+}
+
+// URL returns the API path an attachment is served from.
+func URL(id string) string {
+	return "/api/uploads/" + id
+}
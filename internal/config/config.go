@@ -0,0 +1,546 @@
+// internal/config/config.go
+// Contains the server configuration loaded at startup.
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	EventBusJetStream = "jetstream"
+	EventBusRedis     = "redis"
+
+	RewardsKV      = "kv"
+	RewardsWebhook = "webhook"
+	RewardsNone    = "none"
+
+	SanitizeOff    = "off"
+	SanitizeEscape = "escape"
+	SanitizeStrict = "strict"
+
+	RoundModeFree    = "free"
+	RoundModeChoices = "choices"
+
+	DuplicatesOff    = "off"
+	DuplicatesReject = "reject"
+	DuplicatesGroup  = "group"
+
+	ScopeRead   = "read"   // observe rounds over /ws and read the admin API
+	ScopeSubmit = "submit" // also submit, edit, withdraw and react
+	ScopeAdmin  = "admin"  // also change state through the admin API
+)
+
+// scopeRank orders scopes; each includes the ones ranked below it.
+var scopeRank = map[string]int{ScopeRead: 1, ScopeSubmit: 2, ScopeAdmin: 3}
+
+// ServiceAccount is the token of an automated client. Bots connect to /ws with the token
+// as a bearer token and play under the account name, within the account's scope.
+type ServiceAccount struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Scope string `json:"scope"` // read, submit or admin
+}
+
+// Allows reports whether the account's scope includes scope.
+func (a ServiceAccount) Allows(scope string) bool {
+	return scopeRank[scope] > 0 && scopeRank[a.Scope] >= scopeRank[scope]
+}
+
+// ChoiceSet is a prompt with a fixed set of options played by a round in choices mode.
+type ChoiceSet struct {
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options"`
+	Answer  *int     `json:"answer,omitempty"` // index of the correct option, nil to reward the most popular one
+}
+
+// Built-in pacing profiles.
+const (
+	PacingBlitz    = "blitz"
+	PacingNormal   = "normal"
+	PacingMarathon = "marathon"
+)
+
+// PacingProfile bundles the timing of rounds under a name.
+type PacingProfile struct {
+	RoundDurationSeconds    int `json:"round_duration_seconds"`
+	SubmissionWindowSeconds int `json:"submission_window_seconds"` // 0 keeps submissions open for the whole round
+	PauseSeconds            int `json:"pause_seconds"`             // between rounds
+}
+
+// UsernamePolicy decides which names players may connect and sign in with.
+type UsernamePolicy struct {
+	MinLength       int      `json:"min_length"` // in characters, after normalization
+	MaxLength       int      `json:"max_length"`
+	Categories      []string `json:"categories"`       // Unicode categories allowed, e.g. "L", "Nd" or "Mn"; empty allows ASCII letters and digits
+	ExtraCharacters string   `json:"extra_characters"` // characters allowed besides the categories
+	NormalizeNFKC   bool     `json:"normalize_nfkc"`   // fold compatibility forms such as full-width letters before checking
+	Reserved        []string `json:"reserved"`         // names nobody may use, compared case-insensitively
+	CaseInsensitive bool     `json:"case_insensitive"` // names differing only in case belong to the same user for uniqueness, bans and kicks
+}
+
+// WinnerScoring weighs the odds of winner selection by submission scores.
+type WinnerScoring struct {
+	Enabled           bool    `json:"enabled"`            // draw winners with odds proportional to their scores instead of uniformly
+	Base              float64 `json:"base"`               // score every submission starts with, so each keeps a chance
+	LengthWeight      float64 `json:"length_weight"`      // weight of the length score, which reaches 1 at length_target characters
+	LengthTarget      int     `json:"length_target"`      // characters for the full length score
+	OriginalityWeight float64 `json:"originality_weight"` // weight of 1 minus the highest word overlap with submissions of earlier rounds
+	PluginWeight      float64 `json:"plugin_weight"`      // weight of the rules script's score relative to the round's best
+}
+
+// Config holds the server level settings.
+type Config struct {
+	EventBus string `json:"event_bus"` // jetstream or redis
+	NatsURL  string `json:"nats_url"`
+	RedisURL string `json:"redis_url"`
+
+	NatsConnectionName string `json:"nats_connection_name"`
+	InstanceID         string `json:"instance_id"`        // names this instance on the admin control plane, generated when empty
+	IDGenerator        string `json:"id_generator"`       // ulid (round IDs are start seconds) or snowflake (unique across instances)
+	SnowflakeNode      int    `json:"snowflake_node"`     // snowflake node of this instance, 1-1023; 0 derives it from instance_id
+	ControlTimeoutMs   int    `json:"control_timeout_ms"` // how long admin commands wait for replies from other instances
+	SubjectPrefix      string `json:"subject_prefix"`     // namespace for subjects, streams and buckets, e.g. "staging.game1"
+	NatsUser           string `json:"nats_user"`
+	NatsPassword       string `json:"nats_password"`
+	NatsCredsFile      string `json:"nats_creds_file"` // JWT + NKey credentials file
+	NatsNKeyFile       string `json:"nats_nkey_file"`  // NKey seed file
+	NatsTLSCert        string `json:"nats_tls_cert"`   // client certificate for mutual TLS
+	NatsTLSKey         string `json:"nats_tls_key"`
+	NatsTLSCA          string `json:"nats_tls_ca"` // CA bundle used to verify the server
+
+	StreamsFile     string `json:"streams_file"`     // YAML spec of the JetStream streams and consumers, built-in streams when missing
+	StreamsDryRun   bool   `json:"streams_dry_run"`  // log the changes the streams_file would make instead of applying them
+	StreamsReplicas int    `json:"streams_replicas"` // replicas of streams whose spec sets none, e.g. 3 on a three-node cluster; 0 means 1
+
+	StreamsMonitorSeconds     int   `json:"streams_monitor_seconds"`      // poll stream and consumer lag this often, 0 disables the monitor
+	StreamsLagWarn            int64 `json:"streams_lag_warn"`             // warn when a durable consumer has this many messages left to acknowledge, 0 disables the warning
+	StreamsStorageWarnPercent int   `json:"streams_storage_warn_percent"` // warn when a stream or the account uses this share of its storage limit, 0 disables the warning
+	StreamsReplicaLagWarn     int64 `json:"streams_replica_lag_warn"`     // warn when a stream replica or mirror trails by this many operations, 0 disables the warning
+
+	MaxConnections    int  `json:"max_connections"`     // 0 means unlimited
+	WaitingRoom       bool `json:"waiting_room"`        // queue connections instead of rejecting when full
+	WaitingRoomSize   int  `json:"waiting_room_size"`   // maximum queued connections
+	RetryAfterSeconds int  `json:"retry_after_seconds"` // Retry-After sent with 503 responses
+
+	ServiceAccounts       []ServiceAccount `json:"service_accounts"`          // bot tokens with scoped permissions
+	BotRateLimitPerSecond float64          `json:"bot_rate_limit_per_second"` // WebSocket frames and admin API requests per service account
+	BotRateLimitBurst     int              `json:"bot_rate_limit_burst"`
+
+	ListenAddr              string   `json:"listen_addr"`
+	AdminListenAddr         string   `json:"admin_listen_addr"`     // separate listener for admin routes, empty serves them on listen_addr
+	AdminToken              string   `json:"admin_token"`           // bearer token for admin endpoints, empty disables them unless a service account may use them
+	CORSAllowedOrigins      []string `json:"cors_allowed_origins"`  // origins allowed to call the game API from browsers, "*" for any
+	WebSocketAllowedOrigins []string `json:"ws_allowed_origins"`    // origins allowed to open WebSockets from browsers, empty or "*" for any
+	RateLimitPerSecond      float64  `json:"rate_limit_per_second"` // per-IP request rate on the game listener, 0 disables limiting
+	RateLimitBurst          int      `json:"rate_limit_burst"`
+	TrustedProxies          []string `json:"trusted_proxies"` // proxy IPs or CIDRs whose X-Forwarded-For is honored
+	GeoIPDatabase           string   `json:"geoip_database"`  // MaxMind country database (.mmdb), empty disables geo tagging
+
+	OIDCIssuer        string            `json:"oidc_issuer"`         // OpenID Connect provider operators sign in to the admin routes with, empty disables SSO
+	OIDCClientID      string            `json:"oidc_client_id"`      // client registered with the provider
+	OIDCClientSecret  string            `json:"oidc_client_secret"`  // empty for public clients, which rely on PKCE alone
+	OIDCRedirectURL   string            `json:"oidc_redirect_url"`   // absolute URL of /api/admin/sso/callback as the provider reaches it
+	OIDCScopes        []string          `json:"oidc_scopes"`         // requested in addition to openid, e.g. email and groups
+	OIDCGroupsClaim   string            `json:"oidc_groups_claim"`   // ID token claim listing the user's groups
+	OIDCGroupRoles    map[string]string `json:"oidc_group_roles"`    // IdP group to role, read or admin; users in no mapped group are refused
+	OIDCSessionHours  int               `json:"oidc_session_hours"`  // lifetime of an admin session cookie
+	OIDCSessionSecret string            `json:"oidc_session_secret"` // signs session cookies, share it across instances; empty generates one per start
+
+	StaticDir string `json:"static_dir"` // serve the UI from this directory instead of the assets embedded in the binary
+
+	StrictStartup bool `json:"strict_startup"` // exit with a report when a startup check fails instead of running degraded
+
+	// Session resumption. Tokens are signed with resume_secrets when set, the first signing
+	// and all verifying, otherwise with keys generated and rotated in the RESUME_KEYS bucket.
+	ResumeTokenTTLSeconds  int      `json:"resume_token_ttl_seconds"`  // how long a resume token stays valid, 0 disables resumption
+	ResumeSecrets          []string `json:"resume_secrets"`            // HMAC keys, newest first; RESUME_SECRETS holds them comma-separated
+	ResumeKeyRotationHours int      `json:"resume_key_rotation_hours"` // age at which a generated key is replaced
+
+	// HTTP server tuning, applied to the game and admin listeners. Timeouts of 0 use the
+	// defaults in internal/api/server.go, negative ones disable the timeout.
+	TLSCertFile                  string `json:"tls_cert_file"` // serve HTTPS and wss:// with this certificate, empty for cleartext
+	TLSKeyFile                   string `json:"tls_key_file"`
+	HTTP2                        bool   `json:"http2"` // negotiate HTTP/2 for the API over TLS; /ws always uses HTTP/1.1
+	HTTPReadTimeoutSeconds       int    `json:"http_read_timeout_seconds"`
+	HTTPReadHeaderTimeoutSeconds int    `json:"http_read_header_timeout_seconds"`
+	HTTPWriteTimeoutSeconds      int    `json:"http_write_timeout_seconds"`
+	HTTPIdleTimeoutSeconds       int    `json:"http_idle_timeout_seconds"`
+	HTTPRequestTimeoutSeconds    int    `json:"http_request_timeout_seconds"` // deadline of API requests, past which event bus reads answer 504
+	HTTPMaxHeaderBytes           int    `json:"http_max_header_bytes"`
+	TCPKeepAliveSeconds          int    `json:"tcp_keepalive_seconds"` // keep-alive probe interval on accepted connections
+
+	RoundDurationSeconds    int `json:"round_duration_seconds"`
+	SubmissionWindowSeconds int `json:"submission_window_seconds"` // submissions close this long after the round starts, 0 keeps them open for the whole round
+	RoundPauseSeconds       int `json:"round_pause_seconds"`       // wait between the end of a round and the start of the next, 0 starts it right away
+
+	// A pacing profile sets the three values above at once. The selected profile replaces
+	// them at startup and can be switched through PATCH /api/admin/config or per room.
+	PacingProfile  string                   `json:"pacing_profile"`  // PACING_PROFILE, empty keeps the values above
+	PacingProfiles map[string]PacingProfile `json:"pacing_profiles"` // adds to or redefines blitz, normal and marathon
+
+	MaxSubmissionsPerRound     int `json:"max_submissions_per_round"`     // close submissions once a round has this many, 0 disables the cap
+	EarlyCloseRemainingSeconds int `json:"early_close_remaining_seconds"` // after an early close, end the round this many seconds later, 0 keeps its length
+
+	MinParticipants          int `json:"min_participants"`           // void rounds in which fewer users submitted, 0 disables the minimum
+	ParticipantsGraceSeconds int `json:"participants_grace_seconds"` // reopen entries once for this long before voiding, 0 voids right away
+
+	AdaptiveRounds          bool `json:"adaptive_rounds"`            // lengthen quiet rounds and shorten busy ones, starting from round_duration_seconds
+	MinRoundDurationSeconds int  `json:"min_round_duration_seconds"` // lower bound for adaptive rounds
+	MaxRoundDurationSeconds int  `json:"max_round_duration_seconds"` // upper bound for adaptive rounds
+
+	TournamentQualifyingRounds int `json:"tournament_qualifying_rounds"` // qualifying rounds before each tournament final, 0 disables tournaments
+
+	SeriesRounds    int `json:"series_rounds"`     // consecutive rounds per best-of series, 0 disables series
+	SeriesWinPoints int `json:"series_win_points"` // series points earned by each round winner
+
+	RoundMode  string      `json:"round_mode"`  // free (any text) or choices (pick an option of choice_sets)
+	ChoiceSets []ChoiceSet `json:"choice_sets"` // option sets played in turn by rounds in choices mode
+
+	MaxRooms                  int `json:"max_rooms"`                    // private rooms that may exist at once, 0 disables POST /api/rooms
+	MaxRoomCapacity           int `json:"max_room_capacity"`            // largest capacity a room may be created with
+	MaxRoomsPerOwner          int `json:"max_rooms_per_owner"`          // rooms one player may own at once, 0 means unlimited
+	RoomCreateIntervalSeconds int `json:"room_create_interval_seconds"` // least time between two rooms created by one player
+	RoomIdleMinutes           int `json:"room_idle_minutes"`            // rooms without clients for this long are deleted, 0 keeps them
+
+	EncryptedMaxBytes int `json:"encrypted_max_bytes"` // largest decoded ciphertext of a submission in an encrypted room
+
+	MaxLobbyConnections int `json:"max_lobby_connections"` // room browser connections on /ws/lobby, 0 means unlimited
+	MaxHistoryStreams   int `json:"max_history_streams"`   // replay viewers on /ws/history, 0 means unlimited
+
+	PublishQueueSize    int `json:"publish_queue_size"`    // submission events buffered for the background publisher
+	PublishMaxRetries   int `json:"publish_max_retries"`   // attempts after the first before an event is given up
+	EventBufferSize     int `json:"event_buffer_size"`     // game events kept for resync_from requests
+	MemoryHistoryRounds int `json:"memory_history_rounds"` // finished rounds kept in memory for history while the event bus is down, 0 disables
+	HistoryConcurrency  int `json:"history_concurrency"`   // concurrent history reads (JetStream consumers) by the HTTP API, 0 means unlimited
+
+	ReplayOnStartupMinutes int `json:"replay_on_startup_minutes"` // rebuild rounds, winners and statistics from this much event history on startup, 0 disables
+
+	UsernamePolicy UsernamePolicy `json:"username_policy"`
+
+	ChaosMode bool `json:"chaos_mode"` // expose /api/admin/chaos to inject failures in resilience drills; never enable in production
+
+	GuestMode       bool `json:"guest_mode"`        // accept /ws without a username and assign a generated guest name
+	GuestsCanSubmit bool `json:"guests_can_submit"` // guests may submit messages
+	GuestsCanWin    bool `json:"guests_can_win"`    // guest submissions are eligible for winner selection
+
+	SkipIdleRounds     bool `json:"skip_idle_rounds"`     // do not run rounds while no client is connected; the first client to join starts one
+	PublishEmptyRounds bool `json:"publish_empty_rounds"` // publish round end events for rounds without submissions
+
+	DeliveryAckTimeoutSeconds int `json:"delivery_ack_timeout_seconds"` // wait for delivery_ack before one retransmit of round_start and winner_announcement
+
+	WinnerAppealWindowSeconds int `json:"winner_appeal_window_seconds"` // how long after selection an admin may invalidate a winner, 0 disables appeals
+	WinnerCooldownRounds      int `json:"winner_cooldown_rounds"`       // rounds after a win in which the winner cannot win again unless every candidate is cooling down, 0 disables
+
+	RoundTags         []string `json:"round_tags"`          // tags every round is given, e.g. a theme or event name, unless an admin retags it
+	SearchIndexRounds int      `json:"search_index_rounds"` // finished rounds /api/search covers, the most recent kept in memory, 0 disables search
+
+	NudgeAtPercent int `json:"nudge_at_percent"` // remind clients that have not submitted once this share of the submission window passed, 0 disables nudges
+
+	LatencyPingSeconds int `json:"latency_ping_seconds"` // interval of application level pings, 0 disables them
+	TimeSyncSeconds    int `json:"time_sync_seconds"`    // interval of time_sync broadcasts for client timers, 0 sends them on request only
+	MaxLatencyMs       int `json:"max_latency_ms"`       // disconnect clients above this RTT, 0 disables the check
+
+	ParticipantsIntervalMs int `json:"participants_interval_ms"` // broadcast user_joined and user_left at most this often, 0 disables them
+
+	AdaptivePing      bool `json:"adaptive_ping"`        // adapt each client's WebSocket ping interval and read deadline to its pongs
+	PingMinSeconds    int  `json:"ping_min_seconds"`     // shortest adaptive ping interval, used while pongs are slow
+	PingMaxSeconds    int  `json:"ping_max_seconds"`     // longest adaptive ping interval, reached while the connection is stable
+	SlowPongMs        int  `json:"slow_pong_ms"`         // a pong slower than this halves the interval and marks the connection degraded
+	StablePongsToGrow int  `json:"stable_pongs_to_grow"` // consecutive fast pongs before the interval grows by half
+
+	DeprioritizeBackgrounded bool `json:"deprioritize_backgrounded"` // send broadcasts to clients whose heartbeat says they are backgrounded last, skipping countdown, time_sync and reaction_counts
+
+	BroadcastPartitionThreshold int `json:"broadcast_partition_threshold"` // rooms with more clients split each broadcast across concurrent goroutines, 0 disables partitioning
+	BroadcastPartitions         int `json:"broadcast_partitions"`          // goroutines a partitioned broadcast uses, 0 for one per CPU
+
+	WebSocketBatchMax int `json:"ws_batch_max"` // most queued messages sent as one JSON array frame to clients that declared batch in hello, 0 or 1 disables batching
+
+	MessageDeadlineMs int `json:"message_deadline_ms"` // longest the hub works on one client frame before answering PROCESSING_TIMEOUT, 0 disables the deadline
+
+	ReactionEmojis []string `json:"reaction_emojis"` // emoji accepted as reactions during the reveal phase
+
+	WinnerScoring WinnerScoring `json:"winner_scoring"`
+
+	RulesScript    string `json:"rules_script"`     // Lua script with validate and score functions, empty for the default rules
+	RulesTimeoutMs int    `json:"rules_timeout_ms"` // longest a single rules script call may run
+
+	SanitizeMode string `json:"sanitize_mode"` // off, escape (remove invisible characters, escape HTML) or strict (also strip tags)
+
+	MaxMessageLength int `json:"max_message_length"` // longest submission text in characters after sanitizing; the WebSocket read limit is derived from it
+
+	DuplicateContent         string `json:"duplicate_content"`          // off, reject or group identical submissions within a round
+	DuplicateContentDistance int    `json:"duplicate_content_distance"` // character edits between normalized texts that still count as identical
+
+	UploadMaxBytes     int64    `json:"upload_max_bytes"`     // largest accepted attachment
+	UploadContentTypes []string `json:"upload_content_types"` // accepted attachment MIME types
+
+	PreferencesMaxBytes int `json:"preferences_max_bytes"` // largest preferences object a user may store
+
+	MessageLogSampleRate int `json:"message_log_sample_rate"` // log 1 in this many message_received events at info, the rest at debug
+
+	RewardsProvider      string `json:"rewards_provider"` // kv, webhook or none
+	RewardPoints         int    `json:"reward_points"`    // points granted per win
+	RewardsWebhookURL    string `json:"rewards_webhook_url"`
+	RewardsWebhookSecret string `json:"rewards_webhook_secret"` // HMAC key for the X-Signature header
+
+	ArchiveEndpoint   string `json:"archive_endpoint"` // S3-compatible endpoint finished rounds are archived to, e.g. http://minio:9000; empty disables archival
+	ArchiveRegion     string `json:"archive_region"`   // signing region, us-east-1 when empty
+	ArchiveBucket     string `json:"archive_bucket"`
+	ArchivePrefix     string `json:"archive_prefix"` // key prefix of archived rounds, stored as PREFIX/ROOM/ROUND_ID.json
+	ArchiveAccessKey  string `json:"archive_access_key"`
+	ArchiveSecretKey  string `json:"archive_secret_key"`
+	ArchiveExpireDays int    `json:"archive_expire_days"` // lifecycle rule deleting archived rounds after this many days, 0 keeps them
+	ArchiveQueueSize  int    `json:"archive_queue_size"`  // archives waiting for upload before new ones are dropped
+	ArchiveMaxRetries int    `json:"archive_max_retries"` // upload attempts after the first before an archive is given up
+}
+
+// DefaultConfig returns the configuration used when no config file is present.
+func DefaultConfig() Config {
+	return Config{
+		EventBus: EventBusJetStream,
+		NatsURL:  "nats://127.0.0.1:4222",
+		RedisURL: "redis://127.0.0.1:6379/0",
+
+		NatsConnectionName: "game-server",
+		StreamsFile:        "streams.yaml",
+		ControlTimeoutMs:   500,
+
+		StreamsMonitorSeconds:     30,
+		StreamsLagWarn:            10000,
+		StreamsStorageWarnPercent: 80,
+		StreamsReplicaLagWarn:     1000,
+
+		MaxConnections:    0,
+		WaitingRoom:       false,
+		WaitingRoomSize:   100,
+		RetryAfterSeconds: 5,
+
+		BotRateLimitPerSecond: 1,
+		BotRateLimitBurst:     5,
+
+		RoundDurationSeconds:    15,
+		SubmissionWindowSeconds: 0,
+		PacingProfiles: map[string]PacingProfile{
+			PacingBlitz:    {RoundDurationSeconds: 8, SubmissionWindowSeconds: 6, PauseSeconds: 2},
+			PacingNormal:   {RoundDurationSeconds: 15, SubmissionWindowSeconds: 0, PauseSeconds: 3},
+			PacingMarathon: {RoundDurationSeconds: 120, SubmissionWindowSeconds: 90, PauseSeconds: 10},
+		},
+		MinRoundDurationSeconds: 10,
+		MaxRoundDurationSeconds: 60,
+		RoundMode:               RoundModeFree,
+		SeriesWinPoints:         1,
+		MessageLogSampleRate:    1,
+		WebSocketBatchMax:       16,
+
+		BroadcastPartitionThreshold: 5000,
+
+		SearchIndexRounds: 2000,
+
+		ListenAddr:     ":8080",
+		RateLimitBurst: 20,
+		HTTP2:          true,
+
+		OIDCGroupsClaim:  "groups",
+		OIDCSessionHours: 8,
+
+		PublishQueueSize:  1024,
+		PublishMaxRetries: 5,
+		EventBufferSize:   256,
+
+		MaxRooms:                  50,
+		MaxRoomCapacity:           100,
+		MaxRoomsPerOwner:          3,
+		RoomCreateIntervalSeconds: 60,
+		RoomIdleMinutes:           30,
+		EncryptedMaxBytes:         4096,
+		MaxLobbyConnections:       1000,
+		MaxHistoryStreams:         16,
+		MemoryHistoryRounds:       50,
+		HistoryConcurrency:        8,
+
+		UsernamePolicy: UsernamePolicy{
+			MinLength:       3,
+			MaxLength:       20,
+			ExtraCharacters: "_",
+			NormalizeNFKC:   true,
+		},
+
+		GuestsCanSubmit: true,
+		GuestsCanWin:    true,
+
+		SkipIdleRounds:     true,
+		PublishEmptyRounds: false,
+
+		DeliveryAckTimeoutSeconds: 5,
+		WinnerAppealWindowSeconds: 300,
+		NudgeAtPercent:            50,
+
+		LatencyPingSeconds: 10,
+		TimeSyncSeconds:    30,
+		MaxLatencyMs:       0,
+
+		ParticipantsIntervalMs: 1000,
+
+		PingMinSeconds:    10,
+		PingMaxSeconds:    54,
+		SlowPongMs:        1000,
+		StablePongsToGrow: 5,
+
+		DeprioritizeBackgrounded: true,
+
+		MessageDeadlineMs: 2000,
+
+		ResumeTokenTTLSeconds:  900,
+		ResumeKeyRotationHours: 24,
+
+		ReactionEmojis: []string{"👍", "😂", "🔥", "😮", "👏"},
+
+		WinnerScoring: WinnerScoring{
+			Base:              1,
+			LengthWeight:      1,
+			LengthTarget:      100,
+			OriginalityWeight: 1,
+		},
+
+		RulesTimeoutMs: 100,
+
+		SanitizeMode:     SanitizeEscape,
+		MaxMessageLength: 500,
+
+		DuplicateContent: DuplicatesOff,
+
+		UploadMaxBytes:     5 << 20,
+		UploadContentTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp"},
+
+		PreferencesMaxBytes: 4096,
+
+		RewardsProvider: RewardsKV,
+		RewardPoints:    10,
+
+		ArchivePrefix:     "rounds/",
+		ArchiveQueueSize:  64,
+		ArchiveMaxRetries: 5,
+	}
+}
+
+// ApplyEnv overrides settings with values from environment variables when they are set.
+func (c *Config) ApplyEnv() {
+	if v := os.Getenv("EVENT_BUS"); v != "" {
+		c.EventBus = v
+	}
+	if v := os.Getenv("NATS_URL"); v != "" {
+		c.NatsURL = v
+	}
+	if v := os.Getenv("NATS_USER"); v != "" {
+		c.NatsUser = v
+	}
+	if v := os.Getenv("NATS_PASSWORD"); v != "" {
+		c.NatsPassword = v
+	}
+	if v := os.Getenv("NATS_CREDS_FILE"); v != "" {
+		c.NatsCredsFile = v
+	}
+	if v := os.Getenv("REDIS_URL"); v != "" {
+		c.RedisURL = v
+	}
+	if v := os.Getenv("LISTEN_ADDR"); v != "" {
+		c.ListenAddr = v
+	}
+	if v := os.Getenv("ADMIN_LISTEN_ADDR"); v != "" {
+		c.AdminListenAddr = v
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		c.AdminToken = v
+	}
+	if v := os.Getenv("OIDC_CLIENT_SECRET"); v != "" {
+		c.OIDCClientSecret = v
+	}
+	if v := os.Getenv("OIDC_SESSION_SECRET"); v != "" {
+		c.OIDCSessionSecret = v
+	}
+	if v := os.Getenv("SUBJECT_PREFIX"); v != "" {
+		c.SubjectPrefix = v
+	}
+	if v := os.Getenv("ARCHIVE_ACCESS_KEY"); v != "" {
+		c.ArchiveAccessKey = v
+	}
+	if v := os.Getenv("ARCHIVE_SECRET_KEY"); v != "" {
+		c.ArchiveSecretKey = v
+	}
+	if v := os.Getenv("STRICT_STARTUP"); v != "" {
+		c.StrictStartup = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("RESUME_SECRETS"); v != "" {
+		c.ResumeSecrets = strings.Split(v, ",")
+	}
+	if v := os.Getenv("PACING_PROFILE"); v != "" {
+		c.PacingProfile = v
+	}
+}
+
+// UsePacingProfile copies the round timing of the named profile into round_duration_seconds,
+// submission_window_seconds and round_pause_seconds. An empty name only clears the
+// selection and keeps the current timing.
+func (c *Config) UsePacingProfile(name string) error {
+	if name == "" {
+		c.PacingProfile = ""
+		return nil
+	}
+	profile, ok := c.PacingProfiles[name]
+	if !ok {
+		return fmt.Errorf("unknown pacing profile %q", name)
+	}
+	if profile.RoundDurationSeconds <= 0 || profile.SubmissionWindowSeconds < 0 || profile.PauseSeconds < 0 {
+		return fmt.Errorf("pacing profile %q needs a positive round duration and no negative values", name)
+	}
+	c.PacingProfile = name
+	c.RoundDurationSeconds = profile.RoundDurationSeconds
+	c.SubmissionWindowSeconds = profile.SubmissionWindowSeconds
+	c.RoundPauseSeconds = profile.PauseSeconds
+	return nil
+}
+
+// ServiceAccountByToken returns the service account a bearer token belongs to.
+func (c Config) ServiceAccountByToken(token string) (ServiceAccount, bool) {
+	if token == "" {
+		return ServiceAccount{}, false
+	}
+	for _, account := range c.ServiceAccounts {
+		if account.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(account.Token)) == 1 {
+			return account, true
+		}
+	}
+	return ServiceAccount{}, false
+}
+
+// IsServiceAccount reports whether name belongs to a service account, which only its
+// token may play under.
+func (c Config) IsServiceAccount(name string) bool {
+	for _, account := range c.ServiceAccounts {
+		if strings.EqualFold(account.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject returns the subject namespaced with SubjectPrefix.
+func (c Config) Subject(subject string) string {
+	if c.SubjectPrefix == "" {
+		return subject
+	}
+	return c.SubjectPrefix + "." + subject
+}
+
+// ResourceName returns a JetStream stream or bucket name namespaced with SubjectPrefix.
+// Names cannot contain dots, so "staging.game1" and "ROUNDS" become "STAGING_GAME1_ROUNDS".
+func (c Config) ResourceName(name string) string {
+	if c.SubjectPrefix == "" {
+		return name
+	}
+	prefix := strings.NewReplacer(".", "_", "-", "_", "*", "_", ">", "_").Replace(c.SubjectPrefix)
+	return strings.ToUpper(prefix) + "_" + name
+}
@@ -0,0 +1,34 @@
+// internal/eventbus/delay.go
+package eventbus
+
+import "time"
+
+// delayedBus holds back every publish of another bus by a delay that may change at
+// runtime, to rehearse a slow persistence backend.
+type delayedBus struct {
+	EventBus
+	delay func() time.Duration
+}
+
+// WithPublishDelay returns a bus that sleeps for delay() before each Publish and
+// PublishWithID. Reads and subscriptions are not delayed.
+func WithPublishDelay(bus EventBus, delay func() time.Duration) EventBus {
+	if bus == nil {
+		return nil
+	}
+	return &delayedBus{EventBus: bus, delay: delay}
+}
+
+func (b *delayedBus) Publish(subject string, data []byte) error {
+	if d := b.delay(); d > 0 {
+		time.Sleep(d)
+	}
+	return b.EventBus.Publish(subject, data)
+}
+
+func (b *delayedBus) PublishWithID(subject, id string, data []byte) error {
+	if d := b.delay(); d > 0 {
+		time.Sleep(d)
+	}
+	return b.EventBus.PublishWithID(subject, id, data)
+}
@@ -0,0 +1,55 @@
+// internal/eventbus/eventbus.go
+// Defines the EventBus abstraction used for persisting and distributing hub events.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrHistoryIncomplete is returned by History and its variants together with the events
+// read so far when maxWait ran out while stored events were still pending, so callers
+// can tell a partial history from a complete one.
+var ErrHistoryIncomplete = errors.New("history read stopped at the deadline")
+
+// Event is a single entry published on the bus.
+type Event struct {
+	Subject   string
+	Data      []byte
+	Timestamp time.Time
+}
+
+// Handler is invoked for every event delivered to a subscription.
+type Handler func(Event)
+
+// Subscription represents an active subscription that can be cancelled.
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// EventBus is the messaging layer used by the hub and the HTTP API.
+// Subjects follow the NATS dot notation (e.g. "messages.ROUND_ID") regardless of the backend,
+// and Subscribe accepts a trailing "*" wildcard token.
+type EventBus interface {
+	// Publish persists data under the given subject and delivers it to live subscribers.
+	Publish(subject string, data []byte) error
+	// PublishWithID is like Publish but drops the event if one with the same id
+	// was already published on the subject within the retention window.
+	PublishWithID(subject, id string, data []byte) error
+	// Subscribe registers a handler for live events matching the subject.
+	Subscribe(subject string, handler Handler) (Subscription, error)
+	// History returns up to limit stored events for a subject, oldest first. A trailing
+	// "*" token reads every matching subject, e.g. "winners.*".
+	// maxWait bounds the whole read; events read until then are returned together with
+	// ErrHistoryIncomplete when stored events were still pending.
+	History(subject string, limit int, maxWait time.Duration) ([]Event, error)
+	// HistoryContext is History that also stops when ctx is done, returning its error,
+	// so a read ends as soon as the caller no longer needs it.
+	HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]Event, error)
+	// HistorySince is HistoryContext starting at the first event stored at or after since
+	// rather than at the oldest one, so the limit applies to recent events.
+	HistorySince(ctx context.Context, subject string, since time.Time, limit int, maxWait time.Duration) ([]Event, error)
+	// Close releases any resources held by the bus.
+	Close() error
+}
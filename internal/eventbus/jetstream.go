@@ -0,0 +1,155 @@
+// internal/eventbus/jetstream.go
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	historyConsumerPrefix     = "API_CONSUMER_"
+	historyConsumerMaxDeliver = 1
+	historyFetchBatch         = 256 // messages requested per pull while reading history
+)
+
+// JetStreamBus implements EventBus on top of NATS JetStream.
+type JetStreamBus struct {
+	nc     *nats.Conn
+	js     nats.JetStreamContext
+	logger *logger.Logger
+}
+
+// NewJetStreamBus wraps an existing NATS connection and JetStream context.
+// Stream creation is left to the caller.
+func NewJetStreamBus(nc *nats.Conn, js nats.JetStreamContext, logger *logger.Logger) *JetStreamBus {
+	return &JetStreamBus{nc: nc, js: js, logger: logger}
+}
+
+// Publish stores the event in the stream that owns the subject.
+func (b *JetStreamBus) Publish(subject string, data []byte) error {
+	_, err := b.js.Publish(subject, data)
+	return err
+}
+
+// PublishWithID relies on the JetStream duplicate window via the Nats-Msg-Id header.
+func (b *JetStreamBus) PublishWithID(subject, id string, data []byte) error {
+	_, err := b.js.Publish(subject, data, nats.MsgId(id))
+	return err
+}
+
+// Subscribe uses a core NATS subscription, which also receives JetStream publishes.
+func (b *JetStreamBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	return b.nc.Subscribe(subject, func(msg *nats.Msg) {
+		handler(Event{Subject: msg.Subject, Data: msg.Data, Timestamp: time.Now()})
+	})
+}
+
+// History reads stored events for a subject through a short-lived pull consumer
+// that is removed again before returning. It pulls in batches until the consumer has no
+// pending messages, limit events were read or maxWait, the deadline for the whole read,
+// has passed; a read cut short by the deadline returns what arrived with
+// ErrHistoryIncomplete and logs a warning.
+func (b *JetStreamBus) History(subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistoryContext(context.Background(), subject, limit, maxWait)
+}
+
+// HistoryContext is History that gives up once ctx is done: the stream lookup, consumer
+// creation and every fetch are bound to it, and the consumer is still removed.
+func (b *JetStreamBus) HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistorySince(ctx, subject, time.Time{}, limit, maxWait)
+}
+
+// HistorySince is HistoryContext with a consumer that delivers from since onwards, or from
+// the start of the stream when since is zero.
+func (b *JetStreamBus) HistorySince(ctx context.Context, subject string, since time.Time, limit int, maxWait time.Duration) ([]Event, error) {
+	readCtx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+	streamName, err := b.js.StreamNameBySubject(subject, nats.Context(readCtx))
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolving stream for %s: %w", subject, err)
+	}
+
+	consumerName := fmt.Sprintf("%s%s_%d", historyConsumerPrefix, streamName, time.Now().UnixNano())
+	consumer := &nats.ConsumerConfig{
+		Name:          consumerName,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		FilterSubject: subject,
+		MaxDeliver:    historyConsumerMaxDeliver,
+	}
+	if !since.IsZero() {
+		consumer.DeliverPolicy = nats.DeliverByStartTimePolicy
+		consumer.OptStartTime = &since
+	}
+	info, err := b.js.AddConsumer(streamName, consumer, nats.Context(readCtx))
+	if ctx.Err() != nil {
+		// The consumer may have been created before the request was abandoned.
+		b.js.DeleteConsumer(streamName, consumerName)
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating consumer %s for subject %s: %w", consumerName, subject, err)
+	}
+	defer func() {
+		if delErr := b.js.DeleteConsumer(streamName, consumerName); delErr != nil {
+			b.logger.Errorf("Error deleting consumer %s: %v", consumerName, delErr)
+		}
+	}()
+
+	sub, err := b.js.PullSubscribe(subject, consumerName, nats.Bind(streamName, consumerName))
+	if err != nil {
+		return nil, fmt.Errorf("subscribing with consumer %s to subject %s: %w", consumerName, subject, err)
+	}
+	defer func() {
+		if unsubErr := sub.Unsubscribe(); unsubErr != nil {
+			b.logger.Errorf("Error unsubscribing consumer %s: %v", consumerName, unsubErr)
+		}
+	}()
+
+	pending := info.NumPending
+	events := make([]Event, 0, min(uint64(limit), pending))
+	for pending > 0 && len(events) < limit {
+		if readCtx.Err() != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			b.logger.Warnf("History of %s stopped at the deadline with %d events read and %d pending", subject, len(events), pending)
+			return events, fmt.Errorf("reading %s: %w with %d events pending", subject, ErrHistoryIncomplete, pending)
+		}
+		batch := int(min(uint64(min(historyFetchBatch, limit-len(events))), pending))
+		msgs, err := sub.Fetch(batch, nats.Context(readCtx))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && !errors.Is(err, nats.ErrTimeout) && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("fetching messages with consumer %s: %w", consumerName, err)
+		}
+		if len(msgs) == 0 {
+			b.logger.Warnf("History of %s timed out with %d events read and %d pending", subject, len(events), pending)
+			return events, fmt.Errorf("reading %s: %w with %d events pending", subject, ErrHistoryIncomplete, pending)
+		}
+		for _, msg := range msgs {
+			event := Event{Subject: msg.Subject, Data: msg.Data, Timestamp: time.Now()}
+			if meta, err := msg.Metadata(); err == nil {
+				event.Timestamp = meta.Timestamp
+				pending = meta.NumPending
+			}
+			events = append(events, event)
+			msg.Ack()
+		}
+	}
+	return events, nil
+}
+
+// Close is a no-op; the NATS connection is owned by the server.
+func (b *JetStreamBus) Close() error {
+	return nil
+}
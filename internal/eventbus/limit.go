@@ -0,0 +1,52 @@
+// internal/eventbus/limit.go
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrHistoryBusy is returned by a limited bus when no history read slot frees up in time.
+var ErrHistoryBusy = errors.New("too many concurrent history reads")
+
+// limitedBus bounds the number of concurrent History calls of another bus. Each JetStream
+// history read creates a consumer, so a burst of API requests would otherwise create a
+// burst of consumers on the NATS server.
+type limitedBus struct {
+	EventBus
+	slots   chan struct{}
+	maxWait time.Duration
+}
+
+// WithHistoryLimit returns a bus that allows at most n History calls at a time. Callers
+// wait up to maxWait for a slot and get ErrHistoryBusy after that, or the context's error
+// when it is done first. A limit of 0 or less returns bus unchanged.
+func WithHistoryLimit(bus EventBus, n int, maxWait time.Duration) EventBus {
+	if n <= 0 || bus == nil {
+		return bus
+	}
+	return &limitedBus{EventBus: bus, slots: make(chan struct{}, n), maxWait: maxWait}
+}
+
+func (b *limitedBus) History(subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistoryContext(context.Background(), subject, limit, maxWait)
+}
+
+func (b *limitedBus) HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistorySince(ctx, subject, time.Time{}, limit, maxWait)
+}
+
+func (b *limitedBus) HistorySince(ctx context.Context, subject string, since time.Time, limit int, maxWait time.Duration) ([]Event, error) {
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+	case <-timer.C:
+		return nil, ErrHistoryBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-b.slots }()
+	return b.EventBus.HistorySince(ctx, subject, since, limit, maxWait)
+}
@@ -0,0 +1,63 @@
+// internal/eventbus/prefix.go
+package eventbus
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// prefixedBus namespaces every subject of another bus, so deployments sharing a NATS
+// cluster or Redis server do not see each other's events.
+type prefixedBus struct {
+	bus    EventBus
+	prefix string // ends with "."
+}
+
+// WithPrefix returns a bus that prepends "prefix." to every subject and strips it from
+// delivered events. An empty prefix returns bus unchanged.
+func WithPrefix(bus EventBus, prefix string) EventBus {
+	if prefix == "" || bus == nil {
+		return bus
+	}
+	return &prefixedBus{bus: bus, prefix: prefix + "."}
+}
+
+func (b *prefixedBus) Publish(subject string, data []byte) error {
+	return b.bus.Publish(b.prefix+subject, data)
+}
+
+func (b *prefixedBus) PublishWithID(subject, id string, data []byte) error {
+	return b.bus.PublishWithID(b.prefix+subject, id, data)
+}
+
+func (b *prefixedBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	return b.bus.Subscribe(b.prefix+subject, func(event Event) {
+		handler(b.strip(event))
+	})
+}
+
+func (b *prefixedBus) History(subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistoryContext(context.Background(), subject, limit, maxWait)
+}
+
+func (b *prefixedBus) HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistorySince(ctx, subject, time.Time{}, limit, maxWait)
+}
+
+func (b *prefixedBus) HistorySince(ctx context.Context, subject string, since time.Time, limit int, maxWait time.Duration) ([]Event, error) {
+	events, err := b.bus.HistorySince(ctx, b.prefix+subject, since, limit, maxWait)
+	for i := range events {
+		events[i] = b.strip(events[i])
+	}
+	return events, err
+}
+
+func (b *prefixedBus) Close() error {
+	return b.bus.Close()
+}
+
+func (b *prefixedBus) strip(event Event) Event {
+	event.Subject = strings.TrimPrefix(event.Subject, b.prefix)
+	return event
+}
@@ -0,0 +1,189 @@
+// internal/eventbus/redis.go
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisDataField      = "data"
+	redisDedupPrefix    = "dedup:"
+	redisStreamMaxLen   = 10000
+	redisCommandTimeout = 5 * time.Second
+)
+
+// RedisBus implements EventBus with Redis Streams for history and Redis pub/sub for live delivery.
+// Every subject maps to its own stream key, which expires after the configured retention.
+type RedisBus struct {
+	client    *redis.Client
+	retention time.Duration
+	logger    *logger.Logger
+}
+
+// NewRedisBus connects to Redis using a redis:// URL and verifies the connection.
+func NewRedisBus(url string, retention time.Duration, logger *logger.Logger) (*RedisBus, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing redis url: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to redis: %w", err)
+	}
+	return &RedisBus{client: client, retention: retention, logger: logger}, nil
+}
+
+// Publish appends the event to the subject's stream and notifies pub/sub subscribers.
+func (b *RedisBus) Publish(subject string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+
+	pipe := b.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: subject,
+		MaxLen: redisStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{redisDataField: data},
+	})
+	if b.retention > 0 {
+		pipe.Expire(ctx, subject, b.retention)
+	}
+	pipe.Publish(ctx, subject, data)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// PublishWithID records the id in a dedup key that lives as long as the stream
+// and only publishes when the key did not exist yet.
+func (b *RedisBus) PublishWithID(subject, id string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+
+	fresh, err := b.client.SetNX(ctx, redisDedupPrefix+subject+":"+id, 1, b.retention).Result()
+	if err != nil {
+		return fmt.Errorf("checking duplicate %s on %s: %w", id, subject, err)
+	}
+	if !fresh {
+		return nil
+	}
+	return b.Publish(subject, data)
+}
+
+// Subscribe uses a pattern subscription so NATS style "*" wildcards keep working.
+func (b *RedisBus) Subscribe(subject string, handler Handler) (Subscription, error) {
+	ctx := context.Background()
+	pubsub := b.client.PSubscribe(ctx, subject)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("subscribing to %s: %w", subject, err)
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			handler(Event{Subject: msg.Channel, Data: []byte(msg.Payload), Timestamp: time.Now()})
+		}
+	}()
+	return &redisSubscription{pubsub: pubsub}, nil
+}
+
+// History reads the stored stream for the subject. maxWait only bounds the Redis call.
+// Wildcard subjects are resolved with SCAN and the streams merged by time.
+func (b *RedisBus) History(subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistoryContext(context.Background(), subject, limit, maxWait)
+}
+
+// HistoryContext is History with the Redis calls also bound to ctx.
+func (b *RedisBus) HistoryContext(ctx context.Context, subject string, limit int, maxWait time.Duration) ([]Event, error) {
+	return b.HistorySince(ctx, subject, time.Time{}, limit, maxWait)
+}
+
+// HistorySince is HistoryContext reading each stream from the first entry ID at or after
+// since, or from the start when since is zero.
+func (b *RedisBus) HistorySince(ctx context.Context, subject string, since time.Time, limit int, maxWait time.Duration) ([]Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	start := "-"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+	if !strings.HasSuffix(subject, "*") {
+		return b.streamHistory(ctx, subject, start, limit)
+	}
+
+	var events []Event
+	iter := b.client.ScanType(ctx, 0, subject, 100, "stream").Iterator()
+	for iter.Next(ctx) {
+		streamEvents, err := b.streamHistory(ctx, iter.Val(), start, limit)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, streamEvents...)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scanning streams %s: %w", subject, err)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// streamHistory reads up to limit entries of a single stream from the entry ID start.
+func (b *RedisBus) streamHistory(ctx context.Context, subject, start string, limit int) ([]Event, error) {
+	entries, err := b.client.XRangeN(ctx, subject, start, "+", int64(limit)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading stream %s: %w", subject, err)
+	}
+
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		data, ok := entry.Values[redisDataField].(string)
+		if !ok {
+			b.logger.Warnf("Skipping malformed entry %s in stream %s", entry.ID, subject)
+			continue
+		}
+		events = append(events, Event{
+			Subject:   subject,
+			Data:      []byte(data),
+			Timestamp: streamIDTime(entry.ID),
+		})
+	}
+	return events, nil
+}
+
+// Close closes the underlying Redis client.
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
+
+type redisSubscription struct {
+	pubsub *redis.PubSub
+}
+
+func (s *redisSubscription) Unsubscribe() error {
+	return s.pubsub.Close()
+}
+
+// streamIDTime extracts the millisecond timestamp embedded in a Redis stream entry ID.
+func streamIDTime(id string) time.Time {
+	ms, _, _ := strings.Cut(id, "-")
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(n)
+}
@@ -0,0 +1,188 @@
+// internal/hub/admin.go
+// Operator actions exposed through the admin API.
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/erilali/internal/config"
+)
+
+// ErrNoActiveRound is returned by ForceEndRound when no round is running.
+var ErrNoActiveRound = errors.New("no active round")
+
+// Ban records why and when a username was banned.
+type Ban struct {
+	Username string    `json:"username"`
+	Reason   string    `json:"reason,omitempty"`
+	BannedAt time.Time `json:"banned_at"`
+}
+
+// RuntimeSettings are the configuration values admins may change while the server runs.
+// The connection limit and waiting room apply to the next connection attempt. Settings
+// that are only read at startup (listeners, the event bus, round length, providers) are
+// not included, but switching pacing_profile replaces the round length, submission window
+// and pause from the next round.
+type RuntimeSettings struct {
+	MaxConnections          int  `json:"max_connections"`
+	WaitingRoom             bool `json:"waiting_room"`
+	WaitingRoomSize         int  `json:"waiting_room_size"`
+	RetryAfterSeconds       int  `json:"retry_after_seconds"`
+	SubmissionWindowSeconds int  `json:"submission_window_seconds"`
+	AdaptiveRounds          bool `json:"adaptive_rounds"`
+	SkipIdleRounds          bool `json:"skip_idle_rounds"`
+	PublishEmptyRounds      bool `json:"publish_empty_rounds"`
+	MaxLatencyMs            int  `json:"max_latency_ms"`
+	RewardPoints            int  `json:"reward_points"`
+	MessageLogSampleRate    int  `json:"message_log_sample_rate"`
+
+	PacingProfile string `json:"pacing_profile"` // empty keeps the current timing
+}
+
+// settings returns a snapshot of the current configuration.
+func (h *Hub) settings() config.Config {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return h.Config
+}
+
+// RuntimeSettings returns the current values of the adjustable settings.
+func (h *Hub) RuntimeSettings() RuntimeSettings {
+	cfg := h.settings()
+	return RuntimeSettings{
+		MaxConnections:          cfg.MaxConnections,
+		WaitingRoom:             cfg.WaitingRoom,
+		WaitingRoomSize:         cfg.WaitingRoomSize,
+		RetryAfterSeconds:       cfg.RetryAfterSeconds,
+		SubmissionWindowSeconds: cfg.SubmissionWindowSeconds,
+		AdaptiveRounds:          cfg.AdaptiveRounds,
+		SkipIdleRounds:          cfg.SkipIdleRounds,
+		PublishEmptyRounds:      cfg.PublishEmptyRounds,
+		MaxLatencyMs:            cfg.MaxLatencyMs,
+		RewardPoints:            cfg.RewardPoints,
+		MessageLogSampleRate:    cfg.MessageLogSampleRate,
+		PacingProfile:           cfg.PacingProfile,
+	}
+}
+
+// ApplyRuntimeSettings validates and applies adjusted settings. A changed submission
+// window, pacing profile or adaptive mode takes effect from the next round. A newly
+// selected profile sets the submission window over the one in s.
+func (h *Hub) ApplyRuntimeSettings(s RuntimeSettings, actor string) error {
+	if s.MaxConnections < 0 || s.WaitingRoomSize < 0 || s.RetryAfterSeconds < 0 ||
+		s.SubmissionWindowSeconds < 0 || s.MaxLatencyMs < 0 || s.RewardPoints < 0 ||
+		s.MessageLogSampleRate < 0 {
+		return errors.New("settings must not be negative")
+	}
+
+	rules := h.GameRules()
+	h.configMu.Lock()
+	if s.PacingProfile != h.Config.PacingProfile {
+		if err := h.Config.UsePacingProfile(s.PacingProfile); err != nil {
+			h.configMu.Unlock()
+			return err
+		}
+		s.SubmissionWindowSeconds = h.Config.SubmissionWindowSeconds
+	}
+	h.Config.MaxConnections = s.MaxConnections
+	h.Config.WaitingRoom = s.WaitingRoom
+	h.Config.WaitingRoomSize = s.WaitingRoomSize
+	h.Config.RetryAfterSeconds = s.RetryAfterSeconds
+	h.Config.SubmissionWindowSeconds = s.SubmissionWindowSeconds
+	h.Config.AdaptiveRounds = s.AdaptiveRounds
+	h.Config.SkipIdleRounds = s.SkipIdleRounds
+	h.Config.PublishEmptyRounds = s.PublishEmptyRounds
+	h.Config.MaxLatencyMs = s.MaxLatencyMs
+	h.Config.RewardPoints = s.RewardPoints
+	h.Config.MessageLogSampleRate = s.MessageLogSampleRate
+	h.configMu.Unlock()
+
+	h.Audit(AuditAdminAction, actor, "Runtime settings changed", fmt.Sprintf("%+v", s))
+	h.Logger.Infof("Runtime settings changed by %s: %+v", actor, s)
+	h.broadcastRulesUpdate(rules)
+	return nil
+}
+
+// KickClient closes every connection of username and returns how many were closed.
+// The read pumps then unregister the clients as for a normal disconnect.
+func (h *Hub) KickClient(username, actor string) int {
+	kicked := 0
+	for _, client := range h.clients.snapshot() {
+		if h.sameUsername(client.Username(), username) {
+			client.Conn.Close()
+			kicked++
+		}
+	}
+	if kicked > 0 {
+		h.Audit(AuditAdminAction, username, "Kicked by "+actor, "")
+		h.Logger.Infof("Kicked %d connection(s) of %s", kicked, username)
+	}
+	return kicked
+}
+
+// BanUser prevents username from connecting and kicks any open connections.
+func (h *Hub) BanUser(username, reason, actor string) Ban {
+	ban := Ban{Username: username, Reason: reason, BannedAt: h.clock.Now()}
+	h.bansMu.Lock()
+	h.bans[h.usernameKey(username)] = ban
+	h.bansMu.Unlock()
+
+	h.Audit(AuditBan, username, "Banned by "+actor, reason)
+	h.Logger.Infof("Banned %s: %s", username, reason)
+	h.KickClient(username, actor)
+	return ban
+}
+
+// UnbanUser lifts a ban and reports whether one existed.
+func (h *Hub) UnbanUser(username, actor string) bool {
+	key := h.usernameKey(username)
+	h.bansMu.Lock()
+	_, ok := h.bans[key]
+	delete(h.bans, key)
+	h.bansMu.Unlock()
+
+	if ok {
+		h.Audit(AuditAdminAction, username, "Unbanned by "+actor, "")
+	}
+	return ok
+}
+
+// Bans lists the current bans ordered by username.
+func (h *Hub) Bans() []Ban {
+	h.bansMu.RLock()
+	defer h.bansMu.RUnlock()
+	bans := make([]Ban, 0, len(h.bans))
+	for _, ban := range h.bans {
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Username < bans[j].Username })
+	return bans
+}
+
+// isBanned reports whether username is banned.
+func (h *Hub) isBanned(username string) bool {
+	key := h.usernameKey(username)
+	h.bansMu.RLock()
+	defer h.bansMu.RUnlock()
+	_, ok := h.bans[key]
+	return ok
+}
+
+// ForceEndRound ends the active round immediately and returns its ID.
+// The next round starts on the following tick of the round timer.
+func (h *Hub) ForceEndRound(actor string) (int64, error) {
+	h.Mu.RLock()
+	roundActive := h.RoundActive
+	roundID := h.CurrentRoundID
+	h.Mu.RUnlock()
+	if !roundActive {
+		return 0, ErrNoActiveRound
+	}
+
+	h.EndRound()
+	h.Audit(AuditAdminAction, actor, "Round ended by admin", fmt.Sprint(roundID))
+	return roundID, nil
+}
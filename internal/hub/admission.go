@@ -0,0 +1,145 @@
+// internal/hub/admission.go
+package hub
+
+// Occupancy describes how many connection slots are in use.
+type Occupancy struct {
+	Active  int  `json:"active"`
+	Max     int  `json:"max"` // 0 means unlimited
+	Waiting int  `json:"waiting"`
+	Full    bool `json:"full"`
+}
+
+// Occupancy returns the current slot usage and waiting room length.
+func (h *Hub) Occupancy() Occupancy {
+	h.admissionMu.Lock()
+	defer h.admissionMu.Unlock()
+
+	max := h.settings().MaxConnections
+	return Occupancy{
+		Active:  h.activeSlots,
+		Max:     max,
+		Waiting: len(h.waitingRoom),
+		Full:    max > 0 && h.activeSlots >= max,
+	}
+}
+
+// acquireSlot reserves a connection slot if one is free.
+func (h *Hub) acquireSlot() bool {
+	h.admissionMu.Lock()
+	defer h.admissionMu.Unlock()
+
+	if max := h.settings().MaxConnections; max > 0 && h.activeSlots >= max {
+		return false
+	}
+	h.activeSlots++
+	return true
+}
+
+// releaseSlot gives a slot back after an admitted client left. If someone is waiting,
+// the slot is handed over directly and the admitted client is returned so the caller
+// can register it; otherwise nil is returned.
+func (h *Hub) releaseSlot() *Client {
+	h.admissionMu.Lock()
+	defer h.admissionMu.Unlock()
+
+	if len(h.waitingRoom) == 0 {
+		h.activeSlots--
+		return nil
+	}
+	next := h.waitingRoom[0]
+	h.waitingRoom = h.waitingRoom[1:]
+	next.waiting = false
+	return next
+}
+
+// abandonSlot gives back the slot of an admitted client that never got registered, such
+// as one whose upgrade failed. Like removeClient it hands the slot to the first waiting
+// client, which is registered through the Run goroutine; once the hub stopped, that
+// client is disconnected like the rest of the waiting room and the slot freed.
+func (h *Hub) abandonSlot() {
+	next := h.releaseSlot()
+	if next == nil {
+		return
+	}
+	h.sendAdmitted(next)
+	select {
+	case h.Register <- next:
+		go h.notifyWaitingPositions()
+	case <-h.context().Done():
+		h.admissionMu.Lock()
+		h.activeSlots--
+		h.admissionMu.Unlock()
+		next.Conn.Close()
+	}
+}
+
+// sendAdmitted tells a client leaving the waiting room that it is connected.
+func (h *Hub) sendAdmitted(client *Client) {
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version": "1.0",
+		"type":    "admitted",
+		"data":    "A slot is free, you are now connected",
+	})
+}
+
+// enterWaitingRoom queues a client until a slot frees up. It returns false when the
+// waiting room is disabled or full.
+func (h *Hub) enterWaitingRoom(client *Client) (int, bool) {
+	h.admissionMu.Lock()
+	defer h.admissionMu.Unlock()
+
+	if cfg := h.settings(); !cfg.WaitingRoom || len(h.waitingRoom) >= cfg.WaitingRoomSize {
+		return 0, false
+	}
+	client.waiting = true
+	h.waitingRoom = append(h.waitingRoom, client)
+	return len(h.waitingRoom), true
+}
+
+// leaveWaitingRoom removes a client that disconnected before being admitted.
+// It returns false if the client was already admitted.
+func (h *Hub) leaveWaitingRoom(client *Client) bool {
+	h.admissionMu.Lock()
+	defer h.admissionMu.Unlock()
+
+	if !client.waiting {
+		return false
+	}
+	for i, c := range h.waitingRoom {
+		if c == client {
+			h.waitingRoom = append(h.waitingRoom[:i:i], h.waitingRoom[i+1:]...)
+			break
+		}
+	}
+	client.waiting = false
+	return true
+}
+
+// isWaiting reports whether the client is still queued in the waiting room.
+func (h *Hub) isWaiting(client *Client) bool {
+	h.admissionMu.Lock()
+	defer h.admissionMu.Unlock()
+	return client.waiting
+}
+
+// notifyWaitingPositions tells every queued client its current position.
+func (h *Hub) notifyWaitingPositions() {
+	h.admissionMu.Lock()
+	queued := append([]*Client(nil), h.waitingRoom...)
+	h.admissionMu.Unlock()
+
+	for i, client := range queued {
+		h.sendWaitingMessage(client, i+1)
+	}
+}
+
+// sendWaitingMessage informs a queued client of its position in the waiting room.
+func (h *Hub) sendWaitingMessage(client *Client, position int) {
+	message := map[string]interface{}{
+		"version":  "1.0",
+		"type":     "waiting",
+		"data":     "Server is full, waiting for a free slot",
+		"position": position,
+	}
+	h.sendMessageToClient(client, message)
+}
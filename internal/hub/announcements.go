@@ -0,0 +1,124 @@
+// internal/hub/announcements.go
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/erilali/internal/message"
+)
+
+const (
+	maxAnnouncementLength      = 1000
+	maxAnnouncementTitleLength = 100
+	maxAnnouncementTTL         = 7 * 24 * time.Hour
+)
+
+// announcementBoard keeps the announcements that have not expired for clients that
+// connect later. It is shared by the main hub and its room hubs.
+type announcementBoard struct {
+	mu      sync.Mutex
+	entries []message.Announcement
+	expires []time.Time // expiry of each entry
+}
+
+// post adds an announcement that expires at expiresAt; a zero time keeps none.
+func (b *announcementBoard) post(announcement message.Announcement, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, entry := range b.entries {
+		if entry.ID == announcement.ID {
+			return // already posted by a room's parent or a repeated control command
+		}
+	}
+	b.entries = append(b.entries, announcement)
+	b.expires = append(b.expires, expiresAt)
+}
+
+// active returns the announcements that have not expired at now, oldest first, and
+// forgets the expired ones.
+func (b *announcementBoard) active(now time.Time) []message.Announcement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := 0
+	for i, entry := range b.entries {
+		if now.Before(b.expires[i]) {
+			b.entries[kept], b.expires[kept] = entry, b.expires[i]
+			kept++
+		}
+	}
+	b.entries, b.expires = b.entries[:kept], b.expires[:kept]
+	if kept == 0 {
+		return nil
+	}
+	return append([]message.Announcement(nil), b.entries...)
+}
+
+// NewAnnouncement validates an announcement before it is sent. severity defaults to
+// info; a positive ttl repeats it in state_sync until it expires.
+func (h *Hub) NewAnnouncement(severity, title, text string, ttl time.Duration) (message.Announcement, error) {
+	title, text = strings.TrimSpace(title), strings.TrimSpace(text)
+	switch severity {
+	case "":
+		severity = message.SeverityInfo
+	case message.SeverityInfo, message.SeverityWarning, message.SeverityCritical:
+	default:
+		return message.Announcement{}, fmt.Errorf("unknown severity %q, expected info, warning or critical", severity)
+	}
+	switch {
+	case text == "" || utf8.RuneCountInString(text) > maxAnnouncementLength:
+		return message.Announcement{}, fmt.Errorf("text must be 1-%d characters", maxAnnouncementLength)
+	case utf8.RuneCountInString(title) > maxAnnouncementTitleLength:
+		return message.Announcement{}, fmt.Errorf("title must be at most %d characters", maxAnnouncementTitleLength)
+	case ttl < 0 || ttl > maxAnnouncementTTL:
+		return message.Announcement{}, errors.New("expires_in_seconds must be between 0 and 7 days")
+	}
+	now := h.clock.Now().UTC()
+	announcement := message.Announcement{
+		ID:       h.idgen.NewID(),
+		Severity: severity,
+		Title:    title,
+		Text:     text,
+		SentAt:   now.Format(time.RFC3339),
+	}
+	if ttl > 0 {
+		announcement.ExpiresAt = now.Add(ttl).Format(time.RFC3339)
+	}
+	return announcement, nil
+}
+
+// Announce broadcasts an announcement to the clients of this hub and every room and
+// records it in the audit stream.
+func (h *Hub) Announce(announcement message.Announcement, actor string) {
+	h.deliverAnnouncement(announcement)
+	detail, _ := json.Marshal(announcement)
+	h.Audit(AuditAnnouncement, actor, "Announcement sent by "+actor, string(detail))
+	h.Logger.Infof("Announcement %s sent by %s: %s", announcement.ID, actor, announcement.Text)
+}
+
+// deliverAnnouncement broadcasts an announcement without recording it, as instances do
+// for an announcement another instance sent.
+func (h *Hub) deliverAnnouncement(announcement message.Announcement) {
+	expiresAt, _ := time.Parse(time.RFC3339, announcement.ExpiresAt)
+	h.notices.post(announcement, expiresAt)
+	h.broadcastAnnouncement(announcement)
+	for _, rh := range h.roomHubs() {
+		rh.broadcastAnnouncement(announcement)
+	}
+}
+
+func (h *Hub) broadcastAnnouncement(announcement message.Announcement) {
+	h.BroadcastMessage(map[string]interface{}{
+		"version": "1.0",
+		"type":    "announcement",
+		"data":    announcement,
+	})
+}
@@ -0,0 +1,200 @@
+// internal/hub/appeals.go
+package hub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/attachments"
+)
+
+var (
+	// ErrNoWinner is returned when a round has no winner that could be invalidated.
+	ErrNoWinner = errors.New("round has no winner to invalidate")
+	// ErrAppealWindowClosed is returned once the appeal window of a winner has passed.
+	ErrAppealWindowClosed = errors.New("appeal window has closed")
+)
+
+// WinnerCorrection describes a winner invalidated by an admin and the entrant drawn in
+// their place.
+type WinnerCorrection struct {
+	RoundID       int64         `json:"round_id"`
+	Winner        *RoundMessage `json:"winner"`     // nil when no eligible entrant remained
+	Supersedes    string        `json:"supersedes"` // message ID of the invalidated winner
+	Previous      string        `json:"previous"`   // username of the invalidated winner
+	Reason        string        `json:"reason,omitempty"`
+	InvalidatedBy string        `json:"invalidated_by"`
+	InvalidatedAt time.Time     `json:"invalidated_at"`
+}
+
+// winnerDraw is a winner selection that can still be appealed.
+type winnerDraw struct {
+	candidates   []RoundMessage     // submissions that could win, after eligibility and choice filtering
+	winner       *RoundMessage      // current winner, nil once no candidate remains
+	selectedAt   time.Time          // the appeal window runs from the original selection
+	disqualified map[string]bool    // usernames whose winning entries were invalidated
+	scores       map[string]float64 // odds of the candidates with winner_scoring, nil otherwise
+}
+
+// winnerDraws keeps the candidates of recent winner selections for the appeal window.
+type winnerDraws struct {
+	mu    sync.Mutex
+	draws map[int64]*winnerDraw
+}
+
+func newWinnerDraws() *winnerDraws {
+	return &winnerDraws{draws: make(map[int64]*winnerDraw)}
+}
+
+// record keeps a selection and forgets those selected before cutoff.
+func (d *winnerDraws) record(roundID int64, candidates []RoundMessage, winner RoundMessage, scores map[string]float64, selectedAt, cutoff time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, draw := range d.draws {
+		if draw.selectedAt.Before(cutoff) {
+			delete(d.draws, id)
+		}
+	}
+	d.draws[roundID] = &winnerDraw{
+		candidates:   append([]RoundMessage(nil), candidates...),
+		winner:       &winner,
+		selectedAt:   selectedAt,
+		disqualified: make(map[string]bool),
+		scores:       scores,
+	}
+}
+
+// drop removes a submission taken out by a moderator from the candidates of a round.
+func (d *winnerDraws) drop(roundID int64, messageID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if draw, ok := d.draws[roundID]; ok {
+		for i, msg := range draw.candidates {
+			if msg.ID == messageID {
+				draw.candidates = append(draw.candidates[:i:i], draw.candidates[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// redraw disqualifies the current winner of a round and picks a new one among the
+// remaining candidates, with the scores of the original selection. Entries by disqualified
+// users cannot win again.
+func (d *winnerDraws) redraw(roundID int64, cutoff time.Time, pick func([]RoundMessage, map[string]float64) RoundMessage) (RoundMessage, *RoundMessage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draw, ok := d.draws[roundID]
+	if !ok || draw.winner == nil {
+		return RoundMessage{}, nil, ErrNoWinner
+	}
+	if draw.selectedAt.Before(cutoff) {
+		return RoundMessage{}, nil, ErrAppealWindowClosed
+	}
+
+	previous := *draw.winner
+	draw.disqualified[previous.Username] = true
+	remaining := make([]RoundMessage, 0, len(draw.candidates))
+	for _, msg := range draw.candidates {
+		if !draw.disqualified[msg.Username] {
+			remaining = append(remaining, msg)
+		}
+	}
+	draw.winner = nil
+	if len(remaining) > 0 {
+		winner := pick(remaining, draw.scores)
+		draw.winner = &winner
+	}
+	return previous, draw.winner, nil
+}
+
+// rememberDraw keeps the candidates of a winner selection so an admin can invalidate the
+// winner within winner_appeal_window_seconds.
+func (h *Hub) rememberDraw(roundID int64, candidates []RoundMessage, winner RoundMessage, scores map[string]float64) {
+	window := time.Duration(h.settings().WinnerAppealWindowSeconds) * time.Second
+	if window <= 0 {
+		return
+	}
+	now := h.clock.Now()
+	h.draws.record(roundID, candidates, winner, scores, now, now.Add(-window))
+}
+
+// InvalidateWinner disqualifies the winner of a round, for example after a rule violation,
+// and re-draws among the remaining entrants. The correction is published to the winners
+// subject with a supersedes reference and broadcast as "winner_updated". Statistics move
+// to the new winner, who also receives the reward; points already granted are not taken back.
+// Every instance drops the round from its history cache.
+// Repeated appeals of the same round are allowed while the window is open.
+func (h *Hub) InvalidateWinner(roundID int64, reason, actor string) (WinnerCorrection, error) {
+	window := time.Duration(h.settings().WinnerAppealWindowSeconds) * time.Second
+	if window <= 0 {
+		return WinnerCorrection{}, ErrAppealWindowClosed
+	}
+	now := h.clock.Now()
+	var odds drawOdds
+	previous, winner, err := h.draws.redraw(roundID, now.Add(-window), func(candidates []RoundMessage, scores map[string]float64) RoundMessage {
+		var picked RoundMessage
+		picked, odds = h.pickWinner(roundID, candidates, scores)
+		return picked
+	})
+	if err != nil {
+		return WinnerCorrection{}, err
+	}
+
+	correction := WinnerCorrection{
+		RoundID:       roundID,
+		Winner:        winner,
+		Supersedes:    previous.ID,
+		Previous:      previous.Username,
+		Reason:        reason,
+		InvalidatedBy: actor,
+		InvalidatedAt: now,
+	}
+	newWinner := ""
+	if winner != nil {
+		newWinner = winner.Username
+	}
+	round, retained := h.recent.get(roundID)
+	if !retained {
+		round.Messages = h.rounds.messages(roundID)
+		round.EndedAt = now
+	}
+	redrawn := newRoundOdds(roundID, round.Messages, odds, winner, now)
+	h.recent.setWinner(roundID, winner, redrawn)
+	h.setSummaryWinner(roundID, newWinner)
+	if result := h.lastResult.Load(); result != nil && result.RoundID == roundID {
+		h.rememberResult(roundID, winner)
+	}
+	h.tournamentRoundWon(roundID, newWinner)
+	h.recordCooldown(roundID, newWinner)
+	h.publishWinnerCorrectionToNATS(correction)
+	h.indexRound(roundID, round.Messages, winner, round.EndedAt)
+	h.archiveRound(roundID, round.Messages, winner, round.Scores, redrawn)
+	h.invalidateRounds([]int64{roundID}, actor)
+	h.Audit(AuditAdminAction, previous.Username, "Winner invalidated by "+actor, previous.ID+": "+reason)
+	h.Logger.Infof("Winner %s of round %d invalidated by %s (%s), new winner: %q", previous.Username, roundID, actor, reason, newWinner)
+
+	update := map[string]interface{}{
+		"version":    "1.0",
+		"type":       "winner_updated",
+		"round_id":   roundID,
+		"winner":     winner,
+		"supersedes": previous.ID,
+		"previous":   previous.Username,
+		"reason":     reason,
+	}
+	if winner != nil && winner.AttachmentID != "" {
+		update["attachment_url"] = attachments.URL(winner.AttachmentID)
+	}
+	h.BroadcastMessage(update)
+	h.seriesRoundWon(roundID, newWinner)
+
+	h.revokeWin(previous.Username)
+	if winner != nil {
+		points := h.grantReward(winner.Username, roundID)
+		h.recordWin(winner.Username)
+		h.notifyWinner(roundID, *winner, points, 0, true)
+	}
+	return correction, nil
+}
@@ -0,0 +1,272 @@
+// internal/hub/archive.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/erilali/internal/archive"
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+const (
+	defaultArchiveQueueSize  = 64
+	defaultArchiveMaxRetries = 5
+	archiveInitialBackoff    = time.Second
+	archiveMaxBackoff        = time.Minute
+)
+
+// RoundArchive is the compacted record of a finished round written to the object store,
+// so its history survives the retention of the event bus.
+type RoundArchive struct {
+	RoundID    int64              `json:"round_id"`
+	Room       string             `json:"room"`
+	Messages   []RoundMessage     `json:"messages"`
+	Winner     *RoundMessage      `json:"winner"`           // nil when no submission won
+	Scores     map[string]float64 `json:"scores,omitempty"` // winner odds by message ID, see winner_scoring
+	Odds       *RoundOdds         `json:"odds,omitempty"`   // how the winner was drawn, see odds.go
+	Stats      *RoundSummary      `json:"stats,omitempty"`
+	Tags       []string           `json:"tags,omitempty"` // see round_tags and /api/admin/rounds/{id}/tags
+	ArchivedAt time.Time          `json:"archived_at"`
+}
+
+// ArchiveStats reports the state of the round archive worker.
+type ArchiveStats struct {
+	Bucket   string `json:"bucket"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Archived uint64 `json:"archived"`
+	Retries  uint64 `json:"retries"`
+	Failed   uint64 `json:"failed"`  // given up after all retries
+	Dropped  uint64 `json:"dropped"` // rejected because the queue was full
+}
+
+// archiveJob is a round archive waiting to be uploaded.
+type archiveJob struct {
+	roundID int64
+	key     string
+	data    []byte
+}
+
+// archiveQueue uploads round archives in the background, retrying with backoff, so a slow
+// or unavailable object store never delays the end of a round.
+type archiveQueue struct {
+	store      *archive.S3Store
+	prefix     string
+	expireDays int
+	logger     *logger.Logger
+	jobs       chan archiveJob
+	maxRetries int
+
+	archived atomic.Uint64
+	retries  atomic.Uint64
+	failed   atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// newArchiveQueue opens the object store configured with archive_endpoint and
+// archive_bucket. It returns nil when archival is disabled or misconfigured.
+func newArchiveQueue(cfg config.Config, logger *logger.Logger) *archiveQueue {
+	if cfg.ArchiveEndpoint == "" {
+		return nil
+	}
+	store, err := archive.NewS3Store(cfg.ArchiveEndpoint, cfg.ArchiveRegion, cfg.ArchiveBucket, cfg.ArchiveAccessKey, cfg.ArchiveSecretKey)
+	if err != nil {
+		logger.Errorf("Round archival disabled: %v", err)
+		return nil
+	}
+	size := cfg.ArchiveQueueSize
+	if size <= 0 {
+		size = defaultArchiveQueueSize
+	}
+	maxRetries := cfg.ArchiveMaxRetries
+	if maxRetries < 0 {
+		maxRetries = defaultArchiveMaxRetries
+	}
+	return &archiveQueue{
+		store:      store,
+		prefix:     cfg.ArchivePrefix,
+		expireDays: cfg.ArchiveExpireDays,
+		logger:     logger,
+		jobs:       make(chan archiveJob, size),
+		maxRetries: maxRetries,
+	}
+}
+
+// objectKey returns the key a round is archived under, namespaced by room.
+func (q *archiveQueue) objectKey(room string, roundID int64) string {
+	return fmt.Sprintf("%s%s/%d.json", q.prefix, room, roundID)
+}
+
+// enqueue hands an archive to the background worker without blocking.
+// Archives are dropped and counted when the queue is full.
+func (q *archiveQueue) enqueue(job archiveJob) {
+	select {
+	case q.jobs <- job:
+	default:
+		q.dropped.Add(1)
+		q.logger.Errorf("Archive queue full, dropping archive of round %d", job.roundID)
+	}
+}
+
+// run applies the lifecycle rule, then uploads queued archives until ctx is canceled.
+// Archives still queued are uploaded before it returns, without further retries.
+func (q *archiveQueue) run(ctx context.Context) {
+	if q.expireDays > 0 {
+		if err := q.store.SetExpiration(ctx, q.prefix, q.expireDays); err != nil {
+			q.logger.Errorf("Failed to set expiration of archived rounds in bucket %s: %v", q.store.Bucket(), err)
+		} else {
+			q.logger.Infof("Archived rounds in bucket %s expire after %d days", q.store.Bucket(), q.expireDays)
+		}
+	}
+	for {
+		select {
+		case job := <-q.jobs:
+			q.upload(ctx, job)
+		case <-ctx.Done():
+			for {
+				select {
+				case job := <-q.jobs:
+					q.upload(ctx, job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// upload writes one archive, retrying with exponential backoff while ctx is not canceled.
+func (q *archiveQueue) upload(ctx context.Context, job archiveJob) {
+	backoff := archiveInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := q.store.Put(context.Background(), job.key, job.data, "application/json")
+		if err == nil {
+			q.archived.Add(1)
+			return
+		}
+		if attempt >= q.maxRetries || ctx.Err() != nil {
+			q.failed.Add(1)
+			q.logger.Errorf("Failed to archive round %d to %s after %d attempts: %v", job.roundID, job.key, attempt+1, err)
+			return
+		}
+		q.retries.Add(1)
+		q.logger.Warnf("Archiving round %d failed, retrying in %v: %v", job.roundID, backoff, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, archiveMaxBackoff)
+	}
+}
+
+// stats returns a snapshot of the queue metrics.
+func (q *archiveQueue) stats() ArchiveStats {
+	return ArchiveStats{
+		Bucket:   q.store.Bucket(),
+		Depth:    len(q.jobs),
+		Capacity: cap(q.jobs),
+		Archived: q.archived.Load(),
+		Retries:  q.retries.Load(),
+		Failed:   q.failed.Load(),
+		Dropped:  q.dropped.Load(),
+	}
+}
+
+// archiveRound queues the archive of a finished round with submissions. Rounds are
+// archived again when an appeal changes their winner, replacing the earlier object.
+func (h *Hub) archiveRound(roundID int64, messages []RoundMessage, winner *RoundMessage, scores map[string]float64, odds *RoundOdds) {
+	if h.archiver == nil || len(messages) == 0 {
+		return
+	}
+	record := RoundArchive{
+		RoundID:    roundID,
+		Room:       h.room,
+		Messages:   messages,
+		Winner:     winner,
+		Scores:     scores,
+		Odds:       odds,
+		Stats:      h.roundSummary(roundID),
+		Tags:       h.search.tagsOf(roundKey{room: h.room, roundID: roundID}, h.settings().RoundTags),
+		ArchivedAt: h.clock.Now(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		h.Logger.Errorf("Failed to marshal archive of round %d: %v", roundID, err)
+		return
+	}
+	h.archiver.enqueue(archiveJob{roundID: roundID, key: h.archiver.objectKey(record.Room, roundID), data: data})
+}
+
+// ArchiveStats returns the metrics of the round archive worker, and false when
+// archival is disabled.
+func (h *Hub) ArchiveStats() (ArchiveStats, bool) {
+	if h.archiver == nil {
+		return ArchiveStats{}, false
+	}
+	return h.archiver.stats(), true
+}
+
+// eraseUser rewrites every archived round holding a submission or win that match accepts,
+// dropping the submissions and anonymizing the winner. It returns how many archives were
+// rewritten and stops at the first object it cannot read or write.
+func (q *archiveQueue) eraseUser(ctx context.Context, match func(username string) bool) (int, error) {
+	keys, err := q.store.List(ctx, q.prefix)
+	if err != nil {
+		return 0, fmt.Errorf("listing archived rounds: %w", err)
+	}
+	rewritten := 0
+	for _, key := range keys {
+		data, err := q.store.Get(ctx, key)
+		if err != nil {
+			return rewritten, fmt.Errorf("reading %s: %w", key, err)
+		}
+		var record RoundArchive
+		if err := json.Unmarshal(data, &record); err != nil {
+			q.logger.Warnf("Skipping archive %s during erasure: %v", key, err)
+			continue
+		}
+		if !record.eraseUser(match) {
+			continue
+		}
+		if data, err = json.Marshal(record); err != nil {
+			return rewritten, fmt.Errorf("encoding %s: %w", key, err)
+		}
+		if err := q.store.Put(ctx, key, data, "application/json"); err != nil {
+			return rewritten, fmt.Errorf("writing %s: %w", key, err)
+		}
+		rewritten++
+	}
+	return rewritten, nil
+}
+
+// eraseUser drops the submissions match accepts from an archive, together with their
+// scores, and anonymizes its winner. It reports whether the archive changed.
+func (a *RoundArchive) eraseUser(match func(username string) bool) bool {
+	changed := false
+	kept := a.Messages[:0]
+	for _, msg := range a.Messages {
+		if match(msg.Username) {
+			delete(a.Scores, msg.ID)
+			changed = true
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	a.Messages = kept
+	if a.Winner != nil && match(a.Winner.Username) {
+		a.Winner = erasedWinner(a.Winner)
+		changed = true
+	}
+	if a.Odds != nil && a.Odds.eraseUser(match) {
+		changed = true
+	}
+	if a.Stats != nil && a.Stats.eraseUser(match) {
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,76 @@
+// internal/hub/audit.go
+package hub
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/erilali/internal/message"
+)
+
+// Audit event types. Each is published on "audit.<event>".
+const (
+	AuditConnect             = "connect"
+	AuditDisconnect          = "disconnect"
+	AuditBan                 = "ban"
+	AuditAdminAction         = "admin_action"
+	AuditModerationRejection = "moderation_rejection"
+	AuditSignIn              = "sign_in"
+	AuditAnnouncement        = "announcement"
+	AuditUserDataErased      = "user_data_erased"
+	AuditAdminLogin          = "admin_login"
+)
+
+// AuditEventTypes lists every audit event type, used by the API to query all subjects.
+var AuditEventTypes = []string{
+	AuditConnect,
+	AuditDisconnect,
+	AuditBan,
+	AuditAdminAction,
+	AuditModerationRejection,
+	AuditSignIn,
+	AuditAnnouncement,
+	AuditUserDataErased,
+	AuditAdminLogin,
+}
+
+// Audit publishes a structured audit record to the AUDIT stream.
+// Errors are logged; auditing never blocks the caller's flow on failure.
+func (h *Hub) Audit(event, username, msg, detail string) {
+	h.publishAudit(message.LogEntry{
+		Event:    event,
+		Username: username,
+		Message:  msg,
+		Detail:   detail,
+	})
+}
+
+// auditClient records an audit event about a connected client, including where it connected from.
+func (h *Hub) auditClient(event string, client *Client, msg, detail string) {
+	h.publishAudit(message.LogEntry{
+		Event:     event,
+		Username:  client.Username(),
+		Message:   msg,
+		Detail:    detail,
+		RemoteIP:  client.Metadata.RemoteIP,
+		UserAgent: client.Metadata.UserAgent,
+		Country:   client.Metadata.Country,
+		SessionID: client.SessionID,
+	})
+}
+
+func (h *Hub) publishAudit(entry message.LogEntry) {
+	if h.Bus == nil {
+		return
+	}
+	entry.Timestamp = h.clock.Now().UTC().Format(time.RFC3339Nano)
+	event := entry.Event
+	data, err := json.Marshal(entry)
+	if err != nil {
+		h.Logger.Errorf("Failed to marshal audit entry: %v", err)
+		return
+	}
+	if err := h.Bus.Publish("audit."+event, data); err != nil {
+		h.Logger.Errorf("Failed to publish audit event %s: %v", event, err)
+	}
+}
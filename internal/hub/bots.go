@@ -0,0 +1,88 @@
+// internal/hub/bots.go
+package hub
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+)
+
+// Error codes sent to service account clients.
+const (
+	ScopeForbiddenCode = "SCOPE_FORBIDDEN" // the account's scope does not allow the message
+	RateLimitedCode    = "RATE_LIMITED"    // the account sent frames faster than bot_rate_limit_per_second
+)
+
+// playMessageTypes need the submit scope when sent by a service account.
+var playMessageTypes = map[string]bool{
+	"client_message":   true,
+	"edit_message":     true,
+	"withdraw_message": true,
+	"reaction":         true,
+	"auth":             true,
+}
+
+// frameLimiter is a token bucket limiting how fast a bot may send frames.
+type frameLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+// newFrameLimiter returns a limiter, or nil when perSecond disables limiting.
+func newFrameLimiter(perSecond float64, burst int, now time.Time) *frameLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &frameLimiter{perSecond: perSecond, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// allow takes a token and reports whether one was available.
+func (l *frameLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.perSecond)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// botAllowed applies a service account's rate limit and scope to a frame before it is
+// handled. Frames of regular users always pass.
+func (h *Hub) botAllowed(client *Client, messageType string) bool {
+	if client.account == nil {
+		return true
+	}
+	if !client.frames.allow(h.clock.Now()) {
+		h.SendErrorCode(client, RateLimitedCode, "Too many messages, slow down")
+		return false
+	}
+	if playMessageTypes[messageType] && !client.account.Allows(config.ScopeSubmit) {
+		h.SendErrorCode(client, ScopeForbiddenCode, "This service account is read-only")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+// internal/hub/broadcast.go
+package hub
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sharedPayloadCount is how many recent broadcasts keep their MessagePack form.
+const sharedPayloadCount = 64
+
+// broadcast delivers a message to every client that accepts it. With more clients than
+// broadcast_partition_threshold they are split into broadcast_partitions contiguous
+// partitions sent to concurrently. All of them share the payload, encoded once for
+// JSON and, when MessagePack clients receive it, once for MessagePack. Clients whose
+// queue is full are removed after every partition finished, since only the Run
+// goroutine may remove clients. It must only be called from the Run goroutine.
+func (h *Hub) broadcast(message OutboundMessage) {
+	started := time.Now()
+	clients := h.broadcastOrder(h.clients.snapshot(), message.Type)
+	h.payloads.prepare(message, clients)
+
+	partitions := h.broadcastPartitions(len(clients))
+	var slow []*Client
+	if partitions <= 1 {
+		slow = h.sendBroadcast(clients, message)
+	} else {
+		size := (len(clients) + partitions - 1) / partitions
+		results := make([][]*Client, partitions)
+		var wg sync.WaitGroup
+		for i := 0; i < partitions; i++ {
+			part := clients[min(i*size, len(clients)):min((i+1)*size, len(clients))]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = h.sendBroadcast(part, message)
+			}()
+		}
+		wg.Wait()
+		for _, result := range results {
+			slow = append(slow, result...)
+		}
+	}
+	for _, client := range slow {
+		// Assume client is disconnected or slow.
+		// Let the read/write pumps handle the cleanup.
+		h.removeClient(client)
+	}
+	h.broadcasts.record(time.Since(started), partitions > 1)
+}
+
+// sendBroadcast queues a message for clients and returns those whose queue was full.
+func (h *Hub) sendBroadcast(clients []*Client, message OutboundMessage) []*Client {
+	var slow []*Client
+	for _, client := range clients {
+		if !client.Accepts(message.Type) || h.chaos.dropBroadcast(h.rng) {
+			continue
+		}
+		select {
+		case client.Send <- message.Data:
+			h.trackDelivery(client, message)
+		default:
+			slow = append(slow, client)
+		}
+	}
+	return slow
+}
+
+// broadcastPartitions returns how many partitions a broadcast to clients is split into,
+// 1 at or below broadcast_partition_threshold or when partitioning is disabled.
+func (h *Hub) broadcastPartitions(clients int) int {
+	cfg := h.settings()
+	if cfg.BroadcastPartitionThreshold <= 0 || clients <= cfg.BroadcastPartitionThreshold {
+		return 1
+	}
+	partitions := cfg.BroadcastPartitions
+	if partitions <= 0 {
+		partitions = runtime.GOMAXPROCS(0)
+	}
+	return max(min(partitions, clients), 1)
+}
+
+// sharedPayloads keeps the MessagePack form of recent broadcasts, encoded once when the
+// broadcast goes out and reused by the write pump of every MessagePack client. Entries
+// are matched by the identity of the JSON payload, which all recipients share.
+type sharedPayloads struct {
+	mu      sync.RWMutex
+	entries [sharedPayloadCount]sharedPayload
+	next    int
+}
+
+type sharedPayload struct {
+	json    []byte
+	msgpack []byte
+}
+
+// prepare encodes a broadcast for MessagePack once if any of clients will receive it
+// that way.
+func (p *sharedPayloads) prepare(message OutboundMessage, clients []*Client) {
+	if len(message.Data) == 0 {
+		return
+	}
+	for _, client := range clients {
+		if client.Subprotocol != SubprotocolMsgpack || !client.Accepts(message.Type) {
+			continue
+		}
+		encoded, err := encodeMsgpack(message.Data)
+		if err != nil {
+			return // the write pumps report the error
+		}
+		p.mu.Lock()
+		p.entries[p.next] = sharedPayload{json: message.Data, msgpack: encoded}
+		p.next = (p.next + 1) % sharedPayloadCount
+		p.mu.Unlock()
+		return
+	}
+}
+
+// msgpack returns the MessagePack form of a JSON message, shared with the other
+// recipients when it is a recent broadcast and encoded on the spot otherwise.
+func (p *sharedPayloads) msgpack(data []byte) ([]byte, error) {
+	if len(data) > 0 {
+		p.mu.RLock()
+		for _, entry := range p.entries {
+			if len(entry.json) == len(data) && &entry.json[0] == &data[0] {
+				p.mu.RUnlock()
+				return entry.msgpack, nil
+			}
+		}
+		p.mu.RUnlock()
+	}
+	return encodeMsgpack(data)
+}
+
+// BroadcastStats describes how long broadcasts took to reach every client's queue.
+type BroadcastStats struct {
+	Broadcasts  uint64  `json:"broadcasts"`
+	Partitioned uint64  `json:"partitioned"` // split across broadcast_partitions goroutines
+	AvgMs       float64 `json:"avg_ms"`
+	MaxMs       float64 `json:"max_ms"`
+}
+
+// broadcastTimings accumulates the fan-out time of broadcasts.
+type broadcastTimings struct {
+	count       atomic.Uint64
+	partitioned atomic.Uint64
+	total       atomic.Int64 // nanoseconds
+	max         atomic.Int64 // nanoseconds
+}
+
+func (t *broadcastTimings) record(elapsed time.Duration, partitioned bool) {
+	t.count.Add(1)
+	if partitioned {
+		t.partitioned.Add(1)
+	}
+	t.total.Add(int64(elapsed))
+	for {
+		current := t.max.Load()
+		if int64(elapsed) <= current || t.max.CompareAndSwap(current, int64(elapsed)) {
+			return
+		}
+	}
+}
+
+func (t *broadcastTimings) stats() BroadcastStats {
+	stats := BroadcastStats{
+		Broadcasts:  t.count.Load(),
+		Partitioned: t.partitioned.Load(),
+		MaxMs:       float64(t.max.Load()) / float64(time.Millisecond),
+	}
+	if stats.Broadcasts > 0 {
+		stats.AvgMs = float64(t.total.Load()) / float64(stats.Broadcasts) / float64(time.Millisecond)
+	}
+	return stats
+}
@@ -0,0 +1,81 @@
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+// BenchmarkBroadcast measures the time from handing a round_start of about 600 bytes to
+// the hub until every client has read it off its queue, at several room sizes, sent
+// from a single goroutine and partitioned over one goroutine per CPU. A tenth of the
+// clients read MessagePack as their write pumps would. Clients are simulated in memory,
+// so the results isolate the hub's fan-out.
+func BenchmarkBroadcast(b *testing.B) {
+	for _, clients := range []int{1000, 10000, 50000} {
+		for _, partitioned := range []bool{false, true} {
+			mode := "single"
+			cfg := config.DefaultConfig()
+			cfg.BroadcastPartitionThreshold = 0
+			if partitioned {
+				mode = "partitioned"
+				cfg.BroadcastPartitionThreshold = 1
+			}
+			b.Run(fmt.Sprintf("clients=%d/%s", clients, mode), func(b *testing.B) {
+				benchmarkBroadcast(b, cfg, clients, 0.1)
+			})
+		}
+	}
+}
+
+func benchmarkBroadcast(b *testing.B, cfg config.Config, clients int, msgpackShare float64) {
+	h := newHub(cfg, nil, nil, nil, logger.NewLogger("broadcast-benchmark"))
+	var received sync.WaitGroup
+	var readers sync.WaitGroup
+	msgpackClients := int(float64(clients) * msgpackShare)
+	for i := 0; i < clients; i++ {
+		client := &Client{Send: make(chan []byte, 256)}
+		if i < msgpackClients {
+			client.Subprotocol = SubprotocolMsgpack
+		}
+		h.clients.add(client)
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for data := range client.Send {
+				if client.Subprotocol == SubprotocolMsgpack {
+					h.payloads.msgpack(data)
+				}
+				received.Done()
+			}
+		}()
+	}
+	defer func() {
+		for _, client := range h.clients.snapshot() {
+			close(client.Send)
+		}
+		readers.Wait()
+	}()
+
+	data, err := json.Marshal(map[string]interface{}{
+		"version": "1.0",
+		"type":    "round_start",
+		"data":    int64(1),
+		"message": strings.Repeat("x", 512),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(h.broadcastPartitions(clients)), "partitions")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		received.Add(clients)
+		h.broadcast(OutboundMessage{Type: "round_start", Data: data})
+		received.Wait()
+	}
+}
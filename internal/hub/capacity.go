@@ -0,0 +1,86 @@
+// internal/hub/capacity.go
+package hub
+
+import (
+	"context"
+	"time"
+)
+
+// closeSubmissionsEarly closes the submission window of a round that reached
+// max_submissions_per_round. With early_close_remaining_seconds set, the round is also
+// cut short to end that long after submissions closed.
+func (h *Hub) closeSubmissionsEarly(roundID int64) {
+	remaining := time.Duration(h.settings().EarlyCloseRemainingSeconds) * time.Second
+
+	h.Mu.Lock()
+	if !h.RoundActive || h.CurrentRoundID != roundID {
+		h.Mu.Unlock()
+		return
+	}
+	now := h.clock.Now()
+	if !now.Before(h.submissionsCloseAt) {
+		// Already closed, by the cap or the regular window.
+		h.Mu.Unlock()
+		return
+	}
+	h.submissionsCloseAt = now
+	h.roundTiming.SubmissionDeadline = now
+	shortened := remaining > 0 && now.Add(remaining).Before(h.roundTiming.EndsAt)
+	if shortened {
+		h.roundTiming.EndsAt = now.Add(remaining)
+	}
+	timing := h.roundTiming
+	h.Mu.Unlock()
+
+	closed := map[string]interface{}{
+		"version": "1.0",
+		"type":    "submissions_closed",
+		"data":    roundID,
+		"reason":  "max_submissions",
+	}
+	timing.addTo(closed)
+	h.BroadcastMessage(closed)
+	h.Logger.Infof("Round %d reached %d submissions, submissions closed early", roundID, h.settings().MaxSubmissionsPerRound)
+
+	if shortened {
+		h.clock.AfterFunc(remaining, func() { h.cutRound(roundID) })
+	}
+}
+
+// cutRound wakes the round timer to end roundID before its scheduled end.
+func (h *Hub) cutRound(roundID int64) {
+	select {
+	case h.roundCut <- roundID:
+	default:
+	}
+}
+
+// idleWake is sent on roundCut to wake a round timer that skipped its round because no
+// client was connected. Round IDs are never zero.
+const idleWake int64 = 0
+
+// waitRoundEnd blocks until a round of the given length is over or the round is cut
+// short with cutRound, or, after a skipped round, until a client joins. Stale cuts for
+// earlier rounds are ignored. It returns false if ctx was canceled first.
+func (h *Hub) waitRoundEnd(ctx context.Context, length time.Duration) bool {
+	h.Mu.RLock()
+	roundID, active, idle := h.CurrentRoundID, h.RoundActive, h.roundsIdle
+	h.Mu.RUnlock()
+
+	done := make(chan struct{})
+	timer := h.clock.AfterFunc(length, func() { close(done) })
+	for {
+		select {
+		case <-done:
+			return true
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case cut := <-h.roundCut:
+			if (active && cut == roundID) || (idle && cut == idleWake) {
+				timer.Stop()
+				return true
+			}
+		}
+	}
+}
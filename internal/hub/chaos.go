@@ -0,0 +1,99 @@
+// internal/hub/chaos.go
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoNATSConnection is returned when a NATS disconnect is injected without a connection.
+var ErrNoNATSConnection = errors.New("not connected to NATS")
+
+// ChaosSettings are the failures injected in chaos mode, for resilience drills.
+type ChaosSettings struct {
+	BroadcastDropPercent int `json:"broadcast_drop_percent"` // share of broadcast deliveries to clients silently dropped
+	PublishDelayMs       int `json:"publish_delay_ms"`       // delay before every event bus publish
+}
+
+// chaosState holds the injected failures. A nil state injects none.
+type chaosState struct {
+	dropPercent  atomic.Int32
+	publishDelay atomic.Int64 // nanoseconds
+}
+
+// dropBroadcast reports whether a broadcast delivery should be dropped, drawing from rng.
+func (c *chaosState) dropBroadcast(rng *rand.Rand) bool {
+	if c == nil {
+		return false
+	}
+	percent := c.dropPercent.Load()
+	return percent > 0 && rng.Int31n(100) < percent
+}
+
+// delay returns how long event bus publishes are held back.
+func (c *chaosState) delay() time.Duration {
+	return time.Duration(c.publishDelay.Load())
+}
+
+// ChaosSettings returns the failures currently injected.
+func (h *Hub) ChaosSettings() ChaosSettings {
+	if h.chaos == nil {
+		return ChaosSettings{}
+	}
+	return ChaosSettings{
+		BroadcastDropPercent: int(h.chaos.dropPercent.Load()),
+		PublishDelayMs:       int(h.chaos.delay() / time.Millisecond),
+	}
+}
+
+// ApplyChaosSettings changes the injected failures. It fails unless chaos_mode is enabled.
+func (h *Hub) ApplyChaosSettings(s ChaosSettings, actor string) error {
+	switch {
+	case h.chaos == nil:
+		return errors.New("chaos mode is disabled")
+	case s.BroadcastDropPercent < 0 || s.BroadcastDropPercent > 100:
+		return errors.New("broadcast_drop_percent must be between 0 and 100")
+	case s.PublishDelayMs < 0:
+		return errors.New("publish_delay_ms must not be negative")
+	}
+	h.chaos.dropPercent.Store(int32(s.BroadcastDropPercent))
+	h.chaos.publishDelay.Store(int64(time.Duration(s.PublishDelayMs) * time.Millisecond))
+
+	h.Audit(AuditAdminAction, actor, "Chaos settings changed", fmt.Sprintf("%+v", s))
+	h.Logger.Warnf("Chaos settings changed by %s: %+v", actor, s)
+	return nil
+}
+
+// ForceNATSReconnect drops the NATS connection so the reconnect paths of the server and
+// its subscriptions can be observed. The client library reconnects on its own.
+func (h *Hub) ForceNATSReconnect(actor string) error {
+	if h.NatsConn == nil || !h.NatsConn.IsConnected() {
+		return ErrNoNATSConnection
+	}
+	if err := h.NatsConn.ForceReconnect(); err != nil {
+		return err
+	}
+	h.Audit(AuditAdminAction, actor, "NATS connection dropped for a chaos drill", "")
+	h.Logger.Warnf("NATS connection dropped by %s", actor)
+	return nil
+}
+
+// KillRandomClients closes the connections of up to n randomly chosen clients without a
+// close frame, as a network failure would, and returns the names of their users.
+func (h *Hub) KillRandomClients(n int, actor string) []string {
+	clients := h.clients.snapshot()
+	h.rng.Shuffle(len(clients), func(i, j int) { clients[i], clients[j] = clients[j], clients[i] })
+	killed := make([]string, 0, min(n, len(clients)))
+	for _, client := range clients[:min(n, len(clients))] {
+		client.Conn.Close()
+		killed = append(killed, client.Username())
+	}
+	if len(killed) > 0 {
+		h.Audit(AuditAdminAction, actor, "Connections killed for a chaos drill", fmt.Sprintf("%v", killed))
+		h.Logger.Warnf("Killed %d client connection(s) for %s: %v", len(killed), actor, killed)
+	}
+	return killed
+}
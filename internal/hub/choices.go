@@ -0,0 +1,139 @@
+// internal/hub/choices.go
+package hub
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/message"
+)
+
+// InvalidChoiceCode is the error code sent for submissions without a valid option index in
+// choices mode, and for option indexes sent to rounds without options.
+const InvalidChoiceCode = "INVALID_CHOICE"
+
+var errInvalidChoice = errors.New("Invalid choice")
+
+// nextChoicesLocked returns the choice set of a starting round and advances to the next
+// one, or nil in free mode or without usable choice sets. Callers must hold Mu.
+func (h *Hub) nextChoicesLocked() *config.ChoiceSet {
+	cfg := h.settings()
+	if cfg.RoundMode != config.RoundModeChoices {
+		return nil
+	}
+	sets := make([]config.ChoiceSet, 0, len(cfg.ChoiceSets))
+	for _, set := range cfg.ChoiceSets {
+		if len(set.Options) >= 2 && (set.Answer == nil || (*set.Answer >= 0 && *set.Answer < len(set.Options))) {
+			sets = append(sets, set)
+		}
+	}
+	if len(sets) == 0 {
+		h.Logger.Warn("Round mode is choices but no choice set has two options and a valid answer, playing a free round")
+		return nil
+	}
+	set := sets[h.nextChoiceSet%len(sets)]
+	h.nextChoiceSet++
+	return &set
+}
+
+// setRoundChoices records the choice set a round plays, nil for free rounds.
+func (h *Hub) setRoundChoices(roundID int64, choices *config.ChoiceSet) {
+	b := h.rounds.bucket(roundID)
+	b.mu.Lock()
+	b.choices = choices
+	b.mu.Unlock()
+}
+
+// roundChoices returns the choice set of a round, or nil when it is played in free mode.
+func (h *Hub) roundChoices(roundID int64) *config.ChoiceSet {
+	b := h.rounds.bucket(roundID)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.choices
+}
+
+// addRoundChoices adds the prompt and options of a round to a round_start or state_sync
+// round. The answer stays on the server.
+func addRoundChoices(m map[string]interface{}, choices *config.ChoiceSet) {
+	if choices != nil {
+		m["choices"] = message.RoundChoices{Prompt: choices.Prompt, Options: choices.Options}
+	}
+}
+
+// applyChoice validates the option index of a submission against the round's choice set
+// and replaces the submission text with the chosen option.
+func (h *Hub) applyChoice(roundID int64, submission *message.Submission) error {
+	choices := h.roundChoices(roundID)
+	if choices == nil {
+		if submission.Choice != nil {
+			return fmt.Errorf("%w: this round has no options, submit text instead", errInvalidChoice)
+		}
+		return nil
+	}
+	if submission.Choice == nil {
+		return fmt.Errorf("%w: this round expects the index of one of its %d options", errInvalidChoice, len(choices.Options))
+	}
+	if index := *submission.Choice; index < 0 || index >= len(choices.Options) {
+		return fmt.Errorf("%w: expected an option index from 0 to %d", errInvalidChoice, len(choices.Options)-1)
+	}
+	submission.Text = choices.Options[*submission.Choice]
+	return nil
+}
+
+// sendSubmissionError reports a submission rejected by parseSubmission, with
+// InvalidChoiceCode for option indexes that the round does not accept.
+func (h *Hub) sendSubmissionError(client *Client, prefix string, err error) {
+	if errors.Is(err, errInvalidChoice) {
+		h.SendErrorCode(client, InvalidChoiceCode, prefix+err.Error())
+		return
+	}
+	h.SendErrorMessage(client, prefix+err.Error())
+}
+
+// tallyChoices counts the messages of a round per option. The winning options are the
+// correct one when the set has an answer, otherwise the most popular ones.
+func tallyChoices(choices *config.ChoiceSet, messages []RoundMessage) *message.ChoiceTally {
+	tally := &message.ChoiceTally{
+		RoundChoices:   message.RoundChoices{Prompt: choices.Prompt, Options: choices.Options},
+		Counts:         make([]int, len(choices.Options)),
+		WinningOptions: []int{},
+		Answer:         choices.Answer,
+	}
+	for _, msg := range messages {
+		if msg.Choice != nil && *msg.Choice >= 0 && *msg.Choice < len(tally.Counts) {
+			tally.Counts[*msg.Choice]++
+		}
+	}
+	if choices.Answer != nil {
+		tally.WinningOptions = append(tally.WinningOptions, *choices.Answer)
+		return tally
+	}
+	most := 0
+	for _, count := range tally.Counts {
+		most = max(most, count)
+	}
+	for index, count := range tally.Counts {
+		if most > 0 && count == most {
+			tally.WinningOptions = append(tally.WinningOptions, index)
+		}
+	}
+	return tally
+}
+
+// winningChoices keeps the candidates that picked one of the tally's winning options.
+func winningChoices(tally *message.ChoiceTally, candidates []RoundMessage) []RoundMessage {
+	winning := make([]RoundMessage, 0, len(candidates))
+	for _, msg := range candidates {
+		if msg.Choice == nil {
+			continue
+		}
+		for _, index := range tally.WinningOptions {
+			if *msg.Choice == index {
+				winning = append(winning, msg)
+				break
+			}
+		}
+	}
+	return winning
+}
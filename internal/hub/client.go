@@ -2,15 +2,249 @@
 package hub
 
 import (
+	"encoding/json"
+	"sync"
 	"time"
 
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/message"
 	"github.com/gorilla/websocket"
 )
 
+// Capabilities describes the optional features a client declared in its "hello" message.
+// Clients that never send "hello" keep the zero value, which matches the legacy protocol.
+type Capabilities = message.Capabilities
+
+// optionalMessageTypes are broadcast types a client may opt out of with a "subscribe" message.
+// Round lifecycle and winner messages are always delivered.
+var optionalMessageTypes = map[string]bool{
+	"countdown":       true,
+	"reaction_counts": true,
+	"presence":        true,
+	"user_joined":     true,
+	"user_left":       true,
+	"time_sync":       true,
+	"nudge":           true,
+}
+
 // Client represents a connected user.
 type Client struct {
-	Username   string
-	Conn       *websocket.Conn
-	Send       chan []byte
-	LastActive time.Time
+	Conn        *websocket.Conn
+	Send        chan []byte
+	LastActive  time.Time // guarded by mu, use Touch and Info
+	ConnectedAt time.Time
+	SessionID   string             // identifies this connection, from the hub's ID generator
+	Metadata    ConnectionMetadata // remote address, user agent and country captured on connect
+	Subprotocol string             // negotiated WebSocket subprotocol, empty for legacy JSON clients
+
+	account *config.ServiceAccount // set for bots connected with a service account token
+	frames  *frameLimiter          // rate limit of bots, nil for users
+	ping    *pinger                // WebSocket ping pacing and round trip
+
+	mu             sync.RWMutex
+	username       string // changes when a guest signs in, use Username
+	guest          bool   // connected without a username and given a generated one
+	capabilities   Capabilities
+	excluded       map[string]bool // optional message types the client opted out of
+	rtt            time.Duration   // last application level round trip time
+	latencyStrikes int             // consecutive pongs above the latency threshold
+	preferences    json.RawMessage // stored preferences of a registered user, sent in state_sync
+
+	timing ConnectionTiming // captured on upgrade, guarded by mu for the first message latency
+
+	roleToken string // role_token query parameter, grants the owner or moderator role in a room
+
+	resumed        bool      // continues a session after a disconnect, see resume.go
+	resumeIssuedAt time.Time // when the last resume token was sent, guarded by mu
+	resumeUsername string    // name the last resume token was issued for, guarded by mu
+
+	appState    string    // focused or backgrounded from the last heartbeat, empty before the first one
+	queueDepth  int       // unprocessed messages the client reported in its last heartbeat
+	heartbeatAt time.Time // when the last heartbeat arrived
+
+	closeFrame []byte // close message the write pump sends once Send is closed, nil for an empty one; guarded by mu
+
+	waiting bool // queued in the waiting room, guarded by Hub.admissionMu
+}
+
+// Username returns the client's current name.
+func (c *Client) Username() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username
+}
+
+// Bot reports whether the client connected with a service account token.
+func (c *Client) Bot() bool {
+	return c.account != nil
+}
+
+// Guest reports whether the client plays under a generated guest name.
+func (c *Client) Guest() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.guest
+}
+
+// signIn replaces a guest's generated name with a registered one.
+func (c *Client) signIn(username string) {
+	c.mu.Lock()
+	c.username = username
+	c.guest = false
+	c.mu.Unlock()
+}
+
+// Preferences returns the stored preferences of the client's user, nil for guests and
+// bots or when they could not be loaded.
+func (c *Client) Preferences() json.RawMessage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.preferences
+}
+
+func (c *Client) setPreferences(prefs json.RawMessage) {
+	c.mu.Lock()
+	c.preferences = prefs
+	c.mu.Unlock()
+}
+
+// Capabilities returns the features negotiated with the client.
+func (c *Client) Capabilities() Capabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities
+}
+
+// SetCapabilities records the features declared by the client.
+func (c *Client) SetCapabilities(caps Capabilities) {
+	c.mu.Lock()
+	c.capabilities = caps
+	c.mu.Unlock()
+}
+
+// SetExcluded replaces the set of optional message types the client does not want.
+func (c *Client) SetExcluded(types []string) {
+	excluded := make(map[string]bool, len(types))
+	for _, t := range types {
+		excluded[t] = true
+	}
+	c.mu.Lock()
+	c.excluded = excluded
+	c.mu.Unlock()
+}
+
+// Excluded returns the optional message types the client opted out of.
+func (c *Client) Excluded() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	types := make([]string, 0, len(c.excluded))
+	for t := range c.excluded {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Accepts reports whether a message of the given type should be sent to the client.
+func (c *Client) Accepts(messageType string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.excluded[messageType]
+}
+
+// closeWith makes the write pump end the connection with code and text once the
+// messages queued before it are written, instead of the read pump closing it at once.
+func (c *Client) closeWith(code int, text string) {
+	c.mu.Lock()
+	c.closeFrame = websocket.FormatCloseMessage(code, text)
+	c.mu.Unlock()
+}
+
+// closeMessage returns the close message set by closeWith, nil when none was set.
+func (c *Client) closeMessage() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closeFrame
+}
+
+// ClientInfo is a snapshot of a client's connection details for the admin API.
+type ClientInfo struct {
+	Username     string       `json:"username"`
+	SessionID    string       `json:"session_id"`
+	Guest        bool         `json:"guest,omitempty"`
+	ConnectedAt  time.Time    `json:"connected_at"`
+	LastActive   time.Time    `json:"last_active"`
+	RTTMillis    float64      `json:"rtt_ms"`
+	PingRTTMs    float64      `json:"ping_rtt_ms"`           // round trip of the last WebSocket ping
+	PingInterval float64      `json:"ping_interval_seconds"` // current WebSocket ping interval
+	Quality      string       `json:"quality"`               // good or degraded, see slow_pong_ms
+	AppState     string       `json:"app_state,omitempty"`   // focused or backgrounded from a recent heartbeat
+	QueueDepth   int          `json:"queue_depth,omitempty"` // reported with app_state
+	Capabilities Capabilities `json:"capabilities"`
+	Excluded     []string     `json:"excluded,omitempty"`
+	Subprotocol  string       `json:"subprotocol,omitempty"`
+	Instance     string       `json:"instance,omitempty"` // server instance holding the connection, set by the control plane
+	Bot          bool         `json:"bot,omitempty"`      // connected with a service account token
+	Scope        string       `json:"scope,omitempty"`    // the service account's scope, bots only
+	ConnectionMetadata
+	ConnectionTiming
+}
+
+// Touch records activity from the client at now.
+func (c *Client) Touch(now time.Time) {
+	c.mu.Lock()
+	c.LastActive = now
+	c.mu.Unlock()
+}
+
+// RTT returns the last measured application level round trip time.
+func (c *Client) RTT() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rtt
+}
+
+// recordRTT stores a measured RTT and returns the number of consecutive measurements above
+// thresholdMs. A zero threshold disables the check.
+func (c *Client) recordRTT(rtt time.Duration, thresholdMs int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rtt = rtt
+	if thresholdMs > 0 && rtt > time.Duration(thresholdMs)*time.Millisecond {
+		c.latencyStrikes++
+	} else {
+		c.latencyStrikes = 0
+	}
+	return c.latencyStrikes
+}
+
+// Info returns a snapshot of the client's state.
+func (c *Client) Info(now time.Time) ClientInfo {
+	c.mu.RLock()
+	info := ClientInfo{
+		Username:     c.username,
+		SessionID:    c.SessionID,
+		Guest:        c.guest,
+		ConnectedAt:  c.ConnectedAt,
+		LastActive:   c.LastActive,
+		RTTMillis:    float64(c.rtt) / float64(time.Millisecond),
+		Capabilities: c.capabilities,
+		Subprotocol:  c.Subprotocol,
+
+		ConnectionMetadata: c.Metadata,
+		ConnectionTiming:   c.timing,
+	}
+	c.mu.RUnlock()
+	if c.account != nil {
+		info.Bot = true
+		info.Scope = c.account.Scope
+	}
+	if c.ping != nil {
+		rtt, interval, quality := c.ping.state()
+		info.PingRTTMs = float64(rtt) / float64(time.Millisecond)
+		info.PingInterval = interval.Seconds()
+		info.Quality = quality
+	}
+	info.Excluded = c.Excluded()
+	info.AppState, info.QueueDepth = c.AppState(now)
+	return info
 }
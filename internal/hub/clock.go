@@ -0,0 +1,82 @@
+// internal/hub/clock.go
+// Seams for time and randomness so round timing and winner selection can be driven
+// deterministically, e.g. by a fake clock that fast-forwards rounds. Everything the game
+// and its clients see goes through them; network deadlines, pings and latencies measured
+// for metrics use the time package, since they time real connections.
+package hub
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the time functions used for round timing.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker is the part of *time.Ticker the hub uses.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is the part of *time.Timer the hub uses.
+type Timer interface {
+	Stop() bool
+}
+
+// realClock implements Clock with the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// lockedSource makes a rand.Source safe for the concurrent winner selections
+// of overlapping rounds.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// SetClock replaces the clock used for round timing. It must be called before Run.
+func (h *Hub) SetClock(clock Clock) {
+	h.clock = clock
+	h.StartTime = clock.Now()
+}
+
+// SetRandSource replaces the source used for winner selection, guest names and chaos
+// drills, so a fixed seed yields a reproducible sequence of winners. It must be called
+// before Run.
+func (h *Hub) SetRandSource(src rand.Source) {
+	h.rng = rand.New(&lockedSource{src: src})
+}
@@ -0,0 +1,194 @@
+package hub
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+)
+
+// fakeClock is a Clock that only moves when the test advances it. Timers, tickers and
+// sleepers whose time comes fire during Advance, in order.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending timer, ticker or Sleep call of a fakeClock.
+type fakeWaiter struct {
+	clock   *fakeClock
+	at      time.Time
+	period  time.Duration  // tickers only
+	c       chan time.Time // tickers and sleepers
+	f       func()         // timers only
+	stopped bool
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock was advanced by d.
+func (c *fakeClock) Sleep(d time.Duration) {
+	w := c.wait(d, 0, make(chan time.Time, 1), nil)
+	<-w.c
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	return fakeTicker{c.wait(d, d, make(chan time.Time, 1), nil)}
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.wait(d, 0, nil, f)
+}
+
+func (c *fakeClock) wait(d, period time.Duration, ch chan time.Time, f func()) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), period: period, c: ch, f: f}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// awaitWaiters blocks until n timers, tickers and sleepers are pending, so a test knows
+// a goroutine reached its Sleep before advancing the clock.
+func (c *fakeClock) awaitWaiters(n int) {
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Advance moves the clock forward by d. Like a time.Ticker, a ticker whose last tick was
+// not received yet drops the next ones.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+		if len(c.waiters) == 0 || c.waiters[0].at.After(end) {
+			break
+		}
+		w := c.waiters[0]
+		c.now = w.at
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+		if w.f != nil {
+			c.mu.Unlock()
+			w.f()
+			c.mu.Lock()
+			continue
+		}
+		select {
+		case w.c <- c.now:
+		default:
+		}
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func (w *fakeWaiter) Stop() bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTicker is a ticker of a fakeClock.
+type fakeTicker struct{ *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.c }
+func (t fakeTicker) Stop()               { t.fakeWaiter.Stop() }
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	fired := 0
+	timer := clock.AfterFunc(2*time.Second, func() { fired++ })
+	ticker := clock.NewTicker(time.Second)
+	slept := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Second)
+		close(slept)
+	}()
+	clock.awaitWaiters(3)
+
+	clock.Advance(1500 * time.Millisecond)
+	if got := <-ticker.C(); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("tick at %s, want one second after the start", got)
+	}
+	if fired != 0 {
+		t.Error("timer fired early")
+	}
+	clock.Advance(time.Second)
+	<-slept
+	if fired != 1 || timer.Stop() {
+		t.Errorf("timer fired %d times and is still pending: %v, want it fired once", fired, timer.Stop())
+	}
+	if !clock.Now().Equal(start.Add(2500 * time.Millisecond)) {
+		t.Errorf("now = %s, want 2.5s after the start", clock.Now())
+	}
+}
+
+func TestHeartbeatGoesStale(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	h := newHub(config.DefaultConfig(), nil, nil, nil, logger.NewLogger("test"))
+	h.SetClock(clock)
+	client := &Client{username: "ada", Send: make(chan []byte, 4)}
+	h.clients.add(client)
+
+	h.HandleClientMessage(context.Background(), client, map[string]interface{}{
+		"version": "1.0",
+		"type":    "heartbeat",
+		"data":    map[string]interface{}{"state": message.AppStateBackgrounded, "queue_depth": 2.0},
+	})
+	if stats := h.Engagement(); stats.Backgrounded != 1 {
+		t.Fatalf("engagement = %+v, want the client backgrounded", stats)
+	}
+	clock.Advance(heartbeatStaleAfter + time.Second)
+	if stats := h.Engagement(); stats.Reporting != 0 || stats.Backgrounded != 0 {
+		t.Errorf("engagement after the heartbeat went stale = %+v, want nobody reporting", stats)
+	}
+}
+
+func TestGuestNamesReproducible(t *testing.T) {
+	names := func() []string {
+		h := newHub(config.DefaultConfig(), nil, nil, nil, logger.NewLogger("test"))
+		h.SetRandSource(rand.NewSource(7))
+		return []string{h.newGuestName("a"), h.newGuestName("b"), h.newGuestName("c")}
+	}
+	first, second := names(), names()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("guest names %v and %v from the same seed differ", first, second)
+		}
+	}
+}
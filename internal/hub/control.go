@@ -0,0 +1,286 @@
+// internal/hub/control.go
+// Control plane that carries admin commands to every server instance over NATS request-reply.
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/erilali/internal/message"
+	"github.com/nats-io/nats.go"
+)
+
+// Admin commands understood by the control plane.
+const (
+	ControlKick     = "kick"
+	ControlBan      = "ban"
+	ControlUnban    = "unban"
+	ControlEndRound = "end_round"
+	ControlClients  = "clients"
+	ControlAnnounce = "announce"
+	ControlMute     = "mute"
+	ControlUnmute   = "unmute"
+
+	ControlInvalidateRounds = "invalidate_rounds"
+	ControlEraseUser        = "erase_user"
+)
+
+const (
+	controlSubject        = "control.admin"
+	defaultControlTimeout = 500 * time.Millisecond
+)
+
+// ControlCommand is an admin command sent to every instance.
+type ControlCommand struct {
+	Action   string `json:"action"`
+	Username string `json:"username,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Actor    string `json:"actor"`
+	Origin   string `json:"origin"` // instance that fanned the command out, which runs it itself
+
+	Announcement *message.Announcement `json:"announcement,omitempty"` // announce: the announcement to broadcast
+	Mute         *Mute                 `json:"mute,omitempty"`         // mute: the mute to apply
+	RoundIDs     []int64               `json:"round_ids,omitempty"`    // invalidate_rounds: rounds whose history changed
+}
+
+// ControlReply is the outcome of a command on one instance.
+type ControlReply struct {
+	Instance string       `json:"instance"`
+	Kicked   int          `json:"kicked,omitempty"`   // kick, ban: connections closed
+	Found    bool         `json:"found,omitempty"`    // unban, unmute: the user was banned or muted there
+	Erased   int          `json:"erased,omitempty"`   // erase_user: submissions dropped from memory
+	RoundID  int64        `json:"round_id,omitempty"` // end_round: the round that was ended
+	Clients  []ClientInfo `json:"clients,omitempty"`  // clients: the instance's connections
+	Error    string       `json:"error,omitempty"`
+}
+
+// ControlResult collects the replies of every instance that answered in time,
+// this instance first.
+type ControlResult struct {
+	Replies []ControlReply `json:"instances"`
+}
+
+// Kicked returns the connections closed across all instances.
+func (r ControlResult) Kicked() int {
+	kicked := 0
+	for _, reply := range r.Replies {
+		kicked += reply.Kicked
+	}
+	return kicked
+}
+
+// Found reports whether any instance found what the command referred to.
+func (r ControlResult) Found() bool {
+	for _, reply := range r.Replies {
+		if reply.Found {
+			return true
+		}
+	}
+	return false
+}
+
+// Clients merges the connections of all instances.
+func (r ControlResult) Clients() []ClientInfo {
+	clients := []ClientInfo{}
+	for _, reply := range r.Replies {
+		clients = append(clients, reply.Clients...)
+	}
+	return clients
+}
+
+// InstanceID identifies this server instance in control plane replies.
+func (h *Hub) InstanceID() string {
+	return h.instanceID
+}
+
+// Control runs an admin command on this instance and, when connected to NATS, on every
+// other instance, and gathers their replies. Peers that do not answer within
+// control_timeout_ms are left out of the result.
+func (h *Hub) Control(cmd ControlCommand) ControlResult {
+	cmd.Origin = h.instanceID
+	result := ControlResult{Replies: []ControlReply{h.executeControl(cmd)}}
+	result.Replies = append(result.Replies, h.requestPeers(cmd)...)
+	return result
+}
+
+// executeControl runs an admin command against this instance's clients and rounds.
+func (h *Hub) executeControl(cmd ControlCommand) ControlReply {
+	reply := ControlReply{Instance: h.instanceID}
+	switch cmd.Action {
+	case ControlKick:
+		reply.Kicked = h.KickClient(cmd.Username, cmd.Actor)
+	case ControlBan:
+		reply.Kicked = h.countConnections(cmd.Username)
+		h.BanUser(cmd.Username, cmd.Reason, cmd.Actor)
+	case ControlUnban:
+		reply.Found = h.UnbanUser(cmd.Username, cmd.Actor)
+	case ControlEndRound:
+		roundID, err := h.ForceEndRound(cmd.Actor)
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		reply.RoundID = roundID
+	case ControlClients:
+		reply.Clients = h.ClientInfos()
+		for i := range reply.Clients {
+			reply.Clients[i].Instance = h.instanceID
+		}
+	case ControlAnnounce:
+		if cmd.Announcement == nil {
+			reply.Error = "announce without an announcement"
+			break
+		}
+		if cmd.Origin != h.instanceID {
+			h.deliverAnnouncement(*cmd.Announcement) // the origin records it in the audit stream
+			break
+		}
+		h.Announce(*cmd.Announcement, cmd.Actor)
+	case ControlMute:
+		if cmd.Mute == nil {
+			reply.Error = "mute without a mute"
+			break
+		}
+		if cmd.Origin != h.instanceID {
+			h.holdMute(*cmd.Mute) // the origin persists and records it
+			break
+		}
+		h.ApplyMute(*cmd.Mute)
+	case ControlUnmute:
+		reply.Found = h.UnmuteUser(cmd.Username, cmd.Actor)
+	case ControlEraseUser:
+		reply.Erased = h.eraseUserFromMemory(cmd.Username)
+	case ControlInvalidateRounds:
+		h.notifyRoundsChanged(cmd.RoundIDs)
+	default:
+		reply.Error = "unknown control action " + cmd.Action
+	}
+	return reply
+}
+
+// OnRoundsChanged registers fn to be called with the IDs of finished rounds whose stored
+// history was rewritten on this or any other instance, so caches of it can drop them.
+func (h *Hub) OnRoundsChanged(fn func(roundIDs []int64)) {
+	h.roundsChangedMu.Lock()
+	defer h.roundsChangedMu.Unlock()
+	h.roundsChanged = append(h.roundsChanged, fn)
+}
+
+// invalidateRounds tells every instance that the stored history of roundIDs changed.
+func (h *Hub) invalidateRounds(roundIDs []int64, actor string) {
+	if len(roundIDs) == 0 {
+		return
+	}
+	h.Control(ControlCommand{Action: ControlInvalidateRounds, RoundIDs: roundIDs, Actor: actor})
+}
+
+func (h *Hub) notifyRoundsChanged(roundIDs []int64) {
+	h.roundsChangedMu.Lock()
+	listeners := slices.Clone(h.roundsChanged)
+	h.roundsChangedMu.Unlock()
+	for _, fn := range listeners {
+		fn(roundIDs)
+	}
+}
+
+// countConnections returns how many connections username has on this instance, matching
+// usernames the way KickClient and BanUser do.
+func (h *Hub) countConnections(username string) int {
+	count := 0
+	for _, client := range h.clients.snapshot() {
+		if h.sameUsername(client.Username(), username) {
+			count++
+		}
+	}
+	return count
+}
+
+// controlTimeout returns how long Control waits for peer replies.
+func (h *Hub) controlTimeout() time.Duration {
+	ms := h.settings().ControlTimeoutMs
+	if ms <= 0 {
+		return defaultControlTimeout
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// requestPeers fans a command out to the other instances and collects their replies
+// until the control timeout. The number of instances is not known, so the full timeout
+// is always waited out.
+func (h *Hub) requestPeers(cmd ControlCommand) []ControlReply {
+	if h.NatsConn == nil {
+		return nil
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		h.Logger.Errorf("Failed to marshal control command: %v", err)
+		return nil
+	}
+	inbox := h.NatsConn.NewRespInbox()
+	sub, err := h.NatsConn.SubscribeSync(inbox)
+	if err != nil {
+		h.Logger.Errorf("Failed to subscribe to control replies: %v", err)
+		return nil
+	}
+	defer sub.Unsubscribe()
+	if err := h.NatsConn.PublishRequest(h.settings().Subject(controlSubject), inbox, data); err != nil {
+		h.Logger.Errorf("Failed to publish control command: %v", err)
+		return nil
+	}
+
+	var replies []ControlReply
+	deadline := time.Now().Add(h.controlTimeout())
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return replies
+		}
+		msg, err := sub.NextMsg(remaining)
+		if err != nil {
+			if !errors.Is(err, nats.ErrTimeout) {
+				h.Logger.Errorf("Error reading control replies: %v", err)
+			}
+			return replies
+		}
+		var reply ControlReply
+		if err := json.Unmarshal(msg.Data, &reply); err != nil {
+			h.Logger.Errorf("Invalid control reply: %v", err)
+			continue
+		}
+		replies = append(replies, reply)
+	}
+}
+
+// serveControl answers admin commands fanned out by other instances until ctx is canceled.
+func (h *Hub) serveControl(ctx context.Context) {
+	if h.NatsConn == nil {
+		return
+	}
+	sub, err := h.NatsConn.Subscribe(h.settings().Subject(controlSubject), func(msg *nats.Msg) {
+		var cmd ControlCommand
+		if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+			h.Logger.Errorf("Invalid control command: %v", err)
+			return
+		}
+		if cmd.Origin == h.instanceID {
+			return // ran locally by Control
+		}
+		h.Logger.Infof("Control command %s from instance %s", cmd.Action, cmd.Origin)
+		data, err := json.Marshal(h.executeControl(cmd))
+		if err != nil {
+			h.Logger.Errorf("Failed to marshal control reply: %v", err)
+			return
+		}
+		if err := msg.Respond(data); err != nil {
+			h.Logger.Errorf("Failed to answer control command: %v", err)
+		}
+	})
+	if err != nil {
+		h.Logger.Errorf("Failed to subscribe to control commands, admin commands from other instances will not reach this one: %v", err)
+		return
+	}
+	<-ctx.Done()
+	sub.Unsubscribe()
+}
@@ -0,0 +1,243 @@
+// internal/hub/cooldown.go
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	cooldownBucket  = "WINNER_COOLDOWN"
+	cooldownKey     = "recent"
+	cooldownRetries = 5 // attempts to store the recent rounds while other instances write them
+)
+
+// cooldownRound is the outcome of one of the latest rounds, Winner empty when the round
+// had none.
+type cooldownRound struct {
+	RoundID int64  `json:"round_id"`
+	Winner  string `json:"winner,omitempty"`
+}
+
+// winnerCooldown remembers the winners of the last rounds so they sit out the following
+// draws. The main hub keeps them in a JetStream key-value bucket when available, shared
+// by every instance, so neither a restart nor another instance playing the next round
+// hands out back-to-back wins. Writes are compare-and-set on the bucket's revision.
+type winnerCooldown struct {
+	mu     sync.Mutex
+	length int             // rounds a winner sits out
+	kv     nats.KeyValue   // nil for room hubs and without JetStream
+	rounds []cooldownRound // oldest first, at most length; the last state read or written
+}
+
+// newMemoryWinnerCooldown returns nil when the cooldown is disabled.
+func newMemoryWinnerCooldown(length int) *winnerCooldown {
+	if length <= 0 {
+		return nil
+	}
+	return &winnerCooldown{length: length}
+}
+
+// newWinnerCooldown opens the bucket, creating it if needed, and loads the recent
+// winners. It returns nil when the cooldown is disabled.
+func newWinnerCooldown(js nats.JetStreamContext, bucket string, length int, logger *logger.Logger) *winnerCooldown {
+	c := newMemoryWinnerCooldown(length)
+	if c == nil || js == nil {
+		return c
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Winners of the latest rounds, excluded from the next draws",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening winner cooldown bucket, recent winners are kept in memory only: %v", err)
+		return c
+	}
+	c.kv = kv
+	if rounds, _, err := c.load(); err != nil {
+		logger.Errorf("Error loading recent winners: %v", err)
+	} else {
+		c.rounds = rounds
+	}
+	return c
+}
+
+// trim drops rounds beyond the cooldown length.
+func (c *winnerCooldown) trim(rounds []cooldownRound) []cooldownRound {
+	if over := len(rounds) - c.length; over > 0 {
+		return append([]cooldownRound(nil), rounds[over:]...)
+	}
+	return rounds
+}
+
+// load reads the recent rounds from the bucket with their revision, 0 when none are
+// stored yet.
+func (c *winnerCooldown) load() ([]cooldownRound, uint64, error) {
+	entry, err := c.kv.Get(cooldownKey)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var rounds []cooldownRound
+	if err := json.Unmarshal(entry.Value(), &rounds); err != nil {
+		return nil, 0, fmt.Errorf("decoding recent winners: %w", err)
+	}
+	return c.trim(rounds), entry.Revision(), nil
+}
+
+// update applies change to the recent rounds, which reports whether it changed them, and
+// stores the result. With the bucket the rounds another instance stored are read first
+// and written back only if nobody wrote them since, retrying otherwise. Callers must hold
+// mu.
+func (c *winnerCooldown) update(change func([]cooldownRound) ([]cooldownRound, bool)) error {
+	if c.kv == nil {
+		if rounds, changed := change(c.rounds); changed {
+			c.rounds = c.trim(rounds)
+		}
+		return nil
+	}
+	for attempt := 0; attempt < cooldownRetries; attempt++ {
+		stored, revision, err := c.load()
+		if err != nil {
+			return err
+		}
+		rounds, changed := change(stored)
+		if !changed {
+			c.rounds = stored
+			return nil
+		}
+		rounds = c.trim(rounds)
+		data, err := json.Marshal(rounds)
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			_, err = c.kv.Create(cooldownKey, data)
+		} else {
+			_, err = c.kv.Update(cooldownKey, data, revision)
+		}
+		if err == nil {
+			c.rounds = rounds
+			return nil
+		}
+		if !kvConflict(err) {
+			return err
+		}
+	}
+	return errors.New("too many concurrent updates")
+}
+
+// winners returns the usernames that won one of the last rounds, as stored in the
+// bucket when it can be read.
+func (c *winnerCooldown) winners() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kv != nil {
+		if rounds, _, err := c.load(); err == nil {
+			c.rounds = rounds
+		}
+	}
+	var winners []string
+	for _, round := range c.rounds {
+		if round.Winner != "" {
+			winners = append(winners, round.Winner)
+		}
+	}
+	return winners
+}
+
+// record sets the winner of a round, replacing the one recorded before when an appeal
+// redraws it, and drops the rounds that fell out of the cooldown.
+func (c *winnerCooldown) record(roundID int64, winner string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.update(func(rounds []cooldownRound) ([]cooldownRound, bool) {
+		rounds = slices.Clone(rounds)
+		for i := range rounds {
+			if rounds[i].RoundID == roundID {
+				rounds[i].Winner = winner
+				return rounds, true
+			}
+		}
+		return append(rounds, cooldownRound{RoundID: roundID, Winner: winner}), true
+	})
+}
+
+// eraseUser forgets the wins of the users match accepts.
+func (c *winnerCooldown) eraseUser(match func(username string) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.update(func(rounds []cooldownRound) ([]cooldownRound, bool) {
+		rounds = slices.Clone(rounds)
+		changed := false
+		for i := range rounds {
+			if rounds[i].Winner != "" && match(rounds[i].Winner) {
+				rounds[i].Winner = ""
+				changed = true
+			}
+		}
+		return rounds, changed
+	})
+}
+
+// applyWinnerCooldown leaves the winners of the last winner_cooldown_rounds rounds out
+// of the candidates and returns the usernames it excluded. When every candidate won
+// recently nobody is excluded, so a round is never left without a winner by the cooldown.
+func (h *Hub) applyWinnerCooldown(candidates []RoundMessage) ([]RoundMessage, []string) {
+	if h.cooldown == nil {
+		return candidates, nil
+	}
+	recent := h.cooldown.winners()
+	if len(recent) == 0 {
+		return candidates, nil
+	}
+	cooling := func(username string) bool {
+		for _, winner := range recent {
+			if h.sameUsername(winner, username) {
+				return true
+			}
+		}
+		return false
+	}
+	kept := make([]RoundMessage, 0, len(candidates))
+	var excluded []string
+	for _, msg := range candidates {
+		if !cooling(msg.Username) {
+			kept = append(kept, msg)
+			continue
+		}
+		if !slices.Contains(excluded, msg.Username) {
+			excluded = append(excluded, msg.Username)
+		}
+	}
+	if len(kept) == 0 {
+		return candidates, nil
+	}
+	if len(excluded) > 0 {
+		h.Logger.Debugf("Winner cooldown excluded %d recent winners from the draw", len(excluded))
+	}
+	return kept, excluded
+}
+
+// recordCooldown remembers the winner of a round, empty for rounds without one, for the
+// winner cooldown.
+func (h *Hub) recordCooldown(roundID int64, winner string) {
+	if h.cooldown == nil {
+		return
+	}
+	if err := h.cooldown.record(roundID, winner); err != nil {
+		h.Logger.Errorf("Error storing the winner of round %d for the cooldown: %v", roundID, err)
+	}
+}
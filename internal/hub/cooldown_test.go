@@ -0,0 +1,55 @@
+package hub
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestWinnerCooldownSharedBucket(t *testing.T) {
+	kv := newMemoryKV()
+	first := &winnerCooldown{length: 3, kv: kv}
+	second := &winnerCooldown{length: 3, kv: kv}
+
+	if err := first.record(1, "ada"); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	// The second instance ends round 2 while the first ends round 3: the first instance's
+	// write lands on a stale revision and is retried on top of round 2.
+	kv.beforeWrite = func(string) error {
+		kv.beforeWrite = nil
+		return second.record(2, "grace")
+	}
+	if err := first.record(3, "linus"); err != nil {
+		t.Fatalf("record after a concurrent write: %v", err)
+	}
+	for _, c := range []*winnerCooldown{first, second} {
+		if got, want := c.winners(), []string{"ada", "grace", "linus"}; !slices.Equal(got, want) {
+			t.Errorf("winners = %v, want %v", got, want)
+		}
+	}
+
+	if err := second.record(4, ""); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if got, want := first.winners(), []string{"grace", "linus"}; !slices.Equal(got, want) {
+		t.Errorf("winners after round 1 fell out = %v, want %v", got, want)
+	}
+}
+
+func TestWinnerCooldownWriteError(t *testing.T) {
+	kv := newMemoryKV()
+	c := &winnerCooldown{length: 3, kv: kv}
+	unavailable := errors.New("jetstream unavailable")
+	attempts := 0
+	kv.beforeWrite = func(string) error {
+		attempts++
+		return unavailable
+	}
+	if err := c.record(1, "ada"); !errors.Is(err, unavailable) {
+		t.Errorf("record error = %v, want the write error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("%d write attempts, want no retry after an error other than a conflict", attempts)
+	}
+}
@@ -0,0 +1,69 @@
+// internal/hub/deadline.go
+package hub
+
+import (
+	"context"
+	"time"
+)
+
+// ProcessingTimeoutCode is the error code for frames the hub could not handle within
+// message_deadline_ms, typically because JetStream is not answering. The frame was not
+// applied, so the client may send it again.
+const ProcessingTimeoutCode = "PROCESSING_TIMEOUT"
+
+// messageContext returns the context a client frame is handled under, ending after
+// message_deadline_ms.
+func (h *Hub) messageContext() (context.Context, context.CancelFunc) {
+	deadline := time.Duration(h.settings().MessageDeadlineMs) * time.Millisecond
+	if deadline <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), deadline)
+}
+
+// runWithContext runs fn, which cannot be interrupted, and waits until it returns or ctx
+// ends. fn always runs, even under an expired ctx. When ctx ends first it returns
+// ctx.Err() at once and leaves fn running; undo, if not nil, is called once fn returns
+// to reverse what it did.
+func runWithContext(ctx context.Context, fn func() error, undo func()) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if undo != nil {
+			go func() {
+				<-done
+				undo()
+			}()
+		}
+		return ctx.Err()
+	}
+}
+
+// within runs bookkeeping that follows the answer to a frame, such as statistics and
+// audit records, without holding up the client's next frame past ctx.
+func (h *Hub) within(ctx context.Context, what string, fn func()) {
+	err := runWithContext(ctx, func() error {
+		fn()
+		return nil
+	}, nil)
+	if err != nil {
+		h.Logger.Warnf("Still %s after the message deadline, continuing in the background", what)
+	}
+}
+
+// sendProcessingTimeout tells a client its frame was dropped after the deadline.
+func (h *Hub) sendProcessingTimeout(client *Client, messageType string) {
+	h.Logger.Warnf("Handling %s from %s exceeded the %d ms deadline", messageType, client.Username(), h.settings().MessageDeadlineMs)
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":    "1.0",
+		"type":       "error",
+		"data":       "The server is busy, please send your message again",
+		"error_code": ProcessingTimeoutCode,
+		"retriable":  true,
+	})
+}
@@ -0,0 +1,190 @@
+// internal/hub/delivery.go
+package hub
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDeliveryAckTimeout = 5 * time.Second
+	deliverySweepInterval     = 1 * time.Second
+)
+
+// ackedMessageTypes are broadcasts that clients which declared delivery_acks confirm
+// with a "delivery_ack" message. Unconfirmed ones are sent once more.
+var ackedMessageTypes = map[string]bool{
+	"round_start":         true,
+	"winner_announcement": true,
+}
+
+// DeliveryStats reports how reliably acknowledged broadcasts reached their clients.
+type DeliveryStats struct {
+	Tracked       uint64  `json:"tracked"`        // deliveries sent to clients that acknowledge
+	Acked         uint64  `json:"acked"`          // acknowledged, with or without a retransmit
+	Retransmitted uint64  `json:"retransmitted"`  // sent a second time after the ack window
+	Failed        uint64  `json:"failed"`         // never acknowledged, or the client left first
+	Pending       int     `json:"pending"`        // awaiting an ack
+	SuccessRate   float64 `json:"success_rate"`   // acked / (acked + failed), 1 before any outcome
+	RetransmitHit float64 `json:"retransmit_hit"` // share of retransmits that were then acked
+}
+
+// pendingDelivery is a broadcast sent to a client that has not acknowledged it yet.
+type pendingDelivery struct {
+	data          []byte
+	sentAt        time.Time
+	retransmitted bool
+}
+
+// deliveryTracker keeps the unacknowledged deliveries of every client.
+// Sends to a client's channel happen under mu, and forget must be called under mu
+// before the channel is closed, so a retransmit never hits a closed channel.
+type deliveryTracker struct {
+	mu      sync.Mutex
+	pending map[*Client]map[string]*pendingDelivery
+
+	tracked, acked, retransmitted, ackedAfterRetransmit, failed uint64
+}
+
+func newDeliveryTracker() *deliveryTracker {
+	return &deliveryTracker{pending: make(map[*Client]map[string]*pendingDelivery)}
+}
+
+// track records a delivery that was just sent to the client.
+func (t *deliveryTracker) track(client *Client, deliveryID string, data []byte, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	deliveries := t.pending[client]
+	if deliveries == nil {
+		deliveries = make(map[string]*pendingDelivery)
+		t.pending[client] = deliveries
+	}
+	deliveries[deliveryID] = &pendingDelivery{data: data, sentAt: now}
+	t.tracked++
+}
+
+// ack confirms a delivery. Unknown or repeated IDs are ignored.
+func (t *deliveryTracker) ack(client *Client, deliveryID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delivery, ok := t.pending[client][deliveryID]
+	if !ok {
+		return
+	}
+	delete(t.pending[client], deliveryID)
+	t.acked++
+	if delivery.retransmitted {
+		t.ackedAfterRetransmit++
+	}
+}
+
+// forget drops a departing client's deliveries, counting them as failed.
+func (t *deliveryTracker) forget(client *Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failed += uint64(len(t.pending[client]))
+	delete(t.pending, client)
+}
+
+// sweep retransmits deliveries unacknowledged for longer than timeout once, and gives
+// up on those still unacknowledged a further timeout after their retransmit.
+func (t *deliveryTracker) sweep(now time.Time, timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for client, deliveries := range t.pending {
+		for id, delivery := range deliveries {
+			if now.Sub(delivery.sentAt) < timeout {
+				continue
+			}
+			if delivery.retransmitted {
+				delete(deliveries, id)
+				t.failed++
+				continue
+			}
+			select {
+			case client.Send <- delivery.data:
+				delivery.retransmitted = true
+				delivery.sentAt = now
+				t.retransmitted++
+			default:
+				// The client is not keeping up; a retransmit would not help.
+				delete(deliveries, id)
+				t.failed++
+			}
+		}
+		if len(deliveries) == 0 {
+			delete(t.pending, client)
+		}
+	}
+}
+
+func (t *deliveryTracker) stats() DeliveryStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := DeliveryStats{
+		Tracked:       t.tracked,
+		Acked:         t.acked,
+		Retransmitted: t.retransmitted,
+		Failed:        t.failed,
+		SuccessRate:   1,
+	}
+	for _, deliveries := range t.pending {
+		stats.Pending += len(deliveries)
+	}
+	if outcomes := t.acked + t.failed; outcomes > 0 {
+		stats.SuccessRate = float64(t.acked) / float64(outcomes)
+	}
+	if t.retransmitted > 0 {
+		stats.RetransmitHit = float64(t.ackedAfterRetransmit) / float64(t.retransmitted)
+	}
+	return stats
+}
+
+// DeliveryStats returns the delivery metrics of acknowledged broadcasts.
+func (h *Hub) DeliveryStats() DeliveryStats {
+	return h.deliveries.stats()
+}
+
+// deliveryAckTimeout returns how long clients have to acknowledge a broadcast.
+func (h *Hub) deliveryAckTimeout() time.Duration {
+	seconds := h.settings().DeliveryAckTimeoutSeconds
+	if seconds <= 0 {
+		return defaultDeliveryAckTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// trackDelivery starts waiting for the client's ack of a broadcast it was just sent,
+// if the client declared delivery_acks.
+func (h *Hub) trackDelivery(client *Client, message OutboundMessage) {
+	if message.DeliveryID == "" || !client.Capabilities().DeliveryAcks {
+		return
+	}
+	h.deliveries.track(client, message.DeliveryID, message.Data, h.clock.Now())
+}
+
+// handleDeliveryAck confirms a broadcast; data is its delivery_id.
+func (h *Hub) handleDeliveryAck(client *Client, message map[string]interface{}) {
+	deliveryID, ok := message["data"].(string)
+	if !ok || deliveryID == "" {
+		h.SendErrorMessage(client, "Invalid delivery_ack: data must be a delivery_id")
+		return
+	}
+	h.deliveries.ack(client, deliveryID)
+}
+
+// runDeliverySweeper retransmits unacknowledged broadcasts until ctx is canceled.
+func (h *Hub) runDeliverySweeper(ctx context.Context) {
+	ticker := h.clock.NewTicker(deliverySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			h.deliveries.sweep(h.clock.Now(), h.deliveryAckTimeout())
+		}
+	}
+}
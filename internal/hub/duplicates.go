@@ -0,0 +1,156 @@
+// internal/hub/duplicates.go
+package hub
+
+import (
+	"slices"
+	"unicode"
+
+	"github.com/erilali/internal/config"
+)
+
+// DuplicateContentCode is the error code for submissions that repeat an earlier one of
+// the round when duplicate_content is reject.
+const DuplicateContentCode = "DUPLICATE_CONTENT"
+
+// duplicateMode returns how submissions repeating an earlier one are handled. Detection
+// only applies to free text: options in choices mode are meant to be shared, and the
+// server cannot compare the ciphertext of encrypted rooms.
+func (h *Hub) duplicateMode() string {
+	cfg := h.settings()
+	switch {
+	case h.encrypted || cfg.RoundMode == config.RoundModeChoices:
+		return config.DuplicatesOff
+	case cfg.DuplicateContent == config.DuplicatesReject || cfg.DuplicateContent == config.DuplicatesGroup:
+		return cfg.DuplicateContent
+	default:
+		return config.DuplicatesOff
+	}
+}
+
+// contentKey normalizes a text for comparison: lowercase letters and digits, with every
+// run of anything else, such as spaces and punctuation, reduced to a single space.
+func contentKey(text string) []rune {
+	key := make([]rune, 0, len(text))
+	space := false
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && len(key) > 0 {
+				key = append(key, ' ')
+			}
+			key = append(key, unicode.ToLower(r))
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return key
+}
+
+// contentMatcher compares normalized texts, allowing up to distance character edits.
+type contentMatcher struct {
+	distance int
+}
+
+func (h *Hub) contentMatcher() contentMatcher {
+	return contentMatcher{distance: max(h.settings().DuplicateContentDistance, 0)}
+}
+
+// same reports whether two normalized texts count as the same content. Texts with no
+// letters or digits never match.
+func (m contentMatcher) same(a, b []rune) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if m.distance == 0 {
+		return slices.Equal(a, b)
+	}
+	return withinDistance(a, b, m.distance)
+}
+
+// duplicateIn reports whether messages hold one other than except whose content
+// matches text.
+func (m contentMatcher) duplicateIn(messages []RoundMessage, text, except string) bool {
+	key := contentKey(text)
+	for _, msg := range messages {
+		if msg.ID != except && m.same(key, contentKey(msg.Message)) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b is at most k.
+// Only the band of cells within k of the diagonal is computed.
+func withinDistance(a, b []rune, k int) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > k {
+		return false
+	}
+	inf := k + 1
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = min(j, inf)
+	}
+	for i := 1; i <= len(a); i++ {
+		lo, hi := max(1, i-k), min(len(b), i+k)
+		if lo == 1 {
+			cur[0] = min(i, inf)
+		} else {
+			cur[lo-1] = inf
+		}
+		best := cur[lo-1]
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j-1]+cost, cur[j-1]+1, prev[j]+1, inf)
+			best = min(best, cur[j])
+		}
+		if hi < len(b) {
+			cur[hi+1] = inf
+		}
+		if best >= inf {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)] <= k
+}
+
+// uniqueEntries reduces candidates to one entry per content for the winner draw when
+// duplicate_content is group, so a text submitted many times is no likelier to win than
+// one submitted once. Each group is represented by its earliest submission, whose
+// author came up with it. It returns the candidates unchanged in other modes.
+func (h *Hub) uniqueEntries(candidates []RoundMessage) []RoundMessage {
+	if h.duplicateMode() != config.DuplicatesGroup {
+		return candidates
+	}
+	matcher := h.contentMatcher()
+	var unique []RoundMessage
+	var keys [][]rune
+	for _, msg := range candidates {
+		key := contentKey(msg.Message)
+		if !slices.ContainsFunc(keys, func(k []rune) bool { return matcher.same(key, k) }) {
+			unique = append(unique, msg)
+			keys = append(keys, key)
+		}
+	}
+	if len(unique) < len(candidates) {
+		h.Logger.Debugf("Grouped %d submissions into %d unique entries", len(candidates), len(unique))
+	}
+	return unique
+}
+
+// duplicateRejecter returns the matcher that rejects duplicates in a round, or nil
+// unless duplicate_content is reject.
+func (h *Hub) duplicateRejecter() *contentMatcher {
+	if h.duplicateMode() != config.DuplicatesReject {
+		return nil
+	}
+	matcher := h.contentMatcher()
+	return &matcher
+}
@@ -0,0 +1,50 @@
+// internal/hub/encryption.go
+package hub
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/erilali/internal/message"
+)
+
+const defaultEncryptedMaxBytes = 4096
+
+// acceptCiphertext checks the submission of an encrypted room: base64 ciphertext of
+// at most encrypted_max_bytes and an optional lang, nothing the server would have to
+// read. The ciphertext is stored and forwarded as the submission's text.
+func (h *Hub) acceptCiphertext(submission *message.Submission) error {
+	switch {
+	case !h.encrypted:
+		return errors.New("Invalid message data: ciphertext is only accepted in encrypted rooms")
+	case submission.Ciphertext == "":
+		return errors.New("Invalid message data: this room is encrypted, send ciphertext instead of text")
+	case submission.Text != "" || submission.AttachmentID != "" || submission.Choice != nil:
+		return errors.New("Invalid message data: encrypted rooms accept only ciphertext and lang")
+	}
+	maxBytes := h.settings().EncryptedMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultEncryptedMaxBytes
+	}
+	data, err := base64.StdEncoding.DecodeString(submission.Ciphertext)
+	if err != nil || len(data) == 0 {
+		return errors.New("Invalid ciphertext: expected standard base64")
+	}
+	if len(data) > maxBytes {
+		return fmt.Errorf("Invalid ciphertext: larger than %d bytes", maxBytes)
+	}
+	submission.Text, submission.Ciphertext = submission.Ciphertext, ""
+	return nil
+}
+
+// loggable returns submission text for logs and audit records. Encrypted rooms log
+// only the size of the ciphertext.
+func (h *Hub) loggable(text string) string {
+	if !h.encrypted {
+		return text
+	}
+	n := base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(text, "=")))
+	return fmt.Sprintf("[%d bytes of ciphertext]", n)
+}
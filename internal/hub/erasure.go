@@ -0,0 +1,375 @@
+// internal/hub/erasure.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/rewards"
+)
+
+// ErasedUsername replaces the name of an erased user where a record has to keep a
+// username, such as the winner of a round.
+const ErasedUsername = "[deleted]"
+
+const (
+	erasurePageSize     = 10000            // events per history read when looking for a user's records
+	erasureFetchMaxWait = 10 * time.Second // per page
+	erasureReason       = "user data erased"
+	// erasureArchiveTimeout bounds the rewrite of archived rounds, which lists and reads
+	// every object under archive_prefix.
+	erasureArchiveTimeout = 30 * time.Minute
+)
+
+// ErasureReport describes what EraseUserData removed.
+type ErasureReport struct {
+	Username           string    `json:"username"`
+	RedactedMessages   int       `json:"redacted_messages"`  // submissions redacted in the event history
+	RedactedWinners    int       `json:"redacted_winners"`   // wins anonymized in the event history
+	RedactedSummaries  int       `json:"redacted_summaries"` // round summaries republished without the user
+	MemoryMessages     int       `json:"memory_messages"`    // submissions dropped from rounds held in memory, on every instance
+	StatsDeleted       bool      `json:"stats_deleted"`
+	PreferencesDeleted bool      `json:"preferences_deleted"`
+	PointsDeleted      bool      `json:"points_deleted"`
+	ArchivesPending    bool      `json:"archives_pending"` // archived rounds are being rewritten in the background
+	ErasedBy           string    `json:"erased_by"`
+	ErasedAt           time.Time `json:"erased_at"`
+}
+
+// EraseUserData removes a user's data: their submissions are redacted in the event
+// history, their wins are republished under ErasedUsername, the round summaries naming
+// them are republished without them, and their statistics, preferences and points are
+// deleted. Every instance is told over the control plane to drop them from the rounds
+// it holds in memory and from its caches. Archived rounds are rewritten in the
+// background, with an audit record once done. Event history that cannot be read, or
+// only in part, is reported as an error after everything else was erased.
+func (h *Hub) EraseUserData(username, actor string) (ErasureReport, error) {
+	match := func(name string) bool { return h.sameUsername(name, username) }
+	report := ErasureReport{Username: username, ErasedBy: actor, ErasedAt: h.clock.Now()}
+	var errs []error
+
+	result := h.Control(ControlCommand{Action: ControlEraseUser, Username: username, Actor: actor})
+	for _, reply := range result.Replies {
+		report.MemoryMessages += reply.Erased
+	}
+
+	if err := h.userStats.delete(h.usernameKey(username)); err != nil {
+		errs = append(errs, err)
+	} else {
+		report.StatsDeleted = true
+	}
+	if err := h.preferences.delete(username); err != nil {
+		errs = append(errs, err)
+	} else {
+		report.PreferencesDeleted = true
+	}
+	if eraser, ok := h.Rewards.(rewards.PointsEraser); ok {
+		if err := eraser.DeletePoints(username); err != nil {
+			errs = append(errs, fmt.Errorf("deleting points for %s: %w", username, err))
+		} else {
+			report.PointsDeleted = true
+		}
+	}
+
+	if h.Bus != nil {
+		var changed []int64
+		rounds, redacted, err := h.redactUserMessages(match, actor, report.ErasedAt)
+		report.RedactedMessages = redacted
+		changed = append(changed, rounds...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		rounds, err = h.redactUserWins(match, actor, report.ErasedAt)
+		report.RedactedWinners = len(rounds)
+		changed = append(changed, rounds...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		rounds, err = h.redactUserSummaries(match)
+		report.RedactedSummaries = len(rounds)
+		changed = append(changed, rounds...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		slices.Sort(changed)
+		h.invalidateRounds(slices.Compact(changed), actor)
+	}
+
+	if h.archiver != nil {
+		report.ArchivesPending = true
+		h.goTask(func() { h.eraseArchives(username, actor, match) })
+	}
+
+	detail, _ := json.Marshal(report)
+	h.Audit(AuditUserDataErased, username, "User data erased by "+actor, string(detail))
+	h.Logger.Infof("Erased data of %s for %s: %d messages redacted, %d wins anonymized",
+		username, actor, report.RedactedMessages, report.RedactedWinners)
+	if len(errs) > 0 {
+		return report, fmt.Errorf("erasing data of %s: %w", username, errs[0])
+	}
+	return report, nil
+}
+
+// eraseUserFromMemory drops a user from what this instance holds in memory: the rounds of
+// the main hub and every room, and the search index. It returns how many submissions
+// were dropped.
+func (h *Hub) eraseUserFromMemory(username string) int {
+	match := func(name string) bool { return h.sameUsername(name, username) }
+	erased := 0
+	for _, hub := range append([]*Hub{h}, h.roomHubs()...) {
+		erased += hub.eraseFromMemory(match)
+	}
+	h.search.eraseUser(match)
+	return erased
+}
+
+// eraseFromMemory drops the submissions match accepts from the open and retained rounds
+// and the round history, and anonymizes the wins among them. The last winner sent in
+// state_sync is anonymized too, and the events retained for resync are dropped, so
+// clients that fall behind get a fresh state_sync instead. It returns how many
+// submissions were dropped.
+func (h *Hub) eraseFromMemory(match func(username string) bool) int {
+	erased := 0
+	for _, roundID := range h.rounds.roundIDs() {
+		for _, msg := range h.rounds.messages(roundID) {
+			if match(msg.Username) {
+				if _, ok := h.rounds.remove(roundID, msg.ID); ok {
+					erased++
+				}
+			}
+		}
+	}
+	erased += h.recent.eraseUser(match)
+	if result := h.lastResult.Load(); result != nil && result.Winner != nil && match(result.Winner.Username) {
+		h.rememberResult(result.RoundID, erasedWinner(result.Winner))
+	}
+	h.events.clear()
+	if h.cooldown != nil {
+		if err := h.cooldown.eraseUser(match); err != nil {
+			h.Logger.Errorf("Error erasing recent winners: %v", err)
+		}
+	}
+
+	h.Mu.Lock()
+	for i := range h.RoundHistory {
+		h.RoundHistory[i].eraseUser(match)
+	}
+	h.Mu.Unlock()
+	return erased
+}
+
+// redactUserMessages publishes a redaction for every submission of the user still
+// visible in the event history and returns the rounds it changed and how many
+// submissions it redacted.
+func (h *Hub) redactUserMessages(match func(username string) bool, actor string, now time.Time) ([]int64, int, error) {
+	type submission struct {
+		roundID int64
+		visible bool
+	}
+	var order []string
+	submissions := make(map[string]*submission)
+	err := h.eachEvent("messages.*", func(event eventbus.Event) {
+		var record struct {
+			ID       string `json:"id"`
+			Action   string `json:"action"`
+			Username string `json:"username"`
+			RoundID  int64  `json:"round_id"`
+		}
+		if err := json.Unmarshal(event.Data, &record); err != nil || record.ID == "" {
+			return
+		}
+		switch record.Action {
+		case messageActionWithdraw, messageActionRedact:
+			if s, ok := submissions[record.ID]; ok {
+				s.visible = false
+			} else {
+				// A removal read before its submission keeps it from being redacted again.
+				submissions[record.ID] = &submission{}
+			}
+		case messageActionEdit:
+		default:
+			if _, ok := submissions[record.ID]; !ok && match(record.Username) {
+				submissions[record.ID] = &submission{roundID: record.RoundID, visible: true}
+				order = append(order, record.ID)
+			}
+		}
+	})
+
+	// A read that failed part way still redacts what it found.
+	var rounds []int64
+	redacted := 0
+	for _, id := range order {
+		if s := submissions[id]; s.visible {
+			h.publishRedactionToNATS(Redaction{
+				RoundID:   s.roundID,
+				MessageID: id,
+				Reason:    erasureReason,
+				RemovedBy: actor,
+				RemovedAt: now,
+			})
+			rounds = append(rounds, s.roundID)
+			redacted++
+		}
+	}
+	if err != nil {
+		return rounds, redacted, fmt.Errorf("reading message history: %w", err)
+	}
+	return rounds, redacted, nil
+}
+
+// redactUserWins republishes every current win of the user under ErasedUsername,
+// superseding the original record, and returns the rounds it anonymized.
+func (h *Hub) redactUserWins(match func(username string) bool, actor string, now time.Time) ([]int64, error) {
+	var rounds []int64
+	current := make(map[int64]RoundMessage)
+	err := h.eachEvent("winners.*", func(event eventbus.Event) {
+		var record struct {
+			RoundID   int64  `json:"round_id"`
+			MessageID string `json:"message_id"`
+			Username  string `json:"username"`
+		}
+		if err := json.Unmarshal(event.Data, &record); err != nil {
+			return
+		}
+		if _, ok := current[record.RoundID]; !ok {
+			rounds = append(rounds, record.RoundID)
+		}
+		current[record.RoundID] = RoundMessage{ID: record.MessageID, Username: record.Username}
+	})
+
+	var redacted []int64
+	for _, roundID := range rounds {
+		winner := current[roundID]
+		if winner.Username == "" || !match(winner.Username) {
+			continue
+		}
+		h.publishWinnerCorrectionToNATS(WinnerCorrection{
+			RoundID:       roundID,
+			Winner:        erasedWinner(&winner),
+			Supersedes:    winner.ID,
+			Previous:      winner.Username,
+			Reason:        erasureReason,
+			InvalidatedBy: actor,
+			InvalidatedAt: now,
+		})
+		redacted = append(redacted, roundID)
+	}
+	if err != nil {
+		return redacted, fmt.Errorf("reading winner history: %w", err)
+	}
+	return redacted, nil
+}
+
+// redactUserSummaries republishes the latest summary of every round the user took part
+// in or won, without them among the participants and with the winner anonymized, and
+// returns the rounds it republished. Readers of round_summary keep the latest summary
+// of a round.
+func (h *Hub) redactUserSummaries(match func(username string) bool) ([]int64, error) {
+	var rounds []int64
+	latest := make(map[int64]RoundSummary)
+	err := h.eachEvent("round_summary.*", func(event eventbus.Event) {
+		var summary RoundSummary
+		if err := json.Unmarshal(event.Data, &summary); err != nil || summary.RoundID == 0 {
+			return
+		}
+		if _, ok := latest[summary.RoundID]; !ok {
+			rounds = append(rounds, summary.RoundID)
+		}
+		latest[summary.RoundID] = summary
+	})
+
+	var redacted []int64
+	for _, roundID := range rounds {
+		summary := latest[roundID]
+		if !summary.eraseUser(match) {
+			continue
+		}
+		summary.Corrected = true
+		h.publishRoundSummaryToNATS(summary)
+		redacted = append(redacted, roundID)
+	}
+	if err != nil {
+		return redacted, fmt.Errorf("reading round summaries: %w", err)
+	}
+	return redacted, nil
+}
+
+// eachEvent calls fn with every event on subject, oldest first. The history is read in
+// pages of erasurePageSize events, each starting at the timestamp of the last event of
+// the page before, so streams of any length are read to the end; the events at that
+// timestamp which fn has seen already are skipped. On error fn has seen the events up
+// to the failed page.
+func (h *Hub) eachEvent(subject string, fn func(event eventbus.Event)) error {
+	var since time.Time
+	seen := 0 // events at since passed to fn
+	for {
+		events, err := h.Bus.HistorySince(h.context(), subject, since, erasurePageSize, erasureFetchMaxWait)
+		if err != nil {
+			return err
+		}
+		skip := 0
+		for skip < seen && skip < len(events) && events[skip].Timestamp.Equal(since) {
+			skip++
+		}
+		for _, event := range events[skip:] {
+			fn(event)
+		}
+		if len(events) < erasurePageSize {
+			return nil
+		}
+
+		last := events[len(events)-1].Timestamp
+		if last.Equal(since) {
+			return fmt.Errorf("more than %d events of %s at %s, later records were not checked", erasurePageSize, subject, since.Format(time.RFC3339Nano))
+		}
+		since, seen = last, 0
+		for i := len(events) - 1; i >= 0 && events[i].Timestamp.Equal(last); i-- {
+			seen++
+		}
+	}
+}
+
+// eraseArchives rewrites the archived rounds of an erased user and audits the outcome.
+// Stopping the hub cancels the rewrite, which is then audited as failed.
+func (h *Hub) eraseArchives(username, actor string, match func(username string) bool) {
+	ctx, cancel := context.WithTimeout(h.context(), erasureArchiveTimeout)
+	defer cancel()
+	rewritten, err := h.archiver.eraseUser(ctx, match)
+	if err != nil {
+		h.Logger.Errorf("Erasing archived rounds of %s failed after %d archives: %v", username, rewritten, err)
+		h.Audit(AuditUserDataErased, username, "Archive erasure failed", fmt.Sprintf("rewritten=%d error=%v", rewritten, err))
+		return
+	}
+	h.Logger.Infof("Erased %s from %d archived rounds", username, rewritten)
+	h.Audit(AuditUserDataErased, username, "Archived rounds erased for "+actor, fmt.Sprintf("rewritten=%d", rewritten))
+}
+
+// erasedWinner returns the winner of a round with the erased user's name and submission
+// removed, keeping the message ID so corrections can supersede it.
+func erasedWinner(winner *RoundMessage) *RoundMessage {
+	return &RoundMessage{ID: winner.ID, Username: ErasedUsername, Timestamp: winner.Timestamp}
+}
+
+// eraseUser removes the participants match accepts from a summary and anonymizes its
+// winner. It reports whether the summary changed.
+func (s *RoundSummary) eraseUser(match func(username string) bool) bool {
+	changed := false
+	kept := make([]string, 0, len(s.Participants))
+	for _, username := range s.Participants {
+		if match(username) {
+			changed = true
+			continue
+		}
+		kept = append(kept, username)
+	}
+	s.Participants = kept
+	if s.Winner != "" && match(s.Winner) {
+		s.Winner = ErasedUsername
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,73 @@
+// internal/hub/framesize.go
+package hub
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// MessageTooLargeCode is the error code sent before a connection is closed for a frame
+// above the read limit.
+const MessageTooLargeCode = "MESSAGE_TOO_LARGE"
+
+const (
+	// maxEncodedCharBytes is the most bytes a character of submission text takes in a
+	// frame: four for UTF-8, six for a \uXXXX escape.
+	maxEncodedCharBytes = 6
+	// frameEnvelopeBytes covers everything around the text: type, version, lang,
+	// attachment_id, choice and message_id.
+	frameEnvelopeBytes = 1024
+)
+
+// errFrameTooLarge is returned by readFrame for a frame above the read limit.
+var errFrameTooLarge = errors.New("frame exceeds the read limit")
+
+// readLimit returns the largest client frame the hub reads, in bytes. It follows from
+// max_message_length, or encrypted_max_bytes in encrypted rooms, so any submission
+// that passes validation also fits in a frame.
+func (h *Hub) readLimit() int64 {
+	payload := h.maxMessageLength() * maxEncodedCharBytes
+	if h.encrypted {
+		maxBytes := h.settings().EncryptedMaxBytes
+		if maxBytes <= 0 {
+			maxBytes = defaultEncryptedMaxBytes
+		}
+		payload = max(payload, base64.StdEncoding.EncodedLen(maxBytes))
+	}
+	return int64(payload + frameEnvelopeBytes)
+}
+
+// readFrame reads the next frame of a client. A frame above limit returns
+// errFrameTooLarge after limit bytes, leaving the connection open so the client can
+// still be told why it is closed.
+func readFrame(conn *websocket.Conn, limit int64) ([]byte, error) {
+	_, r, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errFrameTooLarge
+	}
+	return data, nil
+}
+
+// rejectLargeFrame answers a frame above limit with a MESSAGE_TOO_LARGE error and has
+// the write pump close the connection with 1009 once the error is written.
+func (h *Hub) rejectLargeFrame(client *Client, limit int64) {
+	h.Logger.Warnf("Closing connection of %s: frame larger than %d bytes", client.Username(), limit)
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":    "1.0",
+		"type":       "error",
+		"data":       "Message too large, the connection will be closed",
+		"error_code": MessageTooLargeCode,
+		"max_bytes":  limit,
+	})
+	client.closeWith(websocket.CloseMessageTooBig, "message too big")
+}
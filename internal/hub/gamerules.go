@@ -0,0 +1,66 @@
+// internal/hub/gamerules.go
+package hub
+
+import (
+	"time"
+
+	"github.com/erilali/internal/message"
+)
+
+// Submission length bounds in characters, checked after sanitizing. The upper bound is
+// max_message_length, defaultMaxMessageLength when unset.
+const (
+	minMessageLength        = 1
+	defaultMaxMessageLength = 500
+)
+
+// GameRules is the rule set served by /api/rules.
+type GameRules = message.GameRules
+
+// GameRules returns the active rule set. Rounds per hour and the submission window
+// follow from the configured round length and pause; adaptive rounds vary around it.
+func (h *Hub) GameRules() GameRules {
+	cfg := h.settings()
+	length := h.roundDuration()
+	winnerMode := message.WinnerModeRandom
+	if cfg.WinnerScoring.Enabled {
+		winnerMode = message.WinnerModeWeighted
+	}
+	return GameRules{
+		MinMessageLength:        minMessageLength,
+		MaxMessageLength:        h.maxMessageLength(),
+		SanitizeMode:            cfg.SanitizeMode,
+		RoundMode:               cfg.RoundMode,
+		RoundDurationSeconds:    int(length / time.Second),
+		AdaptiveRounds:          cfg.AdaptiveRounds,
+		RoundsPerHour:           float64(time.Hour) / float64(length+h.roundPause()),
+		SubmissionWindowSeconds: int(h.submissionWindow(length) / time.Second),
+		RoundPauseSeconds:       int(h.roundPause() / time.Second),
+		PacingProfile:           cfg.PacingProfile,
+		MaxSubmissionsPerRound:  cfg.MaxSubmissionsPerRound,
+		WinnerMode:              winnerMode,
+		ScriptedRules:           h.Rules != nil,
+		DuplicateContent:        h.duplicateMode(),
+	}
+}
+
+// maxMessageLength returns the longest submission text in characters.
+func (h *Hub) maxMessageLength() int {
+	if n := h.settings().MaxMessageLength; n > 0 {
+		return n
+	}
+	return defaultMaxMessageLength
+}
+
+// broadcastRulesUpdate sends the rule set to every client when it differs from before.
+func (h *Hub) broadcastRulesUpdate(before GameRules) {
+	rules := h.GameRules()
+	if rules == before {
+		return
+	}
+	h.BroadcastMessage(map[string]interface{}{
+		"version": "1.0",
+		"type":    "rules_update",
+		"data":    rules,
+	})
+}
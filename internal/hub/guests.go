@@ -0,0 +1,119 @@
+// internal/hub/guests.go
+package hub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// guestPrefix starts every generated guest name. Registered names may not use it.
+const guestPrefix = "guest_"
+
+// GuestRestrictedCode is the error code sent to guests when guest_can_submit is off.
+const GuestRestrictedCode = "GUEST_RESTRICTED"
+
+// Words for guest names, short enough that every name fits the default username length.
+var (
+	guestAdjectives = []string{"red", "blue", "green", "gold", "gray", "pink", "teal", "jade", "amber", "misty", "sunny", "swift", "calm", "brave"}
+	guestAnimals    = []string{"panda", "otter", "fox", "owl", "lynx", "heron", "koala", "yak", "wolf", "hare", "finch", "moose", "seal", "crane"}
+)
+
+// isGuestName reports whether a username belongs to a guest.
+func isGuestName(username string) bool {
+	return strings.HasPrefix(username, guestPrefix)
+}
+
+// newGuestName returns a readable guest name such as guest_red_panda_42 that no
+// connected client is using. When ten random names are all taken it falls back to the
+// name made of the connection's sessionID, which is unique.
+func (h *Hub) newGuestName(sessionID string) string {
+	for attempt := 0; attempt < 10; attempt++ {
+		name := fmt.Sprintf("%s%s_%s_%d", guestPrefix,
+			guestAdjectives[h.rng.Intn(len(guestAdjectives))],
+			guestAnimals[h.rng.Intn(len(guestAnimals))],
+			h.rng.Intn(100))
+		if !h.usernameConnected(name) {
+			return name
+		}
+	}
+	return guestPrefix + strings.ToLower(sessionID)
+}
+
+// usernameConnected reports whether a connected client uses the name, in any case when
+// the username policy is case-insensitive.
+func (h *Hub) usernameConnected(username string) bool {
+	for _, client := range h.clients.snapshot() {
+		if h.sameUsername(client.Username(), username) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendIdentity tells the client the name it plays under and whether it is a guest.
+// After signing in it also carries the stored preferences of the registered name.
+func (h *Hub) sendIdentity(client *Client) {
+	data := map[string]interface{}{
+		"username": client.Username(),
+		"guest":    client.Guest(),
+	}
+	if prefs := client.Preferences(); prefs != nil {
+		data["preferences"] = prefs
+	}
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version": "1.0",
+		"type":    "identity",
+		"data":    data,
+	})
+}
+
+// handleAuth upgrades a guest to a registered name. The name must be valid, not banned
+// and not in use. A submission made as a guest in the current round still counts
+// against the new name.
+func (h *Hub) handleAuth(client *Client, message map[string]interface{}) {
+	if !client.Guest() {
+		h.SendErrorMessage(client, "Only guests can sign in")
+		return
+	}
+	data, _ := message["data"].(map[string]interface{})
+	username, _ := data["username"].(string)
+	username, err := h.checkUsername(username)
+	if err != nil {
+		h.SendErrorMessage(client, "Cannot sign in: "+err.Error())
+		return
+	}
+	if h.isBanned(username) {
+		h.SendErrorMessage(client, "User is banned")
+		return
+	}
+	if h.usernameConnected(username) {
+		h.SendErrorMessage(client, "Username is already in use")
+		return
+	}
+
+	guestName := client.Username()
+	client.signIn(username)
+	h.rosterChanged()
+	if limiter := h.limiter.Load(); limiter.has(h.usernameKey(guestName)) {
+		limiter.tryMark(h.usernameKey(username))
+	}
+	h.loadPreferences(client)
+	h.sendIdentity(client)
+	h.auditClient(AuditSignIn, client, "Guest signed in", guestName)
+	h.Logger.Infof("Guest %s signed in as %s", guestName, username)
+}
+
+// eligibleWinners returns the messages that may win. Guest submissions are excluded
+// unless guests_can_win is set.
+func (h *Hub) eligibleWinners(messages []RoundMessage) []RoundMessage {
+	if h.settings().GuestsCanWin {
+		return messages
+	}
+	eligible := make([]RoundMessage, 0, len(messages))
+	for _, msg := range messages {
+		if !isGuestName(msg.Username) {
+			eligible = append(eligible, msg)
+		}
+	}
+	return eligible
+}
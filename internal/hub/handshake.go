@@ -0,0 +1,151 @@
+// internal/hub/handshake.go
+package hub
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Rejected handshakes are logged at up to handshakeLogPerSecond with bursts of
+// handshakeLogBurst, so a flood of bad upgrade requests cannot flood the log. Every
+// rejection is still counted.
+const (
+	handshakeLogPerSecond = 1
+	handshakeLogBurst     = 10
+	maxLoggedUsername     = 64 // characters of a rejected username that are logged
+)
+
+// Reasons a WebSocket upgrade request is turned away.
+const (
+	HandshakeMissingUsername        = "missing_username"
+	HandshakeInvalidUsername        = "invalid_username"
+	HandshakeBanned                 = "banned"
+	HandshakeOriginRejected         = "origin_rejected"
+	HandshakeUnsupportedSubprotocol = "unsupported_subprotocol"
+	HandshakeOverCapacity           = "over_capacity"
+	HandshakeUpgradeFailed          = "upgrade_failed"
+	HandshakeShuttingDown           = "shutting_down"
+	HandshakeHTTPVersion            = "http_version"
+	HandshakeRoomNotFound           = "room_not_found"
+	HandshakeRoomCode               = "invalid_room_code"
+	HandshakeInvalidToken           = "invalid_token"
+	HandshakeUsernameTaken          = "username_taken" // in another case, see username_policy.case_insensitive
+	HandshakeInvalidResume          = "invalid_resume_token"
+)
+
+var handshakeReasons = []string{
+	HandshakeMissingUsername,
+	HandshakeInvalidUsername,
+	HandshakeBanned,
+	HandshakeOriginRejected,
+	HandshakeUnsupportedSubprotocol,
+	HandshakeOverCapacity,
+	HandshakeUpgradeFailed,
+	HandshakeShuttingDown,
+	HandshakeHTTPVersion,
+	HandshakeRoomNotFound,
+	HandshakeRoomCode,
+	HandshakeInvalidToken,
+	HandshakeUsernameTaken,
+	HandshakeInvalidResume,
+}
+
+// handshakeRejections counts rejected upgrade requests by reason since startup.
+type handshakeRejections struct {
+	mu       sync.Mutex
+	counts   map[string]uint64
+	logs     *frameLimiter // rejections that may be logged, nil until the first one
+	unlogged uint64        // rejections not logged since the last logged one
+}
+
+func (c *handshakeRejections) add(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]uint64)
+	}
+	c.counts[reason]++
+}
+
+// logAllowed reports whether a rejection at now may be logged and, if so, how many were
+// left unlogged before it.
+func (c *handshakeRejections) logAllowed(now time.Time) (bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.logs == nil {
+		c.logs = newFrameLimiter(handshakeLogPerSecond, handshakeLogBurst, now)
+	}
+	if !c.logs.allow(now) {
+		c.unlogged++
+		return false, 0
+	}
+	unlogged := c.unlogged
+	c.unlogged = 0
+	return true, unlogged
+}
+
+func (c *handshakeRejections) snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]uint64, len(handshakeReasons))
+	for _, reason := range handshakeReasons {
+		counts[reason] = c.counts[reason]
+	}
+	return counts
+}
+
+// HandshakeRejections returns the number of rejected WebSocket upgrades by reason,
+// including reasons that have not occurred.
+func (h *Hub) HandshakeRejections() map[string]uint64 {
+	return h.handshakes.snapshot()
+}
+
+// rejectHandshake counts and logs a rejected upgrade request, so operators can tell
+// misconfigured clients from attacks. Logging is rate limited and the username, which
+// the client chose, is cut to maxLoggedUsername characters.
+func (h *Hub) rejectHandshake(r *http.Request, reason, username string) {
+	h.handshakes.add(reason)
+	log, unlogged := h.handshakes.logAllowed(h.clock.Now())
+	if !log {
+		return
+	}
+	if utf8.RuneCountInString(username) > maxLoggedUsername {
+		username = string([]rune(username)[:maxLoggedUsername]) + "…"
+	}
+	metadata := h.inspector.inspect(r)
+	fields := map[string]interface{}{
+		"reason":     reason,
+		"username":   h.loggable(username),
+		"remote_ip":  metadata.RemoteIP,
+		"user_agent": metadata.UserAgent,
+		"origin":     r.Header.Get("Origin"),
+	}
+	if unlogged > 0 {
+		fields["unlogged"] = unlogged
+	}
+	h.Logger.WithFields(fields).Warn("WebSocket handshake rejected")
+}
+
+// originAllowed reports whether the request's Origin may open a WebSocket. Without
+// configured origins, or with "*", any origin is accepted. Requests without an Origin
+// header do not come from browsers and are always accepted.
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(allowed) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, candidate := range allowed {
+		if candidate == "*" || strings.EqualFold(strings.TrimSuffix(candidate, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package hub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHandshakeLogLimit(t *testing.T) {
+	var rejections handshakeRejections
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < handshakeLogBurst; i++ {
+		if log, _ := rejections.logAllowed(now); !log {
+			t.Fatalf("rejection %d of a burst not logged", i+1)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if log, _ := rejections.logAllowed(now); log {
+			t.Fatal("rejection beyond the burst logged")
+		}
+	}
+	log, unlogged := rejections.logAllowed(now.Add(time.Second))
+	if !log || unlogged != 5 {
+		t.Errorf("rejection a second later: logged %v with %d unlogged, want it logged with 5", log, unlogged)
+	}
+}
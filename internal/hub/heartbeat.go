@@ -0,0 +1,135 @@
+// internal/hub/heartbeat.go
+package hub
+
+import (
+	"time"
+
+	"github.com/erilali/internal/message"
+)
+
+// heartbeatStaleAfter is how long a reported app state holds without a new heartbeat.
+// After that the client counts as not reporting, as before its first heartbeat.
+const heartbeatStaleAfter = 2 * time.Minute
+
+// backgroundSkippedTypes are broadcasts a backgrounded app would not render and that are
+// outdated once it returns: they are not sent to backgrounded clients while
+// deprioritize_backgrounded is on. The client gets a time_sync when it is focused again.
+var backgroundSkippedTypes = map[string]bool{
+	"countdown":       true,
+	"time_sync":       true,
+	"reaction_counts": true,
+}
+
+// recordHeartbeat stores the app state and queue depth of a heartbeat and returns the
+// state reported before, empty when none was or it went stale.
+func (c *Client) recordHeartbeat(state string, queueDepth int, now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.appState
+	if now.Sub(c.heartbeatAt) > heartbeatStaleAfter {
+		previous = ""
+	}
+	c.appState, c.queueDepth, c.heartbeatAt = state, queueDepth, now
+	return previous
+}
+
+// AppState returns the app state and queue depth of the client's last heartbeat, empty
+// when it sent none in the heartbeatStaleAfter before now.
+func (c *Client) AppState(now time.Time) (string, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.appState == "" || now.Sub(c.heartbeatAt) > heartbeatStaleAfter {
+		return "", 0
+	}
+	return c.appState, c.queueDepth
+}
+
+// backgrounded reports whether the client's app recently said it is in the background.
+func (c *Client) backgrounded(now time.Time) bool {
+	state, _ := c.AppState(now)
+	return state == message.AppStateBackgrounded
+}
+
+// handleHeartbeat records the app state a client reports. A client coming back to the
+// foreground gets a time_sync, since those are skipped while it is backgrounded.
+func (h *Hub) handleHeartbeat(client *Client, msg map[string]interface{}) {
+	data, _ := msg["data"].(map[string]interface{})
+	state, _ := data["state"].(string)
+	if state != message.AppStateFocused && state != message.AppStateBackgrounded {
+		h.SendErrorMessage(client, "Invalid heartbeat state: expected focused or backgrounded")
+		return
+	}
+	depth, _ := data["queue_depth"].(float64)
+	if depth < 0 {
+		h.SendErrorMessage(client, "Invalid heartbeat queue_depth: must not be negative")
+		return
+	}
+	h.heartbeats.Add(1)
+	previous := client.recordHeartbeat(state, int(depth), h.clock.Now())
+	if previous == message.AppStateBackgrounded && state == message.AppStateFocused &&
+		h.settings().DeprioritizeBackgrounded {
+		h.sendMessageToClient(client, h.timeSyncMessage())
+	}
+}
+
+// broadcastOrder returns the clients a broadcast of messageType goes to, foreground and
+// non-reporting clients first so a full round of sends reaches them before backgrounded
+// ones. Without deprioritize_backgrounded it returns clients unchanged.
+func (h *Hub) broadcastOrder(clients []*Client, messageType string) []*Client {
+	if !h.settings().DeprioritizeBackgrounded {
+		return clients
+	}
+	now := h.clock.Now()
+	ordered := make([]*Client, 0, len(clients))
+	var background []*Client
+	for _, client := range clients {
+		if client.backgrounded(now) {
+			background = append(background, client)
+		} else {
+			ordered = append(ordered, client)
+		}
+	}
+	if backgroundSkippedTypes[messageType] {
+		return ordered
+	}
+	return append(ordered, background...)
+}
+
+// EngagementStats aggregates the heartbeats of the connected clients.
+type EngagementStats struct {
+	Clients       int     `json:"clients"`
+	Reporting     int     `json:"reporting"` // clients with a heartbeat in the last two minutes
+	Focused       int     `json:"focused"`
+	Backgrounded  int     `json:"backgrounded"`
+	FocusedRatio  float64 `json:"focused_ratio"`   // focused share of reporting clients
+	AvgQueueDepth float64 `json:"avg_queue_depth"` // over reporting clients
+	MaxQueueDepth int     `json:"max_queue_depth"`
+	Heartbeats    uint64  `json:"heartbeats"` // received since the server started
+}
+
+// Engagement returns the app states reported by the clients connected to this hub.
+func (h *Hub) Engagement() EngagementStats {
+	stats := EngagementStats{Heartbeats: h.heartbeats.Load()}
+	totalDepth := 0
+	now := h.clock.Now()
+	for _, client := range h.clients.snapshot() {
+		stats.Clients++
+		state, depth := client.AppState(now)
+		switch state {
+		case message.AppStateFocused:
+			stats.Focused++
+		case message.AppStateBackgrounded:
+			stats.Backgrounded++
+		default:
+			continue
+		}
+		stats.Reporting++
+		totalDepth += depth
+		stats.MaxQueueDepth = max(stats.MaxQueueDepth, depth)
+	}
+	if stats.Reporting > 0 {
+		stats.FocusedRatio = float64(stats.Focused) / float64(stats.Reporting)
+		stats.AvgQueueDepth = float64(totalDepth) / float64(stats.Reporting)
+	}
+	return stats
+}
@@ -0,0 +1,160 @@
+// internal/hub/history.go
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+// RecentRound is a finished round kept in memory so its history can still be served
+// while the event bus is unavailable.
+type RecentRound struct {
+	RoundID   int64
+	Messages  []RoundMessage
+	Winner    *RoundMessage // nil for rounds without submissions
+	Reactions map[string]int
+	Scores    map[string]float64 // submission scores by message ID, nil unless winner_scoring is enabled
+	Odds      *RoundOdds         // how the winner was drawn, nil for rounds without a draw
+	EndedAt   time.Time
+}
+
+// recentRounds retains the last limit finished rounds, oldest first.
+type recentRounds struct {
+	mu     sync.RWMutex
+	limit  int
+	rounds []RecentRound
+}
+
+func newRecentRounds(limit int) *recentRounds {
+	return &recentRounds{limit: limit}
+}
+
+// add records a finished round, dropping the oldest once the limit is exceeded.
+func (r *recentRounds) add(round RecentRound) {
+	if r.limit <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rounds = append(r.rounds, round)
+	if over := len(r.rounds) - r.limit; over > 0 {
+		r.rounds = append([]RecentRound(nil), r.rounds[over:]...)
+	}
+}
+
+// get returns a copy of a retained round.
+func (r *recentRounds) get(roundID int64) (RecentRound, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, round := range r.rounds {
+		if round.RoundID == roundID {
+			round.Messages = append([]RoundMessage(nil), round.Messages...)
+			return round, true
+		}
+	}
+	return RecentRound{}, false
+}
+
+// all returns the retained rounds, oldest first. Their message slices are shared.
+func (r *recentRounds) all() []RecentRound {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]RecentRound(nil), r.rounds...)
+}
+
+// setReactions stores the final reaction tally of a retained round.
+func (r *recentRounds) setReactions(roundID int64, counts map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.rounds {
+		if r.rounds[i].RoundID == roundID {
+			r.rounds[i].Reactions = counts
+			return
+		}
+	}
+}
+
+// setWinner replaces the winner of a retained round, and the odds it was drawn with,
+// after an appeal.
+func (r *recentRounds) setWinner(roundID int64, winner *RoundMessage, odds *RoundOdds) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.rounds {
+		if r.rounds[i].RoundID == roundID {
+			r.rounds[i].Winner = winner
+			r.rounds[i].Odds = odds
+			return
+		}
+	}
+}
+
+// remove drops a submission from a retained round.
+func (r *recentRounds) remove(roundID int64, messageID string) (RoundMessage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.rounds {
+		if r.rounds[i].RoundID != roundID {
+			continue
+		}
+		messages := r.rounds[i].Messages
+		for j, msg := range messages {
+			if msg.ID == messageID {
+				r.rounds[i].Messages = append(messages[:j:j], messages[j+1:]...)
+				return msg, true
+			}
+		}
+	}
+	return RoundMessage{}, false
+}
+
+// eraseUser drops the submissions match accepts from the retained rounds and anonymizes
+// the rounds they won. It returns how many submissions were dropped.
+func (r *recentRounds) eraseUser(match func(username string) bool) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	erased := 0
+	for i := range r.rounds {
+		round := &r.rounds[i]
+		kept := make([]RoundMessage, 0, len(round.Messages))
+		for _, msg := range round.Messages {
+			if match(msg.Username) {
+				erased++
+				continue
+			}
+			kept = append(kept, msg)
+		}
+		round.Messages = kept
+		if round.Winner != nil && match(round.Winner.Username) {
+			round.Winner = erasedWinner(round.Winner)
+		}
+		if round.Odds != nil {
+			odds := *round.Odds
+			odds.Entries = append([]EntryOdds(nil), odds.Entries...)
+			if odds.eraseUser(match) {
+				round.Odds = &odds
+			}
+		}
+	}
+	return erased
+}
+
+// rememberRound retains a finished round with its winner, if any, and the scores and
+// odds its winner was drawn with, indexes it for search and archives it.
+func (h *Hub) rememberRound(roundID int64, messages []RoundMessage, winner *RoundMessage, scores map[string]float64, odds *RoundOdds) {
+	endedAt := h.clock.Now()
+	h.recent.add(RecentRound{
+		RoundID:  roundID,
+		Messages: messages,
+		Winner:   winner,
+		Scores:   scores,
+		Odds:     odds,
+		EndedAt:  endedAt,
+	})
+	h.indexRound(roundID, messages, winner, endedAt)
+	h.archiveRound(roundID, messages, winner, scores, odds)
+}
+
+// RecentRound returns a finished round from the in-memory history.
+func (h *Hub) RecentRound(roundID int64) (RecentRound, bool) {
+	return h.recent.get(roundID)
+}
@@ -0,0 +1,326 @@
+// internal/hub/historystream.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/message"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	historyStreamMaxSpeed     = 100
+	historyStreamMaxGap       = 10 * time.Second // longest pause between two events, after speeding up
+	historyStreamEventLimit   = 10000            // events read per subject
+	historyStreamFetchMaxWait = 5 * time.Second
+)
+
+// historyStreamSubjects are the event subjects a /ws/history viewer receives.
+var historyStreamSubjects = []string{"rounds.started.*", "rounds.ended.*", "messages.*", "winners.*"}
+
+// errInvalidHistoryQuery is returned for a /ws/history request with a bad from or speed.
+var errInvalidHistoryQuery = errors.New("from must be an RFC 3339 time or Unix seconds in the past, speed a number from 1 to 100")
+
+// historyEvent is a recorded game event replayed to a /ws/history viewer.
+type historyEvent struct {
+	Subject   string
+	Timestamp time.Time
+	Data      json.RawMessage
+}
+
+// ServeHistory upgrades a replay viewer on /ws/history?from=TIME&speed=N. The viewer
+// receives history_start, then every round, submission and winner event recorded since
+// from as a history_event, paced like they happened, speed times faster (default 1,
+// at most 100) with pauses capped at ten seconds, and history_complete before the
+// server closes the connection. Events come from the event bus, or from the rounds kept
+// in memory when it is absent or cannot be read. Withdrawn and removed submissions are
+// left out. Frames sent by the viewer are ignored.
+func (h *Hub) ServeHistory(w http.ResponseWriter, r *http.Request) {
+	if h.parent != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if h.draining() {
+		h.rejectHandshake(r, HandshakeShuttingDown, "")
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if r.ProtoMajor != 1 {
+		h.rejectHandshake(r, HandshakeHTTPVersion, "")
+		http.Error(w, "WebSocket connections require HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	cfg := h.settings()
+	if !originAllowed(r, cfg.WebSocketAllowedOrigins) {
+		h.rejectHandshake(r, HandshakeOriginRejected, "")
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	from, speed, err := parseHistoryQuery(r.URL.Query(), h.clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if active := h.historyStreams.Add(1); cfg.MaxHistoryStreams > 0 && active > int64(cfg.MaxHistoryStreams) {
+		h.historyStreams.Add(-1)
+		h.rejectHandshake(r, HandshakeOverCapacity, "")
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "too many history streams", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := lobbyUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.historyStreams.Add(-1)
+		h.handshakes.add(HandshakeUpgradeFailed)
+		h.Logger.Errorf("History upgrade error: %v", err)
+		return
+	}
+	go func() {
+		defer h.historyStreams.Add(-1)
+		h.streamHistory(conn, from, speed)
+	}()
+}
+
+// parseHistoryQuery reads from, an RFC 3339 time or Unix seconds, and the optional speed.
+func parseHistoryQuery(query url.Values, now time.Time) (time.Time, float64, error) {
+	raw := query.Get("from")
+	from, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		seconds, parseErr := strconv.ParseInt(raw, 10, 64)
+		if parseErr != nil {
+			return time.Time{}, 0, errInvalidHistoryQuery
+		}
+		from = time.Unix(seconds, 0)
+	}
+	if from.After(now) {
+		return time.Time{}, 0, errInvalidHistoryQuery
+	}
+	speed := 1.0
+	if raw := query.Get("speed"); raw != "" {
+		speed, err = strconv.ParseFloat(raw, 64)
+		if err != nil || speed < 1 || speed > historyStreamMaxSpeed {
+			return time.Time{}, 0, errInvalidHistoryQuery
+		}
+	}
+	return from, speed, nil
+}
+
+// streamHistory sends the events since from to a viewer and closes the connection when
+// they ran out, the viewer left or the hub stopped.
+func (h *Hub) streamHistory(conn *websocket.Conn, from time.Time, speed float64) {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(h.context())
+	defer cancel()
+	go func() {
+		// The viewer sends nothing; reading notices when it goes away.
+		defer cancel()
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(webSocketReadDeadline))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(webSocketReadDeadline))
+			return nil
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	events, source := h.historyEvents(ctx, from)
+	if ctx.Err() != nil {
+		return
+	}
+	write := func(frame map[string]interface{}) bool {
+		conn.SetWriteDeadline(time.Now().Add(webSocketWriteDeadline))
+		return conn.WriteJSON(frame) == nil
+	}
+	if !write(map[string]interface{}{
+		"version": "1.0",
+		"type":    "history_start",
+		"from":    from,
+		"speed":   speed,
+		"source":  source,
+		"events":  len(events),
+	}) {
+		return
+	}
+
+	ping := time.NewTicker(webSocketPingPeriod)
+	defer ping.Stop()
+	previous := from
+	for _, event := range events {
+		wait := time.Duration(float64(event.Timestamp.Sub(previous)) / speed)
+		previous = event.Timestamp
+		timer := time.NewTimer(min(max(wait, 0), historyStreamMaxGap))
+	waiting:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-ping.C:
+				conn.SetWriteDeadline(time.Now().Add(webSocketWriteDeadline))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					timer.Stop()
+					return
+				}
+			case <-timer.C:
+				break waiting
+			}
+		}
+		if !write(map[string]interface{}{
+			"version":   "1.0",
+			"type":      "history_event",
+			"subject":   event.Subject,
+			"timestamp": event.Timestamp,
+			"data":      event.Data,
+		}) {
+			return
+		}
+	}
+	if write(map[string]interface{}{
+		"version": "1.0",
+		"type":    "history_complete",
+		"events":  len(events),
+	}) {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "end of history"),
+			time.Now().Add(webSocketWriteDeadline))
+	}
+}
+
+// historyEvents returns the events since from in the order they happened, and where they
+// were read from: "event_bus" or "memory". Event bus reads stop once ctx is done.
+func (h *Hub) historyEvents(ctx context.Context, from time.Time) ([]historyEvent, string) {
+	if h.Bus != nil {
+		events, err := h.busHistoryEvents(ctx, from)
+		if err == nil {
+			return events, "event_bus"
+		}
+		if ctx.Err() != nil {
+			return nil, "event_bus"
+		}
+		h.Logger.Warnf("History stream: event bus unavailable, replaying rounds kept in memory: %v", err)
+	}
+	return h.memoryHistoryEvents(from), "memory"
+}
+
+// busHistoryEvents reads the events since from off the event bus, each subject from
+// from on so its event limit applies to the replayed window. Submissions that were
+// withdrawn or removed later are dropped along with their edits and removal records.
+func (h *Hub) busHistoryEvents(ctx context.Context, from time.Time) ([]historyEvent, error) {
+	var events []historyEvent
+	removed := make(map[string]bool)
+	for _, subject := range historyStreamSubjects {
+		read, err := h.Bus.HistorySince(ctx, subject, from, historyStreamEventLimit, historyStreamFetchMaxWait)
+		if errors.Is(err, eventbus.ErrHistoryIncomplete) {
+			h.Logger.Warnf("History replay of %s is truncated: %v", subject, err)
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", subject, err)
+		}
+		for _, event := range read {
+			if event.Timestamp.Before(from) {
+				continue
+			}
+			if subject == "messages.*" {
+				var submission struct {
+					ID     string `json:"id"`
+					Action string `json:"action"`
+				}
+				json.Unmarshal(event.Data, &submission)
+				if submission.Action == messageActionWithdraw || submission.Action == messageActionRedact {
+					removed[submission.ID] = true
+					continue
+				}
+			}
+			events = append(events, historyEvent{Subject: event.Subject, Timestamp: event.Timestamp, Data: event.Data})
+		}
+	}
+
+	kept := events[:0]
+	for _, event := range events {
+		if len(removed) > 0 && strings.HasPrefix(event.Subject, "messages.") {
+			var submission struct {
+				ID string `json:"id"`
+			}
+			if json.Unmarshal(event.Data, &submission) == nil && removed[submission.ID] {
+				continue
+			}
+		}
+		kept = append(kept, event)
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Timestamp.Before(kept[j].Timestamp) })
+	return kept, nil
+}
+
+// memoryHistoryEvents rebuilds the events since from out of the finished rounds kept in
+// memory: their submissions as last edited, winner and end. Round starts are not kept.
+func (h *Hub) memoryHistoryEvents(from time.Time) []historyEvent {
+	var events []historyEvent
+	add := func(subject string, at time.Time, data map[string]any) {
+		if at.Before(from) {
+			return
+		}
+		if raw, err := json.Marshal(data); err == nil {
+			events = append(events, historyEvent{Subject: subject, Timestamp: at, Data: raw})
+		}
+	}
+	for _, round := range h.recent.all() {
+		for _, msg := range round.Messages {
+			data := map[string]any{
+				"id":       msg.ID,
+				"action":   messageActionSubmit,
+				"username": msg.Username,
+				"content":  msg.Message,
+				"payload": message.Submission{
+					Text:         msg.Message,
+					Lang:         msg.Lang,
+					AttachmentID: msg.AttachmentID,
+					Choice:       msg.Choice,
+				},
+				"timestamp": msg.Timestamp.Unix(),
+				"round_id":  round.RoundID,
+			}
+			if msg.Bot {
+				data["bot"] = true
+			}
+			add(fmt.Sprintf("messages.%d", round.RoundID), msg.Timestamp, data)
+		}
+		if winner := round.Winner; winner != nil {
+			data := map[string]any{
+				"round_id":   round.RoundID,
+				"message_id": winner.ID,
+				"username":   winner.Username,
+				"content":    winner.Message,
+				"timestamp":  round.EndedAt.Unix(),
+			}
+			if winner.Bot {
+				data["bot"] = true
+			}
+			add(fmt.Sprintf("winners.%d", round.RoundID), round.EndedAt, data)
+		}
+		status := "ended"
+		if len(round.Messages) == 0 {
+			status = "empty"
+		}
+		add(fmt.Sprintf("rounds.ended.%d", round.RoundID), round.EndedAt, map[string]any{
+			"round_id":  round.RoundID,
+			"timestamp": round.EndedAt.Unix(),
+			"status":    status,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return events
+}
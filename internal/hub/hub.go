@@ -3,166 +3,490 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/erilali/internal/attachments"
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/ids"
 	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+	"github.com/erilali/internal/rewards"
+	"github.com/erilali/internal/rules"
 	"github.com/nats-io/nats.go"
+	"github.com/oklog/ulid/v2"
 )
 
 // RoundMessage represents a message submitted during a round
-type RoundMessage struct {
-	Username  string    `json:"username"`
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
+type RoundMessage = message.RoundMessage
+
+// OutboundMessage is an encoded message queued for broadcast together with its type,
+// so the hub can skip clients that do not accept it.
+type OutboundMessage struct {
+	Type       string
+	Data       []byte
+	DeliveryID string // set on broadcasts that clients acknowledge, see delivery.go
 }
 
-// Hub represents the main hub that manages clients, rounds, and messaging
+// Hub represents the main hub that manages clients, rounds, and messaging.
+// State is split so that message handling does not contend on a single lock:
+// clients, submission limits and round messages each have their own synchronization,
+// while Mu only guards the round state (RoundActive, CurrentRoundID, RoundHistory, submission window).
 type Hub struct {
-	Clients     map[*Client]bool
 	Register    chan *Client
 	Unregister  chan *Client
-	Broadcast   chan []byte
+	Broadcast   chan OutboundMessage
 	RoundActive bool
-	Mu          sync.Mutex
+	Mu          sync.RWMutex
 
 	NatsConn       *nats.Conn
 	Js             nats.JetStreamContext
+	Bus            eventbus.EventBus // persistence/pub-sub backend, nil when unavailable
 	StartTime      time.Time
-	CurrentRoundID int64                    // current round ID (timestamp)
-	MessageLimiter map[string]bool          // maps username to round submission status
-	RoundMessages  map[int64][]RoundMessage // stores messages by round ID
-	Logger         *logger.Logger           // custom logger
+	CurrentRoundID int64                  // current round ID (timestamp)
+	RoundHistory   []RoundSummary         // finished rounds, oldest first
+	Logger         *logger.Logger         // custom logger
+	Config         config.Config          // server configuration, read through settings() since admins can adjust it at runtime
+	Rewards        rewards.RewardProvider // invoked after winner selection, nil when disabled
+	Rules          rules.Rules            // custom submission validation and winner scoring, nil without a rules script
+	Attachments    *attachments.Store     // uploaded media, nil when JetStream is unavailable
+
+	clients     *clientRegistry                   // connected clients
+	payloads    sharedPayloads                    // MessagePack forms of recent broadcasts, encoded once for every recipient
+	broadcasts  broadcastTimings                  // fan-out time of broadcasts
+	limiter     atomic.Pointer[submissionLimiter] // users who submitted in the current round, replaced each round
+	lastResult  atomic.Pointer[roundResult]       // winner of the last round that had one, for state_sync
+	events      *eventLog                         // sequence numbers and resync buffer of game events
+	instanceID  string                            // identifies this server instance on the control plane
+	idgen       ids.Generator                     // round, submission and session IDs, shared with room hubs
+	rounds      *roundStore                       // submitted messages by round ID
+	reactions   reactionTally                     // reactions for the round in its reveal phase
+	rejections  rejectionTally                    // rejected submissions by round until summarized
+	deliveries  *deliveryTracker                  // acknowledged broadcasts awaiting client acks
+	submissions *submissionLedger                 // persisted submissions by round and user, nil without JetStream
+	recent      *recentRounds                     // finished rounds served as history while the event bus is down
+	draws       *winnerDraws                      // candidates of recent winner selections, for appeals
+	publisher   *publishQueue                     // publishes submission events in the background, nil without an event bus
+	archiver    *archiveQueue                     // writes finished rounds to the object store, nil when archival is disabled
+	chaos       *chaosState                       // failures injected for resilience drills, nil unless chaos_mode is enabled
+	tournaments *tournamentTracker                // tournament brackets, nil when tournaments are disabled
+	series      *seriesTracker                    // best-of series, nil when series are disabled
+	cooldown    *winnerCooldown                   // recent winners left out of the draw, nil when winner_cooldown_rounds is 0
+	search      *searchIndex                      // tags and text index of finished rounds, shared with room hubs
+	userStats   *userStatsStore                   // lifetime statistics per user
+	preferences *preferencesStore                 // client preferences per user
+	room        string                            // name of the room this hub plays, defaultRoom for the main hub
+	encrypted   bool                              // submissions are ciphertext, set for encrypted rooms
+	rooms       *roomRegistry                     // private rooms, each played by its own hub; nil in room hubs
+	lobby       *lobby                            // room browser connections, nil in room hubs
+	parent      *Hub                              // main hub of a room hub, nil otherwise
+
+	submissionsCloseAt time.Time     // end of the current round's submission window, guarded by Mu
+	roundTiming        roundTiming   // deadlines of the current round, guarded by Mu
+	nextRoundLength    time.Duration // length of the next round chosen in adaptive mode, guarded by Mu
+	nextChoiceSet      int           // index of the choice set played by the next round in choices mode, guarded by Mu
+	roundExtended      bool          // the current round's entries were reopened for min_participants, guarded by Mu
+	roundsPlayed       int           // rounds ended since the hub was created, guarded by Mu
+	streakHolder       string        // winner of the latest round that had one, guarded by Mu
+	streakCount        int           // rounds in a row streakHolder won, guarded by Mu
+	roundCut           chan int64    // IDs of rounds to end before their scheduled end, or idleWake
+	roundsIdle         bool          // the round timer skipped its last round for want of clients, guarded by Mu
+
+	configMu sync.RWMutex   // guards Config against runtime adjustments
+	bansMu   sync.RWMutex   // guards bans
+	bans     map[string]Ban // by usernameKey
+
+	mutes *muteStore // muted usernames until their mute expires
+
+	roundsChangedMu sync.Mutex               // guards roundsChanged
+	roundsChanged   []func(roundIDs []int64) // called when the stored history of finished rounds changed, see OnRoundsChanged
+
+	historyStreams atomic.Int64 // open /ws/history replay viewers
+
+	heartbeats atomic.Uint64 // heartbeat frames received, for engagement statistics
+
+	resumeKeys *resumeKeyring // sign and verify session resume tokens, shared with room hubs
+
+	youWon heldWins // you_won messages for winners who were not connected
+
+	inspector  *connectionInspector // resolves client IP, user agent and country on connect
+	handshakes handshakeRejections  // rejected WebSocket upgrades by reason
+	inbound    *inboundCounters     // received frames and sampled message_received logs
+	upgrades   *upgradeTimings      // handshake and first message latencies, shared with room hubs
+	roster     participantRoster    // usernames last announced in user_joined and user_left
+	notices    *announcementBoard   // announcements repeated in state_sync, shared with room hubs
+
+	clock Clock      // time source for rounds, replaceable with SetClock
+	rng   *rand.Rand // winner selection, replaceable with SetRandSource
+
+	life lifecycle // context and goroutines of the current run, see Stop
+
+	admissionMu sync.Mutex // guards activeSlots, waitingRoom and Client.waiting
+	activeSlots int        // connection slots in use, bounded by Config.MaxConnections
+	waitingRoom []*Client  // upgraded connections queued for a slot, oldest first
 }
 
 // NewHub creates a new Hub instance and initializes its fields.
 // It sets up channels for client registration, unregistration, and message broadcasting.
 // It also initializes NATS connection details, logger, and other hub-specific properties.
-func NewHub(nc *nats.Conn, js nats.JetStreamContext, logger *logger.Logger) *Hub {
-	return &Hub{
-		Clients:        make(map[*Client]bool),
+func NewHub(cfg config.Config, nc *nats.Conn, js nats.JetStreamContext, bus eventbus.EventBus, logger *logger.Logger) *Hub {
+	var chaos *chaosState
+	if cfg.ChaosMode {
+		logger.Warn("Chaos mode enabled: failures can be injected through /api/admin/chaos")
+		chaos = &chaosState{}
+		bus = eventbus.WithPublishDelay(bus, chaos.delay)
+	}
+	h := newHub(cfg, nc, js, bus, logger)
+	h.chaos = chaos
+	if snowflake, ok := h.idgen.(*ids.Snowflake); ok {
+		logger.Infof("Generating snowflake IDs as node %d", snowflake.Node())
+	}
+	h.Rewards = newRewardProvider(cfg, js, logger)
+	h.Rules = newRules(cfg, logger)
+	h.Attachments = newAttachmentStore(js, cfg.ResourceName(attachmentsBucket), logger)
+	h.submissions = newSubmissionLedger(js, cfg.ResourceName(submissionsBucket), logger)
+	h.inspector = newConnectionInspector(cfg, logger)
+	h.userStats = newUserStatsStore(js, cfg.ResourceName(userStatsBucket), logger)
+	h.preferences = newPreferencesStore(js, cfg.ResourceName(preferencesBucket), logger)
+	h.mutes = newMuteStore(js, cfg.ResourceName(mutesBucket), h.clock.Now(), h.usernameKey, logger)
+	h.resumeKeys = newResumeKeyring(cfg, js, cfg.ResourceName(resumeKeysBucket), h.clock.Now(), logger)
+	h.tournaments = newTournamentTracker(js, cfg.ResourceName(tournamentsBucket), cfg.TournamentQualifyingRounds, logger)
+	h.series = newSeriesTracker(js, cfg.ResourceName(seriesBucket), cfg.SeriesRounds, logger)
+	h.cooldown = newWinnerCooldown(js, cfg.ResourceName(cooldownBucket), cfg.WinnerCooldownRounds, logger)
+	h.rooms = newRoomRegistry()
+	h.lobby = newLobby()
+	if bus != nil {
+		h.publisher = newPublishQueue(bus, cfg.PublishQueueSize, cfg.PublishMaxRetries, logger)
+	}
+	h.archiver = newArchiveQueue(cfg, logger)
+	h.search = newSearchIndex(js, cfg.ResourceName(roundTagsBucket), cfg.SearchIndexRounds, logger)
+	if h.archiver != nil && h.search.size > 0 {
+		go h.loadSearchIndex(h.context())
+	}
+	h.warnUsernamePolicy()
+	h.replayHistory(time.Duration(cfg.ReplayOnStartupMinutes) * time.Minute)
+	return h
+}
+
+// newHub creates a hub with its channels and in-memory state but none of the stores
+// and providers, which NewHub opens and room hubs share with the main hub.
+func newHub(cfg config.Config, nc *nats.Conn, js nats.JetStreamContext, bus eventbus.EventBus, logger *logger.Logger) *Hub {
+	h := &Hub{
 		Register:       make(chan *Client),
 		Unregister:     make(chan *Client),
-		Broadcast:      make(chan []byte),
+		Broadcast:      make(chan OutboundMessage),
 		RoundActive:    false,
 		NatsConn:       nc,
 		Js:             js,
+		Bus:            bus,
 		StartTime:      time.Now(),
 		CurrentRoundID: 0,
-		MessageLimiter: make(map[string]bool),
-		RoundMessages:  make(map[int64][]RoundMessage),
 		Logger:         logger,
+		Config:         cfg,
+		clients:        newClientRegistry(),
+		rounds:         newRoundStore(),
+		deliveries:     newDeliveryTracker(),
+		recent:         newRecentRounds(cfg.MemoryHistoryRounds),
+		draws:          newWinnerDraws(),
+		events:         newEventLog(cfg.EventBufferSize),
+		inbound:        newInboundCounters(),
+		upgrades:       newUpgradeTimings(),
+		notices:        &announcementBoard{},
+		bans:           make(map[string]Ban),
+		mutes:          newMemoryMuteStore(),
+		cooldown:       newMemoryWinnerCooldown(cfg.WinnerCooldownRounds),
+		search:         newMemorySearchIndex(cfg.SearchIndexRounds),
+		resumeKeys:     newMemoryResumeKeyring(time.Now()),
+		roundCut:       make(chan int64, 1),
+		room:           defaultRoom,
+	}
+	h.life.ctx, h.life.cancel = context.WithCancel(context.Background())
+	h.instanceID = cfg.InstanceID
+	if h.instanceID == "" {
+		h.instanceID = ulid.Make().String()
+	}
+	idgen, err := ids.New(cfg.IDGenerator, cfg.SnowflakeNode, h.instanceID)
+	if err != nil {
+		logger.Errorf("Using ULIDs, invalid ID generator: %v", err)
+		idgen = ids.ULID{}
 	}
+	h.idgen = idgen
+	h.limiter.Store(newSubmissionLimiter())
+	h.clock = realClock{}
+	h.SetRandSource(rand.NewSource(time.Now().UnixNano()))
+	return h
 }
 
-// Run starts the main event loop for the Hub.
-// It listens for new client registrations, client unregistrations, and messages to broadcast.
-// It also launches a goroutine to manage round timing.
+// Run starts the main event loop for the Hub and blocks until the hub is stopped with Stop.
 func (h *Hub) Run() {
+	if err := h.RunContext(context.Background()); err != nil {
+		h.Logger.Errorf("Hub not started: %v", err)
+	}
+}
+
+// RunContext starts the main event loop for the Hub and blocks until ctx is canceled or
+// Stop is called. It listens for new client registrations, client unregistrations, and
+// messages to broadcast, and launches the round timer and other background goroutines,
+// which all stop with the hub.
+func (h *Hub) RunContext(parent context.Context) error {
+	ctx, cancel := context.WithCancel(parent)
+	h.life.mu.Lock()
+	if h.life.running {
+		h.life.mu.Unlock()
+		cancel()
+		return ErrHubRunning
+	}
+	h.life.cancel() // release the context handed out before this run
+	h.life.ctx, h.life.cancel = ctx, cancel
+	h.life.done = make(chan struct{})
+	h.life.running = true
+	h.life.draining = false
+	done := h.life.done
+	background := append(([]func(context.Context))(nil), h.life.background...)
+	h.life.mu.Unlock()
+
+	defer func() {
+		h.shutdown()
+		h.life.workers.Wait()
+		h.life.mu.Lock()
+		h.life.running = false
+		h.life.mu.Unlock()
+		close(done)
+	}()
+
 	// Start the round timer
-	go h.StartRoundTimer()
+	h.goWorker(func() { h.StartRoundTimer(ctx) })
+	h.goWorker(func() { h.runLatencyProbe(ctx) })
+	h.goWorker(func() { h.runTimeSync(ctx) })
+	h.goWorker(func() { h.runReactionBroadcaster(ctx) })
+	h.goWorker(func() { h.runParticipantBroadcaster(ctx) })
+	h.goWorker(func() { h.runDeliverySweeper(ctx) })
+	h.goWorker(func() { h.runMuteExpiry(ctx) })
+	h.goWorker(func() { h.runResumeTokens(ctx) })
+	h.goWorker(func() { h.serveControl(ctx) })
+	if h.publisher != nil {
+		h.goWorker(func() { h.publisher.run(ctx) })
+	}
+	if h.archiver != nil {
+		h.goWorker(func() { h.archiver.run(ctx) })
+	}
+	if h.parent == nil {
+		h.goWorker(func() { h.search.watchTags(ctx) })
+	}
+	if h.rooms != nil {
+		h.goWorker(func() { h.runRoomReaper(ctx) })
+	}
+	for _, fn := range background {
+		h.goWorker(func() { fn(ctx) })
+	}
 
 	for {
 		select {
-		case client := <-h.Register:
-			h.Mu.Lock()
-			h.Clients[client] = true
-			roundActive := h.RoundActive
-			currentRoundID := h.CurrentRoundID
-			h.Mu.Unlock()
-
-			// Send current round status to the newly connected client
-			if roundActive {
-				roundMessage := map[string]interface{}{
-					"version": "1.0",
-					"type":    "round_start",
-					"data":    currentRoundID,
-				}
-				h.sendMessageToClient(client, roundMessage)
-			}
+		case <-ctx.Done():
+			return nil
 
-			h.Logger.Infof("Client registered: %s", client.Username)
+		case client := <-h.Register:
+			h.registerClient(client)
 
 		case client := <-h.Unregister:
-			h.Mu.Lock()
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				close(client.Send)
-				h.Logger.Infof("Client unregistered: %s", client.Username)
-			}
-			h.Mu.Unlock()
+			h.removeClient(client)
 
 		case message := <-h.Broadcast:
-			// To avoid holding the lock while sending on channels,
-			// we make a copy of the clients map.
-			h.Mu.Lock()
-			clientsToBroadcast := make([]*Client, 0, len(h.Clients))
-			for client := range h.Clients {
-				clientsToBroadcast = append(clientsToBroadcast, client)
-			}
-			h.Mu.Unlock()
-
-			for _, client := range clientsToBroadcast {
-				select {
-				case client.Send <- message:
-				default:
-					// Assume client is disconnected or slow.
-					// Let the read/write pumps handle the cleanup.
-					// We can trigger it by sending to the unregister channel.
-					h.Unregister <- client
-				}
-			}
+			// The registry hands out a snapshot so no lock is held while sending on channels.
+			h.broadcast(message)
+		}
+	}
+}
+
+// removeClient unregisters a client and hands its slot to the next client in the waiting
+// room. It must only be called from the Run goroutine; other goroutines use unregister.
+func (h *Hub) removeClient(client *Client) {
+	if !h.clients.remove(client) {
+		return
+	}
+	h.deliveries.forget(client)
+	close(client.Send)
+	h.Logger.Infof("Client unregistered: %s", client.Username())
+	h.roomChanged(roomUpdateOccupancy)
+	h.rosterChanged()
+
+	if next := h.releaseSlot(); next != nil {
+		h.sendAdmitted(next)
+		h.registerClient(next)
+		go h.notifyWaitingPositions()
+	}
+}
+
+// unregister asks the Run goroutine to remove a client. It does not block once the hub
+// stopped, since shutdown removes every client itself.
+func (h *Hub) unregister(client *Client) {
+	select {
+	case h.Unregister <- client:
+	case <-h.context().Done():
+	}
+}
+
+// ClientInfos returns a snapshot of every connected client for the admin API.
+func (h *Hub) ClientInfos() []ClientInfo {
+	clients := h.clients.snapshot()
+	now := h.clock.Now()
+	infos := make([]ClientInfo, 0, len(clients))
+	for _, client := range clients {
+		infos = append(infos, client.Info(now))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Username < infos[j].Username })
+	return infos
+}
+
+// registerClient adds a client to the hub and sends it the current round status.
+// It must only be called from the Run goroutine.
+func (h *Hub) registerClient(client *Client) {
+	h.clients.add(client)
+	h.sendStateSync(client)
+	h.sendResumeToken(client, client.resumed)
+	h.deliverHeldWin(client)
+	h.Mu.RLock()
+	roundActive := h.RoundActive
+	currentRoundID := h.CurrentRoundID
+	timing := h.roundTiming
+	idle := h.roundsIdle
+	h.Mu.RUnlock()
+
+	// The first client to join an idle hub starts a round rather than waiting out the
+	// round length the timer skipped.
+	if idle {
+		h.cutRound(idleWake)
+	}
+
+	// Send current round status to the newly connected client
+	if roundActive {
+		roundMessage := map[string]interface{}{
+			"version": "1.0",
+			"type":    "round_start",
+			"data":    currentRoundID,
 		}
+		timing.addTo(roundMessage)
+		addRoundChoices(roundMessage, h.roundChoices(currentRoundID))
+		h.sendMessageToClient(client, roundMessage)
+	}
+	if bracket, ok := h.currentTournament(); ok {
+		h.sendMessageToClient(client, map[string]interface{}{
+			"version": "1.0",
+			"type":    "bracket_update",
+			"data":    bracket,
+		})
 	}
+
+	h.Logger.Infof("Client registered: %s", client.Username())
+	h.roomChanged(roomUpdateOccupancy)
+	h.rosterChanged()
 }
 
 // sendMessageToClient sends a message directly to a specific client
 func (h *Hub) sendMessageToClient(client *Client, message map[string]interface{}) {
+	if messageType, _ := message["type"].(string); !client.Accepts(messageType) {
+		return
+	}
 	if data, err := json.Marshal(message); err == nil {
 		select {
 		case client.Send <- data:
 		default:
 			// Client is slow or disconnected, trigger cleanup
-			h.Unregister <- client
+			h.unregister(client)
 		}
 	}
 }
 
-// addRoundMessage adds a message to the current round
-func (h *Hub) addRoundMessage(roundID int64, username, messageText string) {
-	h.Mu.Lock()
-	defer h.Mu.Unlock()
+// newRoundMessage builds a submission with a fresh server-assigned ID.
+func (h *Hub) newRoundMessage(username string, submission message.Submission) RoundMessage {
+	return RoundMessage{
+		ID:           h.idgen.NewID(),
+		Username:     username,
+		Message:      submission.Text,
+		Lang:         submission.Lang,
+		AttachmentID: submission.AttachmentID,
+		Choice:       submission.Choice,
+		Encrypted:    h.encrypted,
+		Timestamp:    h.clock.Now(),
+	}
+}
 
-	if h.RoundMessages[roundID] == nil {
-		h.RoundMessages[roundID] = make([]RoundMessage, 0)
+// addRoundMessageCapped adds a message to a round unless the round already holds
+// maxMessages of them, zero meaning no cap, or, with a non-nil dedupe, one with the same
+// content. It returns the number of messages after the call, whether the message was
+// added and whether it was refused as a duplicate.
+func (h *Hub) addRoundMessageCapped(roundID int64, roundMsg RoundMessage, maxMessages int, dedupe *contentMatcher) (int, bool, bool) {
+	b := h.rounds.bucket(roundID)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if maxMessages > 0 && len(b.messages) >= maxMessages {
+		return len(b.messages), false, false
 	}
+	if dedupe != nil && dedupe.duplicateIn(b.messages, roundMsg.Message, "") {
+		return len(b.messages), false, true
+	}
+	b.messages = append(b.messages, roundMsg)
+	return len(b.messages), true, false
+}
 
-	roundMsg := RoundMessage{
-		Username:  username,
-		Message:   messageText,
-		Timestamp: time.Now(),
+// editRoundMessage replaces the content of a message owned by username.
+// It returns the updated message and false if no such message exists in the round, and
+// whether the edit was refused because, with a non-nil dedupe, another message of the
+// round has the new content.
+func (h *Hub) editRoundMessage(roundID int64, username, messageID string, submission message.Submission, dedupe *contentMatcher) (RoundMessage, bool, bool) {
+	b := h.rounds.bucket(roundID)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, msg := range b.messages {
+		if msg.ID == messageID && msg.Username == username {
+			if dedupe != nil && dedupe.duplicateIn(b.messages, submission.Text, messageID) {
+				return msg, true, true
+			}
+			b.messages[i].Message = submission.Text
+			b.messages[i].Lang = submission.Lang
+			b.messages[i].AttachmentID = submission.AttachmentID
+			b.messages[i].Choice = submission.Choice
+			b.messages[i].Timestamp = h.clock.Now()
+			return b.messages[i], true, false
+		}
 	}
+	return RoundMessage{}, false, false
+}
+
+// removeRoundMessage withdraws a message owned by username from the round
+// and clears the user's submission flag so they may submit again.
+func (h *Hub) removeRoundMessage(roundID int64, username, messageID string) (RoundMessage, bool) {
+	b := h.rounds.bucket(roundID)
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	h.RoundMessages[roundID] = append(h.RoundMessages[roundID], roundMsg)
+	for i, msg := range b.messages {
+		if msg.ID == messageID && msg.Username == username {
+			b.messages = append(b.messages[:i:i], b.messages[i+1:]...)
+			h.limiter.Load().clear(h.usernameKey(username))
+			return msg, true
+		}
+	}
+	return RoundMessage{}, false
 }
 
 // cleanupOldMessages removes messages from rounds older than the specified number of rounds
 func (h *Hub) cleanupOldMessages(currentRoundID int64) {
-	h.Mu.Lock()
-	defer h.Mu.Unlock()
-
 	const keepRounds = 3
-	var roundIDs []int64
-	for id := range h.RoundMessages {
-		roundIDs = append(roundIDs, id)
-	}
+	roundIDs := h.rounds.roundIDs()
 
 	if len(roundIDs) > keepRounds {
 		// Sort and keep only recent rounds
 		for _, id := range roundIDs {
 			if id < currentRoundID-int64(keepRounds-1) {
-				delete(h.RoundMessages, id)
+				h.rounds.delete(id)
 			}
 		}
 	}
@@ -0,0 +1,72 @@
+// internal/hub/inbound.go
+package hub
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// eventMessageReceived is the log event of an accepted submission.
+const eventMessageReceived = "message_received"
+
+// InboundStats counts what clients sent, so the totals stay visible when
+// message_log_sample_rate leaves most message_received events out of the info log.
+type InboundStats struct {
+	Frames     map[string]uint64 `json:"frames"`      // frames received by message type
+	Received   uint64            `json:"received"`    // accepted submissions, one message_received event each
+	Logged     uint64            `json:"logged"`      // message_received events logged at info
+	SampleRate int               `json:"sample_rate"` // 1 in this many message_received events is logged at info
+}
+
+// inboundCounters counts inbound frames and sampled message logs.
+type inboundCounters struct {
+	mu     sync.Mutex
+	frames map[string]uint64
+
+	received atomic.Uint64
+	logged   atomic.Uint64
+}
+
+func newInboundCounters() *inboundCounters {
+	return &inboundCounters{frames: make(map[string]uint64)}
+}
+
+// countFrame records a frame of the given message type.
+func (c *inboundCounters) countFrame(messageType string) {
+	c.mu.Lock()
+	c.frames[messageType]++
+	c.mu.Unlock()
+}
+
+// logMessageReceived logs an accepted submission. With message_log_sample_rate above 1
+// only the first of every that many is logged at info, noting the running total, and
+// the rest at debug, so debug logs still show every message.
+func (h *Hub) logMessageReceived(username string, roundID int64, text string) {
+	n := h.inbound.received.Add(1)
+	rate := h.settings().MessageLogSampleRate
+	detail := fmt.Sprintf("Message from %s in round %d: %s", username, roundID, h.loggable(text))
+	if rate > 1 && (n-1)%uint64(rate) != 0 {
+		h.Logger.LogEvent("debug", eventMessageReceived, username, detail)
+		return
+	}
+	h.inbound.logged.Add(1)
+	if rate > 1 {
+		detail += fmt.Sprintf(" (1 in %d logged, %d received)", rate, n)
+	}
+	h.Logger.LogEvent("info", eventMessageReceived, username, detail)
+}
+
+// InboundStats returns the inbound frame and message log counters of the hub.
+func (h *Hub) InboundStats() InboundStats {
+	h.inbound.mu.Lock()
+	frames := maps.Clone(h.inbound.frames)
+	h.inbound.mu.Unlock()
+	return InboundStats{
+		Frames:     frames,
+		Received:   h.inbound.received.Load(),
+		Logged:     h.inbound.logged.Load(),
+		SampleRate: max(h.settings().MessageLogSampleRate, 1),
+	}
+}
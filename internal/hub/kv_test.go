@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// memoryKV is the part of a JetStream key-value bucket the stores use, in memory. Like
+// the server it refuses a Create of an existing key and an Update at a stale revision.
+// beforeWrite, when set, runs before every Create and Update, so tests can interleave a
+// write of another instance or fail the write with its error.
+type memoryKV struct {
+	nats.KeyValue
+
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	revision    uint64
+	beforeWrite func(key string) error
+}
+
+type memoryEntry struct {
+	key      string
+	value    []byte
+	revision uint64
+}
+
+func (e memoryEntry) Bucket() string             { return "TEST" }
+func (e memoryEntry) Key() string                { return e.key }
+func (e memoryEntry) Value() []byte              { return e.value }
+func (e memoryEntry) Revision() uint64           { return e.revision }
+func (e memoryEntry) Created() time.Time         { return time.Time{} }
+func (e memoryEntry) Delta() uint64              { return 0 }
+func (e memoryEntry) Operation() nats.KeyValueOp { return nats.KeyValuePut }
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{entries: make(map[string]memoryEntry)}
+}
+
+func (kv *memoryKV) Get(key string) (nats.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entry, ok := kv.entries[key]
+	if !ok {
+		return nil, nats.ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+func (kv *memoryKV) Keys(...nats.WatchOpt) ([]string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if len(kv.entries) == 0 {
+		return nil, nats.ErrNoKeysFound
+	}
+	keys := make([]string, 0, len(kv.entries))
+	for key := range kv.entries {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (kv *memoryKV) Put(key string, value []byte) (uint64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.putLocked(key, value), nil
+}
+
+func (kv *memoryKV) Create(key string, value []byte) (uint64, error) {
+	return kv.Update(key, value, 0)
+}
+
+func (kv *memoryKV) Update(key string, value []byte, last uint64) (uint64, error) {
+	if kv.beforeWrite != nil {
+		if err := kv.beforeWrite(key); err != nil {
+			return 0, err
+		}
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entry, ok := kv.entries[key]
+	if last == 0 && ok {
+		return 0, nats.ErrKeyExists
+	}
+	if last != 0 && (!ok || entry.revision != last) {
+		return 0, &nats.APIError{Code: 400, ErrorCode: nats.JSErrCodeStreamWrongLastSequence, Description: "wrong last sequence"}
+	}
+	return kv.putLocked(key, value), nil
+}
+
+func (kv *memoryKV) putLocked(key string, value []byte) uint64 {
+	kv.revision++
+	kv.entries[key] = memoryEntry{key: key, value: value, revision: kv.revision}
+	return kv.revision
+}
+
+func (kv *memoryKV) Delete(key string, _ ...nats.DeleteOpt) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	delete(kv.entries, key)
+	return nil
+}
+
+func (kv *memoryKV) Purge(key string, opts ...nats.DeleteOpt) error {
+	return kv.Delete(key, opts...)
+}
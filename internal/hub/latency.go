@@ -0,0 +1,72 @@
+// internal/hub/latency.go
+package hub
+
+import (
+	"context"
+	"time"
+)
+
+// maxLatencyStrikes is how many consecutive slow pongs are tolerated before a client is dropped.
+const maxLatencyStrikes = 3
+
+// runLatencyProbe periodically sends an application level "ping" carrying the server time
+// to every client. Clients echo it back in a "pong", from which the RTT is computed.
+func (h *Hub) runLatencyProbe(ctx context.Context) {
+	interval := h.settings().LatencyPingSeconds
+	if interval <= 0 {
+		return
+	}
+	ticker := h.clock.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		ping := map[string]interface{}{
+			"version": "1.0",
+			"type":    "ping",
+			"data":    h.clock.Now().UnixMilli(),
+		}
+		for _, client := range h.clients.snapshot() {
+			h.sendMessageToClient(client, ping)
+		}
+	}
+}
+
+// handlePing answers a client initiated "ping" with a "pong" echoing the client timestamp
+// together with the server time, so the client can measure RTT and clock offset.
+func (h *Hub) handlePing(client *Client, message map[string]interface{}) {
+	pong := map[string]interface{}{
+		"version":     "1.0",
+		"type":        "pong",
+		"data":        message["data"],
+		"server_time": h.clock.Now().UnixMilli(),
+	}
+	h.sendMessageToClient(client, pong)
+}
+
+// handlePong records the RTT from a "pong" answering one of our pings and disconnects
+// clients that stay above the configured latency threshold.
+func (h *Hub) handlePong(client *Client, message map[string]interface{}) {
+	sentMillis, ok := message["data"].(float64)
+	if !ok {
+		h.SendErrorMessage(client, "Invalid pong data")
+		return
+	}
+	rtt := h.clock.Now().Sub(time.UnixMilli(int64(sentMillis)))
+	if rtt < 0 {
+		h.SendErrorMessage(client, "Invalid pong data")
+		return
+	}
+
+	maxLatencyMs := h.settings().MaxLatencyMs
+	strikes := client.recordRTT(rtt, maxLatencyMs)
+	if strikes >= maxLatencyStrikes {
+		h.Logger.Warnf("Disconnecting %s: RTT %v above %dms threshold", client.Username(), rtt, maxLatencyMs)
+		h.SendErrorMessage(client, "Connection latency too high")
+		client.Conn.Close()
+	}
+}
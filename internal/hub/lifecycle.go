@@ -0,0 +1,136 @@
+// internal/hub/lifecycle.go
+package hub
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrHubRunning is returned by RunContext when the hub is already running.
+var ErrHubRunning = errors.New("hub is already running")
+
+// lifecycle tracks one run of the hub, from RunContext until Run's loop returns.
+// A stopped hub can be run again.
+type lifecycle struct {
+	mu       sync.Mutex
+	ctx      context.Context // canceled when the current run stops
+	cancel   context.CancelFunc
+	done     chan struct{} // closed once the current run finished shutting down
+	running  bool
+	draining bool // Stop was called: no new connections or rounds
+
+	workers    sync.WaitGroup              // long-running goroutines of the current run
+	tasks      sync.WaitGroup              // one-off work such as winner selection that Stop waits for
+	background []func(ctx context.Context) // added with RunInBackground, started with every run
+}
+
+// context returns the context of the current run. Before the first run it is a live
+// context so connections can be accepted while the hub starts.
+func (h *Hub) context() context.Context {
+	h.life.mu.Lock()
+	defer h.life.mu.Unlock()
+	return h.life.ctx
+}
+
+// draining reports whether the hub is shutting down.
+func (h *Hub) draining() bool {
+	h.life.mu.Lock()
+	defer h.life.mu.Unlock()
+	return h.life.draining
+}
+
+// goWorker runs a long-running goroutine that stops with ctx.
+func (h *Hub) goWorker(fn func()) {
+	h.life.workers.Add(1)
+	go func() {
+		defer h.life.workers.Done()
+		fn()
+	}()
+}
+
+// RunInBackground has fn run as a background goroutine of the hub: it is started with
+// every run, at once if the hub is running, and its context is canceled when the hub
+// stops.
+func (h *Hub) RunInBackground(fn func(ctx context.Context)) {
+	h.life.mu.Lock()
+	defer h.life.mu.Unlock()
+	h.life.background = append(h.life.background, fn)
+	if ctx := h.life.ctx; h.life.running && ctx.Err() == nil {
+		h.goWorker(func() { fn(ctx) })
+	}
+}
+
+// goTask runs one-off work that Stop lets finish before shutting the hub down.
+func (h *Hub) goTask(fn func()) {
+	h.life.tasks.Add(1)
+	go func() {
+		defer h.life.tasks.Done()
+		fn()
+	}()
+}
+
+// Stop drains and shuts down a running hub: it stops accepting connections and starting
+// rounds, ends the active round and waits for its winner selection, then stops every
+// background goroutine and disconnects all clients. It returns ctx's error if the
+// shutdown does not finish in time; the hub keeps shutting down in the background.
+// After Stop returns, Run or RunContext may be called again.
+func (h *Hub) Stop(ctx context.Context) error {
+	h.life.mu.Lock()
+	if !h.life.running {
+		h.life.mu.Unlock()
+		return nil
+	}
+	h.life.draining = true
+	cancel, done := h.life.cancel, h.life.done
+	h.life.mu.Unlock()
+
+	h.Logger.Info("Stopping hub")
+	h.stopRooms(ctx)
+	h.EndRound()
+
+	tasksDone := make(chan struct{})
+	go func() {
+		h.life.tasks.Wait()
+		close(tasksDone)
+	}()
+	select {
+	case <-tasksDone:
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+
+	cancel()
+	select {
+	case <-done:
+		h.Logger.Info("Hub stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown disconnects every client once the Run loop returned. Closing a client's
+// send channel makes its write pump send a close frame, which ends its read pump too.
+func (h *Hub) shutdown() {
+	removed := 0
+	for _, client := range h.clients.snapshot() {
+		if h.clients.remove(client) {
+			h.deliveries.forget(client)
+			close(client.Send)
+			removed++
+		}
+	}
+
+	h.admissionMu.Lock()
+	h.activeSlots -= removed
+	queued := append([]*Client(nil), h.waitingRoom...)
+	h.admissionMu.Unlock()
+	for _, client := range queued {
+		client.Conn.Close()
+	}
+	if h.lobby != nil {
+		h.lobby.closeAll()
+	}
+}
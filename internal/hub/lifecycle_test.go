@@ -0,0 +1,36 @@
+package hub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+func TestRunInBackground(t *testing.T) {
+	h := newHub(config.DefaultConfig(), nil, nil, nil, logger.NewLogger("test"))
+	started := make(chan context.Context, 2)
+	h.RunInBackground(func(ctx context.Context) { started <- ctx })
+
+	go h.Run()
+	var ctx context.Context
+	select {
+	case ctx = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background work not started with the hub")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("background work started with a canceled context")
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("background work's context not canceled when the hub stopped")
+	}
+}
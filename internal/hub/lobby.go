@@ -0,0 +1,239 @@
+// internal/hub/lobby.go
+package hub
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Reasons carried by room_updated lobby events.
+const (
+	roomUpdateRoundStarted = "round_started"
+	roomUpdateRoundEnded   = "round_ended"
+	roomUpdateOccupancy    = "occupancy"
+)
+
+const lobbySendBuffer = 64
+
+// lobbyUpgrader upgrades /ws/lobby and /ws/history connections, which only receive JSON.
+var lobbyUpgrader = websocket.Upgrader{
+	ReadBufferSize:  512,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		// Checked against ws_allowed_origins in ServeLobby and ServeHistory.
+		return true
+	},
+}
+
+// lobbySubscriber is a /ws/lobby connection of a room browser.
+type lobbySubscriber struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// lobby holds the room browser connections of the main hub and sends them room
+// lifecycle events. Subscribers do not play, so they need no username or slot.
+type lobby struct {
+	mu          sync.Mutex
+	subscribers map[*lobbySubscriber]bool
+}
+
+func newLobby() *lobby {
+	return &lobby{subscribers: make(map[*lobbySubscriber]bool)}
+}
+
+// add registers a subscriber unless the lobby already holds limit of them; zero means
+// no limit.
+func (l *lobby) add(s *lobbySubscriber, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit > 0 && len(l.subscribers) >= limit {
+		return false
+	}
+	l.subscribers[s] = true
+	return true
+}
+
+// remove unregisters a subscriber and closes its send channel once.
+func (l *lobby) remove(s *lobbySubscriber) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.subscribers[s] {
+		delete(l.subscribers, s)
+		close(s.send)
+	}
+}
+
+// broadcast queues data for every subscriber, dropping those too slow to keep up.
+func (l *lobby) broadcast(data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for s := range l.subscribers {
+		select {
+		case s.send <- data:
+		default:
+			delete(l.subscribers, s)
+			close(s.send)
+		}
+	}
+}
+
+// closeAll disconnects every subscriber when the hub shuts down.
+func (l *lobby) closeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for s := range l.subscribers {
+		delete(l.subscribers, s)
+		close(s.send)
+	}
+}
+
+// ServeLobby upgrades a room browser connection on /ws/lobby. The subscriber receives a
+// lobby_snapshot of every room, then room_created, room_updated and room_deleted events.
+// Frames sent by the subscriber are ignored.
+func (h *Hub) ServeLobby(w http.ResponseWriter, r *http.Request) {
+	if h.lobby == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if h.draining() {
+		h.rejectHandshake(r, HandshakeShuttingDown, "")
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if r.ProtoMajor != 1 {
+		h.rejectHandshake(r, HandshakeHTTPVersion, "")
+		http.Error(w, "WebSocket connections require HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	cfg := h.settings()
+	if !originAllowed(r, cfg.WebSocketAllowedOrigins) {
+		h.rejectHandshake(r, HandshakeOriginRejected, "")
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	conn, err := lobbyUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.handshakes.add(HandshakeUpgradeFailed)
+		h.Logger.Errorf("Lobby upgrade error: %v", err)
+		return
+	}
+	subscriber := &lobbySubscriber{conn: conn, send: make(chan []byte, lobbySendBuffer)}
+	if !h.lobby.add(subscriber, cfg.MaxLobbyConnections) {
+		h.handshakes.add(HandshakeOverCapacity)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "lobby full"),
+			time.Now().Add(webSocketWriteDeadline))
+		conn.Close()
+		return
+	}
+	// The snapshot is queued after registering, so no event between the two is lost;
+	// an event may arrive before the snapshot, which then already includes it.
+	h.sendLobby(subscriber, map[string]interface{}{
+		"version": "1.0",
+		"type":    "lobby_snapshot",
+		"rooms":   h.Rooms(),
+	})
+	go h.lobbyWritePump(subscriber)
+	go h.lobbyReadPump(subscriber)
+}
+
+// sendLobby queues a message for one subscriber.
+func (h *Hub) sendLobby(s *lobbySubscriber, message map[string]interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		h.Logger.Errorf("Failed to marshal lobby message: %v", err)
+		return
+	}
+	h.lobby.mu.Lock()
+	defer h.lobby.mu.Unlock()
+	if h.lobby.subscribers[s] {
+		select {
+		case s.send <- data:
+		default:
+		}
+	}
+}
+
+// lobbyReadPump discards incoming frames and unregisters the subscriber once the
+// connection closes or stops answering pings.
+func (h *Hub) lobbyReadPump(s *lobbySubscriber) {
+	defer func() {
+		h.lobby.remove(s)
+		s.conn.Close()
+	}()
+	s.conn.SetReadLimit(512)
+	s.conn.SetReadDeadline(time.Now().Add(webSocketReadDeadline))
+	s.conn.SetPongHandler(func(string) error {
+		s.conn.SetReadDeadline(time.Now().Add(webSocketReadDeadline))
+		return nil
+	})
+	for {
+		if _, _, err := s.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// lobbyWritePump writes queued lobby events and keeps the connection alive with pings.
+func (h *Hub) lobbyWritePump(s *lobbySubscriber) {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer func() {
+		ticker.Stop()
+		s.conn.Close()
+	}()
+	for {
+		select {
+		case data, ok := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(webSocketWriteDeadline))
+			if !ok {
+				s.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(webSocketWriteDeadline))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// announceLobby broadcasts a room event to the lobby.
+func (h *Hub) announceLobby(message map[string]interface{}) {
+	if h.lobby == nil {
+		return
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		h.Logger.Errorf("Failed to marshal lobby message: %v", err)
+		return
+	}
+	h.lobby.broadcast(data)
+}
+
+// roomChanged tells the lobby of the main hub that this room hub's rounds or occupancy
+// changed. It is a no-op outside room hubs.
+func (h *Hub) roomChanged(reason string) {
+	if h.parent == nil {
+		return
+	}
+	info, ok := h.parent.Room(h.room)
+	if !ok {
+		return // deleted, room_deleted was already announced
+	}
+	h.parent.announceLobby(map[string]interface{}{
+		"version": "1.0",
+		"type":    "room_updated",
+		"reason":  reason,
+		"room":    info,
+	})
+}
@@ -2,114 +2,464 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/message"
 )
 
-// validateUsername checks if the provided username is valid according to predefined rules.
-// Rules include length constraints (3-20 characters) and character set (alphanumeric and underscore).
-func validateUsername(username string) bool {
-	// Check length (3-20 characters)
-	if len(username) < 3 || len(username) > 20 {
-		return false
-	}
+// langTagPattern loosely matches BCP 47 language tags such as "en", "pt-BR" or "zh-Hant-TW".
+var langTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8}){0,3}$`)
 
-	// Check for only alphanumeric and underscore characters
-	for _, char := range username {
-		if !((char >= 'a' && char <= 'z') ||
-			(char >= 'A' && char <= 'Z') ||
-			(char >= '0' && char <= '9') ||
-			char == '_') {
-			return false
-		}
+// validateMessageContent sanitizes the provided message content according to the
+// sanitization mode and checks that the result has between minMessageLength and
+// maxLength characters. It returns the content to store and whether it is valid;
+// nothing is valid under an unknown mode.
+func validateMessageContent(content, mode string, maxLength int) (string, bool) {
+	plain, stored, ok := sanitizeContent(content, mode)
+	if !ok {
+		return "", false
+	}
+	if mode == config.SanitizeOff {
+		plain = strings.TrimSpace(plain)
 	}
 
-	return true
+	length := utf8.RuneCountInString(plain)
+	return stored, length >= minMessageLength && length <= maxLength
 }
 
-// validateMessageContent checks if the provided message content is valid.
-// It trims leading/trailing whitespace and then checks length constraints (1-500 characters).
-func validateMessageContent(content string) bool {
-	// Trim whitespace
-	content = strings.TrimSpace(content)
-
-	// Check length (1-500 characters)
-	return len(content) >= 1 && len(content) <= 500
+// parseSubmission reads the data of a client_message or edit_message, which is either a
+// plain string or a structured object, and validates each field. A top level
+// "attachment_id" or "choice" is accepted for clients that send plain string data.
+// In choices mode the text is the chosen option of the round. Submissions without a lang
+// take the locale the client declared in "hello".
+func (h *Hub) parseSubmission(client *Client, roundID int64, msg map[string]interface{}) (message.Submission, error) {
+	raw, err := json.Marshal(msg["data"])
+	if err != nil {
+		return message.Submission{}, errors.New("Invalid message data")
+	}
+	var data message.SubmissionData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return message.Submission{}, errors.New("Invalid message data: expected a string or an object with text, lang, attachment_id and choice")
+	}
+	submission := data.Submission
+	if submission.AttachmentID == "" {
+		submission.AttachmentID, _ = msg["attachment_id"].(string)
+	}
+	if submission.Choice == nil {
+		if choice, ok := msg["choice"].(float64); ok {
+			index := int(choice)
+			if float64(index) != choice {
+				return submission, fmt.Errorf("%w: expected an option index", errInvalidChoice)
+			}
+			submission.Choice = &index
+		}
+	}
+	if h.encrypted || submission.Ciphertext != "" {
+		if err := h.acceptCiphertext(&submission); err != nil {
+			return submission, err
+		}
+	} else {
+		if err := h.applyChoice(roundID, &submission); err != nil {
+			return submission, err
+		}
+		maxLength := h.maxMessageLength()
+		text, ok := validateMessageContent(submission.Text, h.settings().SanitizeMode, maxLength)
+		if !ok {
+			return submission, fmt.Errorf("Invalid message content: text must be %d-%d characters", minMessageLength, maxLength)
+		}
+		submission.Text = text
+	}
+	if submission.Lang == "" {
+		submission.Lang = client.Capabilities().Locale
+	} else if !langTagPattern.MatchString(submission.Lang) {
+		return submission, errors.New("Invalid lang: expected a language tag such as \"en\" or \"pt-BR\"")
+	}
+	if submission.AttachmentID != "" && (h.Attachments == nil || !h.Attachments.Exists(submission.AttachmentID)) {
+		return submission, errors.New("Unknown attachment_id")
+	}
+	return submission, nil
 }
 
 // HandleClientMessage processes incoming messages from a connected client.
-// It first determines the message type and then routes it to the appropriate handler.
+// It first determines the message type, validates the frame against the protocol schema of
+// that type (see validation.go) and then routes it to the appropriate handler.
 // For "client_message" type, it performs checks for active round, submission limits, and message validity before processing.
-func (h *Hub) HandleClientMessage(client *Client, message map[string]interface{}) {
+// ctx bounds how long JetStream lookups on the way may hold up the client's next frame.
+func (h *Hub) HandleClientMessage(ctx context.Context, client *Client, message map[string]interface{}) {
 	messageType, ok := message["type"].(string)
 	if !ok {
 		h.SendErrorMessage(client, "Invalid message format")
 		return
 	}
+	h.inbound.countFrame(messageType)
+	if !h.botAllowed(client, messageType) || !h.roleAllowed(client, messageType) || !h.validFrame(client, message) {
+		return
+	}
 
 	switch messageType {
+	case "hello":
+		h.handleHello(client, message)
+	case "subscribe":
+		h.handleSubscribe(client, message)
+	case "reaction":
+		h.handleReaction(client, message)
+	case "ping":
+		h.handlePing(client, message)
+	case "pong":
+		h.handlePong(client, message)
+	case "heartbeat":
+		h.handleHeartbeat(client, message)
+	case "time_sync":
+		h.handleTimeSync(client, message)
+	case "participants":
+		h.handleParticipants(client)
+	case "auth":
+		h.handleAuth(client, message)
+	case "delivery_ack":
+		h.handleDeliveryAck(client, message)
+	case "resync_from":
+		h.handleResync(client, message)
 	case "client_message":
-		if !h.RoundActive {
+		round := h.submissionState()
+		if !round.active {
 			h.SendErrorMessage(client, "No active round")
 			return
 		}
-
-		// Check if user already submitted for this round
-		h.Mu.Lock()
-		if h.MessageLimiter[client.Username] {
-			h.Mu.Unlock()
-			h.SendErrorMessage(client, "You have already submitted a message for this round")
+		if client.Guest() && !h.settings().GuestsCanSubmit {
+			h.countRejection(round.roundID, rejectGuest)
+			h.SendErrorCode(client, GuestRestrictedCode, "Guests cannot submit, sign in with an auth message first")
+			return
+		}
+		if h.checkMuted(client) {
+			h.countRejection(round.roundID, rejectMuted)
+			return
+		}
+		if !round.open {
+			h.countRejection(round.roundID, rejectSubmissionsClosed)
+			h.SendErrorCode(client, SubmissionsClosedCode, "Submissions are closed for this round")
 			return
 		}
-		h.MessageLimiter[client.Username] = true
-		h.Mu.Unlock()
-		data, ok := message["data"].(string)
-		if !ok || data == "" {
-			h.SendErrorMessage(client, "Invalid message data")
+		if !h.tournamentEligible(client.Username(), round.roundID) {
+			h.countRejection(round.roundID, rejectNotAFinalist)
+			h.SendErrorCode(client, NotAFinalistCode, "Only tournament finalists can submit in the final round")
 			return
 		}
 
-		// Validate message content
-		if !validateMessageContent(data) {
-			h.SendErrorMessage(client, "Invalid message content: must be 1-500 characters")
+		// Check if user already submitted for this round. The mark is taken before the
+		// submission is validated, so concurrent submissions cannot both pass, and every
+		// rejection below clears it again so the user may send a valid one.
+		if !round.limiter.tryMark(h.usernameKey(client.Username())) {
+			h.countRejection(round.roundID, rejectDuplicate)
+			if !h.ackExistingSubmission(ctx, client, round.roundID) {
+				h.SendErrorMessage(client, "You have already submitted a message for this round")
+			}
+			return
+		}
+		submission, err := h.parseSubmission(client, round.roundID, message)
+		if err != nil {
+			round.limiter.clear(h.usernameKey(client.Username()))
+			h.countRejection(round.roundID, rejectInvalid)
+			h.sendSubmissionError(client, "", err)
+			h.within(ctx, "auditing a rejection", func() {
+				h.auditClient(AuditModerationRejection, client, err.Error(), h.loggable(submission.Text))
+			})
+			return
+		}
+		if ok, reason := h.checkRules(client, round.roundID, submission); !ok {
+			round.limiter.clear(h.usernameKey(client.Username()))
+			h.countRejection(round.roundID, rejectRules)
+			h.SendErrorCode(client, RuleRejectedCode, reason)
+			h.within(ctx, "auditing a rejection", func() {
+				h.auditClient(AuditModerationRejection, client, reason, h.loggable(submission.Text))
+			})
 			return
 		}
 
-		h.ProcessMessage(client, data)
+		h.ProcessMessage(ctx, client, round.roundID, submission)
+	case "edit_message":
+		h.handleEditMessage(client, message)
+	case "withdraw_message":
+		h.handleWithdrawMessage(ctx, client, message)
+	case "remove_message":
+		h.handleRemoveMessage(client, message)
+	case "mute":
+		h.handleMute(client, message)
 	default:
 		h.SendErrorMessage(client, "Unknown message type")
 	}
 }
 
-// ProcessMessage takes a valid client message during an active round, stores it,
-// broadcasts it to all clients, publishes to NATS, and logs the message.
-func (h *Hub) ProcessMessage(client *Client, content string) {
-	h.Mu.Lock()
-	currentRoundID := h.CurrentRoundID
-	h.Mu.Unlock()
+// handleHello records the capabilities a client declares in its "hello" handshake
+// and replies with a "welcome" message echoing the negotiated feature set.
+func (h *Hub) handleHello(client *Client, message map[string]interface{}) {
+	var caps Capabilities
+	raw, err := json.Marshal(message["data"])
+	if err == nil {
+		err = json.Unmarshal(raw, &caps)
+	}
+	if err != nil {
+		h.SendErrorMessage(client, "Invalid hello data")
+		return
+	}
+
+	if h.settings().WebSocketBatchMax <= 1 {
+		caps.Batch = false // batching disabled, welcome tells the client
+	}
+	if !langTagPattern.MatchString(caps.Locale) {
+		caps.Locale = "" // not a language tag, welcome tells the client
+	}
+	client.SetCapabilities(caps)
+	h.Logger.Debugf("Client %s capabilities: %+v", client.Username(), caps)
+
+	welcome := map[string]interface{}{
+		"version": "1.0",
+		"type":    "welcome",
+		"data":    caps,
+	}
+	h.sendMessageToClient(client, welcome)
+}
+
+// handleSubscribe updates which optional broadcast types the client receives.
+// The data object may carry "exclude" and "include" lists of message types.
+func (h *Hub) handleSubscribe(client *Client, message map[string]interface{}) {
+	var prefs struct {
+		Exclude []string `json:"exclude"`
+		Include []string `json:"include"`
+	}
+	raw, err := json.Marshal(message["data"])
+	if err == nil {
+		err = json.Unmarshal(raw, &prefs)
+	}
+	if err != nil {
+		h.SendErrorMessage(client, "Invalid subscribe data")
+		return
+	}
+
+	excluded := make(map[string]bool)
+	for _, t := range client.Excluded() {
+		excluded[t] = true
+	}
+	for _, t := range prefs.Exclude {
+		if !optionalMessageTypes[t] {
+			h.SendErrorMessage(client, "Message type cannot be unsubscribed: "+t)
+			return
+		}
+		excluded[t] = true
+	}
+	for _, t := range prefs.Include {
+		delete(excluded, t)
+	}
+
+	types := make([]string, 0, len(excluded))
+	for t := range excluded {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	client.SetExcluded(types)
+
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version": "1.0",
+		"type":    "subscribed",
+		"data":    map[string]interface{}{"excluded": types},
+	})
+}
+
+// ProcessMessage takes a valid client message accepted into the given round, stores it,
+// acknowledges it, publishes to NATS, and logs the message. If the round ended in the
+// meantime the client gets a ROUND_CLOSED error and nothing is stored; if the round
+// reached max_submissions_per_round it gets SUBMISSIONS_CLOSED. If claiming the
+// submission in the ledger outlasts ctx it gets a retriable PROCESSING_TIMEOUT instead.
+func (h *Hub) ProcessMessage(ctx context.Context, client *Client, currentRoundID int64, submission message.Submission) {
+	roundMsg := h.newRoundMessage(client.Username(), submission)
+	roundMsg.Bot = client.Bot()
 
-	// Store the message for winner selection
-	h.addRoundMessage(currentRoundID, client.Username, content)
+	// The ledger catches resubmissions after a reconnect or through another instance.
+	if h.submissions != nil {
+		existingID, err := h.submissions.claim(ctx, currentRoundID, h.usernameKey(client.Username()), roundMsg.ID)
+		if err != nil && ctx.Err() != nil {
+			// Nothing was stored: the user may send the submission again.
+			h.limiter.Load().clear(h.usernameKey(client.Username()))
+			h.countRejection(currentRoundID, rejectTimeout)
+			h.sendProcessingTimeout(client, "client_message")
+			return
+		} else if err != nil {
+			h.Logger.Errorf("Failed to record submission, relying on the local limiter: %v", err)
+		} else if existingID != "" {
+			h.countRejection(currentRoundID, rejectDuplicate)
+			existing, ok := h.userSubmission(currentRoundID, client.Username())
+			if ok && existing.ID == existingID {
+				h.SendDuplicateAck(client, currentRoundID, existingID, &existing)
+			} else {
+				h.SendDuplicateAck(client, currentRoundID, existingID, nil)
+			}
+			return
+		}
+	}
+
+	// Store the message for winner selection, unless the round ended while it was handled,
+	// or is full, or the content was submitted before with duplicates rejected
+	maxMessages := h.settings().MaxSubmissionsPerRound
+	dedupe := h.duplicateRejecter()
+	count, added, duplicate := 0, false, false
+	if !h.inRound(currentRoundID, func() {
+		count, added, duplicate = h.addRoundMessageCapped(currentRoundID, roundMsg, maxMessages, dedupe)
+	}) || !added {
+		if h.submissions != nil {
+			if err := h.submissions.release(ctx, currentRoundID, h.usernameKey(client.Username())); err != nil {
+				h.Logger.Errorf("Failed to release late submission: %v", err)
+			}
+		}
+		if duplicate {
+			// Nothing was stored: the user may submit something else.
+			h.limiter.Load().clear(h.usernameKey(client.Username()))
+			h.countRejection(currentRoundID, rejectDuplicateContent)
+			h.SendErrorCode(client, DuplicateContentCode, "This round already has a submission with the same content")
+			return
+		}
+		if count > 0 {
+			h.countRejection(currentRoundID, rejectSubmissionsClosed)
+			h.SendErrorCode(client, SubmissionsClosedCode, "Submissions are closed for this round")
+			return
+		}
+		h.countRejection(currentRoundID, rejectRoundClosed)
+		h.SendErrorCode(client, RoundClosedCode, "The round ended before your message was accepted")
+		h.Logger.Infof("Late message from %s rejected, round %d already ended", client.Username(), currentRoundID)
+		return
+	}
+	if maxMessages > 0 && count == maxMessages {
+		h.closeSubmissionsEarly(currentRoundID)
+	}
 
 	// No broadcast of individual messages – only the winning message is ever shown to everyone.
 	// Optionally still acknowledge the sender locally so they know it was accepted.
-	h.SendAckMessage(client) // Keep per-user ack (not broadcast)
+	h.SendAckMessage(client, currentRoundID, roundMsg.ID) // Keep per-user ack (not broadcast)
 
 	// Publish to NATS if available
-	h.publishMessageToNATS(client, content)
+	h.publishMessageToNATS(currentRoundID, messageActionSubmit, roundMsg)
+	h.recordSubmission(client.Username())
+
+	h.logMessageReceived(client.Username(), currentRoundID, submission.Text)
+}
+
+// handleEditMessage replaces the content of a submission the client made in the active round.
+// The submission is identified by the "message_id" returned in its ack.
+func (h *Hub) handleEditMessage(client *Client, message map[string]interface{}) {
+	round := h.submissionState()
+	if !round.active {
+		h.SendErrorMessage(client, "No active round")
+		return
+	}
+	if !round.open {
+		h.SendErrorCode(client, SubmissionsClosedCode, "Submissions are closed for this round")
+		return
+	}
+	if h.checkMuted(client) {
+		return
+	}
+	currentRoundID := round.roundID
+
+	messageID, _ := message["message_id"].(string)
+	if messageID == "" {
+		h.SendErrorMessage(client, "Invalid edit: message_id is required")
+		return
+	}
+	submission, err := h.parseSubmission(client, currentRoundID, message)
+	if err != nil {
+		h.sendSubmissionError(client, "Invalid edit: ", err)
+		return
+	}
+	if ok, reason := h.checkRules(client, currentRoundID, submission); !ok {
+		h.SendErrorCode(client, RuleRejectedCode, reason)
+		return
+	}
+
+	var roundMsg RoundMessage
+	var found, duplicate bool
+	dedupe := h.duplicateRejecter()
+	if !h.inRound(currentRoundID, func() {
+		roundMsg, found, duplicate = h.editRoundMessage(currentRoundID, client.Username(), messageID, submission, dedupe)
+	}) {
+		h.SendErrorCode(client, RoundClosedCode, "The round ended before your edit was applied")
+		return
+	}
+	if !found {
+		h.SendErrorMessage(client, "Unknown message_id for this round")
+		return
+	}
+	if duplicate {
+		h.SendErrorCode(client, DuplicateContentCode, "This round already has a submission with the same content")
+		return
+	}
 
-	h.Logger.Infof("Message from %s in round %d: %s", client.Username, currentRoundID, content)
+	h.SendAckMessage(client, currentRoundID, roundMsg.ID)
+	h.publishMessageToNATS(currentRoundID, messageActionEdit, roundMsg)
+	h.Logger.Infof("Message %s edited by %s in round %d", roundMsg.ID, client.Username(), currentRoundID)
+}
+
+// handleWithdrawMessage removes a submission the client made in the active round,
+// allowing them to submit a new message.
+func (h *Hub) handleWithdrawMessage(ctx context.Context, client *Client, message map[string]interface{}) {
+	round := h.submissionState()
+	if !round.active {
+		h.SendErrorMessage(client, "No active round")
+		return
+	}
+	if !round.open {
+		h.SendErrorCode(client, SubmissionsClosedCode, "Submissions are closed for this round")
+		return
+	}
+	currentRoundID := round.roundID
+
+	messageID, _ := message["message_id"].(string)
+	var roundMsg RoundMessage
+	var found bool
+	if !h.inRound(currentRoundID, func() {
+		roundMsg, found = h.removeRoundMessage(currentRoundID, client.Username(), messageID)
+	}) {
+		h.SendErrorCode(client, RoundClosedCode, "The round ended before your withdrawal was applied")
+		return
+	}
+	if !found {
+		h.SendErrorMessage(client, "Unknown message_id for this round")
+		return
+	}
+	if h.submissions != nil {
+		if err := h.submissions.release(ctx, currentRoundID, h.usernameKey(client.Username())); err != nil {
+			h.Logger.Errorf("Failed to release withdrawn submission: %v", err)
+		}
+	}
+
+	h.SendAckMessage(client, currentRoundID, roundMsg.ID)
+	h.publishMessageToNATS(currentRoundID, messageActionWithdraw, roundMsg)
+	h.Logger.Infof("Message %s withdrawn by %s in round %d", roundMsg.ID, client.Username(), currentRoundID)
 }
 
 // SendErrorMessage constructs and sends an error message to a specific client.
 // The error message includes a version, type ("error"), and the error details.
 // If sending fails, it closes the client's send channel and removes the client from the hub.
 func (h *Hub) SendErrorMessage(client *Client, errorMsg string) {
+	h.SendErrorCode(client, "", errorMsg)
+}
+
+// SendErrorCode sends an error message carrying a machine readable "error_code"
+// so clients can react to specific rejections. An empty code is omitted.
+func (h *Hub) SendErrorCode(client *Client, code, errorMsg string) {
 	message := map[string]interface{}{
 		"version": "1.0",
 		"type":    "error",
 		"data":    errorMsg,
 	}
+	if code != "" {
+		message["error_code"] = code
+	}
 
 	if data, err := json.Marshal(message); err == nil {
 		// The WritePump has a deadline and will handle a slow client.
@@ -120,12 +470,14 @@ func (h *Hub) SendErrorMessage(client *Client, errorMsg string) {
 
 // SendAckMessage constructs and sends an acknowledgment message to a specific client.
 // This is typically sent after a client's message has been successfully received and initially processed.
-// If sending fails, it closes the client's send channel and removes the client from the hub.
-func (h *Hub) SendAckMessage(client *Client) {
+// The ack carries the server-assigned message ID so the client can reference the submission later.
+func (h *Hub) SendAckMessage(client *Client, roundID int64, messageID string) {
 	message := map[string]interface{}{
-		"version": "1.0",
-		"type":    "ack",
-		"data":    "Message received successfully",
+		"version":    "1.0",
+		"type":       "ack",
+		"data":       "Message received successfully",
+		"message_id": messageID,
+		"round_id":   roundID,
 	}
 
 	if data, err := json.Marshal(message); err == nil {
@@ -135,10 +487,60 @@ func (h *Hub) SendAckMessage(client *Client) {
 	}
 }
 
+// SendDuplicateAck answers a repeated submission with the ID of the message the user
+// already submitted in the round, including the message itself when it is known locally.
+func (h *Hub) SendDuplicateAck(client *Client, roundID int64, messageID string, existing *RoundMessage) {
+	message := map[string]interface{}{
+		"version":    "1.0",
+		"type":       "ack",
+		"data":       "You have already submitted a message for this round",
+		"message_id": messageID,
+		"round_id":   roundID,
+		"duplicate":  true,
+	}
+	if existing != nil {
+		message["submission"] = existing
+	}
+	h.sendMessageToClient(client, message)
+}
+
+// anyClientAccepts reports whether at least one connected client wants the message type.
+func (h *Hub) anyClientAccepts(messageType string) bool {
+	for _, client := range h.clients.snapshot() {
+		if client.Accepts(messageType) {
+			return true
+		}
+	}
+	return false
+}
+
 // BroadcastMessage marshals a given message map into JSON and sends it to the hub's broadcast channel.
 // This channel is then read by the hub's Run loop to distribute the message to all connected clients.
 func (h *Hub) BroadcastMessage(message map[string]interface{}) {
+	messageType, _ := message["type"].(string)
+	if optionalMessageTypes[messageType] && !h.anyClientAccepts(messageType) {
+		// Nobody wants it, skip encoding and the broadcast loop entirely.
+		return
+	}
+	deliveryID := ""
+	if ackedMessageTypes[messageType] {
+		deliveryID = h.idgen.NewID()
+		message["delivery_id"] = deliveryID
+	}
+	send := func(data []byte) {
+		select {
+		case h.Broadcast <- OutboundMessage{Type: messageType, Data: data, DeliveryID: deliveryID}:
+		case <-h.context().Done():
+			// The hub stopped, there is nobody left to deliver to.
+		}
+	}
+	if sequencedMessageType(messageType) {
+		if err := h.events.publish(messageType, message, send); err != nil {
+			h.Logger.Errorf("Failed to marshal %s broadcast: %v", messageType, err)
+		}
+		return
+	}
 	if data, err := json.Marshal(message); err == nil {
-		h.Broadcast <- data
+		send(data)
 	}
 }
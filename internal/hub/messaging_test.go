@@ -0,0 +1,128 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+// newSubmissionHub returns a hub with round 1 open for submissions.
+func newSubmissionHub(t *testing.T, cfg config.Config) *Hub {
+	t.Helper()
+	h := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+	h.userStats = newUserStatsStore(nil, userStatsBucket, h.Logger)
+	h.Mu.Lock()
+	h.CurrentRoundID = 1
+	h.RoundActive = true
+	h.submissionsCloseAt = h.clock.Now().Add(time.Minute)
+	h.Mu.Unlock()
+	return h
+}
+
+// submitReply is the part of the hub's answer to a client_message the tests check.
+type submitReply struct {
+	Type      string `json:"type"`
+	Code      string `json:"error_code"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+// accepted reports whether the reply acknowledges a newly stored submission.
+func (r submitReply) accepted() bool {
+	return r.Type == "ack" && !r.Duplicate
+}
+
+// submit sends a client_message and returns the hub's reply.
+func submit(t *testing.T, h *Hub, client *Client, text string) submitReply {
+	t.Helper()
+	h.HandleClientMessage(context.Background(), client, map[string]interface{}{
+		"version": "1.0",
+		"type":    "client_message",
+		"data":    text,
+	})
+	select {
+	case data := <-client.Send:
+		var reply submitReply
+		if err := json.Unmarshal(data, &reply); err != nil {
+			t.Fatalf("reply %s: %v", data, err)
+		}
+		return reply
+	default:
+		t.Fatalf("no reply to %q", text)
+		return submitReply{}
+	}
+}
+
+func TestRejectedSubmissionCanBeResent(t *testing.T) {
+	cfg := config.DefaultConfig()
+	h := newSubmissionHub(t, cfg)
+	client := &Client{username: "ada", Send: make(chan []byte, 16)}
+
+	if reply := submit(t, h, client, ""); reply.Type != "error" {
+		t.Fatalf("empty submission answered with %+v, want an error", reply)
+	}
+	if reply := submit(t, h, client, "a valid submission"); !reply.accepted() {
+		t.Errorf("submission after a rejected one answered with %+v, want it accepted", reply)
+	}
+	if reply := submit(t, h, client, "another submission"); reply.accepted() {
+		t.Errorf("second submission in the round answered with %+v, want it refused", reply)
+	}
+}
+
+func TestDuplicateContentCanBeReplaced(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DuplicateContent = config.DuplicatesReject
+	h := newSubmissionHub(t, cfg)
+	ada := &Client{username: "ada", Send: make(chan []byte, 16)}
+	grace := &Client{username: "grace", Send: make(chan []byte, 16)}
+
+	if reply := submit(t, h, ada, "same words"); !reply.accepted() {
+		t.Fatalf("first submission answered with %+v, want it accepted", reply)
+	}
+	if reply := submit(t, h, grace, "same words"); reply.Code != DuplicateContentCode {
+		t.Fatalf("copied submission answered with %+v, want %s", reply, DuplicateContentCode)
+	}
+	if reply := submit(t, h, grace, "different words"); !reply.accepted() {
+		t.Errorf("submission after a duplicate answered with %+v, want it accepted", reply)
+	}
+}
+
+func TestRemovedSubmissionCanBeReplaced(t *testing.T) {
+	h := newSubmissionHub(t, config.DefaultConfig())
+	client := &Client{username: "ada", Send: make(chan []byte, 16)}
+
+	if reply := submit(t, h, client, "a valid submission"); !reply.accepted() {
+		t.Fatalf("submission answered with %+v, want it accepted", reply)
+	}
+	removed, ok := h.userSubmission(1, "ada")
+	if !ok {
+		t.Fatal("accepted submission not stored")
+	}
+	if _, err := h.RemoveSubmission(1, removed.ID, "off topic", "moderator"); err != nil {
+		t.Fatalf("RemoveSubmission: %v", err)
+	}
+	if reply := submit(t, h, client, "another submission"); !reply.accepted() {
+		t.Errorf("submission after a removed one answered with %+v, want it accepted", reply)
+	}
+}
+
+func TestRemoveMessageInvalidRoundID(t *testing.T) {
+	h := newSubmissionHub(t, config.DefaultConfig())
+	client := &Client{username: "moderator", Send: make(chan []byte, 16)}
+
+	for _, roundID := range []interface{}{nil, "1", 1.5, true} {
+		h.handleRemoveMessage(client, map[string]interface{}{"round_id": roundID, "message_id": "m1"})
+		select {
+		case data := <-client.Send:
+			var reply submitReply
+			if err := json.Unmarshal(data, &reply); err != nil || reply.Type != "error" {
+				t.Errorf("round_id %v answered with %s, want an error", roundID, data)
+			}
+		default:
+			t.Errorf("round_id %v not answered", roundID)
+		}
+	}
+}
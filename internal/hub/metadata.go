@@ -0,0 +1,67 @@
+// internal/hub/metadata.go
+package hub
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/util"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// maxUserAgentLength bounds the stored User-Agent so clients cannot bloat audit records.
+const maxUserAgentLength = 256
+
+// ConnectionMetadata describes where a connection came from.
+type ConnectionMetadata struct {
+	RemoteIP  string `json:"remote_ip"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Country   string `json:"country,omitempty"` // ISO 3166-1 alpha-2, when a GeoIP database is configured
+}
+
+// connectionInspector extracts connection metadata, honoring X-Forwarded-For from trusted
+// proxies and optionally resolving the country from a MaxMind GeoIP database.
+type connectionInspector struct {
+	trusted []*net.IPNet
+	geo     *geoip2.Reader
+}
+
+func newConnectionInspector(cfg config.Config, logger *logger.Logger) *connectionInspector {
+	inspector := &connectionInspector{}
+	trusted, err := util.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Errorf("Ignoring trusted proxies: %v", err)
+	} else {
+		inspector.trusted = trusted
+	}
+	if cfg.GeoIPDatabase != "" {
+		geo, err := geoip2.Open(cfg.GeoIPDatabase)
+		if err != nil {
+			logger.Errorf("Error opening GeoIP database, country tagging disabled: %v", err)
+		} else {
+			inspector.geo = geo
+		}
+	}
+	return inspector
+}
+
+// inspect returns the metadata of an incoming connection request.
+func (i *connectionInspector) inspect(r *http.Request) ConnectionMetadata {
+	meta := ConnectionMetadata{
+		RemoteIP:  util.ClientIP(r, i.trusted),
+		UserAgent: r.UserAgent(),
+	}
+	if len(meta.UserAgent) > maxUserAgentLength {
+		meta.UserAgent = meta.UserAgent[:maxUserAgentLength]
+	}
+	if i.geo != nil {
+		if ip := net.ParseIP(meta.RemoteIP); ip != nil {
+			if record, err := i.geo.Country(ip); err == nil {
+				meta.Country = record.Country.IsoCode
+			}
+		}
+	}
+	return meta
+}
@@ -0,0 +1,82 @@
+// internal/hub/moderation.go
+package hub
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMessageNotFound is returned when a submission to remove does not exist.
+var ErrMessageNotFound = errors.New("message not found")
+
+// Redaction describes a submission removed by a moderator.
+type Redaction struct {
+	RoundID   int64     `json:"round_id"`
+	MessageID string    `json:"message_id"`
+	Username  string    `json:"username,omitempty"` // empty when the round is no longer held in memory
+	Reason    string    `json:"reason,omitempty"`
+	RemovedBy string    `json:"removed_by"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+// RemoveSubmission takes a submission out of its round so it cannot win, publishes a
+// redaction record that removes it from history and tells the author why.
+// Rounds that are no longer held in memory can still be redacted in history, and every
+// instance drops the round from its history cache.
+// While the round is still running the author gets their submission slot back, so they
+// may submit again.
+func (h *Hub) RemoveSubmission(roundID int64, messageID, reason, actor string) (Redaction, error) {
+	removed, found := h.rounds.remove(roundID, messageID)
+	if retained, ok := h.recent.remove(roundID, messageID); ok && !found {
+		removed, found = retained, true
+	}
+	h.search.remove(roundKey{room: h.room, roundID: roundID}, messageID)
+	if !found && h.Bus == nil {
+		return Redaction{}, ErrMessageNotFound
+	}
+
+	redaction := Redaction{
+		RoundID:   roundID,
+		MessageID: messageID,
+		Username:  removed.Username,
+		Reason:    reason,
+		RemovedBy: actor,
+		RemovedAt: h.clock.Now(),
+	}
+	h.draws.drop(roundID, messageID)
+	h.publishRedactionToNATS(redaction)
+	h.invalidateRounds([]int64{roundID}, actor)
+	h.Audit(AuditAdminAction, removed.Username, "Submission removed by "+actor, messageID+": "+reason)
+	h.Logger.Infof("Message %s in round %d removed by %s: %s", messageID, roundID, actor, reason)
+
+	if found {
+		h.releaseSubmission(roundID, removed.Username)
+		notice := map[string]interface{}{
+			"version":    "1.0",
+			"type":       "message_removed",
+			"data":       reason,
+			"message_id": messageID,
+			"round_id":   roundID,
+		}
+		for _, client := range h.clients.snapshot() {
+			if client.Username() == removed.Username {
+				h.sendMessageToClient(client, notice)
+			}
+		}
+	}
+	return redaction, nil
+}
+
+// releaseSubmission clears the mark of the user's submission in roundID, if it is the active
+// round, and their entry in the ledger.
+func (h *Hub) releaseSubmission(roundID int64, username string) {
+	if !h.inRound(roundID, func() { h.limiter.Load().clear(h.usernameKey(username)) }) {
+		return
+	}
+	if h.submissions != nil {
+		if err := h.submissions.release(context.Background(), roundID, h.usernameKey(username)); err != nil {
+			h.Logger.Errorf("Failed to release removed submission: %v", err)
+		}
+	}
+}
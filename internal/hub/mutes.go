@@ -0,0 +1,315 @@
+// internal/hub/mutes.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// MutedCode is the error code for submissions from muted users.
+const MutedCode = "MUTED"
+
+const (
+	mutesBucket       = "MUTES"
+	maxMuteDuration   = 7 * 24 * time.Hour // bounds a single mute
+	muteSweepInterval = time.Second        // how often expired mutes are lifted
+)
+
+// ErrMuteDuration is returned for a mute shorter than a second or longer than maxMuteDuration.
+var ErrMuteDuration = fmt.Errorf("mute duration must be between 1 second and %s", maxMuteDuration)
+
+// Mute stops a user from submitting until it expires. Unlike a ban, muted users stay
+// connected and keep receiving broadcasts.
+type Mute struct {
+	Username string    `json:"username"`
+	Reason   string    `json:"reason,omitempty"`
+	MutedBy  string    `json:"muted_by"`
+	MutedAt  time.Time `json:"muted_at"`
+	Until    time.Time `json:"until"`
+}
+
+// muteStore keeps the mutes of a hub in memory and, for the main hub with JetStream,
+// in a key-value bucket so they survive restarts. Mutes are keyed by the usernameKey of
+// the muted user, so a mute covers every case variant of a case-insensitive name.
+type muteStore struct {
+	kv nats.KeyValue // nil for room hubs and without JetStream
+
+	mu    sync.Mutex
+	mutes map[string]Mute
+}
+
+func newMemoryMuteStore() *muteStore {
+	return &muteStore{mutes: make(map[string]Mute)}
+}
+
+// newMuteStore opens the bucket, creating it if needed, and loads the mutes that have
+// not expired by now under the usernameKey nameKey returns. Without JetStream mutes are kept in
+// memory only.
+func newMuteStore(js nats.JetStreamContext, bucket string, now time.Time, nameKey func(string) string, logger *logger.Logger) *muteStore {
+	store := newMemoryMuteStore()
+	if js == nil {
+		return store
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Muted users until their mute expires",
+			TTL:         maxMuteDuration, // mutes that expired while no instance ran age out
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening mutes bucket, mutes will be lost on restart: %v", err)
+		return store
+	}
+	store.kv = kv
+	if loaded, err := store.load(now, nameKey); err != nil {
+		logger.Errorf("Error loading mutes: %v", err)
+	} else if loaded > 0 {
+		logger.Infof("Loaded %d active mutes", loaded)
+	}
+	return store
+}
+
+// load reads the unexpired mutes from the bucket into memory, keyed by nameKey.
+func (s *muteStore) load(now time.Time, nameKey func(string) string) (int, error) {
+	keys, err := s.kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("listing mutes: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		entry, err := s.kv.Get(key)
+		if err != nil {
+			continue // deleted since listing
+		}
+		var mute Mute
+		if err := json.Unmarshal(entry.Value(), &mute); err != nil || !now.Before(mute.Until) {
+			continue
+		}
+		s.mutes[nameKey(mute.Username)] = mute
+	}
+	return len(s.mutes), nil
+}
+
+// put stores a mute of the user with the given usernameKey, replacing any earlier one,
+// and with persist writes it to the bucket.
+func (s *muteStore) put(name string, mute Mute, persist bool) error {
+	s.mu.Lock()
+	s.mutes[name] = mute
+	s.mu.Unlock()
+	if !persist || s.kv == nil {
+		return nil
+	}
+	data, err := json.Marshal(mute)
+	if err != nil {
+		return err
+	}
+	if _, err := s.kv.Put(userKey(name), data); err != nil {
+		return fmt.Errorf("storing mute of %s: %w", mute.Username, err)
+	}
+	return nil
+}
+
+// remove lifts the mute of the user with the given usernameKey and reports whether
+// there was one.
+func (s *muteStore) remove(name string) (Mute, bool, error) {
+	s.mu.Lock()
+	mute, ok := s.mutes[name]
+	delete(s.mutes, name)
+	s.mu.Unlock()
+	return mute, ok, s.forget(name)
+}
+
+// forget deletes a mute from the bucket. Every instance lifting a mute deletes it, so
+// it is gone even if the instance that applied it stopped.
+func (s *muteStore) forget(name string) error {
+	if s.kv == nil {
+		return nil
+	}
+	if err := s.kv.Delete(userKey(name)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return fmt.Errorf("deleting mute of %s: %w", name, err)
+	}
+	return nil
+}
+
+// get returns the mute of the user with the given usernameKey if it has not expired
+// by now.
+func (s *muteStore) get(name string, now time.Time) (Mute, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mute, ok := s.mutes[name]
+	if !ok || !now.Before(mute.Until) {
+		return Mute{}, false
+	}
+	return mute, true
+}
+
+// list returns the mutes that have not expired by now, ordered by username.
+func (s *muteStore) list(now time.Time) []Mute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mutes := make([]Mute, 0, len(s.mutes))
+	for _, mute := range s.mutes {
+		if now.Before(mute.Until) {
+			mutes = append(mutes, mute)
+		}
+	}
+	sort.Slice(mutes, func(i, j int) bool { return mutes[i].Username < mutes[j].Username })
+	return mutes
+}
+
+// expire removes the mutes that expired by now from memory and returns them by
+// usernameKey.
+func (s *muteStore) expire(now time.Time) map[string]Mute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := make(map[string]Mute)
+	for name, mute := range s.mutes {
+		if !now.Before(mute.Until) {
+			expired[name] = mute
+			delete(s.mutes, name)
+		}
+	}
+	return expired
+}
+
+// NewMute validates a mute of username for duration before it is applied.
+func (h *Hub) NewMute(username, reason string, duration time.Duration, actor string) (Mute, error) {
+	if duration < time.Second || duration > maxMuteDuration {
+		return Mute{}, ErrMuteDuration
+	}
+	now := h.clock.Now().UTC()
+	return Mute{Username: username, Reason: reason, MutedBy: actor, MutedAt: now, Until: now.Add(duration)}, nil
+}
+
+// MuteUser mutes username for duration, replacing any earlier mute. The user stays
+// connected but gets MutedCode errors for submissions and edits until the mute expires.
+func (h *Hub) MuteUser(username, reason string, duration time.Duration, actor string) (Mute, error) {
+	mute, err := h.NewMute(username, reason, duration, actor)
+	if err != nil {
+		return Mute{}, err
+	}
+	h.ApplyMute(mute)
+	return mute, nil
+}
+
+// ApplyMute stores a mute made with NewMute, records it in the audit stream and tells
+// the user's clients.
+func (h *Hub) ApplyMute(mute Mute) {
+	if err := h.mutes.put(h.usernameKey(mute.Username), mute, true); err != nil {
+		h.Logger.Errorf("Mute will be lost on restart: %v", err)
+	}
+	h.notifyMute(mute.Username, &mute)
+	until := mute.Until.UTC().Format(time.RFC3339)
+	h.Audit(AuditAdminAction, mute.Username, "Muted by "+mute.MutedBy, fmt.Sprintf("until %s: %s", until, mute.Reason))
+	h.Logger.Infof("Muted %s until %s: %s", mute.Username, until, mute.Reason)
+}
+
+// holdMute stores a mute another instance applied, which persisted and recorded it.
+func (h *Hub) holdMute(mute Mute) {
+	h.mutes.put(h.usernameKey(mute.Username), mute, false)
+	h.notifyMute(mute.Username, &mute)
+}
+
+// UnmuteUser lifts the mute of username before it expires and reports whether there
+// was one.
+func (h *Hub) UnmuteUser(username, actor string) bool {
+	_, ok, err := h.mutes.remove(h.usernameKey(username))
+	if err != nil {
+		h.Logger.Errorf("Mute may return on restart: %v", err)
+	}
+	if !ok {
+		return false
+	}
+	h.notifyMute(username, nil)
+	h.Audit(AuditAdminAction, username, "Unmuted by "+actor, "")
+	h.Logger.Infof("Unmuted %s", username)
+	return true
+}
+
+// Mutes lists the active mutes ordered by username.
+func (h *Hub) Mutes() []Mute {
+	return h.mutes.list(h.clock.Now())
+}
+
+// mutedUntil returns when the mute of username expires, if it is muted. In a room the
+// main hub's mutes apply as well as the room's own.
+func (h *Hub) mutedUntil(username string) (time.Time, bool) {
+	now := h.clock.Now()
+	mute, muted := h.mutes.get(h.usernameKey(username), now)
+	if h.parent != nil {
+		if server, ok := h.parent.mutes.get(h.parent.usernameKey(username), now); ok && (!muted || server.Until.After(mute.Until)) {
+			mute, muted = server, true
+		}
+	}
+	return mute.Until, muted
+}
+
+// checkMuted answers a muted client's submission with MutedCode and reports whether it
+// was muted.
+func (h *Hub) checkMuted(client *Client) bool {
+	until, muted := h.mutedUntil(client.Username())
+	if muted {
+		h.SendErrorCode(client, MutedCode, "You are muted until "+until.UTC().Format(time.RFC3339))
+	}
+	return muted
+}
+
+// notifyMute sends a mute_update to the connected clients of username, in the main
+// hub's rooms too: the mute, or nil once it is lifted.
+func (h *Hub) notifyMute(username string, mute *Mute) {
+	update := map[string]interface{}{
+		"version": "1.0",
+		"type":    "mute_update",
+		"muted":   mute != nil,
+	}
+	if mute != nil {
+		update["until"] = mute.Until.UTC().Format(time.RFC3339)
+		if mute.Reason != "" {
+			update["reason"] = mute.Reason
+		}
+	}
+	for _, hub := range append([]*Hub{h}, h.roomHubs()...) {
+		for _, client := range hub.clients.snapshot() {
+			if hub.sameUsername(client.Username(), username) {
+				hub.sendMessageToClient(client, update)
+			}
+		}
+	}
+}
+
+// runMuteExpiry lifts mutes as they expire, telling the users' clients, until ctx is
+// canceled.
+func (h *Hub) runMuteExpiry(ctx context.Context) {
+	ticker := h.clock.NewTicker(muteSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			for name, mute := range h.mutes.expire(h.clock.Now()) {
+				if err := h.mutes.forget(name); err != nil {
+					h.Logger.Errorf("Failed to delete expired mute: %v", err)
+				}
+				h.notifyMute(mute.Username, nil)
+				h.Logger.Infof("Mute of %s expired", mute.Username)
+			}
+		}
+	}
+}
@@ -4,49 +4,99 @@ package hub
 import (
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"time"
+
+	"github.com/erilali/internal/attachments"
+	"github.com/erilali/internal/message"
 )
 
-// publishMessageToNATS serializes client message data (username, content, timestamp, round_id)
-// into JSON and publishes it to a NATS JetStream subject.
-// The subject is dynamically created based on the current round ID (e.g., "messages.ROUND_ID").
-// Errors during marshaling or publishing are logged.
-func (h *Hub) publishMessageToNATS(client *Client, content string) {
-	if h.NatsConn != nil && h.Js != nil {
+// Actions recorded for submissions on the messages subject.
+const (
+	messageActionSubmit   = "submit"
+	messageActionEdit     = "edit"
+	messageActionWithdraw = "withdraw"
+	messageActionRedact   = "redact"
+)
+
+// publishMessageToNATS serializes a submission event (id, action, username, content, payload, timestamp, round_id)
+// into JSON and publishes it to an event bus subject. The payload holds the structured submission;
+// content repeats its text for consumers that predate structured submissions.
+// The subject is dynamically created based on the round ID (e.g., "messages.ROUND_ID").
+// Original submissions are published with their message ID so the bus can drop duplicates.
+// Publishing happens in the background through the publish queue so bus latency does not
+// block the client; marshaling errors are logged here, publish errors by the queue.
+func (h *Hub) publishMessageToNATS(roundID int64, action string, msg RoundMessage) {
+	if h.Bus != nil {
 		messageData := map[string]any{
-			"username":  client.Username,
-			"content":   content,
-			"timestamp": time.Now().Unix(),
-			"round_id":  h.CurrentRoundID,
+			"id":       msg.ID,
+			"action":   action,
+			"username": msg.Username,
+			"content":  msg.Message,
+			"payload": message.Submission{
+				Text:         msg.Message,
+				Lang:         msg.Lang,
+				AttachmentID: msg.AttachmentID,
+				Choice:       msg.Choice,
+			},
+			"timestamp": h.clock.Now().Unix(),
+			"round_id":  roundID,
+		}
+		if msg.Bot {
+			messageData["bot"] = true
 		}
 
-		subject := fmt.Sprintf("messages.%d", h.CurrentRoundID)
+		subject := fmt.Sprintf("messages.%d", roundID)
 		if data, err := json.Marshal(messageData); err == nil {
-			if _, err := h.Js.Publish(subject, data); err != nil {
-				h.Logger.Errorf("Failed to publish message to NATS: %v", err)
+			id := ""
+			if action == messageActionSubmit {
+				id = msg.ID
 			}
+			h.publisher.enqueue(subject, id, data)
 		} else {
 			h.Logger.Errorf("Failed to marshal message data: %v", err)
 		}
 	}
 }
 
-// publishRoundStartToNATS serializes round start event data (round_id, timestamp, status)
-// into JSON and publishes it to a NATS JetStream subject.
+// publishRedactionToNATS records a moderator removal on the round's messages subject so
+// history readers drop the submission. It goes through the publish queue behind the
+// submission it removes, and waits for room in a full queue rather than being dropped.
+func (h *Hub) publishRedactionToNATS(redaction Redaction) {
+	if h.Bus != nil {
+		redactionData := map[string]any{
+			"id":         redaction.MessageID,
+			"action":     messageActionRedact,
+			"username":   redaction.Username,
+			"reason":     redaction.Reason,
+			"removed_by": redaction.RemovedBy,
+			"timestamp":  redaction.RemovedAt.Unix(),
+			"round_id":   redaction.RoundID,
+		}
+		subject := fmt.Sprintf("messages.%d", redaction.RoundID)
+		if data, err := json.Marshal(redactionData); err == nil {
+			h.publisher.enqueueWait(h.context(), subject, data)
+		} else {
+			h.Logger.Errorf("Failed to marshal redaction data: %v", err)
+		}
+	}
+}
+
+// publishRoundStartToNATS serializes round start event data (round_id, timestamp, status and the
+// started_at, submission_deadline and ends_at deadlines) into JSON and publishes it to an event bus subject.
 // The subject is dynamically created based on the current round ID (e.g., "rounds.started.ROUND_ID").
 // Errors during marshaling or publishing are logged.
-func (h *Hub) publishRoundStartToNATS() {
-	if h.NatsConn != nil && h.Js != nil {
+func (h *Hub) publishRoundStartToNATS(timing roundTiming) {
+	if h.Bus != nil {
 		subject := fmt.Sprintf("rounds.started.%d", h.CurrentRoundID)
 		roundData := map[string]any{
 			"round_id":  h.CurrentRoundID,
-			"timestamp": time.Now().Unix(),
-			"status":    "started",
+			"timestamp": h.clock.Now().Unix(),
+			"status":    roundStatusStarted,
 		}
+		timing.addTo(roundData)
 		if data, err := json.Marshal(roundData); err == nil {
-			if _, err := h.Js.Publish(subject, data); err != nil {
-				h.Logger.Errorf("Failed to publish round start to NATS: %v", err)
+			if err := h.Bus.Publish(subject, data); err != nil {
+				h.Logger.Errorf("Failed to publish round start to event bus: %v", err)
 			}
 		} else {
 			h.Logger.Errorf("Failed to marshal round start data: %v", err)
@@ -55,20 +105,21 @@ func (h *Hub) publishRoundStartToNATS() {
 }
 
 // publishRoundEndToNATS serializes round end event data (round_id, timestamp, status)
-// into JSON and publishes it to a NATS JetStream subject.
+// into JSON and publishes it to an event bus subject. The status is "ended", "empty"
+// for rounds that had no submissions, or "void" for rounds short of min_participants.
 // The subject is dynamically created based on the provided round ID (e.g., "rounds.ended.ROUND_ID").
 // Errors during marshaling or publishing are logged.
-func (h *Hub) publishRoundEndToNATS(roundID int64) {
-	if h.NatsConn != nil && h.Js != nil {
+func (h *Hub) publishRoundEndToNATS(roundID int64, status string) {
+	if h.Bus != nil {
 		subject := fmt.Sprintf("rounds.ended.%d", roundID)
 		roundData := map[string]any{
 			"round_id":  roundID,
-			"timestamp": time.Now().Unix(),
-			"status":    "ended",
+			"timestamp": h.clock.Now().Unix(),
+			"status":    status,
 		}
 		if data, err := json.Marshal(roundData); err == nil {
-			if _, err := h.Js.Publish(subject, data); err != nil {
-				h.Logger.Errorf("Failed to publish round end to NATS: %v", err)
+			if err := h.Bus.Publish(subject, data); err != nil {
+				h.Logger.Errorf("Failed to publish round end to event bus: %v", err)
 			}
 		} else {
 			h.Logger.Errorf("Failed to marshal round end data: %v", err)
@@ -79,34 +130,63 @@ func (h *Hub) publishRoundEndToNATS(roundID int64) {
 // SelectWinner selects and announces a winner from the round messages.
 func (h *Hub) SelectWinner(roundID int64) {
 	// Wait a moment for any final messages to be processed
-	time.Sleep(500 * time.Millisecond)
+	h.clock.Sleep(500 * time.Millisecond)
 
-	h.Mu.Lock()
-	messages, exists := h.RoundMessages[roundID]
-	if !exists || len(messages) == 0 {
-		h.Mu.Unlock()
-		h.Logger.Infof("No messages found for round %d, no winner selected", roundID)
+	messages := h.rounds.messages(roundID)
+	candidates := h.eligibleWinners(messages)
+	eligible := len(candidates)
+	// In choices mode only submissions of the correct or most popular option can win.
+	var tally *message.ChoiceTally
+	if choices := h.roundChoices(roundID); choices != nil {
+		tally = tallyChoices(choices, messages)
+		candidates = winningChoices(tally, candidates)
+	}
+	candidates, excluded := h.applyWinnerCooldown(candidates)
+	candidates = h.uniqueEntries(candidates)
+	if len(candidates) == 0 {
+		h.recordRoundSummary(summarizeRound(roundID, messages, "", h.clock.Now()))
+		h.rememberRound(roundID, messages, nil, nil, nil)
+		h.tournamentRoundWon(roundID, "")
+		h.recordCooldown(roundID, "")
+		h.Logger.Infof("No eligible messages found for round %d, no winner selected", roundID)
 
 		// Send "no winner" message
+		reason := "No messages submitted this round"
+		if eligible > 0 {
+			reason = "No eligible submission this round picked a winning option"
+		} else if len(messages) > 0 {
+			reason = "No submission this round was eligible to win"
+		}
 		noWinnerMessage := map[string]interface{}{
 			"version":        "1.0",
 			"type":           "winner_announcement",
 			"round_id":       roundID,
 			"winner":         nil,
-			"total_messages": 0,
-			"message":        "No messages submitted this round",
+			"total_messages": len(messages),
+			"message":        reason,
+		}
+		if tally != nil {
+			noWinnerMessage["choices"] = tally
 		}
 		h.BroadcastMessage(noWinnerMessage)
+		h.seriesRoundWon(roundID, "")
 		return
 	}
 
-	// Select random winner
-	winnerIndex := rand.Intn(len(messages))
-	winner := messages[winnerIndex]
+	// Select the winner among the eligible submissions
+	scores := h.scoreSubmissions(roundID, messages)
+	winner, odds := h.pickWinner(roundID, candidates, scores)
 	totalMessages := len(messages)
-	h.Mu.Unlock()
+	summary := summarizeRound(roundID, messages, winner.Username, h.clock.Now())
+	summary.CooldownExcluded = excluded
+	h.recordRoundSummary(summary)
+	h.rememberRound(roundID, messages, &winner, scores, newRoundOdds(roundID, messages, odds, &winner, h.clock.Now()))
+	h.rememberResult(roundID, &winner)
+	h.rememberDraw(roundID, candidates, winner, scores)
+	h.tournamentRoundWon(roundID, winner.Username)
+	h.recordCooldown(roundID, winner.Username)
 
-	h.Logger.Infof("Selected winner for round %d: %s with message: %s", roundID, winner.Username, winner.Message)
+	h.Logger.Infof("Selected winner for round %d: %s with message: %s", roundID, winner.Username, h.loggable(winner.Message))
 
 	// Create winner announcement
 	announcement := map[string]interface{}{
@@ -116,42 +196,107 @@ func (h *Hub) SelectWinner(roundID int64) {
 		"winner":         winner,
 		"total_messages": totalMessages,
 	}
+	if winner.AttachmentID != "" {
+		announcement["attachment_url"] = attachments.URL(winner.AttachmentID)
+	}
+	if tally != nil {
+		announcement["choices"] = tally
+	}
 
-	// Broadcast winner announcement
+	// Broadcast winner announcement and open the reveal phase for reactions
 	h.BroadcastMessage(announcement)
+	h.seriesRoundWon(roundID, winner.Username)
+	h.startReveal(roundID)
 
 	// Publish winner to NATS
 	winnerData := map[string]interface{}{
+		"id":        winner.ID,
 		"username":  winner.Username,
 		"content":   winner.Message,
 		"timestamp": winner.Timestamp.Unix(),
 	}
+	if winner.AttachmentID != "" {
+		winnerData["attachment_url"] = attachments.URL(winner.AttachmentID)
+	}
+	if winner.Bot {
+		winnerData["bot"] = true
+	}
 	h.publishWinnerToNATS(roundID, winnerData)
 
+	// Hand out the winner's reward
+	points := h.grantReward(winner.Username, roundID)
+	h.recordWin(winner.Username)
+	h.notifyWinner(roundID, winner, points, h.advanceStreak(winner.Username), false)
+
 	// Clean up old round messages (keep only last 3 rounds)
 	h.cleanupOldMessages(roundID)
 }
 
 // publishWinnerToNATS serializes winner data (round_id, username, content, timestamp)
-// into JSON and publishes it to a NATS JetStream subject.
+// into JSON and publishes it to an event bus subject.
 // The subject is dynamically created based on the round ID (e.g., "winners.ROUND_ID").
 // Errors during marshaling or publishing are logged.
 func (h *Hub) publishWinnerToNATS(roundID int64, messageData map[string]interface{}) {
-	if h.NatsConn != nil && h.Js != nil {
+	if h.Bus != nil {
 		winnerData := map[string]any{
-			"round_id":  roundID,
-			"username":  messageData["username"],
-			"content":   messageData["content"],
-			"timestamp": time.Now().Unix(),
+			"round_id":   roundID,
+			"message_id": messageData["id"],
+			"username":   messageData["username"],
+			"content":    messageData["content"],
+			"timestamp":  h.clock.Now().Unix(),
+		}
+		if url, ok := messageData["attachment_url"]; ok {
+			winnerData["attachment_url"] = url
+		}
+		if bot, ok := messageData["bot"]; ok {
+			winnerData["bot"] = bot
 		}
 
 		winnerSubject := fmt.Sprintf("winners.%d", roundID)
 		if data, err := json.Marshal(winnerData); err == nil {
-			if _, err := h.Js.Publish(winnerSubject, data); err != nil {
-				h.Logger.Errorf("Failed to publish winner to NATS: %v", err)
+			if err := h.Bus.Publish(winnerSubject, data); err != nil {
+				h.Logger.Errorf("Failed to publish winner to event bus: %v", err)
 			}
 		} else {
 			h.Logger.Errorf("Failed to marshal winner data: %v", err)
 		}
 	}
 }
+
+// publishWinnerCorrectionToNATS records an invalidated winner on the round's winners
+// subject. The record names the new winner, or an empty username when none remained,
+// and supersedes the message ID of the invalidated one; readers use the latest record.
+func (h *Hub) publishWinnerCorrectionToNATS(correction WinnerCorrection) {
+	if h.Bus != nil {
+		winnerData := map[string]any{
+			"round_id":       correction.RoundID,
+			"message_id":     "",
+			"username":       "",
+			"content":        "",
+			"timestamp":      correction.InvalidatedAt.Unix(),
+			"supersedes":     correction.Supersedes,
+			"reason":         correction.Reason,
+			"invalidated_by": correction.InvalidatedBy,
+		}
+		if winner := correction.Winner; winner != nil {
+			winnerData["message_id"] = winner.ID
+			winnerData["username"] = winner.Username
+			winnerData["content"] = winner.Message
+			if winner.AttachmentID != "" {
+				winnerData["attachment_url"] = attachments.URL(winner.AttachmentID)
+			}
+			if winner.Bot {
+				winnerData["bot"] = true
+			}
+		}
+
+		winnerSubject := fmt.Sprintf("winners.%d", correction.RoundID)
+		if data, err := json.Marshal(winnerData); err == nil {
+			if err := h.Bus.Publish(winnerSubject, data); err != nil {
+				h.Logger.Errorf("Failed to publish winner correction to event bus: %v", err)
+			}
+		} else {
+			h.Logger.Errorf("Failed to marshal winner correction: %v", err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// internal/hub/nudges.go
+package hub
+
+import (
+	"fmt"
+	"time"
+)
+
+// scheduleNudge arms the nudge of a round at nudge_at_percent of its submission window.
+func (h *Hub) scheduleNudge(roundID int64, window time.Duration) {
+	percent := h.settings().NudgeAtPercent
+	if percent <= 0 || percent >= 100 {
+		return
+	}
+	h.clock.AfterFunc(window*time.Duration(percent)/100, func() { h.nudgeUnsubmitted(roundID) })
+}
+
+// nudgeUnsubmitted reminds the connected clients that could still submit to roundID but
+// have not. Clients opt out by excluding "nudge" with a subscribe message. The limiter and
+// client registry are read from snapshots, so no lock is held while the nudges are sent.
+func (h *Hub) nudgeUnsubmitted(roundID int64) {
+	round := h.submissionState()
+	if !round.open || round.roundID != roundID {
+		return
+	}
+	h.Mu.RLock()
+	closeIn := max(h.submissionsCloseAt.Sub(h.clock.Now()), 0)
+	h.Mu.RUnlock()
+
+	guestsCanSubmit := h.settings().GuestsCanSubmit
+	var targets []*Client
+	for _, client := range h.clients.snapshot() {
+		username := client.Username()
+		switch {
+		case client.Bot(), !client.Accepts("nudge"):
+		case client.Guest() && !guestsCanSubmit:
+		case round.limiter.has(h.usernameKey(username)):
+		case !h.tournamentEligible(username, roundID):
+		default:
+			targets = append(targets, client)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	nudge := map[string]interface{}{
+		"version":                 "1.0",
+		"type":                    "nudge",
+		"round_id":                roundID,
+		"submissions_close_in_ms": closeIn.Milliseconds(),
+		"message":                 fmt.Sprintf("Submissions close in %d seconds, you have not submitted yet", int(closeIn.Round(time.Second).Seconds())),
+	}
+	for _, client := range targets {
+		h.sendMessageToClient(client, nudge)
+	}
+	h.Logger.Debugf("Nudged %d clients that have not submitted to round %d", len(targets), roundID)
+}
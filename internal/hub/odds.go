@@ -0,0 +1,168 @@
+// internal/hub/odds.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Winner draw strategies reported with the odds of a round.
+const (
+	OddsUniform  = "uniform"   // every candidate equally likely
+	OddsWeighted = "weighted"  // odds proportional to the winner_scoring scores
+	OddsRulesTop = "rules_top" // the best score of the rules script wins, ties at random
+)
+
+// ErrOddsNotFound is returned by RoundOdds for rounds without a recorded draw.
+var ErrOddsNotFound = errors.New("no winner draw recorded for this round")
+
+// EntryOdds is the chance one submission of a round had to be drawn.
+type EntryOdds struct {
+	MessageID   string   `json:"message_id"`
+	Username    string   `json:"username"`
+	Candidate   bool     `json:"candidate"`       // took part in the draw; ineligible, losing-option and grouped duplicate entries did not
+	Score       *float64 `json:"score,omitempty"` // the score the odds follow, unless uniform
+	Probability float64  `json:"probability"`
+}
+
+// RoundOdds records how the winner of a round was drawn, for fairness audits. It is
+// computed when the winner is selected, again when an appeal redraws, and archived
+// with the round.
+type RoundOdds struct {
+	RoundID    int64       `json:"round_id"`
+	Strategy   string      `json:"strategy"`            // empty when an appeal left no candidate to draw
+	WinnerID   string      `json:"winner_id,omitempty"` // empty when no candidate remained
+	Entries    []EntryOdds `json:"entries"`
+	SelectedAt time.Time   `json:"selected_at"`
+}
+
+// drawOdds is how a draw among candidates picks its winner.
+type drawOdds struct {
+	strategy string
+	odds     map[string]float64 // probability by message ID, candidates only
+	scores   map[string]float64 // what the odds follow by message ID, nil for uniform draws
+}
+
+// winnerOdds returns the odds of a draw among candidates. scores are the winner_scoring
+// scores, nil when it is disabled.
+func (h *Hub) winnerOdds(roundID int64, candidates []RoundMessage, scores map[string]float64) drawOdds {
+	odds := make(map[string]float64, len(candidates))
+	uniform := func() drawOdds {
+		for _, msg := range candidates {
+			odds[msg.ID] = 1 / float64(len(candidates))
+		}
+		return drawOdds{strategy: OddsUniform, odds: odds}
+	}
+	if scores != nil {
+		total := 0.0
+		for _, msg := range candidates {
+			total += scores[msg.ID]
+		}
+		if total <= 0 {
+			return uniform()
+		}
+		for _, msg := range candidates {
+			odds[msg.ID] = scores[msg.ID] / total
+		}
+		return drawOdds{strategy: OddsWeighted, odds: odds, scores: scores}
+	}
+	if h.Rules != nil {
+		ruleScores, err := h.Rules.Score(roundID, candidates)
+		if err != nil {
+			h.Logger.Errorf("Rules script failed to score round %d, selecting at random: %v", roundID, err)
+		} else if ruleScores != nil {
+			byID := make(map[string]float64, len(candidates))
+			var best []int
+			for i, score := range ruleScores {
+				byID[candidates[i].ID] = score
+				switch {
+				case len(best) == 0 || score > ruleScores[best[0]]:
+					best = []int{i}
+				case score == ruleScores[best[0]]:
+					best = append(best, i)
+				}
+			}
+			for _, i := range best {
+				odds[candidates[i].ID] = 1 / float64(len(best))
+			}
+			return drawOdds{strategy: OddsRulesTop, odds: odds, scores: byID}
+		}
+	}
+	return uniform()
+}
+
+// draw picks a candidate with its probability.
+func (h *Hub) draw(candidates []RoundMessage, odds drawOdds) RoundMessage {
+	target := h.rng.Float64()
+	for _, msg := range candidates {
+		target -= odds.odds[msg.ID]
+		if target < 0 {
+			return msg
+		}
+	}
+	// Rounding can leave a sliver past the last candidate with a chance.
+	for i := len(candidates) - 1; i > 0; i-- {
+		if odds.odds[candidates[i].ID] > 0 {
+			return candidates[i]
+		}
+	}
+	return candidates[0]
+}
+
+// newRoundOdds lists every submission of a round with the odds it was drawn with; those
+// missing from odds were not candidates.
+func newRoundOdds(roundID int64, messages []RoundMessage, draw drawOdds, winner *RoundMessage, selectedAt time.Time) *RoundOdds {
+	record := &RoundOdds{
+		RoundID:    roundID,
+		Strategy:   draw.strategy,
+		Entries:    make([]EntryOdds, 0, len(messages)),
+		SelectedAt: selectedAt,
+	}
+	if winner != nil {
+		record.WinnerID = winner.ID
+	}
+	for _, msg := range messages {
+		probability, candidate := draw.odds[msg.ID]
+		entry := EntryOdds{MessageID: msg.ID, Username: msg.Username, Candidate: candidate, Probability: probability}
+		if score, ok := draw.scores[msg.ID]; ok && candidate {
+			entry.Score = &score
+		}
+		record.Entries = append(record.Entries, entry)
+	}
+	return record
+}
+
+// eraseUser anonymizes the entries of the users match accepts.
+func (o *RoundOdds) eraseUser(match func(username string) bool) bool {
+	changed := false
+	for i := range o.Entries {
+		if match(o.Entries[i].Username) {
+			o.Entries[i].Username = ErasedUsername
+			changed = true
+		}
+	}
+	return changed
+}
+
+// RoundOdds returns the odds a round's winner was drawn with, from the rounds held in
+// memory or else the round's archive.
+func (h *Hub) RoundOdds(ctx context.Context, roundID int64) (RoundOdds, error) {
+	if round, ok := h.recent.get(roundID); ok && round.Odds != nil {
+		return *round.Odds, nil
+	}
+	if h.archiver == nil {
+		return RoundOdds{}, ErrOddsNotFound
+	}
+	data, err := h.archiver.store.Get(ctx, h.archiver.objectKey(h.room, roundID))
+	if err != nil {
+		h.Logger.Debugf("Reading archive of round %d for its odds: %v", roundID, err)
+		return RoundOdds{}, ErrOddsNotFound
+	}
+	var record RoundArchive
+	if err := json.Unmarshal(data, &record); err != nil || record.Odds == nil {
+		return RoundOdds{}, ErrOddsNotFound
+	}
+	return *record.Odds, nil
+}
@@ -0,0 +1,106 @@
+// internal/hub/participants.go
+package hub
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// participantRoster remembers the usernames last announced to clients, so roster
+// changes are broadcast as differences at most once per participants_interval_ms.
+type participantRoster struct {
+	dirty atomic.Bool // a client registered, left or signed in since the last broadcast
+
+	mu        sync.Mutex
+	announced []string // sorted
+}
+
+// rosterChanged marks the roster for the next participant broadcast.
+func (h *Hub) rosterChanged() {
+	h.roster.dirty.Store(true)
+}
+
+// participantNames returns the sorted usernames of the connected clients, each once.
+// Clients in the waiting room are not participants.
+func (h *Hub) participantNames() []string {
+	clients := h.clients.snapshot()
+	names := make([]string, 0, len(clients))
+	for _, client := range clients {
+		names = append(names, client.Username())
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// rosterDiff returns the names in current but not in previous, and the other way round.
+// Both lists must be sorted.
+func rosterDiff(previous, current []string) (joined, left []string) {
+	i, j := 0, 0
+	for i < len(previous) || j < len(current) {
+		switch {
+		case j == len(current) || (i < len(previous) && previous[i] < current[j]):
+			left = append(left, previous[i])
+			i++
+		case i == len(previous) || current[j] < previous[i]:
+			joined = append(joined, current[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return joined, left
+}
+
+// runParticipantBroadcaster broadcasts "user_joined" and "user_left" with the usernames
+// that joined or left since the previous broadcast, so a reconnect within the interval
+// or a burst of joins in a large room costs at most one message of each type.
+func (h *Hub) runParticipantBroadcaster(ctx context.Context) {
+	interval := h.settings().ParticipantsIntervalMs
+	if interval <= 0 {
+		return
+	}
+	ticker := h.clock.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		if !h.roster.dirty.Swap(false) {
+			continue
+		}
+		current := h.participantNames()
+		h.roster.mu.Lock()
+		joined, left := rosterDiff(h.roster.announced, current)
+		h.roster.announced = current
+		h.roster.mu.Unlock()
+
+		if len(left) > 0 {
+			h.BroadcastMessage(participantsMessage("user_left", left, len(current)))
+		}
+		if len(joined) > 0 {
+			h.BroadcastMessage(participantsMessage("user_joined", joined, len(current)))
+		}
+	}
+}
+
+// handleParticipants answers a "participants" request with every connected username.
+func (h *Hub) handleParticipants(client *Client) {
+	names := h.participantNames()
+	h.sendMessageToClient(client, participantsMessage("participants", names, len(names)))
+}
+
+func participantsMessage(messageType string, names []string, count int) map[string]interface{} {
+	return map[string]interface{}{
+		"version": "1.0",
+		"type":    messageType,
+		"data":    names,
+		"count":   count,
+	}
+}
@@ -0,0 +1,184 @@
+// internal/hub/pinger.go
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+)
+
+// Connection qualities reported in connection_quality messages and the admin client list.
+const (
+	QualityGood     = "good"     // pongs arrive within slow_pong_ms
+	QualityDegraded = "degraded" // the last pong was slower
+)
+
+// pongGrace is how long after the next ping is due a pong may still arrive before the
+// read deadline of an adaptively pinged connection expires.
+const pongGrace = webSocketWriteDeadline
+
+// pinger paces the WebSocket control pings of one client and measures their round trip.
+// With adaptive_ping a slow pong halves the interval, down to ping_min_seconds, so a dead
+// connection is noticed sooner; stable_pongs_to_grow fast pongs in a row grow it by half,
+// up to ping_max_seconds, to cut overhead. The read deadline follows the interval, and
+// resized tells the write pump to restart its ping ticker with it.
+type pinger struct {
+	mu       sync.Mutex
+	adaptive bool
+	interval time.Duration
+	sentAt   time.Time     // when the unanswered ping was sent, zero once answered
+	rtt      time.Duration // round trip of the last answered ping
+	fast     int           // consecutive pongs within slow_pong_ms
+	quality  string
+	resized  chan struct{}
+}
+
+func newPinger(cfg config.Config) *pinger {
+	p := &pinger{adaptive: cfg.AdaptivePing, interval: webSocketPingPeriod, quality: QualityGood, resized: make(chan struct{}, 1)}
+	if p.adaptive {
+		_, p.interval = pingBounds(cfg)
+	}
+	return p
+}
+
+// pingBounds returns the range adaptive ping intervals are kept in. Unset or inverted
+// bounds fall back to the fixed ping period.
+func pingBounds(cfg config.Config) (time.Duration, time.Duration) {
+	minInterval := time.Duration(cfg.PingMinSeconds) * time.Second
+	maxInterval := time.Duration(cfg.PingMaxSeconds) * time.Second
+	if maxInterval <= 0 {
+		maxInterval = webSocketPingPeriod
+	}
+	if minInterval <= 0 || minInterval > maxInterval {
+		minInterval = maxInterval
+	}
+	return minInterval, maxInterval
+}
+
+// period returns how long to wait before the next ping.
+func (p *pinger) period() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+// readDeadline returns how long the connection may stay silent before it is considered dead.
+func (p *pinger) readDeadline() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.adaptive {
+		return webSocketReadDeadline
+	}
+	return p.interval + pongGrace
+}
+
+// sent records a ping. While an earlier ping is unanswered its time is kept, so a late
+// pong counts the whole wait.
+func (p *pinger) sent(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sentAt.IsZero() {
+		p.sentAt = now
+	}
+}
+
+// ponged records the pong answering the last ping and adapts the interval, signaling
+// resized when it changed. It reports whether the connection quality changed.
+// Unsolicited pongs are ignored.
+func (p *pinger) ponged(now time.Time, cfg config.Config) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sentAt.IsZero() {
+		return false
+	}
+	p.rtt = now.Sub(p.sentAt)
+	p.sentAt = time.Time{}
+
+	minInterval, maxInterval := pingBounds(cfg)
+	previous, previousInterval := p.quality, p.interval
+	slow := cfg.SlowPongMs > 0 && p.rtt > time.Duration(cfg.SlowPongMs)*time.Millisecond
+	if slow {
+		p.quality = QualityDegraded
+		p.fast = 0
+		if p.adaptive {
+			p.interval = max(p.interval/2, minInterval)
+		}
+	} else {
+		p.quality = QualityGood
+		p.fast++
+		if p.fast >= cfg.StablePongsToGrow {
+			p.fast = 0
+			if p.adaptive {
+				p.interval = min(p.interval*3/2, maxInterval)
+			}
+		}
+	}
+	if p.interval != previousInterval {
+		select {
+		case p.resized <- struct{}{}:
+		default:
+		}
+	}
+	return p.quality != previous
+}
+
+// state returns the last round trip, the current interval and the connection quality.
+func (p *pinger) state() (time.Duration, time.Duration, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rtt, p.interval, p.quality
+}
+
+// reportQuality tells a client its connection quality changed.
+func (h *Hub) reportQuality(client *Client) {
+	rtt, interval, quality := client.ping.state()
+	h.Logger.Infof("Connection of %s is %s: ping RTT %v, pinging every %v", client.Username(), quality, rtt, interval)
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":               "1.0",
+		"type":                  "connection_quality",
+		"quality":               quality,
+		"rtt_ms":                float64(rtt) / float64(time.Millisecond),
+		"ping_interval_seconds": interval.Seconds(),
+	})
+}
+
+// ConnectionQualityStats summarizes the WebSocket ping round trips of the connected clients.
+type ConnectionQualityStats struct {
+	Clients             int     `json:"clients"`
+	Good                int     `json:"good"`
+	Degraded            int     `json:"degraded"`
+	AvgPingRTTMs        float64 `json:"avg_ping_rtt_ms"` // over clients that answered a ping
+	MaxPingRTTMs        float64 `json:"max_ping_rtt_ms"`
+	AvgPingIntervalSecs float64 `json:"avg_ping_interval_seconds"`
+	AdaptivePing        bool    `json:"adaptive_ping"`
+}
+
+// ConnectionQuality returns the ping statistics of the clients connected to this hub.
+func (h *Hub) ConnectionQuality() ConnectionQualityStats {
+	stats := ConnectionQualityStats{AdaptivePing: h.settings().AdaptivePing}
+	var totalRTT, totalInterval time.Duration
+	measured := 0
+	for _, client := range h.clients.snapshot() {
+		rtt, interval, quality := client.ping.state()
+		stats.Clients++
+		if quality == QualityDegraded {
+			stats.Degraded++
+		} else {
+			stats.Good++
+		}
+		totalInterval += interval
+		if rtt > 0 {
+			measured++
+			totalRTT += rtt
+			stats.MaxPingRTTMs = max(stats.MaxPingRTTMs, float64(rtt)/float64(time.Millisecond))
+		}
+	}
+	if measured > 0 {
+		stats.AvgPingRTTMs = float64(totalRTT) / float64(measured) / float64(time.Millisecond)
+	}
+	if stats.Clients > 0 {
+		stats.AvgPingIntervalSecs = (totalInterval / time.Duration(stats.Clients)).Seconds()
+	}
+	return stats
+}
@@ -0,0 +1,40 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+)
+
+func TestPingerResized(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AdaptivePing = true
+	cfg.SlowPongMs = 100
+	cfg.PingMinSeconds = 10
+	cfg.PingMaxSeconds = 40
+	p := newPinger(cfg)
+	now := time.Now()
+
+	p.sent(now)
+	p.ponged(now.Add(10*time.Millisecond), cfg)
+	select {
+	case <-p.resized:
+		t.Fatal("fast pong at the longest interval signaled a resize")
+	default:
+	}
+
+	p.sent(now)
+	p.ponged(now.Add(time.Second), cfg)
+	select {
+	case <-p.resized:
+	default:
+		t.Fatal("slow pong halved the interval without signaling a resize")
+	}
+	if got, want := p.period(), 20*time.Second; got != want {
+		t.Errorf("interval after a slow pong = %v, want %v", got, want)
+	}
+	if p.readDeadline() <= p.period() {
+		t.Errorf("read deadline %v does not leave room for the ping due in %v", p.readDeadline(), p.period())
+	}
+}
@@ -0,0 +1,179 @@
+// internal/hub/preferences.go
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	preferencesBucket          = "PREFERENCES"
+	defaultPreferencesMaxBytes = 4096
+)
+
+// Errors returned by SetUserPreferences.
+var (
+	ErrInvalidPreferences  = errors.New("preferences must be a JSON object")
+	ErrPreferencesTooLarge = errors.New("preferences are too large")
+	ErrGuestPreferences    = errors.New("guests cannot store preferences")
+)
+
+// UserPreferences is the preference blob a client stored for a registered user, such as
+// its theme, notification opt-outs and locale. The server does not interpret it.
+type UserPreferences struct {
+	Username    string          `json:"username"`
+	Preferences json.RawMessage `json:"preferences"`
+	UpdatedAt   *time.Time      `json:"updated_at"` // null until preferences were stored
+}
+
+// preferencesStore keeps user preferences in a JetStream key-value bucket, or in memory
+// without JetStream.
+type preferencesStore struct {
+	kv nats.KeyValue // nil for the in-memory store
+
+	mu     sync.Mutex
+	memory map[string]UserPreferences
+}
+
+// newPreferencesStore opens the bucket, creating it if needed, and falls back to memory
+// when JetStream is unavailable.
+func newPreferencesStore(js nats.JetStreamContext, bucket string, logger *logger.Logger) *preferencesStore {
+	store := &preferencesStore{memory: make(map[string]UserPreferences)}
+	if js == nil {
+		logger.Warn("Using in-memory user preferences. Preferences will be lost on restart.")
+		return store
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Client preferences per user",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening user preferences bucket, keeping preferences in memory: %v", err)
+		return store
+	}
+	store.kv = kv
+	return store
+}
+
+// get returns the preferences of a user, with an empty object when none were stored.
+func (s *preferencesStore) get(username string) (UserPreferences, error) {
+	empty := UserPreferences{Username: username, Preferences: json.RawMessage("{}")}
+	if s.kv == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		prefs, ok := s.memory[username]
+		if !ok {
+			return empty, nil
+		}
+		return prefs, nil
+	}
+	entry, err := s.kv.Get(userKey(username))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return empty, nil
+	}
+	if err != nil {
+		return UserPreferences{}, fmt.Errorf("reading preferences for %s: %w", username, err)
+	}
+	var prefs UserPreferences
+	if err := json.Unmarshal(entry.Value(), &prefs); err != nil {
+		return UserPreferences{}, fmt.Errorf("corrupt preferences for %s: %w", username, err)
+	}
+	return prefs, nil
+}
+
+// put replaces the preferences of a user. The last write wins.
+func (s *preferencesStore) put(prefs UserPreferences) error {
+	if s.kv == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.memory[prefs.Username] = prefs
+		return nil
+	}
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	if _, err := s.kv.Put(userKey(prefs.Username), data); err != nil {
+		return fmt.Errorf("storing preferences for %s: %w", prefs.Username, err)
+	}
+	return nil
+}
+
+// delete forgets a user's preferences, including earlier revisions in the bucket.
+func (s *preferencesStore) delete(username string) error {
+	if s.kv == nil {
+		s.mu.Lock()
+		delete(s.memory, username)
+		s.mu.Unlock()
+		return nil
+	}
+	if err := s.kv.Purge(userKey(username)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return fmt.Errorf("deleting preferences for %s: %w", username, err)
+	}
+	return nil
+}
+
+// UserPreferences returns the stored preferences of a user.
+func (h *Hub) UserPreferences(username string) (UserPreferences, error) {
+	return h.preferences.get(username)
+}
+
+// SetUserPreferences replaces the preferences of a registered user with data, a JSON
+// object of at most preferences_max_bytes. Connected clients of the user receive the
+// new preferences with their next state_sync.
+func (h *Hub) SetUserPreferences(username string, data []byte) (UserPreferences, error) {
+	if isGuestName(username) {
+		return UserPreferences{}, ErrGuestPreferences
+	}
+	if !h.validUsername(username) {
+		return UserPreferences{}, ErrInvalidUsername
+	}
+	maxBytes := h.settings().PreferencesMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultPreferencesMaxBytes
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil || !bytes.HasPrefix(compact.Bytes(), []byte("{")) {
+		return UserPreferences{}, ErrInvalidPreferences
+	}
+	if compact.Len() > maxBytes {
+		return UserPreferences{}, ErrPreferencesTooLarge
+	}
+
+	now := h.clock.Now().UTC()
+	prefs := UserPreferences{Username: username, Preferences: compact.Bytes(), UpdatedAt: &now}
+	if err := h.preferences.put(prefs); err != nil {
+		return UserPreferences{}, err
+	}
+	for _, client := range h.clients.snapshot() {
+		if h.sameUsername(client.Username(), username) {
+			client.setPreferences(prefs.Preferences)
+		}
+	}
+	return prefs, nil
+}
+
+// loadPreferences reads the preferences of a registered client so they can be sent in
+// state_sync without reading the bucket on the hub goroutine. Errors are logged.
+func (h *Hub) loadPreferences(client *Client) {
+	if client.Guest() || client.Bot() {
+		return
+	}
+	prefs, err := h.preferences.get(client.Username())
+	if err != nil {
+		h.Logger.Errorf("Failed to load preferences of %s: %v", client.Username(), err)
+		return
+	}
+	client.setPreferences(prefs.Preferences)
+}
@@ -0,0 +1,192 @@
+// internal/hub/publisher.go
+package hub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/logger"
+)
+
+const (
+	defaultPublishQueueSize  = 1024
+	defaultPublishMaxRetries = 5
+	publishInitialBackoff    = 100 * time.Millisecond
+	publishMaxBackoff        = 5 * time.Second
+)
+
+// publishJob is an event waiting to be published on the bus.
+type publishJob struct {
+	subject    string
+	id         string // deduplication ID, empty for plain publishes
+	data       []byte
+	enqueuedAt time.Time
+}
+
+// PublishQueueStats reports the state of the asynchronous publish queue.
+type PublishQueueStats struct {
+	Depth         int     `json:"depth"`
+	Capacity      int     `json:"capacity"`
+	Published     uint64  `json:"published"`
+	Retries       uint64  `json:"retries"`
+	Failed        uint64  `json:"failed"`  // given up after all retries
+	Dropped       uint64  `json:"dropped"` // rejected because the queue was full
+	LastLatencyMs float64 `json:"last_latency_ms"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"` // exponentially weighted, from enqueue to acknowledgement
+	MaxLatencyMs  float64 `json:"max_latency_ms"`
+}
+
+// publishQueue decouples submission handling from event bus latency: events are
+// buffered and published in order by a single background worker that retries with backoff.
+type publishQueue struct {
+	bus        eventbus.EventBus
+	logger     *logger.Logger
+	jobs       chan publishJob
+	maxRetries int
+
+	published atomic.Uint64
+	retries   atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+
+	latencyMu   sync.Mutex
+	lastLatency time.Duration
+	avgLatency  time.Duration
+	maxLatency  time.Duration
+}
+
+func newPublishQueue(bus eventbus.EventBus, size, maxRetries int, logger *logger.Logger) *publishQueue {
+	if size <= 0 {
+		size = defaultPublishQueueSize
+	}
+	if maxRetries < 0 {
+		maxRetries = defaultPublishMaxRetries
+	}
+	return &publishQueue{
+		bus:        bus,
+		logger:     logger,
+		jobs:       make(chan publishJob, size),
+		maxRetries: maxRetries,
+	}
+}
+
+// enqueue hands an event to the background publisher without blocking.
+// Events are dropped and counted when the queue is full.
+func (q *publishQueue) enqueue(subject, id string, data []byte) {
+	select {
+	case q.jobs <- publishJob{subject: subject, id: id, data: data, enqueuedAt: time.Now()}:
+	default:
+		q.dropped.Add(1)
+		q.logger.Errorf("Publish queue full, dropping event on %s", subject)
+	}
+}
+
+// enqueueWait hands an event that must not be dropped to the background publisher,
+// waiting for room in a full queue until ctx is done.
+func (q *publishQueue) enqueueWait(ctx context.Context, subject string, data []byte) {
+	job := publishJob{subject: subject, data: data, enqueuedAt: time.Now()}
+	select {
+	case q.jobs <- job:
+		return
+	default:
+	}
+	q.logger.Warnf("Publish queue full, waiting to publish on %s", subject)
+	select {
+	case q.jobs <- job:
+	case <-ctx.Done():
+		q.dropped.Add(1)
+		q.logger.Errorf("Publish queue full while stopping, dropping event on %s", subject)
+	}
+}
+
+// run publishes queued events until ctx is canceled, then publishes what is still queued.
+// The queue stays usable so a restarted hub can run it again.
+func (q *publishQueue) run(ctx context.Context) {
+	for {
+		select {
+		case job := <-q.jobs:
+			q.publish(job)
+		case <-ctx.Done():
+			for {
+				select {
+				case job := <-q.jobs:
+					q.publish(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// publish delivers one event, retrying with exponential backoff.
+func (q *publishQueue) publish(job publishJob) {
+	backoff := publishInitialBackoff
+	for attempt := 0; ; attempt++ {
+		var err error
+		if job.id != "" {
+			err = q.bus.PublishWithID(job.subject, job.id, job.data)
+		} else {
+			err = q.bus.Publish(job.subject, job.data)
+		}
+		if err == nil {
+			q.published.Add(1)
+			q.observe(time.Since(job.enqueuedAt))
+			return
+		}
+		if attempt >= q.maxRetries {
+			q.failed.Add(1)
+			q.logger.Errorf("Failed to publish to %s after %d attempts: %v", job.subject, attempt+1, err)
+			return
+		}
+		q.retries.Add(1)
+		q.logger.Warnf("Publish to %s failed, retrying in %v: %v", job.subject, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, publishMaxBackoff)
+	}
+}
+
+// observe records the latency of a published event.
+func (q *publishQueue) observe(latency time.Duration) {
+	q.latencyMu.Lock()
+	defer q.latencyMu.Unlock()
+	q.lastLatency = latency
+	if q.avgLatency == 0 {
+		q.avgLatency = latency
+	} else {
+		q.avgLatency = (q.avgLatency*9 + latency) / 10
+	}
+	if latency > q.maxLatency {
+		q.maxLatency = latency
+	}
+}
+
+// stats returns a snapshot of the queue metrics.
+func (q *publishQueue) stats() PublishQueueStats {
+	q.latencyMu.Lock()
+	last, avg, max := q.lastLatency, q.avgLatency, q.maxLatency
+	q.latencyMu.Unlock()
+	return PublishQueueStats{
+		Depth:         len(q.jobs),
+		Capacity:      cap(q.jobs),
+		Published:     q.published.Load(),
+		Retries:       q.retries.Load(),
+		Failed:        q.failed.Load(),
+		Dropped:       q.dropped.Load(),
+		LastLatencyMs: float64(last) / float64(time.Millisecond),
+		AvgLatencyMs:  float64(avg) / float64(time.Millisecond),
+		MaxLatencyMs:  float64(max) / float64(time.Millisecond),
+	}
+}
+
+// PublishQueueStats returns the metrics of the submission publish queue, and false
+// when no event bus is available.
+func (h *Hub) PublishQueueStats() (PublishQueueStats, bool) {
+	if h.publisher == nil {
+		return PublishQueueStats{}, false
+	}
+	return h.publisher.stats(), true
+}
@@ -0,0 +1,91 @@
+// internal/hub/quorum.go
+package hub
+
+import (
+	"fmt"
+	"time"
+)
+
+// roundStatusVoid is published on the ROUNDS stream for rounds voided for lack of participants.
+const roundStatusVoid = "void"
+
+// countParticipants returns how many different users submitted the messages.
+func countParticipants(messages []RoundMessage) int {
+	seen := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		seen[msg.Username] = true
+	}
+	return len(seen)
+}
+
+// belowQuorum reports whether a round with submissions had fewer participants than
+// min_participants. Rounds without submissions are empty rather than void.
+func (h *Hub) belowQuorum(messages []RoundMessage) (int, bool) {
+	participants := countParticipants(messages)
+	return participants, participants > 0 && participants < h.settings().MinParticipants
+}
+
+// extendRoundForQuorum reopens entries of the active round once, for
+// participants_grace_seconds, when too few users submitted. It returns how long the
+// round now runs, and false when the round was not extended.
+func (h *Hub) extendRoundForQuorum() (time.Duration, bool) {
+	grace := time.Duration(h.settings().ParticipantsGraceSeconds) * time.Second
+	if grace <= 0 {
+		return 0, false
+	}
+
+	h.Mu.Lock()
+	roundID := h.CurrentRoundID
+	if !h.RoundActive || h.roundExtended {
+		h.Mu.Unlock()
+		return 0, false
+	}
+	participants, short := h.belowQuorum(h.rounds.messages(roundID))
+	if !short {
+		h.Mu.Unlock()
+		return 0, false
+	}
+	h.roundExtended = true
+	now := h.clock.Now()
+	h.submissionsCloseAt = now.Add(grace)
+	h.roundTiming.SubmissionDeadline = h.submissionsCloseAt
+	h.roundTiming.EndsAt = h.submissionsCloseAt
+	timing := h.roundTiming
+	h.Mu.Unlock()
+
+	extended := map[string]interface{}{
+		"version":          "1.0",
+		"type":             "round_extended",
+		"data":             roundID,
+		"participants":     participants,
+		"min_participants": h.settings().MinParticipants,
+	}
+	timing.addTo(extended)
+	h.BroadcastMessage(extended)
+	h.Logger.Infof("Round %d has %d participants, entries reopened for %v", roundID, participants, grace)
+	return grace, true
+}
+
+// voidRound ends a round that had too few participants without a winner. It broadcasts
+// "round_void" and records the round as void on the ROUNDS stream and in the statistics.
+func (h *Hub) voidRound(roundID int64, messages []RoundMessage, participants int) {
+	minimum := h.settings().MinParticipants
+	h.publishRoundEndToNATS(roundID, roundStatusVoid)
+	summary := summarizeRound(roundID, messages, "", h.clock.Now())
+	summary.Void = true
+	h.recordRoundSummary(summary)
+	h.rememberRound(roundID, messages, nil, nil, nil)
+	h.tournamentRoundWon(roundID, "")
+	h.recordCooldown(roundID, "")
+
+	h.BroadcastMessage(map[string]interface{}{
+		"version":          "1.0",
+		"type":             "round_void",
+		"data":             roundID,
+		"participants":     participants,
+		"min_participants": minimum,
+		"message":          fmt.Sprintf("Round voided: %d of %d required participants", participants, minimum),
+	})
+	h.seriesRoundWon(roundID, "")
+	h.Logger.Infof("Round %d voided with %d of %d required participants", roundID, participants, minimum)
+}
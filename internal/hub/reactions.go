@@ -0,0 +1,152 @@
+// internal/hub/reactions.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const reactionBroadcastInterval = time.Second
+
+// reactionTally aggregates emoji reactions for the round currently in its reveal phase,
+// i.e. between its winner announcement and the next one.
+type reactionTally struct {
+	mu      sync.Mutex
+	roundID int64
+	counts  map[string]int
+	reacted map[string]bool // username + emoji pairs that were already counted
+	dirty   bool            // counts changed since the last broadcast
+}
+
+// openReveal starts collecting reactions for a round and returns the final counts
+// of the previous reveal phase (nil if there was none).
+func (t *reactionTally) openReveal(roundID int64) (int64, map[string]int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prevRound, prevCounts := t.roundID, t.counts
+	t.roundID = roundID
+	t.counts = make(map[string]int)
+	t.reacted = make(map[string]bool)
+	t.dirty = false
+	return prevRound, prevCounts
+}
+
+// add counts a reaction and reports false if the round is not in its reveal phase
+// or the user already sent this emoji.
+func (t *reactionTally) add(roundID int64, username, emoji string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts == nil || roundID != t.roundID {
+		return false
+	}
+	key := username + "\x00" + emoji
+	if t.reacted[key] {
+		return false
+	}
+	t.reacted[key] = true
+	t.counts[emoji]++
+	t.dirty = true
+	return true
+}
+
+// takeDirty returns a copy of the counts if they changed since the last call.
+func (t *reactionTally) takeDirty() (int64, map[string]int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dirty {
+		return 0, nil, false
+	}
+	t.dirty = false
+	counts := make(map[string]int, len(t.counts))
+	for emoji, n := range t.counts {
+		counts[emoji] = n
+	}
+	return t.roundID, counts, true
+}
+
+// startReveal opens the reveal phase for a round whose winner was just announced and
+// archives the final tally of the previous reveal phase.
+func (h *Hub) startReveal(roundID int64) {
+	prevRound, prevCounts := h.reactions.openReveal(roundID)
+	if prevCounts != nil {
+		h.recent.setReactions(prevRound, prevCounts)
+		h.publishReactionsToNATS(prevRound, prevCounts)
+	}
+}
+
+// handleReaction counts an emoji reaction for the round in its reveal phase.
+// Counts are broadcast in batches by runReactionBroadcaster.
+func (h *Hub) handleReaction(client *Client, message map[string]interface{}) {
+	emoji, _ := message["data"].(string)
+	if !h.allowedReaction(emoji) {
+		h.SendErrorMessage(client, "Unsupported reaction")
+		return
+	}
+	roundID, ok := message["round_id"].(float64)
+	if !ok {
+		h.SendErrorMessage(client, "Reaction requires round_id")
+		return
+	}
+	if !h.reactions.add(int64(roundID), client.Username(), emoji) {
+		h.SendErrorMessage(client, "Reactions are closed for this round or already counted")
+	}
+}
+
+// allowedReaction reports whether emoji is in the configured reaction set.
+func (h *Hub) allowedReaction(emoji string) bool {
+	for _, allowed := range h.settings().ReactionEmojis {
+		if emoji == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// runReactionBroadcaster periodically broadcasts updated reaction counts so that bursts of
+// reactions result in at most one "reaction_counts" message per interval.
+func (h *Hub) runReactionBroadcaster(ctx context.Context) {
+	ticker := time.NewTicker(reactionBroadcastInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		roundID, counts, changed := h.reactions.takeDirty()
+		if !changed {
+			continue
+		}
+		h.BroadcastMessage(map[string]interface{}{
+			"version":  "1.0",
+			"type":     "reaction_counts",
+			"round_id": roundID,
+			"data":     counts,
+		})
+	}
+}
+
+// publishReactionsToNATS archives the final reaction tally of a round on "reactions.ROUND_ID".
+func (h *Hub) publishReactionsToNATS(roundID int64, counts map[string]int) {
+	if h.Bus != nil {
+		reactionData := map[string]any{
+			"round_id":  roundID,
+			"counts":    counts,
+			"timestamp": h.clock.Now().Unix(),
+		}
+		if data, err := json.Marshal(reactionData); err == nil {
+			if err := h.Bus.Publish(fmt.Sprintf("reactions.%d", roundID), data); err != nil {
+				h.Logger.Errorf("Failed to publish reactions to event bus: %v", err)
+			}
+		} else {
+			h.Logger.Errorf("Failed to marshal reaction data: %v", err)
+		}
+	}
+}
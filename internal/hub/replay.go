@@ -0,0 +1,247 @@
+// internal/hub/replay.go
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+)
+
+const (
+	replayEventLimit   = 10000 // events read per subject when rebuilding state
+	replayFetchMaxWait = 2 * time.Second
+)
+
+// replayedRound is what the event streams recorded about one round.
+type replayedRound struct {
+	id       int64
+	timing   roundTiming
+	started  bool
+	ended    bool
+	messages []RoundMessage
+	removed  map[string]bool // submissions withdrawn or redacted, kept for redactions read before their submission
+	winner   *RoundMessage
+	endedAt  time.Time
+}
+
+// replayHistory rebuilds in-memory state from the last replay_on_startup_minutes of the
+// ROUNDS, MESSAGES and WINNERS streams so a restart mid-round neither loses the round
+// nor starts an overlapping one. Finished rounds refill the recent rounds, the last
+// winner and the round history behind the statistics. The latest round, if it neither
+// ended nor has a winner, becomes the active round again with its submissions and
+// deadlines; StartRoundTimer then lets it run out instead of starting a new one.
+// Nothing is published or broadcast. Read errors are logged and leave the state empty.
+func (h *Hub) replayHistory(window time.Duration) {
+	if h.Bus == nil || window <= 0 {
+		return
+	}
+	cutoff := h.clock.Now().Add(-window)
+	rounds := make(map[int64]*replayedRound)
+	round := func(subject string) *replayedRound {
+		id, err := strconv.ParseInt(subject[strings.LastIndex(subject, ".")+1:], 10, 64)
+		if err != nil {
+			return nil
+		}
+		if rounds[id] == nil {
+			rounds[id] = &replayedRound{id: id}
+		}
+		return rounds[id]
+	}
+
+	for _, subject := range []string{"rounds.started.*", "rounds.ended.*", "messages.*", "winners.*"} {
+		events, err := h.Bus.HistorySince(h.context(), subject, cutoff, replayEventLimit, replayFetchMaxWait)
+		if errors.Is(err, eventbus.ErrHistoryIncomplete) {
+			h.Logger.Warnf("Replay: %s read in part, state is rebuilt from %d events: %v", subject, len(events), err)
+		} else if err != nil {
+			h.Logger.Errorf("Replay: error reading %s, state is rebuilt without it: %v", subject, err)
+			continue
+		}
+		for _, event := range events {
+			if event.Timestamp.Before(cutoff) {
+				continue
+			}
+			if r := round(event.Subject); r != nil {
+				h.applyReplayedEvent(r, subject, event)
+			}
+		}
+	}
+	if len(rounds) == 0 {
+		h.Logger.Infof("Replay: no rounds in the last %s", window)
+		return
+	}
+
+	ids := make([]int64, 0, len(rounds))
+	for id := range rounds {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	latest := rounds[ids[len(ids)-1]]
+	resume := latest.started && !latest.ended && latest.winner == nil
+	finished := ids
+	if resume {
+		finished = ids[:len(ids)-1]
+	}
+
+	for _, id := range finished {
+		// Empty rounds publish no end event unless publish_empty_rounds is set.
+		r := rounds[id]
+		if r.endedAt.IsZero() {
+			r.endedAt = r.timing.EndsAt
+		}
+		if r.endedAt.IsZero() {
+			r.endedAt = time.Unix(id, 0)
+		}
+	}
+
+	h.Mu.Lock()
+	for _, id := range finished {
+		r := rounds[id]
+		winner := ""
+		if r.winner != nil {
+			winner = r.winner.Username
+		}
+		h.RoundHistory = append(h.RoundHistory, summarizeRound(id, r.messages, winner, r.endedAt))
+	}
+	h.CurrentRoundID = max(h.CurrentRoundID, latest.id)
+	if resume {
+		h.RoundActive = true
+		h.roundTiming = latest.timing
+		h.submissionsCloseAt = latest.timing.SubmissionDeadline
+		limiter := newSubmissionLimiter()
+		for _, msg := range latest.messages {
+			limiter.tryMark(h.usernameKey(msg.Username))
+		}
+		h.limiter.Store(limiter)
+	}
+	h.Mu.Unlock()
+
+	for _, id := range finished {
+		r := rounds[id]
+		h.recent.add(RecentRound{RoundID: id, Messages: r.messages, Winner: r.winner, EndedAt: r.endedAt})
+		if r.winner != nil {
+			h.rememberResult(id, r.winner)
+		}
+	}
+	if resume {
+		b := h.rounds.bucket(latest.id)
+		b.mu.Lock()
+		b.messages = append(b.messages, latest.messages...)
+		b.mu.Unlock()
+		if deadline := latest.timing.SubmissionDeadline; deadline.Before(latest.timing.EndsAt) && deadline.After(h.clock.Now()) {
+			h.clock.AfterFunc(deadline.Sub(h.clock.Now()), func() { h.closeSubmissions(latest.id, deadline) })
+		}
+		h.Logger.Infof("Replay: resumed round %d with %d submissions, ending at %s", latest.id, len(latest.messages), latest.timing.EndsAt.Format(time.RFC3339))
+	}
+	h.Logger.Infof("Replay: rebuilt %d finished rounds from the last %s", len(finished), window)
+}
+
+// applyReplayedEvent folds one stream event into the round it belongs to. Submissions
+// are folded like the history API does: edits replace the text, withdrawals and
+// redactions remove the submission, also when they were read before it.
+func (h *Hub) applyReplayedEvent(r *replayedRound, subject string, event eventbus.Event) {
+	var data struct {
+		ID                 string `json:"id"`
+		Action             string `json:"action"`
+		Username           string `json:"username"`
+		Bot                bool   `json:"bot"`
+		Content            string `json:"content"`
+		Timestamp          int64  `json:"timestamp"`
+		MessageID          string `json:"message_id"`
+		Supersedes         string `json:"supersedes"`
+		StartedAt          string `json:"started_at"`
+		SubmissionDeadline string `json:"submission_deadline"`
+		EndsAt             string `json:"ends_at"`
+		PacingProfile      string `json:"pacing_profile"`
+		Payload            struct {
+			Text         string `json:"text"`
+			Lang         string `json:"lang"`
+			AttachmentID string `json:"attachment_id"`
+			Choice       *int   `json:"choice"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		h.Logger.Errorf("Replay: skipping malformed event on %s: %v", event.Subject, err)
+		return
+	}
+
+	switch subject {
+	case "rounds.started.*":
+		r.started = true
+		r.timing.StartedAt, _ = time.Parse(time.RFC3339, data.StartedAt)
+		r.timing.SubmissionDeadline, _ = time.Parse(time.RFC3339, data.SubmissionDeadline)
+		r.timing.EndsAt, _ = time.Parse(time.RFC3339, data.EndsAt)
+		r.timing.Pacing = data.PacingProfile
+	case "rounds.ended.*":
+		r.ended = true
+		r.endedAt = time.Unix(data.Timestamp, 0)
+	case "winners.*":
+		// A correction replaces the winner drawn earlier and keeps the round's end time;
+		// one without a username leaves the round without a winner.
+		if data.Supersedes == "" {
+			r.endedAt = time.Unix(data.Timestamp, 0)
+		}
+		if data.Username == "" {
+			r.winner = nil
+			return
+		}
+		r.winner = &RoundMessage{ID: data.MessageID, Username: data.Username, Message: data.Content, Timestamp: time.Unix(data.Timestamp, 0)}
+		for _, msg := range r.messages {
+			if msg.ID == data.MessageID {
+				winner := msg
+				r.winner = &winner
+			}
+		}
+	case "messages.*":
+		text := data.Payload.Text
+		if text == "" {
+			text = data.Content
+		}
+		switch data.Action {
+		case messageActionEdit:
+			for i := range r.messages {
+				if r.messages[i].ID == data.ID {
+					r.messages[i].Message = text
+					r.messages[i].Lang = data.Payload.Lang
+					r.messages[i].AttachmentID = data.Payload.AttachmentID
+					r.messages[i].Choice = data.Payload.Choice
+				}
+			}
+		case messageActionWithdraw, messageActionRedact:
+			if r.removed == nil {
+				r.removed = make(map[string]bool)
+			}
+			r.removed[data.ID] = true
+			for i := range r.messages {
+				if r.messages[i].ID == data.ID {
+					r.messages = append(r.messages[:i:i], r.messages[i+1:]...)
+					break
+				}
+			}
+		default:
+			if r.removed[data.ID] {
+				return
+			}
+			for _, msg := range r.messages {
+				if msg.ID == data.ID {
+					return
+				}
+			}
+			r.messages = append(r.messages, RoundMessage{
+				ID:           data.ID,
+				Username:     data.Username,
+				Message:      text,
+				Lang:         data.Payload.Lang,
+				AttachmentID: data.Payload.AttachmentID,
+				Choice:       data.Payload.Choice,
+				Bot:          data.Bot,
+				Timestamp:    time.Unix(data.Timestamp, 0),
+			})
+		}
+	}
+}
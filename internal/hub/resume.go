@@ -0,0 +1,350 @@
+// internal/hub/resume.go
+package hub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	resumeKeysBucket    = "RESUME_KEYS"
+	resumeCheckInterval = 15 * time.Second // how often tokens are refreshed and keys rotated
+)
+
+// Errors returned for resume tokens that cannot be used.
+var (
+	ErrResumeDisabled     = errors.New("session resumption is disabled")
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+	ErrResumeTokenExpired = errors.New("resume token expired")
+)
+
+// resumeClaims is what a resume token vouches for: the connection it was issued to.
+type resumeClaims struct {
+	Username  string `json:"u"`
+	Guest     bool   `json:"g,omitempty"`
+	SessionID string `json:"s"`
+	Room      string `json:"r"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// resumeKey is an HMAC key that signs resume tokens.
+type resumeKey struct {
+	ID        string    `json:"id"`
+	Secret    []byte    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// resumeKeyring holds the keys resume tokens are signed and verified with. Keys from
+// resume_secrets never change. Otherwise keys are generated, shared with the other
+// instances through a key-value bucket and replaced every resume_key_rotation_hours;
+// a replaced key verifies tokens until the last one it signed expired.
+type resumeKeyring struct {
+	kv     nats.KeyValue // nil with resume_secrets or without JetStream
+	static bool          // keys come from resume_secrets
+
+	mu   sync.RWMutex
+	keys []resumeKey // newest first
+}
+
+// newMemoryResumeKeyring returns a keyring with one generated key, which other instances
+// do not know and a restart loses.
+func newMemoryResumeKeyring(now time.Time) *resumeKeyring {
+	k := &resumeKeyring{}
+	k.keys = []resumeKey{newResumeKey(now)}
+	return k
+}
+
+// newResumeKeyring uses resume_secrets when configured, otherwise opens the bucket,
+// creating it if needed, and loads or generates the keys.
+func newResumeKeyring(cfg config.Config, js nats.JetStreamContext, bucket string, now time.Time, logger *logger.Logger) *resumeKeyring {
+	if len(cfg.ResumeSecrets) > 0 {
+		k := &resumeKeyring{static: true}
+		for _, secret := range cfg.ResumeSecrets {
+			sum := sha256.Sum256([]byte(secret))
+			k.keys = append(k.keys, resumeKey{ID: hex.EncodeToString(sum[:4]), Secret: []byte(secret)})
+		}
+		return k
+	}
+	if js == nil {
+		if cfg.ResumeTokenTTLSeconds > 0 {
+			logger.Warnf("No resume_secrets and no JetStream: resume tokens only work on this instance until it restarts")
+		}
+		return newMemoryResumeKeyring(now)
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Keys that sign session resume tokens",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening resume keys bucket, resume tokens only work on this instance until it restarts: %v", err)
+		return newMemoryResumeKeyring(now)
+	}
+	k := &resumeKeyring{kv: kv}
+	if err := k.rotate(now, cfg); err != nil {
+		logger.Errorf("Error loading resume keys, resume tokens only work on this instance until it restarts: %v", err)
+		return newMemoryResumeKeyring(now)
+	}
+	return k
+}
+
+func newResumeKey(now time.Time) resumeKey {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	id := make([]byte, 4)
+	rand.Read(id)
+	return resumeKey{ID: hex.EncodeToString(id), Secret: secret, CreatedAt: now}
+}
+
+// signing returns the key new tokens are signed with.
+func (k *resumeKeyring) signing() resumeKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[0]
+}
+
+// lookup returns the key with id, reading it from the bucket when another instance
+// generated it since the last rotation check.
+func (k *resumeKeyring) lookup(id string) (resumeKey, bool) {
+	k.mu.RLock()
+	for _, key := range k.keys {
+		if key.ID == id {
+			k.mu.RUnlock()
+			return key, true
+		}
+	}
+	k.mu.RUnlock()
+	if k.kv == nil {
+		return resumeKey{}, false
+	}
+	entry, err := k.kv.Get(id)
+	if err != nil {
+		return resumeKey{}, false
+	}
+	var key resumeKey
+	if err := json.Unmarshal(entry.Value(), &key); err != nil || key.ID != id {
+		return resumeKey{}, false
+	}
+	return key, true
+}
+
+// rotate reloads the generated keys from the bucket, adds a key when the newest is
+// older than resume_key_rotation_hours and deletes keys no unexpired token can carry.
+// Instances rotating at once each add a key; all of them verify.
+func (k *resumeKeyring) rotate(now time.Time, cfg config.Config) error {
+	if k.static || k.kv == nil {
+		return nil
+	}
+	rotation := time.Duration(cfg.ResumeKeyRotationHours) * time.Hour
+	if rotation <= 0 {
+		rotation = 24 * time.Hour
+	}
+	ttl := time.Duration(cfg.ResumeTokenTTLSeconds) * time.Second
+
+	names, err := k.kv.Keys()
+	if err != nil && !errors.Is(err, nats.ErrNoKeysFound) {
+		return fmt.Errorf("listing resume keys: %w", err)
+	}
+	var keys []resumeKey
+	for _, name := range names {
+		entry, err := k.kv.Get(name)
+		if err != nil {
+			continue // deleted by another instance meanwhile
+		}
+		var key resumeKey
+		if err := json.Unmarshal(entry.Value(), &key); err != nil {
+			continue
+		}
+		// A key stops signing once it is rotation old and verifies for ttl longer.
+		if now.Sub(key.CreatedAt) > rotation+ttl {
+			k.kv.Delete(name)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	if len(keys) == 0 || now.Sub(keys[0].CreatedAt) >= rotation {
+		key := newResumeKey(now)
+		data, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		if _, err := k.kv.Create(key.ID, data); err != nil {
+			return fmt.Errorf("storing resume key: %w", err)
+		}
+		keys = append([]resumeKey{key}, keys...)
+	}
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+// sign encodes claims as a token: key ID, payload and HMAC-SHA256, joined by dots.
+func (k *resumeKeyring) sign(claims resumeClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	key := k.signing()
+	signed := key.ID + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(resumeMAC(key.Secret, signed)), nil
+}
+
+// verify checks a token's signature and returns its claims, expired or not.
+func (k *resumeKeyring) verify(token string) (resumeClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return resumeClaims{}, ErrInvalidResumeToken
+	}
+	key, ok := k.lookup(parts[0])
+	if !ok {
+		return resumeClaims{}, ErrInvalidResumeToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(mac, resumeMAC(key.Secret, parts[0]+"."+parts[1])) {
+		return resumeClaims{}, ErrInvalidResumeToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return resumeClaims{}, ErrInvalidResumeToken
+	}
+	var claims resumeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Username == "" || claims.SessionID == "" {
+		return resumeClaims{}, ErrInvalidResumeToken
+	}
+	return claims, nil
+}
+
+func resumeMAC(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// checkResumeToken returns the claims of a token issued by this hub's room that has
+// not expired.
+func (h *Hub) checkResumeToken(token string) (resumeClaims, error) {
+	if h.settings().ResumeTokenTTLSeconds <= 0 {
+		return resumeClaims{}, ErrResumeDisabled
+	}
+	claims, err := h.resumeKeys.verify(token)
+	if err != nil {
+		return resumeClaims{}, err
+	}
+	if claims.Room != h.room {
+		return resumeClaims{}, ErrInvalidResumeToken
+	}
+	if h.clock.Now().Unix() >= claims.ExpiresAt {
+		return resumeClaims{}, ErrResumeTokenExpired
+	}
+	return claims, nil
+}
+
+// sessionHeldByOther reports whether a connection of another session plays username,
+// which a resume must not take over.
+func (h *Hub) sessionHeldByOther(username, sessionID string) bool {
+	for _, client := range h.clients.snapshot() {
+		if h.sameUsername(client.Username(), username) && client.SessionID != sessionID {
+			return true
+		}
+	}
+	return false
+}
+
+// closeSession closes the earlier connections of a resumed session, which are usually
+// already dead but not yet timed out.
+func (h *Hub) closeSession(sessionID string, resumed *Client) {
+	for _, client := range h.clients.snapshot() {
+		if client != resumed && client.SessionID == sessionID {
+			client.Conn.Close()
+		}
+	}
+}
+
+// sendResumeToken issues a client a token for resuming its session on any instance
+// after a disconnect. Bots authenticate with their token instead.
+func (h *Hub) sendResumeToken(client *Client, resumed bool) {
+	ttl := time.Duration(h.settings().ResumeTokenTTLSeconds) * time.Second
+	if ttl <= 0 || client.Bot() {
+		return
+	}
+	now := h.clock.Now()
+	username := client.Username()
+	claims := resumeClaims{
+		Username:  username,
+		Guest:     client.Guest(),
+		SessionID: client.SessionID,
+		Room:      h.room,
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	token, err := h.resumeKeys.sign(claims)
+	if err != nil {
+		h.Logger.Errorf("Failed to sign resume token: %v", err)
+		return
+	}
+	client.mu.Lock()
+	client.resumeIssuedAt = now
+	client.resumeUsername = username
+	client.mu.Unlock()
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":    "1.0",
+		"type":       "resume_token",
+		"token":      token,
+		"expires_at": time.Unix(claims.ExpiresAt, 0).UTC(),
+		"session_id": client.SessionID,
+		"resumed":    resumed,
+	})
+}
+
+// runResumeTokens refreshes every client's resume token once half its lifetime passed
+// or the client signed in under another name, and rotates the keys of the main hub,
+// until ctx is canceled.
+func (h *Hub) runResumeTokens(ctx context.Context) {
+	ticker := h.clock.NewTicker(resumeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		cfg := h.settings()
+		if h.parent == nil {
+			if err := h.resumeKeys.rotate(h.clock.Now(), cfg); err != nil {
+				h.Logger.Errorf("Error rotating resume keys: %v", err)
+			}
+		}
+		refresh := time.Duration(cfg.ResumeTokenTTLSeconds) * time.Second / 2
+		if refresh <= 0 {
+			continue
+		}
+		now := h.clock.Now()
+		for _, client := range h.clients.snapshot() {
+			client.mu.RLock()
+			stale := now.Sub(client.resumeIssuedAt) >= refresh || client.resumeUsername != client.username
+			client.mu.RUnlock()
+			if stale {
+				h.sendResumeToken(client, false)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// internal/hub/rewards.go
+package hub
+
+import (
+	"errors"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/rewards"
+	"github.com/nats-io/nats.go"
+)
+
+const pointsBucket = "POINTS"
+
+// ErrPointsUnavailable is returned by UserPoints when balances are not tracked locally.
+var ErrPointsUnavailable = errors.New("points are not tracked by this server")
+
+// newRewardProvider builds the provider selected in the configuration. The KV ledger
+// falls back to an in-memory ledger when JetStream is not available.
+func newRewardProvider(cfg config.Config, js nats.JetStreamContext, logger *logger.Logger) rewards.RewardProvider {
+	switch cfg.RewardsProvider {
+	case config.RewardsNone:
+		return nil
+	case config.RewardsWebhook:
+		if cfg.RewardsWebhookURL == "" {
+			logger.Warn("Rewards webhook selected without rewards_webhook_url, rewards disabled")
+			return nil
+		}
+		return rewards.NewWebhookProvider(cfg.RewardsWebhookURL, cfg.RewardsWebhookSecret)
+	default:
+		if js != nil {
+			ledger, err := rewards.NewKVLedger(js, cfg.ResourceName(pointsBucket))
+			if err == nil {
+				return ledger
+			}
+			logger.Errorf("Error opening points ledger: %v", err)
+		}
+		logger.Warn("Using in-memory points ledger. Points will be lost on restart.")
+		return rewards.NewMemoryLedger()
+	}
+}
+
+// grantReward hands the configured points to the round winner and returns how many
+// were granted.
+func (h *Hub) grantReward(username string, roundID int64) int {
+	points := h.settings().RewardPoints
+	if h.Rewards == nil || points <= 0 {
+		return 0
+	}
+	if err := h.Rewards.GrantPoints(username, roundID, points); err != nil {
+		h.Logger.Errorf("Failed to grant %d points to %s for round %d: %v", points, username, roundID, err)
+		return 0
+	}
+	h.Logger.Debugf("Granted %d points to %s for round %d", points, username, roundID)
+	return points
+}
+
+// UserPoints returns a user's point balance from the configured ledger.
+func (h *Hub) UserPoints(username string) (int64, error) {
+	reader, ok := h.Rewards.(rewards.PointsReader)
+	if !ok {
+		return 0, ErrPointsUnavailable
+	}
+	return reader.Points(username)
+}
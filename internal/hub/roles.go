@@ -0,0 +1,276 @@
+// internal/hub/roles.go
+package hub
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/erilali/internal/message"
+)
+
+// Roles of users in a room.
+const (
+	RoleOwner     = message.RoleOwner
+	RoleModerator = message.RoleModerator
+	RolePlayer    = message.RolePlayer
+	RoleSpectator = message.RoleSpectator
+)
+
+// RoleForbiddenCode is the error code for messages the sender's role in the room does
+// not allow.
+const RoleForbiddenCode = "ROLE_FORBIDDEN"
+
+// Errors returned by role operations.
+var (
+	ErrInvalidRole   = errors.New("role must be moderator, player or spectator")
+	ErrRoleForbidden = errors.New("forbidden")
+)
+
+// moderationMessageTypes need the owner or moderator role in a room. Spectators may not
+// send playMessageTypes.
+var moderationMessageTypes = map[string]bool{
+	"remove_message": true,
+	"mute":           true,
+}
+
+// RoomRole is a user's role in a room. Moderators act with their moderator token, the
+// owner with the owner token; the token is only returned to the owner assigning it.
+type RoomRole struct {
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	ModeratorToken string `json:"moderator_token,omitempty"`
+}
+
+// role returns the role assigned to username in the room, player by default.
+func (rm *room) role(username string) RoomRole {
+	if username == rm.settings.Owner {
+		return RoomRole{Username: username, Role: RoleOwner}
+	}
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if role, ok := rm.roles[username]; ok {
+		return role
+	}
+	return RoomRole{Username: username, Role: RolePlayer}
+}
+
+// access returns the role a token grants: owner for the owner token, moderator for the
+// token of a current moderator, and false for any other.
+func (rm *room) access(token string) (RoomRole, bool) {
+	if rm.isOwner(token) {
+		return RoomRole{Username: rm.settings.Owner, Role: RoleOwner}, true
+	}
+	if token == "" {
+		return RoomRole{}, false
+	}
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	for _, role := range rm.roles {
+		if role.Role == RoleModerator && subtle.ConstantTimeCompare([]byte(token), []byte(role.ModeratorToken)) == 1 {
+			return role, true
+		}
+	}
+	return RoomRole{}, false
+}
+
+// SetRoomRole assigns a role to username in a room; player removes the assignment.
+// Making someone a moderator issues a new moderator token, invalidating an earlier one.
+// Connected clients of the user are told their new role.
+func (h *Hub) SetRoomRole(name, ownerToken, username, role string) (RoomRole, error) {
+	rh, err := h.OwnedRoom(name, ownerToken)
+	if err != nil {
+		return RoomRole{}, err
+	}
+	rm, _ := h.rooms.get(name)
+	if username == rm.settings.Owner {
+		return RoomRole{}, fmt.Errorf("%w: the owner's role cannot be changed", ErrRoleForbidden)
+	}
+	assigned := RoomRole{Username: username, Role: role}
+	rm.mu.Lock()
+	switch role {
+	case RoleModerator:
+		assigned.ModeratorToken = randomToken(32)
+		rm.roles[username] = assigned
+	case RoleSpectator:
+		rm.roles[username] = assigned
+	case RolePlayer:
+		delete(rm.roles, username)
+	default:
+		rm.mu.Unlock()
+		return RoomRole{}, ErrInvalidRole
+	}
+	rm.mu.Unlock()
+
+	for _, client := range rh.clients.snapshot() {
+		if client.Username() == username {
+			rh.sendMessageToClient(client, map[string]interface{}{
+				"version": "1.0",
+				"type":    "role_update",
+				"data":    rh.clientRole(client),
+			})
+		}
+	}
+	h.Audit(AuditAdminAction, username, fmt.Sprintf("Made %s in room %s by its owner", role, name), rm.settings.Owner)
+	h.Logger.Infof("%s is now %s in room %s", username, role, name)
+	return assigned, nil
+}
+
+// RoomRoles lists the users with a role other than player in a room, ordered by
+// username, without their tokens.
+func (h *Hub) RoomRoles(name, ownerToken string) ([]RoomRole, error) {
+	if _, err := h.OwnedRoom(name, ownerToken); err != nil {
+		return nil, err
+	}
+	rm, _ := h.rooms.get(name)
+	roles := []RoomRole{{Username: rm.settings.Owner, Role: RoleOwner}}
+	rm.mu.Lock()
+	for _, role := range rm.roles {
+		role.ModeratorToken = ""
+		roles = append(roles, role)
+	}
+	rm.mu.Unlock()
+	assigned := roles[1:]
+	sort.Slice(assigned, func(i, j int) bool { return assigned[i].Username < assigned[j].Username })
+	return roles, nil
+}
+
+// ModeratedRoom returns the hub of a room and the role a bearer token grants in it,
+// owner or moderator. Moderators may remove submissions and mute users in that room only.
+func (h *Hub) ModeratedRoom(name, token string) (*Hub, RoomRole, error) {
+	if h.rooms == nil {
+		return nil, RoomRole{}, ErrRoomNotFound
+	}
+	rm, ok := h.rooms.get(name)
+	if !ok {
+		return nil, RoomRole{}, ErrRoomNotFound
+	}
+	role, ok := rm.access(token)
+	if !ok {
+		return nil, RoomRole{}, ErrNotRoomOwner
+	}
+	return rm.hub, role, nil
+}
+
+// ownRoom returns the room a room hub plays, nil for the main hub or a deleted room.
+func (h *Hub) ownRoom() *room {
+	if h.parent == nil {
+		return nil
+	}
+	rm, ok := h.parent.rooms.get(h.room)
+	if !ok || rm.hub != h {
+		return nil
+	}
+	return rm
+}
+
+// clientRole returns the role a client plays in its room. The owner and moderator roles
+// need the matching role_token on connect; without it, or in the main hub, a client
+// is a player, or a spectator if assigned that role.
+func (h *Hub) clientRole(client *Client) string {
+	rm := h.ownRoom()
+	if rm == nil {
+		return RolePlayer
+	}
+	if access, ok := rm.access(client.roleToken); ok && access.Username == client.Username() {
+		return access.Role
+	}
+	if role := rm.role(client.Username()); role.Role == RoleSpectator {
+		return RoleSpectator
+	}
+	return RolePlayer
+}
+
+// roleAllowed checks a frame against the sender's role before it is handled: only
+// owners and moderators moderate, and spectators may not play.
+func (h *Hub) roleAllowed(client *Client, messageType string) bool {
+	if !moderationMessageTypes[messageType] && !playMessageTypes[messageType] {
+		return true
+	}
+	role := h.clientRole(client)
+	switch {
+	case moderationMessageTypes[messageType] && role != RoleOwner && role != RoleModerator:
+		h.SendErrorCode(client, RoleForbiddenCode, "Only the room's owner and moderators can moderate")
+		return false
+	case playMessageTypes[messageType] && messageType != "auth" && role == RoleSpectator:
+		h.SendErrorCode(client, RoleForbiddenCode, "Spectators cannot play in this room")
+		return false
+	}
+	return true
+}
+
+// handleRemoveMessage lets a moderator remove a submission of the room's rounds.
+func (h *Hub) handleRemoveMessage(client *Client, msg map[string]interface{}) {
+	roundID, ok := msg["round_id"].(float64)
+	if !ok || roundID != math.Trunc(roundID) {
+		h.SendErrorMessage(client, "Removing a message requires an integer round_id")
+		return
+	}
+	messageID, _ := msg["message_id"].(string)
+	reason, _ := msg["reason"].(string)
+	redaction, err := h.RemoveSubmission(int64(roundID), messageID, reason, client.Username())
+	if errors.Is(err, ErrMessageNotFound) {
+		h.SendErrorMessage(client, "Message not found in this room")
+		return
+	}
+	if err != nil {
+		h.SendErrorMessage(client, err.Error())
+		return
+	}
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":    "1.0",
+		"type":       "moderation_ack",
+		"action":     "remove_message",
+		"username":   redaction.Username,
+		"round_id":   int64(roundID),
+		"message_id": messageID,
+	})
+}
+
+// handleMute lets a moderator mute a user in the room. Only the owner may mute
+// moderators, and nobody the owner.
+func (h *Hub) handleMute(client *Client, msg map[string]interface{}) {
+	username, _ := msg["username"].(string)
+	seconds, _ := msg["duration_seconds"].(float64)
+	reason, _ := msg["reason"].(string)
+	mute, err := h.muteInRoom(h.clientRole(client), client.Username(), username, reason, time.Duration(seconds)*time.Second)
+	if errors.Is(err, ErrRoleForbidden) {
+		h.SendErrorCode(client, RoleForbiddenCode, err.Error())
+		return
+	}
+	if err != nil {
+		h.SendErrorMessage(client, "Invalid mute: "+err.Error())
+		return
+	}
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":  "1.0",
+		"type":     "moderation_ack",
+		"action":   "mute",
+		"username": mute.Username,
+		"until":    mute.Until.UTC().Format(time.RFC3339),
+	})
+}
+
+// muteInRoom mutes username in a room hub on behalf of actor playing role.
+func (h *Hub) muteInRoom(role, actor, username, reason string, duration time.Duration) (Mute, error) {
+	rm := h.ownRoom()
+	if rm == nil {
+		return Mute{}, ErrRoomNotFound
+	}
+	switch target := rm.role(username).Role; {
+	case target == RoleOwner:
+		return Mute{}, fmt.Errorf("%w: the room's owner cannot be muted", ErrRoleForbidden)
+	case target == RoleModerator && role != RoleOwner:
+		return Mute{}, fmt.Errorf("%w: only the room's owner can mute a moderator", ErrRoleForbidden)
+	}
+	return h.MuteUser(username, reason, duration, actor)
+}
+
+// MuteInRoom mutes username in the room on behalf of the owner or a moderator, as
+// returned by ModeratedRoom.
+func (h *Hub) MuteInRoom(access RoomRole, username, reason string, duration time.Duration) (Mute, error) {
+	return h.muteInRoom(access.Role, access.Username, username, reason, duration)
+}
@@ -0,0 +1,524 @@
+// internal/hub/rooms.go
+package hub
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+)
+
+// defaultRoom is the room played by the main hub, joined by connecting to /ws without a room.
+const defaultRoom = "main"
+
+const (
+	joinCodeLength   = 6
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I lookalikes
+	maxRoomInvites   = 100                                // unused invite tokens per room
+	roomReapInterval = time.Minute                        // how often rooms are checked for idleness
+	roomStopTimeout  = 10 * time.Second                   // how long an idle room's hub gets to stop
+)
+
+// Errors returned by room operations.
+var (
+	ErrRoomNotFound   = errors.New("room not found")
+	ErrRoomExists     = errors.New("room already exists")
+	ErrNotRoomOwner   = errors.New("not the room owner")
+	ErrTooManyRooms   = errors.New("too many rooms")
+	ErrTooManyInvites = errors.New("too many unused invites")
+	// ErrRoomAuth is returned for room creations without the resume token of a player
+	// signed in under a registered name.
+	ErrRoomAuth = errors.New("a resume token of a signed-in player is required")
+	// ErrTooManyOwnedRooms is returned when the player owns max_rooms_per_owner rooms.
+	ErrTooManyOwnedRooms = errors.New("too many rooms owned")
+	// ErrRoomCreateTooSoon is returned when the player created a room less than
+	// room_create_interval_seconds ago.
+	ErrRoomCreateTooSoon = errors.New("created a room too recently, try again later")
+)
+
+var roomNamePattern = regexp.MustCompile(`^[a-z0-9_-]{3,32}$`)
+
+// RoomSettings are chosen by the owner when creating a room.
+type RoomSettings = message.RoomSettings
+
+// RoomInfo describes a room without its secrets.
+type RoomInfo = message.RoomInfo
+
+// RoomCredentials are returned once, to the owner creating the room.
+type RoomCredentials struct {
+	RoomInfo
+	JoinCode   string `json:"join_code,omitempty"` // empty for public rooms
+	OwnerToken string `json:"owner_token"`         // authorizes the owner's actions on the room
+}
+
+// room is a private room played by its own hub. Room hubs keep their rounds in memory
+// only; rewards, rules, attachments and user statistics are shared with the main hub.
+type room struct {
+	settings   RoomSettings
+	createdAt  time.Time
+	joinCode   string
+	ownerToken string
+	hub        *Hub
+
+	mu         sync.Mutex
+	invites    map[string]bool     // unused single-use invite tokens
+	roles      map[string]RoomRole // moderators and spectators by username
+	emptySince time.Time           // since when nobody has been connected, zero while someone is
+}
+
+// roomRegistry holds the rooms of the main hub.
+type roomRegistry struct {
+	mu      sync.RWMutex
+	rooms   map[string]*room
+	created map[string]time.Time // latest room creation by owner usernameKey
+}
+
+func newRoomRegistry() *roomRegistry {
+	return &roomRegistry{rooms: make(map[string]*room), created: make(map[string]time.Time)}
+}
+
+func (r *roomRegistry) get(name string) (*room, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rm, ok := r.rooms[name]
+	return rm, ok
+}
+
+// randomToken returns n random bytes, hex encoded.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newJoinCode returns a short code that is easy to read out and type.
+func newJoinCode() string {
+	b := make([]byte, joinCodeLength)
+	rand.Read(b)
+	for i := range b {
+		b[i] = joinCodeAlphabet[int(b[i])%len(joinCodeAlphabet)]
+	}
+	return string(b)
+}
+
+// info describes the room, its current occupancy and whether a round is running.
+func (rm *room) info() RoomInfo {
+	connected, _ := rm.hub.clients.counts()
+	rm.hub.Mu.RLock()
+	roundActive, roundID := rm.hub.RoundActive, rm.hub.CurrentRoundID
+	rm.hub.Mu.RUnlock()
+	info := RoomInfo{RoomSettings: rm.settings, CreatedAt: rm.createdAt, Connected: connected, RoundActive: roundActive}
+	if roundActive {
+		info.RoundID = roundID
+	}
+	return info
+}
+
+// isOwner checks an owner token in constant time.
+func (rm *room) isOwner(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(rm.ownerToken)) == 1
+}
+
+// admits reports whether code opens the room: the join code or an unused invite token,
+// which is used up.
+func (rm *room) admits(code string) bool {
+	if rm.settings.Public {
+		return true
+	}
+	if code == "" {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(rm.joinCode)) == 1 {
+		return true
+	}
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.invites[code] {
+		delete(rm.invites, code)
+		return true
+	}
+	return false
+}
+
+// CreateRoom creates a room and starts its hub. The owner is the player the resume token
+// was issued to, who must be signed in under a registered name; an owner in settings
+// must name that player. Each player owns at most max_rooms_per_owner rooms and creates
+// one every room_create_interval_seconds. The owner token and join code are only
+// returned here.
+func (h *Hub) CreateRoom(settings RoomSettings, resumeToken string) (RoomCredentials, error) {
+	cfg := h.settings()
+	if h.rooms == nil || cfg.MaxRooms <= 0 {
+		return RoomCredentials{}, errors.New("rooms are disabled")
+	}
+	claims, err := h.checkResumeToken(resumeToken)
+	if err != nil || claims.Guest {
+		return RoomCredentials{}, ErrRoomAuth
+	}
+	if settings.Owner != "" && !h.sameUsername(settings.Owner, claims.Username) {
+		return RoomCredentials{}, errors.New("owner must be the signed-in player")
+	}
+	settings.Owner = claims.Username
+	switch {
+	case h.draining():
+		return RoomCredentials{}, errors.New("server is shutting down")
+	case !roomNamePattern.MatchString(settings.Name) || settings.Name == defaultRoom:
+		return RoomCredentials{}, errors.New("invalid room name: must be 3-32 characters of a-z, 0-9, _ and -")
+	case !h.validUsername(settings.Owner):
+		return RoomCredentials{}, errors.New("invalid owner username")
+	case settings.Capacity < 0 || settings.Capacity > cfg.MaxRoomCapacity:
+		return RoomCredentials{}, fmt.Errorf("capacity must be between 0 and %d", cfg.MaxRoomCapacity)
+	case settings.RoundDurationSeconds < 0 || settings.SubmissionWindowSeconds < 0 || settings.MaxSubmissionsPerRound < 0:
+		return RoomCredentials{}, errors.New("round settings must not be negative")
+	case settings.Encrypted && settings.Public:
+		return RoomCredentials{}, errors.New("only private rooms can be encrypted")
+	}
+	if err := cfg.UsePacingProfile(settings.PacingProfile); err != nil {
+		return RoomCredentials{}, err
+	}
+	if settings.Capacity == 0 {
+		settings.Capacity = cfg.MaxRoomCapacity
+	}
+	if settings.RoundDurationSeconds == 0 {
+		settings.RoundDurationSeconds = cfg.RoundDurationSeconds
+	}
+
+	now := h.clock.Now()
+	owner := h.usernameKey(settings.Owner)
+	interval := time.Duration(cfg.RoomCreateIntervalSeconds) * time.Second
+	h.rooms.mu.Lock()
+	if _, exists := h.rooms.rooms[settings.Name]; exists {
+		h.rooms.mu.Unlock()
+		return RoomCredentials{}, ErrRoomExists
+	}
+	if len(h.rooms.rooms) >= cfg.MaxRooms {
+		h.rooms.mu.Unlock()
+		return RoomCredentials{}, ErrTooManyRooms
+	}
+	if cfg.MaxRoomsPerOwner > 0 && h.roomsOwnedLocked(owner) >= cfg.MaxRoomsPerOwner {
+		h.rooms.mu.Unlock()
+		return RoomCredentials{}, ErrTooManyOwnedRooms
+	}
+	if last, ok := h.rooms.created[owner]; ok && now.Sub(last) < interval {
+		h.rooms.mu.Unlock()
+		return RoomCredentials{}, ErrRoomCreateTooSoon
+	}
+	for name, last := range h.rooms.created {
+		if now.Sub(last) >= interval {
+			delete(h.rooms.created, name)
+		}
+	}
+	if interval > 0 {
+		h.rooms.created[owner] = now
+	}
+	rm := &room{
+		settings:   settings,
+		createdAt:  now,
+		ownerToken: randomToken(32),
+		hub:        h.newRoomHub(settings),
+		invites:    make(map[string]bool),
+		roles:      make(map[string]RoomRole),
+		emptySince: now,
+	}
+	if !settings.Public {
+		rm.joinCode = newJoinCode()
+	}
+	h.rooms.rooms[settings.Name] = rm
+	h.rooms.mu.Unlock()
+
+	go rm.hub.Run()
+	h.Audit(AuditAdminAction, settings.Owner, "Room created", settings.Name)
+	h.Logger.Infof("Room %s created by %s", settings.Name, settings.Owner)
+	info := rm.info()
+	h.announceLobby(map[string]interface{}{
+		"version": "1.0",
+		"type":    "room_created",
+		"room":    info,
+	})
+	return RoomCredentials{RoomInfo: info, JoinCode: rm.joinCode, OwnerToken: rm.ownerToken}, nil
+}
+
+// roomsOwnedLocked counts the rooms of the owner with the given usernameKey. The caller
+// holds h.rooms.mu.
+func (h *Hub) roomsOwnedLocked(owner string) int {
+	owned := 0
+	for _, rm := range h.rooms.rooms {
+		if h.usernameKey(rm.settings.Owner) == owner {
+			owned++
+		}
+	}
+	return owned
+}
+
+// newRoomHub builds the hub that plays a room, with the server's configuration adjusted
+// by the room's settings.
+func (h *Hub) newRoomHub(settings RoomSettings) *Hub {
+	cfg := h.settings()
+	cfg.MaxConnections = settings.Capacity
+	cfg.WaitingRoom = false
+	cfg.SkipIdleRounds = true
+	cfg.AdaptiveRounds = false
+	cfg.TournamentQualifyingRounds = 0
+	cfg.ReplayOnStartupMinutes = 0
+	if settings.PacingProfile != "" {
+		cfg.UsePacingProfile(settings.PacingProfile) // checked by CreateRoom
+	}
+	if settings.RoundDurationSeconds > 0 {
+		cfg.RoundDurationSeconds = settings.RoundDurationSeconds
+	}
+	if settings.SubmissionWindowSeconds > 0 {
+		cfg.SubmissionWindowSeconds = settings.SubmissionWindowSeconds
+	}
+	if settings.MaxSubmissionsPerRound > 0 {
+		cfg.MaxSubmissionsPerRound = settings.MaxSubmissionsPerRound
+	}
+
+	if settings.Encrypted {
+		// The server cannot read encrypted submissions: no options to pick from, no
+		// scores from their content and no rules script.
+		cfg.RoundMode = config.RoundModeFree
+		cfg.WinnerScoring.Enabled = false
+	}
+
+	rh := newHub(cfg, nil, nil, nil, logger.NewLogger("room:"+settings.Name))
+	rh.room = settings.Name
+	rh.parent = h
+	rh.encrypted = settings.Encrypted
+	rh.Rewards = h.Rewards
+	if !settings.Encrypted {
+		rh.Rules = h.Rules
+	}
+	rh.Attachments = h.Attachments
+	rh.inspector = h.inspector
+	rh.upgrades = h.upgrades
+	rh.userStats = h.userStats
+	rh.preferences = h.preferences
+	rh.idgen = h.idgen
+	rh.notices = h.notices
+	rh.search = h.search
+	rh.resumeKeys = h.resumeKeys
+	rh.SetClock(h.clock)
+	rh.rng = h.rng
+	return rh
+}
+
+// Room describes a room.
+func (h *Hub) Room(name string) (RoomInfo, bool) {
+	if h.rooms == nil {
+		return RoomInfo{}, false
+	}
+	rm, ok := h.rooms.get(name)
+	if !ok {
+		return RoomInfo{}, false
+	}
+	return rm.info(), true
+}
+
+// OwnedRoom returns the hub of a room for its owner, who may then kick, ban and end
+// rounds in that room only.
+func (h *Hub) OwnedRoom(name, ownerToken string) (*Hub, error) {
+	if h.rooms == nil {
+		return nil, ErrRoomNotFound
+	}
+	rm, ok := h.rooms.get(name)
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if !rm.isOwner(ownerToken) {
+		return nil, ErrNotRoomOwner
+	}
+	return rm.hub, nil
+}
+
+// RoomOwner returns the owner's username, for audit records of owner actions.
+func (h *Hub) RoomOwner(name string) string {
+	if info, ok := h.Room(name); ok {
+		return info.Owner
+	}
+	return ""
+}
+
+// CreateRoomInvite issues a single-use invite token for a room.
+func (h *Hub) CreateRoomInvite(name, ownerToken string) (string, error) {
+	if _, err := h.OwnedRoom(name, ownerToken); err != nil {
+		return "", err
+	}
+	rm, _ := h.rooms.get(name)
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if len(rm.invites) >= maxRoomInvites {
+		return "", ErrTooManyInvites
+	}
+	token := randomToken(16)
+	rm.invites[token] = true
+	return token, nil
+}
+
+// DeleteRoom stops a room's hub, which ends its round and disconnects its clients, and
+// removes the room.
+func (h *Hub) DeleteRoom(ctx context.Context, name, ownerToken string) error {
+	if _, err := h.OwnedRoom(name, ownerToken); err != nil {
+		return err
+	}
+	if rm, ok := h.rooms.get(name); ok {
+		h.removeRoom(ctx, name, rm, "Room deleted")
+	}
+	return nil
+}
+
+// removeRoom removes rm unless it was removed meanwhile, tells the lobby and stops the
+// room's hub. why is the audit message.
+func (h *Hub) removeRoom(ctx context.Context, name string, rm *room, why string) {
+	h.rooms.mu.Lock()
+	if h.rooms.rooms[name] != rm {
+		h.rooms.mu.Unlock()
+		return
+	}
+	delete(h.rooms.rooms, name)
+	h.rooms.mu.Unlock()
+	h.announceLobby(map[string]interface{}{
+		"version": "1.0",
+		"type":    "room_deleted",
+		"name":    name,
+	})
+
+	if err := rm.hub.Stop(ctx); err != nil {
+		h.Logger.Errorf("Room %s did not stop cleanly: %v", name, err)
+	}
+	h.Audit(AuditAdminAction, rm.settings.Owner, why, name)
+	h.Logger.Infof("%s: %s", why, name)
+}
+
+// runRoomReaper deletes the rooms nobody has been connected to for room_idle_minutes,
+// until ctx is canceled.
+func (h *Hub) runRoomReaper(ctx context.Context) {
+	ticker := h.clock.NewTicker(roomReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			h.reapIdleRooms(ctx, h.clock.Now())
+		}
+	}
+}
+
+// reapIdleRooms deletes the rooms that have been empty for room_idle_minutes by now.
+// A room is empty from its creation until its first client connects.
+func (h *Hub) reapIdleRooms(ctx context.Context, now time.Time) {
+	idle := time.Duration(h.settings().RoomIdleMinutes) * time.Minute
+	if idle <= 0 {
+		return
+	}
+	h.rooms.mu.RLock()
+	rooms := make(map[string]*room, len(h.rooms.rooms))
+	for name, rm := range h.rooms.rooms {
+		rooms[name] = rm
+	}
+	h.rooms.mu.RUnlock()
+
+	for name, rm := range rooms {
+		connected, _ := rm.hub.clients.counts()
+		rm.mu.Lock()
+		switch {
+		case connected > 0:
+			rm.emptySince = time.Time{}
+		case rm.emptySince.IsZero():
+			rm.emptySince = now
+		}
+		expired := connected == 0 && now.Sub(rm.emptySince) >= idle
+		rm.mu.Unlock()
+		if expired {
+			stopCtx, cancel := context.WithTimeout(ctx, roomStopTimeout)
+			h.removeRoom(stopCtx, name, rm, "Room expired")
+			cancel()
+		}
+	}
+}
+
+// Rooms lists every room ordered by name.
+func (h *Hub) Rooms() []RoomInfo {
+	if h.rooms == nil {
+		return nil
+	}
+	h.rooms.mu.RLock()
+	rooms := make([]RoomInfo, 0, len(h.rooms.rooms))
+	for _, rm := range h.rooms.rooms {
+		rooms = append(rooms, rm.info())
+	}
+	h.rooms.mu.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms
+}
+
+// roomHubs returns the hubs of every room.
+func (h *Hub) roomHubs() []*Hub {
+	if h.rooms == nil {
+		return nil
+	}
+	h.rooms.mu.RLock()
+	defer h.rooms.mu.RUnlock()
+	hubs := make([]*Hub, 0, len(h.rooms.rooms))
+	for _, rm := range h.rooms.rooms {
+		hubs = append(hubs, rm.hub)
+	}
+	return hubs
+}
+
+// stopRooms stops and removes every room when the main hub shuts down.
+func (h *Hub) stopRooms(ctx context.Context) {
+	if h.rooms == nil {
+		return
+	}
+	h.rooms.mu.Lock()
+	rooms := h.rooms.rooms
+	h.rooms.rooms = make(map[string]*room)
+	h.rooms.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, rm := range rooms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := rm.hub.Stop(ctx); err != nil {
+				h.Logger.Errorf("Room %s did not stop cleanly: %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// serveRoom hands a WebSocket upgrade for a room to the room's hub once the join code
+// or invite token in the code parameter checks out. Server-wide bans apply in rooms too.
+func (h *Hub) serveRoom(w http.ResponseWriter, r *http.Request, name string) {
+	username := r.URL.Query().Get("username")
+	rm, ok := h.rooms.get(name)
+	if !ok {
+		h.rejectHandshake(r, HandshakeRoomNotFound, username)
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	if username != "" && h.isBanned(username) {
+		h.rejectHandshake(r, HandshakeBanned, username)
+		http.Error(w, "user is banned", http.StatusForbidden)
+		return
+	}
+	if !rm.admits(r.URL.Query().Get("code")) {
+		h.rejectHandshake(r, HandshakeRoomCode, username)
+		http.Error(w, "a valid join code or invite token is required", http.StatusForbidden)
+		return
+	}
+	rm.hub.ServeWs(w, r)
+}
@@ -0,0 +1,126 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+// newRoomsHub returns a main hub that hosts rooms, stopped with the test.
+func newRoomsHub(t *testing.T, cfg config.Config) *Hub {
+	t.Helper()
+	h := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+	h.rooms = newRoomRegistry()
+	t.Cleanup(func() { h.stopRooms(context.Background()) })
+	return h
+}
+
+// playerToken returns a resume token of a player of the main room.
+func playerToken(t *testing.T, h *Hub, username string, guest bool) string {
+	t.Helper()
+	token, err := h.resumeKeys.sign(resumeClaims{
+		Username:  username,
+		Guest:     guest,
+		SessionID: "session-" + username,
+		Room:      defaultRoom,
+		ExpiresAt: h.clock.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func TestCreateRoomAuth(t *testing.T) {
+	h := newRoomsHub(t, config.DefaultConfig())
+
+	tests := []struct {
+		name  string
+		owner string
+		token string
+		err   error
+	}{
+		{"no token", "", "", ErrRoomAuth},
+		{"forged token", "", "not-a-token", ErrRoomAuth},
+		{"guest", "", playerToken(t, h, "guest_red_fox_1", true), ErrRoomAuth},
+		{"someone else as owner", "grace", playerToken(t, h, "ada", false), errors.New("owner must be the signed-in player")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.CreateRoom(RoomSettings{Name: "room-" + tt.owner, Owner: tt.owner}, tt.token)
+			if err == nil || err.Error() != tt.err.Error() {
+				t.Errorf("CreateRoom error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	credentials, err := h.CreateRoom(RoomSettings{Name: "adas-room"}, playerToken(t, h, "ada", false))
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if credentials.Owner != "ada" {
+		t.Errorf("owner = %q, want the player the token was issued to", credentials.Owner)
+	}
+}
+
+func TestCreateRoomLimits(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxRoomsPerOwner = 2
+	cfg.RoomCreateIntervalSeconds = 60
+	h := newRoomsHub(t, cfg)
+	clock := newFakeClock(time.Now())
+	h.SetClock(clock)
+	token := playerToken(t, h, "Ada", false)
+	intervalPassed := func() { clock.Advance(time.Minute) }
+
+	if _, err := h.CreateRoom(RoomSettings{Name: "first"}, token); err != nil {
+		t.Fatalf("first room: %v", err)
+	}
+	if _, err := h.CreateRoom(RoomSettings{Name: "second"}, token); !errors.Is(err, ErrRoomCreateTooSoon) {
+		t.Errorf("room within the interval: error = %v, want ErrRoomCreateTooSoon", err)
+	}
+	if _, err := h.CreateRoom(RoomSettings{Name: "other"}, playerToken(t, h, "grace", false)); err != nil {
+		t.Errorf("another player's room: %v", err)
+	}
+	intervalPassed()
+	if _, err := h.CreateRoom(RoomSettings{Name: "second"}, token); err != nil {
+		t.Fatalf("room after the interval: %v", err)
+	}
+	intervalPassed()
+	if _, err := h.CreateRoom(RoomSettings{Name: "third"}, token); !errors.Is(err, ErrTooManyOwnedRooms) {
+		t.Errorf("room beyond max_rooms_per_owner: error = %v, want ErrTooManyOwnedRooms", err)
+	}
+}
+
+func TestReapIdleRooms(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RoomIdleMinutes = 30
+	h := newRoomsHub(t, cfg)
+	clock := newFakeClock(time.Now())
+	h.SetClock(clock)
+	start := clock.Now()
+	if _, err := h.CreateRoom(RoomSettings{Name: "empty"}, playerToken(t, h, "ada", false)); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if _, err := h.CreateRoom(RoomSettings{Name: "busy"}, playerToken(t, h, "grace", false)); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	busy, _ := h.rooms.get("busy")
+	busy.hub.clients.add(&Client{username: "linus", Send: make(chan []byte, 1)})
+
+	h.reapIdleRooms(context.Background(), start.Add(29*time.Minute))
+	if _, ok := h.Room("empty"); !ok {
+		t.Fatal("room deleted before room_idle_minutes")
+	}
+	h.reapIdleRooms(context.Background(), start.Add(31*time.Minute))
+	if _, ok := h.Room("empty"); ok {
+		t.Error("room empty since its creation was kept past room_idle_minutes")
+	}
+	if _, ok := h.Room("busy"); !ok {
+		t.Error("room with a connected client was deleted")
+	}
+}
@@ -2,35 +2,270 @@
 package hub
 
 import (
+	"context"
 	"time"
 )
 
 const (
-	roundDuration         = 15 * time.Second
+	defaultRoundDuration  = 15 * time.Second
 	countdownStartSeconds = 10
 )
 
-// StartRoundTimer starts the round management timer.
-func (h *Hub) StartRoundTimer() {
-	ticker := time.NewTicker(roundDuration)
-	defer ticker.Stop()
+// Adaptive round tuning: rounds in which fewer than lowParticipation of the connected
+// clients submitted get longer, rounds above highParticipation get shorter, by roundLengthStep.
+const (
+	lowParticipation  = 0.25
+	highParticipation = 0.75
+	roundLengthStep   = 0.2
+)
 
-	// Start first round immediately
-	h.StartRound()
+// Error codes for client messages that arrive too late.
+const (
+	SubmissionsClosedCode = "SUBMISSIONS_CLOSED" // after the submission window, while the round still runs
+	RoundClosedCode       = "ROUND_CLOSED"       // the round ended while the message was being handled
+)
+
+// Round statuses published on the ROUNDS stream.
+const (
+	roundStatusStarted = "started"
+	roundStatusEnded   = "ended"
+	roundStatusEmpty   = "empty"
+)
+
+// StartRoundTimer starts the round management timer. It returns when ctx is canceled.
+func (h *Hub) StartRoundTimer(ctx context.Context) {
+	// Start first round immediately. A round rebuilt from the event streams after a
+	// restart is resumed instead and runs for the rest of its length.
+	wait, resumed := h.remainingRoundTime()
+	if !resumed {
+		h.startRoundIfNeeded()
+		wait = h.currentRoundLength()
+	}
+
+	// End the current round and start a new one once it has run its length. Rounds are
+	// waited out one at a time since adaptive mode changes their length and full rounds
+	// may be cut short.
+	for {
+		if !h.waitRoundEnd(ctx, wait) {
+			return
+		}
+		h.Mu.RLock()
+		roundActive := h.RoundActive
+		h.Mu.RUnlock()
+		if roundActive {
+			// A round short of min_participants may get more time once before it is voided.
+			if grace, extended := h.extendRoundForQuorum(); extended {
+				wait = grace
+				continue
+			}
+			h.EndRound()
+		}
+		if pause := h.roundPause(); pause > 0 && !h.waitRoundEnd(ctx, pause) {
+			return
+		}
+		h.startRoundIfNeeded()
+		wait = h.currentRoundLength()
+	}
+}
+
+// remainingRoundTime returns how long the active round still runs, and false when no
+// round is active.
+func (h *Hub) remainingRoundTime() (time.Duration, bool) {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	if !h.RoundActive {
+		return 0, false
+	}
+	return max(h.roundTiming.EndsAt.Sub(h.clock.Now()), 0), true
+}
+
+// roundTiming holds the deadlines of a round, sent to clients so they can render timers.
+type roundTiming struct {
+	StartedAt          time.Time
+	SubmissionDeadline time.Time
+	EndsAt             time.Time
+	Pacing             string // pacing profile the round was started with, empty for custom timing
+}
 
-	// End the current round and start a new one on each tick.
-	for range ticker.C {
-		h.EndRound()
-		h.StartRound()
+// addTo sets the started_at, submission_deadline and ends_at fields (RFC3339) on a message,
+// and pacing_profile when the round was started with one.
+func (t roundTiming) addTo(message map[string]interface{}) {
+	message["started_at"] = t.StartedAt.UTC().Format(time.RFC3339)
+	message["submission_deadline"] = t.SubmissionDeadline.UTC().Format(time.RFC3339)
+	message["ends_at"] = t.EndsAt.UTC().Format(time.RFC3339)
+	message["duration_seconds"] = int(t.EndsAt.Sub(t.StartedAt).Seconds())
+	if t.Pacing != "" {
+		message["pacing_profile"] = t.Pacing
 	}
 }
 
+// roundDuration returns the configured round length.
+func (h *Hub) roundDuration() time.Duration {
+	seconds := h.settings().RoundDurationSeconds
+	if seconds <= 0 {
+		return defaultRoundDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// roundPause returns how long to wait between the end of a round and the start of the next.
+func (h *Hub) roundPause() time.Duration {
+	return time.Duration(max(h.settings().RoundPauseSeconds, 0)) * time.Second
+}
+
+// roundLengthBounds returns the range adaptive rounds are kept in. Bounds that are unset
+// or inverted fall back to the configured round length.
+func (h *Hub) roundLengthBounds() (time.Duration, time.Duration) {
+	cfg := h.settings()
+	base := h.roundDuration()
+	minLength := time.Duration(cfg.MinRoundDurationSeconds) * time.Second
+	maxLength := time.Duration(cfg.MaxRoundDurationSeconds) * time.Second
+	if minLength <= 0 || minLength > base {
+		minLength = base
+	}
+	if maxLength < base {
+		maxLength = base
+	}
+	return minLength, maxLength
+}
+
+// currentRoundLength returns the length of the running round, or of the next one
+// while no round is active.
+func (h *Hub) currentRoundLength() time.Duration {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	if h.RoundActive {
+		return h.roundTiming.EndsAt.Sub(h.roundTiming.StartedAt)
+	}
+	return h.nextRoundLengthLocked()
+}
+
+// nextRoundLengthLocked returns the length for the next round. Outside adaptive mode this
+// is the configured round length. Callers must hold Mu.
+func (h *Hub) nextRoundLengthLocked() time.Duration {
+	if !h.settings().AdaptiveRounds || h.nextRoundLength <= 0 {
+		return h.roundDuration()
+	}
+	minLength, maxLength := h.roundLengthBounds()
+	return min(max(h.nextRoundLength, minLength), maxLength)
+}
+
+// adaptRoundLength picks the next round's length from the participation in the round
+// that just ended: quiet rounds are lengthened and busy ones shortened within the bounds.
+func (h *Hub) adaptRoundLength(length time.Duration, submissions int) {
+	if !h.settings().AdaptiveRounds {
+		return
+	}
+	connected, _ := h.clients.counts()
+	if connected == 0 {
+		return
+	}
+	participation := float64(submissions) / float64(connected)
+	next := length
+	switch {
+	case participation < lowParticipation:
+		next = time.Duration(float64(length) * (1 + roundLengthStep))
+	case participation > highParticipation:
+		next = time.Duration(float64(length) * (1 - roundLengthStep))
+	}
+	minLength, maxLength := h.roundLengthBounds()
+	next = min(max(next, minLength), maxLength).Round(time.Second)
+
+	h.Mu.Lock()
+	h.nextRoundLength = next
+	h.Mu.Unlock()
+	if next != length {
+		h.Logger.Infof("Participation %.0f%%, next round lasts %v", participation*100, next)
+	}
+}
+
+// submissionWindow returns how long submissions stay open in a round of the given length.
+// It is capped at the round length, which is also the default.
+func (h *Hub) submissionWindow(roundLength time.Duration) time.Duration {
+	window := time.Duration(h.settings().SubmissionWindowSeconds) * time.Second
+	if window <= 0 || window > roundLength {
+		return roundLength
+	}
+	return window
+}
+
+// roundSnapshot is the round a client message is handled against. It is read in one
+// critical section so the round ID and its submission limiter always belong together.
+type roundSnapshot struct {
+	roundID int64
+	active  bool
+	open    bool // submissions are accepted
+	limiter *submissionLimiter
+}
+
+// submissionState reports the current round and whether it still accepts submissions.
+func (h *Hub) submissionState() roundSnapshot {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	return roundSnapshot{
+		roundID: h.CurrentRoundID,
+		active:  h.RoundActive,
+		open:    h.RoundActive && h.clock.Now().Before(h.submissionsCloseAt),
+		limiter: h.limiter.Load(),
+	}
+}
+
+// inRound runs fn if roundID is still the active round, holding the round state read
+// lock so EndRound cannot close the round while fn changes its messages. Whatever fn
+// stores is therefore seen by winner selection, and messages for a round that already
+// ended are rejected instead of landing in the next one. fn must not acquire Mu.
+// It reports whether fn ran.
+func (h *Hub) inRound(roundID int64, fn func()) bool {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	if !h.RoundActive || h.CurrentRoundID != roundID {
+		return false
+	}
+	fn()
+	return true
+}
+
+// startRoundIfNeeded starts the next round unless idle rounds are skipped and nobody is connected,
+// or the hub is shutting down.
+func (h *Hub) startRoundIfNeeded() {
+	if h.draining() {
+		return
+	}
+	if h.settings().SkipIdleRounds {
+		if connected, _ := h.clients.counts(); connected == 0 {
+			h.Mu.Lock()
+			h.roundsIdle = true
+			h.Mu.Unlock()
+			h.Logger.Debug("No clients connected, skipping round")
+			return
+		}
+	}
+	h.StartRound()
+}
+
 // StartRound begins a new message round.
 func (h *Hub) StartRound() {
 	h.Mu.Lock()
 	h.RoundActive = true
-	h.CurrentRoundID = time.Now().Unix()
-	h.MessageLimiter = make(map[string]bool) // Reset submission tracker
+	h.roundsIdle = false
+	now := h.clock.Now()
+	// Round IDs always grow, even across restarts, so rounds never share messages.
+	h.CurrentRoundID = h.idgen.NewRoundID(now, h.CurrentRoundID)
+	length := h.nextRoundLengthLocked()
+	window := h.submissionWindow(length)
+	h.submissionsCloseAt = now.Add(window)
+	h.roundTiming = roundTiming{
+		StartedAt:          now,
+		SubmissionDeadline: h.submissionsCloseAt,
+		EndsAt:             now.Add(length),
+		Pacing:             h.settings().PacingProfile,
+	}
+	timing := h.roundTiming
+	h.limiter.Store(newSubmissionLimiter()) // Reset submission tracker
+	h.roundExtended = false
+	roundID := h.CurrentRoundID
+	choices := h.nextChoicesLocked()
+	h.setRoundChoices(roundID, choices) // before unlocking, so every submission of the round sees its options
 	h.Mu.Unlock()
 
 	// Broadcast round start
@@ -39,54 +274,133 @@ func (h *Hub) StartRound() {
 		"type":    "round_start",
 		"data":    h.CurrentRoundID,
 	}
+	timing.addTo(roundMessage)
+	addRoundChoices(roundMessage, choices)
 
 	h.BroadcastMessage(roundMessage)
+	h.tournamentRoundStarted(roundID)
+	h.seriesRoundStarted(roundID)
+	h.roomChanged(roomUpdateRoundStarted)
 
 	// Publish round start to NATS
-	h.publishRoundStartToNATS()
+	h.publishRoundStartToNATS(timing)
 
 	h.Logger.Infof("Round %d started", h.CurrentRoundID)
 
 	// Start countdown
-	go h.StartCountdown(h.CurrentRoundID)
+	ctx := h.context()
+	h.goWorker(func() { h.StartCountdown(ctx, roundID) })
+
+	if window < length {
+		deadline := timing.SubmissionDeadline
+		h.clock.AfterFunc(window, func() { h.closeSubmissions(roundID, deadline) })
+	}
+	h.scheduleNudge(roundID, window)
+}
+
+// closeSubmissions announces the end of the submission window if the round is still running
+// and its submissions were not closed early at a different deadline.
+// The rest of the round is left for reveal and voting.
+func (h *Hub) closeSubmissions(roundID int64, deadline time.Time) {
+	h.Mu.RLock()
+	current := h.RoundActive && h.CurrentRoundID == roundID && h.submissionsCloseAt.Equal(deadline)
+	h.Mu.RUnlock()
+	if !current {
+		return
+	}
+
+	h.BroadcastMessage(map[string]interface{}{
+		"version": "1.0",
+		"type":    "submissions_closed",
+		"data":    roundID,
+	})
+	h.Logger.Infof("Submissions closed for round %d", roundID)
 }
 
 // EndRound ends the current message round and selects a winner.
+// Rounds without any submission are marked empty: no winner is selected and,
+// unless PublishEmptyRounds is set, no round end event is published. Rounds with
+// fewer participants than min_participants are voided without a winner.
 func (h *Hub) EndRound() {
 	h.Mu.Lock()
+	if !h.RoundActive {
+		// Already ended, e.g. forced by an admin just before the timer fired.
+		h.Mu.Unlock()
+		return
+	}
 	h.RoundActive = false
 	roundID := h.CurrentRoundID
+	length := h.roundTiming.EndsAt.Sub(h.roundTiming.StartedAt)
 	h.Mu.Unlock()
 
+	messages := h.rounds.messages(roundID)
+	empty := len(messages) == 0
+	participants, void := h.belowQuorum(messages)
+	h.adaptRoundLength(length, len(messages))
+
 	// Broadcast round end
 	roundMessage := map[string]interface{}{
 		"version": "1.0",
 		"type":    "round_end",
 		"data":    roundID,
 	}
+	if empty {
+		roundMessage["empty"] = true
+	}
+	if void {
+		roundMessage["void"] = true
+	}
+	if pause := h.roundPause(); pause > 0 {
+		roundMessage["next_round_in_seconds"] = int(pause / time.Second)
+	}
 
 	h.BroadcastMessage(roundMessage)
+	h.roomChanged(roomUpdateRoundEnded)
+
+	if empty {
+		if h.settings().PublishEmptyRounds {
+			h.publishRoundEndToNATS(roundID, roundStatusEmpty)
+		}
+		h.recordRoundSummary(summarizeRound(roundID, messages, "", h.clock.Now()))
+		h.rememberRound(roundID, messages, nil, nil, nil)
+		h.tournamentRoundWon(roundID, "")
+		h.recordCooldown(roundID, "")
+		h.seriesRoundWon(roundID, "")
+		h.Logger.Infof("Round %d ended without participants", roundID)
+		return
+	}
+	if void {
+		h.voidRound(roundID, messages, participants)
+		return
+	}
 
 	// Publish round end to NATS
-	h.publishRoundEndToNATS(roundID)
+	h.publishRoundEndToNATS(roundID, roundStatusEnded)
 
 	h.Logger.Infof("Round %d ended", roundID)
 
 	// Select and announce winner (simplified random selection)
-	go h.SelectWinner(roundID)
+	h.goTask(func() { h.SelectWinner(roundID) })
 }
 
-// StartCountdown sends countdown messages to clients.
-func (h *Hub) StartCountdown(roundID int64) {
+// StartCountdown sends countdown messages to clients. It returns early when ctx is canceled.
+func (h *Hub) StartCountdown(ctx context.Context, roundID int64) {
+	ticker := h.clock.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
 	// Countdown text updates disabled per UI simplification request (graphical timer only)
 	for i := countdownStartSeconds; i >= 1; i-- {
 		// Maintain timing alignment without broadcasting messages
-		time.Sleep(1 * time.Second)
-		h.Mu.Lock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		h.Mu.RLock()
 		if !h.RoundActive || h.CurrentRoundID != roundID {
-			h.Mu.Unlock()
+			h.Mu.RUnlock()
 			return
 		}
-		h.Mu.Unlock()
+		h.Mu.RUnlock()
 	}
 }
@@ -0,0 +1,58 @@
+// internal/hub/rules.go
+package hub
+
+import (
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+	"github.com/erilali/internal/rules"
+)
+
+const defaultRulesTimeout = 100 * time.Millisecond
+
+// RuleRejectedCode is the error code for submissions the rules script rejected.
+const RuleRejectedCode = "RULE_REJECTED"
+
+// newRules loads the configured rules script, or returns nil when none is configured
+// or it fails to load.
+func newRules(cfg config.Config, logger *logger.Logger) rules.Rules {
+	if cfg.RulesScript == "" {
+		return nil
+	}
+	timeout := time.Duration(cfg.RulesTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultRulesTimeout
+	}
+	script, err := rules.NewLuaRules(cfg.RulesScript, timeout)
+	if err != nil {
+		logger.Errorf("Error loading rules script, using the default rules: %v", err)
+		return nil
+	}
+	logger.Infof("Loaded rules script %s", cfg.RulesScript)
+	return script
+}
+
+// checkRules runs a submission or edit through the rules script. Script errors are
+// logged and the submission accepted, so a broken script does not stop the game.
+func (h *Hub) checkRules(client *Client, roundID int64, submission message.Submission) (bool, string) {
+	if h.Rules == nil {
+		return true, ""
+	}
+	ok, reason, err := h.Rules.Validate(rules.Submission{RoundID: roundID, Username: client.Username(), Submission: submission})
+	if err != nil {
+		h.Logger.Errorf("Rules script failed to validate a submission from %s: %v", client.Username(), err)
+		return true, ""
+	}
+	return ok, reason
+}
+
+// pickWinner selects the winning message among candidates and returns the odds it was
+// drawn with. With winner_scoring enabled the odds follow scores, see scoreSubmissions;
+// otherwise the highest score from the rules script wins, ties broken at random, or a
+// random message without scores.
+func (h *Hub) pickWinner(roundID int64, candidates []RoundMessage, scores map[string]float64) (RoundMessage, drawOdds) {
+	odds := h.winnerOdds(roundID, candidates, scores)
+	return h.draw(candidates, odds), odds
+}
@@ -0,0 +1,80 @@
+// internal/hub/sanitize.go
+package hub
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/erilali/internal/config"
+)
+
+// htmlTagPattern matches HTML tags and comments, including unterminated ones at the end.
+var htmlTagPattern = regexp.MustCompile(`<!--.*?(-->|$)|</?[A-Za-z!][^>]*(>|$)`)
+
+const (
+	zeroWidthJoiner        = '\u200D'
+	emojiVariationSelector = '\uFE0F'
+)
+
+// invisibleRunes are characters that render as nothing but can hide content or reorder
+// text: zero-width characters, the byte order mark and bidirectional overrides.
+var invisibleRunes = map[rune]bool{
+	'\u200B': true,                                                                 // zero width space
+	'\u200C': true,                                                                 // zero width non-joiner
+	'\u200D': true,                                                                 // zero width joiner
+	'\u2060': true,                                                                 // word joiner
+	'\uFEFF': true,                                                                 // zero width no-break space / byte order mark
+	'\u00AD': true,                                                                 // soft hyphen
+	'\u202A': true, '\u202B': true, '\u202C': true, '\u202D': true, '\u202E': true, // bidi embeddings and overrides
+	'\u2066': true, '\u2067': true, '\u2068': true, '\u2069': true, // bidi isolates
+}
+
+// stripInvisible removes control characters other than newlines and tabs, invisible
+// characters and invalid UTF-8. Zero width joiners inside emoji sequences such as
+// family or profession emoji are kept.
+func stripInvisible(content string) string {
+	runes := []rune(strings.ToValidUTF8(content, ""))
+	var b strings.Builder
+	b.Grow(len(content))
+	for i, r := range runes {
+		switch {
+		case r == '\n' || r == '\t':
+		case r == zeroWidthJoiner && i > 0 && i+1 < len(runes) && isEmojiPart(runes[i-1]) && isEmojiPart(runes[i+1]):
+		case r == unicode.ReplacementChar, unicode.IsControl(r), invisibleRunes[r]:
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isEmojiPart reports whether r can appear around a zero width joiner in an emoji sequence:
+// a pictograph, a skin tone modifier or the emoji variation selector.
+func isEmojiPart(r rune) bool {
+	return unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r) || r == emojiVariationSelector
+}
+
+// sanitizeContent makes submitted text safe to render in web frontends. It returns the
+// text as it should be measured and the text as it should be stored, and false for an
+// unknown mode, which the startup self-check flags as a configuration error. Escaping
+// happens last so entities do not count against the length limit.
+//
+//   - off: content is kept verbatim
+//   - escape: invisible characters are removed and HTML special characters escaped
+//   - strict: HTML tags and comments are removed as well before escaping
+func sanitizeContent(content, mode string) (plain, stored string, ok bool) {
+	switch mode {
+	case config.SanitizeOff:
+		return content, content, true
+	case config.SanitizeEscape:
+		content = stripInvisible(content)
+	case config.SanitizeStrict:
+		content = htmlTagPattern.ReplaceAllString(stripInvisible(content), "")
+	default:
+		return "", "", false
+	}
+	content = strings.TrimSpace(content)
+	return content, html.EscapeString(content), true
+}
@@ -0,0 +1,95 @@
+package hub
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erilali/internal/config"
+)
+
+func TestSanitizeContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		content string
+		want    string
+	}{
+		{"zwj family emoji kept", config.SanitizeEscape, "hi \U0001F468\u200D\U0001F469\u200D\U0001F467", "hi \U0001F468\u200D\U0001F469\u200D\U0001F467"},
+		{"zwj profession emoji with skin tone kept", config.SanitizeEscape, "\U0001F469\U0001F3FD\u200D\U0001F4BB", "\U0001F469\U0001F3FD\u200D\U0001F4BB"},
+		{"zwj with variation selector kept", config.SanitizeEscape, "❤\uFE0F\u200D\U0001F525", "❤\uFE0F\u200D\U0001F525"},
+		{"zwj between letters removed", config.SanitizeEscape, "a\u200Db", "ab"},
+		{"zwj at the end removed", config.SanitizeEscape, "\U0001F468\u200D", "\U0001F468"},
+		{"bidi override removed", config.SanitizeEscape, "invoice\u202Egpj.exe", "invoicegpj.exe"},
+		{"bidi embeddings and isolates removed", config.SanitizeEscape, "\u202Aa\u202Cb\u2066c\u2069", "abc"},
+		{"byte order mark removed", config.SanitizeEscape, "\uFEFFhello", "hello"},
+		{"soft hyphen removed", config.SanitizeEscape, "hel\u00ADlo", "hello"},
+		{"zero width space removed", config.SanitizeEscape, "he\u200Bllo", "hello"},
+		{"control characters removed", config.SanitizeEscape, "a\x00b\x1bc\x7f", "abc"},
+		{"newlines and tabs kept", config.SanitizeEscape, "a\nb\tc", "a\nb\tc"},
+		{"invalid utf-8 removed", config.SanitizeEscape, "ab\xffcd\xc3", "abcd"},
+		{"replacement character removed", config.SanitizeEscape, "a\uFFFDb", "ab"},
+		{"surrounding space trimmed", config.SanitizeEscape, "  \u200Bhello\n ", "hello"},
+		{"html escaped", config.SanitizeEscape, `<b>"fish" & chips</b>`, "&lt;b&gt;&#34;fish&#34; &amp; chips&lt;/b&gt;"},
+		{"apostrophe escaped", config.SanitizeEscape, "it's", "it&#39;s"},
+		{"strict removes tags", config.SanitizeStrict, "<b>bold</b> <i>move</i>", "bold move"},
+		{"strict removes comments", config.SanitizeStrict, "a<!-- hidden -->b", "ab"},
+		{"strict removes unterminated tag", config.SanitizeStrict, "hello <script src=x", "hello"},
+		{"strict removes unterminated comment", config.SanitizeStrict, "hello <!-- rest", "hello"},
+		{"strict escapes comparisons", config.SanitizeStrict, "1 < 2 & 3 > 2", "1 &lt; 2 &amp; 3 &gt; 2"},
+		{"strict removes invisible characters", config.SanitizeStrict, "\uFEFF<b>a\u202Eb</b>", "ab"},
+		{"off keeps content verbatim", config.SanitizeOff, " <b>\u202Ea\xff</b> ", " <b>\u202Ea\xff</b> "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, ok := sanitizeContent(tt.content, tt.mode)
+			if !ok {
+				t.Fatalf("sanitizeContent(%q, %q) refused a known mode", tt.content, tt.mode)
+			}
+			if got != tt.want {
+				t.Errorf("sanitizeContent(%q, %q) = %q, want %q", tt.content, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeContentUnknownMode(t *testing.T) {
+	for _, mode := range []string{"", "Escape", "html", "none"} {
+		if _, got, ok := sanitizeContent("hello", mode); ok {
+			t.Errorf("sanitizeContent with mode %q = %q, want it refused", mode, got)
+		}
+		if _, ok := validateMessageContent("hello", mode, 500); ok {
+			t.Errorf("validateMessageContent accepted text under unknown mode %q", mode)
+		}
+	}
+}
+
+func TestValidateMessageContentLength(t *testing.T) {
+	const maxLength = 500
+	tests := []struct {
+		name    string
+		mode    string
+		content string
+		valid   bool
+	}{
+		{"500 ascii characters", config.SanitizeEscape, strings.Repeat("a", 500), true},
+		{"501 ascii characters", config.SanitizeEscape, strings.Repeat("a", 501), false},
+		{"500 two-byte runes", config.SanitizeEscape, strings.Repeat("é", 500), true},
+		{"501 two-byte runes", config.SanitizeEscape, strings.Repeat("é", 501), false},
+		{"500 four-byte runes", config.SanitizeEscape, strings.Repeat("\U0001F600", 500), true},
+		{"501 four-byte runes", config.SanitizeEscape, strings.Repeat("\U0001F600", 501), false},
+		{"500 html special characters, counted before escaping", config.SanitizeEscape, strings.Repeat("<", 500), true},
+		{"invisible characters do not count", config.SanitizeEscape, strings.Repeat("a", 500) + strings.Repeat("\u200B", 10), true},
+		{"removed tags do not count", config.SanitizeStrict, "<b>" + strings.Repeat("a", 500) + "</b>", true},
+		{"500 runes verbatim", config.SanitizeOff, strings.Repeat("é", 500), true},
+		{"501 runes verbatim", config.SanitizeOff, strings.Repeat("é", 501), false},
+		{"only invisible characters", config.SanitizeEscape, "\u200B\uFEFF", false},
+		{"only whitespace verbatim", config.SanitizeOff, "   ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, valid := validateMessageContent(tt.content, tt.mode, maxLength); valid != tt.valid {
+				t.Errorf("validateMessageContent valid = %v, want %v", valid, tt.valid)
+			}
+		})
+	}
+}
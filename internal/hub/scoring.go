@@ -0,0 +1,107 @@
+// internal/hub/scoring.go
+package hub
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/erilali/internal/config"
+)
+
+// scoreSubmissions scores the submissions of a round for weighted winner selection and
+// returns the scores by message ID, or nil when winner_scoring is disabled. A score is
+//
+//	base + length_weight*length + originality_weight*originality + plugin_weight*plugin
+//
+// where length grows to 1 at length_target characters, originality is 1 minus the highest
+// word overlap with a submission of the rounds held in memory, and plugin is the rules
+// script's score relative to the best one of the round. Each part lies between 0 and 1.
+func (h *Hub) scoreSubmissions(roundID int64, messages []RoundMessage) map[string]float64 {
+	cfg := h.settings().WinnerScoring
+	if !cfg.Enabled || len(messages) == 0 {
+		return nil
+	}
+
+	var previous []map[string]bool
+	if cfg.OriginalityWeight > 0 {
+		for _, round := range h.recent.all() {
+			if round.RoundID == roundID {
+				continue
+			}
+			for _, msg := range round.Messages {
+				previous = append(previous, wordSet(msg.Message))
+			}
+		}
+	}
+	plugin := h.pluginScores(roundID, messages, cfg)
+
+	scores := make(map[string]float64, len(messages))
+	for i, msg := range messages {
+		score := cfg.Base
+		if cfg.LengthWeight > 0 && cfg.LengthTarget > 0 {
+			score += cfg.LengthWeight * min(float64(utf8.RuneCountInString(msg.Message))/float64(cfg.LengthTarget), 1)
+		}
+		if cfg.OriginalityWeight > 0 {
+			words, overlap := wordSet(msg.Message), 0.0
+			for _, other := range previous {
+				overlap = max(overlap, jaccard(words, other))
+			}
+			score += cfg.OriginalityWeight * (1 - overlap)
+		}
+		if plugin != nil {
+			score += cfg.PluginWeight * plugin[i]
+		}
+		scores[msg.ID] = max(score, 0)
+	}
+	return scores
+}
+
+// pluginScores returns the rules script's scores scaled to the best one of the round,
+// negative scores counting as 0, or nil when there is no script score to weigh in.
+func (h *Hub) pluginScores(roundID int64, messages []RoundMessage, cfg config.WinnerScoring) []float64 {
+	if h.Rules == nil || cfg.PluginWeight <= 0 {
+		return nil
+	}
+	raw, err := h.Rules.Score(roundID, messages)
+	if err != nil {
+		h.Logger.Errorf("Rules script failed to score round %d, scoring without it: %v", roundID, err)
+		return nil
+	}
+	if raw == nil {
+		return nil
+	}
+	best := 0.0
+	for _, score := range raw {
+		best = max(best, score)
+	}
+	scaled := make([]float64, len(raw))
+	if best > 0 {
+		for i, score := range raw {
+			scaled[i] = max(score, 0) / best
+		}
+	}
+	return scaled
+}
+
+// wordSet returns the lowercase words of a text.
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		words[word] = true
+	}
+	return words
+}
+
+// jaccard returns the share of words two sets have in common, from 0 to 1.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for word := range a {
+		if b[word] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,553 @@
+// internal/hub/search.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	roundTagsBucket    = "ROUND_TAGS"
+	maxRoundTags       = 16
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+	// searchLoadTimeout bounds reading the most recent archived rounds into the index at
+	// startup.
+	searchLoadTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrSearchDisabled is returned by Search when search_index_rounds is 0.
+	ErrSearchDisabled = errors.New("search is disabled")
+	// ErrEmptySearch is returned for queries without a tag, username or text.
+	ErrEmptySearch = errors.New("search needs a tag, username or text")
+	// ErrInvalidTag is returned for tags that are not 1-64 characters of a-z, 0-9 and
+	// ':', '_', '.' or '-', or for more than maxRoundTags tags.
+	ErrInvalidTag = fmt.Errorf("tags are 1-64 characters of a-z, 0-9, ':', '_', '.' or '-', at most %d per round", maxRoundTags)
+	// ErrRoundNotTaggable is returned when tagging a round that is neither active nor
+	// held in the search index.
+	ErrRoundNotTaggable = errors.New("round is neither active nor indexed")
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9:_.-]{0,63}$`)
+
+// SearchQuery selects finished rounds by tag and their messages by author and text.
+// Every given criterion must match.
+type SearchQuery struct {
+	Tags     []string // the round carries every tag
+	Username string   // messages by this user
+	Text     string   // messages containing every word of the text
+	Limit    int      // most recent rounds returned, defaultSearchLimit when 0
+}
+
+// SearchResult lists the matching rounds, most recent first.
+type SearchResult struct {
+	Rounds        []SearchRound `json:"rounds"`
+	Matches       int           `json:"matches"`        // messages matched in the listed rounds
+	Truncated     bool          `json:"truncated"`      // more rounds matched than the limit
+	IndexedRounds int           `json:"indexed_rounds"` // rounds the search covered
+}
+
+// SearchRound is a matching round with its messages that matched the username and text.
+// Queries by tag alone list no messages.
+type SearchRound struct {
+	RoundID  int64          `json:"round_id"`
+	Room     string         `json:"room"`
+	Tags     []string       `json:"tags"`
+	EndedAt  time.Time      `json:"ended_at"`
+	Winner   string         `json:"winner,omitempty"`
+	Messages []RoundMessage `json:"messages,omitempty"`
+}
+
+// roundKey identifies a round across rooms, whose round IDs may coincide.
+type roundKey struct {
+	room    string
+	roundID int64
+}
+
+// kvKey returns the key of the round's tags in the key-value bucket.
+func (k roundKey) kvKey() string {
+	return k.room + "." + strconv.FormatInt(k.roundID, 10)
+}
+
+// indexedRound is a finished round held in the search index.
+type indexedRound struct {
+	endedAt  time.Time
+	winner   string
+	messages []RoundMessage
+	terms    []string // distinct words of the messages, to unlink the round on eviction
+}
+
+// searchIndex is an in-memory inverted index over the messages of the most recent
+// finished rounds of every room, together with the rounds' tags. Each instance indexes
+// the rounds it played and, at startup, the archived rounds of every instance. Tags set
+// by admins are persisted in a JetStream key-value bucket when available, which every
+// instance watches. Encrypted submissions are not indexed.
+type searchIndex struct {
+	mu     sync.RWMutex
+	size   int // rounds kept; 0 disables the index
+	rounds map[roundKey]*indexedRound
+	order  []roundKey                       // indexed rounds, oldest first
+	terms  map[string]map[roundKey]struct{} // word to the rounds containing it
+	tags   map[roundKey][]string            // tags set on active or indexed rounds
+	kv     nats.KeyValue                    // nil without JetStream
+	logger *logger.Logger
+}
+
+func newMemorySearchIndex(size int) *searchIndex {
+	return &searchIndex{
+		size:   max(size, 0),
+		rounds: make(map[roundKey]*indexedRound),
+		terms:  make(map[string]map[roundKey]struct{}),
+		tags:   make(map[roundKey][]string),
+	}
+}
+
+// newSearchIndex opens the bucket of round tags. Without JetStream tags set by admins are
+// lost on restart.
+func newSearchIndex(js nats.JetStreamContext, bucket string, size int, logger *logger.Logger) *searchIndex {
+	s := newMemorySearchIndex(size)
+	s.logger = logger
+	if size <= 0 || js == nil {
+		return s
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Round tags set by admins, by room and round ID",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening round tags bucket, tags are kept in memory only: %v", err)
+		return s
+	}
+	s.kv = kv
+	return s
+}
+
+// normalizeTags lowercases and trims tags and drops duplicates. It fails with
+// ErrInvalidTag on malformed tags or too many of them.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, ErrInvalidTag
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxRoundTags {
+		return nil, ErrInvalidTag
+	}
+	return normalized, nil
+}
+
+// searchTerms splits text into lowercase words of letters and digits.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tagsOf returns the tags set on a round, here or in the bucket by another instance, or
+// else defaults.
+func (s *searchIndex) tagsOf(key roundKey, defaults []string) []string {
+	s.mu.RLock()
+	tags, ok := s.tags[key]
+	s.mu.RUnlock()
+	if !ok {
+		tags, ok = s.storedTags(key)
+	}
+	if !ok {
+		tags, _ = normalizeTags(defaults)
+	}
+	return tags
+}
+
+// withRoomTag returns tags followed by the tag every round of a room carries.
+func withRoomTag(tags []string, room string) []string {
+	return append(slices.Clone(tags), "room:"+room)
+}
+
+// setTags replaces the tags of a round and persists them.
+func (s *searchIndex) setTags(key roundKey, tags []string) error {
+	if s.kv != nil {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		if _, err := s.kv.Put(key.kvKey(), data); err != nil {
+			return fmt.Errorf("storing tags of round %d: %w", key.roundID, err)
+		}
+	}
+	s.mu.Lock()
+	s.tags[key] = tags
+	s.mu.Unlock()
+	return nil
+}
+
+// watchTags applies the tags other instances set on rounds this instance has indexed
+// until ctx is done. Tags of other rounds are read from the bucket when they are
+// indexed, see tagsOf.
+func (s *searchIndex) watchTags(ctx context.Context) {
+	if s.kv == nil {
+		return
+	}
+	watcher, err := s.kv.WatchAll(nats.Context(ctx), nats.UpdatesOnly())
+	if err != nil {
+		s.logger.Errorf("Error watching round tags, tags set on other instances apply to new rounds only: %v", err)
+		return
+	}
+	defer watcher.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-watcher.Updates():
+			if !ok {
+				return
+			}
+			if entry == nil {
+				continue
+			}
+			key, ok := parseRoundKey(entry.Key())
+			if !ok || entry.Operation() != nats.KeyValuePut {
+				continue
+			}
+			var tags []string
+			if err := json.Unmarshal(entry.Value(), &tags); err != nil {
+				continue
+			}
+			s.mu.Lock()
+			if _, indexed := s.rounds[key]; indexed {
+				s.tags[key] = tags
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// parseRoundKey is the inverse of roundKey.kvKey.
+func parseRoundKey(kvKey string) (roundKey, bool) {
+	dot := strings.LastIndex(kvKey, ".")
+	if dot < 0 {
+		return roundKey{}, false
+	}
+	roundID, err := strconv.ParseInt(kvKey[dot+1:], 10, 64)
+	if err != nil {
+		return roundKey{}, false
+	}
+	return roundKey{room: kvKey[:dot], roundID: roundID}, true
+}
+
+// storedTags reads tags an admin set on a round from the bucket.
+func (s *searchIndex) storedTags(key roundKey) ([]string, bool) {
+	if s.kv == nil {
+		return nil, false
+	}
+	entry, err := s.kv.Get(key.kvKey())
+	if err != nil {
+		if !errors.Is(err, nats.ErrKeyNotFound) {
+			s.logger.Errorf("Error reading tags of round %d: %v", key.roundID, err)
+		}
+		return nil, false
+	}
+	var tags []string
+	if err := json.Unmarshal(entry.Value(), &tags); err != nil {
+		return nil, false
+	}
+	return tags, true
+}
+
+// add indexes a finished round with the tags it carries, replacing an earlier entry of
+// the same round, and evicts the rounds that ended first beyond the index size. Rounds
+// are kept in the order they ended, so archived rounds loaded after the start fall in
+// behind the rounds played since.
+func (s *searchIndex) add(key roundKey, messages []RoundMessage, winner *RoundMessage, endedAt time.Time, tags []string) {
+	if s.size == 0 {
+		return
+	}
+	round := &indexedRound{endedAt: endedAt}
+	if winner != nil {
+		round.winner = winner.Username
+	}
+	seen := make(map[string]bool)
+	for _, msg := range messages {
+		if msg.Encrypted {
+			continue
+		}
+		round.messages = append(round.messages, msg)
+		for _, term := range searchTerms(msg.Message) {
+			if !seen[term] {
+				seen[term] = true
+				round.terms = append(round.terms, term)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rounds[key]; ok {
+		s.unlink(key)
+		s.order = slices.DeleteFunc(s.order, func(k roundKey) bool { return k == key })
+	}
+	s.rounds[key] = round
+	at := sort.Search(len(s.order), func(i int) bool { return s.rounds[s.order[i]].endedAt.After(endedAt) })
+	s.order = slices.Insert(s.order, at, key)
+	if tags != nil {
+		s.tags[key] = tags
+	}
+	for _, term := range round.terms {
+		if s.terms[term] == nil {
+			s.terms[term] = make(map[roundKey]struct{})
+		}
+		s.terms[term][key] = struct{}{}
+	}
+	for len(s.order) > s.size {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		s.unlink(oldest)
+		delete(s.rounds, oldest)
+		delete(s.tags, oldest)
+	}
+}
+
+// unlink removes a round from the word index. Callers must hold mu.
+func (s *searchIndex) unlink(key roundKey) {
+	for _, term := range s.rounds[key].terms {
+		delete(s.terms[term], key)
+		if len(s.terms[term]) == 0 {
+			delete(s.terms, term)
+		}
+	}
+}
+
+// indexed reports whether a round is held in the index.
+func (s *searchIndex) indexed(key roundKey) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.rounds[key]
+	return ok
+}
+
+// remove drops a submission from an indexed round. Its words stay linked to the round,
+// which search then finds without a matching message.
+func (s *searchIndex) remove(key roundKey, messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if round, ok := s.rounds[key]; ok {
+		round.messages = slices.DeleteFunc(round.messages, func(msg RoundMessage) bool { return msg.ID == messageID })
+	}
+}
+
+// eraseUser drops the submissions match accepts from every indexed round and anonymizes
+// the rounds they won.
+func (s *searchIndex) eraseUser(match func(username string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, round := range s.rounds {
+		round.messages = slices.DeleteFunc(round.messages, func(msg RoundMessage) bool { return match(msg.Username) })
+		if match(round.winner) {
+			round.winner = ErasedUsername
+		}
+	}
+}
+
+// search runs a query, matching usernames with sameUser.
+func (s *searchIndex) search(q SearchQuery, sameUser func(a, b string) bool) (SearchResult, error) {
+	if s.size == 0 {
+		return SearchResult{}, ErrSearchDisabled
+	}
+	tags, err := normalizeTags(q.Tags)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	terms := searchTerms(q.Text)
+	if len(tags) == 0 && q.Username == "" && len(terms) == 0 {
+		return SearchResult{}, ErrEmptySearch
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := SearchResult{Rounds: []SearchRound{}, IndexedRounds: len(s.rounds)}
+	for i := len(s.order) - 1; i >= 0; i-- {
+		key := s.order[i]
+		round := s.rounds[key]
+		if !s.containsTerms(key, terms) {
+			continue
+		}
+		roundTags := withRoomTag(s.tags[key], key.room)
+		if !containsAll(roundTags, tags) {
+			continue
+		}
+		var messages []RoundMessage
+		if q.Username != "" || len(terms) > 0 {
+			for _, msg := range round.messages {
+				if (q.Username == "" || sameUser(msg.Username, q.Username)) && containsAll(searchTerms(msg.Message), terms) {
+					messages = append(messages, msg)
+				}
+			}
+			if len(messages) == 0 {
+				continue
+			}
+		}
+		if len(result.Rounds) == limit {
+			result.Truncated = true
+			break
+		}
+		result.Matches += len(messages)
+		result.Rounds = append(result.Rounds, SearchRound{
+			RoundID:  key.roundID,
+			Room:     key.room,
+			Tags:     roundTags,
+			EndedAt:  round.endedAt,
+			Winner:   round.winner,
+			Messages: messages,
+		})
+	}
+	return result, nil
+}
+
+// containsTerms reports whether a round contains every word. Callers must hold mu.
+func (s *searchIndex) containsTerms(key roundKey, terms []string) bool {
+	for _, term := range terms {
+		if _, ok := s.terms[term][key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexRound adds a finished round of this hub to the search index, tagged with the tags
+// set on it or else round_tags.
+func (h *Hub) indexRound(roundID int64, messages []RoundMessage, winner *RoundMessage, endedAt time.Time) {
+	key := roundKey{room: h.room, roundID: roundID}
+	h.search.add(key, messages, winner, endedAt, h.search.tagsOf(key, h.settings().RoundTags))
+}
+
+// Search finds finished rounds of every room by tag, and their messages by author and
+// text, among the search_index_rounds most recent rounds.
+func (h *Hub) Search(q SearchQuery) (SearchResult, error) {
+	return h.search.search(q, h.sameUsername)
+}
+
+// SetRoundTags replaces the tags of a round of a room, "" for the main room, and records
+// the change in the audit log. The round must be active or held in the search index.
+// The room's own tag is always added and need not be given.
+func (h *Hub) SetRoundTags(room string, roundID int64, tags []string, actor string) ([]string, error) {
+	if h.search.size == 0 {
+		return nil, ErrSearchDisabled
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	target := h
+	if room != "" && room != h.room {
+		rm, ok := h.rooms.get(room)
+		if !ok {
+			return nil, ErrRoomNotFound
+		}
+		target = rm.hub
+	}
+	key := roundKey{room: target.room, roundID: roundID}
+	target.Mu.RLock()
+	active := target.RoundActive && target.CurrentRoundID == roundID
+	target.Mu.RUnlock()
+	if !active && !h.search.indexed(key) {
+		return nil, ErrRoundNotTaggable
+	}
+	if err := h.search.setTags(key, tags); err != nil {
+		return nil, err
+	}
+	h.Audit(AuditAdminAction, "", fmt.Sprintf("Round %d tagged by %s", roundID, actor), strings.Join(tags, ","))
+	return withRoomTag(tags, key.room), nil
+}
+
+// loadSearchIndex fills the search index with the most recent archived rounds, so search
+// covers rounds played before a restart. Tags set by admins take precedence over those
+// the archive recorded.
+func (h *Hub) loadSearchIndex(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, searchLoadTimeout)
+	defer cancel()
+	keys, err := h.archiver.store.List(ctx, h.archiver.prefix)
+	if err != nil {
+		h.Logger.Errorf("Error listing archived rounds, search covers new rounds only: %v", err)
+		return
+	}
+	roundIDs := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		name := strings.TrimSuffix(key[strings.LastIndex(key, "/")+1:], ".json")
+		if id, err := strconv.ParseInt(name, 10, 64); err == nil {
+			roundIDs[key] = id
+		}
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool { _, ok := roundIDs[key]; return !ok })
+	sort.Slice(keys, func(i, j int) bool { return roundIDs[keys[i]] < roundIDs[keys[j]] })
+	if len(keys) > h.search.size {
+		keys = keys[len(keys)-h.search.size:]
+	}
+
+	loaded := 0
+	for _, objectKey := range keys {
+		data, err := h.archiver.store.Get(ctx, objectKey)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			h.Logger.Warnf("Skipping archive %s in the search index: %v", objectKey, err)
+			continue
+		}
+		var record RoundArchive
+		if err := json.Unmarshal(data, &record); err != nil {
+			h.Logger.Warnf("Skipping archive %s in the search index: %v", objectKey, err)
+			continue
+		}
+		key := roundKey{room: record.Room, roundID: record.RoundID}
+		if h.search.indexed(key) {
+			continue // finished since the start, the index already has the latest
+		}
+		tags, ok := h.search.storedTags(key)
+		if !ok {
+			tags = record.Tags
+		}
+		endedAt := record.ArchivedAt
+		if record.Stats != nil {
+			endedAt = record.Stats.EndedAt
+		}
+		h.search.add(key, record.Messages, record.Winner, endedAt, tags)
+		loaded++
+	}
+	h.Logger.Infof("Search index loaded %d archived rounds", loaded)
+}
@@ -0,0 +1,191 @@
+package hub
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tooMany := make([]string, maxRoundTags+1)
+	for i := range tooMany {
+		tooMany[i] = "tag" + string(rune('a'+i))
+	}
+
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+		err  error
+	}{
+		{"lowercased and trimmed", []string{" Theme:Space ", "EVENT:launch"}, []string{"theme:space", "event:launch"}, nil},
+		{"duplicates dropped", []string{"a", "A", " a"}, []string{"a"}, nil},
+		{"punctuation allowed", []string{"v1.2_beta-3"}, []string{"v1.2_beta-3"}, nil},
+		{"none", nil, []string{}, nil},
+		{"empty tag", []string{""}, nil, ErrInvalidTag},
+		{"leading punctuation", []string{":space"}, nil, ErrInvalidTag},
+		{"space inside", []string{"theme space"}, nil, ErrInvalidTag},
+		{"too long", []string{strings.Repeat("a", 65)}, nil, ErrInvalidTag},
+		{"too many", tooMany, nil, ErrInvalidTag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.tags)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("tags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestSearchIndex returns an index of size rounds holding three rounds, which ended in
+// order: rounds 1 and 2 of the main room tagged theme:space and theme:sea, and round 3
+// of the lobby room without tags.
+func newTestSearchIndex(size int) *searchIndex {
+	s := newMemorySearchIndex(size)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.add(roundKey{roundID: 1}, []RoundMessage{
+		{ID: "m1", Username: "Ada", Message: "Rockets to the Moon"},
+		{ID: "m2", Username: "grace", Message: "moon base"},
+	}, &RoundMessage{ID: "m1", Username: "Ada"}, start, []string{"theme:space"})
+	s.add(roundKey{roundID: 2}, []RoundMessage{
+		{ID: "m3", Username: "ada", Message: "deep sea"},
+		{ID: "m4", Username: "linus", Message: "moon tides", Encrypted: true},
+	}, nil, start.Add(time.Minute), []string{"theme:sea"})
+	s.add(roundKey{room: "lobby", roundID: 3}, []RoundMessage{
+		{ID: "m5", Username: "grace", Message: "the moon again"},
+	}, &RoundMessage{ID: "m5", Username: "grace"}, start.Add(2*time.Minute), nil)
+	return s
+}
+
+func caseInsensitive(a, b string) bool { return strings.EqualFold(a, b) }
+
+// foundRounds returns the IDs of the rounds a result lists, in order.
+func foundRounds(result SearchResult) []int64 {
+	ids := []int64{}
+	for _, round := range result.Rounds {
+		ids = append(ids, round.RoundID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestSearchIndex(10)
+
+	tests := []struct {
+		name    string
+		query   SearchQuery
+		rounds  []int64
+		matches int
+	}{
+		{"tag", SearchQuery{Tags: []string{"Theme:Space"}}, []int64{1}, 0},
+		{"room tag", SearchQuery{Tags: []string{"room:lobby"}}, []int64{3}, 0},
+		{"text, most recent first", SearchQuery{Text: "MOON"}, []int64{3, 1}, 3},
+		{"every word of the text", SearchQuery{Text: "moon rockets"}, []int64{1}, 1},
+		{"username", SearchQuery{Username: "ADA"}, []int64{2, 1}, 2},
+		{"username and text", SearchQuery{Username: "grace", Text: "moon"}, []int64{3, 1}, 2},
+		{"tag, username and text", SearchQuery{Tags: []string{"theme:space"}, Username: "grace", Text: "moon"}, []int64{1}, 1},
+		{"tag and username of another round", SearchQuery{Tags: []string{"theme:sea"}, Username: "grace"}, []int64{}, 0},
+		{"encrypted messages are not indexed", SearchQuery{Text: "tides"}, []int64{}, 0},
+		{"limit", SearchQuery{Text: "moon", Limit: 1}, []int64{3}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.search(tt.query, caseInsensitive)
+			if err != nil {
+				t.Fatalf("search: %v", err)
+			}
+			if got := foundRounds(result); !slices.Equal(got, tt.rounds) {
+				t.Errorf("rounds = %v, want %v", got, tt.rounds)
+			}
+			if result.Matches != tt.matches {
+				t.Errorf("matches = %d, want %d", result.Matches, tt.matches)
+			}
+			if result.IndexedRounds != 3 {
+				t.Errorf("indexed rounds = %d, want 3", result.IndexedRounds)
+			}
+		})
+	}
+
+	result, _ := s.search(SearchQuery{Text: "moon", Limit: 1}, caseInsensitive)
+	if !result.Truncated {
+		t.Error("result cut at the limit is not marked truncated")
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	if _, err := newMemorySearchIndex(0).search(SearchQuery{Text: "moon"}, caseInsensitive); !errors.Is(err, ErrSearchDisabled) {
+		t.Errorf("disabled index: error = %v, want ErrSearchDisabled", err)
+	}
+	s := newTestSearchIndex(10)
+	if _, err := s.search(SearchQuery{Text: " ... "}, caseInsensitive); !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("query without words: error = %v, want ErrEmptySearch", err)
+	}
+	if _, err := s.search(SearchQuery{Tags: []string{"bad tag"}}, caseInsensitive); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("malformed tag: error = %v, want ErrInvalidTag", err)
+	}
+}
+
+func TestSearchEviction(t *testing.T) {
+	s := newTestSearchIndex(2)
+
+	if s.indexed(roundKey{roundID: 1}) {
+		t.Error("round that ended first is still indexed")
+	}
+	if _, ok := s.tags[roundKey{roundID: 1}]; ok {
+		t.Error("tags of the evicted round are kept")
+	}
+	if _, ok := s.terms["rockets"]; ok {
+		t.Error("words only the evicted round contained are still indexed")
+	}
+
+	// An archived round loaded late falls in by the time it ended and is evicted first.
+	s.add(roundKey{roundID: 0}, []RoundMessage{{ID: "m0", Username: "ada", Message: "moon"}}, nil, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	if s.indexed(roundKey{roundID: 0}) {
+		t.Error("round older than every indexed one was kept")
+	}
+	result, err := s.search(SearchQuery{Text: "moon"}, caseInsensitive)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got := foundRounds(result); !slices.Equal(got, []int64{3}) {
+		t.Errorf("rounds = %v, want [3]", got)
+	}
+}
+
+func TestSearchRemoveAndErase(t *testing.T) {
+	s := newTestSearchIndex(10)
+
+	s.remove(roundKey{roundID: 1}, "m2")
+	result, _ := s.search(SearchQuery{Username: "grace"}, caseInsensitive)
+	if got := foundRounds(result); !slices.Equal(got, []int64{3}) {
+		t.Errorf("after removing a message: rounds = %v, want [3]", got)
+	}
+
+	s.eraseUser(func(username string) bool { return strings.EqualFold(username, "ada") })
+	result, _ = s.search(SearchQuery{Username: "ada"}, caseInsensitive)
+	if len(result.Rounds) != 0 {
+		t.Errorf("after erasing a user: rounds = %v, want none", foundRounds(result))
+	}
+	result, _ = s.search(SearchQuery{Tags: []string{"theme:space"}}, caseInsensitive)
+	if len(result.Rounds) != 1 || result.Rounds[0].Winner != ErasedUsername {
+		t.Errorf("round won by the erased user = %+v, want the winner %q", result.Rounds, ErasedUsername)
+	}
+}
+
+func TestParseRoundKey(t *testing.T) {
+	for _, key := range []roundKey{{roundID: 7}, {room: "lobby", roundID: 1700000000}, {room: "a.b", roundID: 3}} {
+		got, ok := parseRoundKey(key.kvKey())
+		if !ok || got != key {
+			t.Errorf("parseRoundKey(%q) = %+v, %v, want %+v", key.kvKey(), got, ok, key)
+		}
+	}
+	if _, ok := parseRoundKey("lobby"); ok {
+		t.Error("key without a round ID parsed")
+	}
+}
@@ -0,0 +1,137 @@
+// internal/hub/sequence.go
+package hub
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// ResyncUnavailableCode is sent with resync_complete when the requested events were
+// already dropped from the buffer; the client gets a fresh state_sync instead.
+const ResyncUnavailableCode = "RESYNC_UNAVAILABLE"
+
+// sequencedMessageType reports whether broadcasts of the given type are game events that
+// carry a sequence number. Types a client may opt out of are not sequenced, so every
+// client receives every sequence number and a gap always means a lost frame.
+func sequencedMessageType(messageType string) bool {
+	return !optionalMessageTypes[messageType]
+}
+
+// sequencedEvent is a game event retained for resync.
+type sequencedEvent struct {
+	seq         uint64
+	messageType string
+	data        []byte
+}
+
+// eventLog numbers game events and retains the last limit of them, oldest first.
+type eventLog struct {
+	mu     sync.Mutex // serializes numbering with handing events to the Run goroutine
+	last   atomic.Uint64
+	limit  int
+	events []sequencedEvent
+}
+
+func newEventLog(limit int) *eventLog {
+	return &eventLog{limit: limit}
+}
+
+// publish sets the next sequence number on a game event, encodes and retains it and
+// hands it to send. send runs under the log's lock so events reach the Run goroutine in
+// sequence order. The Run goroutine must never take the lock itself, it reads lastSeq.
+func (l *eventLog) publish(messageType string, message map[string]interface{}, send func(data []byte)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seq := l.last.Load() + 1
+	message["seq"] = seq
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	l.last.Store(seq)
+	if l.limit > 0 {
+		l.events = append(l.events, sequencedEvent{seq: seq, messageType: messageType, data: data})
+		if over := len(l.events) - l.limit; over > 0 {
+			l.events = append([]sequencedEvent(nil), l.events[over:]...)
+		}
+	}
+	send(data)
+	return nil
+}
+
+// lastSeq returns the sequence number of the latest game event, 0 before the first.
+func (l *eventLog) lastSeq() uint64 {
+	return l.last.Load()
+}
+
+// clear drops the retained events; numbering continues where it was, so resyncs from
+// before now are answered with a state_sync.
+func (l *eventLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = nil
+}
+
+// since returns the retained events from sequence number from on, and whether the
+// buffer still holds every one of them.
+func (l *eventLog) since(from uint64) ([]sequencedEvent, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last := l.last.Load()
+	if from > last {
+		return nil, true
+	}
+	if len(l.events) == 0 || l.events[0].seq > from {
+		var events []sequencedEvent
+		if len(l.events) > 0 {
+			events = append(events, l.events...)
+		}
+		return events, false
+	}
+	start := int(from - l.events[0].seq)
+	return append([]sequencedEvent(nil), l.events[start:]...), true
+}
+
+// handleResync replays the game events a client missed, starting at the sequence number
+// in data, and finishes with resync_complete. Events that already left the buffer are
+// replaced by a state_sync.
+func (h *Hub) handleResync(client *Client, message map[string]interface{}) {
+	from, ok := message["data"].(float64)
+	if !ok || from < 1 || from != float64(uint64(from)) {
+		h.SendErrorMessage(client, "Invalid resync_from: data must be a sequence number")
+		return
+	}
+
+	events, complete := h.events.since(uint64(from))
+	replayed := 0
+	for _, event := range events {
+		if !client.Accepts(event.messageType) {
+			continue
+		}
+		select {
+		case client.Send <- event.data:
+			replayed++
+		default:
+			// Client is slow or disconnected, trigger cleanup
+			h.unregister(client)
+			return
+		}
+	}
+
+	done := map[string]interface{}{
+		"version":  "1.0",
+		"type":     "resync_complete",
+		"from":     uint64(from),
+		"to":       h.events.lastSeq(),
+		"complete": complete,
+		"replayed": replayed,
+	}
+	if !complete {
+		done["code"] = ResyncUnavailableCode
+	}
+	h.sendMessageToClient(client, done)
+	if !complete {
+		h.sendStateSync(client)
+	}
+}
@@ -0,0 +1,259 @@
+// internal/hub/series.go
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	seriesBucket = "SERIES"
+	maxSeries    = 100 // series kept in memory
+)
+
+// ErrSeriesNotFound is returned for unknown series IDs.
+var ErrSeriesNotFound = errors.New("series not found")
+
+// Series is the state of a best-of series.
+type Series = message.Series
+
+// seriesTracker groups consecutive rounds into best-of series of a fixed length. Series
+// are persisted in a JetStream key-value bucket when available.
+type seriesTracker struct {
+	mu     sync.Mutex
+	length int
+	kv     nats.KeyValue // nil without JetStream
+	series []*Series     // latest last, bounded by maxSeries
+}
+
+// newSeriesTracker returns nil when series are disabled.
+func newSeriesTracker(js nats.JetStreamContext, bucket string, length int, logger *logger.Logger) *seriesTracker {
+	if length <= 0 {
+		return nil
+	}
+	t := &seriesTracker{length: length}
+	if js == nil {
+		logger.Warn("JetStream unavailable, series are kept in memory only")
+		return t
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Best-of series by ID",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening series bucket, series are kept in memory only: %v", err)
+		return t
+	}
+	t.kv = kv
+	return t
+}
+
+// latest returns the most recent series, or nil. Callers must hold mu.
+func (t *seriesTracker) latest() *Series {
+	if len(t.series) == 0 {
+		return nil
+	}
+	return t.series[len(t.series)-1]
+}
+
+// find returns the series with the given ID, or the one containing roundID when id is
+// empty. Callers must hold mu.
+func (t *seriesTracker) find(id string, roundID int64) *Series {
+	for i := len(t.series) - 1; i >= 0; i-- {
+		s := t.series[i]
+		if id != "" && s.ID == id {
+			return s
+		}
+		if id == "" && slices.ContainsFunc(s.Rounds, func(r message.SeriesRound) bool { return r.RoundID == roundID }) {
+			return s
+		}
+	}
+	return nil
+}
+
+// cloneSeries returns a deep copy of a series.
+func cloneSeries(s Series) Series {
+	s.Rounds = slices.Clone(s.Rounds)
+	s.Standings = slices.Clone(s.Standings)
+	return s
+}
+
+// seriesStandings totals the points of every round winner, most points first. Ties go
+// to whoever reached their total first, which also decides the champion.
+func seriesStandings(rounds []message.SeriesRound, winPoints int) []message.SeriesStanding {
+	standings := []message.SeriesStanding{}
+	reached := make(map[string]int) // round index of each user's last win
+	for i, round := range rounds {
+		if round.Winner == "" {
+			continue
+		}
+		j := slices.IndexFunc(standings, func(s message.SeriesStanding) bool { return s.Username == round.Winner })
+		if j < 0 {
+			standings = append(standings, message.SeriesStanding{Username: round.Winner})
+			j = len(standings) - 1
+		}
+		standings[j].Wins++
+		standings[j].Points += winPoints
+		reached[round.Winner] = i
+	}
+	slices.SortStableFunc(standings, func(a, b message.SeriesStanding) int {
+		if a.Points != b.Points {
+			return b.Points - a.Points
+		}
+		return reached[a.Username] - reached[b.Username]
+	})
+	return standings
+}
+
+// seriesRoundStarted assigns a new round to the running series, starting a new series
+// once the previous one played all its rounds, and broadcasts the updated series.
+func (h *Hub) seriesRoundStarted(roundID int64) {
+	t := h.series
+	if t == nil {
+		return
+	}
+	now := h.clock.Now()
+	t.mu.Lock()
+	current := t.latest()
+	if current == nil || len(current.Rounds) >= current.Length {
+		// The last round's winner may still be pending; it is recorded on the previous series.
+		current = &Series{
+			ID:        h.idgen.NewID(),
+			Status:    message.SeriesStatusInProgress,
+			Length:    t.length,
+			Standings: []message.SeriesStanding{},
+			StartedAt: now,
+		}
+		t.series = append(t.series, current)
+		if over := len(t.series) - maxSeries; over > 0 {
+			t.series = append([]*Series(nil), t.series[over:]...)
+		}
+	}
+	current.Rounds = append(current.Rounds, message.SeriesRound{RoundID: roundID})
+	current.UpdatedAt = now
+	series := cloneSeries(*current)
+	t.mu.Unlock()
+
+	h.saveSeries(series)
+	h.Logger.Infof("Round %d is round %d of %d of series %s", roundID, len(series.Rounds), series.Length, series.ID)
+}
+
+// seriesRoundWon records the winner of a series round, an empty winner for a round
+// nobody won, and recomputes the standings. Once every round of the series has a result
+// the series finishes and its champion is announced. An appeal that replaces the winner
+// of a series round updates the standings and announces a changed champion again.
+func (h *Hub) seriesRoundWon(roundID int64, winner string) {
+	t := h.series
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	current := t.find("", roundID)
+	if current == nil {
+		t.mu.Unlock()
+		return
+	}
+	i := slices.IndexFunc(current.Rounds, func(r message.SeriesRound) bool { return r.RoundID == roundID })
+	current.Rounds[i].Winner = winner
+	current.Standings = seriesStandings(current.Rounds, h.settings().SeriesWinPoints)
+
+	previousChampion, wasFinished := current.Champion, current.Status == message.SeriesStatusFinished
+	lastRound := len(current.Rounds) >= current.Length && i == len(current.Rounds)-1
+	if lastRound || wasFinished {
+		current.Status = message.SeriesStatusFinished
+		current.Champion = ""
+		if len(current.Standings) > 0 {
+			current.Champion = current.Standings[0].Username
+		}
+	}
+	current.UpdatedAt = h.clock.Now()
+	series := cloneSeries(*current)
+	t.mu.Unlock()
+
+	h.saveSeries(series)
+	if series.Status == message.SeriesStatusFinished && (!wasFinished || series.Champion != previousChampion) {
+		h.announceSeriesChampion(series)
+	}
+}
+
+// announceSeriesChampion broadcasts the champion and final standings of a finished series.
+func (h *Hub) announceSeriesChampion(series Series) {
+	points := 0
+	if len(series.Standings) > 0 {
+		points = series.Standings[0].Points
+	}
+	h.Logger.Infof("Series %s finished, champion: %q with %d points", series.ID, series.Champion, points)
+	h.BroadcastMessage(map[string]interface{}{
+		"version":   "1.0",
+		"type":      "series_champion",
+		"series_id": series.ID,
+		"champion":  series.Champion,
+		"points":    points,
+		"standings": series.Standings,
+	})
+}
+
+// saveSeries persists the series and broadcasts it to clients.
+func (h *Hub) saveSeries(series Series) {
+	if kv := h.series.kv; kv != nil {
+		if data, err := json.Marshal(series); err == nil {
+			if _, err := kv.Put(series.ID, data); err != nil {
+				h.Logger.Errorf("Failed to store series %s: %v", series.ID, err)
+			}
+		} else {
+			h.Logger.Errorf("Failed to marshal series %s: %v", series.ID, err)
+		}
+	}
+	h.BroadcastMessage(map[string]interface{}{
+		"version": "1.0",
+		"type":    "series_update",
+		"data":    series,
+	})
+}
+
+// Series returns a series by ID, or the most recent one for "current". Series no longer
+// held in memory are read from the key-value bucket.
+func (h *Hub) Series(id string) (Series, error) {
+	t := h.series
+	if t == nil {
+		return Series{}, ErrSeriesNotFound
+	}
+	t.mu.Lock()
+	var found *Series
+	if id == "current" {
+		found = t.latest()
+	} else {
+		found = t.find(id, 0)
+	}
+	if found != nil {
+		defer t.mu.Unlock()
+		return cloneSeries(*found), nil
+	}
+	t.mu.Unlock()
+
+	if id == "current" || t.kv == nil {
+		return Series{}, ErrSeriesNotFound
+	}
+	entry, err := t.kv.Get(id)
+	if errors.Is(err, nats.ErrKeyNotFound) || errors.Is(err, nats.ErrInvalidKey) {
+		return Series{}, ErrSeriesNotFound
+	}
+	if err != nil {
+		return Series{}, err
+	}
+	var series Series
+	if err := json.Unmarshal(entry.Value(), &series); err != nil {
+		return Series{}, err
+	}
+	return series, nil
+}
@@ -0,0 +1,201 @@
+// internal/hub/shards.go
+// Concurrent containers that split hub state so message handling does not
+// serialize on a single mutex.
+package hub
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/erilali/internal/config"
+)
+
+const limiterShardCount = 64
+
+// clientRegistry holds the connected clients behind its own read/write lock.
+type clientRegistry struct {
+	mu      sync.RWMutex
+	clients map[*Client]bool
+	peak    int
+}
+
+func newClientRegistry() *clientRegistry {
+	return &clientRegistry{clients: make(map[*Client]bool)}
+}
+
+// add registers a client and updates the peak count.
+func (r *clientRegistry) add(client *Client) {
+	r.mu.Lock()
+	r.clients[client] = true
+	if len(r.clients) > r.peak {
+		r.peak = len(r.clients)
+	}
+	r.mu.Unlock()
+}
+
+// remove deletes a client and reports whether it was registered.
+func (r *clientRegistry) remove(client *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.clients[client] {
+		return false
+	}
+	delete(r.clients, client)
+	return true
+}
+
+// snapshot returns the registered clients so callers can send without holding the lock.
+func (r *clientRegistry) snapshot() []*Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clients := make([]*Client, 0, len(r.clients))
+	for client := range r.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// counts returns the current and peak number of clients.
+func (r *clientRegistry) counts() (int, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.clients), r.peak
+}
+
+// submissionLimiter tracks which users already submitted in a round.
+// Usernames are spread over independently locked shards.
+type submissionLimiter struct {
+	shards [limiterShardCount]limiterShard
+}
+
+type limiterShard struct {
+	mu    sync.Mutex
+	users map[string]bool
+}
+
+func newSubmissionLimiter() *submissionLimiter {
+	l := &submissionLimiter{}
+	for i := range l.shards {
+		l.shards[i].users = make(map[string]bool)
+	}
+	return l
+}
+
+func (l *submissionLimiter) shard(username string) *limiterShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(username))
+	return &l.shards[hash.Sum32()%limiterShardCount]
+}
+
+// tryMark flags the user as having submitted and returns false if they already had.
+func (l *submissionLimiter) tryMark(username string) bool {
+	s := l.shard(username)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.users[username] {
+		return false
+	}
+	s.users[username] = true
+	return true
+}
+
+// clear allows the user to submit again.
+func (l *submissionLimiter) clear(username string) {
+	s := l.shard(username)
+	s.mu.Lock()
+	delete(s.users, username)
+	s.mu.Unlock()
+}
+
+// has reports whether the user submitted.
+func (l *submissionLimiter) has(username string) bool {
+	s := l.shard(username)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[username]
+}
+
+// roundStore keeps submitted messages in per-round buckets. The store lock only
+// guards the bucket map; each bucket has its own lock for its messages.
+type roundStore struct {
+	mu     sync.RWMutex
+	rounds map[int64]*roundBucket
+}
+
+type roundBucket struct {
+	mu       sync.Mutex
+	messages []RoundMessage
+	choices  *config.ChoiceSet // options of a round in choices mode, nil in free mode
+}
+
+func newRoundStore() *roundStore {
+	return &roundStore{rounds: make(map[int64]*roundBucket)}
+}
+
+// bucket returns the bucket for a round, creating it on first use.
+func (s *roundStore) bucket(roundID int64) *roundBucket {
+	s.mu.RLock()
+	b, ok := s.rounds[roundID]
+	s.mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok = s.rounds[roundID]; !ok {
+		b = &roundBucket{}
+		s.rounds[roundID] = b
+	}
+	return b
+}
+
+// messages returns a copy of the messages stored for a round.
+func (s *roundStore) messages(roundID int64) []RoundMessage {
+	s.mu.RLock()
+	b, ok := s.rounds[roundID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]RoundMessage(nil), b.messages...)
+}
+
+// remove deletes the message with the given ID from a round and returns it.
+func (s *roundStore) remove(roundID int64, messageID string) (RoundMessage, bool) {
+	s.mu.RLock()
+	b, ok := s.rounds[roundID]
+	s.mu.RUnlock()
+	if !ok {
+		return RoundMessage{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, msg := range b.messages {
+		if msg.ID == messageID {
+			b.messages = append(b.messages[:i:i], b.messages[i+1:]...)
+			return msg, true
+		}
+	}
+	return RoundMessage{}, false
+}
+
+// roundIDs returns the IDs of all stored rounds.
+func (s *roundStore) roundIDs() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int64, 0, len(s.rounds))
+	for id := range s.rounds {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// delete drops a round's bucket.
+func (s *roundStore) delete(roundID int64) {
+	s.mu.Lock()
+	delete(s.rounds, roundID)
+	s.mu.Unlock()
+}
@@ -0,0 +1,200 @@
+package hub
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const benchRegisteredClients = 10000
+
+// singleMutexState is the layout the sharded containers replaced: connected clients,
+// submission marks and round messages all behind one mutex.
+type singleMutexState struct {
+	mu       sync.Mutex
+	clients  map[*Client]bool
+	marks    map[string]bool
+	messages map[int64][]RoundMessage
+}
+
+func newSingleMutexState() *singleMutexState {
+	return &singleMutexState{
+		clients:  make(map[*Client]bool),
+		marks:    make(map[string]bool),
+		messages: make(map[int64][]RoundMessage),
+	}
+}
+
+func (s *singleMutexState) add(client *Client) {
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
+}
+
+func (s *singleMutexState) snapshot() []*Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := make([]*Client, 0, len(s.clients))
+	for client := range s.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// submit marks the user and stores the message, then unmarks them so the benchmark
+// keeps taking the accepting path.
+func (s *singleMutexState) submit(roundID int64, msg RoundMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.marks[msg.Username] {
+		return
+	}
+	s.marks[msg.Username] = true
+	if len(s.messages[roundID]) >= benchRegisteredClients {
+		s.messages[roundID] = s.messages[roundID][:0]
+	}
+	s.messages[roundID] = append(s.messages[roundID], msg)
+	delete(s.marks, msg.Username)
+}
+
+// shardedState is the hub's layout: the client registry, the submission limiter and
+// the round store, each locked on its own.
+type shardedState struct {
+	clients *clientRegistry
+	limiter *submissionLimiter
+	rounds  *roundStore
+}
+
+func newShardedState() *shardedState {
+	return &shardedState{
+		clients: newClientRegistry(),
+		limiter: newSubmissionLimiter(),
+		rounds:  newRoundStore(),
+	}
+}
+
+func (s *shardedState) add(client *Client) { s.clients.add(client) }
+
+func (s *shardedState) snapshot() []*Client { return s.clients.snapshot() }
+
+func (s *shardedState) submit(roundID int64, msg RoundMessage) {
+	if !s.limiter.tryMark(msg.Username) {
+		return
+	}
+	b := s.rounds.bucket(roundID)
+	b.mu.Lock()
+	if len(b.messages) >= benchRegisteredClients {
+		b.messages = b.messages[:0]
+	}
+	b.messages = append(b.messages, msg)
+	b.mu.Unlock()
+	s.limiter.clear(msg.Username)
+}
+
+// benchState is what the benchmarks drive in both layouts.
+type benchState interface {
+	add(client *Client)
+	snapshot() []*Client
+	submit(roundID int64, msg RoundMessage)
+}
+
+var benchLayouts = []struct {
+	name  string
+	state func() benchState
+}{
+	{"sharded", func() benchState { return newShardedState() }},
+	{"single_mutex", func() benchState { return newSingleMutexState() }},
+}
+
+// registerBenchClients fills state with benchRegisteredClients clients whose send
+// queues are full, so broadcasting to them only takes the skip path.
+func registerBenchClients(state benchState) []string {
+	usernames := make([]string, benchRegisteredClients)
+	for i := range usernames {
+		usernames[i] = fmt.Sprintf("user%05d", i)
+		client := &Client{Send: make(chan []byte, 1)}
+		client.Send <- nil
+		state.add(client)
+	}
+	return usernames
+}
+
+// fanOut queues data for every client as a broadcast does.
+func fanOut(state benchState, data []byte) {
+	for _, client := range state.snapshot() {
+		select {
+		case client.Send <- data:
+		default:
+		}
+	}
+}
+
+// runInBackground calls fn in a loop until the returned stop function is called.
+func runInBackground(fn func()) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				fn()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// BenchmarkShardedSubmit measures submissions from 10k registered users while a
+// broadcast runs continuously, with the hub's sharded containers and with one mutex.
+func BenchmarkShardedSubmit(b *testing.B) {
+	for _, layout := range benchLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			state := layout.state()
+			usernames := registerBenchClients(state)
+			stop := runInBackground(func() { fanOut(state, []byte("round_start")) })
+			defer stop()
+
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := next.Add(1)
+					state.submit(1, RoundMessage{ID: "m", Username: usernames[i%benchRegisteredClients], Message: "hello"})
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkShardedBroadcast measures broadcasts to 10k registered clients while
+// submissions arrive continuously, with the hub's sharded containers and with one mutex.
+func BenchmarkShardedBroadcast(b *testing.B) {
+	for _, layout := range benchLayouts {
+		b.Run(layout.name, func(b *testing.B) {
+			state := layout.state()
+			usernames := registerBenchClients(state)
+			var next int
+			stop := runInBackground(func() {
+				next++
+				state.submit(1, RoundMessage{ID: "m", Username: usernames[next%benchRegisteredClients], Message: "hello"})
+			})
+			defer stop()
+
+			data := []byte("round_start")
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					fanOut(state, data)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// internal/hub/statesync.go
+package hub
+
+import (
+	"time"
+)
+
+// roundResult is the winner of the most recent round that had one.
+type roundResult struct {
+	RoundID int64
+	Winner  *RoundMessage
+}
+
+// rememberResult records a round's winner for clients that connect later.
+func (h *Hub) rememberResult(roundID int64, winner *RoundMessage) {
+	h.lastResult.Store(&roundResult{RoundID: roundID, Winner: winner})
+}
+
+// sendStateSync brings a newly registered client up to date: the current round with its
+// deadlines and remaining time, the last winner, how many clients are connected, the
+// sequence number of the latest game event, the stored preferences of its user and the
+// announcements that have not expired.
+func (h *Hub) sendStateSync(client *Client) {
+	h.Mu.RLock()
+	roundActive := h.RoundActive
+	currentRoundID := h.CurrentRoundID
+	timing := h.roundTiming
+	submissionsOpen := roundActive && h.clock.Now().Before(h.submissionsCloseAt)
+	h.Mu.RUnlock()
+
+	round := map[string]interface{}{
+		"round_id":         currentRoundID,
+		"active":           roundActive,
+		"submissions_open": submissionsOpen,
+	}
+	if roundActive {
+		timing.addTo(round)
+		remaining := timing.EndsAt.Sub(h.clock.Now())
+		if remaining < 0 {
+			remaining = 0
+		}
+		round["time_remaining_ms"] = remaining.Milliseconds()
+		addRoundChoices(round, h.roundChoices(currentRoundID))
+	}
+
+	var lastWinner map[string]interface{}
+	if result := h.lastResult.Load(); result != nil {
+		lastWinner = map[string]interface{}{
+			"round_id": result.RoundID,
+			"winner":   result.Winner,
+		}
+	}
+
+	connected, _ := h.clients.counts()
+	message := map[string]interface{}{
+		"version":     "1.0",
+		"type":        "state_sync",
+		"round":       round,
+		"last_winner": lastWinner,
+		"presence":    connected,
+		"seq":         h.events.lastSeq(),
+		"server_time": h.clock.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if prefs := client.Preferences(); prefs != nil {
+		message["preferences"] = prefs
+	}
+	if h.parent != nil {
+		message["role"] = h.clientRole(client)
+	}
+	if until, muted := h.mutedUntil(client.Username()); muted {
+		message["muted_until"] = until.UTC().Format(time.RFC3339)
+	}
+	if announcements := h.notices.active(h.clock.Now()); announcements != nil {
+		message["announcements"] = announcements
+	}
+	h.sendMessageToClient(client, message)
+}
@@ -0,0 +1,300 @@
+// internal/hub/stats.go
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/erilali/internal/eventbus"
+	"github.com/erilali/internal/ids"
+)
+
+const (
+	// maxRoundSummaries bounds the in-memory round history (~41 hours at the default round length).
+	maxRoundSummaries = 10000
+
+	statsEventLimit   = 20000 // summaries read from round_summary.* per statistics request
+	statsFetchMaxWait = 2 * time.Second
+)
+
+// RoundSummary records the outcome of a finished round for statistics.
+type RoundSummary struct {
+	RoundID      int64     `json:"round_id"`
+	StartedAt    time.Time `json:"started_at"`
+	EndedAt      time.Time `json:"ended_at"`
+	Submissions  int       `json:"submissions"`
+	Participants []string  `json:"participants"`
+	Winner       string    `json:"winner,omitempty"`
+	Void         bool      `json:"void,omitempty"`      // too few participants, see min_participants
+	Corrected    bool      `json:"corrected,omitempty"` // replaces an earlier summary of the round, such as after an erasure
+
+	CooldownExcluded []string `json:"cooldown_excluded,omitempty"` // recent winners left out of the draw, see winner_cooldown_rounds
+
+	DurationSeconds float64        `json:"duration_seconds"`
+	Rejections      map[string]int `json:"rejections,omitempty"` // rejected submissions by reason
+}
+
+// WinnerCount is a username together with how many rounds they won.
+type WinnerCount struct {
+	Username  string `json:"username"`
+	Wins      int    `json:"wins"`
+	TotalWins int    `json:"total_wins"` // lifetime wins from the user's statistics
+	StatsURL  string `json:"stats_url"`
+}
+
+// RoundStats holds aggregates over the rounds in a time range.
+type RoundStats struct {
+	Since                 time.Time     `json:"since"`
+	Until                 time.Time     `json:"until"`
+	RoundsPlayed          int           `json:"rounds_played"`
+	RoundsToday           int           `json:"rounds_today"`
+	AvgSubmissions        float64       `json:"avg_submissions_per_round"`
+	UniqueParticipants    int           `json:"unique_participants"`
+	TopWinners            []WinnerCount `json:"top_winners"`
+	PeakConcurrentClients int           `json:"peak_concurrent_clients"`
+	CurrentClients        int           `json:"current_clients"`
+}
+
+// recordRoundSummary appends a finished round to the history, dropping the oldest entries
+// once maxRoundSummaries is exceeded, and publishes it with the round's rejection counts.
+func (h *Hub) recordRoundSummary(summary RoundSummary) {
+	summary.Rejections = h.rejections.take(summary.RoundID)
+	h.publishRoundSummaryToNATS(summary)
+
+	h.Mu.Lock()
+	defer h.Mu.Unlock()
+
+	h.roundsPlayed++
+	h.RoundHistory = append(h.RoundHistory, summary)
+	if over := len(h.RoundHistory) - maxRoundSummaries; over > 0 {
+		h.RoundHistory = append([]RoundSummary(nil), h.RoundHistory[over:]...)
+	}
+}
+
+// setSummaryWinner replaces the winner recorded in a round's summary after an appeal and
+// republishes the summary as corrected.
+func (h *Hub) setSummaryWinner(roundID int64, winner string) {
+	h.Mu.Lock()
+	var corrected *RoundSummary
+	for i := range h.RoundHistory {
+		if h.RoundHistory[i].RoundID == roundID {
+			h.RoundHistory[i].Winner = winner
+			h.RoundHistory[i].Corrected = true
+			summary := h.RoundHistory[i]
+			corrected = &summary
+			break
+		}
+	}
+	h.Mu.Unlock()
+	if corrected != nil {
+		h.publishRoundSummaryToNATS(*corrected)
+	}
+}
+
+// HubStats summarizes the hub for the health check.
+type HubStats struct {
+	StartedAt      time.Time `json:"started_at"`
+	UptimeSeconds  float64   `json:"uptime_seconds"`
+	Clients        int       `json:"clients"`
+	PeakClients    int       `json:"peak_clients"`
+	Waiting        int       `json:"waiting"` // connections queued in the waiting room
+	CurrentRoundID int64     `json:"current_round_id"`
+	RoundActive    bool      `json:"round_active"`
+	RoundsPlayed   int       `json:"rounds_played"` // rounds ended since the server started, including empty ones
+
+	Inbound    InboundStats    `json:"inbound"`
+	Upgrades   UpgradeStats    `json:"upgrades"`   // handshake and first message latencies, including rooms
+	Engagement EngagementStats `json:"engagement"` // app states reported in heartbeats
+	Broadcasts BroadcastStats  `json:"broadcasts"` // time to queue broadcasts for every client
+}
+
+// Stats returns the uptime, connected clients and round progress of the hub.
+func (h *Hub) Stats() HubStats {
+	clients, peak := h.clients.counts()
+	h.Mu.RLock()
+	roundID, active, played := h.CurrentRoundID, h.RoundActive, h.roundsPlayed
+	h.Mu.RUnlock()
+	return HubStats{
+		StartedAt:      h.StartTime,
+		UptimeSeconds:  h.clock.Now().Sub(h.StartTime).Seconds(),
+		Clients:        clients,
+		PeakClients:    peak,
+		Waiting:        h.Occupancy().Waiting,
+		CurrentRoundID: roundID,
+		RoundActive:    active,
+		RoundsPlayed:   played,
+		Inbound:        h.InboundStats(),
+		Upgrades:       h.UpgradeStats(),
+		Engagement:     h.Engagement(),
+		Broadcasts:     h.broadcasts.stats(),
+	}
+}
+
+// roundSummary returns the recorded summary of a round, or nil when it is no longer held.
+func (h *Hub) roundSummary(roundID int64) *RoundSummary {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+	for i := len(h.RoundHistory) - 1; i >= 0; i-- {
+		if h.RoundHistory[i].RoundID == roundID {
+			summary := h.RoundHistory[i]
+			return &summary
+		}
+	}
+	return nil
+}
+
+// summarizeRound builds a RoundSummary from the stored messages of a round that ended at endedAt.
+func summarizeRound(roundID int64, messages []RoundMessage, winner string, endedAt time.Time) RoundSummary {
+	seen := make(map[string]bool, len(messages))
+	participants := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		if !seen[msg.Username] {
+			seen[msg.Username] = true
+			participants = append(participants, msg.Username)
+		}
+	}
+	startedAt := ids.RoundTime(roundID)
+	return RoundSummary{
+		RoundID:         roundID,
+		StartedAt:       startedAt,
+		EndedAt:         endedAt,
+		Submissions:     len(messages),
+		Participants:    participants,
+		Winner:          winner,
+		DurationSeconds: endedAt.Sub(startedAt).Seconds(),
+	}
+}
+
+// storedRoundSummaries reads the summaries published on round_summary.* since from,
+// keeping the latest of each round, such as the correction after an appeal or erasure.
+func (h *Hub) storedRoundSummaries(ctx context.Context, from time.Time) ([]RoundSummary, error) {
+	events, err := h.Bus.HistorySince(ctx, "round_summary.*", from, statsEventLimit, statsFetchMaxWait)
+	if errors.Is(err, eventbus.ErrHistoryIncomplete) {
+		h.Logger.Warnf("Round statistics read %d summaries before the deadline, later rounds come from memory only", len(events))
+	} else if err != nil {
+		return nil, fmt.Errorf("reading round summaries: %w", err)
+	}
+	if len(events) >= statsEventLimit {
+		h.Logger.Warnf("Round statistics read %d summaries, later rounds come from memory only", statsEventLimit)
+	}
+	var rounds []int64
+	latest := make(map[int64]RoundSummary)
+	for _, event := range events {
+		var summary RoundSummary
+		if err := json.Unmarshal(event.Data, &summary); err != nil || summary.RoundID == 0 {
+			continue
+		}
+		if _, ok := latest[summary.RoundID]; !ok {
+			rounds = append(rounds, summary.RoundID)
+		}
+		latest[summary.RoundID] = summary
+	}
+	summaries := make([]RoundSummary, 0, len(rounds))
+	for _, roundID := range rounds {
+		summaries = append(summaries, latest[roundID])
+	}
+	return summaries, nil
+}
+
+// statsRounds returns the summaries of the rounds that started at or after from. With an
+// event bus these are the summaries stored in ROUND_SUMMARY, covering every instance and
+// restarts within its retention, together with the rounds held in memory that are missing
+// there; without one, or when it cannot be read, the rounds held in memory.
+func (h *Hub) statsRounds(ctx context.Context, from time.Time) []RoundSummary {
+	h.Mu.RLock()
+	history := append([]RoundSummary(nil), h.RoundHistory...)
+	h.Mu.RUnlock()
+	if h.Bus == nil {
+		return history
+	}
+	stored, err := h.storedRoundSummaries(ctx, from)
+	if err != nil {
+		h.Logger.Warnf("Round statistics fall back to the rounds held in memory: %v", err)
+		return history
+	}
+
+	seen := make(map[int64]bool, len(stored))
+	for _, summary := range stored {
+		seen[summary.RoundID] = true
+	}
+	rounds := stored
+	for _, summary := range history {
+		if !seen[summary.RoundID] && !summary.StartedAt.Before(from) {
+			rounds = append(rounds, summary)
+		}
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i].RoundID < rounds[j].RoundID })
+	return rounds
+}
+
+// RoundStatistics aggregates the rounds that started within [since, until], read as
+// statsRounds does. A zero since or until leaves that side of the range open.
+func (h *Hub) RoundStatistics(ctx context.Context, since, until time.Time) RoundStats {
+	now := h.clock.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := since
+	if midnight.Before(from) {
+		from = midnight // rounds_today counts regardless of the range
+	}
+	history := h.statsRounds(ctx, from)
+	current, peak := h.clients.counts()
+
+	stats := RoundStats{
+		Since:                 since,
+		Until:                 until,
+		TopWinners:            []WinnerCount{},
+		PeakConcurrentClients: peak,
+		CurrentClients:        current,
+	}
+	participants := make(map[string]bool) // by usernameKey
+	wins := make(map[string]*WinnerCount) // by usernameKey, named as first seen
+	totalSubmissions := 0
+	for _, round := range history {
+		if !round.StartedAt.Before(midnight) {
+			stats.RoundsToday++
+		}
+		if (!since.IsZero() && round.StartedAt.Before(since)) || (!until.IsZero() && round.StartedAt.After(until)) {
+			continue
+		}
+		stats.RoundsPlayed++
+		totalSubmissions += round.Submissions
+		for _, username := range round.Participants {
+			participants[h.usernameKey(username)] = true
+		}
+		if round.Winner != "" && round.Winner != ErasedUsername {
+			key := h.usernameKey(round.Winner)
+			if wins[key] == nil {
+				wins[key] = &WinnerCount{Username: round.Winner}
+			}
+			wins[key].Wins++
+		}
+	}
+
+	if stats.RoundsPlayed > 0 {
+		stats.AvgSubmissions = float64(totalSubmissions) / float64(stats.RoundsPlayed)
+	}
+	stats.UniqueParticipants = len(participants)
+	for _, count := range wins {
+		stats.TopWinners = append(stats.TopWinners, *count)
+	}
+	sort.Slice(stats.TopWinners, func(i, j int) bool {
+		if stats.TopWinners[i].Wins != stats.TopWinners[j].Wins {
+			return stats.TopWinners[i].Wins > stats.TopWinners[j].Wins
+		}
+		return stats.TopWinners[i].Username < stats.TopWinners[j].Username
+	})
+	if len(stats.TopWinners) > 10 {
+		stats.TopWinners = stats.TopWinners[:10]
+	}
+	for i, winner := range stats.TopWinners {
+		stats.TopWinners[i].StatsURL = userStatsURL(winner.Username)
+		if userStats, err := h.UserStats(winner.Username); err == nil {
+			stats.TopWinners[i].TotalWins = userStats.Wins
+		}
+	}
+	return stats
+}
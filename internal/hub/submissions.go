@@ -0,0 +1,153 @@
+// internal/hub/submissions.go
+package hub
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	submissionsBucket = "SUBMISSIONS"
+	submissionsTTL    = 10 * time.Minute // comfortably longer than a round
+)
+
+// submissionLedger records which message each user submitted per round in a JetStream
+// key-value bucket. Unlike the in-memory limiter it survives reconnects, restarts and
+// is shared by every server instance using the same JetStream.
+type submissionLedger struct {
+	kv nats.KeyValue
+}
+
+// newSubmissionLedger opens the bucket, creating it if needed. Without JetStream only
+// the in-memory limiter guards against double submissions.
+func newSubmissionLedger(js nats.JetStreamContext, bucket string, logger *logger.Logger) *submissionLedger {
+	if js == nil {
+		return nil
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Submitted message per round and user",
+			TTL:         submissionsTTL,
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening submissions bucket, falling back to the in-memory limiter: %v", err)
+		return nil
+	}
+	return &submissionLedger{kv: kv}
+}
+
+// submissionKey builds the key for a user's submission in a round from the user's
+// usernameKey. Usernames are encoded so any character is safe in a key.
+func submissionKey(roundID int64, username string) string {
+	return fmt.Sprintf("%d.%s", roundID, base64.RawURLEncoding.EncodeToString([]byte(username)))
+}
+
+// claim records messageID as the user's submission for the round. If the user already
+// submitted, the existing message ID is returned and nothing is written. A claim that
+// completes after ctx ended is released again, so the user can resubmit.
+func (l *submissionLedger) claim(ctx context.Context, roundID int64, username, messageID string) (string, error) {
+	var existing string
+	var claimErr error
+	err := runWithContext(ctx, func() error {
+		existing, claimErr = l.create(roundID, username, messageID)
+		return claimErr
+	}, func() {
+		if claimErr == nil && existing == "" {
+			l.release(context.Background(), roundID, username)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return existing, nil
+}
+
+func (l *submissionLedger) create(roundID int64, username, messageID string) (string, error) {
+	key := submissionKey(roundID, username)
+	_, err := l.kv.Create(key, []byte(messageID))
+	if err == nil {
+		return "", nil
+	}
+	if !errors.Is(err, nats.ErrKeyExists) {
+		return "", fmt.Errorf("claiming submission for %s: %w", username, err)
+	}
+	entry, err := l.kv.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("reading submission for %s: %w", username, err)
+	}
+	return string(entry.Value()), nil
+}
+
+// lookup returns the ID of the user's submission for the round, or "" if there is none.
+func (l *submissionLedger) lookup(ctx context.Context, roundID int64, username string) (string, error) {
+	var messageID string
+	err := runWithContext(ctx, func() error {
+		entry, err := l.kv.Get(submissionKey(roundID, username))
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading submission for %s: %w", username, err)
+		}
+		messageID = string(entry.Value())
+		return nil
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// release forgets the user's submission so they may submit again, e.g. after a withdrawal.
+// A release still running when ctx ends completes in the background.
+func (l *submissionLedger) release(ctx context.Context, roundID int64, username string) error {
+	return runWithContext(ctx, func() error {
+		if err := l.kv.Delete(submissionKey(roundID, username)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return fmt.Errorf("releasing submission for %s: %w", username, err)
+		}
+		return nil
+	}, nil)
+}
+
+// userSubmission returns the message a user submitted in a round from the local store.
+func (h *Hub) userSubmission(roundID int64, username string) (RoundMessage, bool) {
+	for _, msg := range h.rounds.messages(roundID) {
+		if h.sameUsername(msg.Username, username) {
+			return msg, true
+		}
+	}
+	return RoundMessage{}, false
+}
+
+// ackExistingSubmission answers a repeated submission with the message the user already
+// submitted in the round, looking in the local store first and then in the ledger, which
+// also covers submissions made through another instance. It returns false if none is found.
+func (h *Hub) ackExistingSubmission(ctx context.Context, client *Client, roundID int64) bool {
+	if existing, ok := h.userSubmission(roundID, client.Username()); ok {
+		h.SendDuplicateAck(client, roundID, existing.ID, &existing)
+		return true
+	}
+	if h.submissions == nil {
+		return false
+	}
+	messageID, err := h.submissions.lookup(ctx, roundID, h.usernameKey(client.Username()))
+	if err != nil {
+		h.Logger.Errorf("Failed to look up submission: %v", err)
+		return false
+	}
+	if messageID == "" {
+		return false
+	}
+	h.SendDuplicateAck(client, roundID, messageID, nil)
+	return true
+}
@@ -0,0 +1,95 @@
+// internal/hub/subprotocol.go
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Application subprotocols negotiated through Sec-WebSocket-Protocol. Clients that do
+// not request a subprotocol speak the JSON encoding.
+const (
+	SubprotocolJSON    = "game.v1.json"
+	SubprotocolMsgpack = "game.v1.msgpack"
+)
+
+// supportedSubprotocols are listed in order of server preference.
+var supportedSubprotocols = []string{SubprotocolJSON, SubprotocolMsgpack}
+
+// checkSubprotocols rejects upgrade requests that only offer unsupported subprotocols.
+// It reports whether the request may be upgraded.
+func checkSubprotocols(w http.ResponseWriter, r *http.Request) bool {
+	requested := websocket.Subprotocols(r)
+	if len(requested) == 0 {
+		return true
+	}
+	for _, protocol := range requested {
+		for _, supported := range supportedSubprotocols {
+			if protocol == supported {
+				return true
+			}
+		}
+	}
+	http.Error(w, fmt.Sprintf("unsupported subprotocol %q, supported: %s",
+		strings.Join(requested, ", "), strings.Join(supportedSubprotocols, ", ")), http.StatusBadRequest)
+	return false
+}
+
+// decodeFrame decodes a client frame according to the negotiated subprotocol.
+func decodeFrame(subprotocol string, data []byte) (map[string]interface{}, error) {
+	var message map[string]interface{}
+	if subprotocol == SubprotocolMsgpack {
+		if err := msgpack.Unmarshal(data, &message); err != nil {
+			return nil, err
+		}
+		// Normalize to the types the JSON handlers expect.
+		normalized, err := json.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		data = normalized
+		message = nil
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// encodeMsgpack converts an encoded JSON message to MessagePack, keeping integers integral.
+func encodeMsgpack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(normalizeNumbers(value))
+}
+
+// normalizeNumbers replaces json.Number values with int64 or float64.
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,79 @@
+// internal/hub/summary.go
+package hub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Reasons submissions are rejected, counted per round in the round summary.
+const (
+	rejectSubmissionsClosed = "submissions_closed"
+	rejectRoundClosed       = "round_closed"
+	rejectDuplicate         = "duplicate"
+	rejectInvalid           = "invalid"
+	rejectNotAFinalist      = "not_a_finalist"
+	rejectGuest             = "guest"
+	rejectRules             = "rules"
+	rejectDuplicateContent  = "duplicate_content"
+	rejectMuted             = "muted"
+	rejectTimeout           = "timeout"
+)
+
+// rejectionTally counts rejected submissions by round and reason until the round's
+// summary is published.
+type rejectionTally struct {
+	mu     sync.Mutex
+	counts map[int64]map[string]int
+}
+
+func (t *rejectionTally) add(roundID int64, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts == nil {
+		t.counts = make(map[int64]map[string]int)
+	}
+	if t.counts[roundID] == nil {
+		t.counts[roundID] = make(map[string]int)
+	}
+	t.counts[roundID][reason]++
+}
+
+// take returns and forgets the counts of a round, along with counts of older rounds
+// that were never summarized.
+func (t *rejectionTally) take(roundID int64) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := t.counts[roundID]
+	for id := range t.counts {
+		if id <= roundID {
+			delete(t.counts, id)
+		}
+	}
+	return counts
+}
+
+// countRejection records a rejected submission for the round summary.
+func (h *Hub) countRejection(roundID int64, reason string) {
+	if roundID != 0 {
+		h.rejections.add(roundID, reason)
+	}
+}
+
+// publishRoundSummaryToNATS publishes the summary of a finished round on "round_summary.ROUND_ID"
+// so analytics pipelines need not re-aggregate the raw message streams.
+// Errors during marshaling or publishing are logged.
+func (h *Hub) publishRoundSummaryToNATS(summary RoundSummary) {
+	if h.Bus == nil {
+		return
+	}
+	subject := fmt.Sprintf("round_summary.%d", summary.RoundID)
+	if data, err := json.Marshal(summary); err == nil {
+		if err := h.Bus.Publish(subject, data); err != nil {
+			h.Logger.Errorf("Failed to publish round summary to event bus: %v", err)
+		}
+	} else {
+		h.Logger.Errorf("Failed to marshal round summary: %v", err)
+	}
+}
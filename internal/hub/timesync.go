@@ -0,0 +1,67 @@
+// internal/hub/timesync.go
+package hub
+
+import (
+	"context"
+	"time"
+)
+
+// runTimeSync broadcasts a "time_sync" message every time_sync_seconds so client
+// countdowns stay aligned with the server clock. It returns when ctx is canceled.
+func (h *Hub) runTimeSync(ctx context.Context) {
+	interval := h.settings().TimeSyncSeconds
+	if interval <= 0 {
+		return
+	}
+	ticker := h.clock.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		if connected, _ := h.clients.counts(); connected > 0 {
+			h.BroadcastMessage(h.timeSyncMessage())
+		}
+	}
+}
+
+// handleTimeSync answers a client's "time_sync" request, echoing its timestamp.
+func (h *Hub) handleTimeSync(client *Client, message map[string]interface{}) {
+	reply := h.timeSyncMessage()
+	if clientTime, ok := message["data"].(float64); ok {
+		reply["client_time"] = int64(clientTime)
+	}
+	h.sendMessageToClient(client, reply)
+}
+
+// timeSyncMessage reports the server time and the deadlines of the current round. The
+// remaining durations are taken on the monotonic clock, and monotonic_ms counts from the
+// server start, so clients can keep long countdowns exact when the wall clock is adjusted.
+func (h *Hub) timeSyncMessage() map[string]interface{} {
+	now := h.clock.Now()
+	h.Mu.RLock()
+	active := h.RoundActive
+	roundID := h.CurrentRoundID
+	timing := h.roundTiming
+	closeAt := h.submissionsCloseAt
+	h.Mu.RUnlock()
+
+	message := map[string]interface{}{
+		"version":      "1.0",
+		"type":         "time_sync",
+		"server_time":  now.UnixMilli(),
+		"monotonic_ms": now.Sub(h.StartTime).Milliseconds(),
+		"round_id":     roundID,
+		"round_active": active,
+	}
+	if active {
+		message["submission_deadline_ms"] = closeAt.UnixMilli()
+		message["ends_at_ms"] = timing.EndsAt.UnixMilli()
+		message["submissions_close_in_ms"] = max(closeAt.Sub(now), 0).Milliseconds()
+		message["ends_in_ms"] = max(timing.EndsAt.Sub(now), 0).Milliseconds()
+	}
+	return message
+}
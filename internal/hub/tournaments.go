@@ -0,0 +1,315 @@
+// internal/hub/tournaments.go
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	tournamentsBucket = "TOURNAMENTS"
+	maxTournaments    = 100 // tournaments kept in memory
+)
+
+// NotAFinalistCode is the error code sent for submissions to a final round by users who did not qualify.
+const NotAFinalistCode = "NOT_A_FINALIST"
+
+// ErrTournamentNotFound is returned for unknown tournament IDs.
+var ErrTournamentNotFound = errors.New("tournament not found")
+
+// Tournament is the bracket state of a tournament.
+type Tournament = message.Tournament
+
+// tournamentTracker chains rounds into tournaments: the winners of the qualifying rounds
+// advance to a final round restricted to them, after which the next tournament begins.
+// Brackets are persisted in a JetStream key-value bucket when available and reloaded from
+// it on startup, so a restart continues the running tournament.
+type tournamentTracker struct {
+	mu               sync.Mutex
+	qualifyingRounds int
+	kv               nats.KeyValue // nil without JetStream
+	brackets         []*Tournament // latest last, bounded by maxTournaments
+}
+
+// latest returns the most recent tournament, or nil. Callers must hold mu.
+func (t *tournamentTracker) latest() *Tournament {
+	if len(t.brackets) == 0 {
+		return nil
+	}
+	return t.brackets[len(t.brackets)-1]
+}
+
+// find returns the tournament with the given ID, or the one containing roundID when id
+// is empty. Callers must hold mu.
+func (t *tournamentTracker) find(id string, roundID int64) *Tournament {
+	for i := len(t.brackets) - 1; i >= 0; i-- {
+		bracket := t.brackets[i]
+		if id != "" && bracket.ID == id {
+			return bracket
+		}
+		if id == "" && slices.ContainsFunc(bracket.Rounds, func(r message.TournamentRound) bool { return r.RoundID == roundID }) {
+			return bracket
+		}
+	}
+	return nil
+}
+
+// newTournamentTracker returns nil when tournaments are disabled.
+func newTournamentTracker(js nats.JetStreamContext, bucket string, qualifyingRounds int, logger *logger.Logger) *tournamentTracker {
+	if qualifyingRounds <= 0 {
+		return nil
+	}
+	t := &tournamentTracker{qualifyingRounds: qualifyingRounds}
+	if js == nil {
+		logger.Warn("JetStream unavailable, tournament brackets are kept in memory only")
+		return t
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Tournament brackets by ID",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening tournaments bucket, brackets are kept in memory only: %v", err)
+		return t
+	}
+	t.kv = kv
+	if loaded, err := t.load(); err != nil {
+		logger.Errorf("Error loading tournaments: %v", err)
+	} else if loaded > 0 {
+		logger.Infof("Loaded %d tournaments", loaded)
+	}
+	return t
+}
+
+// load reads the most recent maxTournaments brackets from the bucket into memory.
+func (t *tournamentTracker) load() (int, error) {
+	keys, err := t.kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("listing tournaments: %w", err)
+	}
+	var brackets []*Tournament
+	for _, key := range keys {
+		entry, err := t.kv.Get(key)
+		if err != nil {
+			continue // deleted since listing
+		}
+		var bracket Tournament
+		if err := json.Unmarshal(entry.Value(), &bracket); err != nil {
+			continue
+		}
+		brackets = append(brackets, &bracket)
+	}
+	slices.SortFunc(brackets, func(a, b *Tournament) int { return a.StartedAt.Compare(b.StartedAt) })
+	if over := len(brackets) - maxTournaments; over > 0 {
+		brackets = brackets[over:]
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.brackets = brackets
+	return len(brackets), nil
+}
+
+// cloneTournament returns a deep copy of a tournament.
+func cloneTournament(t Tournament) Tournament {
+	t.Rounds = slices.Clone(t.Rounds)
+	t.Finalists = slices.Clone(t.Finalists)
+	return t
+}
+
+// tournamentRoundStarted assigns a new round to the running tournament, starting a new
+// tournament once the previous one played its final, and broadcasts the updated bracket.
+// When nobody won a qualifier the final is skipped: the tournament finishes without a
+// champion and the round qualifies for the next one.
+func (h *Hub) tournamentRoundStarted(roundID int64) {
+	t := h.tournaments
+	if t == nil {
+		return
+	}
+	now := h.clock.Now()
+	t.mu.Lock()
+	current := t.latest()
+	var skipped *Tournament
+	if current != nil && current.Status != message.TournamentStatusFinished &&
+		len(current.Rounds) >= current.QualifyingRounds && len(current.Finalists) == 0 {
+		current.Status = message.TournamentStatusFinished
+		current.UpdatedAt = now
+		bracket := cloneTournament(*current)
+		skipped = &bracket
+	}
+	if current == nil || current.Status == message.TournamentStatusFinished || len(current.Rounds) > current.QualifyingRounds {
+		// The final's winner may still be pending; it is recorded on the previous bracket.
+		current = &Tournament{
+			ID:               h.idgen.NewID(),
+			Status:           message.TournamentStatusQualifying,
+			QualifyingRounds: t.qualifyingRounds,
+			Finalists:        []string{},
+			StartedAt:        now,
+		}
+		t.brackets = append(t.brackets, current)
+		if over := len(t.brackets) - maxTournaments; over > 0 {
+			t.brackets = append([]*Tournament(nil), t.brackets[over:]...)
+		}
+	}
+	stage := message.TournamentStageQualifier
+	if len(current.Rounds) >= current.QualifyingRounds {
+		stage = message.TournamentStageFinal
+		current.Status = message.TournamentStatusFinal
+	}
+	current.Rounds = append(current.Rounds, message.TournamentRound{RoundID: roundID, Stage: stage})
+	current.UpdatedAt = now
+	bracket := cloneTournament(*current)
+	t.mu.Unlock()
+
+	if skipped != nil {
+		h.saveTournament(*skipped)
+		h.Logger.Infof("Tournament %s finished without a final, no qualifier was won", skipped.ID)
+	}
+	h.saveTournament(bracket)
+	h.Logger.Infof("Round %d is %s round %d of tournament %s", roundID, stage, len(bracket.Rounds), bracket.ID)
+}
+
+// tournamentRoundWon records the winner of a tournament round. Qualifier winners advance
+// to the final; the final's winner becomes the champion and finishes the tournament.
+// An empty winner records a round nobody won. When an appeal replaces the winner of a
+// qualifier, the previous winner leaves the finalists unless they won another qualifier.
+func (h *Hub) tournamentRoundWon(roundID int64, winner string) {
+	t := h.tournaments
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	tournament := t.find("", roundID)
+	if tournament == nil {
+		t.mu.Unlock()
+		return
+	}
+	i := slices.IndexFunc(tournament.Rounds, func(r message.TournamentRound) bool { return r.RoundID == roundID })
+	round := &tournament.Rounds[i]
+	previous := round.Winner
+	round.Winner = winner
+	switch round.Stage {
+	case message.TournamentStageQualifier:
+		if previous != "" && previous != winner && !slices.ContainsFunc(tournament.Rounds, func(r message.TournamentRound) bool {
+			return r.Stage == message.TournamentStageQualifier && r.Winner == previous
+		}) {
+			tournament.Finalists = slices.DeleteFunc(tournament.Finalists, func(name string) bool { return name == previous })
+		}
+		if winner != "" && !slices.Contains(tournament.Finalists, winner) {
+			tournament.Finalists = append(tournament.Finalists, winner)
+		}
+	case message.TournamentStageFinal:
+		tournament.Champion = winner
+		tournament.Status = message.TournamentStatusFinished
+	}
+	tournament.UpdatedAt = h.clock.Now()
+	bracket := cloneTournament(*tournament)
+	t.mu.Unlock()
+
+	h.saveTournament(bracket)
+	if bracket.Status == message.TournamentStatusFinished {
+		h.Logger.Infof("Tournament %s finished, champion: %q", bracket.ID, bracket.Champion)
+	}
+}
+
+// tournamentEligible reports whether the user may submit to the round. Only finalists
+// may submit to a tournament final; every other round is open to everyone.
+func (h *Hub) tournamentEligible(username string, roundID int64) bool {
+	t := h.tournaments
+	if t == nil {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	current := t.latest()
+	if current == nil || len(current.Rounds) == 0 {
+		return true
+	}
+	last := current.Rounds[len(current.Rounds)-1]
+	if last.RoundID != roundID || last.Stage != message.TournamentStageFinal {
+		return true
+	}
+	return slices.Contains(current.Finalists, username)
+}
+
+// saveTournament persists the bracket and broadcasts it to clients.
+func (h *Hub) saveTournament(bracket Tournament) {
+	if kv := h.tournaments.kv; kv != nil {
+		if data, err := json.Marshal(bracket); err == nil {
+			if _, err := kv.Put(bracket.ID, data); err != nil {
+				h.Logger.Errorf("Failed to store tournament %s: %v", bracket.ID, err)
+			}
+		} else {
+			h.Logger.Errorf("Failed to marshal tournament %s: %v", bracket.ID, err)
+		}
+	}
+	h.BroadcastMessage(map[string]interface{}{
+		"version": "1.0",
+		"type":    "bracket_update",
+		"data":    bracket,
+	})
+}
+
+// currentTournament returns the most recent tournament, if any.
+func (h *Hub) currentTournament() (Tournament, bool) {
+	t := h.tournaments
+	if t == nil {
+		return Tournament{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current := t.latest(); current != nil {
+		return cloneTournament(*current), true
+	}
+	return Tournament{}, false
+}
+
+// Tournament returns a tournament by ID, or the most recent one for "current".
+// Tournaments no longer held in memory are read from the key-value bucket.
+func (h *Hub) Tournament(id string) (Tournament, error) {
+	t := h.tournaments
+	if t == nil {
+		return Tournament{}, ErrTournamentNotFound
+	}
+	if id == "current" {
+		if current, ok := h.currentTournament(); ok {
+			return current, nil
+		}
+		return Tournament{}, ErrTournamentNotFound
+	}
+	t.mu.Lock()
+	if bracket := t.find(id, 0); bracket != nil {
+		defer t.mu.Unlock()
+		return cloneTournament(*bracket), nil
+	}
+	t.mu.Unlock()
+
+	if t.kv == nil {
+		return Tournament{}, ErrTournamentNotFound
+	}
+	entry, err := t.kv.Get(id)
+	if errors.Is(err, nats.ErrKeyNotFound) || errors.Is(err, nats.ErrInvalidKey) {
+		return Tournament{}, ErrTournamentNotFound
+	}
+	if err != nil {
+		return Tournament{}, err
+	}
+	var tournament Tournament
+	if err := json.Unmarshal(entry.Value(), &tournament); err != nil {
+		return Tournament{}, err
+	}
+	return tournament, nil
+}
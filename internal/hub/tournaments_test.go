@@ -0,0 +1,77 @@
+package hub
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/erilali/internal/message"
+)
+
+// newTournamentHub returns a hub whose tournaments have two qualifiers and are stored in
+// kv, discarding its broadcasts.
+func newTournamentHub(t *testing.T, kv *memoryKV) *Hub {
+	t.Helper()
+	h := newHub(config.DefaultConfig(), nil, nil, nil, logger.NewLogger("test"))
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		for {
+			select {
+			case <-h.Broadcast:
+			case <-done:
+				return
+			}
+		}
+	}()
+	h.tournaments = &tournamentTracker{qualifyingRounds: 2, kv: kv}
+	if _, err := h.tournaments.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return h
+}
+
+func TestTournamentsReloaded(t *testing.T) {
+	kv := newMemoryKV()
+	h := newTournamentHub(t, kv)
+	h.tournamentRoundStarted(1)
+	h.tournamentRoundWon(1, "ada")
+	h.tournamentRoundStarted(2)
+	h.tournamentRoundWon(2, "grace")
+	h.tournamentRoundStarted(3)
+
+	restarted := newTournamentHub(t, kv)
+	current, ok := restarted.currentTournament()
+	if !ok {
+		t.Fatal("no tournament after a restart")
+	}
+	if current.Status != message.TournamentStatusFinal || !slices.Equal(current.Finalists, []string{"ada", "grace"}) {
+		t.Errorf("tournament after a restart = %+v, want the final of ada and grace", current)
+	}
+	if restarted.tournamentEligible("linus", 3) || !restarted.tournamentEligible("ada", 3) {
+		t.Error("final after a restart not restricted to the finalists")
+	}
+}
+
+func TestTournamentFinalSkippedWithoutFinalists(t *testing.T) {
+	h := newTournamentHub(t, newMemoryKV())
+	h.tournamentRoundStarted(1)
+	h.tournamentRoundWon(1, "")
+	h.tournamentRoundStarted(2)
+	h.tournamentRoundWon(2, "")
+	first, _ := h.currentTournament()
+
+	h.tournamentRoundStarted(3)
+	if !h.tournamentEligible("ada", 3) {
+		t.Error("round after qualifiers nobody won is closed to players")
+	}
+	current, _ := h.currentTournament()
+	if current.ID == first.ID || current.Status != message.TournamentStatusQualifying {
+		t.Errorf("round 3 is in %+v, want the first qualifier of a new tournament", current)
+	}
+	skipped, err := h.Tournament(first.ID)
+	if err != nil || skipped.Status != message.TournamentStatusFinished || skipped.Champion != "" {
+		t.Errorf("tournament without finalists = %+v, %v, want it finished without a champion", skipped, err)
+	}
+}
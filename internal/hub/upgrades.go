@@ -0,0 +1,109 @@
+// internal/hub/upgrades.go
+package hub
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConnectionTiming describes how a client connected, for diagnosing slow connects.
+type ConnectionTiming struct {
+	HandshakeMs    float64 `json:"handshake_ms"`               // from receiving the upgrade request to the completed upgrade
+	FirstMessageMs float64 `json:"first_message_ms,omitempty"` // from the upgrade to the client's first frame, absent until it sends one
+	TLSVersion     string  `json:"tls_version,omitempty"`      // only when this server terminates TLS, not behind a proxy
+	TLSCipher      string  `json:"tls_cipher,omitempty"`
+}
+
+// newConnectionTiming captures the handshake duration and TLS parameters of an upgrade.
+func newConnectionTiming(r *http.Request, handshake time.Duration) ConnectionTiming {
+	timing := ConnectionTiming{HandshakeMs: millis(handshake)}
+	if r.TLS != nil {
+		timing.TLSVersion = tls.VersionName(r.TLS.Version)
+		timing.TLSCipher = tls.CipherSuiteName(r.TLS.CipherSuite)
+	}
+	return timing
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// recordFirstMessage stores the time from the upgrade to the first frame the client
+// sent. It returns the latency and true only for the first frame.
+func (c *Client) recordFirstMessage(now time.Time) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timing.FirstMessageMs > 0 || c.ConnectedAt.IsZero() {
+		return 0, false
+	}
+	latency := max(now.Sub(c.ConnectedAt), time.Microsecond)
+	c.timing.FirstMessageMs = millis(latency)
+	return latency, true
+}
+
+// UpgradeStats summarizes the upgrades completed since the server started.
+type UpgradeStats struct {
+	Upgrades          uint64            `json:"upgrades"`
+	AvgHandshakeMs    float64           `json:"avg_handshake_ms"`
+	MaxHandshakeMs    float64           `json:"max_handshake_ms"`
+	FirstMessages     uint64            `json:"first_messages"` // connections that sent a frame
+	AvgFirstMessageMs float64           `json:"avg_first_message_ms"`
+	MaxFirstMessageMs float64           `json:"max_first_message_ms"`
+	TLSVersions       map[string]uint64 `json:"tls_versions,omitempty"` // upgrades by TLS version
+}
+
+// upgradeTimings accumulates the connection timings of a hub and its rooms.
+type upgradeTimings struct {
+	mu    sync.Mutex
+	stats UpgradeStats
+	// totals for the averages
+	handshakes, firstMessages time.Duration
+}
+
+func newUpgradeTimings() *upgradeTimings {
+	return &upgradeTimings{stats: UpgradeStats{TLSVersions: make(map[string]uint64)}}
+}
+
+func (t *upgradeTimings) upgraded(handshake time.Duration, timing ConnectionTiming) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Upgrades++
+	t.handshakes += handshake
+	t.stats.MaxHandshakeMs = max(t.stats.MaxHandshakeMs, timing.HandshakeMs)
+	if timing.TLSVersion != "" {
+		t.stats.TLSVersions[timing.TLSVersion]++
+	}
+}
+
+func (t *upgradeTimings) firstMessage(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.FirstMessages++
+	t.firstMessages += latency
+	t.stats.MaxFirstMessageMs = max(t.stats.MaxFirstMessageMs, millis(latency))
+}
+
+func (t *upgradeTimings) snapshot() UpgradeStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.stats
+	if stats.Upgrades > 0 {
+		stats.AvgHandshakeMs = millis(t.handshakes / time.Duration(stats.Upgrades))
+	}
+	if stats.FirstMessages > 0 {
+		stats.AvgFirstMessageMs = millis(t.firstMessages / time.Duration(stats.FirstMessages))
+	}
+	stats.TLSVersions = make(map[string]uint64, len(t.stats.TLSVersions))
+	for version, n := range t.stats.TLSVersions {
+		stats.TLSVersions[version] = n
+	}
+	return stats
+}
+
+// UpgradeStats returns the handshake and first message latencies of the connections
+// to this hub and its rooms.
+func (h *Hub) UpgradeStats() UpgradeStats {
+	return h.upgrades.snapshot()
+}
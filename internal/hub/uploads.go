@@ -0,0 +1,49 @@
+// internal/hub/uploads.go
+package hub
+
+import (
+	"errors"
+	"time"
+
+	"github.com/erilali/internal/attachments"
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	attachmentsBucket = "ATTACHMENTS"
+	attachmentTTL     = 24 * time.Hour
+)
+
+// ErrUploadAuth is returned for uploads without the resume token of a player allowed to
+// submit.
+var ErrUploadAuth = errors.New("a resume token of a player allowed to submit is required")
+
+// newAttachmentStore opens the attachment Object Store. Uploads are disabled without JetStream.
+func newAttachmentStore(js nats.JetStreamContext, bucket string, logger *logger.Logger) *attachments.Store {
+	if js == nil {
+		logger.Warn("JetStream unavailable, attachments are disabled")
+		return nil
+	}
+	store, err := attachments.NewStore(js, bucket, attachmentTTL)
+	if err != nil {
+		logger.Errorf("Error opening attachment store: %v", err)
+		return nil
+	}
+	return store
+}
+
+// AttachmentStore returns the attachment store used by the upload API, nil when disabled.
+func (h *Hub) AttachmentStore() *attachments.Store {
+	return h.Attachments
+}
+
+// AttachmentUploader returns the username an upload is stored under: the player the
+// resume token was issued to. Guests may upload only where they may submit.
+func (h *Hub) AttachmentUploader(resumeToken string) (string, error) {
+	claims, err := h.checkResumeToken(resumeToken)
+	if err != nil || claims.Guest && !h.settings().GuestsCanSubmit {
+		return "", ErrUploadAuth
+	}
+	return claims.Username, nil
+}
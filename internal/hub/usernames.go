@@ -0,0 +1,154 @@
+// internal/hub/usernames.go
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/erilali/internal/config"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// ErrInvalidUsername is wrapped by the errors of names the username policy rejects.
+	ErrInvalidUsername = errors.New("invalid username")
+	// ErrUsernameReserved is returned for names on the reserved list.
+	ErrUsernameReserved = errors.New("username is reserved")
+	// ErrServiceAccountName is returned for the names of configured service accounts,
+	// which only their token may play under.
+	ErrServiceAccountName = errors.New("username is reserved for a service account")
+	// ErrUsernameTaken is returned when a connected client uses the name in another case.
+	ErrUsernameTaken = errors.New("username is already in use")
+)
+
+// checkUsername applies the configured username policy and returns the name the player
+// is known by, which differs from username when NFKC normalization changed it.
+// Every name a player picks, at the handshake or signing in as a guest, goes through it,
+// so the names of service accounts are refused here too.
+func (h *Hub) checkUsername(username string) (string, error) {
+	cfg := h.settings()
+	username, err := checkUsername(cfg.UsernamePolicy, username)
+	if err == nil && cfg.IsServiceAccount(username) {
+		return "", ErrServiceAccountName
+	}
+	return username, err
+}
+
+// validUsername reports whether a name passes the username policy unchanged. Service
+// account and room owner names are checked with it, since they are never normalized.
+func (h *Hub) validUsername(username string) bool {
+	normalized, err := checkUsername(h.settings().UsernamePolicy, username)
+	return err == nil && normalized == username
+}
+
+// checkUsername normalizes username and checks its length, characters, reserved names
+// and the guest prefix against policy.
+func checkUsername(policy config.UsernamePolicy, username string) (string, error) {
+	if policy.NormalizeNFKC {
+		username = norm.NFKC.String(username)
+	}
+	if n := utf8.RuneCountInString(username); n < policy.MinLength || n > policy.MaxLength {
+		return "", fmt.Errorf("%w: must be %d-%d characters", ErrInvalidUsername, policy.MinLength, policy.MaxLength)
+	}
+	for _, char := range username {
+		if !usernameRuneAllowed(policy, char) {
+			return "", fmt.Errorf("%w: %q is not allowed", ErrInvalidUsername, char)
+		}
+	}
+	if hasGuestPrefix(username) {
+		return "", fmt.Errorf("%w: must not start with %s", ErrInvalidUsername, guestPrefix)
+	}
+	for _, reserved := range policy.Reserved {
+		if policy.NormalizeNFKC {
+			reserved = norm.NFKC.String(reserved)
+		}
+		if strings.EqualFold(username, reserved) {
+			return "", ErrUsernameReserved
+		}
+	}
+	return username, nil
+}
+
+// hasGuestPrefix reports whether username starts with guestPrefix in any case, so no
+// name shares a case-insensitive usernameKey with a guest.
+func hasGuestPrefix(username string) bool {
+	runes := []rune(username)
+	n := utf8.RuneCountInString(guestPrefix)
+	return len(runes) >= n && strings.EqualFold(string(runes[:n]), guestPrefix)
+}
+
+// usernameRuneAllowed reports whether char belongs to one of the policy's Unicode
+// categories or its extra characters. Without categories only ASCII letters and digits
+// are allowed, besides the extra characters.
+func usernameRuneAllowed(policy config.UsernamePolicy, char rune) bool {
+	if strings.ContainsRune(policy.ExtraCharacters, char) {
+		return true
+	}
+	if len(policy.Categories) == 0 {
+		return char < utf8.RuneSelf && (unicode.IsLetter(char) || unicode.IsDigit(char))
+	}
+	for _, category := range policy.Categories {
+		if table, ok := unicode.Categories[category]; ok && unicode.Is(table, char) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnUsernamePolicy logs categories of the username policy that Unicode does not define.
+func (h *Hub) warnUsernamePolicy() {
+	for _, category := range h.settings().UsernamePolicy.Categories {
+		if _, ok := unicode.Categories[category]; !ok {
+			h.Logger.Warnf("Unknown Unicode category %q in username_policy.categories is ignored", category)
+		}
+	}
+}
+
+// sameUsername reports whether two names belong to the same user, ignoring case when
+// the username policy is case-insensitive.
+func (h *Hub) sameUsername(a, b string) bool {
+	if h.settings().UsernamePolicy.CaseInsensitive {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// usernameKey returns the name state about a user is keyed by: the username itself, or
+// its case folding when the username policy is case-insensitive, so that "Alice" and
+// "alice" share one submission mark, ledger entry and set of statistics.
+func (h *Hub) usernameKey(username string) string {
+	if h.settings().UsernamePolicy.CaseInsensitive {
+		return foldUsername(username)
+	}
+	return username
+}
+
+// foldUsername maps every rune to the smallest rune of its simple case folding orbit,
+// so two names fold to the same string exactly when strings.EqualFold reports them equal.
+func foldUsername(username string) string {
+	return strings.Map(func(r rune) rune {
+		folded := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			folded = min(folded, f)
+		}
+		return folded
+	}, username)
+}
+
+// usernameVariantConnected reports whether a connected client uses username in another
+// case while the username policy is case-insensitive. Clients with the exact name may
+// connect more than once.
+func (h *Hub) usernameVariantConnected(username string) bool {
+	if !h.settings().UsernamePolicy.CaseInsensitive {
+		return false
+	}
+	for _, client := range h.clients.snapshot() {
+		if name := client.Username(); name != username && strings.EqualFold(name, username) {
+			return true
+		}
+	}
+	return false
+}
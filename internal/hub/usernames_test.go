@@ -0,0 +1,236 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+func TestCheckUsername(t *testing.T) {
+	ascii := config.DefaultConfig().UsernamePolicy
+	unicodeLetters := ascii
+	unicodeLetters.Categories = []string{"L", "Nd"}
+	lettersOnly := unicodeLetters
+	lettersOnly.NormalizeNFKC = false
+	marks := lettersOnly
+	marks.Categories = []string{"L", "Mn"}
+	reserved := ascii
+	reserved.Reserved = []string{"admin", "ＳＹＳＴＥＭ"}
+	noNFKC := ascii
+	noNFKC.NormalizeNFKC = false
+	short := unicodeLetters
+	short.MinLength, short.MaxLength = 2, 4
+
+	tests := []struct {
+		name     string
+		policy   config.UsernamePolicy
+		username string
+		want     string
+		err      error
+	}{
+		{"ascii name", ascii, "alice_42", "alice_42", nil},
+		{"full-width name folded", ascii, "ａｌｉｃｅ", "alice", nil},
+		{"full-width digits folded", ascii, "bob１２３", "bob123", nil},
+		{"full-width without nfkc rejected", noNFKC, "ａｌｉｃｅ", "", ErrInvalidUsername},
+		{"ligature folded", ascii, "ﬁnn", "finn", nil},
+		{"letters outside ascii rejected without categories", ascii, "zoë", "", ErrInvalidUsername},
+		{"letter category", unicodeLetters, "zoë", "zoë", nil},
+		{"letter category covers other scripts", unicodeLetters, "ユーザー", "ユーザー", nil},
+		{"decimal digit category", unicodeLetters, "user٣", "user٣", nil},
+		{"missing category rejected", lettersOnly, "e\u0301tienne", "", ErrInvalidUsername},
+		{"nonspacing mark category", marks, "e\u0301tienne", "e\u0301tienne", nil},
+		{"combining mark composed by nfkc", unicodeLetters, "e\u0301tienne", "\u00e9tienne", nil},
+		{"symbol rejected", unicodeLetters, "bob\U0001F600", "", ErrInvalidUsername},
+		{"space rejected", ascii, "bob smith", "", ErrInvalidUsername},
+		{"extra character", ascii, "bob_smith", "bob_smith", nil},
+		{"reserved name", reserved, "admin", "", ErrUsernameReserved},
+		{"reserved name in another case", reserved, "ADMIN", "", ErrUsernameReserved},
+		{"reserved name full-width", reserved, "ａｄｍｉｎ", "", ErrUsernameReserved},
+		{"full-width reserved entry", reserved, "system", "", ErrUsernameReserved},
+		{"reserved name as prefix allowed", reserved, "admiral", "admiral", nil},
+		{"guest prefix", ascii, "guest_red_panda", "", ErrInvalidUsername},
+		{"guest prefix in another case", ascii, "GUEST_red_panda", "", ErrInvalidUsername},
+		{"guest prefix full-width", ascii, "ｇｕｅｓｔ_bob", "", ErrInvalidUsername},
+		{"guest without underscore allowed", ascii, "guesthouse", "guesthouse", nil},
+		{"minimum length", short, "éé", "éé", nil},
+		{"below minimum length", short, "é", "", ErrInvalidUsername},
+		{"maximum length in runes", short, "éééé", "éééé", nil},
+		{"above maximum length in runes", short, "ééééé", "", ErrInvalidUsername},
+		{"default maximum of 20 runes", unicodeLetters, strings.Repeat("ö", 20), strings.Repeat("ö", 20), nil},
+		{"default maximum exceeded", unicodeLetters, strings.Repeat("ö", 21), "", ErrInvalidUsername},
+		{"length counted after nfkc", short, "ﬃ", "ffi", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkUsername(tt.policy, tt.username)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("checkUsername(%q) error = %v, want %v", tt.username, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkUsername(%q) error = %v", tt.username, err)
+			}
+			if got != tt.want {
+				t.Errorf("checkUsername(%q) = %q, want %q", tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckUsernameServiceAccount(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ServiceAccounts = []config.ServiceAccount{{Name: "scorebot", Token: "secret", Scope: config.ScopeRead}}
+	h := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+
+	for _, username := range []string{"scorebot", "ScoreBot", "ｓｃｏｒｅｂｏｔ"} {
+		if _, err := h.checkUsername(username); !errors.Is(err, ErrServiceAccountName) {
+			t.Errorf("checkUsername(%q) error = %v, want ErrServiceAccountName", username, err)
+		}
+	}
+	if !h.validUsername("scorebot") {
+		t.Error("service account name is not a valid username for the account itself")
+	}
+
+	guest := &Client{username: h.newGuestName("s1"), guest: true, Send: make(chan []byte, 4)}
+	h.handleAuth(guest, map[string]interface{}{"data": map[string]interface{}{"username": "ScoreBot"}})
+	if !guest.Guest() {
+		t.Errorf("guest signed in as the service account, now %q", guest.Username())
+	}
+}
+
+func TestUsernameKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	sensitive := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+	cfg.UsernamePolicy.CaseInsensitive = true
+	insensitive := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+
+	tests := []struct {
+		a, b string
+	}{
+		{"alice", "Alice"},
+		{"ALICE", "alice"},
+		{"Zoë", "ZOË"},
+		{"kelvin", "Kelvin"}, // Kelvin sign
+		{"straße", "STRAẞE"},
+		{"Σίσυφος", "ΣΊΣΥΦΟΣ"},
+	}
+	for _, tt := range tests {
+		if !strings.EqualFold(tt.a, tt.b) {
+			t.Fatalf("test names %q and %q must be equal under strings.EqualFold", tt.a, tt.b)
+		}
+		if ka, kb := insensitive.usernameKey(tt.a), insensitive.usernameKey(tt.b); ka != kb {
+			t.Errorf("case-insensitive keys of %q and %q differ: %q and %q", tt.a, tt.b, ka, kb)
+		}
+		if sensitive.usernameKey(tt.a) != tt.a {
+			t.Errorf("case-sensitive key of %q = %q, want the name itself", tt.a, sensitive.usernameKey(tt.a))
+		}
+	}
+	if insensitive.usernameKey("strasse") == insensitive.usernameKey("straße") {
+		t.Error("usernameKey folds further than strings.EqualFold")
+	}
+
+	// The submission limiter treats case variants as one user.
+	limiter := newSubmissionLimiter()
+	if !limiter.tryMark(insensitive.usernameKey("Alice")) {
+		t.Fatal("first submission refused")
+	}
+	if limiter.tryMark(insensitive.usernameKey("aLiCe")) {
+		t.Error("case variant submitted twice in one round")
+	}
+	if submissionKey(1, insensitive.usernameKey("BOB")) != submissionKey(1, insensitive.usernameKey("bob")) {
+		t.Error("case variants claim different ledger entries")
+	}
+}
+
+func TestUserStatsCaseInsensitive(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UsernamePolicy.CaseInsensitive = true
+	h := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+	h.userStats = newUserStatsStore(nil, userStatsBucket, h.Logger)
+
+	h.recordSubmission("Alice")
+	h.recordSubmission("alice")
+	stats, err := h.UserStats("ALICE")
+	if err != nil {
+		t.Fatalf("UserStats: %v", err)
+	}
+	if stats.Submissions != 2 || stats.Username != "Alice" {
+		t.Errorf("stats = %+v, want 2 submissions recorded as Alice", stats)
+	}
+}
+
+func TestModerationCaseInsensitive(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UsernamePolicy.CaseInsensitive = true
+	h := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+
+	if _, err := h.MuteUser("Alice", "spam", time.Minute, "admin"); err != nil {
+		t.Fatalf("MuteUser: %v", err)
+	}
+	if _, muted := h.mutedUntil("ALICE"); !muted {
+		t.Error("case variant of a muted user is not muted")
+	}
+	if !h.UnmuteUser("alice", "admin") {
+		t.Error("unmuting a case variant found no mute")
+	}
+	if _, muted := h.mutedUntil("Alice"); muted {
+		t.Error("mute kept after unmuting a case variant")
+	}
+
+	h.BanUser("Bob", "abuse", "admin")
+	if !h.isBanned("BOB") {
+		t.Error("case variant of a banned user is not banned")
+	}
+	if !h.UnbanUser("bob", "admin") || h.isBanned("Bob") {
+		t.Error("ban kept after unbanning a case variant")
+	}
+
+	h.youWon.hold(h.usernameKey("Carol"), map[string]interface{}{"type": "you_won"}, h.clock.Now().Add(time.Minute))
+	if _, ok := h.youWon.take(h.usernameKey("CAROL"), h.clock.Now()); !ok {
+		t.Error("win held for a case variant not delivered")
+	}
+}
+
+func TestNewGuestNameAllTaken(t *testing.T) {
+	h := newHub(config.DefaultConfig(), nil, nil, nil, logger.NewLogger("test"))
+	for _, adjective := range guestAdjectives {
+		for _, animal := range guestAnimals {
+			for n := 0; n < 100; n++ {
+				h.clients.add(&Client{username: fmt.Sprintf("%s%s_%s_%d", guestPrefix, adjective, animal, n)})
+			}
+		}
+	}
+	name := h.newGuestName("01HZX3")
+	if h.usernameConnected(name) {
+		t.Errorf("guest name %q is already connected", name)
+	}
+	if name != "guest_01hzx3" {
+		t.Errorf("guest name = %q, want the one of the session", name)
+	}
+}
+
+func TestCountConnectionsFollowsPolicy(t *testing.T) {
+	cfg := config.DefaultConfig()
+	sensitive := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+	cfg.UsernamePolicy.CaseInsensitive = true
+	insensitive := newHub(cfg, nil, nil, nil, logger.NewLogger("test"))
+
+	for _, h := range []*Hub{sensitive, insensitive} {
+		h.clients.add(&Client{username: "Alice", Send: make(chan []byte, 1)})
+		h.clients.add(&Client{username: "alice", Send: make(chan []byte, 1)})
+		h.clients.add(&Client{username: "bob", Send: make(chan []byte, 1)})
+	}
+	if got := sensitive.countConnections("ALICE"); got != 0 {
+		t.Errorf("case-sensitive count of ALICE = %d, want 0", got)
+	}
+	if got := insensitive.countConnections("ALICE"); got != 2 {
+		t.Errorf("case-insensitive count of ALICE = %d, want 2", got)
+	}
+}
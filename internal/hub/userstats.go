@@ -0,0 +1,296 @@
+// internal/hub/userstats.go
+package hub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	userStatsBucket     = "USER_STATS"
+	userStatsRetries    = 5
+	userStatsPathPrefix = "/api/users/"
+)
+
+// ErrUserStatsNotFound is returned for users without recorded statistics.
+var ErrUserStatsNotFound = errors.New("no statistics for user")
+
+// UserStats are the lifetime totals of a registered user.
+type UserStats struct {
+	Username    string    `json:"username"`
+	Submissions int       `json:"submissions"`
+	Wins        int       `json:"wins"`
+	LastSeen    time.Time `json:"last_seen"`
+	Rooms       []string  `json:"rooms_joined"`
+}
+
+// userStatsStore keeps user statistics in a JetStream key-value bucket so they survive
+// restarts, or in memory without JetStream. Changes to the bucket are queued and written
+// in the background, so no submission waits for a key-value round trip.
+type userStatsStore struct {
+	kv nats.KeyValue // nil for the in-memory store
+
+	mu       sync.Mutex
+	memory   map[string]UserStats
+	pending  map[string]*pendingStats // queued changes by usernameKey, bucket only
+	flushing bool                     // a flush is writing the queued changes
+}
+
+// pendingStats are the queued changes of one user, applied in one write.
+type pendingStats struct {
+	username string
+	changes  []func(*UserStats)
+}
+
+// newUserStatsStore opens the bucket, creating it if needed, and falls back to memory
+// when JetStream is unavailable.
+func newUserStatsStore(js nats.JetStreamContext, bucket string, logger *logger.Logger) *userStatsStore {
+	store := &userStatsStore{memory: make(map[string]UserStats), pending: make(map[string]*pendingStats)}
+	if js == nil {
+		logger.Warn("Using in-memory user statistics. Statistics will be lost on restart.")
+		return store
+	}
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Lifetime statistics per user",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		logger.Errorf("Error opening user statistics bucket, keeping statistics in memory: %v", err)
+		return store
+	}
+	store.kv = kv
+	return store
+}
+
+// userKey returns the key-value bucket key of a user: names may hold characters keys cannot.
+func userKey(username string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(username))
+}
+
+// kvConflict reports whether a key-value Create or Update failed because another writer
+// changed the key since it was read, which is worth retrying. Other errors are not.
+func kvConflict(err error) bool {
+	return errors.Is(err, nats.ErrKeyExists)
+}
+
+// get returns the statistics of the user with the given usernameKey.
+func (s *userStatsStore) get(name string) (UserStats, error) {
+	if s.kv == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		stats, ok := s.memory[name]
+		if !ok {
+			return UserStats{}, ErrUserStatsNotFound
+		}
+		return stats, nil
+	}
+	entry, err := s.kv.Get(userKey(name))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return UserStats{}, ErrUserStatsNotFound
+	}
+	if err != nil {
+		return UserStats{}, fmt.Errorf("reading statistics for %s: %w", name, err)
+	}
+	var stats UserStats
+	if err := json.Unmarshal(entry.Value(), &stats); err != nil {
+		return UserStats{}, fmt.Errorf("corrupt statistics for %s: %w", name, err)
+	}
+	return stats, nil
+}
+
+// update applies change to the statistics stored under name, the user's usernameKey,
+// using optimistic concurrency so several server instances can update the same user.
+// Statistics created by it are recorded under username.
+func (s *userStatsStore) update(name, username string, change func(*UserStats)) error {
+	if s.kv == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		stats, ok := s.memory[name]
+		if !ok {
+			stats.Username = username
+		}
+		change(&stats)
+		s.memory[name] = stats
+		return nil
+	}
+
+	key := userKey(name)
+	for attempt := 0; attempt < userStatsRetries; attempt++ {
+		stats := UserStats{Username: username}
+		var revision uint64
+		entry, err := s.kv.Get(key)
+		switch {
+		case errors.Is(err, nats.ErrKeyNotFound):
+		case err != nil:
+			return fmt.Errorf("reading statistics for %s: %w", username, err)
+		default:
+			if err := json.Unmarshal(entry.Value(), &stats); err != nil {
+				return fmt.Errorf("corrupt statistics for %s: %w", username, err)
+			}
+			revision = entry.Revision()
+		}
+
+		change(&stats)
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+		if revision == 0 {
+			_, err = s.kv.Create(key, data)
+		} else {
+			_, err = s.kv.Update(key, data, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		if !kvConflict(err) {
+			return fmt.Errorf("storing statistics for %s: %w", username, err)
+		}
+	}
+	return fmt.Errorf("updating statistics for %s: too many concurrent updates", username)
+}
+
+// queue adds change to the pending changes of the user with the given usernameKey and
+// reports whether the caller has to start a flush.
+func (s *userStatsStore) queue(name, username string, change func(*UserStats)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.pending[name]
+	if !ok {
+		pending = &pendingStats{username: username}
+		s.pending[name] = pending
+	}
+	pending.changes = append(pending.changes, change)
+	if s.flushing {
+		return false
+	}
+	s.flushing = true
+	return true
+}
+
+// flush writes the queued changes until none are left, with one update per user however
+// many changes were queued for them meanwhile. failed is called for updates that failed.
+func (s *userStatsStore) flush(failed func(username string, err error)) {
+	for {
+		s.mu.Lock()
+		batch := s.pending
+		if len(batch) == 0 {
+			s.flushing = false
+			s.mu.Unlock()
+			return
+		}
+		s.pending = make(map[string]*pendingStats)
+		s.mu.Unlock()
+
+		for name, pending := range batch {
+			err := s.update(name, pending.username, func(stats *UserStats) {
+				for _, change := range pending.changes {
+					change(stats)
+				}
+			})
+			if err != nil {
+				failed(pending.username, err)
+			}
+		}
+	}
+}
+
+// delete forgets the statistics stored under name, including earlier revisions in the
+// bucket and changes still queued.
+func (s *userStatsStore) delete(name string) error {
+	s.mu.Lock()
+	delete(s.memory, name)
+	delete(s.pending, name)
+	s.mu.Unlock()
+	if s.kv == nil {
+		return nil
+	}
+	if err := s.kv.Purge(userKey(name)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return fmt.Errorf("deleting statistics for %s: %w", name, err)
+	}
+	return nil
+}
+
+// UserStats returns the lifetime statistics of a user.
+func (h *Hub) UserStats(username string) (UserStats, error) {
+	return h.userStats.get(h.usernameKey(username))
+}
+
+// userStatsURL is where the API serves a user's statistics.
+func userStatsURL(username string) string {
+	return userStatsPathPrefix + url.PathEscape(username) + "/stats"
+}
+
+// updateUserStats records activity of a registered user. Guests are not tracked since
+// their names do not outlive the connection. Changes to the bucket are written by a
+// background task, which Stop waits for. Errors are logged.
+func (h *Hub) updateUserStats(username string, change func(*UserStats)) {
+	if isGuestName(username) {
+		return
+	}
+	failed := func(username string, err error) {
+		h.Logger.Errorf("Failed to update statistics for %s: %v", username, err)
+	}
+	if h.userStats.kv == nil {
+		if err := h.userStats.update(h.usernameKey(username), username, change); err != nil {
+			failed(username, err)
+		}
+		return
+	}
+	if h.userStats.queue(h.usernameKey(username), username, change) {
+		h.goTask(func() { h.userStats.flush(failed) })
+	}
+}
+
+// recordSeen updates when a user was last seen and the rooms they joined.
+func (h *Hub) recordSeen(username, room string) {
+	now := h.clock.Now()
+	h.updateUserStats(username, func(stats *UserStats) {
+		stats.LastSeen = now
+		for _, joined := range stats.Rooms {
+			if joined == room {
+				return
+			}
+		}
+		if room != "" {
+			stats.Rooms = append(stats.Rooms, room)
+		}
+	})
+}
+
+// recordSubmission counts an accepted submission.
+func (h *Hub) recordSubmission(username string) {
+	now := h.clock.Now()
+	h.updateUserStats(username, func(stats *UserStats) {
+		stats.Submissions++
+		stats.LastSeen = now
+	})
+}
+
+// recordWin counts a round won.
+func (h *Hub) recordWin(username string) {
+	h.updateUserStats(username, func(stats *UserStats) {
+		stats.Wins++
+	})
+}
+
+// revokeWin takes back a win invalidated by an admin.
+func (h *Hub) revokeWin(username string) {
+	h.updateUserStats(username, func(stats *UserStats) {
+		if stats.Wins > 0 {
+			stats.Wins--
+		}
+	})
+}
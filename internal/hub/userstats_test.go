@@ -0,0 +1,83 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+// newBucketStatsStore returns a user statistics store writing to kv.
+func newBucketStatsStore(kv *memoryKV) *userStatsStore {
+	store := newUserStatsStore(nil, userStatsBucket, logger.NewLogger("test"))
+	store.kv = kv
+	return store
+}
+
+func TestUserStatsQueued(t *testing.T) {
+	kv := newMemoryKV()
+	h := newHub(config.DefaultConfig(), nil, nil, nil, logger.NewLogger("test"))
+	h.userStats = newBucketStatsStore(kv)
+
+	// Hold the first write back until more submissions are queued: they are written
+	// together in one update.
+	writing, release := make(chan struct{}), make(chan struct{})
+	writes := 0
+	kv.beforeWrite = func(string) error {
+		if writes++; writes == 1 {
+			close(writing)
+			<-release
+		}
+		return nil
+	}
+	h.recordSubmission("ada")
+	<-writing
+	h.recordSubmission("ada")
+	h.recordSubmission("ada")
+	close(release)
+	h.life.tasks.Wait()
+
+	stats, err := h.UserStats("ada")
+	if err != nil {
+		t.Fatalf("UserStats: %v", err)
+	}
+	if stats.Submissions != 3 {
+		t.Errorf("submissions = %d, want 3", stats.Submissions)
+	}
+	if writes != 2 {
+		t.Errorf("%d writes, want the queued submissions written in one", writes)
+	}
+}
+
+func TestUserStatsUpdateRetries(t *testing.T) {
+	kv := newMemoryKV()
+	store := newBucketStatsStore(kv)
+	other := newBucketStatsStore(kv)
+	count := func(stats *UserStats) { stats.Submissions++ }
+
+	// Another instance writes between this one's read and write: the update is retried.
+	kv.beforeWrite = func(string) error {
+		kv.beforeWrite = nil
+		return other.update("ada", "ada", count)
+	}
+	if err := store.update("ada", "ada", count); err != nil {
+		t.Fatalf("update after a concurrent write: %v", err)
+	}
+	if stats, _ := store.get("ada"); stats.Submissions != 2 {
+		t.Errorf("submissions = %d, want both updates counted", stats.Submissions)
+	}
+
+	unavailable := errors.New("jetstream unavailable")
+	attempts := 0
+	kv.beforeWrite = func(string) error {
+		attempts++
+		return unavailable
+	}
+	if err := store.update("ada", "ada", count); !errors.Is(err, unavailable) {
+		t.Errorf("update error = %v, want the write error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("%d write attempts, want no retry after an error other than a conflict", attempts)
+	}
+}
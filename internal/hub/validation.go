@@ -0,0 +1,35 @@
+// internal/hub/validation.go
+package hub
+
+import (
+	"strings"
+
+	"github.com/erilali/internal/message"
+)
+
+// InvalidFrameCode is the error code sent for client frames that do not match the
+// protocol schema of their type.
+const InvalidFrameCode = "INVALID_FRAME"
+
+// validFrame checks a client frame against the schema of its version and type before it
+// is dispatched. Violations are answered with an INVALID_FRAME error listing each field
+// and constraint. Types without a schema are left to HandleClientMessage.
+func (h *Hub) validFrame(client *Client, frame map[string]interface{}) bool {
+	errs, known := message.ValidateClientFrame(frame)
+	if !known || len(errs) == 0 {
+		return true
+	}
+	details := make([]string, 0, len(errs))
+	for _, err := range errs {
+		details = append(details, err.Error())
+	}
+	h.Logger.Debugf("Rejected %v frame from %s: %s", frame["type"], client.Username(), strings.Join(details, "; "))
+	h.sendMessageToClient(client, map[string]interface{}{
+		"version":    "1.0",
+		"type":       "error",
+		"data":       "Invalid frame: " + strings.Join(details, "; "),
+		"error_code": InvalidFrameCode,
+		"errors":     errs,
+	})
+	return false
+}
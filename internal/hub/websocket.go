@@ -2,9 +2,13 @@
 package hub
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/erilali/internal/config"
 	"github.com/gorilla/websocket"
 )
 
@@ -15,79 +19,284 @@ const (
 )
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: true, // negotiated per client through the "hello" capabilities
+	Subprotocols:      supportedSubprotocols,
 	CheckOrigin: func(r *http.Request) bool {
-		// TODO: Implement proper origin check for production
-		// For development, allow all origins
+		// Origins are checked against ws_allowed_origins in ServeWs, where the
+		// rejection can be counted.
 		return true
 	},
 }
 
 // ServeWs upgrades the HTTP connection to a WebSocket and registers the client.
 func (h *Hub) ServeWs(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	if h.draining() {
+		h.rejectHandshake(r, HandshakeShuttingDown, "")
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.ProtoMajor != 1 {
+		// WebSockets over HTTP/2 (RFC 8441) are not supported; clients must use HTTP/1.1.
+		h.rejectHandshake(r, HandshakeHTTPVersion, "")
+		http.Error(w, "WebSocket connections require HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	if name := r.URL.Query().Get("room"); name != "" && name != h.room && h.rooms != nil {
+		h.serveRoom(w, r, name)
+		return
+	}
+
+	cfg := h.settings()
 	username := r.URL.Query().Get("username")
-	if username == "" {
+	guest := false
+	var account *config.ServiceAccount
+	if token := bearerToken(r); token != "" {
+		found, ok := cfg.ServiceAccountByToken(token)
+		if !ok {
+			h.rejectHandshake(r, HandshakeInvalidToken, username)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid service account token", http.StatusUnauthorized)
+			return
+		}
+		account = &found
+		username = found.Name
+	}
+	// A resume token restores the name, guest status and session of an earlier
+	// connection, on this instance or another one.
+	var resumed *resumeClaims
+	if token := r.URL.Query().Get("resume"); token != "" && account == nil {
+		claims, err := h.checkResumeToken(token)
+		if err != nil {
+			h.rejectHandshake(r, HandshakeInvalidResume, username)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		resumed = &claims
+		username, guest = claims.Username, claims.Guest
+	}
+	sessionID := h.idgen.NewID()
+	var usernameErr error
+	if account == nil && resumed == nil && username != "" {
+		var normalized string
+		if normalized, usernameErr = h.checkUsername(username); usernameErr == nil {
+			username = normalized
+		}
+	}
+	switch {
+	case account != nil && !h.validUsername(username):
+		h.Logger.Errorf("Service account name %q is not a valid username", username)
+		h.rejectHandshake(r, HandshakeInvalidUsername, username)
+		http.Error(w, "service account name is not a valid username", http.StatusInternalServerError)
+		return
+	case account != nil:
+		// Bots play under their account name.
+	case resumed != nil && h.sessionHeldByOther(username, resumed.SessionID):
+		h.rejectHandshake(r, HandshakeUsernameTaken, username)
+		http.Error(w, ErrUsernameTaken.Error(), http.StatusConflict)
+		return
+	case resumed != nil:
+		// The name was checked when the session began; its old connection is replaced.
+	case username == "" && cfg.GuestMode:
+		username = h.newGuestName(sessionID)
+		guest = true
+	case username == "":
+		h.rejectHandshake(r, HandshakeMissingUsername, username)
 		http.Error(w, "username is required", http.StatusBadRequest)
 		return
+	case usernameErr != nil:
+		h.rejectHandshake(r, HandshakeInvalidUsername, username)
+		http.Error(w, usernameErr.Error(), http.StatusBadRequest)
+		return
+	case h.usernameVariantConnected(username):
+		h.rejectHandshake(r, HandshakeUsernameTaken, username)
+		http.Error(w, ErrUsernameTaken.Error(), http.StatusConflict)
+		return
+	}
+
+	// Server-wide bans were checked by serveRoom unless the name came from a resume token.
+	if h.isBanned(username) || (h.parent != nil && h.parent.isBanned(username)) {
+		h.rejectHandshake(r, HandshakeBanned, username)
+		http.Error(w, "user is banned", http.StatusForbidden)
+		return
+	}
+
+	if !originAllowed(r, cfg.WebSocketAllowedOrigins) {
+		h.rejectHandshake(r, HandshakeOriginRejected, username)
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	if !checkSubprotocols(w, r) {
+		h.rejectHandshake(r, HandshakeUnsupportedSubprotocol, username)
+		return
 	}
 
-	// Validate username
-	if !validateUsername(username) {
-		http.Error(w, "invalid username: must be 3-20 characters, alphanumeric and underscore only", http.StatusBadRequest)
+	admitted := h.acquireSlot()
+	if !admitted && (!cfg.WaitingRoom || h.Occupancy().Waiting >= cfg.WaitingRoomSize) {
+		h.rejectHandshake(r, HandshakeOverCapacity, username)
+		h.rejectFull(w)
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		h.handshakes.add(HandshakeUpgradeFailed)
 		h.Logger.Errorf("WebSocket upgrade error: %v", err)
+		if admitted {
+			h.abandonSlot()
+		}
 		return
 	}
+	// Compression stays off until the client opts in with a "hello" message.
+	conn.EnableWriteCompression(false)
 
+	upgraded := time.Since(started)
+	timing := newConnectionTiming(r, upgraded)
+	h.upgrades.upgraded(upgraded, timing)
+	now := h.clock.Now()
 	client := &Client{
-		Username:   username,
-		Conn:       conn,
-		Send:       make(chan []byte, 256),
-		LastActive: time.Now(),
+		username:    username,
+		guest:       guest,
+		Conn:        conn,
+		Send:        make(chan []byte, 256),
+		LastActive:  now,
+		ConnectedAt: now,
+		SessionID:   sessionID,
+		Metadata:    h.inspector.inspect(r),
+		Subprotocol: conn.Subprotocol(),
+		ping:        newPinger(cfg),
+		timing:      timing,
+		roleToken:   r.URL.Query().Get("role_token"),
+	}
+	if resumed != nil {
+		client.SessionID = resumed.SessionID
+		client.resumed = true
+		h.closeSession(client.SessionID, client)
+	}
+	if account != nil {
+		client.account = account
+		client.frames = newFrameLimiter(cfg.BotRateLimitPerSecond, cfg.BotRateLimitBurst, now)
+	}
+
+	if guest {
+		h.sendIdentity(client)
+	} else {
+		h.loadPreferences(client)
+	}
+
+	if !admitted {
+		position, ok := h.enterWaitingRoom(client)
+		if !ok {
+			// The waiting room filled up while the connection was being upgraded.
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server full"),
+				time.Now().Add(webSocketWriteDeadline))
+			conn.Close()
+			return
+		}
+		go h.WritePump(client)
+		go h.ReadPump(client)
+		h.sendWaitingMessage(client, position)
+		h.Logger.Infof("Client %s queued in waiting room at position %d", username, position)
+		return
+	}
+
+	select {
+	case h.Register <- client:
+	case <-h.context().Done():
+		h.abandonSlot()
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"),
+			time.Now().Add(webSocketWriteDeadline))
+		conn.Close()
+		return
 	}
-	h.Register <- client
 	go h.ReadPump(client)
 	go h.WritePump(client)
+	if client.resumed {
+		h.auditClient(AuditConnect, client, "Client resumed its session", "")
+	} else {
+		h.auditClient(AuditConnect, client, "Client connected", "")
+	}
+	h.recordSeen(client.Username(), h.room)
+}
+
+// rejectFull answers an upgrade request with 503 when no connection slot is available.
+func (h *Hub) rejectFull(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(h.settings().RetryAfterSeconds))
+	http.Error(w, "server at capacity, retry later", http.StatusServiceUnavailable)
 }
 
 // ReadPump reads messages from the WebSocket connection.
 func (h *Hub) ReadPump(client *Client) {
 	defer func() {
-		h.Unregister <- client
-		client.Conn.Close()
+		if h.leaveWaitingRoom(client) {
+			close(client.Send)
+			go h.notifyWaitingPositions()
+		} else {
+			h.unregister(client)
+			h.auditClient(AuditDisconnect, client, "Client disconnected", "")
+			h.recordSeen(client.Username(), "")
+		}
+		if client.closeMessage() == nil {
+			client.Conn.Close()
+		}
 	}()
 
-	// Set read deadline and pong handler
-	client.Conn.SetReadLimit(512) // Max message size
-	client.Conn.SetReadDeadline(time.Now().Add(webSocketReadDeadline))
+	// Frame size is checked by readFrame rather than SetReadLimit, which would close the
+	// connection before the client can be told why.
+	client.Conn.SetReadDeadline(time.Now().Add(client.ping.readDeadline()))
 	client.Conn.SetPongHandler(func(string) error {
-		client.Conn.SetReadDeadline(time.Now().Add(webSocketReadDeadline))
+		now := time.Now()
+		changed := client.ping.ponged(now, h.settings())
+		client.Conn.SetReadDeadline(now.Add(client.ping.readDeadline()))
+		if changed {
+			h.reportQuality(client)
+		}
 		return nil
 	})
 
 	for {
-		var message map[string]interface{}
-		err := client.Conn.ReadJSON(&message)
+		limit := h.readLimit()
+		data, err := readFrame(client.Conn, limit)
+		if errors.Is(err, errFrameTooLarge) {
+			h.rejectLargeFrame(client, limit)
+			break
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				h.Logger.Errorf("WebSocket error for %s: %v", client.Username, err)
+				h.Logger.Errorf("WebSocket error for %s: %v", client.Username(), err)
 			}
 			break
 		}
+		message, err := decodeFrame(client.Subprotocol, data)
+		if err != nil {
+			h.Logger.Errorf("Invalid frame from %s: %v", client.Username(), err)
+			break
+		}
 
-		client.LastActive = time.Now()
-		h.HandleClientMessage(client, message)
+		client.Touch(h.clock.Now())
+		if latency, first := client.recordFirstMessage(time.Now()); first {
+			h.upgrades.firstMessage(latency)
+		}
+		if h.isWaiting(client) {
+			h.SendErrorMessage(client, "Waiting for a free slot")
+			continue
+		}
+		ctx, cancel := h.messageContext()
+		h.HandleClientMessage(ctx, client, message)
+		cancel()
 	}
 }
 
 // WritePump writes messages to the WebSocket connection.
 func (h *Hub) WritePump(client *Client) {
-	ticker := time.NewTicker(webSocketPingPeriod)
+	ticker := time.NewTicker(client.ping.period())
 	defer func() {
 		ticker.Stop()
 		client.Conn.Close()
@@ -99,23 +308,33 @@ func (h *Hub) WritePump(client *Client) {
 			client.Conn.SetWriteDeadline(time.Now().Add(webSocketWriteDeadline))
 			if !ok {
 				// The hub closed the channel.
-				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				client.Conn.WriteMessage(websocket.CloseMessage, client.closeMessage())
 				return
 			}
 
-			w, err := client.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+			if client.Subprotocol == SubprotocolMsgpack {
+				if err := h.writeMsgpack(client, message); err != nil {
+					return
+				}
+				continue
 			}
-			w.Write(message)
 
-			// Add queued messages to the current write
-			n := len(client.Send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-client.Send)
+			caps := client.Capabilities()
+			frameType := websocket.TextMessage
+			if caps.Binary {
+				frameType = websocket.BinaryMessage
 			}
+			client.Conn.EnableWriteCompression(caps.Compression)
 
+			w, err := client.Conn.NextWriter(frameType)
+			if err != nil {
+				return
+			}
+			if caps.Batch {
+				h.writeBatch(w, client, message)
+			} else {
+				w.Write(message)
+			}
 			if err := w.Close(); err != nil {
 				return
 			}
@@ -125,6 +344,49 @@ func (h *Hub) WritePump(client *Client) {
 			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return // Client connection is likely broken
 			}
+			client.ping.sent(time.Now())
+			ticker.Reset(client.ping.period())
+
+		case <-client.ping.resized:
+			// The pong that changed the interval also moved the read deadline to the new
+			// interval from now, so the next ping must go out within it.
+			ticker.Reset(client.ping.period())
+		}
+	}
+}
+
+// writeBatch writes a message and up to ws_batch_max-1 messages queued behind it as one
+// JSON array. A lone message is written as is, so batching clients must accept both.
+func (h *Hub) writeBatch(w io.Writer, client *Client, message []byte) {
+	n := min(len(client.Send), h.settings().WebSocketBatchMax-1)
+	if n <= 0 {
+		w.Write(message)
+		return
+	}
+	w.Write([]byte{'['})
+	w.Write(message)
+	for i := 0; i < n; i++ {
+		w.Write([]byte{','})
+		w.Write(<-client.Send)
+	}
+	w.Write([]byte{']'})
+}
+
+// writeMsgpack sends a message and everything queued behind it as MessagePack, one
+// binary frame per message: MessagePack clients cannot batch.
+func (h *Hub) writeMsgpack(client *Client, message []byte) error {
+	client.Conn.EnableWriteCompression(client.Capabilities().Compression)
+	n := len(client.Send)
+	for i := 0; ; i++ {
+		data, err := h.payloads.msgpack(message)
+		if err != nil {
+			h.Logger.Errorf("Failed to encode message for %s: %v", client.Username(), err)
+		} else if err := client.Conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			return err
+		}
+		if i == n {
+			return nil
 		}
+		message = <-client.Send
 	}
 }
@@ -0,0 +1,146 @@
+// internal/hub/youwon.go
+package hub
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/attachments"
+)
+
+// youWonHoldTime is how long a you_won message waits for a winner who was not
+// connected when the round ended.
+const youWonHoldTime = 10 * time.Minute
+
+// heldWin is a you_won message for a winner who was not connected.
+type heldWin struct {
+	message map[string]interface{}
+	until   time.Time
+}
+
+// heldWins keeps you_won messages until their winner connects, by usernameKey.
+type heldWins struct {
+	mu   sync.Mutex
+	wins map[string]heldWin
+}
+
+func (w *heldWins) hold(username string, message map[string]interface{}, until time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.wins == nil {
+		w.wins = make(map[string]heldWin)
+	}
+	w.wins[username] = heldWin{message: message, until: until}
+}
+
+// take removes and returns the held message of the winner with the given usernameKey
+// unless it expired by now.
+func (w *heldWins) take(username string, now time.Time) (map[string]interface{}, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for name, held := range w.wins {
+		if now.After(held.until) {
+			delete(w.wins, name)
+		}
+	}
+	held, ok := w.wins[username]
+	if !ok {
+		return nil, false
+	}
+	delete(w.wins, username)
+	return held.message, true
+}
+
+// advanceStreak counts a round won by username and returns how many rounds in a row
+// they have won. Rounds without a winner do not break a streak.
+func (h *Hub) advanceStreak(username string) int {
+	h.Mu.Lock()
+	defer h.Mu.Unlock()
+	if h.streakHolder == username {
+		h.streakCount++
+	} else {
+		h.streakHolder, h.streakCount = username, 1
+	}
+	return h.streakCount
+}
+
+// notifyWinner sends the winner a private you_won message with the round, their
+// submission, the points granted and their balance, win streak and total wins. The
+// winner_announcement broadcast may be missed or opted out of; you_won is acknowledged
+// by clients with delivery_acks and held for a winner who is not connected until they
+// connect, for up to ten minutes. A streak of 0 leaves it out, for winners drawn on appeal.
+func (h *Hub) notifyWinner(roundID int64, winner RoundMessage, points, streak int, appeal bool) {
+	message := map[string]interface{}{
+		"version":    "1.0",
+		"type":       "you_won",
+		"round_id":   roundID,
+		"message_id": winner.ID,
+		"message":    winner.Message,
+		"points":     points,
+	}
+	if winner.AttachmentID != "" {
+		message["attachment_url"] = attachments.URL(winner.AttachmentID)
+	}
+	if balance, err := h.UserPoints(winner.Username); err == nil {
+		message["balance"] = balance
+	}
+	if streak > 0 {
+		message["streak"] = streak
+	}
+	if stats, err := h.UserStats(winner.Username); err == nil && !isGuestName(winner.Username) {
+		message["wins"] = stats.Wins
+	}
+	if appeal {
+		message["appeal"] = true
+	}
+
+	delivered := false
+	for _, client := range h.clients.snapshot() {
+		if h.sameUsername(client.Username(), winner.Username) {
+			h.sendTracked(client, message)
+			delivered = true
+		}
+	}
+	if !delivered {
+		h.youWon.hold(h.usernameKey(winner.Username), message, h.clock.Now().Add(youWonHoldTime))
+	}
+}
+
+// deliverHeldWin sends a newly registered client the you_won of a round it won while
+// it was not connected.
+func (h *Hub) deliverHeldWin(client *Client) {
+	if message, ok := h.youWon.take(h.usernameKey(client.Username()), h.clock.Now()); ok {
+		h.sendTracked(client, message)
+	}
+}
+
+// sendTracked sends a message to one client and, if the client declared delivery_acks,
+// gives it a delivery_id and sends it again when it is not acknowledged in time.
+func (h *Hub) sendTracked(client *Client, message map[string]interface{}) {
+	messageType, _ := message["type"].(string)
+	if !client.Accepts(messageType) {
+		return
+	}
+	deliveryID := ""
+	if client.Capabilities().DeliveryAcks {
+		copied := make(map[string]interface{}, len(message)+1)
+		for key, value := range message {
+			copied[key] = value
+		}
+		deliveryID = h.idgen.NewID()
+		copied["delivery_id"] = deliveryID
+		message = copied
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		h.Logger.Errorf("Failed to marshal %s: %v", messageType, err)
+		return
+	}
+	select {
+	case client.Send <- data:
+		h.trackDelivery(client, OutboundMessage{Type: messageType, Data: data, DeliveryID: deliveryID})
+	default:
+		h.unregister(client)
+	}
+}
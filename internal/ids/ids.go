@@ -0,0 +1,142 @@
+// internal/ids/ids.go
+// Package ids generates the identifiers of rounds, submissions and connections.
+package ids
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Generator kinds, selected with id_generator.
+const (
+	KindULID      = "ulid"
+	KindSnowflake = "snowflake"
+)
+
+// Generator creates identifiers that do not repeat within a process. Snowflake
+// generators with distinct nodes also never collide across instances.
+type Generator interface {
+	// NewID returns an ID for a submission, delivery or connection.
+	NewID() string
+	// NewRoundID returns the ID of a round starting at now, greater than last.
+	NewRoundID(now time.Time, last int64) int64
+}
+
+// New returns the generator of the given kind. node is the snowflake node of this
+// instance; 0 derives one from instanceID.
+func New(kind string, node int, instanceID string) (Generator, error) {
+	switch kind {
+	case "", KindULID:
+		return ULID{}, nil
+	case KindSnowflake:
+		if node == 0 {
+			node = NodeFromInstance(instanceID)
+		}
+		if node < 1 || node > maxNode {
+			return nil, fmt.Errorf("snowflake node %d is outside 1-%d", node, maxNode)
+		}
+		return &Snowflake{node: int64(node)}, nil
+	default:
+		return nil, fmt.Errorf("unknown id generator %q", kind)
+	}
+}
+
+// ULID generates ULIDs for IDs and unix second timestamps for rounds, which is what a
+// single instance has always used.
+type ULID struct{}
+
+func (ULID) NewID() string { return ulid.Make().String() }
+
+// NewRoundID returns the start second; a round started within the second of the
+// previous one, e.g. right after a restart, takes the next ID.
+func (ULID) NewRoundID(now time.Time, last int64) int64 {
+	return max(now.Unix(), last+1)
+}
+
+// Snowflake layout: time since snowflakeEpoch in the high bits, then 10 bits of node and
+// 12 bits of sequence. IDs count milliseconds in 41 bits. Round IDs count seconds in 31
+// bits instead, which keeps them below 2^53 so JavaScript clients read them exactly.
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	timeShift    = nodeBits + sequenceBits
+	maxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// sequence hands out time units with a sequence number that never repeat or go back.
+type sequence struct {
+	last int64 // time unit of the last pair
+	n    int64
+}
+
+// next returns the pair for unit. When the clock stands still or goes back, pairs
+// continue from the last one, borrowing the next unit once its sequence is used up
+// rather than waiting for it.
+func (s *sequence) next(unit int64) (int64, int64) {
+	if unit > s.last {
+		s.last, s.n = unit, 0
+	} else if s.n++; s.n > maxSequence {
+		s.last, s.n = s.last+1, 0
+	}
+	return s.last, s.n
+}
+
+// Snowflake generates time-ordered IDs unique to its node.
+type Snowflake struct {
+	node int64
+
+	mu     sync.Mutex
+	ids    sequence // milliseconds
+	rounds sequence // seconds
+}
+
+// Node returns the node this generator stamps into its IDs.
+func (s *Snowflake) Node() int { return int(s.node) }
+
+func (s *Snowflake) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms, n := s.ids.next(time.Since(snowflakeEpoch).Milliseconds())
+	return strconv.FormatInt(ms<<timeShift|s.node<<sequenceBits|n, 10)
+}
+
+// NewRoundID continues after last when it is a later second than this generator's last
+// round, as after a restart within the same second.
+func (s *Snowflake) NewRoundID(now time.Time, last int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last >= maxUnixRoundID && last>>timeShift > s.rounds.last {
+		s.rounds.last, s.rounds.n = last>>timeShift, maxSequence
+	}
+	sec, n := s.rounds.next(int64(now.Sub(snowflakeEpoch) / time.Second))
+	return sec<<timeShift | s.node<<sequenceBits | n
+}
+
+// NodeFromInstance hashes an instance ID to a snowflake node. Instances with generated
+// IDs may share a node; set snowflake_node explicitly for guaranteed uniqueness.
+func NodeFromInstance(instanceID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(instanceID))
+	return int(h.Sum32()%maxNode) + 1
+}
+
+// maxUnixRoundID bounds unix second round IDs (the year 2242). Snowflake round IDs pass
+// it 35 minutes after snowflakeEpoch.
+const maxUnixRoundID = 1 << 33
+
+// RoundTime returns the start time encoded in a round ID of either generator, so rounds
+// recorded before switching generators keep their times.
+func RoundTime(roundID int64) time.Time {
+	if roundID < maxUnixRoundID {
+		return time.Unix(roundID, 0)
+	}
+	return snowflakeEpoch.Add(time.Duration(roundID>>timeShift) * time.Second)
+}
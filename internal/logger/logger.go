@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -14,14 +15,15 @@ import (
 )
 
 type LogConfig struct {
-	Level      string // debug, info, warn, error, fatal
-	LogToFile  bool
-	LogToJSON  bool
-	FilePath   string
-	MaxSize    int  // megabytes
-	MaxBackups int  // number of backups
-	MaxAge     int  // days
-	Compress   bool // compress old log files
+	Level      string `json:"level"` // debug, info, warn, error, fatal
+	LogToFile  bool   `json:"log_to_file"`
+	LogToJSON  bool   `json:"log_to_json"`
+	NoColor    bool   `json:"no_color"` // plain console output, also enabled by the NO_COLOR environment variable
+	FilePath   string `json:"file_path"`
+	MaxSize    int    `json:"max_size"`    // megabytes
+	MaxBackups int    `json:"max_backups"` // number of backups
+	MaxAge     int    `json:"max_age"`     // days
+	Compress   bool   `json:"compress"`    // compress old log files
 }
 
 func DefaultLogConfig() LogConfig {
@@ -29,6 +31,7 @@ func DefaultLogConfig() LogConfig {
 		Level:      "info",
 		LogToFile:  true,
 		LogToJSON:  true,
+		NoColor:    false,
 		FilePath:   "server.log",
 		MaxSize:    10, // 10 MB
 		MaxBackups: 5,  // 5 backups
@@ -37,6 +40,87 @@ func DefaultLogConfig() LogConfig {
 	}
 }
 
+// ANSI color codes used by the console writer only.
+const (
+	colorReset   = "\033[0m"
+	colorBold    = "\033[1m"
+	colorRed     = "\033[31m"
+	colorGreen   = "\033[32m"
+	colorYellow  = "\033[33m"
+	colorBlue    = "\033[34m"
+	colorMagenta = "\033[35m"
+	colorCyan    = "\033[36m"
+	colorWhite   = "\033[37m"
+	colorGray    = "\033[90m"
+)
+
+// ansiPattern matches ANSI escape sequences, e.g. from user supplied text.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// stripANSI removes escape sequences so JSON and file logs stay plain text.
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// newConsoleWriter builds the human readable stdout writer. Coloring lives here and
+// nowhere else, so the JSON written to files never contains escape sequences.
+func newConsoleWriter(noColor bool) zerolog.ConsoleWriter {
+	colorize := func(color string, s string) string {
+		if noColor {
+			return s
+		}
+		return color + s + colorReset
+	}
+	levelColors := map[string]string{
+		"DEBUG": colorCyan,
+		"INFO":  colorGreen,
+		"WARN":  colorYellow,
+		"ERROR": colorRed,
+		"FATAL": colorMagenta,
+	}
+	return zerolog.ConsoleWriter{
+		Out:        os.Stdout,
+		TimeFormat: "15:04:05",
+		NoColor:    noColor,
+		PartsOrder: []string{
+			zerolog.TimestampFieldName,
+			zerolog.LevelFieldName,
+			"component",
+			zerolog.MessageFieldName,
+		},
+		FieldsExclude: []string{"component"},
+		FormatLevel: func(i interface{}) string {
+			level := strings.ToUpper(fmt.Sprintf("%s", i))
+			color, ok := levelColors[level]
+			if !ok {
+				color = colorWhite
+			}
+			return colorize(color, "[ "+fmt.Sprintf("%-5s", level)+" ]")
+		},
+		FormatTimestamp: func(i interface{}) string {
+			return colorize(colorGray, fmt.Sprintf("%s", i))
+		},
+		FormatMessage: func(i interface{}) string {
+			return colorize(colorBold, fmt.Sprintf("%s", i))
+		},
+		FormatFieldName: func(i interface{}) string {
+			return colorize(colorBlue, fmt.Sprintf("%s", i)) + ": "
+		},
+		FormatFieldValue: func(i interface{}) string {
+			return colorize(colorWhite, fmt.Sprintf("%s", i))
+		},
+		FormatErrFieldName: func(i interface{}) string {
+			return colorize(colorRed, fmt.Sprintf("%s", i)) + ": "
+		},
+		FormatErrFieldValue: func(i interface{}) string {
+			return colorize(colorRed, fmt.Sprintf("%s", i))
+		},
+	}
+}
+
 func InitLogger(config LogConfig) {
 	zerolog.TimeFieldFormat = time.RFC3339
 	level, err := zerolog.ParseLevel(config.Level)
@@ -46,54 +130,8 @@ func InitLogger(config LogConfig) {
 	zerolog.SetGlobalLevel(level)
 	var writers []io.Writer
 	if !config.LogToJSON {
-		consoleWriter := zerolog.ConsoleWriter{
-			Out:        os.Stdout,
-			TimeFormat: "15:04:05",
-			NoColor:    false,
-			PartsOrder: []string{
-				zerolog.TimestampFieldName,
-				zerolog.LevelFieldName,
-				"component",
-				zerolog.MessageFieldName,
-			},
-			FieldsExclude: []string{"component"},
-			FormatLevel: func(i interface{}) string {
-				level := strings.ToUpper(fmt.Sprintf("%s", i))
-				switch level {
-				case "DEBUG":
-					return "\033[36m[ " + fmt.Sprintf("%-5s", level) + " ]\033[0m"
-				case "INFO":
-					return "\033[32m[ " + fmt.Sprintf("%-5s", level) + " ]\033[0m"
-				case "WARN":
-					return "\033[33m[ " + fmt.Sprintf("%-5s", level) + " ]\033[0m"
-				case "ERROR":
-					return "\033[31m[ " + fmt.Sprintf("%-5s", level) + " ]\033[0m"
-				case "FATAL":
-					return "\033[35m[ " + fmt.Sprintf("%-5s", level) + " ]\033[0m"
-				default:
-					return "\033[37m[ " + fmt.Sprintf("%-5s", level) + " ]\033[0m"
-				}
-			},
-			FormatTimestamp: func(i interface{}) string {
-				return fmt.Sprintf("\033[90m%s\033[0m", i)
-			},
-			FormatMessage: func(i interface{}) string {
-				return fmt.Sprintf("\033[1m%s\033[0m", i)
-			},
-			FormatFieldName: func(i interface{}) string {
-				return fmt.Sprintf("\033[34m%s\033[0m: ", i)
-			},
-			FormatFieldValue: func(i interface{}) string {
-				return fmt.Sprintf("\033[37m%s\033[0m", i)
-			},
-			FormatErrFieldName: func(i interface{}) string {
-				return fmt.Sprintf("\033[31m%s\033[0m: ", i)
-			},
-			FormatErrFieldValue: func(i interface{}) string {
-				return fmt.Sprintf("\033[31m%s\033[0m", i)
-			},
-		}
-		writers = append(writers, consoleWriter)
+		_, noColorEnv := os.LookupEnv("NO_COLOR")
+		writers = append(writers, newConsoleWriter(config.NoColor || noColorEnv))
 	} else {
 		writers = append(writers, os.Stdout)
 	}
@@ -142,18 +180,43 @@ func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
 	}
 }
 
-func (l *Logger) Debug(msg string)                       { l.logger.Debug().Msg(msg) }
-func (l *Logger) Debugf(format string, v ...interface{}) { l.logger.Debug().Msgf(format, v...) }
-func (l *Logger) Info(msg string)                        { l.logger.Info().Msg(msg) }
-func (l *Logger) Infof(format string, v ...interface{})  { l.logger.Info().Msgf(format, v...) }
-func (l *Logger) Warn(msg string)                        { l.logger.Warn().Msg(msg) }
-func (l *Logger) Warnf(format string, v ...interface{})  { l.logger.Warn().Msgf(format, v...) }
-func (l *Logger) Error(msg string)                       { l.logger.Error().Msg(msg) }
-func (l *Logger) Errorf(format string, v ...interface{}) { l.logger.Error().Msgf(format, v...) }
-func (l *Logger) Fatal(msg string)                       { l.logger.Fatal().Msg(msg) }
-func (l *Logger) Fatalf(format string, v ...interface{}) { l.logger.Fatal().Msgf(format, v...) }
+// Messages are stripped of ANSI escape sequences, which may arrive in user supplied text.
+func (l *Logger) Debug(msg string) { l.logger.Debug().Msg(stripANSI(msg)) }
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.logger.Debug().Msg(stripANSI(fmt.Sprintf(format, v...)))
+}
+func (l *Logger) Info(msg string) { l.logger.Info().Msg(stripANSI(msg)) }
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.logger.Info().Msg(stripANSI(fmt.Sprintf(format, v...)))
+}
+func (l *Logger) Warn(msg string) { l.logger.Warn().Msg(stripANSI(msg)) }
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.logger.Warn().Msg(stripANSI(fmt.Sprintf(format, v...)))
+}
+func (l *Logger) Error(msg string) { l.logger.Error().Msg(stripANSI(msg)) }
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.logger.Error().Msg(stripANSI(fmt.Sprintf(format, v...)))
+}
+func (l *Logger) Fatal(msg string) { l.logger.Fatal().Msg(stripANSI(msg)) }
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.logger.Fatal().Msg(stripANSI(fmt.Sprintf(format, v...)))
+}
+
+// LogEvent logs a game event with the event, username and round as separate fields
+// instead of formatting them into the message, so JSON output stays machine readable.
 func (l *Logger) LogEvent(level string, event string, username string, detail string) {
-	// ... (copy the LogEvent implementation from your original logger.go)
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		lvl = zerolog.InfoLevel
+	}
+	entry := l.logger.WithLevel(lvl).Str("event", event)
+	if username != "" {
+		entry = entry.Str("username", stripANSI(username))
+	}
+	if round := extractRoundNumber(detail); round != "" {
+		entry = entry.Str("round", round)
+	}
+	entry.Msg(stripANSI(detail))
 }
 
 func extractRoundNumber(detail string) string {
@@ -8,10 +8,28 @@ type Message struct {
 }
 
 type ClientMessage struct {
-	Version  string `json:"version"`
-	Type     string `json:"type"`
-	Username string `json:"username"`
-	Data     string `json:"data"`
+	Version      string         `json:"version"`
+	Type         string         `json:"type"`
+	Username     string         `json:"username,omitempty"` // ignored, the server uses the connection's username
+	Data         SubmissionData `json:"data"`
+	MessageID    string         `json:"message_id,omitempty"`    // target of edit_message / withdraw_message
+	AttachmentID string         `json:"attachment_id,omitempty"` // upload referenced by client_message
+	Choice       *int           `json:"choice,omitempty"`        // option index of client_message in choices mode
+}
+
+// EditMessage replaces the content of the submission message_id.
+type EditMessage struct {
+	Version   string         `json:"version"`
+	Type      string         `json:"type"`
+	Data      SubmissionData `json:"data"`
+	MessageID string         `json:"message_id"`
+}
+
+// WithdrawMessage withdraws the submission message_id.
+type WithdrawMessage struct {
+	Version   string `json:"version"`
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
 }
 
 type LogEntry struct {
@@ -20,6 +38,10 @@ type LogEntry struct {
 	Username  string `json:"username,omitempty"`
 	Message   string `json:"message,omitempty"`
 	Detail    string `json:"detail,omitempty"`
+	RemoteIP  string `json:"remote_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Country   string `json:"country,omitempty"`
+	SessionID string `json:"session_id,omitempty"` // connection the event happened on
 }
 
 type WSMessage struct {
@@ -28,4 +50,12 @@ type WSMessage struct {
 	Username  string `json:"username,omitempty"`
 	Data      string `json:"data"`
 	ErrorCode string `json:"error_code,omitempty"`
+	MessageID string `json:"message_id,omitempty"` // server-assigned ID carried by acks
+	RoundID   int64  `json:"round_id,omitempty"`
+
+	Errors []SchemaError `json:"errors,omitempty"` // INVALID_FRAME errors only: every constraint the frame violates
+
+	Retriable bool `json:"retriable,omitempty"` // PROCESSING_TIMEOUT errors only: the frame was not applied and may be sent again
+
+	MaxBytes int64 `json:"max_bytes,omitempty"` // MESSAGE_TOO_LARGE errors only: the read limit the frame exceeded
 }
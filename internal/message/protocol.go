@@ -0,0 +1,794 @@
+// internal/message/protocol.go
+// Describes every WebSocket message type of the protocol. The structs double as the
+// source for the JSON Schema served by /api/protocol.
+package message
+
+//go:generate go run ../../cmd/protocolgen -o ../../clients/typescript/protocol.ts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ProtocolVersion is the value of the "version" field of every message.
+const ProtocolVersion = "1.0"
+
+// Framing documents how JSON messages map to WebSocket frames.
+const Framing = "Every frame carries one JSON message, except for clients that declared batch in hello: " +
+	"their frames may carry a JSON array of messages in the order they were sent. MessagePack frames always carry one message."
+
+// Message directions.
+const (
+	ClientToServer = "client_to_server"
+	ServerToClient = "server_to_client"
+)
+
+// Capabilities describes the optional features a client declared in its "hello" message.
+// Clients that never send "hello" keep the zero value, which matches the legacy protocol.
+type Capabilities struct {
+	Compression  bool   `json:"compression" schema:"optional"`   // permessage-deflate for outgoing frames
+	Binary       bool   `json:"binary" schema:"optional"`        // send frames as binary instead of text
+	DeliveryAcks bool   `json:"delivery_acks" schema:"optional"` // client confirms round_start, winner_announcement and you_won with delivery_ack
+	Batch        bool   `json:"batch" schema:"optional"`         // client accepts several messages in one frame as a JSON array, see Framing
+	Locale       string `json:"locale,omitempty"`                // BCP 47 tag used as the lang of submissions that name none
+}
+
+// RoundMessage represents a message submitted during a round
+type RoundMessage struct {
+	ID           string    `json:"id"` // server-assigned ULID
+	Username     string    `json:"username"`
+	Message      string    `json:"message"`
+	Lang         string    `json:"lang,omitempty"`
+	AttachmentID string    `json:"attachment_id,omitempty"`
+	Choice       *int      `json:"choice,omitempty"`    // option index in choices mode
+	Bot          bool      `json:"bot,omitempty"`       // submitted by a service account
+	Encrypted    bool      `json:"encrypted,omitempty"` // message is base64 ciphertext only the room's clients can read
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Tournament stages and statuses.
+const (
+	TournamentStageQualifier = "qualifier"
+	TournamentStageFinal     = "final"
+
+	TournamentStatusQualifying = "qualifying"
+	TournamentStatusFinal      = "final"
+	TournamentStatusFinished   = "finished"
+)
+
+// TournamentRound is one round played as part of a tournament.
+type TournamentRound struct {
+	RoundID int64  `json:"round_id"`
+	Stage   string `json:"stage"`            // qualifier or final
+	Winner  string `json:"winner,omitempty"` // empty until selected or when nobody submitted
+}
+
+// Tournament chains qualifying rounds whose winners advance to a final round that only
+// they may submit to.
+type Tournament struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"` // qualifying, final or finished
+	QualifyingRounds int               `json:"qualifying_rounds"`
+	Rounds           []TournamentRound `json:"rounds"`
+	Finalists        []string          `json:"finalists"`
+	Champion         string            `json:"champion,omitempty"`
+	StartedAt        time.Time         `json:"started_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// Series statuses.
+const (
+	SeriesStatusInProgress = "in_progress"
+	SeriesStatusFinished   = "finished"
+)
+
+// SeriesRound is one round played as part of a series.
+type SeriesRound struct {
+	RoundID int64  `json:"round_id"`
+	Winner  string `json:"winner,omitempty"` // empty until selected or when nobody won
+}
+
+// SeriesStanding is the score of one user in a series.
+type SeriesStanding struct {
+	Username string `json:"username"`
+	Points   int    `json:"points"`
+	Wins     int    `json:"wins"`
+}
+
+// Winner modes of GameRules.
+const (
+	WinnerModeRandom   = "random"   // every submission has the same chance
+	WinnerModeWeighted = "weighted" // odds follow submission scores, see winner_scoring
+)
+
+// GameRules is the active rule set, so clients can validate submissions before sending.
+type GameRules struct {
+	MinMessageLength        int     `json:"min_message_length"` // characters after sanitizing
+	MaxMessageLength        int     `json:"max_message_length"`
+	SanitizeMode            string  `json:"sanitize_mode"`
+	RoundMode               string  `json:"round_mode"`                // free or choices
+	RoundDurationSeconds    int     `json:"round_duration_seconds"`    // configured round length
+	AdaptiveRounds          bool    `json:"adaptive_rounds"`           // round lengths vary around round_duration_seconds
+	RoundsPerHour           float64 `json:"rounds_per_hour"`           // at the configured round length
+	SubmissionWindowSeconds int     `json:"submission_window_seconds"` // how long submissions stay open in a round of that length
+	RoundPauseSeconds       int     `json:"round_pause_seconds"`       // between rounds
+	PacingProfile           string  `json:"pacing_profile,omitempty"`  // profile the timing above comes from
+	MaxSubmissionsPerRound  int     `json:"max_submissions_per_round"` // 0 for no cap
+	WinnerMode              string  `json:"winner_mode"`               // random or weighted
+	ScriptedRules           bool    `json:"scripted_rules"`            // a rules script may reject submissions the rules above allow
+	DuplicateContent        string  `json:"duplicate_content"`         // off, reject or group: submissions repeating an earlier one of the round
+}
+
+// Series is a best-of session spanning a fixed number of consecutive rounds. Round
+// winners earn points, and the user with the most points at the end is the champion.
+type Series struct {
+	ID        string           `json:"id"`
+	Status    string           `json:"status"` // in_progress or finished
+	Length    int              `json:"length"` // rounds in the series
+	Rounds    []SeriesRound    `json:"rounds"`
+	Standings []SeriesStanding `json:"standings"` // most points first, ties by who reached them first
+	Champion  string           `json:"champion,omitempty"`
+	StartedAt time.Time        `json:"started_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// RoomSettings are chosen by the owner when creating a private room. Round settings left
+// at 0 use the server's values.
+type RoomSettings struct {
+	Name                    string `json:"name"`
+	Owner                   string `json:"owner"`
+	Public                  bool   `json:"public"`   // joinable without a code
+	Capacity                int    `json:"capacity"` // connected clients, 0 for max_room_capacity
+	RoundDurationSeconds    int    `json:"round_duration_seconds,omitempty"`
+	SubmissionWindowSeconds int    `json:"submission_window_seconds,omitempty"`
+	PacingProfile           string `json:"pacing_profile,omitempty"` // round timing of a named profile; explicit round settings above override it
+	MaxSubmissionsPerRound  int    `json:"max_submissions_per_round,omitempty"`
+	Encrypted               bool   `json:"encrypted,omitempty"` // submissions are end-to-end encrypted, private rooms only
+}
+
+// RoomInfo describes a room without its secrets.
+type RoomInfo struct {
+	RoomSettings
+	CreatedAt   time.Time `json:"created_at"`
+	Connected   int       `json:"connected"`
+	RoundActive bool      `json:"round_active"`
+	RoundID     int64     `json:"round_id,omitempty"` // the running round
+}
+
+// Submission is the structured form of client_message and edit_message data.
+type Submission struct {
+	Text         string `json:"text" schema:"optional"` // ignored in choices mode
+	Lang         string `json:"lang,omitempty"`         // BCP 47 language tag, e.g. "en" or "pt-BR"
+	AttachmentID string `json:"attachment_id,omitempty"`
+	Choice       *int   `json:"choice,omitempty"`     // option index in choices mode, which replaces text with the option
+	Ciphertext   string `json:"ciphertext,omitempty"` // base64 ciphertext replacing text in encrypted rooms
+}
+
+// RoundChoices is the prompt and options of a round in choices mode.
+type RoundChoices struct {
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options"`
+}
+
+// ChoiceTally counts the submissions per option of a round in choices mode.
+type ChoiceTally struct {
+	RoundChoices
+	Counts         []int `json:"counts"`           // submissions per option, by index
+	WinningOptions []int `json:"winning_options"`  // options whose submissions could win
+	Answer         *int  `json:"answer,omitempty"` // the correct option, absent when the most popular one wins
+}
+
+// SubmissionData is submission data as sent by clients: either a plain string, which is
+// the text, or a Submission object.
+type SubmissionData struct {
+	Submission
+}
+
+// UnmarshalJSON accepts a string or a Submission object and rejects unknown fields.
+func (d *SubmissionData) UnmarshalJSON(raw []byte) error {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		d.Submission = Submission{Text: text}
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var submission Submission
+	if err := decoder.Decode(&submission); err != nil {
+		return fmt.Errorf("data must be a string or an object with text, lang, attachment_id and choice: %w", err)
+	}
+	d.Submission = submission
+	return nil
+}
+
+// JSONSchema describes the two accepted forms.
+func (SubmissionData) JSONSchema() map[string]interface{} {
+	object := typeSchema(reflect.TypeOf(Submission{}))
+	object["additionalProperties"] = false
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			object,
+		},
+	}
+}
+
+// HelloMessage declares the client's capabilities; the server answers with "welcome".
+type HelloMessage struct {
+	Version string       `json:"version"`
+	Type    string       `json:"type"`
+	Data    Capabilities `json:"data"`
+}
+
+// SubscribeMessage changes which optional broadcast types the client receives.
+type SubscribeMessage struct {
+	Version string        `json:"version"`
+	Type    string        `json:"type"`
+	Data    SubscribeData `json:"data"`
+}
+
+type SubscribeData struct {
+	Exclude []string `json:"exclude,omitempty"`
+	Include []string `json:"include,omitempty"`
+}
+
+// SubscribedMessage confirms a subscription change with the resulting exclusions.
+type SubscribedMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    struct {
+		Excluded []string `json:"excluded"`
+	} `json:"data"`
+}
+
+// ReactionMessage reacts with an emoji to the winner of a round in its reveal phase.
+type ReactionMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    string `json:"data"` // emoji
+	RoundID int64  `json:"round_id"`
+}
+
+// ReactionCountsMessage carries the reaction tally of a round by emoji.
+type ReactionCountsMessage struct {
+	Version string         `json:"version"`
+	Type    string         `json:"type"`
+	RoundID int64          `json:"round_id"`
+	Data    map[string]int `json:"data"`
+}
+
+// ParticipantsRequest asks for the usernames of every connected client.
+type ParticipantsRequest struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+}
+
+// ParticipantsMessage lists connected usernames in reply to a participants request, or
+// the usernames that joined or left since the previous user_joined and user_left.
+type ParticipantsMessage struct {
+	Version string   `json:"version"`
+	Type    string   `json:"type"`
+	Data    []string `json:"data"`
+	Count   int      `json:"count"` // participants connected in total
+}
+
+// PingMessage carries a timestamp in milliseconds that the peer echoes in a "pong".
+type PingMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    int64  `json:"data"`
+}
+
+// PongMessage echoes a ping timestamp. Server pongs add the server time in milliseconds.
+type PongMessage struct {
+	Version    string `json:"version"`
+	Type       string `json:"type"`
+	Data       int64  `json:"data"`
+	ServerTime int64  `json:"server_time,omitempty"`
+}
+
+// App states reported in heartbeat frames.
+const (
+	AppStateFocused      = "focused"
+	AppStateBackgrounded = "backgrounded"
+)
+
+// HeartbeatMessage reports the state of the client app alongside the WebSocket pings.
+type HeartbeatMessage struct {
+	Version string        `json:"version"`
+	Type    string        `json:"type"`
+	Data    HeartbeatData `json:"data"`
+}
+
+type HeartbeatData struct {
+	State      string `json:"state"`                         // focused or backgrounded
+	QueueDepth int    `json:"queue_depth" schema:"optional"` // messages received but not yet processed by the client
+}
+
+// TimeSyncRequest asks for a time_sync reply. data, a client timestamp, is echoed back
+// as client_time so the client can also measure the round trip.
+type TimeSyncRequest struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    int64  `json:"data" schema:"optional"`
+}
+
+// TimeSyncMessage aligns client timers with the server clock. Times are milliseconds;
+// the *_in_ms durations and monotonic_ms are measured on the server's monotonic clock, so
+// they stay exact across wall clock adjustments during long rounds.
+type TimeSyncMessage struct {
+	Version     string `json:"version"`
+	Type        string `json:"type"`
+	ServerTime  int64  `json:"server_time"`  // unix milliseconds
+	MonotonicMs int64  `json:"monotonic_ms"` // since the server started
+	ClientTime  int64  `json:"client_time,omitempty"`
+	RoundID     int64  `json:"round_id"`
+	RoundActive bool   `json:"round_active"`
+
+	// active rounds only
+	SubmissionDeadlineMs int64 `json:"submission_deadline_ms,omitempty"` // unix milliseconds
+	EndsAtMs             int64 `json:"ends_at_ms,omitempty"`             // unix milliseconds
+	SubmissionsCloseInMs int64 `json:"submissions_close_in_ms,omitempty"`
+	EndsInMs             int64 `json:"ends_in_ms,omitempty"`
+}
+
+// NudgeMessage reminds a client that has not submitted to the running round yet.
+type NudgeMessage struct {
+	Version              string `json:"version"`
+	Type                 string `json:"type"`
+	RoundID              int64  `json:"round_id"`
+	SubmissionsCloseInMs int64  `json:"submissions_close_in_ms"`
+	Message              string `json:"message"`
+}
+
+// ConnectionQualityMessage tells a client how its WebSocket pings are answered.
+type ConnectionQualityMessage struct {
+	Version             string  `json:"version"`
+	Type                string  `json:"type"`
+	Quality             string  `json:"quality"` // good or degraded
+	RTTMillis           float64 `json:"rtt_ms"`  // round trip of the last WebSocket ping
+	PingIntervalSeconds float64 `json:"ping_interval_seconds"`
+}
+
+// RoundEventMessage announces a round lifecycle change; data is the round ID.
+type RoundEventMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    int64  `json:"data"`
+	Empty   bool   `json:"empty,omitempty"` // round_end only: nobody submitted
+	Void    bool   `json:"void,omitempty"`  // round_end only: too few participants, followed by round_void
+	Seq     uint64 `json:"seq,omitempty"`   // game event sequence number, see resync_from
+
+	NextRoundInSeconds int `json:"next_round_in_seconds,omitempty"` // round_end only: pause before the next round
+
+	DeliveryID string `json:"delivery_id,omitempty"` // round_start only, echoed by delivery_ack
+
+	// round_start only, RFC3339
+	StartedAt          string `json:"started_at,omitempty"`
+	SubmissionDeadline string `json:"submission_deadline,omitempty"`
+	EndsAt             string `json:"ends_at,omitempty"`
+	PacingProfile      string `json:"pacing_profile,omitempty"` // round_start only: blitz, normal, marathon or a configured profile
+
+	Choices *RoundChoices `json:"choices,omitempty"` // round_start in choices mode only
+}
+
+// QuorumMessage reports a round short of min_participants: round_extended reopens its
+// entries with new deadlines, round_void ends it without a winner.
+type QuorumMessage struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Data            int64  `json:"data"` // round ID
+	Participants    int    `json:"participants"`
+	MinParticipants int    `json:"min_participants"`
+	Message         string `json:"message,omitempty"` // round_void only
+	Seq             uint64 `json:"seq,omitempty"`     // game event sequence number
+
+	// round_extended only, RFC3339
+	StartedAt          string `json:"started_at,omitempty"`
+	SubmissionDeadline string `json:"submission_deadline,omitempty"`
+	EndsAt             string `json:"ends_at,omitempty"`
+}
+
+// WinnerAnnouncementMessage announces the winning submission of a round.
+type WinnerAnnouncementMessage struct {
+	Version       string        `json:"version"`
+	Type          string        `json:"type"`
+	RoundID       int64         `json:"round_id"`
+	Winner        *RoundMessage `json:"winner"` // null when nobody submitted
+	TotalMessages int           `json:"total_messages"`
+	Message       string        `json:"message,omitempty"`
+	AttachmentURL string        `json:"attachment_url,omitempty"`
+	Choices       *ChoiceTally  `json:"choices,omitempty"`     // rounds in choices mode only
+	DeliveryID    string        `json:"delivery_id,omitempty"` // echoed by delivery_ack
+	Seq           uint64        `json:"seq,omitempty"`         // game event sequence number
+}
+
+// RulesUpdateMessage carries the rule set after an admin changed it.
+type RulesUpdateMessage struct {
+	Version string    `json:"version"`
+	Type    string    `json:"type"`
+	Data    GameRules `json:"data"`
+	Seq     uint64    `json:"seq,omitempty"` // game event sequence number
+}
+
+// Announcement severities, a styling hint for clients.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Announcement is a notice from the operators to every player, such as upcoming
+// maintenance or an event.
+type Announcement struct {
+	ID        string `json:"id"`
+	Severity  string `json:"severity"` // info, warning or critical
+	Title     string `json:"title,omitempty"`
+	Text      string `json:"text"`
+	SentAt    string `json:"sent_at"`              // RFC3339
+	ExpiresAt string `json:"expires_at,omitempty"` // RFC3339; until then state_sync repeats it
+}
+
+// AnnouncementMessage carries an announcement an admin sent.
+type AnnouncementMessage struct {
+	Version string       `json:"version"`
+	Type    string       `json:"type"`
+	Data    Announcement `json:"data"`
+	Seq     uint64       `json:"seq,omitempty"` // game event sequence number
+}
+
+// MuteUpdateMessage tells a client its user was muted or that the mute was lifted.
+type MuteUpdateMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Muted   bool   `json:"muted"`
+	Until   string `json:"until,omitempty"` // RFC 3339, while muted
+	Reason  string `json:"reason,omitempty"`
+}
+
+// YouWonMessage tells the winner of a round, and only them, that they won.
+type YouWonMessage struct {
+	Version       string `json:"version"`
+	Type          string `json:"type"`
+	RoundID       int64  `json:"round_id"`
+	MessageID     string `json:"message_id"` // the winning submission
+	Message       string `json:"message"`
+	AttachmentURL string `json:"attachment_url,omitempty"`
+	Points        int    `json:"points"`            // granted for this win, 0 without a points reward
+	Balance       *int64 `json:"balance,omitempty"` // points after the win, when the server keeps the ledger
+	Streak        int    `json:"streak,omitempty"`  // rounds in a row won, counting rounds that had a winner
+	Wins          int    `json:"wins,omitempty"`    // rounds won in total by a registered user
+	Appeal        bool   `json:"appeal,omitempty"`  // drawn after the first winner was invalidated
+	DeliveryID    string `json:"delivery_id,omitempty"`
+}
+
+// WinnerUpdatedMessage corrects a winner an admin invalidated on appeal.
+type WinnerUpdatedMessage struct {
+	Version       string        `json:"version"`
+	Type          string        `json:"type"`
+	RoundID       int64         `json:"round_id"`
+	Winner        *RoundMessage `json:"winner"`     // null when no eligible entrant remained
+	Supersedes    string        `json:"supersedes"` // message ID of the invalidated winner
+	Previous      string        `json:"previous"`   // username of the invalidated winner
+	Reason        string        `json:"reason"`
+	AttachmentURL string        `json:"attachment_url,omitempty"`
+	Seq           uint64        `json:"seq,omitempty"` // game event sequence number
+}
+
+// BracketUpdateMessage carries the current state of the running tournament.
+type BracketUpdateMessage struct {
+	Version string     `json:"version"`
+	Type    string     `json:"type"`
+	Data    Tournament `json:"data"`
+	Seq     uint64     `json:"seq,omitempty"` // game event sequence number when broadcast
+}
+
+// SeriesUpdateMessage carries the state of the running series at each round boundary.
+type SeriesUpdateMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    Series `json:"data"`
+	Seq     uint64 `json:"seq,omitempty"` // game event sequence number when broadcast
+}
+
+// SeriesChampionMessage announces the champion of a finished series.
+type SeriesChampionMessage struct {
+	Version   string           `json:"version"`
+	Type      string           `json:"type"`
+	SeriesID  string           `json:"series_id"`
+	Champion  string           `json:"champion"` // empty when no round of the series had a winner
+	Points    int              `json:"points"`
+	Standings []SeriesStanding `json:"standings"`
+	Seq       uint64           `json:"seq,omitempty"` // game event sequence number when broadcast
+}
+
+// AckMessage confirms a submission, edit or withdrawal.
+type AckMessage struct {
+	Version    string        `json:"version"`
+	Type       string        `json:"type"`
+	Data       string        `json:"data"`
+	MessageID  string        `json:"message_id"`
+	RoundID    int64         `json:"round_id"`
+	Duplicate  bool          `json:"duplicate,omitempty"`  // the user had already submitted this round
+	Submission *RoundMessage `json:"submission,omitempty"` // the earlier submission of a duplicate
+}
+
+// WaitingMessage tells a queued client its position in the waiting room.
+type WaitingMessage struct {
+	Version  string `json:"version"`
+	Type     string `json:"type"`
+	Data     string `json:"data"`
+	Position int    `json:"position"`
+}
+
+// AuthMessage replaces a guest's generated name with a registered one.
+type AuthMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    struct {
+		Username string `json:"username"`
+	} `json:"data"`
+}
+
+// Roles of users in a room.
+const (
+	RoleOwner     = "owner"     // created the room; moderates and assigns roles
+	RoleModerator = "moderator" // removes submissions and mutes users in the room
+	RolePlayer    = "player"    // the default
+	RoleSpectator = "spectator" // receives the room's broadcasts but may not play
+)
+
+// RoleUpdateMessage tells a client its role in the room changed.
+type RoleUpdateMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    string `json:"data"` // the new role
+}
+
+// RemoveMessageRequest asks to remove a submission of the room's rounds. Moderators only.
+type RemoveMessageRequest struct {
+	Version   string `json:"version"`
+	Type      string `json:"type"`
+	RoundID   int64  `json:"round_id"`
+	MessageID string `json:"message_id"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// MuteRequest asks to stop a user from submitting in the room for a while. Moderators only.
+type MuteRequest struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Username        string `json:"username"`
+	DurationSeconds int    `json:"duration_seconds"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// ModerationAckMessage confirms a moderator's remove_message or mute.
+type ModerationAckMessage struct {
+	Version   string `json:"version"`
+	Type      string `json:"type"`
+	Action    string `json:"action"` // remove_message or mute
+	Username  string `json:"username"`
+	RoundID   int64  `json:"round_id,omitempty"`   // remove_message
+	MessageID string `json:"message_id,omitempty"` // remove_message
+	Until     string `json:"until,omitempty"`      // mute, RFC3339
+}
+
+// IdentityMessage tells a client the name it plays under.
+type IdentityMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    struct {
+		Username    string          `json:"username"`
+		Guest       bool            `json:"guest"`
+		Preferences json.RawMessage `json:"preferences,omitempty"` // stored preferences, after signing in
+	} `json:"data"`
+}
+
+// StateSyncRound is the current round as reported by state_sync.
+type StateSyncRound struct {
+	RoundID         int64 `json:"round_id"`
+	Active          bool  `json:"active"`
+	SubmissionsOpen bool  `json:"submissions_open"`
+
+	// active rounds only, deadlines in RFC3339
+	StartedAt          string `json:"started_at,omitempty"`
+	SubmissionDeadline string `json:"submission_deadline,omitempty"`
+	EndsAt             string `json:"ends_at,omitempty"`
+	DurationSeconds    int    `json:"duration_seconds,omitempty"`
+	TimeRemainingMs    int64  `json:"time_remaining_ms,omitempty"`
+
+	Choices *RoundChoices `json:"choices,omitempty"` // active rounds in choices mode only
+}
+
+// StateSyncLastWinner is the winner of the most recent round that had one.
+type StateSyncLastWinner struct {
+	RoundID int64         `json:"round_id"`
+	Winner  *RoundMessage `json:"winner"`
+}
+
+// StateSyncMessage brings a newly registered client up to date.
+type StateSyncMessage struct {
+	Version     string               `json:"version"`
+	Type        string               `json:"type"`
+	Round       StateSyncRound       `json:"round"`
+	LastWinner  *StateSyncLastWinner `json:"last_winner"` // null until a round had a winner
+	Presence    int                  `json:"presence"`    // connected clients, including this one
+	Seq         uint64               `json:"seq"`         // latest game event sequence number, 0 before the first
+	ServerTime  string               `json:"server_time"`
+	Preferences json.RawMessage      `json:"preferences,omitempty"` // stored preferences of a registered user, see /api/users/{username}/preferences
+
+	Announcements []Announcement `json:"announcements,omitempty"` // announcements that have not expired yet
+	Role          string         `json:"role,omitempty"`          // the client's role, in rooms only
+	MutedUntil    string         `json:"muted_until,omitempty"`   // RFC 3339, while the client's user is muted
+}
+
+// ResyncFromMessage asks for the game events from sequence number data on.
+type ResyncFromMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    uint64 `json:"data"`
+}
+
+// ResyncCompleteMessage follows the replayed events of a resync_from request.
+type ResyncCompleteMessage struct {
+	Version  string `json:"version"`
+	Type     string `json:"type"`
+	From     uint64 `json:"from"`
+	To       uint64 `json:"to"`       // latest sequence number
+	Complete bool   `json:"complete"` // false when events had already been dropped; a state_sync follows
+	Replayed int    `json:"replayed"`
+	Code     string `json:"code,omitempty"` // RESYNC_UNAVAILABLE when incomplete
+}
+
+// DeliveryAckMessage confirms receipt of a broadcast; data is its delivery_id.
+type DeliveryAckMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Data    string `json:"data"`
+}
+
+// LobbySnapshotMessage lists every room to a room browser that just connected.
+type LobbySnapshotMessage struct {
+	Version string     `json:"version"`
+	Type    string     `json:"type"`
+	Rooms   []RoomInfo `json:"rooms"`
+}
+
+// RoomEventMessage carries the state of a room that was created or changed.
+type RoomEventMessage struct {
+	Version string   `json:"version"`
+	Type    string   `json:"type"`
+	Reason  string   `json:"reason,omitempty"` // room_updated only: round_started, round_ended or occupancy
+	Room    RoomInfo `json:"room"`
+}
+
+// RoomDeletedMessage names a room that was deleted.
+type RoomDeletedMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+}
+
+// ResumeTokenMessage carries a token that resumes the client's session after a
+// disconnect: pass it as ?resume= when reconnecting, on any instance.
+type ResumeTokenMessage struct {
+	Version   string    `json:"version"`
+	Type      string    `json:"type"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SessionID string    `json:"session_id"`
+	Resumed   bool      `json:"resumed"` // this connection continues an earlier session
+}
+
+// HistoryStartMessage opens a /ws/history replay.
+type HistoryStartMessage struct {
+	Version string    `json:"version"`
+	Type    string    `json:"type"`
+	From    time.Time `json:"from"`
+	Speed   float64   `json:"speed"`  // how many times faster than real time events follow each other
+	Source  string    `json:"source"` // event_bus, or memory when only the rounds kept in memory could be read
+	Events  int       `json:"events"` // events the replay holds
+}
+
+// HistoryEventMessage replays one recorded event; data is the event as published on subject.
+type HistoryEventMessage struct {
+	Version   string          `json:"version"`
+	Type      string          `json:"type"`
+	Subject   string          `json:"subject"` // rounds.started.<id>, rounds.ended.<id>, messages.<id> or winners.<id>
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// HistoryCompleteMessage ends a /ws/history replay before the server closes the connection.
+type HistoryCompleteMessage struct {
+	Version string `json:"version"`
+	Type    string `json:"type"`
+	Events  int    `json:"events"`
+}
+
+// MessageSpec describes one message type of the protocol.
+type MessageSpec struct {
+	Type        string
+	Direction   string
+	Version     string
+	Description string
+	Payload     reflect.Type
+}
+
+func spec(messageType, direction, description string, payload interface{}) MessageSpec {
+	return MessageSpec{
+		Type:        messageType,
+		Direction:   direction,
+		Version:     ProtocolVersion,
+		Description: description,
+		Payload:     reflect.TypeOf(payload),
+	}
+}
+
+// Protocol lists every message type exchanged over the WebSocket.
+var Protocol = []MessageSpec{
+	spec("hello", ClientToServer, "Declare client capabilities", HelloMessage{}),
+	spec("subscribe", ClientToServer, "Opt out of or back into optional broadcast types", SubscribeMessage{}),
+	spec("client_message", ClientToServer, "Submit a message for the active round", ClientMessage{}),
+	spec("edit_message", ClientToServer, "Replace the content of an own submission", EditMessage{}),
+	spec("withdraw_message", ClientToServer, "Withdraw an own submission", WithdrawMessage{}),
+	spec("reaction", ClientToServer, "React to a round winner during the reveal phase", ReactionMessage{}),
+	spec("ping", ClientToServer, "Measure round trip time; answered with pong", PingMessage{}),
+	spec("time_sync", ClientToServer, "Request a time_sync reply, optionally echoing a client timestamp", TimeSyncRequest{}),
+	spec("participants", ClientToServer, "Request the usernames of every connected client", ParticipantsRequest{}),
+	spec("pong", ClientToServer, "Answer a server ping", PongMessage{}),
+	spec("heartbeat", ClientToServer, "Report whether the app is focused or backgrounded and its queue depth; backgrounded clients get fewer broadcasts", HeartbeatMessage{}),
+	spec("delivery_ack", ClientToServer, "Confirm a round_start or winner_announcement by its delivery_id (clients with delivery_acks)", DeliveryAckMessage{}),
+	spec("resync_from", ClientToServer, "Replay game events from a sequence number on after detecting a gap", ResyncFromMessage{}),
+	spec("auth", ClientToServer, "Sign in as a guest under a registered name", AuthMessage{}),
+	spec("remove_message", ClientToServer, "Remove a submission in the room; owners and moderators only", RemoveMessageRequest{}),
+	spec("mute", ClientToServer, "Stop a user from submitting in the room for duration_seconds; owners and moderators only", MuteRequest{}),
+
+	spec("welcome", ServerToClient, "Negotiated capabilities in reply to hello", HelloMessage{}),
+	spec("identity", ServerToClient, "The client's generated guest name, or its registered name after auth", IdentityMessage{}),
+	spec("state_sync", ServerToClient, "Current round, time remaining, last winner and presence count, sent on registration", StateSyncMessage{}),
+	spec("resume_token", ServerToClient, "Token for resuming the session after a disconnect, sent on registration and refreshed at half its lifetime", ResumeTokenMessage{}),
+	spec("resync_complete", ServerToClient, "End of the events replayed for resync_from", ResyncCompleteMessage{}),
+	spec("subscribed", ServerToClient, "Resulting exclusions in reply to subscribe", SubscribedMessage{}),
+	spec("round_start", ServerToClient, "A round started", RoundEventMessage{}),
+	spec("submissions_closed", ServerToClient, "The submission window of the round ended", RoundEventMessage{}),
+	spec("round_end", ServerToClient, "A round ended", RoundEventMessage{}),
+	spec("round_extended", ServerToClient, "Too few users submitted; entries reopen once until the new deadlines", QuorumMessage{}),
+	spec("round_void", ServerToClient, "The round ended with fewer than min_participants participants and has no winner", QuorumMessage{}),
+	spec("winner_announcement", ServerToClient, "The winner of a round", WinnerAnnouncementMessage{}),
+	spec("winner_updated", ServerToClient, "An admin invalidated a round's winner; winner is the entrant drawn in their place", WinnerUpdatedMessage{}),
+	spec("you_won", ServerToClient, "Sent to the winner only, held until they connect if they were away; acknowledged with delivery_acks", YouWonMessage{}),
+	spec("rules_update", ServerToClient, "An admin changed the game rules; data is the new rule set, as served by /api/rules", RulesUpdateMessage{}),
+	spec("announcement", ServerToClient, "A notice from the operators, not from a player; severity hints at how prominently to show it", AnnouncementMessage{}),
+	spec("bracket_update", ServerToClient, "The tournament bracket changed", BracketUpdateMessage{}),
+	spec("series_update", ServerToClient, "The running series started a round or recorded its winner", SeriesUpdateMessage{}),
+	spec("series_champion", ServerToClient, "A series finished, with its champion and final standings", SeriesChampionMessage{}),
+	spec("participants", ServerToClient, "Usernames of every connected client in reply to participants", ParticipantsMessage{}),
+	spec("user_joined", ServerToClient, "Usernames that connected since the previous roster update, at most every participants_interval_ms; opt out by excluding user_joined", ParticipantsMessage{}),
+	spec("user_left", ServerToClient, "Usernames no longer connected since the previous roster update; opt out by excluding user_left", ParticipantsMessage{}),
+	spec("reaction_counts", ServerToClient, "Batched reaction tally for the round in its reveal phase", ReactionCountsMessage{}),
+	spec("ack", ServerToClient, "A submission, edit or withdrawal was accepted", AckMessage{}),
+	spec("error", ServerToClient, "A client message was rejected", WSMessage{}),
+	spec("ping", ServerToClient, "Latency probe; answer with pong echoing data", PingMessage{}),
+	spec("pong", ServerToClient, "Answer to a client ping", PongMessage{}),
+	spec("time_sync", ServerToClient, "Server time and the current round's deadlines, sent every time_sync_seconds and on request", TimeSyncMessage{}),
+	spec("nudge", ServerToClient, "Reminder at nudge_at_percent of the submission window for clients that have not submitted; opt out by excluding nudge", NudgeMessage{}),
+	spec("connection_quality", ServerToClient, "The connection turned degraded (a WebSocket pong slower than slow_pong_ms) or good again", ConnectionQualityMessage{}),
+	spec("message_removed", ServerToClient, "A moderator removed the client's submission; data is the reason", AckMessage{}),
+	spec("moderation_ack", ServerToClient, "A remove_message or mute was carried out", ModerationAckMessage{}),
+	spec("role_update", ServerToClient, "The room's owner changed the client's role", RoleUpdateMessage{}),
+	spec("mute_update", ServerToClient, "The client's user was muted, or the mute expired or was lifted", MuteUpdateMessage{}),
+	spec("waiting", ServerToClient, "Position in the waiting room while the server is full", WaitingMessage{}),
+	spec("admitted", ServerToClient, "Left the waiting room and joined the game", WSMessage{}),
+	spec("lobby_snapshot", ServerToClient, "Every room, sent to /ws/lobby connections when they connect", LobbySnapshotMessage{}),
+	spec("room_created", ServerToClient, "A room was created (/ws/lobby)", RoomEventMessage{}),
+	spec("room_updated", ServerToClient, "A room's round started or ended, or its occupancy changed (/ws/lobby)", RoomEventMessage{}),
+	spec("room_deleted", ServerToClient, "A room was deleted (/ws/lobby)", RoomDeletedMessage{}),
+	spec("history_start", ServerToClient, "A replay of recorded events began (/ws/history)", HistoryStartMessage{}),
+	spec("history_event", ServerToClient, "A recorded round, submission or winner event, paced as it happened (/ws/history)", HistoryEventMessage{}),
+	spec("history_complete", ServerToClient, "Every event of the replay was sent; the server closes the connection (/ws/history)", HistoryCompleteMessage{}),
+}
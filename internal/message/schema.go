@@ -0,0 +1,117 @@
+// internal/message/schema.go
+package message
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	schemaerType   = reflect.TypeOf((*schemaer)(nil)).Elem()
+)
+
+// schemaer is implemented by types whose JSON form does not follow from their fields.
+type schemaer interface {
+	JSONSchema() map[string]interface{}
+}
+
+// Schema returns the JSON Schema of the message described by spec. The "version" and
+// "type" properties are pinned to the spec's values.
+func (spec MessageSpec) Schema() map[string]interface{} {
+	schema := typeSchema(spec.Payload)
+	schema["$schema"] = schemaDialect
+	schema["title"] = spec.Type
+	if spec.Description != "" {
+		schema["description"] = spec.Description
+	}
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		properties["version"] = map[string]interface{}{"const": spec.Version}
+		properties["type"] = map[string]interface{}{"const": spec.Type}
+	}
+	return schema
+}
+
+// typeSchema builds a schema from a Go type following encoding/json's mapping.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		schema := typeSchema(t.Elem())
+		if kind, ok := schema["type"].(string); ok {
+			schema["type"] = []string{kind, "null"}
+		}
+		return schema
+	}
+	switch {
+	case t.Implements(schemaerType):
+		return reflect.Zero(t).Interface().(schemaer).JSONSchema()
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema maps exported fields to properties using their json tags. Fields of
+// embedded structs without a tag are promoted, as encoding/json does.
+// Fields without omitempty are required, unless tagged schema:"optional" because they are
+// always sent but need not be received.
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := structSchema(field.Type)
+			for property, schema := range embedded["properties"].(map[string]interface{}) {
+				properties[property] = schema
+			}
+			required = append(required, embedded["required"].([]string)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Tag.Get("schema") != "optional" {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
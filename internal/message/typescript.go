@@ -0,0 +1,216 @@
+// internal/message/typescript.go
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// typeScriptClient is appended to the generated types: a thin wrapper around a browser
+// WebSocket that sends typed messages and dispatches received ones by type.
+const typeScriptClient = `
+export type ClientMessageType = ClientMessage["type"];
+export type ServerMessageType = ServerMessage["type"];
+export type ServerMessageOf<T extends ServerMessageType> = Extract<ServerMessage, { type: T }>;
+
+/** Sends typed messages over a WebSocket and dispatches received ones by type. */
+export class GameClient {
+  private readonly handlers = new Map<string, Set<(message: ServerMessage) => void>>();
+
+  /** Opens a JSON connection, e.g. to wss://example.com/ws?username=alice. */
+  static connect(url: string): GameClient {
+    return new GameClient(new WebSocket(url));
+  }
+
+  constructor(readonly socket: WebSocket) {
+    socket.addEventListener("message", (event: MessageEvent) => {
+      if (typeof event.data !== "string") {
+        return; // MessagePack frames are not handled by this client
+      }
+      const parsed: ServerMessage | ServerMessage[] = JSON.parse(event.data);
+      for (const message of Array.isArray(parsed) ? parsed : [parsed]) {
+        this.handlers.get(message.type)?.forEach((handler) => handler(message));
+      }
+    });
+  }
+
+  send(message: ClientMessage): void {
+    this.socket.send(JSON.stringify(message));
+  }
+
+  /** Calls handler for every received message of type and returns a function removing it. */
+  on<T extends ServerMessageType>(type: T, handler: (message: ServerMessageOf<T>) => void): () => void {
+    const handlers = this.handlers.get(type) ?? new Set<(message: ServerMessage) => void>();
+    const wrapped = handler as (message: ServerMessage) => void;
+    handlers.add(wrapped);
+    this.handlers.set(type, handlers);
+    return () => handlers.delete(wrapped);
+  }
+
+  close(code?: number, reason?: string): void {
+    this.socket.close(code, reason);
+  }
+}
+`
+
+// TypeScript returns a TypeScript module with an interface for every message type of
+// Protocol, generated from the same schemas as /api/protocol, the ClientMessage and
+// ServerMessage unions and a small GameClient. Interfaces are named after the message
+// type, prefixed with Client or Server for its direction.
+func TypeScript() []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by cmd/protocolgen from internal/message. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "export const PROTOCOL_VERSION = %q;\n", ProtocolVersion)
+
+	unions := map[string][]string{}
+	for _, spec := range Protocol {
+		name := typeScriptName(spec)
+		unions[spec.Direction] = append(unions[spec.Direction], name)
+		b.WriteString("\n")
+		if spec.Description != "" {
+			fmt.Fprintf(&b, "/** %s */\n", spec.Description)
+		}
+		fmt.Fprintf(&b, "export interface %s %s\n", name, typeScriptType(spec.Schema(), 0))
+	}
+	for _, direction := range []string{ClientToServer, ServerToClient} {
+		union := "ClientMessage"
+		if direction == ServerToClient {
+			union = "ServerMessage"
+		}
+		fmt.Fprintf(&b, "\nexport type %s =\n  | %s;\n", union, strings.Join(unions[direction], "\n  | "))
+	}
+	b.WriteString(typeScriptClient)
+	return b.Bytes()
+}
+
+// typeScriptName returns the interface name of a message, e.g. ServerWinnerAnnouncement.
+func typeScriptName(spec MessageSpec) string {
+	name := "Server"
+	if spec.Direction == ClientToServer {
+		name = "Client"
+	}
+	for _, word := range strings.Split(spec.Type, "_") {
+		if word != "" {
+			name += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return name
+}
+
+// typeScriptType converts a schema built by typeSchema into a TypeScript type, indenting
+// nested object types by depth.
+func typeScriptType(schema map[string]interface{}, depth int) string {
+	if value, ok := schema["const"]; ok {
+		literal, _ := json.Marshal(value)
+		return string(literal)
+	}
+	if options, ok := schema["oneOf"].([]interface{}); ok {
+		types := make([]string, 0, len(options))
+		for _, option := range options {
+			option, _ := option.(map[string]interface{})
+			types = append(types, typeScriptType(option, depth))
+		}
+		return strings.Join(types, " | ")
+	}
+	switch kind := schema["type"].(type) {
+	case string:
+		return typeScriptKind(kind, schema, depth)
+	case []string:
+		types := make([]string, 0, len(kind))
+		for _, k := range kind {
+			types = append(types, typeScriptKind(k, schema, depth))
+		}
+		return strings.Join(types, " | ")
+	}
+	return "unknown"
+}
+
+// typeScriptKind converts one JSON Schema type of schema.
+func typeScriptKind(kind string, schema map[string]interface{}, depth int) string {
+	switch kind {
+	case "boolean", "string", "null":
+		return kind
+	case "integer", "number":
+		return "number"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		item := typeScriptType(items, depth)
+		if strings.Contains(item, " | ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			values, _ := schema["additionalProperties"].(map[string]interface{})
+			return "Record<string, " + typeScriptType(values, depth) + ">"
+		}
+		return typeScriptObject(properties, schema["required"], depth)
+	}
+	return "unknown"
+}
+
+// typeScriptObject writes an object type with version and type first and the other
+// properties sorted by name; properties that are not required are optional.
+func typeScriptObject(properties map[string]interface{}, required interface{}, depth int) string {
+	if len(properties) == 0 {
+		return "{}"
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	order := func(name string) int {
+		switch name {
+		case "version":
+			return 0
+		case "type":
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if oi, oj := order(names[i]), order(names[j]); oi != oj {
+			return oi < oj
+		}
+		return names[i] < names[j]
+	})
+	requiredNames, _ := required.([]string)
+	isRequired := make(map[string]bool, len(requiredNames))
+	for _, name := range requiredNames {
+		isRequired[name] = true
+	}
+	if depth == 0 {
+		// version and type are pinned on every message and always sent.
+		isRequired["version"], isRequired["type"] = true, true
+	}
+
+	indent := strings.Repeat("  ", depth+1)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		optional := "?"
+		if isRequired[name] {
+			optional = ""
+		}
+		fmt.Fprintf(&b, "%s%s%s: %s;\n", indent, typeScriptKey(name), optional, typeScriptType(property, depth+1))
+	}
+	b.WriteString(strings.Repeat("  ", depth) + "}")
+	return b.String()
+}
+
+// typeScriptKey quotes property names that are not valid identifiers.
+func typeScriptKey(name string) string {
+	for i, r := range name {
+		if r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		quoted, _ := json.Marshal(name)
+		return string(quoted)
+	}
+	return name
+}
@@ -0,0 +1,239 @@
+// internal/message/validate.go
+package message
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaError describes one constraint an inbound frame violates.
+type SchemaError struct {
+	Field      string `json:"field"`      // path of the offending value, e.g. "data.choice"; empty for the frame itself
+	Constraint string `json:"constraint"` // the JSON Schema keyword that failed, e.g. "type" or "required"
+	Message    string `json:"message"`
+}
+
+func (e SchemaError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// inboundSchemas holds the schema of every client to server message by version and type.
+var inboundSchemas = func() map[string]map[string]map[string]interface{} {
+	schemas := make(map[string]map[string]map[string]interface{})
+	for _, spec := range Protocol {
+		if spec.Direction != ClientToServer {
+			continue
+		}
+		if schemas[spec.Version] == nil {
+			schemas[spec.Version] = make(map[string]map[string]interface{})
+		}
+		schemas[spec.Version][spec.Type] = spec.Schema()
+	}
+	return schemas
+}()
+
+// ValidateClientFrame checks a decoded client frame against the schema of its version
+// and type. Frames without a version are checked against the current ProtocolVersion,
+// as clients predating versioned frames send none. It reports false when the type has
+// no schema, leaving unknown types to the caller.
+func ValidateClientFrame(frame map[string]interface{}) ([]SchemaError, bool) {
+	messageType, _ := frame["type"].(string)
+	version := ProtocolVersion
+	if raw, ok := frame["version"]; ok {
+		v, isString := raw.(string)
+		if _, known := inboundSchemas[v]; !isString || !known {
+			return []SchemaError{{
+				Field:      "version",
+				Constraint: "enum",
+				Message:    fmt.Sprintf("unsupported protocol version, expected one of %s", strings.Join(protocolVersions(), ", ")),
+			}}, true
+		}
+		version = v
+	}
+	schema, ok := inboundSchemas[version][messageType]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := frame["version"]; !ok {
+		frame = withVersion(frame, version)
+	}
+	var errs []SchemaError
+	validateValue(schema, frame, "", &errs)
+	return errs, true
+}
+
+// protocolVersions lists the versions inbound frames may declare.
+func protocolVersions() []string {
+	versions := make([]string, 0, len(inboundSchemas))
+	for version := range inboundSchemas {
+		versions = append(versions, fmt.Sprintf("%q", version))
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// withVersion returns a shallow copy of frame with its version set.
+func withVersion(frame map[string]interface{}, version string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(frame)+1)
+	for key, value := range frame {
+		copied[key] = value
+	}
+	copied["version"] = version
+	return copied
+}
+
+// validateValue checks a value decoded by encoding/json against the subset of JSON
+// Schema that typeSchema generates, appending a SchemaError per violation.
+func validateValue(schema map[string]interface{}, value interface{}, path string, errs *[]SchemaError) {
+	fail := func(constraint, format string, args ...interface{}) {
+		*errs = append(*errs, SchemaError{Field: path, Constraint: constraint, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if expected, ok := schema["const"]; ok && value != expected {
+		fail("const", "must be %q", expected)
+		return
+	}
+	if options, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		var last []SchemaError
+		for _, option := range options {
+			var optionErrs []SchemaError
+			validateValue(option.(map[string]interface{}), value, path, &optionErrs)
+			if len(optionErrs) == 0 {
+				matched++
+			} else {
+				last = optionErrs
+			}
+		}
+		switch {
+		case matched == 0 && len(options) > 0 && jsonType(value) == "object":
+			// Report why the object form failed, which is the more useful detail.
+			*errs = append(*errs, last...)
+		case matched != 1:
+			fail("oneOf", "must match exactly one of the accepted forms")
+		}
+		return
+	}
+	if !typeMatches(schema["type"], value) {
+		fail("type", "must be %s, got %s", describeType(schema["type"]), jsonType(value))
+		return
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if minimum, ok := schema["minimum"].(int); ok && v < float64(minimum) {
+			fail("minimum", "must be at least %d", minimum)
+		}
+	case string:
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				fail("format", "must be an RFC 3339 date-time")
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		validateObject(schema, v, path, errs)
+	}
+}
+
+// validateObject checks required, properties and additionalProperties of an object.
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string, errs *[]SchemaError) {
+	required, _ := schema["required"].([]string)
+	for _, name := range required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, SchemaError{Field: joinPath(path, name), Constraint: "required", Message: "is required"})
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			validateValue(property, object[name], joinPath(path, name), errs)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, SchemaError{Field: joinPath(path, name), Constraint: "additionalProperties", Message: "is not allowed"})
+			}
+		case map[string]interface{}:
+			validateValue(additional, object[name], joinPath(path, name), errs)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// typeMatches reports whether value has the schema type, a name or a list of names.
+// Missing types match anything.
+func typeMatches(schemaType interface{}, value interface{}) bool {
+	switch t := schemaType.(type) {
+	case string:
+		return hasType(t, value)
+	case []string:
+		for _, name := range t {
+			if hasType(name, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func hasType(name string, value interface{}) bool {
+	if name == "integer" {
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number) && !math.IsInf(number, 0)
+	}
+	return jsonType(value) == name
+}
+
+func describeType(schemaType interface{}) string {
+	if names, ok := schemaType.([]string); ok {
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(schemaType)
+}
+
+// jsonType names the JSON type of a value decoded by encoding/json.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
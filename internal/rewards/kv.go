@@ -0,0 +1,81 @@
+// internal/rewards/kv.go
+package rewards
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+const kvUpdateRetries = 5
+
+// KVLedger stores point balances in a JetStream key-value bucket so they survive restarts.
+type KVLedger struct {
+	kv nats.KeyValue
+}
+
+// NewKVLedger opens the bucket, creating it if it does not exist yet.
+func NewKVLedger(js nats.JetStreamContext, bucket string) (*KVLedger, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Winner points ledger",
+			Storage:     nats.FileStorage,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening points bucket %s: %w", bucket, err)
+	}
+	return &KVLedger{kv: kv}, nil
+}
+
+// GrantPoints adds amount to the user's balance using optimistic concurrency,
+// retrying when another writer updated the key in between.
+func (l *KVLedger) GrantPoints(username string, roundID int64, amount int) error {
+	key := userKey(username)
+	for attempt := 0; attempt < kvUpdateRetries; attempt++ {
+		entry, err := l.kv.Get(key)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			if _, err = l.kv.Create(key, []byte(strconv.Itoa(amount))); err == nil {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading points for %s: %w", username, err)
+		}
+
+		balance, err := strconv.ParseInt(string(entry.Value()), 10, 64)
+		if err != nil {
+			return fmt.Errorf("corrupt points value for %s: %w", username, err)
+		}
+		value := strconv.FormatInt(balance+int64(amount), 10)
+		if _, err = l.kv.Update(key, []byte(value), entry.Revision()); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("granting points to %s for round %d: too many concurrent updates", username, roundID)
+}
+
+// Points returns the user's balance, zero for unknown users.
+func (l *KVLedger) Points(username string) (int64, error) {
+	entry, err := l.kv.Get(userKey(username))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading points for %s: %w", username, err)
+	}
+	return strconv.ParseInt(string(entry.Value()), 10, 64)
+}
+
+// DeletePoints purges the user's balance, including earlier revisions of the key.
+func (l *KVLedger) DeletePoints(username string) error {
+	if err := l.kv.Purge(userKey(username)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return fmt.Errorf("deleting points for %s: %w", username, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// internal/rewards/rewards.go
+// Provides the RewardProvider extension point invoked after winner selection.
+package rewards
+
+import (
+	"encoding/base64"
+	"sync"
+)
+
+// RewardProvider grants points to a round winner.
+type RewardProvider interface {
+	GrantPoints(username string, roundID int64, amount int) error
+}
+
+// PointsReader is implemented by providers that can report a user's balance.
+type PointsReader interface {
+	Points(username string) (int64, error)
+}
+
+// PointsEraser is implemented by providers that can forget a user's balance.
+type PointsEraser interface {
+	DeletePoints(username string) error
+}
+
+// MemoryLedger keeps balances in memory. It is used when no persistent store is available.
+type MemoryLedger struct {
+	mu       sync.Mutex
+	balances map[string]int64
+}
+
+// NewMemoryLedger creates an empty in-memory ledger.
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{balances: make(map[string]int64)}
+}
+
+// GrantPoints adds amount to the user's balance.
+func (l *MemoryLedger) GrantPoints(username string, roundID int64, amount int) error {
+	l.mu.Lock()
+	l.balances[username] += int64(amount)
+	l.mu.Unlock()
+	return nil
+}
+
+// Points returns the user's balance, zero for unknown users.
+func (l *MemoryLedger) Points(username string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.balances[username], nil
+}
+
+// DeletePoints forgets the user's balance.
+func (l *MemoryLedger) DeletePoints(username string) error {
+	l.mu.Lock()
+	delete(l.balances, username)
+	l.mu.Unlock()
+	return nil
+}
+
+// userKey encodes a username into a key that is valid for NATS KV regardless of its characters.
+func userKey(username string) string {
+	return "user." + base64.RawURLEncoding.EncodeToString([]byte(username))
+}
@@ -0,0 +1,66 @@
+// internal/rewards/webhook.go
+package rewards
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const webhookTimeout = 5 * time.Second
+
+// WebhookProvider forwards grants to an external HTTP endpoint. When a secret is configured
+// the request body is signed with HMAC-SHA256 in the X-Signature header.
+type WebhookProvider struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookProvider creates a provider posting to url.
+func NewWebhookProvider(url, secret string) *WebhookProvider {
+	return &WebhookProvider{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// GrantPoints posts the grant as JSON and expects a 2xx response.
+func (p *WebhookProvider) GrantPoints(username string, roundID int64, amount int) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"username":  username,
+		"round_id":  roundID,
+		"amount":    amount,
+		"timestamp": time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.secret != "" {
+		mac := hmac.New(sha256.New, []byte(p.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling reward webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("reward webhook returned %s", resp.Status)
+	}
+	return nil
+}
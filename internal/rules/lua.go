@@ -0,0 +1,155 @@
+// internal/rules/lua.go
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/message"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Functions a rules script may define. Both are optional.
+const (
+	validateFunction = "validate" // validate(submission) -> accepted [, reason]
+	scoreFunction    = "score"    // score(message, round) -> number
+)
+
+// unsafeGlobals are removed from the base library so scripts cannot load code from disk.
+var unsafeGlobals = []string{"dofile", "loadfile", "load", "loadstring", "require"}
+
+// LuaRules runs a Lua script in a sandbox with only the base, table, string and math
+// libraries. A Lua state is single-threaded, so calls are serialized.
+type LuaRules struct {
+	mu      sync.Mutex
+	state   *lua.LState
+	timeout time.Duration // per call
+}
+
+// NewLuaRules loads and runs the script at path, which defines the validate and score
+// functions. Each later call is aborted after timeout.
+func NewLuaRules(path string, timeout time.Duration) (*LuaRules, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules script: %w", err)
+	}
+
+	state := lua.NewState(lua.Options{SkipOpenLibs: true})
+	libraries := []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	}
+	for _, lib := range libraries {
+		if err := state.CallByParam(lua.P{Fn: state.NewFunction(lib.open), Protect: true}, lua.LString(lib.name)); err != nil {
+			state.Close()
+			return nil, fmt.Errorf("opening Lua library %s: %w", lib.name, err)
+		}
+	}
+	for _, name := range unsafeGlobals {
+		state.SetGlobal(name, lua.LNil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	state.SetContext(ctx)
+	defer state.RemoveContext()
+	if err := state.DoString(string(source)); err != nil {
+		state.Close()
+		return nil, fmt.Errorf("running rules script %s: %w", path, err)
+	}
+	return &LuaRules{state: state, timeout: timeout}, nil
+}
+
+// Validate calls validate(submission) with a table of round_id, username, text, lang and
+// attachment_id. Without a validate function every submission is accepted.
+func (r *LuaRules) Validate(sub Submission) (bool, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn, ok := r.state.GetGlobal(validateFunction).(*lua.LFunction)
+	if !ok {
+		return true, "", nil
+	}
+	arg := r.state.NewTable()
+	arg.RawSetString("round_id", lua.LNumber(sub.RoundID))
+	arg.RawSetString("username", lua.LString(sub.Username))
+	arg.RawSetString("text", lua.LString(sub.Text))
+	arg.RawSetString("lang", lua.LString(sub.Lang))
+	arg.RawSetString("attachment_id", lua.LString(sub.AttachmentID))
+
+	results, err := r.call(fn, 2, arg)
+	if err != nil {
+		return true, "", fmt.Errorf("%s: %w", validateFunction, err)
+	}
+	if lua.LVAsBool(results[0]) {
+		return true, "", nil
+	}
+	reason := "Rejected by the game rules"
+	if s, ok := results[1].(lua.LString); ok && s != "" {
+		reason = string(s)
+	}
+	return false, reason, nil
+}
+
+// Score calls score(message, round) for each message, with a message table of id,
+// username, text, lang and timestamp (Unix seconds) and a round table of round_id and
+// submissions. Without a score function it returns nil.
+func (r *LuaRules) Score(roundID int64, messages []message.RoundMessage) ([]float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn, ok := r.state.GetGlobal(scoreFunction).(*lua.LFunction)
+	if !ok {
+		return nil, nil
+	}
+	round := r.state.NewTable()
+	round.RawSetString("round_id", lua.LNumber(roundID))
+	round.RawSetString("submissions", lua.LNumber(len(messages)))
+
+	scores := make([]float64, len(messages))
+	for i, msg := range messages {
+		arg := r.state.NewTable()
+		arg.RawSetString("id", lua.LString(msg.ID))
+		arg.RawSetString("username", lua.LString(msg.Username))
+		arg.RawSetString("text", lua.LString(msg.Message))
+		arg.RawSetString("lang", lua.LString(msg.Lang))
+		arg.RawSetString("timestamp", lua.LNumber(msg.Timestamp.Unix()))
+
+		results, err := r.call(fn, 1, arg, round)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", scoreFunction, err)
+		}
+		score, ok := results[0].(lua.LNumber)
+		if !ok {
+			return nil, fmt.Errorf("%s returned %s instead of a number", scoreFunction, results[0].Type())
+		}
+		scores[i] = float64(score)
+	}
+	return scores, nil
+}
+
+// call runs fn with the per-call timeout and returns its nret results. Callers must hold mu.
+func (r *LuaRules) call(fn *lua.LFunction, nret int, args ...lua.LValue) ([]lua.LValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	r.state.SetContext(ctx)
+	defer r.state.RemoveContext()
+
+	if err := r.state.CallByParam(lua.P{Fn: fn, NRet: nret, Protect: true}, args...); err != nil {
+		return nil, err
+	}
+	results := make([]lua.LValue, nret)
+	for i := range results {
+		results[i] = r.state.Get(i - nret)
+	}
+	r.state.Pop(nret)
+	return results, nil
+}
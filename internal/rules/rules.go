@@ -0,0 +1,22 @@
+// internal/rules/rules.go
+// Provides the Rules extension point for custom submission validation and winner scoring.
+package rules
+
+import "github.com/erilali/internal/message"
+
+// Submission is a client submission offered to Validate, together with who sent it and when.
+type Submission struct {
+	RoundID  int64
+	Username string
+	message.Submission
+}
+
+// Rules customizes a game variant. Validate runs for every submission and edit before it is
+// stored; Score ranks the eligible submissions of a round when its winner is selected.
+type Rules interface {
+	// Validate returns whether the submission is accepted and, if not, a reason for the client.
+	Validate(sub Submission) (bool, string, error)
+	// Score returns one score per message, the highest of which wins. It returns nil
+	// scores when the rules leave winner selection to the server.
+	Score(roundID int64, messages []message.RoundMessage) ([]float64, error)
+}
@@ -0,0 +1,168 @@
+// internal/sso/session.go
+package sso
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// SessionCookie holds the signed session of a signed-in operator.
+	SessionCookie = "admin_session"
+	flowCookie    = "admin_sso_flow"
+	flowTTL       = 10 * time.Minute
+	cookiePath    = "/api/"
+)
+
+// Session is a signed-in operator, kept in a signed cookie so any instance sharing
+// oidc_session_secret accepts it without server-side state.
+type Session struct {
+	Subject string    `json:"sub"`
+	Name    string    `json:"name"`
+	Role    string    `json:"role"` // read or admin, as config.ServiceAccount scopes
+	Expires time.Time `json:"exp"`
+}
+
+// flow is a login in progress, kept in a signed cookie between the redirect to the
+// provider and its callback.
+type flow struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	Return   string    `json:"return"`
+	Expires  time.Time `json:"exp"`
+}
+
+// StartLogin begins a login and redirects to the provider. returnTo is the local path
+// the user is sent back to once signed in.
+func (p *Provider) StartLogin(w http.ResponseWriter, r *http.Request, returnTo string) error {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, `\`) {
+		returnTo = "/api/admin/sso/session"
+	}
+	f := flow{
+		State:    randomToken(24),
+		Nonce:    randomToken(24),
+		Verifier: randomToken(32),
+		Return:   returnTo,
+		Expires:  time.Now().Add(flowTTL),
+	}
+	target, err := p.authCodeURL(r.Context(), f)
+	if err != nil {
+		return err
+	}
+	p.setCookie(w, flowCookie, p.cookies.seal(flowCookie, f), flowTTL)
+	http.Redirect(w, r, target, http.StatusFound)
+	return nil
+}
+
+// FinishLogin handles the provider's callback: it checks the state against the login
+// started by StartLogin, redeems the code and maps the user's groups to a role. On
+// success the session cookie is set and the session and return path are returned.
+func (p *Provider) FinishLogin(w http.ResponseWriter, r *http.Request) (Session, string, error) {
+	var f flow
+	cookie, err := r.Cookie(flowCookie)
+	if err != nil || p.cookies.open(flowCookie, cookie.Value, &f) != nil || time.Now().After(f.Expires) {
+		return Session{}, "", ErrInvalidFlow
+	}
+	p.setCookie(w, flowCookie, "", -1)
+	query := r.URL.Query()
+	if !hmac.Equal([]byte(query.Get("state")), []byte(f.State)) {
+		return Session{}, "", ErrInvalidFlow
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		return Session{}, "", fmt.Errorf("identity provider refused the login: %s %s", providerErr, query.Get("error_description"))
+	}
+	identity, err := p.exchange(r.Context(), query.Get("code"), f)
+	if err != nil {
+		return Session{}, "", err
+	}
+	role := p.Role(identity.Groups)
+	if role == "" {
+		return Session{Subject: identity.Subject, Name: identity.Name}, "", ErrNoRole
+	}
+	session := Session{
+		Subject: identity.Subject,
+		Name:    identity.Name,
+		Role:    role,
+		Expires: time.Now().Add(p.sessionTTL),
+	}
+	p.setCookie(w, SessionCookie, p.cookies.seal(SessionCookie, session), p.sessionTTL)
+	return session, f.Return, nil
+}
+
+// SessionFromRequest returns the unexpired session the request's cookie carries.
+func (p *Provider) SessionFromRequest(r *http.Request) (Session, bool) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return Session{}, false
+	}
+	var session Session
+	if p.cookies.open(SessionCookie, cookie.Value, &session) != nil || time.Now().After(session.Expires) {
+		return Session{}, false
+	}
+	return session, true
+}
+
+// Logout removes the session cookie. Sessions are stateless, so a copied cookie stays
+// valid until it expires; oidc_session_hours bounds that.
+func (p *Provider) Logout(w http.ResponseWriter) {
+	p.setCookie(w, SessionCookie, "", -1)
+}
+
+func (p *Provider) setCookie(w http.ResponseWriter, name, value string, ttl time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cookiePath,
+		HttpOnly: true,
+		Secure:   p.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if ttl < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(ttl / time.Second)
+	}
+	http.SetCookie(w, cookie)
+}
+
+// signer seals values into tamper-proof cookie values: base64url JSON and an HMAC-SHA256
+// over the cookie name and the JSON, so a value sealed for one cookie is refused by another.
+type signer struct {
+	key []byte
+}
+
+func (s signer) seal(purpose string, v interface{}) string {
+	data, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(purpose, payload))
+}
+
+func (s signer) open(purpose, value string, v interface{}) error {
+	payload, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return errors.New("malformed cookie")
+	}
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, s.mac(purpose, payload)) {
+		return errors.New("invalid cookie signature")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s signer) mac(purpose, payload string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(purpose + "\x00" + payload))
+	return m.Sum(nil)
+}
@@ -0,0 +1,240 @@
+package sso
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+)
+
+// startLogin begins a login and returns the provider's login page location and the
+// cookies set on the browser.
+func startLogin(t *testing.T, p *Provider, returnTo string) (string, []*http.Cookie) {
+	rec := httptest.NewRecorder()
+	if err := p.StartLogin(rec, httptest.NewRequest(http.MethodGet, "/api/admin/sso/login", nil), returnTo); err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("StartLogin answered %d, want a redirect", rec.Code)
+	}
+	return rec.Header().Get("Location"), rec.Result().Cookies()
+}
+
+// finishLogin sends the callback with cookies and returns the recorder next to the result.
+func finishLogin(p *Provider, callback *http.Request, cookies []*http.Cookie) (*httptest.ResponseRecorder, Session, string, error) {
+	for _, cookie := range cookies {
+		callback.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	session, returnTo, err := p.FinishLogin(rec, callback)
+	return rec, session, returnTo, err
+}
+
+func cookieNamed(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+
+	location, cookies := startLogin(t, p, "/ui/admin")
+	rec, session, returnTo, err := finishLogin(p, idp.authorize(t, location), cookies)
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if session.Subject != "user-1" || session.Name != "ada@example.com" || session.Role != config.ScopeAdmin {
+		t.Errorf("session = %+v, want user-1 signed in as ada@example.com with the admin role", session)
+	}
+	if returnTo != "/ui/admin" {
+		t.Errorf("return path = %q, want /ui/admin", returnTo)
+	}
+	if flow := cookieNamed(rec.Result().Cookies(), flowCookie); flow == nil || flow.MaxAge >= 0 {
+		t.Error("flow cookie not cleared after the callback")
+	}
+	sessionCookie := cookieNamed(rec.Result().Cookies(), SessionCookie)
+	if sessionCookie == nil || !sessionCookie.HttpOnly {
+		t.Fatalf("session cookie = %+v, want an HttpOnly cookie", sessionCookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/config", nil)
+	req.AddCookie(sessionCookie)
+	got, ok := p.SessionFromRequest(req)
+	if !ok || got.Subject != session.Subject || got.Role != session.Role {
+		t.Errorf("SessionFromRequest = %+v, %v, want the signed-in session", got, ok)
+	}
+}
+
+func TestLoginRoles(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+
+	tests := []struct {
+		groups []string
+		role   string
+	}{
+		{[]string{"viewers"}, config.ScopeRead},
+		{[]string{"viewers", "ops"}, config.ScopeAdmin},
+		{[]string{"guests"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		idp.mu.Lock()
+		idp.groups = tt.groups
+		idp.mu.Unlock()
+		location, cookies := startLogin(t, p, "/")
+		_, session, _, err := finishLogin(p, idp.authorize(t, location), cookies)
+		if tt.role == "" {
+			if !errors.Is(err, ErrNoRole) {
+				t.Errorf("groups %v: error = %v, want ErrNoRole", tt.groups, err)
+			}
+			continue
+		}
+		if err != nil || session.Role != tt.role {
+			t.Errorf("groups %v: role %q, error %v, want role %q", tt.groups, session.Role, err, tt.role)
+		}
+	}
+}
+
+func TestLoginReplay(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+
+	location, cookies := startLogin(t, p, "/")
+	callback := idp.authorize(t, location)
+	if _, _, _, err := finishLogin(p, callback.Clone(callback.Context()), cookies); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	firstToken := idp.lastIDToken
+
+	// The same callback again: the code was redeemed already.
+	if _, _, _, err := finishLogin(p, callback.Clone(callback.Context()), cookies); err == nil {
+		t.Error("replayed callback signed in again")
+	}
+
+	// A second login whose token endpoint answers with the first login's ID token: the
+	// token is valid but carries the first login's nonce.
+	idp.mu.Lock()
+	idp.replay = firstToken
+	idp.mu.Unlock()
+	location, cookies = startLogin(t, p, "/")
+	_, _, _, err := finishLogin(p, idp.authorize(t, location), cookies)
+	if err == nil || !strings.Contains(err.Error(), "nonce does not match") {
+		t.Errorf("replayed ID token: error = %v, want the nonce refused", err)
+	}
+}
+
+func TestFinishLoginInvalidFlow(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+
+	tests := []struct {
+		name    string
+		cookies func(cookies []*http.Cookie) []*http.Cookie
+		state   string
+	}{
+		{"missing flow cookie", func([]*http.Cookie) []*http.Cookie { return nil }, ""},
+		{"tampered flow cookie", func(cookies []*http.Cookie) []*http.Cookie {
+			cookie := *cookieNamed(cookies, flowCookie)
+			payload, signature, _ := strings.Cut(cookie.Value, ".")
+			cookie.Value = payload + "x." + signature
+			return []*http.Cookie{&cookie}
+		}, ""},
+		{"flow cookie of another login", func([]*http.Cookie) []*http.Cookie {
+			_, other := startLogin(t, p, "/")
+			return other
+		}, ""},
+		{"session cookie value as flow", func([]*http.Cookie) []*http.Cookie {
+			value := p.cookies.seal(SessionCookie, flow{State: "s", Expires: time.Now().Add(time.Minute)})
+			return []*http.Cookie{{Name: flowCookie, Value: value}}
+		}, "s"},
+		{"expired flow", func([]*http.Cookie) []*http.Cookie {
+			value := p.cookies.seal(flowCookie, flow{State: "s", Expires: time.Now().Add(-time.Second)})
+			return []*http.Cookie{{Name: flowCookie, Value: value}}
+		}, "s"},
+		{"flow cookie signed with another secret", func([]*http.Cookie) []*http.Cookie {
+			value := signer{key: []byte("other")}.seal(flowCookie, flow{State: "s", Expires: time.Now().Add(time.Minute)})
+			return []*http.Cookie{{Name: flowCookie, Value: value}}
+		}, "s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, cookies := startLogin(t, p, "/")
+			callback := idp.authorize(t, location)
+			if tt.state != "" {
+				query := callback.URL.Query()
+				query.Set("state", tt.state)
+				callback.URL.RawQuery = query.Encode()
+			}
+			if _, _, _, err := finishLogin(p, callback, tt.cookies(cookies)); !errors.Is(err, ErrInvalidFlow) {
+				t.Errorf("FinishLogin error = %v, want ErrInvalidFlow", err)
+			}
+		})
+	}
+}
+
+func TestStartLoginReturnPath(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+
+	for returnTo, want := range map[string]string{
+		"/ui/admin":           "/ui/admin",
+		"":                    "/api/admin/sso/session",
+		"https://evil.com/":   "/api/admin/sso/session",
+		"//evil.com/":         "/api/admin/sso/session",
+		`/\evil.com/`:         "/api/admin/sso/session",
+		"javascript:alert(1)": "/api/admin/sso/session",
+	} {
+		location, cookies := startLogin(t, p, returnTo)
+		_, _, got, err := finishLogin(p, idp.authorize(t, location), cookies)
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+		if got != want {
+			t.Errorf("return path for %q = %q, want %q", returnTo, got, want)
+		}
+	}
+}
+
+func TestSessionFromRequest(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+	valid := Session{Subject: "user-1", Role: config.ScopeRead, Expires: time.Now().Add(time.Hour)}
+	sealed := p.cookies.seal(SessionCookie, valid)
+	payload, signature, _ := strings.Cut(sealed, ".")
+	elevated := valid
+	elevated.Role = config.ScopeAdmin
+	elevatedPayload, _, _ := strings.Cut(p.cookies.seal(SessionCookie, elevated), ".")
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"valid", sealed, true},
+		{"expired", p.cookies.seal(SessionCookie, Session{Subject: "user-1", Role: config.ScopeAdmin, Expires: time.Now().Add(-time.Second)}), false},
+		{"flow cookie value", p.cookies.seal(flowCookie, valid), false},
+		{"another secret", signer{key: []byte("other")}.seal(SessionCookie, valid), false},
+		{"role raised under the old signature", elevatedPayload + "." + signature, false},
+		{"signature missing", payload, false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/config", nil)
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.value})
+			if _, ok := p.SessionFromRequest(req); ok != tt.ok {
+				t.Errorf("SessionFromRequest ok = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
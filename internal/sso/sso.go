@@ -0,0 +1,313 @@
+// internal/sso/sso.go
+// Package sso signs operators in to the admin routes with an OpenID Connect identity
+// provider: the authorization code flow with PKCE, ID token verification against the
+// provider's published keys, and roles derived from the user's IdP groups.
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+const (
+	// CallbackPath is where the identity provider sends the user back; oidc_redirect_url
+	// must point at it.
+	CallbackPath = "/api/admin/sso/callback"
+
+	defaultGroupsClaim  = "groups"
+	defaultSessionHours = 8
+	discoveryTTL        = time.Hour
+	httpTimeout         = 10 * time.Second
+	maxResponseBytes    = 1 << 20
+)
+
+var (
+	// ErrNoRole is returned for users none of whose groups is mapped to a role.
+	ErrNoRole = errors.New("none of the user's groups grants access to the admin API")
+	// ErrInvalidFlow is returned for callbacks that do not belong to a login started here,
+	// or whose login expired.
+	ErrInvalidFlow = errors.New("login expired or was not started from this server")
+)
+
+// Identity is the verified user of an ID token.
+type Identity struct {
+	Subject string
+	Name    string // email, preferred_username or name, whichever the token has first
+	Groups  []string
+}
+
+// Provider runs the login flow against one OpenID Connect identity provider.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	groupsClaim  string
+	groupRoles   map[string]string
+	sessionTTL   time.Duration
+	cookies      signer
+	secure       bool // cookies only over HTTPS, set when the redirect URL is https
+	client       *http.Client
+	logger       *logger.Logger
+
+	mu          sync.Mutex
+	discovery   *discovery
+	discoveryAt time.Time
+	keys        *keySet
+}
+
+// discovery is the part of the provider metadata the flow uses.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Validate checks the oidc_* settings without contacting the provider.
+func Validate(cfg config.Config) error {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+	var problems []string
+	if u, err := url.Parse(cfg.OIDCIssuer); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, "oidc_issuer must be an absolute URL")
+	}
+	if cfg.OIDCClientID == "" {
+		problems = append(problems, "oidc_client_id is required")
+	}
+	if u, err := url.Parse(cfg.OIDCRedirectURL); err != nil || u.Host == "" || u.Path != CallbackPath {
+		problems = append(problems, "oidc_redirect_url must be an absolute URL ending in "+CallbackPath)
+	}
+	if len(cfg.OIDCGroupRoles) == 0 {
+		problems = append(problems, "oidc_group_roles maps no group to a role, nobody could sign in")
+	}
+	for group, role := range cfg.OIDCGroupRoles {
+		if role != config.ScopeRead && role != config.ScopeAdmin {
+			problems = append(problems, fmt.Sprintf("oidc_group_roles: group %q has role %q, expected read or admin", group, role))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// New returns the provider configured by the oidc_* settings, or nil when oidc_issuer is
+// empty. The provider metadata is fetched on the first login, so an unreachable identity
+// provider does not keep the server from starting.
+func New(cfg config.Config, logger *logger.Logger) (*Provider, error) {
+	if cfg.OIDCIssuer == "" {
+		return nil, nil
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	secret := []byte(cfg.OIDCSessionSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+		logger.Warn("oidc_session_secret is empty: admin sessions end on restart and only work on this instance")
+	}
+	p := &Provider{
+		issuer:       strings.TrimSuffix(cfg.OIDCIssuer, "/"),
+		clientID:     cfg.OIDCClientID,
+		clientSecret: cfg.OIDCClientSecret,
+		redirectURL:  cfg.OIDCRedirectURL,
+		scopes:       append([]string{"openid"}, cfg.OIDCScopes...),
+		groupsClaim:  cfg.OIDCGroupsClaim,
+		groupRoles:   cfg.OIDCGroupRoles,
+		sessionTTL:   time.Duration(cfg.OIDCSessionHours) * time.Hour,
+		cookies:      signer{key: secret},
+		secure:       strings.HasPrefix(cfg.OIDCRedirectURL, "https://"),
+		client:       &http.Client{Timeout: httpTimeout},
+		logger:       logger,
+	}
+	if p.groupsClaim == "" {
+		p.groupsClaim = defaultGroupsClaim
+	}
+	if p.sessionTTL <= 0 {
+		p.sessionTTL = defaultSessionHours * time.Hour
+	}
+	return p, nil
+}
+
+// Role returns the highest role any of groups is mapped to, empty when none is.
+func (p *Provider) Role(groups []string) string {
+	role := ""
+	for _, group := range groups {
+		switch p.groupRoles[group] {
+		case config.ScopeAdmin:
+			return config.ScopeAdmin
+		case config.ScopeRead:
+			role = config.ScopeRead
+		}
+	}
+	return role
+}
+
+// metadata returns the provider metadata, fetching it again once it is an hour old.
+func (p *Provider) metadata(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil && time.Since(p.discoveryAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+	var d discovery
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &d); err != nil {
+		if p.discovery != nil {
+			p.logger.Warnf("Refreshing OIDC provider metadata failed, keeping the previous: %v", err)
+			return p.discovery, nil
+		}
+		return nil, fmt.Errorf("reading OIDC provider metadata: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("provider metadata names issuer %q, expected %q", d.Issuer, p.issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("provider metadata lacks the authorization, token or jwks endpoint")
+	}
+	if p.keys == nil || p.keys.uri != d.JWKSURI {
+		p.keys = newKeySet(d.JWKSURI, p)
+	}
+	p.discovery, p.discoveryAt = &d, time.Now()
+	return p.discovery, nil
+}
+
+// authCodeURL returns the provider's login page for a flow.
+func (p *Provider) authCodeURL(ctx context.Context, f flow) (string, error) {
+	d, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(f.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {f.State},
+		"nonce":                 {f.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// exchange redeems an authorization code and returns the verified identity of its ID token.
+func (p *Provider) exchange(ctx context.Context, code string, f flow) (Identity, error) {
+	d, err := p.metadata(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {f.Verifier},
+	}
+	if p.clientSecret == "" {
+		form.Set("client_id", p.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("redeeming the authorization code: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+	claims, err := p.verify(ctx, tokens.IDToken, d.Issuer, f.Nonce)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verifying the ID token: %w", err)
+	}
+	return p.identity(claims), nil
+}
+
+// identity reads the subject, display name and groups from verified claims.
+func (p *Provider) identity(claims map[string]interface{}) Identity {
+	identity := Identity{}
+	identity.Subject, _ = claims["sub"].(string)
+	for _, claim := range []string{"email", "preferred_username", "name"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			identity.Name = name
+			break
+		}
+	}
+	if identity.Name == "" {
+		identity.Name = identity.Subject
+	}
+	switch groups := claims[p.groupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	}
+	return identity
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	return p.doJSON(req, v)
+}
+
+// doJSON sends a request and decodes a successful JSON response into v.
+func (p *Provider) doJSON(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// randomToken returns n random bytes, base64url encoded.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,373 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+)
+
+const (
+	testClientID     = "cid"
+	testClientSecret = "sec"
+	testRedirectURL  = "http://game.local" + CallbackPath
+)
+
+// fakeIdP is an OpenID Connect provider serving discovery, a JWK set and a token
+// endpoint that redeems codes handed out by authorize once.
+type fakeIdP struct {
+	*httptest.Server
+	t *testing.T
+
+	mu          sync.Mutex
+	keys        map[string]crypto.Signer // published in the JWK set by key ID
+	kid         string                   // signs the ID tokens of the token endpoint
+	groups      []string
+	codes       map[string]grant
+	replay      string // returned instead of a fresh ID token when set
+	lastIDToken string
+	jwksFetches int
+}
+
+type grant struct {
+	nonce     string
+	challenge string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	idp := &fakeIdP{
+		t:      t,
+		keys:   map[string]crypto.Signer{"k1": newRSAKey(t)},
+		kid:    "k1",
+		groups: []string{"ops"},
+		codes:  make(map[string]grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.serveDiscovery)
+	mux.HandleFunc("/jwks", idp.serveKeys)
+	mux.HandleFunc("/token", idp.serveToken)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	return key
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key: %v", err)
+	}
+	return key
+}
+
+// provider returns a Provider configured against the fake provider.
+func (idp *fakeIdP) provider(t *testing.T) *Provider {
+	cfg := config.DefaultConfig()
+	cfg.OIDCIssuer = idp.URL
+	cfg.OIDCClientID = testClientID
+	cfg.OIDCClientSecret = testClientSecret
+	cfg.OIDCRedirectURL = testRedirectURL
+	cfg.OIDCGroupRoles = map[string]string{"ops": config.ScopeAdmin, "viewers": config.ScopeRead}
+	cfg.OIDCSessionSecret = "session-secret"
+	p, err := New(cfg, logger.NewLogger("test"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+// publish replaces the published keys and picks the one signing new ID tokens.
+func (idp *fakeIdP) publish(kid string, keys map[string]crypto.Signer) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys, idp.kid = keys, kid
+}
+
+func (idp *fakeIdP) key(kid string) crypto.Signer {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.keys[kid]
+}
+
+func (idp *fakeIdP) fetches() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.jwksFetches
+}
+
+// claims returns valid claims of an ID token for nonce.
+func (idp *fakeIdP) claims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    idp.URL,
+		"aud":    testClientID,
+		"sub":    "user-1",
+		"email":  "ada@example.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"nonce":  nonce,
+		"groups": idp.groups,
+	}
+}
+
+func (idp *fakeIdP) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.URL,
+		"authorization_endpoint": idp.URL + "/authorize",
+		"token_endpoint":         idp.URL + "/token",
+		"jwks_uri":               idp.URL + "/jwks",
+	})
+}
+
+func (idp *fakeIdP) serveKeys(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.jwksFetches++
+	var keys []map[string]string
+	for kid, key := range idp.keys {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig",
+				"n": encode(key.N.Bytes()),
+				"e": encode(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PrivateKey:
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": kid, "use": "sig", "crv": "P-256",
+				"x": encode(key.X.FillBytes(make([]byte, 32))),
+				"y": encode(key.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (idp *fakeIdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := r.PostFormValue("code")
+	g, ok := idp.codes[code]
+	delete(idp.codes, code)
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != testRedirectURL ||
+		encode(challenge[:]) != g.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	idToken := idp.replay
+	if idToken == "" {
+		idToken = signToken(idp.t, "RS256", idp.kid, idp.keys[idp.kid], idp.claims(g.nonce))
+	}
+	idp.lastIDToken = idToken
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+// authorize plays the user signing in at the provider's login page location and returns
+// the callback request the browser then sends.
+func (idp *fakeIdP) authorize(t *testing.T, location string) *http.Request {
+	u, err := url.Parse(location)
+	if err != nil || u.Path != "/authorize" {
+		t.Fatalf("login redirect to %q, want the authorization endpoint", location)
+	}
+	query := u.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("authorization request %s = %q, want %q", name, got, want)
+		}
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request %q lacks state, nonce or code challenge", location)
+	}
+	code := randomToken(16)
+	idp.mu.Lock()
+	idp.codes[code] = grant{nonce: query.Get("nonce"), challenge: query.Get("code_challenge")}
+	idp.mu.Unlock()
+	callback := url.Values{"code": {code}, "state": {query.Get("state")}}
+	return httptest.NewRequest(http.MethodGet, testRedirectURL+"?"+callback.Encode(), nil)
+}
+
+// signToken returns a compact JWS of claims. A nil key makes an HMAC-SHA256 signature
+// keyed with "secret".
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("signing token: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("signing token: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case nil:
+		m := hmac.New(sha256.New, []byte("secret"))
+		m.Write([]byte(signed))
+		signature = m.Sum(nil)
+	}
+	return signed + "." + encode(signature)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestVerify(t *testing.T) {
+	idp := newFakeIdP(t)
+	rsaKey := idp.key("k1")
+	ecKey := newECKey(t)
+	idp.publish("k1", map[string]crypto.Signer{"k1": rsaKey, "e1": ecKey})
+	p := idp.provider(t)
+	ctx := context.Background()
+	if _, err := p.metadata(ctx); err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+
+	with := func(change func(claims map[string]interface{})) map[string]interface{} {
+		claims := idp.claims("n1")
+		change(claims)
+		return claims
+	}
+	valid := idp.claims("n1")
+	tests := []struct {
+		name  string
+		token string
+		err   string // empty when the token is accepted
+	}{
+		{"rs256", signToken(t, "RS256", "k1", rsaKey, valid), ""},
+		{"es256", signToken(t, "ES256", "e1", ecKey, valid), ""},
+		{"audience list", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["aud"] = []string{"other", testClientID} })), ""},
+		{"expired within clock skew", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-clockSkew / 2).Unix() })), ""},
+		{"hmac with the rsa key id", signToken(t, "HS256", "k1", nil, valid), "unsupported token algorithm"},
+		{"unsigned", stripSignature(signToken(t, "none", "k1", nil, valid)), "unsupported token algorithm"},
+		{"malformed", "not-a-token", "malformed token"},
+		{"es256 header on an rsa key", signToken(t, "ES256", "k1", ecKey, valid), "does not match its key"},
+		{"rs256 header on an ec key", signToken(t, "RS256", "e1", rsaKey, valid), "does not match its key"},
+		{"signed by another key", signToken(t, "RS256", "k1", newRSAKey(t), valid), "invalid token signature"},
+		{"tampered claims", resign(signToken(t, "RS256", "k1", rsaKey, valid), with(func(c map[string]interface{}) { c["groups"] = []string{"ops", "root"} })), "invalid token signature"},
+		{"unknown key id", signToken(t, "RS256", "k9", rsaKey, valid), "unknown signing key"},
+		{"wrong issuer", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["iss"] = "https://evil.example" })), "token issued by"},
+		{"issuer with trailing slash", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["iss"] = idp.URL + "/" })), "token issued by"},
+		{"wrong audience", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["aud"] = "other" })), "not issued to this client"},
+		{"audience list without this client", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["aud"] = []string{"other"} })), "not issued to this client"},
+		{"missing audience", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { delete(c, "aud") })), "not issued to this client"},
+		{"expired", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() })), "token expired"},
+		{"missing expiry", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { delete(c, "exp") })), "token expired"},
+		{"nonce of another login", signToken(t, "RS256", "k1", rsaKey, idp.claims("n2")), "nonce does not match"},
+		{"missing nonce", signToken(t, "RS256", "k1", rsaKey, with(func(c map[string]interface{}) { delete(c, "nonce") })), "nonce does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := p.verify(ctx, tt.token, idp.URL, "n1")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("verify refused a valid token: %v", err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Errorf("claims = %v, want sub user-1", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("verify error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// stripSignature empties the signature of a token, as in alg none.
+func stripSignature(token string) string {
+	return token[:strings.LastIndex(token, ".")+1]
+}
+
+// resign swaps the claims of a signed token, keeping its header and signature.
+func resign(token string, claims map[string]interface{}) string {
+	parts := strings.Split(token, ".")
+	payload, _ := json.Marshal(claims)
+	return parts[0] + "." + encode(payload) + "." + parts[2]
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := idp.provider(t)
+	ctx := context.Background()
+	if _, err := p.metadata(ctx); err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	oldKey, newKey := idp.key("k1"), newRSAKey(t)
+	oldToken := signToken(t, "RS256", "k1", oldKey, idp.claims("n1"))
+	newToken := signToken(t, "RS256", "k2", newKey, idp.claims("n1"))
+
+	if _, err := p.verify(ctx, oldToken, idp.URL, "n1"); err != nil {
+		t.Fatalf("verify before rotation: %v", err)
+	}
+	if got := idp.fetches(); got != 1 {
+		t.Fatalf("key set fetched %d times, want 1", got)
+	}
+
+	// The provider rotates to k2 and retires k1.
+	idp.publish("k2", map[string]crypto.Signer{"k2": newKey})
+	if _, err := p.verify(ctx, newToken, idp.URL, "n1"); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("verify right after the last fetch = %v, want the unknown key refused", err)
+	}
+	if got := idp.fetches(); got != 1 {
+		t.Fatalf("unknown key refetched the key set within %v: %d fetches", keyRefreshMinGap, got)
+	}
+
+	p.keys.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-keyRefreshMinGap)
+	p.keys.mu.Unlock()
+	if _, err := p.verify(ctx, newToken, idp.URL, "n1"); err != nil {
+		t.Fatalf("verify after rotation: %v", err)
+	}
+	if got := idp.fetches(); got != 2 {
+		t.Fatalf("key set fetched %d times, want 2", got)
+	}
+	if _, err := p.verify(ctx, newToken, idp.URL, "n1"); err != nil {
+		t.Fatalf("verify with the cached rotated key: %v", err)
+	}
+	if _, err := p.verify(ctx, oldToken, idp.URL, "n1"); err == nil {
+		t.Fatal("token signed with the retired key accepted")
+	}
+	if got := idp.fetches(); got != 2 {
+		t.Errorf("key set fetched %d times, want 2", got)
+	}
+}
@@ -0,0 +1,253 @@
+// internal/streams/monitor.go
+package streams
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// LagReport is the latest poll of the declared streams and their durable consumers.
+type LagReport struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	Streams   []StreamLag   `json:"streams"`
+	Account   *StorageUsage `json:"account,omitempty"` // JetStream storage of the account, nil when it could not be read
+}
+
+// StreamLag is the state of one stream.
+type StreamLag struct {
+	Name      string        `json:"name"`
+	Domain    string        `json:"domain,omitempty"` // JetStream domain of a mirror outside the local one
+	Messages  uint64        `json:"messages"`
+	Storage   StorageUsage  `json:"storage"`
+	LastSeq   uint64        `json:"last_seq"`
+	Leader    string        `json:"leader,omitempty"`   // server leading a clustered stream
+	Replicas  []ReplicaLag  `json:"replicas,omitempty"` // followers of a clustered stream
+	Mirror    *MirrorLag    `json:"mirror,omitempty"`   // set for mirrors
+	Consumers []ConsumerLag `json:"consumers"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ReplicaLag is how far a follower of a clustered stream trails its leader.
+type ReplicaLag struct {
+	Name    string  `json:"name"`
+	Current bool    `json:"current"`
+	Offline bool    `json:"offline,omitempty"`
+	Lag     uint64  `json:"lag"`            // operations behind the leader
+	Active  float64 `json:"active_seconds"` // since the leader last heard from it
+}
+
+// MirrorLag is how far a mirror trails the stream it copies.
+type MirrorLag struct {
+	Source string  `json:"source"`
+	Lag    uint64  `json:"lag"`            // messages of the source not copied yet
+	Active float64 `json:"active_seconds"` // since the mirror last received from the source
+	Error  string  `json:"error,omitempty"`
+}
+
+// ConsumerLag is how far a durable consumer trails its stream.
+type ConsumerLag struct {
+	Name        string `json:"name"`
+	Pending     uint64 `json:"pending"`       // matching messages not delivered yet
+	AckPending  int    `json:"ack_pending"`   // delivered messages awaiting an ack
+	Redelivered int    `json:"redelivered"`   // messages delivered more than once
+	AckFloorLag uint64 `json:"ack_floor_lag"` // messages left to acknowledge above the ack floor, pending included
+}
+
+// StorageUsage is used bytes against a limit; Percent is only set with a limit.
+type StorageUsage struct {
+	Bytes    uint64  `json:"bytes"`
+	MaxBytes int64   `json:"max_bytes,omitempty"`
+	Percent  float64 `json:"percent,omitempty"`
+}
+
+func newStorageUsage(used uint64, limit int64) StorageUsage {
+	usage := StorageUsage{Bytes: used}
+	if limit > 0 {
+		usage.MaxBytes = limit
+		usage.Percent = float64(used) * 100 / float64(limit)
+	}
+	return usage
+}
+
+// Monitor polls the streams of a spec, their mirrors and durable consumers, keeps the
+// latest LagReport for /health and logs a warning when a consumer falls
+// streams_lag_warn messages behind, a replica or mirror falls streams_replica_lag_warn
+// behind or goes offline, or storage passes streams_storage_warn_percent of its limit,
+// and again once it recovers.
+type Monitor struct {
+	js          nats.JetStreamContext
+	domains     Domains
+	streams     []monitoredStream
+	interval    time.Duration
+	lagWarn     uint64
+	replicaWarn uint64
+	storage     float64
+	logger      *logger.Logger
+
+	mu     sync.Mutex
+	report LagReport
+	warned map[string]bool // thresholds currently crossed, by key
+}
+
+// monitoredStream is a namespaced stream name and the domain it lives in.
+type monitoredStream struct {
+	name   string
+	domain string
+}
+
+// NewMonitor watches the streams of spec and their mirrors. It returns nil when
+// JetStream is unavailable or streams_monitor_seconds is 0.
+func NewMonitor(domains Domains, spec Spec, cfg config.Config, logger *logger.Logger) *Monitor {
+	if domains == nil || cfg.StreamsMonitorSeconds <= 0 {
+		return nil
+	}
+	js, err := domains("")
+	if err != nil {
+		return nil
+	}
+	m := &Monitor{
+		js:          js,
+		domains:     domains,
+		interval:    time.Duration(cfg.StreamsMonitorSeconds) * time.Second,
+		lagWarn:     uint64(max(cfg.StreamsLagWarn, 0)),
+		replicaWarn: uint64(max(cfg.StreamsReplicaLagWarn, 0)),
+		storage:     float64(cfg.StreamsStorageWarnPercent),
+		logger:      logger,
+		warned:      make(map[string]bool),
+	}
+	for _, stream := range spec.Streams {
+		m.streams = append(m.streams, monitoredStream{name: cfg.ResourceName(stream.Name)})
+		if stream.Mirror != nil {
+			m.streams = append(m.streams, monitoredStream{name: cfg.ResourceName(stream.mirrorName()), domain: stream.Mirror.Domain})
+		}
+	}
+	return m
+}
+
+// Run polls until ctx is done, starting right away.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.poll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report returns the latest poll.
+func (m *Monitor) Report() LagReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.report
+}
+
+// poll reads the state of every stream, its durable consumers and the account.
+func (m *Monitor) poll() {
+	report := LagReport{CheckedAt: time.Now(), Streams: make([]StreamLag, 0, len(m.streams))}
+	for _, monitored := range m.streams {
+		report.Streams = append(report.Streams, m.pollStream(monitored))
+	}
+
+	if account, err := m.js.AccountInfo(); err == nil {
+		usage := newStorageUsage(account.Store, account.Limits.MaxStore)
+		report.Account = &usage
+		m.check("storage of the account", usage.Percent >= m.storage && m.storage > 0,
+			fmt.Sprintf("%.1f%% of %d bytes", usage.Percent, usage.MaxBytes))
+	} else {
+		m.logger.Debugf("Reading JetStream account info: %v", err)
+	}
+
+	m.mu.Lock()
+	m.report = report
+	m.mu.Unlock()
+}
+
+// pollStream reads the state of one stream, its replicas or source and its durable
+// consumers.
+func (m *Monitor) pollStream(monitored monitoredStream) StreamLag {
+	name := monitored.name
+	stream := StreamLag{Name: name, Domain: monitored.domain, Consumers: []ConsumerLag{}}
+	js, err := m.domains(monitored.domain)
+	if err != nil {
+		stream.Error = err.Error()
+		return stream
+	}
+	info, err := js.StreamInfo(name)
+	if err != nil {
+		stream.Error = err.Error()
+		return stream
+	}
+	stream.Messages = info.State.Msgs
+	stream.Storage = newStorageUsage(info.State.Bytes, info.Config.MaxBytes)
+	stream.LastSeq = info.State.LastSeq
+	m.check("storage of stream "+name, stream.Storage.Percent >= m.storage && m.storage > 0,
+		fmt.Sprintf("%.1f%% of %d bytes", stream.Storage.Percent, stream.Storage.MaxBytes))
+
+	if cluster := info.Cluster; cluster != nil {
+		stream.Leader = cluster.Leader
+		for _, peer := range cluster.Replicas {
+			replica := ReplicaLag{
+				Name:    peer.Name,
+				Current: peer.Current,
+				Offline: peer.Offline,
+				Lag:     peer.Lag,
+				Active:  peer.Active.Seconds(),
+			}
+			stream.Replicas = append(stream.Replicas, replica)
+			m.check(fmt.Sprintf("replica %s of stream %s", peer.Name, name),
+				peer.Offline || (peer.Lag >= m.replicaWarn && m.replicaWarn > 0),
+				fmt.Sprintf("offline %t, %d operations behind the leader", peer.Offline, peer.Lag))
+		}
+	}
+	if source := info.Mirror; source != nil {
+		mirror := &MirrorLag{Source: source.Name, Lag: source.Lag, Active: source.Active.Seconds()}
+		if source.Error != nil {
+			mirror.Error = source.Error.Error()
+		}
+		stream.Mirror = mirror
+		m.check(fmt.Sprintf("mirror %s of stream %s", name, source.Name),
+			source.Error != nil || (source.Lag >= m.replicaWarn && m.replicaWarn > 0),
+			fmt.Sprintf("%d messages behind, error %q", source.Lag, mirror.Error))
+	}
+
+	for consumer := range js.Consumers(name) {
+		if consumer.Config.Durable == "" {
+			continue // history reads create short-lived ephemeral consumers
+		}
+		lag := ConsumerLag{
+			Name:        consumer.Name,
+			Pending:     consumer.NumPending,
+			AckPending:  consumer.NumAckPending,
+			Redelivered: consumer.NumRedelivered,
+			AckFloorLag: consumer.NumPending + consumer.Delivered.Consumer - consumer.AckFloor.Consumer,
+		}
+		stream.Consumers = append(stream.Consumers, lag)
+		m.check(fmt.Sprintf("lag of consumer %s on stream %s", lag.Name, name), lag.AckFloorLag >= m.lagWarn && m.lagWarn > 0,
+			fmt.Sprintf("%d messages to acknowledge, %d pending, %d awaiting ack", lag.AckFloorLag, lag.Pending, lag.AckPending))
+	}
+	return stream
+}
+
+// check logs a warning when a threshold is crossed and a notice when it clears, once each.
+func (m *Monitor) check(key string, crossed bool, detail string) {
+	m.mu.Lock()
+	was := m.warned[key]
+	m.warned[key] = crossed
+	m.mu.Unlock()
+	switch {
+	case crossed && !was:
+		m.logger.Warnf("JetStream %s is above its threshold: %s", key, detail)
+	case !crossed && was:
+		m.logger.Infof("JetStream %s is back below its threshold: %s", key, detail)
+	}
+}
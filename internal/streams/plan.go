@@ -0,0 +1,288 @@
+// internal/streams/plan.go
+package streams
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/erilali/internal/config"
+	"github.com/nats-io/nats.go"
+)
+
+// Actions of a planned change.
+const (
+	ActionNone         = "none"         // the server already matches the spec
+	ActionCreate       = "create"       // the stream or consumer does not exist yet
+	ActionUpdate       = "update"       // settings the server can change in place differ
+	ActionIncompatible = "incompatible" // settings differ that the server cannot change in place
+	ActionError        = "error"        // the current state could not be read
+)
+
+// FieldDiff is one setting that differs between the server and the spec.
+type FieldDiff struct {
+	Field   string `json:"field"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+}
+
+// Change is what reconciling one stream or consumer with the spec would do.
+type Change struct {
+	Stream   string      `json:"stream"`
+	Consumer string      `json:"consumer,omitempty"` // empty for changes of the stream itself
+	Domain   string      `json:"domain,omitempty"`   // JetStream domain of a mirror outside the local one
+	Action   string      `json:"action"`
+	Diffs    []FieldDiff `json:"diffs,omitempty"`
+	Error    string      `json:"error,omitempty"`
+
+	stream   *nats.StreamConfig
+	consumer *nats.ConsumerConfig
+}
+
+// String describes the change for logs, e.g. "update stream ROUNDS: max_age 30m0s -> 1h0m0s".
+func (c Change) String() string {
+	var b strings.Builder
+	b.WriteString(c.Action)
+	if c.Consumer != "" {
+		fmt.Fprintf(&b, " consumer %s of stream %s", c.Consumer, c.Stream)
+	} else {
+		fmt.Fprintf(&b, " stream %s", c.Stream)
+	}
+	for i, diff := range c.Diffs {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(&b, "%s%s %s -> %s", sep, diff.Field, diff.Current, diff.Desired)
+	}
+	if c.Error != "" {
+		fmt.Fprintf(&b, ": %s", c.Error)
+	}
+	return b.String()
+}
+
+// Plan compares the streams, their mirrors and consumers on the server with the spec
+// without changing anything. Streams and consumers the spec does not mention are left
+// out: reconciling never deletes.
+func Plan(domains Domains, spec Spec, cfg config.Config) []Change {
+	js, err := domains("")
+	if err != nil {
+		return []Change{{Action: ActionError, Error: err.Error()}}
+	}
+	var changes []Change
+	for _, stream := range spec.Streams {
+		desired := stream.streamConfig(cfg)
+		change := planStream(js, desired)
+		changes = append(changes, change)
+
+		for _, consumer := range stream.Consumers {
+			changes = append(changes, planConsumer(js, desired.Name, consumer.consumerConfig(cfg), change.Action == ActionCreate))
+		}
+
+		if stream.Mirror != nil {
+			mirror := stream.mirrorConfig(cfg)
+			mirrorJS, err := domains(stream.Mirror.Domain)
+			if err != nil {
+				changes = append(changes, Change{Stream: mirror.Name, Domain: stream.Mirror.Domain, Action: ActionError, Error: err.Error()})
+				continue
+			}
+			change := planStream(mirrorJS, mirror)
+			change.Domain = stream.Mirror.Domain
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// planStream compares one stream, or mirror, with the spec.
+func planStream(js nats.JetStreamContext, desired nats.StreamConfig) Change {
+	change := Change{Stream: desired.Name, Action: ActionNone}
+	info, err := js.StreamInfo(desired.Name)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		change.Action = ActionCreate
+		change.stream = &desired
+	case err != nil:
+		change.Action = ActionError
+		change.Error = err.Error()
+	default:
+		updated, diffs, compatible := diffStream(info.Config, desired)
+		change.Diffs = diffs
+		switch {
+		case !compatible:
+			change.Action = ActionIncompatible
+		case len(diffs) > 0:
+			change.Action = ActionUpdate
+			change.stream = &updated
+		}
+	}
+	return change
+}
+
+// planConsumer compares one durable consumer with the spec. The consumers of a stream
+// that is about to be created are created with it.
+func planConsumer(js nats.JetStreamContext, stream string, desired nats.ConsumerConfig, newStream bool) Change {
+	change := Change{Stream: stream, Consumer: desired.Durable, Action: ActionNone}
+	var info *nats.ConsumerInfo
+	var err error = nats.ErrConsumerNotFound
+	if !newStream {
+		info, err = js.ConsumerInfo(stream, desired.Durable)
+	}
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound):
+		change.Action = ActionCreate
+		change.consumer = &desired
+	case err != nil:
+		change.Action = ActionError
+		change.Error = err.Error()
+	default:
+		updated, diffs, compatible := diffConsumer(info.Config, desired)
+		change.Diffs = diffs
+		switch {
+		case !compatible:
+			change.Action = ActionIncompatible
+		case len(diffs) > 0:
+			change.Action = ActionUpdate
+			change.consumer = &updated
+		}
+	}
+	return change
+}
+
+// diffStream returns the current configuration with the settings managed by the spec
+// replaced, so settings made outside the spec survive an update, and the differences.
+// compatible is false when the storage, retention or mirrored stream differ, which
+// JetStream cannot change on an existing stream.
+func diffStream(current, desired nats.StreamConfig) (nats.StreamConfig, []FieldDiff, bool) {
+	var diffs []FieldDiff
+	add := func(field string, from, to interface{}) {
+		diffs = append(diffs, FieldDiff{Field: field, Current: fmt.Sprint(from), Desired: fmt.Sprint(to)})
+	}
+	compatible := true
+	if current.Storage != desired.Storage {
+		add("storage", current.Storage, desired.Storage)
+		compatible = false
+	}
+	if current.Retention != desired.Retention {
+		add("retention", current.Retention, desired.Retention)
+		compatible = false
+	}
+	if mirrorName(current) != mirrorName(desired) {
+		add("mirror", mirrorName(current), mirrorName(desired))
+		compatible = false
+	}
+	if !sameSubjects(current.Subjects, desired.Subjects) {
+		add("subjects", current.Subjects, desired.Subjects)
+	}
+	if current.MaxAge != desired.MaxAge {
+		add("max_age", current.MaxAge, desired.MaxAge)
+	}
+	if current.MaxMsgs != desired.MaxMsgs {
+		add("max_msgs", current.MaxMsgs, desired.MaxMsgs)
+	}
+	if current.MaxBytes != desired.MaxBytes {
+		add("max_bytes", current.MaxBytes, desired.MaxBytes)
+	}
+	if max(current.Replicas, 1) != desired.Replicas {
+		add("replicas", current.Replicas, desired.Replicas)
+	}
+	if desired.Placement != nil && placementCluster(current) != desired.Placement.Cluster {
+		add("cluster", placementCluster(current), desired.Placement.Cluster)
+	}
+
+	updated := current
+	updated.Subjects = desired.Subjects
+	updated.MaxAge = desired.MaxAge
+	updated.MaxMsgs = desired.MaxMsgs
+	updated.MaxBytes = desired.MaxBytes
+	updated.Replicas = desired.Replicas
+	if desired.Placement != nil {
+		updated.Placement = desired.Placement
+	}
+	return updated, diffs, compatible
+}
+
+// mirrorName returns the stream a configuration mirrors, empty for regular streams.
+func mirrorName(config nats.StreamConfig) string {
+	if config.Mirror == nil {
+		return ""
+	}
+	return config.Mirror.Name
+}
+
+// placementCluster returns the cluster a stream is pinned to, empty when it is not.
+func placementCluster(config nats.StreamConfig) string {
+	if config.Placement == nil {
+		return ""
+	}
+	return config.Placement.Cluster
+}
+
+// diffConsumer is diffStream for durable consumers, whose ack and deliver policies
+// cannot change once created.
+func diffConsumer(current, desired nats.ConsumerConfig) (nats.ConsumerConfig, []FieldDiff, bool) {
+	var diffs []FieldDiff
+	add := func(field string, from, to interface{}) {
+		diffs = append(diffs, FieldDiff{Field: field, Current: fmt.Sprint(from), Desired: fmt.Sprint(to)})
+	}
+	compatible := true
+	if current.AckPolicy != desired.AckPolicy {
+		add("ack_policy", current.AckPolicy, desired.AckPolicy)
+		compatible = false
+	}
+	if current.DeliverPolicy != desired.DeliverPolicy {
+		add("deliver_policy", current.DeliverPolicy, desired.DeliverPolicy)
+		compatible = false
+	}
+	if current.FilterSubject != desired.FilterSubject {
+		add("filter_subject", current.FilterSubject, desired.FilterSubject)
+	}
+	if current.AckWait != desired.AckWait {
+		add("ack_wait", current.AckWait, desired.AckWait)
+	}
+	if current.MaxDeliver != desired.MaxDeliver {
+		add("max_deliver", current.MaxDeliver, desired.MaxDeliver)
+	}
+
+	updated := current
+	updated.FilterSubject = desired.FilterSubject
+	updated.AckWait = desired.AckWait
+	updated.MaxDeliver = desired.MaxDeliver
+	return updated, diffs, compatible
+}
+
+// Apply carries out the create and update changes of a plan, those of mirrors in their
+// domain, and returns the plan with the error of every change that failed. Other changes
+// are left as they are. Applying a plan twice is harmless: a fresh plan of the applied
+// spec has nothing to do.
+func Apply(domains Domains, changes []Change) []Change {
+	applied := make([]Change, len(changes))
+	failedStreams := make(map[string]bool)
+	for i, change := range changes {
+		var err error
+		js, domainErr := domains(change.Domain)
+		switch {
+		case change.stream == nil && change.consumer == nil:
+		case domainErr != nil:
+			err = domainErr
+		case change.consumer != nil && failedStreams[change.Stream]:
+			err = errors.New("stream was not reconciled")
+		case change.stream != nil && change.Action == ActionCreate:
+			_, err = js.AddStream(change.stream)
+		case change.stream != nil:
+			_, err = js.UpdateStream(change.stream)
+		case change.consumer != nil && change.Action == ActionCreate:
+			_, err = js.AddConsumer(change.Stream, change.consumer)
+		case change.consumer != nil:
+			_, err = js.UpdateConsumer(change.Stream, change.consumer)
+		}
+		if err != nil {
+			change.Error = err.Error()
+			if change.Consumer == "" {
+				failedStreams[change.Stream] = true
+			}
+		}
+		applied[i] = change
+	}
+	return applied
+}
@@ -0,0 +1,307 @@
+// internal/streams/spec.go
+package streams
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erilali/internal/config"
+	"github.com/nats-io/nats.go"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec declares the JetStream streams of the server and their durable consumers.
+// Names are namespaced with subject_prefix like the rest of the server's resources,
+// so one spec serves every deployment sharing a NATS cluster.
+type Spec struct {
+	Streams []StreamSpec `yaml:"streams"`
+}
+
+// StreamSpec declares one stream. Unset limits mean unlimited.
+type StreamSpec struct {
+	Name      string         `yaml:"name"`
+	Subjects  []string       `yaml:"subjects"`
+	Retention string         `yaml:"retention"` // limits (default), interest or workqueue
+	Storage   string         `yaml:"storage"`   // file (default) or memory
+	MaxAge    time.Duration  `yaml:"max_age"`   // e.g. 30m or 24h
+	MaxMsgs   int64          `yaml:"max_msgs"`
+	MaxBytes  int64          `yaml:"max_bytes"`
+	Replicas  int            `yaml:"replicas"` // streams_replicas when unset
+	Mirror    *MirrorSpec    `yaml:"mirror"`   // copy of the stream kept in a disaster recovery cluster
+	Consumers []ConsumerSpec `yaml:"consumers"`
+}
+
+// MirrorSpec declares a read-only mirror of a stream, kept up to date by JetStream in
+// another cluster of a super-cluster or in another JetStream domain, such as a DR site
+// joined over a leafnode. Unset limits follow the mirrored stream.
+type MirrorSpec struct {
+	Name         string        `yaml:"name"`          // <stream>_MIRROR when unset
+	Cluster      string        `yaml:"cluster"`       // cluster to place the mirror in, any when empty
+	Domain       string        `yaml:"domain"`        // JetStream domain to create the mirror in, the local one when empty
+	SourceDomain string        `yaml:"source_domain"` // JetStream domain of the mirrored stream, needed with domain
+	Storage      string        `yaml:"storage"`
+	MaxAge       time.Duration `yaml:"max_age"`
+	Replicas     int           `yaml:"replicas"`
+}
+
+// ConsumerSpec declares a durable pull consumer of a stream.
+type ConsumerSpec struct {
+	Name          string        `yaml:"name"`
+	FilterSubject string        `yaml:"filter_subject"` // every subject of the stream when empty
+	AckPolicy     string        `yaml:"ack_policy"`     // explicit (default), all or none
+	DeliverPolicy string        `yaml:"deliver_policy"` // all (default), last, new or last_per_subject
+	AckWait       time.Duration `yaml:"ack_wait"`       // 30s when unset
+	MaxDeliver    int           `yaml:"max_deliver"`    // unlimited when unset
+}
+
+var (
+	retentionPolicies = map[string]nats.RetentionPolicy{
+		"":          nats.LimitsPolicy,
+		"limits":    nats.LimitsPolicy,
+		"interest":  nats.InterestPolicy,
+		"workqueue": nats.WorkQueuePolicy,
+	}
+	storageTypes = map[string]nats.StorageType{
+		"":       nats.FileStorage,
+		"file":   nats.FileStorage,
+		"memory": nats.MemoryStorage,
+	}
+	ackPolicies = map[string]nats.AckPolicy{
+		"":         nats.AckExplicitPolicy,
+		"explicit": nats.AckExplicitPolicy,
+		"all":      nats.AckAllPolicy,
+		"none":     nats.AckNonePolicy,
+	}
+	deliverPolicies = map[string]nats.DeliverPolicy{
+		"":                 nats.DeliverAllPolicy,
+		"all":              nats.DeliverAllPolicy,
+		"last":             nats.DeliverLastPolicy,
+		"new":              nats.DeliverNewPolicy,
+		"last_per_subject": nats.DeliverLastPerSubjectPolicy,
+	}
+)
+
+const defaultAckWait = 30 * time.Second
+
+// Domains returns the JetStream context of a domain: the local context for "", one that
+// reaches another JetStream domain otherwise.
+type Domains func(domain string) (nats.JetStreamContext, error)
+
+// ConnDomains returns the Domains of a connection whose local JetStream context is js.
+func ConnDomains(nc *nats.Conn, js nats.JetStreamContext) Domains {
+	return func(domain string) (nats.JetStreamContext, error) {
+		if domain == "" {
+			return js, nil
+		}
+		return nc.JetStream(nats.Domain(domain))
+	}
+}
+
+// Load reads and validates the spec file at path. Errors wrap fs.ErrNotExist when the
+// file does not exist.
+func Load(path string) (Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, err
+	}
+	var spec Spec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		return Spec{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return Spec{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Validate checks that names are unique and usable, every stream has subjects and
+// every policy is known.
+func (s Spec) Validate() error {
+	streamNames := make(map[string]bool)
+	for _, stream := range s.Streams {
+		if !validName(stream.Name) {
+			return fmt.Errorf("invalid stream name %q", stream.Name)
+		}
+		if streamNames[stream.Name] {
+			return fmt.Errorf("stream %s is declared twice", stream.Name)
+		}
+		streamNames[stream.Name] = true
+		if len(stream.Subjects) == 0 {
+			return fmt.Errorf("stream %s has no subjects", stream.Name)
+		}
+		if _, ok := retentionPolicies[stream.Retention]; !ok {
+			return fmt.Errorf("stream %s: unknown retention %q", stream.Name, stream.Retention)
+		}
+		if _, ok := storageTypes[stream.Storage]; !ok {
+			return fmt.Errorf("stream %s: unknown storage %q", stream.Name, stream.Storage)
+		}
+		if stream.MaxAge < 0 || stream.MaxMsgs < 0 || stream.MaxBytes < 0 || stream.Replicas < 0 {
+			return fmt.Errorf("stream %s: limits must not be negative", stream.Name)
+		}
+		if mirror := stream.Mirror; mirror != nil {
+			name := stream.mirrorName()
+			if !validName(name) {
+				return fmt.Errorf("stream %s: invalid mirror name %q", stream.Name, name)
+			}
+			switch {
+			case mirror.Domain == "" && mirror.Cluster == "":
+				return fmt.Errorf("stream %s: mirror needs a cluster or domain to recover from", stream.Name)
+			case mirror.Domain != "" && mirror.SourceDomain == "":
+				return fmt.Errorf("stream %s: mirror in domain %s needs the source_domain of the stream", stream.Name, mirror.Domain)
+			case mirror.Domain == "" && streamNames[name]:
+				return fmt.Errorf("stream %s: mirror %s is declared twice", stream.Name, name)
+			}
+			if mirror.Domain == "" {
+				streamNames[name] = true // mirrors in the local domain share its stream names
+			}
+			if _, ok := storageTypes[mirror.Storage]; !ok {
+				return fmt.Errorf("stream %s: unknown mirror storage %q", stream.Name, mirror.Storage)
+			}
+			if mirror.MaxAge < 0 || mirror.Replicas < 0 {
+				return fmt.Errorf("stream %s: mirror limits must not be negative", stream.Name)
+			}
+		}
+
+		consumerNames := make(map[string]bool)
+		for _, consumer := range stream.Consumers {
+			if !validName(consumer.Name) {
+				return fmt.Errorf("stream %s: invalid consumer name %q", stream.Name, consumer.Name)
+			}
+			if consumerNames[consumer.Name] {
+				return fmt.Errorf("stream %s: consumer %s is declared twice", stream.Name, consumer.Name)
+			}
+			consumerNames[consumer.Name] = true
+			if _, ok := ackPolicies[consumer.AckPolicy]; !ok {
+				return fmt.Errorf("consumer %s: unknown ack_policy %q", consumer.Name, consumer.AckPolicy)
+			}
+			if _, ok := deliverPolicies[consumer.DeliverPolicy]; !ok {
+				return fmt.Errorf("consumer %s: unknown deliver_policy %q", consumer.Name, consumer.DeliverPolicy)
+			}
+			if consumer.AckWait < 0 || consumer.MaxDeliver < 0 {
+				return fmt.Errorf("consumer %s: limits must not be negative", consumer.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the declared streams, before namespacing.
+func (s Spec) Names() []string {
+	names := make([]string, 0, len(s.Streams))
+	for _, stream := range s.Streams {
+		names = append(names, stream.Name)
+	}
+	return names
+}
+
+// validName reports whether name can name a stream or consumer: JetStream rejects
+// dots, wildcards and whitespace.
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ".*> \t\r\n/\\")
+}
+
+// streamConfig returns the stream configuration the spec asks for, namespaced for cfg.
+// Limits are normalized the way the server reports them, so they compare equal.
+func (s StreamSpec) streamConfig(cfg config.Config) nats.StreamConfig {
+	subjects := make([]string, len(s.Subjects))
+	for i, subject := range s.Subjects {
+		subjects[i] = cfg.Subject(subject)
+	}
+	return nats.StreamConfig{
+		Name:      cfg.ResourceName(s.Name),
+		Subjects:  subjects,
+		Retention: retentionPolicies[s.Retention],
+		Storage:   storageTypes[s.Storage],
+		MaxAge:    s.MaxAge,
+		MaxMsgs:   unlimitedIfZero(s.MaxMsgs),
+		MaxBytes:  unlimitedIfZero(s.MaxBytes),
+		Replicas:  s.replicas(cfg),
+	}
+}
+
+// replicas returns the replicas the stream asks for, streams_replicas when it sets none.
+func (s StreamSpec) replicas(cfg config.Config) int {
+	if s.Replicas > 0 {
+		return s.Replicas
+	}
+	return max(cfg.StreamsReplicas, 1)
+}
+
+// mirrorName returns the name of the stream's mirror, before namespacing.
+func (s StreamSpec) mirrorName() string {
+	if s.Mirror.Name != "" {
+		return s.Mirror.Name
+	}
+	return s.Name + "_MIRROR"
+}
+
+// mirrorConfig returns the configuration of the stream's mirror, namespaced for cfg.
+// A mirror has no subjects of its own: JetStream copies every message of the source.
+func (s StreamSpec) mirrorConfig(cfg config.Config) nats.StreamConfig {
+	source := s.streamConfig(cfg)
+	mirror := s.Mirror
+	desired := nats.StreamConfig{
+		Name:      cfg.ResourceName(s.mirrorName()),
+		Mirror:    &nats.StreamSource{Name: source.Name, Domain: mirror.SourceDomain},
+		Retention: source.Retention,
+		Storage:   source.Storage,
+		MaxAge:    source.MaxAge,
+		MaxMsgs:   source.MaxMsgs,
+		MaxBytes:  source.MaxBytes,
+		Replicas:  source.Replicas,
+	}
+	if mirror.Storage != "" {
+		desired.Storage = storageTypes[mirror.Storage]
+	}
+	if mirror.MaxAge > 0 {
+		desired.MaxAge = mirror.MaxAge
+	}
+	if mirror.Replicas > 0 {
+		desired.Replicas = mirror.Replicas
+	}
+	if mirror.Cluster != "" {
+		desired.Placement = &nats.Placement{Cluster: mirror.Cluster}
+	}
+	return desired
+}
+
+// consumerConfig returns the durable consumer configuration the spec asks for.
+func (c ConsumerSpec) consumerConfig(cfg config.Config) nats.ConsumerConfig {
+	consumer := nats.ConsumerConfig{
+		Durable:       cfg.ResourceName(c.Name),
+		AckPolicy:     ackPolicies[c.AckPolicy],
+		DeliverPolicy: deliverPolicies[c.DeliverPolicy],
+		AckWait:       c.AckWait,
+		MaxDeliver:    int(unlimitedIfZero(int64(c.MaxDeliver))),
+	}
+	if consumer.AckWait == 0 {
+		consumer.AckWait = defaultAckWait
+	}
+	if c.FilterSubject != "" {
+		consumer.FilterSubject = cfg.Subject(c.FilterSubject)
+	}
+	return consumer
+}
+
+func unlimitedIfZero(n int64) int64 {
+	if n == 0 {
+		return -1
+	}
+	return n
+}
+
+// sameSubjects reports whether two subject lists hold the same subjects in any order.
+func sameSubjects(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 
+	"github.com/erilali/internal/config"
 	"github.com/erilali/internal/logger"
 )
 
@@ -25,3 +26,34 @@ func LoadLoggerConfig(filePath string) (logger.LogConfig, error) {
 	}
 	return config, nil
 }
+
+// LoadConfig loads the server configuration from a JSON file and applies environment overrides
+func LoadConfig(filePath string) (config.Config, error) {
+	cfg := config.DefaultConfig()
+	file, err := os.Open(filePath)
+	if err != nil {
+		cfg.ApplyEnv()
+		if os.IsNotExist(err) {
+			return cfg, usePacingProfile(&cfg)
+		}
+		return cfg, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(&cfg)
+	cfg.ApplyEnv()
+	if err == nil {
+		err = usePacingProfile(&cfg)
+	}
+	return cfg, err
+}
+
+// usePacingProfile applies the selected pacing profile, dropping a selection that
+// cannot be applied so rounds do not claim a profile they do not follow.
+func usePacingProfile(cfg *config.Config) error {
+	if err := cfg.UsePacingProfile(cfg.PacingProfile); err != nil {
+		cfg.PacingProfile = ""
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// internal/web/web.go
+// Package web serves the browser UI, embedded in the binary so the server deploys as a
+// single file.
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed dist
+var embedded embed.FS
+
+const (
+	indexFile       = "/index.html"
+	gzipMinBytes    = 1024            // smaller files are not worth compressing
+	immutablePrefix = "/assets/"      // fingerprinted build output, which never changes under the same name
+	immutableMaxAge = 365 * 24 * 3600 // seconds
+	assetMaxAge     = 3600            // seconds, for other files
+)
+
+// reservedPrefixes are never answered with the SPA's index.html: a client asking for a
+// missing API route gets a 404 rather than HTML.
+var reservedPrefixes = []string{"/api/", "/ws/"}
+
+// asset is a file of the UI held in memory, with its gzipped form when that is smaller.
+type asset struct {
+	body        []byte
+	gzipped     []byte
+	contentType string
+	etag        string
+}
+
+// Handler serves the UI's files. Paths that match no file and have no extension get
+// index.html, so client-side routes of a single page app load the app.
+type Handler struct {
+	assets map[string]*asset
+}
+
+// New reads the UI from dir, or from the assets embedded in the binary when dir is empty.
+// Files are read once; changes in dir need a restart.
+func New(dir string) (*Handler, error) {
+	var fsys fs.FS
+	if dir != "" {
+		fsys = os.DirFS(dir)
+	} else {
+		sub, err := fs.Sub(embedded, "dist")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+
+	h := &Handler{assets: make(map[string]*asset)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		h.assets["/"+name] = newAsset(name, body)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading UI assets: %w", err)
+	}
+	if h.assets[indexFile] == nil {
+		return nil, errors.New("UI assets have no index.html")
+	}
+	return h, nil
+}
+
+func newAsset(name string, body []byte) *asset {
+	sum := sha256.Sum256(body)
+	a := &asset{
+		body:        body,
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}
+	if a.contentType == "" {
+		a.contentType = http.DetectContentType(body)
+	}
+	if len(body) >= gzipMinBytes && compressible(a.contentType) {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(body)
+		zw.Close()
+		if buf.Len() < len(body) {
+			a.gzipped = buf.Bytes()
+		}
+	}
+	return a
+}
+
+// compressible reports whether a content type is text that gzip shrinks; images and
+// fonts are compressed already.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/javascript", mediaType == "application/json",
+		mediaType == "application/manifest+json", mediaType == "image/svg+xml",
+		mediaType == "application/wasm":
+		return true
+	}
+	return false
+}
+
+// ServeHTTP answers GET and HEAD requests with the file at the request path, index.html
+// for / and for client-side routes, with caching headers and gzip when the client
+// accepts it.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	if name == "/" {
+		name = indexFile
+	}
+	a, ok := h.assets[name]
+	if !ok {
+		if path.Ext(name) != "" || reserved(name) {
+			http.NotFound(w, r)
+			return
+		}
+		name, a = indexFile, h.assets[indexFile]
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", a.contentType)
+	header.Set("ETag", a.etag)
+	header.Set("Vary", "Accept-Encoding")
+	header.Set("X-Content-Type-Options", "nosniff")
+	switch {
+	case name == indexFile:
+		header.Set("Cache-Control", "no-cache") // revalidated with the ETag, so deploys show at once
+	case strings.HasPrefix(name, immutablePrefix):
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(immutableMaxAge)+", immutable")
+	default:
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(assetMaxAge))
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), a.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := a.body
+	if a.gzipped != nil && acceptsGzip(r) {
+		header.Set("Content-Encoding", "gzip")
+		body = a.gzipped
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+func reserved(name string) bool {
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(name+"/", prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header lists etag or is "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the client accepts gzip, ignoring ones that refuse it
+// with q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			q := strings.ReplaceAll(params, " ", "")
+			return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+		}
+	}
+	return false
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/erilali/internal/api"
+	"github.com/erilali/internal/config"
+	"github.com/erilali/internal/eventbus"
 	"github.com/erilali/internal/hub"
 	"github.com/erilali/internal/logger"
 	"github.com/erilali/internal/util"
@@ -17,30 +19,45 @@ var serverLogger *logger.Logger
 
 func main() {
 	// Load logger configuration
-	config, err := util.LoadLoggerConfig("logger_config.json")
+	logConfig, err := util.LoadLoggerConfig("logger_config.json")
 	if err != nil {
 		fmt.Printf("Error loading logger config: %v, using defaults\n", err)
 	}
 
 	// Override specific settings for development (remove these lines for production)
-	config.LogToJSON = false
-	config.LogToFile = false
+	logConfig.LogToJSON = false
+	logConfig.LogToFile = false
 
-	logger.InitLogger(config)
+	logger.InitLogger(logConfig)
 	serverLogger = logger.NewLogger("server")
 	serverLogger.Info("Logger initialized with configuration")
 	serverLogger.WithFields(map[string]interface{}{
-		"level":       config.Level,
-		"log_to_file": config.LogToFile,
-		"log_to_json": config.LogToJSON,
-		"file_path":   config.FilePath,
+		"level":       logConfig.Level,
+		"log_to_file": logConfig.LogToFile,
+		"log_to_json": logConfig.LogToJSON,
+		"no_color":    logConfig.NoColor,
+		"file_path":   logConfig.FilePath,
 	}).Info("Logger configuration details")
 
+	// Checks of things only main knows about, reported by the startup self-check.
+	var preflight []api.CheckResult
+	if logConfig.LogToFile && logConfig.FilePath != "" {
+		preflight = append(preflight, api.CheckLogFile(logConfig.FilePath))
+	}
+
+	cfg, err := util.LoadConfig("server_config.json")
+	if err != nil {
+		serverLogger.Errorf("Error loading server config: %v, using defaults", err)
+		preflight = append(preflight, api.CheckResult{Name: "config_file", Status: api.CheckFail, Detail: err.Error()})
+	} else {
+		preflight = append(preflight, api.CheckResult{Name: "config_file", Status: api.CheckOK, Detail: "server_config.json"})
+	}
+
 	// In Go 1.20+, the global random number generator in the math/rand package is
 	// automatically seeded. Explicit seeding is no longer necessary for most use cases.
 
 	// Use the new modularized API and Hub packages
-	api.StartServer(serverLogger, func(nc *nats.Conn, js nats.JetStreamContext, logger *logger.Logger) interface{} {
-		return hub.NewHub(nc, js, logger)
-	})
+	api.StartServer(cfg, serverLogger, func(cfg config.Config, nc *nats.Conn, js nats.JetStreamContext, bus eventbus.EventBus, logger *logger.Logger) interface{} {
+		return hub.NewHub(cfg, nc, js, bus, logger)
+	}, preflight...)
 }